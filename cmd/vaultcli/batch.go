@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaultransport"
+)
+
+// openBatch opens the input of the batch methods, the standard input for -.
+func openBatch(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(name)
+}
+
+// readBatch calls item with the line number and the text of each non-empty
+// line of r, until item fails, and returns the error reading r or of item.
+func readBatch(r io.Reader, item func(id, line string) error) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		if line := scanner.Text(); line != "" {
+			if err := item(strconv.Itoa(n), line); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// batchHash hashes the passwords of the lines of r and writes the line
+// number and hash of each line to w, separated by a tab. The failed lines
+// are logged rather than written. It returns the number of failed lines.
+func batchHash(ctx context.Context, client *vaultransport.BatchClient, r io.Reader, w io.Writer, logger log.Logger) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	items := make(chan vaultendpoint.BatchHashRequest)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(items)
+		readErr <- readBatch(r, func(id, line string) error {
+			select {
+			case items <- vaultendpoint.BatchHashRequest{ID: id, Password: line}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	out := bufio.NewWriter(w)
+	var failed int
+	err := client.Hash(ctx, items, func(resp vaultendpoint.BatchHashResponse) {
+		if resp.Err != nil {
+			failed++
+			level.Error(logger).Log("method", "BatchHash", "line", resp.ID, "err", resp.Err)
+			return
+		}
+		fmt.Fprintf(out, "%s\t%s\n", resp.ID, resp.Hash)
+	})
+	cancel()
+	if rerr := <-readErr; err == nil {
+		err = rerr
+	}
+	if ferr := out.Flush(); err == nil {
+		err = ferr
+	}
+	return failed, err
+}
+
+// batchValidate validates the passwords and hashes of the lines of r,
+// separated by a tab, and writes the line number and validity of each line to
+// w, separated by a tab. The failed lines are logged rather than written. It
+// returns the number of failed lines.
+func batchValidate(ctx context.Context, client *vaultransport.BatchClient, r io.Reader, w io.Writer, logger log.Logger) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	items := make(chan vaultendpoint.BatchValidateRequest)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(items)
+		readErr <- readBatch(r, func(id, line string) error {
+			i := strings.LastIndexByte(line, '\t')
+			if i < 0 {
+				return fmt.Errorf("line %s: want a password and a hash separated by a tab", id)
+			}
+			select {
+			case items <- vaultendpoint.BatchValidateRequest{ID: id, Password: line[:i], Hash: line[i+1:]}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	out := bufio.NewWriter(w)
+	var failed int
+	err := client.Validate(ctx, items, func(resp vaultendpoint.BatchValidateResponse) {
+		if resp.Err != nil {
+			failed++
+			level.Error(logger).Log("method", "BatchValidate", "line", resp.ID, "err", resp.Err)
+			return
+		}
+		fmt.Fprintf(out, "%s\t%t\n", resp.ID, resp.Valid)
+	})
+	cancel()
+	if rerr := <-readErr; err == nil {
+		err = rerr
+	}
+	if ferr := out.Flush(); err == nil {
+		err = ferr
+	}
+	return failed, err
+}
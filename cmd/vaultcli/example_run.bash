@@ -7,7 +7,7 @@ export VAULTCLI_LOG_LEVEL="all"
 cd $(go list -f '{{.Dir}}' github.com/williamlsh/vault/cmd/vaultcli) && go run \
   -race . \
   -server-name="localhost" \
-  -tls-cert="../../testdata/server-cert.pem" \
+  -tls-ca="../../testdata/ca-cert.pem" \
   -grpc-addr="34.82.235.95:8080" \
   -method="hash" \
   -zipkin-url="http://34.82.235.95:9411/api/v2/spans" \
@@ -18,6 +18,8 @@ cd $(go list -f '{{.Dir}}' github.com/williamlsh/vault/cmd/vaultcli) && go run \
 cd $(go list -f '{{.Dir}}' github.com/williamlsh/vault/cmd/vaultcli) && go run \
   -race . \
   -http-addr="https://34.82.235.95:443" \
+  -server-name="localhost" \
+  -tls-ca="../../testdata/ca-cert.pem" \
   -method="hash" \
   -zipkin-url="" \
   -lightstep-token="" \
@@ -2,8 +2,13 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -14,7 +19,6 @@ import (
 	"github.com/openzipkin/zipkin-go"
 	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
 	"sourcegraph.com/sourcegraph/appdash"
 	appdashot "sourcegraph.com/sourcegraph/appdash/opentracing"
 
@@ -23,19 +27,99 @@ import (
 )
 
 const (
-	vaultcliLogLevel = "VAULTCLI_LOG_LEVEL"
-	grpcDialTimeout  = 1 * time.Second
-	rpcTimeout       = 3 * time.Second
+	vaultcliLogLevel  = "VAULTCLI_LOG_LEVEL"
+	vaultToken        = "VAULT_TOKEN"
+	vaultPassword     = "VAULT_PASSWORD"
+	vaultClientSecret = "VAULT_CLIENT_SECRET"
+	grpcDialTimeout   = 1 * time.Second
+	rpcTimeout        = 3 * time.Second
 )
 
 func main() {
 	var (
 		httpAddr = flag.String("http-addr", "", "HTTP listen address")
 		grpcAddr = flag.String("grpc-addr", "", "gRPC listen address")
-		method   = flag.String("method", "", "hash, validate")
-		// TLS certificate file and server name.
-		tlsCert            = flag.String("tls-cert", "", "TLS certificate file")
+		method   = flag.String("method", "", "hash, validate, init, unseal, seal, seal-status, rotate, kv-put, kv-get, kv-delete, kv-list, policy-read, policy-write, policy-list, subject-write, lease-lookup, lease-renew, lease-revoke, lease-revoke-prefix, token-create, token-lookup, token-renew, token-revoke, token-revoke-orphan, approle-write, approle-read, approle-secret-id, approle-login, userpass-write, userpass-delete, userpass-login, oauth-client-write, oauth-token, oauth-introspect, oauth-revoke, deny, allow, denylist, pki-generate, pki-import, pki-ca, pki-role-write, pki-issue, pki-sign, pki-revoke, pki-crl, ssh-ca-configure, ssh-ca, ssh-role-write, ssh-sign, wrap, unwrap, wrap-lookup, batch-hash, batch-validate")
+		tok      = flag.String("token", os.Getenv(vaultToken), "Token authenticating the requests, $"+vaultToken+" by default")
+		authMode = flag.String("auth", "token", "Authentication of the requests: token, with -token; oauth, with access tokens issued to -client-id; approle, with tokens of logins with -role-id and -secret-id; or jwt, with JWTs signed by -jwt-key")
+		// System backend arguments.
+		unsealKey = flag.String("key", "", "Unseal key share for the unseal method")
+		shares    = flag.Int("shares", 5, "Number of unseal key shares for the init method")
+		threshold = flag.Int("threshold", 3, "Number of unseal key shares required to unseal for the init method")
+		// Key-value secrets engine arguments.
+		kvPath    = flag.String("path", "", "Secret path for the kv methods")
+		kvData    = flag.String("data", "", "Secret data as comma separated key=value pairs for the kv-put method")
+		kvVersion = flag.Int("version", 0, "Secret version for the kv-get and kv-delete methods, zero meaning the current version")
+		kvCAS     = flag.Int("cas", -1, "Check-and-set version for the kv-put method, negative to disable")
+		// Policy arguments.
+		name          = flag.String("name", "", "Policy name, auth method and subject like jwt/ci for the subject-write method, display name for the token-create method, role name for the approle methods, username for the userpass methods, token ID or subject for the deny and allow methods, or role name for the pki and ssh methods")
+		policyFile    = flag.String("policy", "", "JSON policy rules file for the policy-write method")
+		policiesNames = flag.String("policies", "", "Comma separated policy names for the subject-write, token-create, approle-write, userpass-write and oauth-client-write methods")
+		// Leases.
+		leaseID        = flag.String("lease-id", "", "Lease ID for the lease methods, or prefix for the lease-revoke-prefix method")
+		leaseIncrement = flag.Duration("increment", 0, "Requested extension for the lease-renew and token-renew methods, zero meaning the default TTL")
+		// Tokens.
+		tokenID     = flag.String("token-id", "", "Token for the token methods, empty meaning the -token itself, or access token for the oauth-introspect and oauth-revoke methods")
+		tokenTTL    = flag.String("ttl", "", "TTL of the tokens created by the token-create method or issued to the approle-write role, userpass-write user or oauth-client-write client, of the deny entry, or of the CAs and certificates of the pki methods, e.g. 1h")
+		tokenOrphan = flag.Bool("orphan", false, "Create an orphan token with the token-create method")
+		// AppRole.
+		roleID       = flag.String("role-id", "", "Role ID for the approle-login method and -auth approle")
+		secretID     = flag.String("secret-id", "", "Secret ID for the approle-login method and -auth approle")
+		boundCIDRs   = flag.String("bound-cidrs", "", "Comma separated CIDRs allowed to log in with the approle-write role")
+		secretIDUses = flag.Int("secret-id-num-uses", 0, "Number of logins a secret ID of the approle-write role is good for, zero meaning unlimited")
+		secretIDTTL  = flag.String("secret-id-ttl", "", "TTL of the secret IDs of the approle-write role, e.g. 24h")
+		// Userpass.
+		password = flag.String("password", os.Getenv(vaultPassword), "Password for the userpass-write and userpass-login methods, $"+vaultPassword+" by default")
+		// OAuth2.
+		clientID     = flag.String("client-id", "", "Client ID for the oauth methods")
+		clientSecret = flag.String("client-secret", os.Getenv(vaultClientSecret), "Client secret for the oauth-token, oauth-introspect and oauth-revoke methods, $"+vaultClientSecret+" by default")
+		scope        = flag.String("scope", "", "Space separated scopes requested by the oauth-token method and -auth oauth, claimed with -auth jwt, or allowed to the oauth-client-write client")
+		// Self-signed JWTs.
+		jwtKey      = flag.String("jwt-key", "", "PEM private key signing the JWTs with -auth jwt")
+		jwtKeyID    = flag.String("jwt-key-id", "", "Key ID (kid header) of the JWTs signed with -auth jwt")
+		jwtIssuer   = flag.String("jwt-issuer", "", "Issuer (iss claim) of the JWTs signed with -auth jwt")
+		jwtSubject  = flag.String("jwt-subject", "", "Subject (sub claim) of the JWTs signed with -auth jwt")
+		jwtAudience = flag.String("jwt-audience", "vaultd", "Audience (aud claim) of the JWTs signed with -auth jwt")
+		jwtTTL      = flag.Duration("jwt-ttl", time.Minute, "Lifetime of the JWTs signed with -auth jwt")
+		// Denylist.
+		denyKind   = flag.String("kind", "jti", "Kind of the -name denied or allowed by the deny and allow methods, jti or subject")
+		denyReason = flag.String("reason", "", "Reason recorded by the deny method")
+		// PKI secrets engine.
+		pkiIntermediate = flag.Bool("intermediate", false, "Generate the key of an intermediate CA and print its CSR with the pki-generate method")
+		pkiCommonName   = flag.String("common-name", "", "Common name of the CA of the pki-generate method or of the certificate of the pki-issue method")
+		pkiAltNames     = flag.String("alt-names", "", "Comma separated DNS names of the certificate of the pki-issue method")
+		pkiIPSANs       = flag.String("ip-sans", "", "Comma separated IP addresses of the certificate of the pki-issue method")
+		pkiURISANs      = flag.String("uri-sans", "", "Comma separated URIs of the certificate of the pki-issue method, e.g. SPIFFE IDs")
+		pkiDomains      = flag.String("allowed-domains", "", "Comma separated domains of the pki-role-write role, or of the host principals of the ssh-role-write role")
+		pkiSubdomains   = flag.Bool("allow-subdomains", false, "Allow the subdomains of -allowed-domains to the pki-role-write or ssh-role-write role")
+		pkiAllowIPs     = flag.Bool("allow-ip-sans", false, "Allow IP SANs to the pki-role-write role")
+		pkiAllowedURIs  = flag.String("allowed-uri-sans", "", "Comma separated URI SANs allowed to the pki-role-write role, a trailing * matching any suffix")
+		pkiUsage        = flag.String("usage", "server", "Comma separated TLS usages, server and client, of the certificates of the pki-role-write role")
+		pkiMaxTTL       = flag.String("max-ttl", "", "Maximum TTL of the certificates of the pki-role-write or ssh-role-write role")
+		pkiKeyType      = flag.String("key-type", "", "Key type of the pki-generate CA or of the pki-role-write role: rsa, ec or ed25519, or any for CSRs; or of the ssh-ca-configure CA")
+		pkiPEMFile      = flag.String("pem-file", "", "PEM bundle of the CA for the pki-import method, CSR for the pki-sign method, or private key of the CA for the ssh-ca-configure method")
+		pkiSerial       = flag.String("serial", "", "Serial number of the certificate for the pki-revoke method")
+		// SSH secrets engine.
+		sshCertType      = flag.String("cert-type", "user", "Type of the certificates of the ssh-role-write role or ssh-sign method, user or host")
+		sshPrincipals    = flag.String("principals", "", "Comma separated principals of the certificate of the ssh-sign method")
+		sshUsers         = flag.String("allowed-users", "", "Comma separated users of the ssh-role-write role, * allowing any")
+		sshDefaultUser   = flag.String("default-user", "", "Principal of the ssh-role-write role certificates requesting none")
+		sshOptions       = flag.String("critical-options", "", "Comma separated key=value critical options allowed and set by default by the ssh-role-write role, or requested by the ssh-sign method")
+		sshExtensions    = flag.String("extensions", "", "Comma separated key=value extensions allowed and set by default by the ssh-role-write role, or requested by the ssh-sign method, e.g. permit-pty")
+		sshPublicKeyFile = flag.String("public-key-file", "", "Public key signed by the ssh-sign method, e.g. ~/.ssh/id_ed25519.pub")
+
+		wrapTTL       = flag.String("wrap-ttl", "", "Wrap the response of the method behind a single-use wrapping token valid for the TTL, e.g. 5m, or TTL of the wrapping token of the wrap method")
+		wrappingToken = flag.String("wrapping-token", "", "Wrapping token for the unwrap and wrap-lookup methods")
+
+		batchFile   = flag.String("batch-file", "-", "Input of the batch-hash method, one password per line, or of the batch-validate method, a password and a hash separated by a tab per line; - for the standard input")
+		batchWindow = flag.Int("batch-window", vaultransport.DefaultStreamWindow, "Number of items of the batch methods in flight at once")
+		// TLS verification of the server and client certificate.
+		tlsCA              = flag.String("tls-ca", "", "PEM bundle of the CA certificates verifying the server, the system roots by default")
 		serverNameOverride = flag.String("server-name", "", "Server name override")
+		tlsClientCert      = flag.String("tls-client-cert", "", "PEM client certificate authenticating with vaultd, with -tls-client-key")
+		tlsClientKey       = flag.String("tls-client-key", "", "PEM private key of -tls-client-cert")
+		tlsMinVersion      = flag.String("tls-min-version", "1.2", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3")
+		tlsInsecure        = flag.Bool("tls-insecure-skip-verify", false, "Do not verify the server certificate; insecure, for tests only")
 		// Zipkin tracer.
 		zipkinURL = flag.String("zipkin-url", "", "Enable Zipkin tracing (zipkin-go-opentracing) using a reporter URL e.g. http://localhost:9411/api/v2/spans")
 		// Lightstep tracer.
@@ -116,16 +200,42 @@ func main() {
 		}
 	}
 
+	minVersion, err := vaultransport.ParseTLSVersion(*tlsMinVersion)
+	if err != nil {
+		level.Error(logger).Log("during", "parse TLS version", "err", err)
+		os.Exit(1)
+	}
+	tlsOpts := vaultransport.ClientTLS{
+		CAFile:     *tlsCA,
+		ServerName: *serverNameOverride,
+		CertFile:   *tlsClientCert,
+		KeyFile:    *tlsClientKey,
+		MinVersion: minVersion,
+		Insecure:   *tlsInsecure,
+	}
+
 	var (
 		svc vaultservice.Service
-		err error
+		sys vaultservice.SysService
+		kv  vaultservice.KVService
+		pol vaultservice.PolicyService
+		ls  vaultservice.LeaseService
+		tk  vaultservice.TokenService
+		ar  vaultservice.AppRoleService
+		up  vaultservice.UserpassService
+		oa  vaultservice.OAuthService
+		dl  vaultservice.DenylistService
+		pk  vaultservice.PKIService
+		sh  vaultservice.SSHService
+		wr  vaultservice.WrappingService
+		bc  *vaultransport.BatchClient
 	)
+	var conn *grpc.ClientConn
 	if *httpAddr != "" {
-		svc, err = vaultransport.NewHTTPClient(*httpAddr, tracer, zipkinTracer, logger)
 		level.Info(logger).Log("transport", "http", "http-addr", *httpAddr)
 	} else if *grpcAddr != "" {
 		level.Info(logger).Log("transport", "grpc", "grpc-addr", *grpcAddr)
-		creds, err := credentials.NewClientTLSFromFile(*tlsCert, *serverNameOverride)
+		creds, err := tlsOpts.Credentials()
 		if err != nil {
 			level.Error(logger).Log("transport", "gRPC", "during", "construct TLS credentials", "err", err)
 			os.Exit(1)
@@ -133,27 +243,132 @@ func main() {
 		opts := []grpc.DialOption{
 			grpc.WithTransportCredentials(creds),
 			grpc.WithTimeout(grpcDialTimeout),
+			grpc.WithUnaryInterceptor(vaultransport.WrappingUnaryClientInterceptor),
 		}
-		conn, err := grpc.Dial(*grpcAddr, opts...)
+		conn, err = grpc.Dial(*grpcAddr, opts...)
 		if err != nil {
 			level.Error(logger).Log("transport", "gRPC", "during", "grpc dial", "err", err)
 			os.Exit(1)
 		}
 		defer conn.Close()
-		svc = vaultransport.NewGRPCClient(conn, tracer, zipkinTracer, logger)
 	} else {
 		level.Error(logger).Log("err", "no remote address specified")
 		os.Exit(1)
 	}
-	if err != nil {
+
+	// newClients constructs the clients of the services, authenticating their
+	// requests with the tokens of the source.
+	newClients := func(tokens vaultransport.TokenSource) (err error) {
+		if conn != nil {
+			svc = vaultransport.NewGRPCClient(conn, tokens, tracer, zipkinTracer, logger)
+			sys = vaultransport.NewGRPCSysClient(conn, tokens, tracer, zipkinTracer, logger)
+			kv = vaultransport.NewGRPCKVClient(conn, tokens, tracer, zipkinTracer, logger)
+			pol = vaultransport.NewGRPCPolicyClient(conn, tokens, tracer, zipkinTracer, logger)
+			ls = vaultransport.NewGRPCLeaseClient(conn, tokens, tracer, zipkinTracer, logger)
+			tk = vaultransport.NewGRPCTokenClient(conn, tokens, tracer, zipkinTracer, logger)
+			ar = vaultransport.NewGRPCAppRoleClient(conn, tokens, tracer, zipkinTracer, logger)
+			up = vaultransport.NewGRPCUserpassClient(conn, tokens, tracer, zipkinTracer, logger)
+			oa = vaultransport.NewGRPCOAuthClient(conn, tokens, tracer, zipkinTracer, logger)
+			dl = vaultransport.NewGRPCDenylistClient(conn, tokens, tracer, zipkinTracer, logger)
+			pk = vaultransport.NewGRPCPKIClient(conn, tokens, tracer, zipkinTracer, logger)
+			sh = vaultransport.NewGRPCSSHClient(conn, tokens, tracer, zipkinTracer, logger)
+			wr = vaultransport.NewGRPCWrappingClient(conn, tokens, tracer, zipkinTracer, logger)
+			bc = vaultransport.NewGRPCBatchClient(conn, tokens, *batchWindow)
+			return nil
+		}
+		svc, err = vaultransport.NewHTTPClient(*httpAddr, tlsOpts, tokens, tracer, zipkinTracer, logger)
+		if err == nil {
+			sys, err = vaultransport.NewHTTPSysClient(*httpAddr, tlsOpts, tokens, tracer, zipkinTracer, logger)
+		}
+		if err == nil {
+			kv, err = vaultransport.NewHTTPKVClient(*httpAddr, tlsOpts, tokens, tracer, zipkinTracer, logger)
+		}
+		if err == nil {
+			pol, err = vaultransport.NewHTTPPolicyClient(*httpAddr, tlsOpts, tokens, tracer, zipkinTracer, logger)
+		}
+		if err == nil {
+			ls, err = vaultransport.NewHTTPLeaseClient(*httpAddr, tlsOpts, tokens, tracer, zipkinTracer, logger)
+		}
+		if err == nil {
+			tk, err = vaultransport.NewHTTPTokenClient(*httpAddr, tlsOpts, tokens, tracer, zipkinTracer, logger)
+		}
+		if err == nil {
+			ar, err = vaultransport.NewHTTPAppRoleClient(*httpAddr, tlsOpts, tokens, tracer, zipkinTracer, logger)
+		}
+		if err == nil {
+			up, err = vaultransport.NewHTTPUserpassClient(*httpAddr, tlsOpts, tokens, tracer, zipkinTracer, logger)
+		}
+		if err == nil {
+			oa, err = vaultransport.NewHTTPOAuthClient(*httpAddr, tlsOpts, tokens, tracer, zipkinTracer, logger)
+		}
+		if err == nil {
+			dl, err = vaultransport.NewHTTPDenylistClient(*httpAddr, tlsOpts, tokens, tracer, zipkinTracer, logger)
+		}
+		if err == nil {
+			pk, err = vaultransport.NewHTTPPKIClient(*httpAddr, tlsOpts, tokens, tracer, zipkinTracer, logger)
+		}
+		if err == nil {
+			sh, err = vaultransport.NewHTTPSSHClient(*httpAddr, tlsOpts, tokens, tracer, zipkinTracer, logger)
+		}
+		if err == nil {
+			wr, err = vaultransport.NewHTTPWrappingClient(*httpAddr, tlsOpts, tokens, tracer, zipkinTracer, logger)
+		}
+		return err
+	}
+
+	// The OAuth and AppRole sources obtain their tokens through clients
+	// sending none.
+	var tokens vaultransport.TokenSource
+	switch *authMode {
+	case "token":
+		tokens = vaultransport.StaticTokenSource(*tok)
+	case "oauth", "approle":
+		if err := newClients(nil); err != nil {
+			level.Error(logger).Log("err", err)
+			// Note: don't use os.Exit(1) here and below, or deferred func
+			// will not execute.
+			return
+		}
+		if *authMode == "oauth" {
+			tokens = vaultransport.NewOAuthTokenSource(oa, vaultservice.ClientCredentials{ClientID: *clientID, ClientSecret: *clientSecret}, *scope)
+		} else {
+			tokens = vaultransport.NewAppRoleTokenSource(ar, *roleID, *secretID)
+		}
+	case "jwt":
+		key, err := vaultransport.LoadSigningKey(*jwtKey)
+		if err != nil {
+			level.Error(logger).Log("auth", "jwt", "during", "load key", "err", err)
+			return
+		}
+		tokens, err = vaultransport.NewSelfSignedTokenSource(vaultransport.SelfSignedConfig{
+			Key:      key,
+			KeyID:    *jwtKeyID,
+			Issuer:   *jwtIssuer,
+			Subject:  *jwtSubject,
+			Audience: *jwtAudience,
+			Scope:    *scope,
+			TTL:      *jwtTTL,
+		})
+		if err != nil {
+			level.Error(logger).Log("auth", "jwt", "err", err)
+			return
+		}
+	default:
+		level.Error(logger).Log("err", "invalid auth", "auth", *authMode)
+		return
+	}
+	if err := newClients(tokens); err != nil {
 		level.Error(logger).Log("err", err)
-		// Note: don't use os.Exit(1) here and below, or deferred func will not
-		// execute.
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
 	defer cancel()
+	// The wrap method wraps its own data with -wrap-ttl, the response of any
+	// other method is wrapped.
+	if *wrapTTL != "" && *method != "wrap" {
+		ctx = vaultransport.WithWrapTTL(ctx, *wrapTTL)
+	}
 	switch *method {
 	case "hash":
 		h, err := svc.Hash(ctx, "znm9832nmrfz4egwy43rn8")
@@ -169,7 +384,556 @@ func main() {
 			return
 		}
 		level.Info(logger).Log("method", "Validate", "result", v)
+	case "init":
+		keys, root, err := sys.Init(ctx, *shares, *threshold)
+		if err != nil {
+			level.Error(logger).Log("method", "Init", "err", err)
+			return
+		}
+		for i, k := range keys {
+			fmt.Printf("Unseal Key %d: %s\n", i+1, k)
+		}
+		fmt.Printf("Initial Root Token: %s\n", root)
+	case "unseal":
+		status, err := sys.Unseal(ctx, *unsealKey, false)
+		if err != nil {
+			level.Error(logger).Log("method", "Unseal", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "Unseal", "sealed", status.Sealed, "progress", fmt.Sprintf("%d/%d", status.Progress, status.Threshold))
+	case "seal":
+		if err := sys.Seal(ctx); err != nil {
+			level.Error(logger).Log("method", "Seal", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "Seal", "result", "sealed")
+	case "seal-status":
+		status, err := sys.SealStatus(ctx)
+		if err != nil {
+			level.Error(logger).Log("method", "SealStatus", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "SealStatus", "initialized", status.Initialized, "sealed", status.Sealed, "t", status.Threshold, "n", status.Shares, "progress", status.Progress, "auto_unseal", status.AutoUnseal)
+	case "rotate":
+		status, err := sys.Rotate(ctx)
+		if err != nil {
+			level.Error(logger).Log("method", "Rotate", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "Rotate", "term", status.Term, "install_time", status.InstallTime)
+	case "kv-put":
+		var cas *int
+		if *kvCAS >= 0 {
+			cas = kvCAS
+		}
+		md, err := kv.Put(ctx, *kvPath, parseKeyValues(*kvData), cas)
+		if err != nil {
+			level.Error(logger).Log("method", "KVPut", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "KVPut", "version", md.Version)
+	case "kv-get":
+		secret, err := kv.Get(ctx, *kvPath, *kvVersion)
+		if err != nil {
+			level.Error(logger).Log("method", "KVGet", "err", err)
+			return
+		}
+		for k, v := range secret.Data {
+			fmt.Printf("%s=%s\n", k, v)
+		}
+	case "kv-delete":
+		var versions []int
+		if *kvVersion > 0 {
+			versions = []int{*kvVersion}
+		}
+		if err := kv.Delete(ctx, *kvPath, versions); err != nil {
+			level.Error(logger).Log("method", "KVDelete", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "KVDelete", "result", "deleted")
+	case "kv-list":
+		keys, err := kv.List(ctx, *kvPath)
+		if err != nil {
+			level.Error(logger).Log("method", "KVList", "err", err)
+			return
+		}
+		for _, k := range keys {
+			fmt.Println(k)
+		}
+	case "policy-read":
+		rules, err := pol.ReadPolicy(ctx, *name)
+		if err != nil {
+			level.Error(logger).Log("method", "ReadPolicy", "err", err)
+			return
+		}
+		fmt.Println(rules)
+	case "policy-write":
+		rules, err := ioutil.ReadFile(*policyFile)
+		if err != nil {
+			level.Error(logger).Log("method", "WritePolicy", "err", err)
+			return
+		}
+		if err := pol.WritePolicy(ctx, *name, string(rules)); err != nil {
+			level.Error(logger).Log("method", "WritePolicy", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "WritePolicy", "name", *name)
+	case "policy-list":
+		names, err := pol.ListPolicies(ctx)
+		if err != nil {
+			level.Error(logger).Log("method", "ListPolicies", "err", err)
+			return
+		}
+		for _, n := range names {
+			fmt.Println(n)
+		}
+	case "subject-write":
+		if err := pol.WriteSubject(ctx, *name, splitNames(*policiesNames)); err != nil {
+			level.Error(logger).Log("method", "WriteSubject", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "WriteSubject", "subject", *name, "policies", *policiesNames)
+	case "lease-lookup":
+		l, err := ls.Lookup(ctx, *leaseID)
+		if err != nil {
+			level.Error(logger).Log("method", "LeaseLookup", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "LeaseLookup", "lease_id", l.ID, "expire_time", l.ExpireTime, "ttl", l.TTL())
+	case "lease-renew":
+		l, err := ls.Renew(ctx, *leaseID, *leaseIncrement)
+		if err != nil {
+			level.Error(logger).Log("method", "LeaseRenew", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "LeaseRenew", "lease_id", l.ID, "expire_time", l.ExpireTime, "ttl", l.TTL())
+	case "lease-revoke":
+		if err := ls.Revoke(ctx, *leaseID); err != nil {
+			level.Error(logger).Log("method", "LeaseRevoke", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "LeaseRevoke", "lease_id", *leaseID)
+	case "lease-revoke-prefix":
+		if err := ls.RevokePrefix(ctx, *leaseID); err != nil {
+			level.Error(logger).Log("method", "LeaseRevokePrefix", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "LeaseRevokePrefix", "prefix", *leaseID)
+	case "token-create":
+		t, err := tk.Create(ctx, vaultservice.TokenOptions{
+			Policies:    splitNames(*policiesNames),
+			TTL:         *tokenTTL,
+			DisplayName: *name,
+			NoParent:    *tokenOrphan,
+		})
+		if err != nil {
+			level.Error(logger).Log("method", "TokenCreate", "err", err)
+			return
+		}
+		fmt.Println(t.ID)
+	case "token-lookup":
+		t, err := tk.Lookup(ctx, *tokenID)
+		if err != nil {
+			level.Error(logger).Log("method", "TokenLookup", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "TokenLookup", "display_name", t.DisplayName, "policies", strings.Join(t.Policies, ","), "expire_time", t.ExpireTime)
+	case "token-renew":
+		t, err := tk.Renew(ctx, *tokenID, *leaseIncrement)
+		if err != nil {
+			level.Error(logger).Log("method", "TokenRenew", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "TokenRenew", "display_name", t.DisplayName, "expire_time", t.ExpireTime)
+	case "token-revoke":
+		if err := tk.Revoke(ctx, *tokenID); err != nil {
+			level.Error(logger).Log("method", "TokenRevoke", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "TokenRevoke", "result", "revoked")
+	case "token-revoke-orphan":
+		if err := tk.RevokeOrphan(ctx, *tokenID); err != nil {
+			level.Error(logger).Log("method", "TokenRevokeOrphan", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "TokenRevokeOrphan", "result", "revoked")
+	case "approle-write":
+		err := ar.WriteRole(ctx, *name, vaultservice.AppRole{
+			BoundCIDRs:      splitNames(*boundCIDRs),
+			TokenPolicies:   splitNames(*policiesNames),
+			TokenTTL:        *tokenTTL,
+			SecretIDNumUses: *secretIDUses,
+			SecretIDTTL:     *secretIDTTL,
+		})
+		if err != nil {
+			level.Error(logger).Log("method", "WriteRole", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "WriteRole", "role", *name)
+	case "approle-read":
+		role, err := ar.ReadRole(ctx, *name)
+		if err != nil {
+			level.Error(logger).Log("method", "ReadRole", "err", err)
+			return
+		}
+		fmt.Println(role.RoleID)
+	case "approle-secret-id":
+		secret, err := ar.GenerateSecretID(ctx, *name)
+		if err != nil {
+			level.Error(logger).Log("method", "GenerateSecretID", "err", err)
+			return
+		}
+		fmt.Println(secret.SecretID)
+	case "approle-login":
+		t, err := ar.Login(ctx, *roleID, *secretID)
+		if err != nil {
+			level.Error(logger).Log("method", "AppRoleLogin", "err", err)
+			return
+		}
+		fmt.Println(t.ID)
+	case "userpass-write":
+		err := up.WriteUser(ctx, *name, vaultservice.User{
+			Password:      *password,
+			TokenPolicies: splitNames(*policiesNames),
+			TokenTTL:      *tokenTTL,
+		})
+		if err != nil {
+			level.Error(logger).Log("method", "WriteUser", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "WriteUser", "username", *name)
+	case "userpass-delete":
+		if err := up.DeleteUser(ctx, *name); err != nil {
+			level.Error(logger).Log("method", "DeleteUser", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "DeleteUser", "username", *name)
+	case "userpass-login":
+		t, err := up.Login(ctx, *name, *password)
+		if err != nil {
+			level.Error(logger).Log("method", "UserpassLogin", "err", err)
+			return
+		}
+		fmt.Println(t.ID)
+	case "oauth-client-write":
+		secret, err := oa.WriteClient(ctx, *clientID, vaultservice.OAuthClient{
+			Scopes:        strings.Fields(*scope),
+			TokenPolicies: splitNames(*policiesNames),
+			TokenTTL:      *tokenTTL,
+		})
+		if err != nil {
+			level.Error(logger).Log("method", "WriteClient", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "WriteClient", "client_id", *clientID)
+		// The secret is only returned when the client is registered.
+		if secret != "" {
+			fmt.Println(secret)
+		}
+	case "oauth-token":
+		t, err := oa.Token(ctx, vaultservice.AccessTokenRequest{
+			ClientCredentials: vaultservice.ClientCredentials{ClientID: *clientID, ClientSecret: *clientSecret},
+			GrantType:         vaultservice.GrantClientCredentials,
+			Scope:             *scope,
+		})
+		if err != nil {
+			level.Error(logger).Log("method", "Token", "err", err)
+			return
+		}
+		fmt.Println(t.AccessToken)
+	case "oauth-introspect":
+		i, err := oa.Introspect(ctx, vaultservice.ClientCredentials{ClientID: *clientID, ClientSecret: *clientSecret}, *tokenID)
+		if err != nil {
+			level.Error(logger).Log("method", "Introspect", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "Introspect", "active", i.Active, "client_id", i.ClientID, "scope", i.Scope, "exp", i.ExpiresAt)
+	case "oauth-revoke":
+		if err := oa.Revoke(ctx, vaultservice.ClientCredentials{ClientID: *clientID, ClientSecret: *clientSecret}, *tokenID); err != nil {
+			level.Error(logger).Log("method", "Revoke", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "Revoke", "result", "revoked")
+	case "deny":
+		e, err := dl.Deny(ctx, vaultservice.DenyOptions{Kind: *denyKind, Value: *name, Reason: *denyReason, TTL: *tokenTTL})
+		if err != nil {
+			level.Error(logger).Log("method", "Deny", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "Deny", "kind", e.Kind, "value", e.Value, "expire_time", e.ExpireTime)
+	case "allow":
+		if err := dl.Allow(ctx, *denyKind, *name); err != nil {
+			level.Error(logger).Log("method", "Allow", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "Allow", "kind", *denyKind, "value", *name)
+	case "denylist":
+		entries, err := dl.ListDenied(ctx)
+		if err != nil {
+			level.Error(logger).Log("method", "ListDenied", "err", err)
+			return
+		}
+		for _, e := range entries {
+			fmt.Printf("%s\t%s\t%s\n", e.Kind, e.Value, e.Reason)
+		}
+	case "pki-generate":
+		ca, err := pk.GenerateCA(ctx, vaultservice.PKIGenerateOptions{
+			Intermediate: *pkiIntermediate,
+			CommonName:   *pkiCommonName,
+			TTL:          *tokenTTL,
+			KeyType:      *pkiKeyType,
+		})
+		if err != nil {
+			level.Error(logger).Log("method", "PKIGenerateCA", "err", err)
+			return
+		}
+		if ca.CSR != "" {
+			fmt.Print(ca.CSR)
+			return
+		}
+		fmt.Print(ca.Certificate)
+	case "pki-import":
+		bundle, err := ioutil.ReadFile(*pkiPEMFile)
+		if err != nil {
+			level.Error(logger).Log("method", "PKIImportCA", "err", err)
+			return
+		}
+		ca, err := pk.ImportCA(ctx, string(bundle))
+		if err != nil {
+			level.Error(logger).Log("method", "PKIImportCA", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "PKIImportCA", "serial_number", ca.SerialNumber, "expiration", ca.Expiration)
+	case "pki-ca":
+		ca, err := pk.ReadCA(ctx)
+		if err != nil {
+			level.Error(logger).Log("method", "PKIReadCA", "err", err)
+			return
+		}
+		fmt.Print(ca.Certificate + strings.Join(ca.CAChain, ""))
+	case "pki-role-write":
+		usages := splitNames(*pkiUsage)
+		err := pk.WriteRole(ctx, *name, vaultservice.PKIRole{
+			AllowedDomains:  splitNames(*pkiDomains),
+			AllowSubdomains: *pkiSubdomains,
+			AllowIPSANs:     *pkiAllowIPs,
+			AllowedURISANs:  splitNames(*pkiAllowedURIs),
+			TTL:             *tokenTTL,
+			MaxTTL:          *pkiMaxTTL,
+			KeyType:         *pkiKeyType,
+			ServerFlag:      contains(usages, "server"),
+			ClientFlag:      contains(usages, "client"),
+		})
+		if err != nil {
+			level.Error(logger).Log("method", "PKIWriteRole", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "PKIWriteRole", "role", *name)
+	case "pki-issue":
+		cert, err := pk.Issue(ctx, *name, vaultservice.PKIIssueRequest{
+			CommonName: *pkiCommonName,
+			AltNames:   splitNames(*pkiAltNames),
+			IPSANs:     splitNames(*pkiIPSANs),
+			URISANs:    splitNames(*pkiURISANs),
+			TTL:        *tokenTTL,
+		})
+		if err != nil {
+			level.Error(logger).Log("method", "PKIIssue", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "PKIIssue", "serial_number", cert.SerialNumber, "expiration", cert.Expiration)
+		fmt.Print(cert.Certificate + cert.PrivateKey)
+	case "pki-sign":
+		csr, err := ioutil.ReadFile(*pkiPEMFile)
+		if err != nil {
+			level.Error(logger).Log("method", "PKISign", "err", err)
+			return
+		}
+		cert, err := pk.Sign(ctx, *name, vaultservice.PKISignRequest{CSR: string(csr), TTL: *tokenTTL})
+		if err != nil {
+			level.Error(logger).Log("method", "PKISign", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "PKISign", "serial_number", cert.SerialNumber, "expiration", cert.Expiration)
+		fmt.Print(cert.Certificate)
+	case "pki-revoke":
+		cert, err := pk.Revoke(ctx, *pkiSerial)
+		if err != nil {
+			level.Error(logger).Log("method", "PKIRevoke", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "PKIRevoke", "serial_number", cert.SerialNumber, "revocation_time", cert.RevocationTime)
+	case "pki-crl":
+		crl, err := pk.ReadCRL(ctx)
+		if err != nil {
+			level.Error(logger).Log("method", "PKIReadCRL", "err", err)
+			return
+		}
+		fmt.Print(crl)
+	case "ssh-ca-configure":
+		var key []byte
+		if *pkiPEMFile != "" {
+			if key, err = ioutil.ReadFile(*pkiPEMFile); err != nil {
+				level.Error(logger).Log("method", "SSHConfigureCA", "err", err)
+				return
+			}
+		}
+		ca, err := sh.ConfigureCA(ctx, vaultservice.SSHCAOptions{PrivateKey: string(key), KeyType: *pkiKeyType})
+		if err != nil {
+			level.Error(logger).Log("method", "SSHConfigureCA", "err", err)
+			return
+		}
+		fmt.Print(ca.PublicKey)
+	case "ssh-ca":
+		ca, err := sh.ReadCA(ctx)
+		if err != nil {
+			level.Error(logger).Log("method", "SSHReadCA", "err", err)
+			return
+		}
+		fmt.Print(ca.PublicKey)
+	case "ssh-role-write":
+		options, extensions := parseKeyValues(*sshOptions), parseKeyValues(*sshExtensions)
+		err := sh.WriteRole(ctx, *name, vaultservice.SSHRole{
+			AllowUserCertificates:  *sshCertType == vaultservice.SSHCertTypeUser,
+			AllowHostCertificates:  *sshCertType == vaultservice.SSHCertTypeHost,
+			AllowedUsers:           splitNames(*sshUsers),
+			DefaultUser:            *sshDefaultUser,
+			AllowedDomains:         splitNames(*pkiDomains),
+			AllowSubdomains:        *pkiSubdomains,
+			TTL:                    *tokenTTL,
+			MaxTTL:                 *pkiMaxTTL,
+			AllowedCriticalOptions: keys(options),
+			DefaultCriticalOptions: options,
+			AllowedExtensions:      keys(extensions),
+			DefaultExtensions:      extensions,
+		})
+		if err != nil {
+			level.Error(logger).Log("method", "SSHWriteRole", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "SSHWriteRole", "role", *name)
+	case "ssh-sign":
+		key, err := ioutil.ReadFile(*sshPublicKeyFile)
+		if err != nil {
+			level.Error(logger).Log("method", "SSHSign", "err", err)
+			return
+		}
+		cert, err := sh.Sign(ctx, *name, vaultservice.SSHSignRequest{
+			PublicKey:       string(key),
+			CertType:        *sshCertType,
+			ValidPrincipals: splitNames(*sshPrincipals),
+			TTL:             *tokenTTL,
+			CriticalOptions: parseKeyValues(*sshOptions),
+			Extensions:      parseKeyValues(*sshExtensions),
+		})
+		if err != nil {
+			level.Error(logger).Log("method", "SSHSign", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "SSHSign", "serial_number", cert.SerialNumber, "expiration", cert.Expiration)
+		fmt.Print(cert.SignedKey)
+	case "wrap":
+		data, err := json.Marshal(parseKeyValues(*kvData))
+		if err != nil {
+			level.Error(logger).Log("method", "Wrap", "err", err)
+			return
+		}
+		info, err := wr.Wrap(ctx, "", data, *wrapTTL)
+		if err != nil {
+			level.Error(logger).Log("method", "Wrap", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "Wrap", "ttl", info.TTL, "expiration", info.ExpirationTime)
+		fmt.Println(info.Token)
+	case "unwrap":
+		data, err := wr.Unwrap(ctx, *wrappingToken)
+		if err != nil {
+			level.Error(logger).Log("method", "Unwrap", "err", err)
+			return
+		}
+		fmt.Println(string(data))
+	case "wrap-lookup":
+		info, err := wr.Lookup(ctx, *wrappingToken)
+		if err != nil {
+			level.Error(logger).Log("method", "WrapLookup", "err", err)
+			return
+		}
+		level.Info(logger).Log("method", "WrapLookup", "ttl", info.TTL, "creation_path", info.CreationPath, "creation_time", info.CreationTime, "expiration", info.ExpirationTime)
+	case "batch-hash", "batch-validate":
+		if bc == nil {
+			level.Error(logger).Log("method", *method, "err", "batch methods require -grpc-addr")
+			return
+		}
+		in, err := openBatch(*batchFile)
+		if err != nil {
+			level.Error(logger).Log("method", *method, "err", err)
+			return
+		}
+		defer in.Close()
+		// Batches run until their input is read, without the RPC timeout.
+		batch := batchHash
+		if *method == "batch-validate" {
+			batch = batchValidate
+		}
+		failed, err := batch(context.Background(), bc, in, os.Stdout, logger)
+		if err != nil {
+			level.Error(logger).Log("method", *method, "err", err)
+			return
+		}
+		level.Info(logger).Log("method", *method, "failed", failed)
 	default:
 		level.Error(logger).Log("err", "invalid method")
+		return
+	}
+	if info, ok := vaultransport.WrapInfoFromContext(ctx); ok {
+		level.Info(logger).Log("method", *method, "wrapped", info.CreationPath, "ttl", info.TTL, "expiration", info.ExpirationTime)
+		fmt.Println(info.Token)
+	}
+}
+
+// parseKeyValues parses comma separated key=value pairs.
+func parseKeyValues(s string) map[string]string {
+	m := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		if kv = strings.TrimSpace(kv); kv == "" {
+			continue
+		}
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) == 1 {
+			pair = append(pair, "")
+		}
+		m[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+	}
+	return m
+}
+
+// splitNames parses comma separated names.
+func splitNames(s string) []string {
+	var names []string
+	for _, n := range strings.Split(s, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// keys returns the sorted keys of m.
+func keys(m map[string]string) []string {
+	var names []string
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// contains reports whether name is one of names.
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
 	}
+	return false
 }
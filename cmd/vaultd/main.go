@@ -1,18 +1,23 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
-	"github.com/go-kit/log"
-	"github.com/go-kit/log/level"
 	"github.com/go-kit/kit/metrics"
 	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	lightstep "github.com/lightstep/lightstep-tracer-go"
 	opentracing "github.com/opentracing/opentracing-go"
 	zipkinot "github.com/openzipkin-contrib/zipkin-go-opentracing"
@@ -22,10 +27,24 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 	"sourcegraph.com/sourcegraph/appdash"
 	appdashot "sourcegraph.com/sourcegraph/appdash/opentracing"
 
+	"github.com/williamlsh/vault/internal/certmanager"
+	"github.com/williamlsh/vault/internal/denylist"
+	"github.com/williamlsh/vault/internal/gateway"
+	"github.com/williamlsh/vault/internal/health"
+	"github.com/williamlsh/vault/internal/jwks"
+	"github.com/williamlsh/vault/internal/lease"
+	"github.com/williamlsh/vault/internal/oauth"
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/replay"
+	"github.com/williamlsh/vault/internal/seal"
 	"github.com/williamlsh/vault/internal/store"
+	"github.com/williamlsh/vault/internal/token"
 	"github.com/williamlsh/vault/internal/vaultendpoint"
 	"github.com/williamlsh/vault/internal/vaultransport"
 	"github.com/williamlsh/vault/internal/vaultservice"
@@ -35,13 +54,23 @@ import (
 const vaultdLogLevel = "VAULTD_LOG_LEVEL"
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "pki" {
+		os.Exit(runPKI(os.Args[2:], os.Stderr))
+	}
+
 	var (
 		httpAddr = flag.String("http-addr", ":443", "HTTP listen address")
 		grpcAddr = flag.String("grpc-addr", ":8080", "gRPC listen address")
-		promAddr = flag.String("prom-addr", ":8081", "Prometheus server listen address")
+
+		grpcReflection = flag.Bool("grpc-reflection", false, "Enable gRPC server reflection, e.g. for grpcurl")
+		healthInterval = flag.Duration("health-check-interval", 5*time.Second, "Interval between checks of the store, the seal and the circuit breakers reported by the gRPC Health service")
+		promAddr       = flag.String("prom-addr", ":8081", "Prometheus server listen address")
 		// TLS files.
-		tlsCert = flag.String("tls-cert", "", "TLS certificate file")
-		tlsKey  = flag.String("tls-key", "", "TLS key file")
+		tlsCert       = flag.String("tls-cert", "", "TLS certificate file")
+		tlsKey        = flag.String("tls-key", "", "TLS key file")
+		tlsClientCA   = flag.String("tls-client-ca", "", "Enable TLS client authentication with the CA certificates of this PEM bundle")
+		tlsClientAuth = flag.String("tls-client-auth", "verify-if-given", "TLS client certificate policy with -tls-client-ca, verify-if-given or require")
+		tlsReload     = flag.Duration("tls-reload-interval", 30*time.Second, "Interval between checks of the TLS certificate and key files for changes, which are reloaded as on SIGHUP")
 		// Postgres connection credentials.
 		pgUser    = flag.String("pg-user", "", "postgreSQL database username")
 		pgPass    = flag.String("pg-password", "", "postgreSQL database password for provided user")
@@ -49,6 +78,27 @@ func main() {
 		pgHost    = flag.String("pg-host", "localhost", "postgreSQL database host")
 		pgSslmode = flag.String("pg-sslmode", "disable", "postgreSQL database connection sslmode option")
 		pgPort    = flag.String("pg-port", "5432", "postgreSQL connection binding port")
+		// Auto-unseal key provider.
+		sealType   = flag.String("seal-type", "", "Enable auto-unseal with a registered key provider, e.g. file")
+		sealConfig = flag.String("seal-config", "", "Key provider configuration as comma separated key=value pairs, e.g. path=/etc/vaultd/unseal.key")
+		// Key-value secrets engine.
+		kvMaxVersions = flag.Int("kv-max-versions", vaultservice.DefaultKVMaxVersions, "Default number of versions kept per key-value secret")
+		kvDeleteAfter = flag.Duration("kv-delete-version-after", 0, "Default lease of new key-value secret versions, zero to keep them until deleted")
+		// Leases.
+		leaseDefaultTTL = flag.Duration("lease-default-ttl", 768*time.Hour, "Default TTL of issued leases")
+		leaseMaxTTL     = flag.Duration("lease-max-ttl", 768*time.Hour, "Maximum TTL of issued leases, including renewals")
+		leaseInterval   = flag.Duration("lease-expiration-interval", 5*time.Second, "Interval between scans for expired leases")
+		// OAuth2 authorization server.
+		oauthIssuer = flag.String("oauth-issuer", "vaultd", "Issuer (iss claim) of the OAuth2 access tokens")
+		// External JWT issuer.
+		jwtJWKS       = flag.String("jwt-jwks", "", "Enable JWTs of an external issuer, verified with the JSON Web Key Set at this file path or URL")
+		jwtRefresh    = flag.Duration("jwt-jwks-refresh", 15*time.Minute, "Interval between reloads of the JSON Web Key Set")
+		jwtIssuer     = flag.String("jwt-issuer", "", "Required iss claim of the external JWTs")
+		jwtAudience   = flag.String("jwt-audience", "vaultd", "Required aud claim of the external JWTs")
+		jwtClockSkew  = flag.Duration("jwt-clock-skew", 30*time.Second, "Clock skew tolerated when checking the exp, nbf and iat claims of the external JWTs")
+		jwtAlgorithms = flag.String("jwt-algorithms", strings.Join(jwks.DefaultAlgorithms, ","), "Comma separated signature algorithms accepted for the external JWTs")
+		jwtReplay     = flag.Bool("jwt-replay-protection", false, "Accept each external JWT once, refusing the JWTs without a jti claim")
+		jwtPurge      = flag.Duration("jwt-replay-purge-interval", time.Minute, "Interval between purges of the expired JWT IDs remembered against replays")
 		// Zipkin tracer.
 		zipkinURL = flag.String("zipkin-url", "", "Enable Zipkin tracing (zipkin-go-opentracing) using a reporter URL e.g. http://localhost:9411/api/v1/spans")
 		// Lightstep tracer.
@@ -153,23 +203,220 @@ func main() {
 		}, []string{})
 	}
 
-	// Datastore domain
-	datastore := store.New(log.With(logger, "domain", "store"), dsn)
+	var leaseMetrics lease.Metrics
+	{
+		// Lease metrics.
+		leaseMetrics = lease.Metrics{
+			Issued: prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: "vault",
+				Subsystem: "lease",
+				Name:      "issued_total",
+				Help:      "Total count of issued leases.",
+			}, []string{}),
+			Renewed: prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: "vault",
+				Subsystem: "lease",
+				Name:      "renewed_total",
+				Help:      "Total count of lease renewals.",
+			}, []string{}),
+			Revoked: prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: "vault",
+				Subsystem: "lease",
+				Name:      "revoked_total",
+				Help:      "Total count of revoked leases by reason.",
+			}, []string{"reason"}),
+			Active: prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+				Namespace: "vault",
+				Subsystem: "lease",
+				Name:      "active",
+				Help:      "Number of unexpired leases.",
+			}, []string{}),
+		}
+	}
+
+	// Database connection shared by the seal and the datastore.
+	db := store.Connect(log.With(logger, "domain", "store"), dsn)
+	if err := store.Migrate(log.With(logger, "domain", "store"), db); err != nil {
+		os.Exit(1)
+	}
+
+	// Seal domain. The daemon starts sealed and refuses requests until it is
+	// unsealed through the system endpoints, or by the key provider.
+	var sl *seal.Seal
+	{
+		sealStorage := store.NewSealStorage(log.With(logger, "domain", "store"), db)
+		if *sealType == "" {
+			sl = seal.New(sealStorage)
+		} else {
+			provider, err := seal.NewKeyProvider(*sealType, parseKeyValues(*sealConfig))
+			if err != nil {
+				level.Error(logger).Log("seal", *sealType, "during", "construct key provider", "err", err)
+				os.Exit(1)
+			}
+			sl = seal.NewAutoSeal(sealStorage, provider)
+			if err := sl.AutoUnseal(context.Background()); err != nil {
+				level.Warn(logger).Log("seal", *sealType, "during", "auto-unseal", "err", err)
+			} else {
+				level.Info(logger).Log("seal", *sealType, "auto-unseal", "success")
+			}
+		}
+	}
+
+	// Datastore domain, encrypting everything at rest with the seal keyring.
+	datastore := store.New(log.With(logger, "domain", "store"), db, sl)
+	storage := store.NewStorage(log.With(logger, "domain", "store"), db, sl)
+
+	// Policies granted to tokens, enforced by the endpoints.
+	policies := policy.NewStore(storage)
+
+	// Expiration manager revoking the secrets and tokens issued by vaultd.
+	leases := lease.NewManager(log.With(logger, "domain", "lease"), store.NewLeaseStorage(log.With(logger, "domain", "store"), db), leaseMetrics, *leaseDefaultTTL, *leaseMaxTTL)
+
+	// Token store authenticating the requests.
+	tokens := token.NewStore(store.NewTokenStorage(log.With(logger, "domain", "store"), db), leases)
+	issuer := oauth.NewIssuer(storage, leases, *oauthIssuer)
+
+	// Verifier of the JWTs of an external issuer, granted the policies
+	// attached to their subject.
+	var verifier *jwks.Verifier
+	if *jwtJWKS != "" {
+		if *jwtIssuer == "" || *jwtAudience == "" {
+			level.Error(logger).Log("during", "jwks", "err", "-jwt-issuer and -jwt-audience are required with -jwt-jwks")
+			os.Exit(1)
+		}
+		keys := jwks.NewKeySet(*jwtJWKS, nil)
+		if err := keys.Refresh(context.Background()); err != nil {
+			level.Error(logger).Log("during", "jwks", "source", *jwtJWKS, "err", err)
+		}
+		go keys.Run(context.Background(), *jwtRefresh, level.Error(log.With(logger, "domain", "jwks")))
+		verifier = jwks.NewVerifier(keys, jwks.Config{
+			Issuer:     *jwtIssuer,
+			Audience:   *jwtAudience,
+			ClockSkew:  *jwtClockSkew,
+			Algorithms: strings.Split(*jwtAlgorithms, ","),
+		})
+	}
+
+	// Denylist of the JWT IDs and subjects revoked before the tokens expire,
+	// and the IDs of the external JWTs already accepted by any replica.
+	denied := denylist.New(storage, leases)
+	var replays *replay.Cache
+	if *jwtReplay {
+		replays = replay.NewCache(store.NewReplayStorage(log.With(logger, "domain", "store"), db))
+		go replays.Run(context.Background(), *jwtPurge, level.Error(log.With(logger, "domain", "replay")))
+	}
+	auth := vaultendpoint.NewAuthorizer(tokens, policies, issuer, verifier, denied, replays)
+	// Circuit breakers of the endpoints, by the gRPC service serving them.
+	breakers := vaultendpoint.NewBreakerRegistry()
 
 	// Service domain.
 	var (
-		service     = vaultservice.New(log.With(logger, "domain", "vaultservice"), ints, datastore)
-		endpoints   = vaultendpoint.New(service, duration, tracer, zipkinTracer, log.With(logger, "domain", "vaultendpoint"))
-		httpHandler = vaultransport.NewHTTPHandler(endpoints, tracer, zipkinTracer, log.With(logger, "domain", "vaultransport-http"))
-		grpcServer  = vaultransport.NewGRPCServer(endpoints, tracer, zipkinTracer, log.With(logger, "domain", "vaultransport-grpc"))
+		service       = vaultservice.New(log.With(logger, "domain", "vaultservice"), ints, datastore, sl)
+		sysService    = vaultservice.NewSysService(log.With(logger, "domain", "vaultservice-sys"), ints, sl, tokens)
+		kvService     = vaultservice.NewKVService(log.With(logger, "domain", "vaultservice-kv"), ints, storage, leases, *kvMaxVersions, *kvDeleteAfter)
+		policyService = vaultservice.NewPolicyService(log.With(logger, "domain", "vaultservice-policy"), ints, policies)
+		leaseService  = vaultservice.NewLeaseService(log.With(logger, "domain", "vaultservice-lease"), ints, leases)
+		tokenService  = vaultservice.NewTokenService(log.With(logger, "domain", "vaultservice-token"), ints, tokens)
+		appRoleSvc    = vaultservice.NewAppRoleService(log.With(logger, "domain", "vaultservice-approle"), ints, storage, tokens, leases)
+		userpassSvc   = vaultservice.NewUserpassService(log.With(logger, "domain", "vaultservice-userpass"), ints, storage, tokens)
+		oauthService  = vaultservice.NewOAuthService(log.With(logger, "domain", "vaultservice-oauth"), ints, storage, issuer)
+		denylistSvc   = vaultservice.NewDenylistService(log.With(logger, "domain", "vaultservice-denylist"), ints, denied)
+		pkiService    = vaultservice.NewPKIService(log.With(logger, "domain", "vaultservice-pki"), ints, storage)
+		sshService    = vaultservice.NewSSHService(log.With(logger, "domain", "vaultservice-ssh"), ints, storage)
+		wrappingSvc   = vaultservice.NewWrappingService(log.With(logger, "domain", "vaultservice-wrapping"), ints, storage, leases)
+		endpoints     = vaultendpoint.New(service, auth, breakers, duration, tracer, zipkinTracer, log.With(logger, "domain", "vaultendpoint"))
+		batchEps      = vaultendpoint.NewBatchSet(service, auth, breakers, duration)
+		sysEndpoints  = vaultendpoint.NewSysSet(sysService, auth, duration, tracer, zipkinTracer, log.With(logger, "domain", "vaultendpoint-sys"))
+		kvEndpoints   = vaultendpoint.NewKVSet(kvService, auth, breakers, duration, tracer, zipkinTracer, log.With(logger, "domain", "vaultendpoint-kv"))
+		policyEps     = vaultendpoint.NewPolicySet(policyService, auth, duration, tracer, zipkinTracer, log.With(logger, "domain", "vaultendpoint-policy"))
+		leaseEps      = vaultendpoint.NewLeaseSet(leaseService, auth, duration, tracer, zipkinTracer, log.With(logger, "domain", "vaultendpoint-lease"))
+		tokenEps      = vaultendpoint.NewTokenSet(tokenService, auth, duration, tracer, zipkinTracer, log.With(logger, "domain", "vaultendpoint-token"))
+		appRoleEps    = vaultendpoint.NewAppRoleSet(appRoleSvc, auth, duration, tracer, zipkinTracer, log.With(logger, "domain", "vaultendpoint-approle"))
+		userpassEps   = vaultendpoint.NewUserpassSet(userpassSvc, auth, duration, tracer, zipkinTracer, log.With(logger, "domain", "vaultendpoint-userpass"))
+		oauthEps      = vaultendpoint.NewOAuthSet(oauthService, auth, duration, tracer, zipkinTracer, log.With(logger, "domain", "vaultendpoint-oauth"))
+		denylistEps   = vaultendpoint.NewDenylistSet(denylistSvc, auth, duration, tracer, zipkinTracer, log.With(logger, "domain", "vaultendpoint-denylist"))
+		pkiEps        = vaultendpoint.NewPKISet(pkiService, auth, duration, tracer, zipkinTracer, log.With(logger, "domain", "vaultendpoint-pki"))
+		sshEps        = vaultendpoint.NewSSHSet(sshService, auth, duration, tracer, zipkinTracer, log.With(logger, "domain", "vaultendpoint-ssh"))
+		wrappingEps   = vaultendpoint.NewWrappingSet(wrappingSvc, auth, duration, tracer, zipkinTracer, log.With(logger, "domain", "vaultendpoint-wrapping"))
+		grpcServer    = vaultransport.NewGRPCServer(endpoints, batchEps, tracer, zipkinTracer, log.With(logger, "domain", "vaultransport-grpc"))
+		grpcSysServer = vaultransport.NewGRPCSysServer(sysEndpoints, tracer, zipkinTracer, log.With(logger, "domain", "vaultransport-grpc"))
+		grpcKVServer  = vaultransport.NewGRPCKVServer(kvEndpoints, tracer, zipkinTracer, log.With(logger, "domain", "vaultransport-grpc"))
+		grpcPolicySrv = vaultransport.NewGRPCPolicyServer(policyEps, tracer, zipkinTracer, log.With(logger, "domain", "vaultransport-grpc"))
+		grpcLeaseSrv  = vaultransport.NewGRPCLeaseServer(leaseEps, tracer, zipkinTracer, log.With(logger, "domain", "vaultransport-grpc"))
+		grpcTokenSrv  = vaultransport.NewGRPCTokenServer(tokenEps, tracer, zipkinTracer, log.With(logger, "domain", "vaultransport-grpc"))
+		grpcAppRole   = vaultransport.NewGRPCAppRoleServer(appRoleEps, tracer, zipkinTracer, log.With(logger, "domain", "vaultransport-grpc"))
+		grpcUserpass  = vaultransport.NewGRPCUserpassServer(userpassEps, tracer, zipkinTracer, log.With(logger, "domain", "vaultransport-grpc"))
+		grpcOAuth     = vaultransport.NewGRPCOAuthServer(oauthEps, tracer, zipkinTracer, log.With(logger, "domain", "vaultransport-grpc"))
+		grpcDenylist  = vaultransport.NewGRPCDenylistServer(denylistEps, tracer, zipkinTracer, log.With(logger, "domain", "vaultransport-grpc"))
+		grpcPKI       = vaultransport.NewGRPCPKIServer(pkiEps, tracer, zipkinTracer, log.With(logger, "domain", "vaultransport-grpc"))
+		grpcSSH       = vaultransport.NewGRPCSSHServer(sshEps, tracer, zipkinTracer, log.With(logger, "domain", "vaultransport-grpc"))
+		grpcWrapping  = vaultransport.NewGRPCWrappingServer(wrappingEps, tracer, zipkinTracer, log.With(logger, "domain", "vaultransport-grpc"))
 	)
 
-	errs := make(chan error, 2)
+	// The gRPC services, registered with the gRPC server and with the REST
+	// gateway of the HTTP server, which serves their annotated methods.
+	registerServices := func(s grpc.ServiceRegistrar) {
+		vaultpb.RegisterVaultServer(s, grpcServer)
+		vaultpb.RegisterSysServer(s, grpcSysServer)
+		vaultpb.RegisterKVServer(s, grpcKVServer)
+		vaultpb.RegisterPolicyServer(s, grpcPolicySrv)
+		vaultpb.RegisterLeaseServer(s, grpcLeaseSrv)
+		vaultpb.RegisterTokenServer(s, grpcTokenSrv)
+		vaultpb.RegisterAppRoleServer(s, grpcAppRole)
+		vaultpb.RegisterUserpassServer(s, grpcUserpass)
+		vaultpb.RegisterOAuthServer(s, grpcOAuth)
+		vaultpb.RegisterDenylistServer(s, grpcDenylist)
+		vaultpb.RegisterPKIServer(s, grpcPKI)
+		vaultpb.RegisterSSHServer(s, grpcSSH)
+		vaultpb.RegisterWrappingServer(s, grpcWrapping)
+	}
+	gw := gateway.New()
+	registerServices(gw)
+	httpHandler := vaultransport.NewHTTPHandler(sysEndpoints, oauthEps, sshEps, wrappingEps, gw, tracer, zipkinTracer, log.With(logger, "domain", "vaultransport-http"))
+
+	// TLS certificate shared by the listeners, reloaded on SIGHUP and when
+	// its files change.
+	certs, err := certmanager.New(*tlsCert, *tlsKey, prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "vault",
+		Subsystem: "tls",
+		Name:      "certificate_expiry_timestamp_seconds",
+		Help:      "Expiry time of the served TLS certificate, in seconds since the epoch.",
+	}, []string{}))
+	if err != nil {
+		level.Error(logger).Log("during", "load TLS certificate", "err", err)
+		os.Exit(1)
+	}
+	{
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go certs.Run(context.Background(), *tlsReload, hup, log.With(logger, "domain", "certmanager"))
+	}
+
+	// TLS configuration of the HTTP and gRPC listeners.
+	tlsConfig, err := serverTLSConfig(certs, *tlsClientCA, *tlsClientAuth)
+	if err != nil {
+		level.Error(logger).Log("during", "load TLS configuration", "err", err)
+		os.Exit(1)
+	}
+
+	// The expiration manager, the metrics server, the interruption handler
+	// and the HTTP and gRPC servers each send their error.
+	errs := make(chan error, 5)
+
+	// Expiration manager, idle while the vault is sealed.
+	go func() {
+		errs <- leases.Run(context.Background(), *leaseInterval, sl.Sealed)
+	}()
+
+	// Encryption of the secrets written by previous versions, once the vault
+	// is unsealed. It retries until it succeeds, so it sends no error.
+	go store.MigrateSecrets(context.Background(), log.With(logger, "domain", "store"), db, sl, sl.Sealed)
 
 	// Metrics server.
 	go func() {
 		http.Handle("/metrics", promhttp.Handler())
-		errs <- http.ListenAndServeTLS(*promAddr, *tlsCert, *tlsKey, promhttp.Handler())
+		srv := &http.Server{Addr: *promAddr, Handler: promhttp.Handler(), TLSConfig: certs.TLSConfig()}
+		errs <- srv.ListenAndServeTLS("", "")
 	}()
 
 	// Interruption handler.
@@ -182,7 +429,8 @@ func main() {
 	// HTTP server with TLS.
 	go func() {
 		level.Info(logger).Log("transport", "HTTP", "addr", *httpAddr)
-		errs <- http.ListenAndServeTLS(*httpAddr, *tlsCert, *tlsKey, httpHandler)
+		srv := &http.Server{Addr: *httpAddr, Handler: httpHandler, TLSConfig: tlsConfig}
+		errs <- srv.ListenAndServeTLS("", "")
 	}()
 
 	// gRPC server.
@@ -194,18 +442,80 @@ func main() {
 			return
 		}
 		level.Info(logger).Log("transport", "gRPC", "addr", *grpcAddr)
-		// Create tls based credential.
-		creds, err := credentials.NewServerTLSFromFile(*tlsCert, *tlsKey)
-		if err != nil {
-			level.Error(logger).Log("transport", "gRPC", "during", "construct TLS credentials", "err", err)
-			errs <- err
-			return
+		s := grpc.NewServer(
+			grpc.Creds(credentials.NewTLS(tlsConfig)),
+			grpc.UnaryInterceptor(vaultransport.NewGRPCWrappingInterceptor(wrappingEps)),
+		)
+		registerServices(s)
+
+		// Health of the server and of each service, for load balancers and
+		// Kubernetes probes.
+		hs := grpchealth.NewServer()
+		checker := health.NewChecker(hs)
+		checker.AddCheck("store", db.PingContext)
+		checker.AddCheck("seal", func(context.Context) error {
+			if sl.Sealed() {
+				return seal.ErrSealed
+			}
+			return nil
+		})
+		// Every service registered so far is reported by name, not serving
+		// while the store or the seal fails, or while any of its circuit
+		// breakers is open.
+		for name := range s.GetServiceInfo() {
+			checker.AddService(name, breakers.Check(name))
+		}
+		healthpb.RegisterHealthServer(s, hs)
+		go checker.Run(context.Background(), *healthInterval, level.Warn(log.With(logger, "domain", "health")))
+		if *grpcReflection {
+			reflection.Register(s)
 		}
-		s := grpc.NewServer(grpc.Creds(creds))
-		vaultpb.RegisterVaultServer(s, grpcServer)
 		errs <- s.Serve(lis)
 	}()
 
 	// Waiting for error to be received.
 	level.Error(logger).Log("exit", <-errs)
 }
+
+// serverTLSConfig returns the TLS configuration of the listeners serving the
+// certificate of the manager. Client certificates are verified against the
+// CA bundle, if any, either when given or on every connection.
+func serverTLSConfig(certs *certmanager.Manager, clientCA, clientAuth string) (*tls.Config, error) {
+	config := certs.TLSConfig()
+	if clientCA == "" {
+		return config, nil
+	}
+	raw, err := os.ReadFile(clientCA)
+	if err != nil {
+		return nil, err
+	}
+	config.ClientCAs = x509.NewCertPool()
+	if !config.ClientCAs.AppendCertsFromPEM(raw) {
+		return nil, fmt.Errorf("no CA certificate in %s", clientCA)
+	}
+	switch clientAuth {
+	case "verify-if-given":
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unknown TLS client auth %q", clientAuth)
+	}
+	return config, nil
+}
+
+// parseKeyValues parses comma separated key=value pairs.
+func parseKeyValues(s string) map[string]string {
+	m := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		if kv = strings.TrimSpace(kv); kv == "" {
+			continue
+		}
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) == 1 {
+			pair = append(pair, "")
+		}
+		m[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+	}
+	return m
+}
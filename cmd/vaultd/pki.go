@@ -0,0 +1,197 @@
+package main
+
+import (
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/williamlsh/vault/internal/pki"
+)
+
+const pkiUsage = `Usage: vaultd pki <command> [flags]
+
+Generates a development PKI as PEM files: <name>-cert.pem and <name>-key.pem.
+
+Commands:
+  ca      generate a self-signed certificate authority
+  server  generate a server certificate issued by the CA
+  client  generate a client certificate issued by the CA, for mutual TLS
+`
+
+// runPKI runs the pki subcommand with its arguments, writing its messages to
+// w, and returns the exit code.
+func runPKI(args []string, w io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(w, pkiUsage)
+		return 2
+	}
+	cmd := args[0]
+	var defaultCN, defaultDNS, defaultIP string
+	defaultTTL := 90 * 24 * time.Hour
+	switch cmd {
+	case "ca":
+		defaultCN, defaultTTL = "vaultd development CA", 10*365*24*time.Hour
+	case "server":
+		defaultDNS, defaultIP = "localhost", "127.0.0.1,::1"
+	case "client":
+	default:
+		fmt.Fprint(w, pkiUsage)
+		return 2
+	}
+	fs := flag.NewFlagSet("pki "+cmd, flag.ContinueOnError)
+	fs.SetOutput(w)
+	var (
+		outDir  = fs.String("out-dir", ".", "Directory the PEM files are written to")
+		name    = fs.String("name", cmd, "Name of the PEM files, <name>-cert.pem and <name>-key.pem")
+		cn      = fs.String("cn", defaultCN, "Common name of the certificate, the first SAN if empty")
+		ttl     = fs.Duration("ttl", defaultTTL, "Lifetime of the certificate, capped by the lifetime of the CA")
+		keyType = fs.String("key-type", pki.KeyTypeEC, "Key type: rsa, ec or ed25519")
+		keyBits = fs.Int("key-bits", 0, "RSA key size, or EC curve size, the default of the key type if zero")
+		caName  = fs.String("ca", "ca", "Name of the CA PEM files issuing the certificate, in -out-dir")
+		dns     = fs.String("dns", defaultDNS, "Comma separated DNS SANs")
+		ips     = fs.String("ip", defaultIP, "Comma separated IP address SANs")
+		emails  = fs.String("email", "", "Comma separated email address SANs")
+		uris    = fs.String("uri", "", "Comma separated URI SANs, e.g. a SPIFFE ID spiffe://example.org/billing")
+	)
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	t := pki.Template{
+		CommonName:     *cn,
+		DNSNames:       splitList(*dns),
+		EmailAddresses: splitList(*emails),
+		TTL:            *ttl,
+		Server:         cmd == "server",
+		Client:         cmd == "client",
+	}
+	for _, s := range splitList(*ips) {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			fmt.Fprintf(w, "invalid IP address %q\n", s)
+			return 2
+		}
+		t.IPAddresses = append(t.IPAddresses, ip)
+	}
+	for _, s := range splitList(*uris) {
+		u, err := url.Parse(s)
+		if err != nil {
+			fmt.Fprintf(w, "invalid URI %q: %v\n", s, err)
+			return 2
+		}
+		t.URIs = append(t.URIs, u)
+	}
+	if t.CommonName == "" {
+		t.CommonName = firstSAN(t)
+	}
+	if t.CommonName == "" {
+		fmt.Fprintln(w, "-cn or a SAN is required")
+		return 2
+	}
+
+	if err := generate(t, *outDir, *name, *caName, *keyType, *keyBits); err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+	fmt.Fprintf(w, "wrote %s and %s\n", pemPath(*outDir, *name, "cert"), pemPath(*outDir, *name, "key"))
+	return 0
+}
+
+// generate writes a new key and the certificate of the template for it,
+// issued by the CA of caName unless the template is a CA.
+func generate(t pki.Template, dir, name, caName, keyType string, keyBits int) error {
+	key, err := pki.GenerateKey(keyType, keyBits)
+	if err != nil {
+		return err
+	}
+	var cert *x509.Certificate
+	if t.Server || t.Client {
+		ca, caKey, err := loadCA(dir, caName)
+		if err != nil {
+			return err
+		}
+		cert, err = pki.Issue(t, key.Public(), ca, caKey)
+		if err != nil {
+			return err
+		}
+	} else {
+		cert, err = pki.NewCA(t, key)
+		if err != nil {
+			return err
+		}
+	}
+	keyPEM, err := pki.EncodeKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(pemPath(dir, name, "key"), keyPEM, 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(pemPath(dir, name, "cert"), pki.EncodeCertificate(cert), 0644)
+}
+
+// loadCA reads the certificate and key of the CA of the name.
+func loadCA(dir, name string) (*x509.Certificate, crypto.Signer, error) {
+	raw, err := ioutil.ReadFile(pemPath(dir, name, "cert"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, errors.New("no CA, generate one with vaultd pki ca")
+		}
+		return nil, nil, err
+	}
+	ca, err := pki.ParseCertificate(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, err = ioutil.ReadFile(pemPath(dir, name, "key"))
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := pki.ParseKey(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ca, key, nil
+}
+
+func pemPath(dir, name, kind string) string {
+	return filepath.Join(dir, name+"-"+kind+".pem")
+}
+
+func firstSAN(t pki.Template) string {
+	switch {
+	case len(t.URIs) > 0:
+		return t.URIs[0].String()
+	case len(t.DNSNames) > 0:
+		return t.DNSNames[0]
+	case len(t.EmailAddresses) > 0:
+		return t.EmailAddresses[0]
+	case len(t.IPAddresses) > 0:
+		return t.IPAddresses[0].String()
+	}
+	return ""
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
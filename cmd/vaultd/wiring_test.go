@@ -1,100 +1,1934 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
-	"github.com/golang-jwt/jwt/v4"
-	"github.com/go-kit/log"
 	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/log"
+	stdjwt "github.com/golang-jwt/jwt/v4"
 	opentracing "github.com/opentracing/opentracing-go"
 	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/williamlsh/vault/internal/certmanager"
+	"github.com/williamlsh/vault/internal/denylist"
+	"github.com/williamlsh/vault/internal/gateway"
+	"github.com/williamlsh/vault/internal/identity"
+	"github.com/williamlsh/vault/internal/jwks"
+	"github.com/williamlsh/vault/internal/lease"
 	"github.com/williamlsh/vault/internal/mock"
+	"github.com/williamlsh/vault/internal/oauth"
+	"github.com/williamlsh/vault/internal/pki"
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/replay"
+	"github.com/williamlsh/vault/internal/seal"
+	"github.com/williamlsh/vault/internal/token"
 	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaulterr"
 	"github.com/williamlsh/vault/internal/vaultransport"
 	"github.com/williamlsh/vault/internal/vaultservice"
+	vaultpb "github.com/williamlsh/vault/pb"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 type testcase struct {
 	method, url, body, want string
 }
 
-func TestHTTP(t *testing.T) {
+// testServer is a vaultd serving HTTP over mock storage. It trusts the JWTs
+// of an external issuer signed with idpKey.
+type testServer struct {
+	*httptest.Server
+	// rootToken is the token returned when the vault is initialized.
+	rootToken string
+	idpKey    *ecdsa.PrivateKey
+	// jwtIDs numbers the IDs of the external JWTs, which are only accepted
+	// once.
+	jwtIDs int
+
+	zkt      *zipkin.Tracer
+	policies *policy.Store
+	denylist *denylist.List
+	oauth    vaultservice.OAuthService
+	eps      vaultendpoint.Set
+	batchEps vaultendpoint.BatchSet
+	kvEps    vaultendpoint.KVSet
+	polEps   vaultendpoint.PolicySet
+}
+
+// startTestServer starts a sealed vaultd which is not initialized yet. It is
+// closed when the test ends.
+func startTestServer(t *testing.T) *testServer {
+	t.Helper()
 	zkt, _ := zipkin.NewTracer(nil, zipkin.WithNoopTracer(true))
-	svc := vaultservice.New(log.NewNopLogger(), discard.NewCounter(), mock.NewNopStore())
-	eps := vaultendpoint.New(svc, discard.NewHistogram(), opentracing.GlobalTracer(), zkt, log.NewNopLogger())
-	mux := vaultransport.NewHTTPHandler(eps, opentracing.GlobalTracer(), zkt, log.NewNopLogger())
-	srv := httptest.NewServer(mux)
-	defer srv.Close()
+	datastore := mock.NewNopStore()
+	sl := seal.New(mock.NewSealStorage())
+	storage := mock.NewStorage()
+	policies := policy.NewStore(storage)
+	leaseMetrics := lease.Metrics{Issued: discard.NewCounter(), Renewed: discard.NewCounter(), Revoked: discard.NewCounter(), Active: discard.NewGauge()}
+	leases := lease.NewManager(log.NewNopLogger(), mock.NewLeaseStorage(), leaseMetrics, time.Hour, 2*time.Hour)
+	tokens := token.NewStore(mock.NewTokenStorage(), leases)
+	issuer := oauth.NewIssuer(storage, leases, "vaultd")
+	idpKey, jwksFile := newJWKS(t)
+	verifier := jwks.NewVerifier(jwks.NewKeySet(jwksFile, nil), jwks.Config{Issuer: "https://idp.example.com", Audience: "vaultd", ClockSkew: time.Minute})
+	denied := denylist.New(storage, leases)
+	auth := vaultendpoint.NewAuthorizer(tokens, policies, issuer, verifier, denied, replay.NewCache(mock.NewReplayStorage()))
+	breakers := vaultendpoint.NewBreakerRegistry()
+	svc := vaultservice.New(log.NewNopLogger(), discard.NewCounter(), datastore, sl)
+	sys := vaultservice.NewSysService(log.NewNopLogger(), discard.NewCounter(), sl, tokens)
+	eps := vaultendpoint.New(svc, auth, breakers, discard.NewHistogram(), opentracing.GlobalTracer(), zkt, log.NewNopLogger())
+	batchEps := vaultendpoint.NewBatchSet(svc, auth, breakers, discard.NewHistogram())
+	sysEps := vaultendpoint.NewSysSet(sys, auth, discard.NewHistogram(), opentracing.GlobalTracer(), zkt, log.NewNopLogger())
+	kv := vaultservice.NewKVService(log.NewNopLogger(), discard.NewCounter(), storage, leases, 2, 0)
+	kvEps := vaultendpoint.NewKVSet(kv, auth, breakers, discard.NewHistogram(), opentracing.GlobalTracer(), zkt, log.NewNopLogger())
+	pol := vaultservice.NewPolicyService(log.NewNopLogger(), discard.NewCounter(), policies)
+	polEps := vaultendpoint.NewPolicySet(pol, auth, discard.NewHistogram(), opentracing.GlobalTracer(), zkt, log.NewNopLogger())
+	ls := vaultservice.NewLeaseService(log.NewNopLogger(), discard.NewCounter(), leases)
+	lsEps := vaultendpoint.NewLeaseSet(ls, auth, discard.NewHistogram(), opentracing.GlobalTracer(), zkt, log.NewNopLogger())
+	tk := vaultservice.NewTokenService(log.NewNopLogger(), discard.NewCounter(), tokens)
+	tkEps := vaultendpoint.NewTokenSet(tk, auth, discard.NewHistogram(), opentracing.GlobalTracer(), zkt, log.NewNopLogger())
+	ar := vaultservice.NewAppRoleService(log.NewNopLogger(), discard.NewCounter(), storage, tokens, leases)
+	arEps := vaultendpoint.NewAppRoleSet(ar, auth, discard.NewHistogram(), opentracing.GlobalTracer(), zkt, log.NewNopLogger())
+	up := vaultservice.NewUserpassService(log.NewNopLogger(), discard.NewCounter(), storage, tokens)
+	upEps := vaultendpoint.NewUserpassSet(up, auth, discard.NewHistogram(), opentracing.GlobalTracer(), zkt, log.NewNopLogger())
+	oa := vaultservice.NewOAuthService(log.NewNopLogger(), discard.NewCounter(), storage, issuer)
+	oaEps := vaultendpoint.NewOAuthSet(oa, auth, discard.NewHistogram(), opentracing.GlobalTracer(), zkt, log.NewNopLogger())
+	dl := vaultservice.NewDenylistService(log.NewNopLogger(), discard.NewCounter(), denied)
+	dlEps := vaultendpoint.NewDenylistSet(dl, auth, discard.NewHistogram(), opentracing.GlobalTracer(), zkt, log.NewNopLogger())
+	pk := vaultservice.NewPKIService(log.NewNopLogger(), discard.NewCounter(), storage)
+	pkEps := vaultendpoint.NewPKISet(pk, auth, discard.NewHistogram(), opentracing.GlobalTracer(), zkt, log.NewNopLogger())
+	sh := vaultservice.NewSSHService(log.NewNopLogger(), discard.NewCounter(), storage)
+	shEps := vaultendpoint.NewSSHSet(sh, auth, discard.NewHistogram(), opentracing.GlobalTracer(), zkt, log.NewNopLogger())
+	wr := vaultservice.NewWrappingService(log.NewNopLogger(), discard.NewCounter(), storage, leases)
+	wrEps := vaultendpoint.NewWrappingSet(wr, auth, discard.NewHistogram(), opentracing.GlobalTracer(), zkt, log.NewNopLogger())
+	gw := gateway.New()
+	vaultpb.RegisterVaultServer(gw, vaultransport.NewGRPCServer(eps, batchEps, opentracing.GlobalTracer(), zkt, log.NewNopLogger()))
+	vaultpb.RegisterSysServer(gw, vaultransport.NewGRPCSysServer(sysEps, opentracing.GlobalTracer(), zkt, log.NewNopLogger()))
+	vaultpb.RegisterKVServer(gw, vaultransport.NewGRPCKVServer(kvEps, opentracing.GlobalTracer(), zkt, log.NewNopLogger()))
+	vaultpb.RegisterPolicyServer(gw, vaultransport.NewGRPCPolicyServer(polEps, opentracing.GlobalTracer(), zkt, log.NewNopLogger()))
+	vaultpb.RegisterLeaseServer(gw, vaultransport.NewGRPCLeaseServer(lsEps, opentracing.GlobalTracer(), zkt, log.NewNopLogger()))
+	vaultpb.RegisterTokenServer(gw, vaultransport.NewGRPCTokenServer(tkEps, opentracing.GlobalTracer(), zkt, log.NewNopLogger()))
+	vaultpb.RegisterAppRoleServer(gw, vaultransport.NewGRPCAppRoleServer(arEps, opentracing.GlobalTracer(), zkt, log.NewNopLogger()))
+	vaultpb.RegisterUserpassServer(gw, vaultransport.NewGRPCUserpassServer(upEps, opentracing.GlobalTracer(), zkt, log.NewNopLogger()))
+	vaultpb.RegisterOAuthServer(gw, vaultransport.NewGRPCOAuthServer(oaEps, opentracing.GlobalTracer(), zkt, log.NewNopLogger()))
+	vaultpb.RegisterDenylistServer(gw, vaultransport.NewGRPCDenylistServer(dlEps, opentracing.GlobalTracer(), zkt, log.NewNopLogger()))
+	vaultpb.RegisterPKIServer(gw, vaultransport.NewGRPCPKIServer(pkEps, opentracing.GlobalTracer(), zkt, log.NewNopLogger()))
+	vaultpb.RegisterSSHServer(gw, vaultransport.NewGRPCSSHServer(shEps, opentracing.GlobalTracer(), zkt, log.NewNopLogger()))
+	vaultpb.RegisterWrappingServer(gw, vaultransport.NewGRPCWrappingServer(wrEps, opentracing.GlobalTracer(), zkt, log.NewNopLogger()))
+	mux := vaultransport.NewHTTPHandler(sysEps, oaEps, shEps, wrEps, gw, opentracing.GlobalTracer(), zkt, log.NewNopLogger())
+	srv := &testServer{
+		Server:   httptest.NewServer(mux),
+		idpKey:   idpKey,
+		zkt:      zkt,
+		policies: policies,
+		denylist: denied,
+		oauth:    oa,
+		eps:      eps,
+		batchEps: batchEps,
+		kvEps:    kvEps,
+		polEps:   polEps,
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestServer starts a vaultd initialized with a single unseal key and
+// unsealed.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	srv := startTestServer(t)
+	var initResp struct {
+		Keys      []string `json:"keys"`
+		RootToken string   `json:"root_token"`
+	}
+	srv.post(t, "/sys/init", `{"secret_shares":1,"secret_threshold":1}`, &initResp)
+	var status struct {
+		Sealed bool `json:"sealed"`
+	}
+	srv.post(t, "/sys/unseal", fmt.Sprintf(`{"key":%q}`, initResp.Keys[0]), &status)
+	if status.Sealed {
+		t.Fatal("vault still sealed after the unseal key")
+	}
+	srv.rootToken = initResp.RootToken
+	return srv
+}
+
+func TestSeal(t *testing.T) {
+	srv := startTestServer(t)
+
+	if want, have := http.StatusUnauthorized, srv.send(t, http.MethodPost, "/hash", `{"password":"znm9832nmrfz4egwy43rn8"}`, nil); want != have {
+		t.Errorf("hash without token: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusNotImplemented, get(t, srv.URL+"/sys/health"); want != have {
+		t.Errorf("health before init: want %d, have %d", want, have)
+	}
+
+	var initResp struct {
+		Keys      []string `json:"keys"`
+		RootToken string   `json:"root_token"`
+	}
+	srv.post(t, "/sys/init", `{"secret_shares":5,"secret_threshold":3}`, &initResp)
+	if want, have := 5, len(initResp.Keys); want != have {
+		t.Fatalf("unseal keys: want %d, have %d", want, have)
+	}
+	if !strings.HasPrefix(initResp.RootToken, token.Prefix) {
+		t.Fatalf("root token: have %q", initResp.RootToken)
+	}
+	srv.rootToken = initResp.RootToken
+	if want, have := http.StatusServiceUnavailable, get(t, srv.URL+"/sys/health"); want != have {
+		t.Errorf("health while sealed: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusServiceUnavailable, srv.send(t, http.MethodPost, "/hash", `{"password":"znm9832nmrfz4egwy43rn8"}`, nil); want != have {
+		t.Errorf("hash while sealed: want %d, have %d", want, have)
+	}
 
-	t.Run("hash password", func(t *testing.T) {
-		caseHash := testcase{
-			method: http.MethodPost,
-			url:    srv.URL + "/hash",
-			body:   `{"password":"znm9832nmrfz4egwy43rn8"}`,
+	var status struct {
+		Sealed   bool `json:"sealed"`
+		Progress int  `json:"progress"`
+	}
+	for i, key := range initResp.Keys[:3] {
+		// The false and zero fields are left out of the responses.
+		status.Sealed, status.Progress = false, 0
+		srv.post(t, "/sys/unseal", fmt.Sprintf(`{"key":%q}`, key), &status)
+		if i < 2 && (!status.Sealed || status.Progress != i+1) {
+			t.Errorf("after %d keys: want sealed with progress %d, have sealed=%v progress=%d", i+1, i+1, status.Sealed, status.Progress)
 		}
-		req, err := http.NewRequest(caseHash.method, caseHash.url, strings.NewReader(caseHash.body))
-		if err != nil {
-			t.Fatal(err)
+	}
+	if status.Sealed {
+		t.Fatal("vault still sealed after threshold keys")
+	}
+	if want, have := http.StatusOK, get(t, srv.URL+"/sys/health"); want != have {
+		t.Errorf("health when unsealed: want %d, have %d", want, have)
+	}
+}
+
+func TestKVSecrets(t *testing.T) {
+	srv := newTestServer(t)
+	var put struct {
+		Metadata struct {
+			Version int `json:"version"`
+		} `json:"metadata"`
+	}
+	srv.post(t, "/kv/data/app/db", `{"data":{"user":"admin"}}`, &put)
+	srv.post(t, "/kv/data/app/db", `{"data":{"user":"root"},"has_cas":true,"cas":1}`, &put)
+	if want, have := 2, put.Metadata.Version; want != have {
+		t.Errorf("version after second put: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusBadRequest, srv.send(t, http.MethodPost, "/kv/data/app/db", `{"data":{"user":"x"},"has_cas":true,"cas":1}`, nil); want != have {
+		t.Errorf("stale check-and-set: want %d, have %d", want, have)
+	}
+
+	var secret struct {
+		Data map[string]string `json:"data"`
+	}
+	if want, have := http.StatusOK, srv.send(t, http.MethodGet, "/kv/data/app/db?version=1", "", &secret); want != have {
+		t.Fatalf("get version 1: want %d, have %d", want, have)
+	}
+	if want, have := "admin", secret.Data["user"]; want != have {
+		t.Errorf("version 1 data: want %q, have %q", want, have)
+	}
+	if want, have := http.StatusNotFound, srv.send(t, http.MethodGet, "/kv/data/app/missing", "", nil); want != have {
+		t.Errorf("missing secret: want %d, have %d", want, have)
+	}
+
+	var list struct {
+		Keys []string `json:"keys"`
+	}
+	srv.send(t, http.MethodGet, "/kv/list/app/", "", &list)
+	if want, have := "[db]", fmt.Sprint(list.Keys); want != have {
+		t.Errorf("list: want %s, have %s", want, have)
+	}
+}
+
+func TestLeases(t *testing.T) {
+	srv := newTestServer(t)
+	var out struct{}
+	srv.post(t, "/kv/metadata/app/tmp", `{"delete_version_after":"30m"}`, &out)
+	var put struct {
+		Metadata struct {
+			LeaseID string `json:"lease_id"`
+		} `json:"metadata"`
+	}
+	srv.post(t, "/kv/data/app/tmp", `{"data":{"k":"v"}}`, &put)
+	leaseID := put.Metadata.LeaseID
+	if !strings.HasPrefix(leaseID, "kv/data/app/tmp/1/") {
+		t.Fatalf("lease id: have %q", leaseID)
+	}
+
+	var l struct {
+		ExpireTime unixNano `json:"expire_time"`
+	}
+	srv.post(t, "/sys/leases/lookup", fmt.Sprintf(`{"lease_id":%q}`, leaseID), &l)
+	if ttl := l.ExpireTime.TTL(); ttl <= 0 || ttl > 1800 {
+		t.Errorf("lease ttl: want (0, 1800], have %d", ttl)
+	}
+	srv.post(t, "/sys/leases/renew", fmt.Sprintf(`{"lease_id":%q,"increment":10800}`, leaseID), &l)
+	if ttl := l.ExpireTime.TTL(); ttl <= 3600 || ttl > 7200 {
+		t.Errorf("renewed ttl capped by max ttl: want (3600, 7200], have %d", ttl)
+	}
+
+	var list struct {
+		Keys []string `json:"keys"`
+	}
+	srv.send(t, http.MethodGet, "/sys/leases/lookup/kv/data/app/", "", &list)
+	if want, have := fmt.Sprint([]string{leaseID}), fmt.Sprint(list.Keys); want != have {
+		t.Errorf("list leases: want %s, have %s", want, have)
+	}
+
+	srv.post(t, "/sys/leases/revoke-prefix/kv/data/app/tmp/", "", &out)
+	var secret struct {
+		Metadata struct {
+			DeletionTime unixNano `json:"deletion_time"`
+		} `json:"metadata"`
+	}
+	srv.send(t, http.MethodGet, "/kv/data/app/tmp", "", &secret)
+	if secret.Metadata.DeletionTime == "" {
+		t.Error("revoked version not deleted")
+	}
+	if want, have := http.StatusNotFound, srv.send(t, http.MethodPost, "/sys/leases/lookup", fmt.Sprintf(`{"lease_id":%q}`, leaseID), nil); want != have {
+		t.Errorf("lookup revoked lease: want %d, have %d", want, have)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	srv := newTestServer(t)
+	hash := func(tok string) int {
+		return sendAs(t, tok, http.MethodPost, srv.URL+"/hash", `{"password":"znm9832nmrfz4egwy43rn8"}`, nil)
+	}
+	var app struct {
+		ID string `json:"token"`
+	}
+	srv.post(t, "/auth/token/create", `{"policies":["app"]}`, &app)
+	if want, have := http.StatusForbidden, hash(app.ID); want != have {
+		t.Errorf("hash without policy: want %d, have %d", want, have)
+	}
+
+	var out struct{}
+	srv.put(t, "/sys/policy/hasher", `{"policy":"{\"path\":{\"hash\":{\"capabilities\":[\"hash\"]}}}"}`, &out)
+	var hasher struct {
+		ID string `json:"token"`
+	}
+	srv.post(t, "/auth/token/create", `{"policies":["hasher"]}`, &hasher)
+	// The hash endpoint is rate limited, only check it was authorized.
+	if have := hash(hasher.ID); have == http.StatusForbidden || have == http.StatusUnauthorized {
+		t.Errorf("hash with policy: have %d", have)
+	}
+	if want, have := http.StatusBadRequest, srv.send(t, http.MethodPut, "/sys/policy/root", `{"policy":"{}"}`, nil); want != have {
+		t.Errorf("write root policy: want %d, have %d", want, have)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	srv := newTestServer(t)
+	srv.putPolicy(t, "hasher", `{"path":{"hash":{"capabilities":["hash"]}}}`)
+
+	var out struct{}
+	srv.put(t, "/sys/policy/creator", `{"policy":"{\"path\":{\"auth/token/create\":{\"capabilities\":[\"update\"]}}}"}`, &out)
+	var parent struct {
+		ID         string   `json:"token"`
+		ExpireTime unixNano `json:"expire_time"`
+	}
+	srv.post(t, "/auth/token/create", `{"policies":["creator","hasher"],"ttl":"30m","display_name":"ci"}`, &parent)
+	if ttl := parent.ExpireTime.TTL(); ttl <= 0 || ttl > 1800 {
+		t.Errorf("token ttl: want (0, 1800], have %d", ttl)
+	}
+
+	var self struct {
+		DisplayName string   `json:"display_name"`
+		Policies    []string `json:"policies"`
+		Orphan      bool     `json:"orphan"`
+	}
+	if want, have := http.StatusOK, sendAs(t, parent.ID, http.MethodGet, srv.URL+"/auth/token/lookup-self", "", &self); want != have {
+		t.Fatalf("lookup-self: want %d, have %d", want, have)
+	}
+	if want, have := "ci [default creator hasher] false", fmt.Sprint(self.DisplayName, " ", self.Policies, " ", self.Orphan); want != have {
+		t.Errorf("lookup-self: want %s, have %s", want, have)
+	}
+
+	var child struct {
+		ID         string   `json:"token"`
+		ExpireTime unixNano `json:"expire_time"`
+	}
+	if want, have := http.StatusOK, sendAs(t, parent.ID, http.MethodPost, srv.URL+"/auth/token/create", `{"policies":["hasher"],"ttl":"2h"}`, &child); want != have {
+		t.Fatalf("create child: want %d, have %d", want, have)
+	}
+	if child.ExpireTime.TTL() > parent.ExpireTime.TTL() {
+		t.Errorf("child outlives parent: child ttl %d, parent ttl %d", child.ExpireTime.TTL(), parent.ExpireTime.TTL())
+	}
+	if want, have := http.StatusForbidden, sendAs(t, parent.ID, http.MethodPost, srv.URL+"/auth/token/create", `{"policies":["root"]}`, nil); want != have {
+		t.Errorf("grant policy not held: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusForbidden, sendAs(t, parent.ID, http.MethodPost, srv.URL+"/auth/token/create", `{"no_parent":true}`, nil); want != have {
+		t.Errorf("orphan without sudo: want %d, have %d", want, have)
+	}
+
+	// The self operations ignore the token named by the request, so that
+	// a token does not revoke another one, root included, through
+	// revoke-self.
+	for _, path := range []string{"/auth/token/revoke-self", "/v1/auth/token/revoke-self?token=" + url.QueryEscape(srv.rootToken)} {
+		var attacker struct {
+			ID string `json:"token"`
 		}
-		setHeader(req)
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			t.Fatal(err)
+		srv.post(t, "/auth/token/create", `{"policies":["hasher"]}`, &attacker)
+		var looked struct {
+			DisplayName string `json:"display_name"`
 		}
-		body, err := ioutil.ReadAll(resp.Body)
+		sendAs(t, attacker.ID, http.MethodGet, srv.URL+"/auth/token/lookup-self", fmt.Sprintf(`{"token":%q}`, srv.rootToken), &looked)
+		if want, have := "token", looked.DisplayName; want != have {
+			t.Errorf("lookup-self naming root: want %q, have %q", want, have)
+		}
+		if want, have := http.StatusOK, sendAs(t, attacker.ID, http.MethodPost, srv.URL+path, fmt.Sprintf(`{"token":%q}`, srv.rootToken), nil); want != have {
+			t.Errorf("%s naming root: want %d, have %d", path, want, have)
+		}
+		if want, have := http.StatusOK, srv.send(t, http.MethodGet, "/auth/token/lookup-self", "", nil); want != have {
+			t.Fatalf("%s by another token naming root: root lookup-self: want %d, have %d", path, want, have)
+		}
+		if want, have := http.StatusUnauthorized, sendAs(t, attacker.ID, http.MethodGet, srv.URL+"/auth/token/lookup-self", "", nil); want != have {
+			t.Errorf("%s: lookup-self of the caller: want %d, have %d", path, want, have)
+		}
+	}
+
+	// Token leases are renewed with their token, so that both expire
+	// together.
+	var tokenLeases struct {
+		Keys []string `json:"keys"`
+	}
+	srv.send(t, http.MethodGet, "/sys/leases/lookup/auth/token/create/", "", &tokenLeases)
+	if len(tokenLeases.Keys) == 0 {
+		t.Fatal("no token leases")
+	}
+	if want, have := http.StatusBadRequest, srv.send(t, http.MethodPost, "/sys/leases/renew", fmt.Sprintf(`{"lease_id":%q}`, tokenLeases.Keys[0]), nil); want != have {
+		t.Errorf("renew token lease: want %d, have %d", want, have)
+	}
+
+	srv.post(t, "/auth/token/revoke", fmt.Sprintf(`{"token":%q}`, parent.ID), &out)
+	if want, have := http.StatusUnauthorized, sendAs(t, child.ID, http.MethodGet, srv.URL+"/auth/token/lookup-self", "", nil); want != have {
+		t.Errorf("child of revoked token: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusNotFound, srv.send(t, http.MethodPost, "/auth/token/lookup", fmt.Sprintf(`{"token":%q}`, parent.ID), nil); want != have {
+		t.Errorf("lookup revoked token: want %d, have %d", want, have)
+	}
+}
+
+func TestAppRole(t *testing.T) {
+	srv := newTestServer(t)
+	srv.putPolicy(t, "hasher", `{"path":{"hash":{"capabilities":["hash"]}}}`)
+
+	var out struct{}
+	srv.post(t, "/auth/approle/role/batch", `{"bound_cidrs":["127.0.0.0/8","::1/128"],"token_policies":["hasher"],"token_ttl":"10m","secret_id_num_uses":2}`, &out)
+	srv.post(t, "/auth/approle/role/elsewhere", `{"bound_cidrs":["192.0.2.0/24"]}`, &out)
+	if want, have := http.StatusBadRequest, srv.send(t, http.MethodPost, "/auth/approle/role/bad", `{"bound_cidrs":["10.0.0.1"]}`, nil); want != have {
+		t.Errorf("write role with invalid cidr: want %d, have %d", want, have)
+	}
+
+	var role struct {
+		Role struct {
+			RoleID string `json:"role_id"`
+		} `json:"role"`
+	}
+	if want, have := http.StatusOK, srv.send(t, http.MethodGet, "/auth/approle/role/batch", "", &role); want != have || role.Role.RoleID == "" {
+		t.Fatalf("read role: want %d with a role_id, have %d %q", want, have, role.Role.RoleID)
+	}
+	var secret struct {
+		SecretID string `json:"secret_id"`
+		NumUses  int    `json:"secret_id_num_uses,string"`
+	}
+	srv.post(t, "/auth/approle/role/batch/secret-id", "", &secret)
+	if want, have := 2, secret.NumUses; want != have {
+		t.Errorf("secret_id_num_uses: want %d, have %d", want, have)
+	}
+
+	login := func(roleID, secretID string, v interface{}) int {
+		return sendAs(t, "", http.MethodPost, srv.URL+"/auth/approle/login", fmt.Sprintf(`{"role_id":%q,"secret_id":%q}`, roleID, secretID), v)
+	}
+	if want, have := http.StatusBadRequest, login(role.Role.RoleID, secret.SecretID+"x", nil); want != have {
+		t.Errorf("login with wrong secret_id: want %d, have %d", want, have)
+	}
+	var tok struct {
+		ID         string   `json:"token"`
+		ExpireTime unixNano `json:"expire_time"`
+	}
+	if want, have := http.StatusOK, login(role.Role.RoleID, secret.SecretID, &tok); want != have {
+		t.Fatalf("login: want %d, have %d", want, have)
+	}
+	if ttl := tok.ExpireTime.TTL(); ttl <= 0 || ttl > 600 {
+		t.Errorf("login token ttl: want (0, 600], have %d", ttl)
+	}
+	var self struct {
+		DisplayName string   `json:"display_name"`
+		Policies    []string `json:"policies"`
+	}
+	sendAs(t, tok.ID, http.MethodGet, srv.URL+"/auth/token/lookup-self", "", &self)
+	if want, have := "approle-batch [default hasher]", fmt.Sprint(self.DisplayName, " ", self.Policies); want != have {
+		t.Errorf("login token: want %s, have %s", want, have)
+	}
+	if want, have := http.StatusOK, login(role.Role.RoleID, secret.SecretID, nil); want != have {
+		t.Errorf("second login: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusBadRequest, login(role.Role.RoleID, secret.SecretID, nil); want != have {
+		t.Errorf("login with used up secret_id: want %d, have %d", want, have)
+	}
+
+	var other struct {
+		Role struct {
+			RoleID string `json:"role_id"`
+		} `json:"role"`
+	}
+	srv.send(t, http.MethodGet, "/auth/approle/role/elsewhere", "", &other)
+	srv.post(t, "/auth/approle/role/elsewhere/secret-id", "", &secret)
+	if want, have := http.StatusBadRequest, login(other.Role.RoleID, secret.SecretID, nil); want != have {
+		t.Errorf("login outside bound cidrs: want %d, have %d", want, have)
+	}
+
+	if want, have := http.StatusBadRequest, srv.send(t, http.MethodPost, "/auth/approle/role/elsewhere", fmt.Sprintf(`{"role_id":%q}`, role.Role.RoleID), nil); want != have {
+		t.Errorf("write role with a role_id in use: want %d, have %d", want, have)
+	}
+}
+
+func TestUserpass(t *testing.T) {
+	srv := newTestServer(t)
+	srv.putPolicy(t, "hasher", `{"path":{"hash":{"capabilities":["hash"]}}}`)
+
+	var out struct{}
+	if want, have := http.StatusBadRequest, srv.send(t, http.MethodPost, "/auth/userpass/users/alice", `{"token_policies":["hasher"]}`, nil); want != have {
+		t.Errorf("create user without password: want %d, have %d", want, have)
+	}
+	srv.post(t, "/auth/userpass/users/alice", `{"password":"correct horse","token_policies":["hasher"],"token_ttl":"15m"}`, &out)
+
+	var user struct {
+		Password      string   `json:"password"`
+		TokenPolicies []string `json:"token_policies"`
+	}
+	srv.send(t, http.MethodGet, "/auth/userpass/users/alice", "", &user)
+	if want, have := "[hasher]", fmt.Sprint(user.Password, user.TokenPolicies); want != have {
+		t.Errorf("read user: want %s, have %s", want, have)
+	}
+
+	login := func(username, password string, v interface{}) int {
+		return sendAs(t, "", http.MethodPost, srv.URL+"/auth/userpass/login/"+username, fmt.Sprintf(`{"password":%q}`, password), v)
+	}
+	if want, have := http.StatusBadRequest, login("alice", "wrong", nil); want != have {
+		t.Errorf("login with wrong password: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusBadRequest, login("bob", "correct horse", nil); want != have {
+		t.Errorf("login as unknown user: want %d, have %d", want, have)
+	}
+	var tok struct {
+		ID         string   `json:"token"`
+		Policies   []string `json:"policies"`
+		ExpireTime unixNano `json:"expire_time"`
+	}
+	if want, have := http.StatusOK, login("alice", "correct horse", &tok); want != have {
+		t.Fatalf("login: want %d, have %d", want, have)
+	}
+	if want, have := "[default hasher]", fmt.Sprint(tok.Policies); want != have {
+		t.Errorf("login token policies: want %s, have %s", want, have)
+	}
+	if ttl := tok.ExpireTime.TTL(); ttl <= 0 || ttl > 900 {
+		t.Errorf("login token ttl: want (0, 900], have %d", ttl)
+	}
+
+	// Updating the policies keeps the password.
+	srv.post(t, "/auth/userpass/users/alice", `{"token_policies":["app"]}`, &out)
+	if want, have := http.StatusOK, login("alice", "correct horse", nil); want != have {
+		t.Errorf("login after update: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusOK, srv.send(t, http.MethodDelete, "/auth/userpass/users/alice", "", nil); want != have {
+		t.Errorf("delete user: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusBadRequest, login("alice", "correct horse", nil); want != have {
+		t.Errorf("login as deleted user: want %d, have %d", want, have)
+	}
+}
+
+func TestOAuth(t *testing.T) {
+	srv := newTestServer(t)
+	srv.putPolicy(t, "hasher", `{"path":{"hash":{"capabilities":["hash"]}}}`)
+
+	var client struct {
+		ClientSecret string `json:"client_secret"`
+	}
+	srv.post(t, "/oauth/clients/billing", `{"scopes":["vault:hash","vault:validate"],"token_policies":["hasher"],"token_ttl":"10m"}`, &client)
+	if client.ClientSecret == "" {
+		t.Fatal("client secret not returned on registration")
+	}
+	secret := client.ClientSecret
+	// Updating a client keeps its secret.
+	client.ClientSecret = ""
+	srv.post(t, "/oauth/clients/billing", `{"scopes":["vault:hash","vault:validate"],"token_policies":["hasher"],"token_ttl":"10m"}`, &client)
+	if client.ClientSecret != "" {
+		t.Error("client secret returned on update")
+	}
+
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	if want, have := http.StatusUnauthorized, oauthPost(t, srv.URL+"/oauth/token", "billing", "wrong", "grant_type=client_credentials", &errResp); want != have {
+		t.Errorf("token with wrong secret: want %d, have %d", want, have)
+	}
+	if want, have := "invalid_client", errResp.Error; want != have {
+		t.Errorf("token with wrong secret: want %s, have %s", want, have)
+	}
+	if want, have := http.StatusBadRequest, oauthPost(t, srv.URL+"/oauth/token", "billing", secret, "grant_type=password", &errResp); want != have || errResp.Error != "unsupported_grant_type" {
+		t.Errorf("password grant: want %d unsupported_grant_type, have %d %s", want, have, errResp.Error)
+	}
+	if want, have := http.StatusBadRequest, oauthPost(t, srv.URL+"/oauth/token", "billing", secret, "grant_type=client_credentials&scope=vault:admin", &errResp); want != have || errResp.Error != "invalid_scope" {
+		t.Errorf("unknown scope: want %d invalid_scope, have %d %s", want, have, errResp.Error)
+	}
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+		Scope       string `json:"scope"`
+	}
+	if want, have := http.StatusOK, oauthPost(t, srv.URL+"/oauth/token", "billing", secret, "grant_type=client_credentials&scope=vault:hash", &tok); want != have {
+		t.Fatalf("token: want %d, have %d", want, have)
+	}
+	if want, have := "Bearer vault:hash", tok.TokenType+" "+tok.Scope; want != have {
+		t.Errorf("token: want %s, have %s", want, have)
+	}
+	if tok.ExpiresIn <= 0 || tok.ExpiresIn > 600 {
+		t.Errorf("token expires_in: want (0, 600], have %d", tok.ExpiresIn)
+	}
+
+	// The hash endpoint is rate limited, only check it was authorized.
+	if have := sendAs(t, tok.AccessToken, http.MethodPost, srv.URL+"/hash", `{"password":"znm9832nmrfz4egwy43rn8"}`, nil); have == http.StatusForbidden || have == http.StatusUnauthorized {
+		t.Errorf("hash with access token: have %d", have)
+	}
+	if want, have := http.StatusForbidden, sendAs(t, tok.AccessToken, http.MethodGet, srv.URL+"/kv/data/app/db", "", nil); want != have {
+		t.Errorf("read secret with access token: want %d, have %d", want, have)
+	}
+	var validateTok struct {
+		AccessToken string `json:"access_token"`
+	}
+	oauthPost(t, srv.URL+"/oauth/token", "billing", secret, "grant_type=client_credentials&scope=vault:validate", &validateTok)
+	if want, have := http.StatusForbidden, sendAs(t, validateTok.AccessToken, http.MethodPost, srv.URL+"/hash", `{"password":"znm9832nmrfz4egwy43rn8"}`, nil); want != have {
+		t.Errorf("hash with access token without the vault:hash scope: want %d, have %d", want, have)
+	}
+
+	var info struct {
+		Active   bool   `json:"active"`
+		ClientID string `json:"client_id"`
+		Scope    string `json:"scope"`
+	}
+	oauthPost(t, srv.URL+"/oauth/introspect", "billing", secret, "token="+tok.AccessToken, &info)
+	if want, have := "true billing vault:hash", fmt.Sprintf("%v %s %s", info.Active, info.ClientID, info.Scope); want != have {
+		t.Errorf("introspect: want %s, have %s", want, have)
+	}
+	if want, have := http.StatusOK, oauthPost(t, srv.URL+"/oauth/revoke", "billing", secret, "token="+tok.AccessToken, nil); want != have {
+		t.Errorf("revoke: want %d, have %d", want, have)
+	}
+	info.Active = true
+	oauthPost(t, srv.URL+"/oauth/introspect", "billing", secret, "token="+tok.AccessToken, &info)
+	if info.Active {
+		t.Error("introspect after revocation: token still active")
+	}
+	if want, have := http.StatusUnauthorized, sendAs(t, tok.AccessToken, http.MethodPost, srv.URL+"/hash", `{"password":"znm9832nmrfz4egwy43rn8"}`, nil); want != have {
+		t.Errorf("hash with revoked access token: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusOK, oauthPost(t, srv.URL+"/oauth/revoke", "billing", secret, "token=garbage", nil); want != have {
+		t.Errorf("revoke invalid token: want %d, have %d", want, have)
+	}
+}
+
+func TestExternalJWT(t *testing.T) {
+	srv := newTestServer(t)
+	srv.putPolicy(t, "hasher", `{"path":{"hash":{"capabilities":["hash"]}}}`)
+
+	sign := func(audience, scope string) string {
+		return srv.signJWT(t, srv.nextJWTID(), audience, scope)
+	}
+	hash := func(tok string) int {
+		return sendAs(t, tok, http.MethodPost, srv.URL+"/hash", `{"password":"znm9832nmrfz4egwy43rn8"}`, nil)
+	}
+	if want, have := http.StatusUnauthorized, hash(sign("other", "vault:hash")); want != have {
+		t.Errorf("hash with JWT for another audience: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusForbidden, hash(sign("vaultd", "vault:hash")); want != have {
+		t.Errorf("hash with JWT of a subject without policies: want %d, have %d", want, have)
+	}
+	var out struct{}
+	if want, have := http.StatusBadRequest, srv.send(t, http.MethodPut, "/sys/subject/ci", `{"policies":["hasher"]}`, nil); want != have {
+		t.Errorf("write subject without auth method: want %d, have %d", want, have)
+	}
+	// A certificate named like the JWT subject does not grant it policies.
+	srv.put(t, "/sys/subject/cert/ci", `{"policies":["hasher"]}`, &out)
+	if want, have := http.StatusForbidden, hash(sign("vaultd", "vault:hash")); want != have {
+		t.Errorf("hash with JWT of a subject with certificate policies: want %d, have %d", want, have)
+	}
+	srv.put(t, "/sys/subject/jwt/ci", `{"policies":["hasher"]}`, &out)
+	if want, have := http.StatusForbidden, hash(sign("vaultd", "vault:validate")); want != have {
+		t.Errorf("hash with JWT without the vault:hash scope: want %d, have %d", want, have)
+	}
+	// The hash endpoint is rate limited, only check it was authorized.
+	if have := hash(sign("vaultd", "vault:validate vault:hash")); have == http.StatusForbidden || have == http.StatusUnauthorized {
+		t.Errorf("hash with JWT of a subject with policies: have %d", have)
+	}
+}
+
+func TestDenylist(t *testing.T) {
+	srv := newTestServer(t)
+	srv.putPolicy(t, "hasher", `{"path":{"hash":{"capabilities":["hash"]}}}`)
+	srv.put(t, "/sys/subject/jwt/ci", `{"policies":["hasher"]}`, &struct{}{})
+
+	hash := func(tok string) int {
+		return sendAs(t, tok, http.MethodPost, srv.URL+"/hash", `{"password":"znm9832nmrfz4egwy43rn8"}`, nil)
+	}
+	authorized := func(status int) bool {
+		return status != http.StatusUnauthorized && status != http.StatusForbidden
+	}
+
+	tok := srv.signJWT(t, "replayed", "vaultd", "vault:hash")
+	if have := hash(tok); !authorized(have) {
+		t.Errorf("hash with JWT: have %d", have)
+	}
+	if want, have := http.StatusUnauthorized, hash(tok); want != have {
+		t.Errorf("hash with replayed JWT: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusUnauthorized, hash(srv.signJWT(t, "", "vaultd", "vault:hash")); want != have {
+		t.Errorf("hash with JWT without ID: want %d, have %d", want, have)
+	}
+
+	var entry struct {
+		Entry struct {
+			Kind       string   `json:"kind"`
+			Value      string   `json:"value"`
+			Reason     string   `json:"reason"`
+			ExpireTime unixNano `json:"expire_time"`
+		} `json:"entry"`
+	}
+	srv.post(t, "/sys/denylist/jti/leaked", `{"reason":"leaked in logs","ttl":"1h"}`, &entry)
+	if want, have := http.StatusUnauthorized, hash(srv.signJWT(t, "leaked", "vaultd", "vault:hash")); want != have {
+		t.Errorf("hash with denied JWT ID: want %d, have %d", want, have)
+	}
+	entry.Entry.Reason = ""
+	if want, have := http.StatusOK, srv.send(t, http.MethodGet, "/sys/denylist/jti/leaked", "", &entry); want != have {
+		t.Fatalf("read denied JWT ID: want %d, have %d", want, have)
+	}
+	if want, have := "jti leaked leaked in logs", fmt.Sprintf("%s %s %s", entry.Entry.Kind, entry.Entry.Value, entry.Entry.Reason); want != have {
+		t.Errorf("read denied JWT ID: want %s, have %s", want, have)
+	}
+	if d := time.Until(entry.Entry.ExpireTime.Time()); d <= 0 || d > time.Hour {
+		t.Errorf("denied JWT ID expires in %s, want (0, 1h]", d)
+	}
+
+	var out struct{}
+	srv.post(t, "/sys/denylist/subject/ci", `{"reason":"compromised runner"}`, &out)
+	if want, have := http.StatusUnauthorized, hash(srv.signJWT(t, srv.nextJWTID(), "vaultd", "vault:hash")); want != have {
+		t.Errorf("hash with JWT of a denied subject: want %d, have %d", want, have)
+	}
+	var list struct {
+		Entries []struct {
+			Kind  string `json:"kind"`
+			Value string `json:"value"`
+		} `json:"entries"`
+	}
+	srv.send(t, http.MethodGet, "/sys/denylist", "", &list)
+	if want, have := "[{jti leaked} {subject ci}]", fmt.Sprint(list.Entries); want != have {
+		t.Errorf("list denied: want %s, have %s", want, have)
+	}
+	if want, have := http.StatusOK, srv.send(t, http.MethodDelete, "/sys/denylist/subject/ci", "", nil); want != have {
+		t.Errorf("allow subject: want %d, have %d", want, have)
+	}
+	if have := hash(srv.signJWT(t, srv.nextJWTID(), "vaultd", "vault:hash")); !authorized(have) {
+		t.Errorf("hash with JWT of an allowed subject: have %d", have)
+	}
+
+	if want, have := http.StatusBadRequest, srv.send(t, http.MethodPost, "/sys/denylist/user/ci", "", nil); want != have {
+		t.Errorf("deny unknown kind: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusNotFound, srv.send(t, http.MethodGet, "/sys/denylist/subject/ci", "", nil); want != have {
+		t.Errorf("read allowed subject: want %d, have %d", want, have)
+	}
+}
+
+func TestTokenSources(t *testing.T) {
+	srv := newTestServer(t)
+	srv.putPolicy(t, "hasher", `{"path":{"hash":{"capabilities":["hash"]}}}`)
+	srv.put(t, "/sys/subject/jwt/ci", `{"policies":["hasher"]}`, &struct{}{})
+
+	ctx := context.Background()
+	authorized := func(tok string) bool {
+		have := sendAs(t, tok, http.MethodPost, srv.URL+"/hash", `{"password":"znm9832nmrfz4egwy43rn8"}`, nil)
+		return have != http.StatusUnauthorized && have != http.StatusForbidden
+	}
+
+	signed, err := vaultransport.NewSelfSignedTokenSource(vaultransport.SelfSignedConfig{
+		Key:      srv.idpKey,
+		KeyID:    "idp",
+		Issuer:   "https://idp.example.com",
+		Subject:  "ci",
+		Audience: "vaultd",
+		Scope:    "vault:hash",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Replays are refused, so every request needs a new JWT.
+	for i := 0; i < 2; i++ {
+		tok, err := signed.Token(ctx)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if body == nil {
-			t.Fail()
+		if !authorized(tok) {
+			t.Errorf("hash with self-signed JWT %d: not authorized", i)
 		}
+	}
+
+	var client struct {
+		ClientSecret string `json:"client_secret"`
+	}
+	srv.post(t, "/oauth/clients/batch", `{"scopes":["vault:hash"],"token_policies":["hasher"],"token_ttl":"10m"}`, &client)
+	issued := vaultransport.NewOAuthTokenSource(srv.oauth, vaultservice.ClientCredentials{ClientID: "batch", ClientSecret: client.ClientSecret}, "vault:hash")
+	first, err := issued.Token(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := issued.Token(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("access token not reused")
+	}
+	if !authorized(second) {
+		t.Error("hash with access token of the token source: not authorized")
+	}
+
+	fetches := 0
+	expiring := vaultransport.NewCachingTokenSource(func(context.Context) (string, time.Time, error) {
+		fetches++
+		return fmt.Sprint(fetches), time.Now().Add(5 * time.Second), nil
 	})
+	expiring.Token(ctx)
+	if tok, _ := expiring.Token(ctx); tok != "2" {
+		t.Errorf("token about to expire: want a new token, have %s", tok)
+	}
+}
 
-	t.Run("validate hash", func(t *testing.T) {
-		caseValidate := testcase{
-			method: http.MethodPost,
-			url:    srv.URL + "/validate",
-			body:   `{"password":"znm9832nmrfz4egwy43rn8","hash":"$2a$10$8e4JwCH9mCppJpTQ3Ax1PevFIt79her0oOg7AFy3eA4BNoeOMX1w."}`,
-			want:   `{"valid":true}`,
-		}
-		req, err := http.NewRequest(caseValidate.method, caseValidate.url, strings.NewReader(caseValidate.body))
+func TestMTLS(t *testing.T) {
+	srv := newTestServer(t)
+	srv.putPolicy(t, "hasher", `{"path":{"hash":{"capabilities":["hash"]}}}`)
+
+	ca, cert := newClientCert(t, "spiffe://example.org/billing")
+	tlsSrv := httptest.NewUnstartedServer(srv.Config.Handler)
+	tlsSrv.TLS = &tls.Config{ClientCAs: x509.NewCertPool(), ClientAuth: tls.VerifyClientCertIfGiven}
+	tlsSrv.TLS.ClientCAs.AddCert(ca)
+	tlsSrv.StartTLS()
+	defer tlsSrv.Close()
+
+	hash := func(certs ...tls.Certificate) int {
+		// A new transport, so that no connection is reused across certificates.
+		transport := tlsSrv.Client().Transport.(*http.Transport).Clone()
+		transport.TLSClientConfig.Certificates = certs
+		defer transport.CloseIdleConnections()
+		resp, err := (&http.Client{Transport: transport}).Post(tlsSrv.URL+"/hash", "application/json", strings.NewReader(`{"password":"znm9832nmrfz4egwy43rn8"}`))
 		if err != nil {
 			t.Fatal(err)
 		}
-		setHeader(req)
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if want, have := http.StatusUnauthorized, hash(); want != have {
+		t.Errorf("hash without certificate: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusForbidden, hash(cert); want != have {
+		t.Errorf("hash with certificate of a subject without policies: want %d, have %d", want, have)
+	}
+	// SPIFFE IDs hold "//", which cannot be sent in a request path.
+	if err := srv.policies.SetSubjectPolicies(context.Background(), policy.MethodCert, "spiffe://example.org/billing", []string{"hasher"}); err != nil {
+		t.Fatal(err)
+	}
+	if have := hash(cert); have == http.StatusUnauthorized || have == http.StatusForbidden {
+		t.Errorf("hash with certificate: want authorized, have %d", have)
+	}
+
+	// The clients verify the server against the CA bundle, and
+	// authenticate with the client certificate.
+	dir := t.TempDir()
+	writePEM := func(name, typ string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
 			t.Fatal(err)
 		}
-		body, err := ioutil.ReadAll(resp.Body)
+		return path
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := vaultransport.ClientTLS{
+		CAFile:   writePEM("ca.pem", "CERTIFICATE", tlsSrv.Certificate().Raw),
+		CertFile: writePEM("client.pem", "CERTIFICATE", cert.Certificate[0]),
+		KeyFile:  writePEM("client-key.pem", "PRIVATE KEY", keyDER),
+	}
+	for _, tc := range []struct {
+		name string
+		opts vaultransport.ClientTLS
+		ok   bool
+	}{
+		{"system roots", vaultransport.ClientTLS{}, false},
+		{"insecure", vaultransport.ClientTLS{Insecure: true}, true},
+		{"CA bundle", vaultransport.ClientTLS{CAFile: opts.CAFile}, true},
+		{"wrong server name", vaultransport.ClientTLS{CAFile: opts.CAFile, ServerName: "vault.invalid"}, false},
+	} {
+		sys, err := vaultransport.NewHTTPSysClient(tlsSrv.URL, tc.opts, nil, opentracing.GlobalTracer(), srv.zkt, log.NewNopLogger())
 		if err != nil {
 			t.Fatal(err)
 		}
-		if want, have := caseValidate.want, strings.TrimSpace(string(body)); want != have {
-			t.Errorf("%s %s %s: want %s, have %s", caseValidate.method, caseValidate.url, caseValidate.body, want, have)
+		if _, err := sys.SealStatus(context.Background()); (err == nil) != tc.ok {
+			t.Errorf("seal status with %s: want ok %v, have %v", tc.name, tc.ok, err)
 		}
-	})
-}
-
-func signTok() string {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.StandardClaims{
-		IssuedAt:  time.Now().Unix(),
-		ExpiresAt: time.Now().Add(1 * time.Second).Unix(),
-	})
-	ss, err := token.SignedString([]byte("zmh298onj30"))
+	}
+	hasher, err := vaultransport.NewHTTPClient(tlsSrv.URL, opts, nil, opentracing.GlobalTracer(), srv.zkt, log.NewNopLogger())
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
+	}
+	if _, err := hasher.Hash(context.Background(), "znm9832nmrfz4egwy43rn8"); err != nil && (strings.HasPrefix(err.Error(), "401") || strings.HasPrefix(err.Error(), "403")) {
+		t.Errorf("hash with client certificate: want authorized, have %v", err)
+	}
+	if err := srv.denylist.Deny(context.Background(), denylist.Entry{Kind: denylist.KindSubject, Value: "spiffe://example.org/billing"}); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := http.StatusUnauthorized, hash(cert); want != have {
+		t.Errorf("hash with denied certificate: want %d, have %d", want, have)
 	}
-	return ss
 }
 
-func setHeader(r *http.Request) {
-	r.Header.Set("Content-Type", "application/json")
-	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", signTok()))
+func TestPKISecrets(t *testing.T) {
+	srv := newTestServer(t)
+	var ca struct {
+		CA struct {
+			Certificate string `json:"certificate"`
+		} `json:"ca"`
+	}
+	srv.post(t, "/pki/ca/generate", `{"common_name":"vaultd test CA"}`, &ca)
+	if want, have := http.StatusOK, sendAs(t, "", http.MethodGet, srv.URL+"/pki/ca", "", &ca); want != have {
+		t.Fatalf("read CA without token: want %d, have %d", want, have)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM([]byte(ca.CA.Certificate))
+
+	var out struct{}
+	srv.post(t, "/pki/roles/web", `{"allowed_domains":["example.com"],"allow_subdomains":true,"max_ttl":"1h","server_flag":true}`, &out)
+	if want, have := http.StatusBadRequest, srv.send(t, http.MethodPost, "/pki/roles/bad", `{"key_type":"dsa"}`, nil); want != have {
+		t.Errorf("write role with invalid key type: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusUnauthorized, sendAs(t, "", http.MethodPost, srv.URL+"/pki/issue/web", `{"common_name":"api.example.com"}`, nil); want != have {
+		t.Errorf("issue without token: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusBadRequest, srv.send(t, http.MethodPost, "/pki/issue/web", `{"common_name":"example.org"}`, nil); want != have {
+		t.Errorf("issue outside allowed domains: want %d, have %d", want, have)
+	}
+	if want, have := http.StatusNotFound, srv.send(t, http.MethodPost, "/pki/issue/unknown", `{"common_name":"api.example.com"}`, nil); want != have {
+		t.Errorf("issue with unknown role: want %d, have %d", want, have)
+	}
+
+	type issued struct {
+		Certificate struct {
+			SerialNumber   string   `json:"serial_number"`
+			Certificate    string   `json:"certificate"`
+			PrivateKey     string   `json:"private_key"`
+			CAChain        []string `json:"ca_chain"`
+			RevocationTime unixNano `json:"revocation_time"`
+		} `json:"certificate"`
+	}
+	verify := func(issued issued, dnsName string) *x509.Certificate {
+		t.Helper()
+		resp := issued.Certificate
+		block, _ := pem.Decode([]byte(resp.Certificate))
+		if block == nil {
+			t.Fatalf("no certificate: %q", resp.Certificate)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cert.Verify(x509.VerifyOptions{DNSName: dnsName, Roots: roots}); err != nil {
+			t.Errorf("verify %s: %v", dnsName, err)
+		}
+		return cert
+	}
+	var certResp issued
+	srv.post(t, "/pki/issue/web", `{"common_name":"api.example.com","alt_names":["www.example.com"],"ttl":"24h"}`, &certResp)
+	cert := certResp.Certificate
+	leaf := verify(certResp, "www.example.com")
+	if d := time.Until(leaf.NotAfter); d <= 0 || d > time.Hour {
+		t.Errorf("certificate capped by max_ttl expires in %s, want (0, 1h]", d)
+	}
+	if _, err := tls.X509KeyPair([]byte(cert.Certificate), []byte(cert.PrivateKey)); err != nil {
+		t.Errorf("issued key pair: %v", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "csr.example.com"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, _ := json.Marshal(map[string]string{"csr": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))})
+	var signed issued
+	srv.post(t, "/pki/sign/web", string(csr), &signed)
+	verify(signed, "csr.example.com")
+	if signed.Certificate.PrivateKey != "" {
+		t.Error("signed CSR: want no private key")
+	}
+
+	var revoked issued
+	srv.post(t, "/pki/revoke", fmt.Sprintf(`{"serial_number":%q}`, cert.SerialNumber), &revoked)
+	if revoked.Certificate.RevocationTime == "" {
+		t.Error("revoked certificate: want revocation_time")
+	}
+	var list struct {
+		SerialNumbers []string `json:"serial_numbers"`
+	}
+	srv.send(t, http.MethodGet, "/pki/certs", "", &list)
+	if want, have := 2, len(list.SerialNumbers); want != have {
+		t.Errorf("list certificates: want %d, have %d", want, have)
+	}
+	var crl struct {
+		CRL string `json:"crl"`
+	}
+	if want, have := http.StatusOK, sendAs(t, "", http.MethodGet, srv.URL+"/pki/crl", "", &crl); want != have {
+		t.Fatalf("read CRL without token: want %d, have %d", want, have)
+	}
+	revocations, err := x509.ParseCRL([]byte(crl.CRL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(revocations.TBSCertList.RevokedCertificates); want != have {
+		t.Fatalf("revoked certificates in the CRL: want %d, have %d", want, have)
+	}
+	if want, have := leaf.SerialNumber, revocations.TBSCertList.RevokedCertificates[0].SerialNumber; want.Cmp(have) != 0 {
+		t.Errorf("revoked serial number: want %s, have %s", want, have)
+	}
+
+	// An intermediate CA signed by an external root replaces the CA.
+	var intermediate struct {
+		CA struct {
+			CSR string `json:"csr"`
+		} `json:"ca"`
+	}
+	srv.post(t, "/pki/ca/generate", `{"intermediate":true,"common_name":"vaultd intermediate CA"}`, &intermediate)
+	rootKey, err := pki.GenerateKey(pki.KeyTypeEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := pki.NewCA(pki.Template{CommonName: "external root", TTL: 24 * time.Hour}, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode([]byte(intermediate.CA.CSR))
+	req, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inter, err := pki.Issue(pki.Template{CommonName: req.Subject.CommonName, TTL: 12 * time.Hour, IsCA: true}, req.PublicKey, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, _ := json.Marshal(map[string]string{"pem_bundle": string(pki.EncodeCertificate(inter)) + string(pki.EncodeCertificate(root))})
+	srv.post(t, "/pki/ca", string(bundle), &out)
+	roots = x509.NewCertPool()
+	roots.AddCert(root)
+	var chained issued
+	srv.post(t, "/pki/issue/web", `{"common_name":"api.example.com"}`, &chained)
+	intermediates := x509.NewCertPool()
+	for _, c := range chained.Certificate.CAChain {
+		intermediates.AppendCertsFromPEM([]byte(c))
+	}
+	block, _ = pem.Decode([]byte(chained.Certificate.Certificate))
+	leaf, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: "api.example.com", Roots: roots, Intermediates: intermediates}); err != nil {
+		t.Errorf("verify certificate of the intermediate CA: %v", err)
+	}
+}
+
+func TestSSHSecrets(t *testing.T) {
+	srv := newTestServer(t)
+	if want, have := http.StatusNotFound, sendAs(t, "", http.MethodGet, srv.URL+"/ssh/ca", "", nil); want != have {
+		t.Errorf("read unconfigured CA: want %d, have %d", want, have)
+	}
+	var out struct{}
+	srv.post(t, "/ssh/ca", `{}`, &out)
+	resp, err := http.Get(srv.URL + "/ssh/public_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	caKey, _, _, _, err := ssh.ParseAuthorizedKey(raw)
+	if err != nil {
+		t.Fatalf("parse CA public key %q: %v", raw, err)
+	}
+
+	srv.post(t, "/ssh/roles/dev", `{"allow_user_certificates":true,"allowed_users":["alice","bob"],"allowed_extensions":["permit-pty"],"default_extensions":{"permit-pty":""},"max_ttl":"30m"}`, &out)
+	if want, have := http.StatusBadRequest, srv.send(t, http.MethodPost, "/ssh/roles/bad", `{}`, nil); want != have {
+		t.Errorf("write role allowing no certificates: want %d, have %d", want, have)
+	}
+
+	_, userKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userPub, err := ssh.NewPublicKey(userKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(body map[string]interface{}) string {
+		body["public_key"] = string(ssh.MarshalAuthorizedKey(userPub))
+		b, _ := json.Marshal(body)
+		return string(b)
+	}
+	if want, have := http.StatusUnauthorized, sendAs(t, "", http.MethodPost, srv.URL+"/ssh/sign/dev", sign(map[string]interface{}{"valid_principals": []string{"alice"}}), nil); want != have {
+		t.Errorf("sign without token: want %d, have %d", want, have)
+	}
+	for name, body := range map[string]map[string]interface{}{
+		"principal not allowed": {"valid_principals": []string{"root"}},
+		"no principal":          {},
+		"extension not allowed": {"valid_principals": []string{"alice"}, "extensions": map[string]string{"permit-port-forwarding": ""}},
+		"host certificate":      {"valid_principals": []string{"alice"}, "cert_type": "host"},
+	} {
+		if want, have := http.StatusBadRequest, srv.send(t, http.MethodPost, "/ssh/sign/dev", sign(body), nil); want != have {
+			t.Errorf("sign with %s: want %d, have %d", name, want, have)
+		}
+	}
+
+	var signed struct {
+		SerialNumber string `json:"serial_number"`
+		SignedKey    string `json:"signed_key"`
+	}
+	srv.post(t, "/ssh/sign/dev", sign(map[string]interface{}{"valid_principals": []string{"alice"}, "ttl": "2h"}), &signed)
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(signed.SignedKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		t.Fatalf("signed key is a %T, want a certificate", pub)
+	}
+	checker := ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return string(auth.Marshal()) == string(caKey.Marshal())
+		},
+	}
+	if _, err := checker.Authenticate(userConnMetadata("alice"), cert); err != nil {
+		t.Errorf("authenticate alice: %v", err)
+	}
+	if _, err := checker.Authenticate(userConnMetadata("bob"), cert); err == nil {
+		t.Error("authenticate bob with the certificate of alice: want error")
+	}
+	if _, ok := cert.Extensions["permit-pty"]; !ok {
+		t.Errorf("extensions %v: want the default permit-pty", cert.Extensions)
+	}
+	if d := time.Until(time.Unix(int64(cert.ValidBefore), 0)); d <= 0 || d > 30*time.Minute {
+		t.Errorf("certificate capped by max_ttl expires in %s, want (0, 30m]", d)
+	}
+
+	// Host certificates are signed for the subdomains of the role.
+	srv.post(t, "/ssh/roles/hosts", `{"allow_host_certificates":true,"allowed_domains":["example.com"],"allow_subdomains":true}`, &out)
+	if want, have := http.StatusBadRequest, srv.send(t, http.MethodPost, "/ssh/sign/hosts", sign(map[string]interface{}{"cert_type": "host", "valid_principals": []string{"example.org"}}), nil); want != have {
+		t.Errorf("sign host outside allowed domains: want %d, have %d", want, have)
+	}
+	srv.post(t, "/ssh/sign/hosts", sign(map[string]interface{}{"cert_type": "host", "valid_principals": []string{"db.example.com"}}), &signed)
+	pub, _, _, _, err = ssh.ParseAuthorizedKey([]byte(signed.SignedKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostChecker := ssh.CertChecker{
+		IsHostAuthority: func(auth ssh.PublicKey, _ string) bool {
+			return string(auth.Marshal()) == string(caKey.Marshal())
+		},
+	}
+	if err := hostChecker.CheckHostKey("db.example.com:22", nil, pub); err != nil {
+		t.Errorf("check host key: %v", err)
+	}
+
+	// An imported key replaces the key of the CA.
+	caSigner, err := pki.GenerateKey(pki.KeyTypeEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := pki.EncodeKey(caSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(map[string]string{"private_key": string(keyPEM)})
+	var ca struct {
+		PublicKey string `json:"public_key"`
+	}
+	srv.post(t, "/ssh/ca", string(body), &ca)
+	imported, err := ssh.NewPublicKey(caSigner.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := string(ssh.MarshalAuthorizedKey(imported)), ca.PublicKey; want != have {
+		t.Errorf("imported CA public key: want %q, have %q", want, have)
+	}
+}
+
+func TestWrapping(t *testing.T) {
+	srv := newTestServer(t)
+	srv.post(t, "/kv/data/app/db", `{"data":{"user":"root"}}`, &struct{}{})
+
+	// wrapped reads the secret with its response wrapped for ttl.
+	wrapped := func(ttl string) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/kv/data/app/db", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.setHeader(req)
+		req.Header.Set(vaultransport.WrapTTLHeader, ttl)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, body
+	}
+	// unwrap unwraps the token without authenticating, returning the
+	// status code and the unwrapped response.
+	unwrap := func(tok string) (int, []byte) {
+		var unwrapped struct {
+			Data string `json:"data"`
+		}
+		code := sendAs(t, "", http.MethodPost, srv.URL+"/sys/wrapping/unwrap", fmt.Sprintf(`{"token":%q}`, tok), &unwrapped)
+		return code, []byte(unwrapped.Data)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/kv/data/app/db", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.setHeader(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	resp, body := wrapped("1m")
+	if want, have := http.StatusOK, resp.StatusCode; want != have {
+		t.Fatalf("wrapped read: want %d, have %d: %s", want, have, body)
+	}
+	type wrapInfo struct {
+		Token        string `json:"token"`
+		TTL          string `json:"ttl"`
+		CreationPath string `json:"creation_path"`
+	}
+	var info struct {
+		WrapInfo wrapInfo `json:"wrap_info"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "root") {
+		t.Errorf("wrapped response exposes the secret: %s", body)
+	}
+	if want, have := "kv/data/app/db", info.WrapInfo.CreationPath; want != have {
+		t.Errorf("creation path: want %q, have %q", want, have)
+	}
+
+	var lookup struct {
+		WrapInfo wrapInfo `json:"wrap_info"`
+	}
+	srv.post(t, "/sys/wrapping/lookup", fmt.Sprintf(`{"token":%q}`, info.WrapInfo.Token), &lookup)
+	if want, have := "1m0s", lookup.WrapInfo.TTL; want != have {
+		t.Errorf("lookup ttl: want %q, have %q", want, have)
+	}
+	if lookup.WrapInfo.Token != "" {
+		t.Error("lookup returns the wrapping token")
+	}
+
+	// The response is unwrapped exactly once, as it would have been read.
+	code, data := unwrap(info.WrapInfo.Token)
+	if want, have := http.StatusOK, code; want != have {
+		t.Fatalf("unwrap: want %d, have %d: %s", want, have, data)
+	}
+	// protojson randomizes the white space of its output.
+	if want, have := compactJSON(t, secret), compactJSON(t, data); want != have {
+		t.Errorf("unwrapped response: want %s, have %s", want, have)
+	}
+	if want, have := http.StatusBadRequest, func() int { code, _ := unwrap(info.WrapInfo.Token); return code }(); want != have {
+		t.Errorf("second unwrap: want %d, have %d", want, have)
+	}
+
+	if resp, _ := wrapped("soon"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid wrap ttl: want %d, have %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	// Arbitrary data is wrapped in a one-time cubbyhole.
+	srv.post(t, "/sys/wrapping/wrap", `{"data":"{\"password\":\"hunter2\"}","ttl":"30s"}`, &info)
+	if want, have := vaultendpoint.WrapPath, info.WrapInfo.CreationPath; want != have {
+		t.Errorf("cubbyhole creation path: want %q, have %q", want, have)
+	}
+	code, data = unwrap(info.WrapInfo.Token)
+	if want, have := `{"password":"hunter2"}`, string(data); code != http.StatusOK || want != have {
+		t.Errorf("unwrap cubbyhole: want %s, have %d %s", want, code, data)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	srv := newTestServer(t)
+	srv.post(t, "/kv/data/app/db", `{"data":{"user":"admin"}}`, &struct{}{})
+	srv.post(t, "/kv/data/app/db", `{"data":{"user":"root"}}`, &struct{}{})
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/kv/data/app/missing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.setHeader(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if want, have := vaulterr.ProblemContentType, resp.Header.Get("Content-Type"); want != have {
+		t.Errorf("content type: want %s, have %s", want, have)
+	}
+	var problem vaulterr.Problem
+	if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Status != http.StatusNotFound || problem.Code != vaulterr.NotFound || problem.Title != "Not Found" || problem.Detail == "" {
+		t.Errorf("problem details: have %+v", problem)
+	}
+
+	// Both clients decode the errors of the server into typed errors.
+	root := vaultransport.StaticTokenSource(srv.rootToken)
+	httpKV, err := vaultransport.NewHTTPKVClient(srv.URL, vaultransport.ClientTLS{}, root, opentracing.GlobalTracer(), srv.zkt, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	vaultpb.RegisterKVServer(s, vaultransport.NewGRPCKVServer(srv.kvEps, opentracing.GlobalTracer(), srv.zkt, log.NewNopLogger()))
+	vaultpb.RegisterPolicyServer(s, vaultransport.NewGRPCPolicyServer(srv.polEps, opentracing.GlobalTracer(), srv.zkt, log.NewNopLogger()))
+	go s.Serve(lis)
+	defer s.Stop()
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	grpcKV := vaultransport.NewGRPCKVClient(conn, root, opentracing.GlobalTracer(), srv.zkt, log.NewNopLogger())
+	grpcPolicies := vaultransport.NewGRPCPolicyClient(conn, root, opentracing.GlobalTracer(), srv.zkt, log.NewNopLogger())
+
+	ctx := context.Background()
+	stale := 1
+	for name, kv := range map[string]vaultservice.KVService{"http": httpKV, "grpc": grpcKV} {
+		if _, err := kv.Get(ctx, "app/missing", 0); !vaulterr.Is(err, vaulterr.NotFound) {
+			t.Errorf("%s: get missing secret: want %s, have %s %v", name, vaulterr.NotFound, vaulterr.CodeOf(err), err)
+		}
+		if _, err := kv.Put(ctx, "app/db", map[string]string{"user": "x"}, &stale); !vaulterr.Is(err, vaulterr.InvalidArgument) {
+			t.Errorf("%s: stale check-and-set: want %s, have %s %v", name, vaulterr.InvalidArgument, vaulterr.CodeOf(err), err)
+		}
+	}
+	if err := grpcPolicies.WritePolicy(ctx, "root", `{"path":{}}`); !vaulterr.Is(err, vaulterr.InvalidArgument) {
+		t.Errorf("grpc: write builtin policy: want %s, have %s %v", vaulterr.InvalidArgument, vaulterr.CodeOf(err), err)
+	}
+	invalidKV := vaultransport.NewGRPCKVClient(conn, vaultransport.StaticTokenSource("s.invalid"), opentracing.GlobalTracer(), srv.zkt, log.NewNopLogger())
+	if _, err := invalidKV.Get(ctx, "app/db", 0); !vaulterr.Is(err, vaulterr.Unauthenticated) {
+		t.Errorf("grpc: get with invalid token: want %s, have %s %v", vaulterr.Unauthenticated, vaulterr.CodeOf(err), err)
+	}
+}
+
+func TestGateway(t *testing.T) {
+	srv := newTestServer(t)
+	srv.post(t, "/kv/data/app/db", `{"data":{"user":"admin"}}`, &struct{}{})
+
+	resp, err := http.Get(srv.URL + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&doc)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := gateway.OpenAPIVersion, doc.OpenAPI; want != have {
+		t.Errorf("openapi version: want %s, have %s", want, have)
+	}
+	for path, method := range map[string]string{
+		"/v1/kv/data/{path}":        "post",
+		"/v1/sys/seal-status":       "get",
+		"/v1/pki/roles/{name}":      "delete",
+		"/v1/auth/token/renew-self": "post",
+	} {
+		if _, ok := doc.Paths[path][method]; !ok {
+			t.Errorf("openapi: no %s %s", method, path)
+		}
+	}
+
+	// The gateway and the hand-written handlers serve the same secrets.
+	var put struct {
+		Metadata struct {
+			Version int `json:"version"`
+		} `json:"metadata"`
+	}
+	if want, have := http.StatusOK, srv.send(t, http.MethodPost, "/v1/kv/data/gw/db", `{"data":{"user":"gw"}}`, &put); want != have {
+		t.Fatalf("gateway put: want %d, have %d", want, have)
+	}
+	if want, have := 1, put.Metadata.Version; want != have {
+		t.Errorf("gateway put version: want %d, have %d", want, have)
+	}
+	var secret struct {
+		Data map[string]string `json:"data"`
+	}
+	srv.send(t, http.MethodGet, "/kv/data/gw/db", "", &secret)
+	if want, have := "gw", secret.Data["user"]; want != have {
+		t.Errorf("get gateway secret: want %q, have %q", want, have)
+	}
+	secret.Data = nil
+	srv.send(t, http.MethodGet, "/v1/kv/data/app/db?version=1", "", &secret)
+	if want, have := "admin", secret.Data["user"]; want != have {
+		t.Errorf("gateway get version 1: want %q, have %q", want, have)
+	}
+
+	// The errors of the gRPC services are problem details.
+	for _, c := range []struct {
+		tok, method, url, body string
+		want                   int
+	}{
+		{srv.rootToken, http.MethodGet, "/v1/kv/data/gw/missing", "", http.StatusNotFound},
+		{"", http.MethodGet, "/v1/kv/data/gw/db", "", http.StatusUnauthorized},
+		{srv.rootToken, http.MethodPost, "/v1/kv/data/gw/db", `{"data":{"user":"x"},"unknown":1}`, http.StatusBadRequest},
+		{srv.rootToken, http.MethodGet, "/v1/kv/data/gw/db?unknown=1", "", http.StatusBadRequest},
+		{srv.rootToken, http.MethodDelete, "/v1/hash", "", http.StatusMethodNotAllowed},
+		{srv.rootToken, http.MethodGet, "/v1/missing", "", http.StatusNotFound},
+	} {
+		req, err := http.NewRequest(c.method, srv.URL+c.url, strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.tok != "" {
+			req.Header.Set("Authorization", "Bearer "+c.tok)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var problem vaulterr.Problem
+		err = json.NewDecoder(resp.Body).Decode(&problem)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s %s: %v", c.method, c.url, err)
+		}
+		if want, have := vaulterr.ProblemContentType, resp.Header.Get("Content-Type"); want != have {
+			t.Errorf("%s %s: content type: want %s, have %s", c.method, c.url, want, have)
+		}
+		if resp.StatusCode != c.want || problem.Status != c.want {
+			t.Errorf("%s %s: want %d, have %d %+v", c.method, c.url, c.want, resp.StatusCode, problem)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	srv := newTestServer(t)
+	caseHash := testcase{
+		method: http.MethodPost,
+		url:    srv.URL + "/hash",
+		body:   `{"password":"znm9832nmrfz4egwy43rn8"}`,
+	}
+	req, err := http.NewRequest(caseHash.method, caseHash.url, strings.NewReader(caseHash.body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.setHeader(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body == nil {
+		t.Fail()
+	}
+}
+
+func TestValidate(t *testing.T) {
+	srv := newTestServer(t)
+	caseValidate := testcase{
+		method: http.MethodPost,
+		url:    srv.URL + "/validate",
+		body:   `{"password":"znm9832nmrfz4egwy43rn8","hash":"$2a$10$8e4JwCH9mCppJpTQ3Ax1PevFIt79her0oOg7AFy3eA4BNoeOMX1w."}`,
+		want:   `{"valid":true}`,
+	}
+	req, err := http.NewRequest(caseValidate.method, caseValidate.url, strings.NewReader(caseValidate.body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.setHeader(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := caseValidate.want, strings.TrimSpace(string(body)); want != have {
+		t.Errorf("%s %s %s: want %s, have %s", caseValidate.method, caseValidate.url, caseValidate.body, want, have)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	srv := newTestServer(t)
+	srv.putPolicy(t, "hasher", `{"path":{"hash":{"capabilities":["hash"]}}}`)
+	srv.put(t, "/sys/subject/jwt/ci", `{"policies":["hasher"]}`, &struct{}{})
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	vaultpb.RegisterVaultServer(s, vaultransport.NewGRPCServer(srv.eps, srv.batchEps, opentracing.GlobalTracer(), srv.zkt, log.NewNopLogger()))
+	go s.Serve(lis)
+	defer s.Stop()
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// A window smaller than the batch exercises the flow control.
+	clientAs := func(tok string) *vaultransport.BatchClient {
+		return vaultransport.NewGRPCBatchClient(conn, vaultransport.StaticTokenSource(tok), 3)
+	}
+	client := clientAs(srv.rootToken)
+	ctx := context.Background()
+
+	const n = 10
+	hashItems := make(chan vaultendpoint.BatchHashRequest)
+	go func() {
+		defer close(hashItems)
+		for i := 0; i < n; i++ {
+			hashItems <- vaultendpoint.BatchHashRequest{ID: fmt.Sprint(i), Password: fmt.Sprintf("password-%d", i)}
+		}
+	}()
+	hashes := make(map[string]string)
+	if err := client.Hash(ctx, hashItems, func(resp vaultendpoint.BatchHashResponse) {
+		if resp.Err != nil {
+			t.Errorf("hash %s: %v", resp.ID, resp.Err)
+		}
+		hashes[resp.ID] = resp.Hash
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := n, len(hashes); want != have {
+		t.Fatalf("hashes: want %d, have %d", want, have)
+	}
+
+	validateItems := make(chan vaultendpoint.BatchValidateRequest, n+2)
+	for i := 0; i < n; i++ {
+		validateItems <- vaultendpoint.BatchValidateRequest{ID: fmt.Sprint(i), Password: fmt.Sprintf("password-%d", i), Hash: hashes[fmt.Sprint(i)]}
+	}
+	validateItems <- vaultendpoint.BatchValidateRequest{ID: "wrong", Password: "password-0", Hash: hashes["1"]}
+	validateItems <- vaultendpoint.BatchValidateRequest{ID: "missing", Password: "password-0"}
+	close(validateItems)
+	results := make(map[string]vaultendpoint.BatchValidateResponse)
+	if err := client.Validate(ctx, validateItems, func(resp vaultendpoint.BatchValidateResponse) {
+		results[resp.ID] = resp
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if resp := results[fmt.Sprint(i)]; !resp.Valid || resp.Err != nil {
+			t.Errorf("validate %d: have valid=%v err=%v", i, resp.Valid, resp.Err)
+		}
+	}
+	if resp := results["wrong"]; resp.Valid || resp.Err != nil {
+		t.Errorf("validate wrong password: have valid=%v err=%v", resp.Valid, resp.Err)
+	}
+	if resp := results["missing"]; !vaulterr.Is(resp.Err, vaulterr.InvalidArgument) {
+		t.Errorf("validate without hash: want %s, have %s %v", vaulterr.InvalidArgument, vaulterr.CodeOf(resp.Err), resp.Err)
+	}
+
+	// Refused credentials end the stream rather than failing every item.
+	items := make(chan vaultendpoint.BatchHashRequest, 1)
+	items <- vaultendpoint.BatchHashRequest{ID: "0", Password: "password-0"}
+	close(items)
+	if err := clientAs("s.invalid").Hash(ctx, items, func(vaultendpoint.BatchHashResponse) {}); !vaulterr.Is(err, vaulterr.Unauthenticated) {
+		t.Errorf("hash with invalid token: want %s, have %s %v", vaulterr.Unauthenticated, vaulterr.CodeOf(err), err)
+	}
+
+	// The caller is authenticated once per stream, so a JWT, which is
+	// only accepted once, carries every item. Streams are rate limited
+	// like the calls.
+	time.Sleep(time.Second)
+	jwtClient := clientAs(srv.signJWT(t, srv.nextJWTID(), "vaultd", "vault:hash"))
+	items = make(chan vaultendpoint.BatchHashRequest, 3)
+	for i := 0; i < cap(items); i++ {
+		items <- vaultendpoint.BatchHashRequest{ID: fmt.Sprint(i), Password: fmt.Sprintf("password-%d", i)}
+	}
+	close(items)
+	var hashed int
+	if err := jwtClient.Hash(ctx, items, func(resp vaultendpoint.BatchHashResponse) {
+		if resp.Err != nil {
+			t.Errorf("hash %s with JWT: %v", resp.ID, resp.Err)
+		}
+		hashed++
+	}); err != nil {
+		t.Fatalf("hash with JWT: %v", err)
+	}
+	if want, have := 3, hashed; want != have {
+		t.Errorf("hashes with JWT: want %d, have %d", want, have)
+	}
+	items = make(chan vaultendpoint.BatchHashRequest)
+	close(items)
+	if err := client.Hash(ctx, items, func(vaultendpoint.BatchHashResponse) {}); !vaulterr.Is(err, vaulterr.ResourceExhausted) {
+		t.Errorf("hash stream over the rate limit: want %s, have %s %v", vaulterr.ResourceExhausted, vaulterr.CodeOf(err), err)
+	}
+
+	// A stream ends when the token of its caller expires.
+	time.Sleep(time.Second)
+	var short struct {
+		ID string `json:"token"`
+	}
+	srv.post(t, "/auth/token/create", `{"policies":["hasher"],"ttl":"1s"}`, &short)
+	idle := make(chan vaultendpoint.BatchHashRequest)
+	defer close(idle)
+	expiring, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := clientAs(short.ID).Hash(expiring, idle, func(vaultendpoint.BatchHashResponse) {}); !vaulterr.Is(err, vaulterr.Unauthenticated) {
+		t.Errorf("hash stream past the token TTL: want %s, have %s %v", vaulterr.Unauthenticated, vaulterr.CodeOf(err), err)
+	}
+}
+
+func TestPKI(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"ca", "-out-dir", dir},
+		{"server", "-out-dir", dir, "-dns", "localhost,vault.example.com"},
+		{"client", "-out-dir", dir, "-uri", "spiffe://example.org/billing", "-key-type", "rsa"},
+	} {
+		if code := runPKI(args, ioutil.Discard); code != 0 {
+			t.Fatalf("pki %v: exit code %d", args, code)
+		}
+	}
+	if code := runPKI([]string{"client", "-out-dir", t.TempDir(), "-cn", "billing"}, ioutil.Discard); code == 0 {
+		t.Error("client without CA: want failure")
+	}
+
+	// The listeners serve the server certificate, and verify the client
+	// certificate against the CA.
+	certs, err := certmanager.New(filepath.Join(dir, "server-cert.pem"), filepath.Join(dir, "server-key.pem"), discard.NewGauge())
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := serverTLSConfig(certs, filepath.Join(dir, "ca-cert.pem"), "require")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, identity.CertificateName(r.TLS.VerifiedChains[0][0]))
+	}))
+	srv.TLS = config
+	srv.StartTLS()
+	defer srv.Close()
+
+	client, err := (vaultransport.ClientTLS{
+		CAFile:     filepath.Join(dir, "ca-cert.pem"),
+		ServerName: "vault.example.com",
+		CertFile:   filepath.Join(dir, "client-cert.pem"),
+		KeyFile:    filepath.Join(dir, "client-key.pem"),
+	}).Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: client}}).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if want, have := "spiffe://example.org/billing", string(body); want != have {
+		t.Errorf("client identity: want %s, have %s", want, have)
+	}
+}
+
+// userConnMetadata is the metadata of an SSH connection of the user.
+type userConnMetadata string
+
+func (u userConnMetadata) User() string        { return string(u) }
+func (userConnMetadata) SessionID() []byte     { return nil }
+func (userConnMetadata) ClientVersion() []byte { return nil }
+func (userConnMetadata) ServerVersion() []byte { return nil }
+func (userConnMetadata) RemoteAddr() net.Addr  { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)} }
+func (userConnMetadata) LocalAddr() net.Addr   { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)} }
+
+func get(t *testing.T, url string) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// compactJSON returns the JSON document without insignificant white space.
+func compactJSON(t *testing.T, b []byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		t.Fatalf("%s: %v", b, err)
+	}
+	return buf.String()
+}
+
+// unixNano is a time of a response, encoded as the string of its unix
+// nanoseconds like the other 64-bit integers.
+type unixNano string
+
+func (u unixNano) Time() time.Time {
+	n, _ := strconv.ParseInt(string(u), 10, 64)
+	return time.Unix(0, n)
+}
+
+// TTL returns the seconds left until the time.
+func (u unixNano) TTL() int64 {
+	return int64(time.Until(u.Time()).Round(time.Second) / time.Second)
+}
+
+// post makes a POST request to the path authenticated with the root token,
+// failing the test unless it succeeds, and decodes its JSON response into v.
+func (s *testServer) post(t *testing.T, path, body string, v interface{}) {
+	t.Helper()
+	s.mustSend(t, http.MethodPost, path, body, v)
+}
+
+// put is like post with a PUT request.
+func (s *testServer) put(t *testing.T, path, body string, v interface{}) {
+	t.Helper()
+	s.mustSend(t, http.MethodPut, path, body, v)
+}
+
+// putPolicy writes the policy with the rules.
+func (s *testServer) putPolicy(t *testing.T, name, rules string) {
+	t.Helper()
+	s.put(t, "/sys/policy/"+name, fmt.Sprintf(`{"policy":%q}`, rules), &struct{}{})
+}
+
+// mustSend makes a request to the path authenticated with the root token,
+// failing the test unless it succeeds, and decodes its JSON response into v.
+func (s *testServer) mustSend(t *testing.T, method, path, body string, v interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.setHeader(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		t.Fatalf("%s %s: %s: %s", method, path, resp.Status, b)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// send makes a request to the path authenticated with the root token,
+// decoding a successful JSON response into v when it is not nil, and returns
+// the response status code.
+func (s *testServer) send(t *testing.T, method, path, body string, v interface{}) int {
+	t.Helper()
+	return sendAs(t, s.rootToken, method, s.URL+path, body, v)
+}
+
+func (s *testServer) setHeader(r *http.Request) {
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.rootToken))
+}
+
+// nextJWTID returns a new ID for an external JWT.
+func (s *testServer) nextJWTID() string {
+	s.jwtIDs++
+	return fmt.Sprintf("jwt-%d", s.jwtIDs)
+}
+
+// signJWT returns an external JWT of the subject ci with the ID, audience and
+// scope, valid for a minute.
+func (s *testServer) signJWT(t *testing.T, id, audience, scope string) string {
+	t.Helper()
+	tok := stdjwt.NewWithClaims(stdjwt.SigningMethodES256, jwks.Claims{
+		RegisteredClaims: stdjwt.RegisteredClaims{
+			Issuer:    "https://idp.example.com",
+			Subject:   "ci",
+			Audience:  stdjwt.ClaimStrings{audience},
+			ExpiresAt: stdjwt.NewNumericDate(time.Now().Add(time.Minute)),
+			ID:        id,
+		},
+		Scope: scope,
+	})
+	tok.Header["kid"] = "idp"
+	raw, err := tok.SignedString(s.idpKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// sendAs is like send with the request authenticated with tok.
+func sendAs(t *testing.T, tok, method, url, body string, v interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+tok)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// newJWKS generates the signing key of an external JWT issuer and writes its
+// JSON Web Key Set to a file.
+func newJWKS(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	set := fmt.Sprintf(`{"keys":[{"kty":"EC","kid":"idp","crv":"P-256","x":%q,"y":%q}]}`, b64(key.X.Bytes()), b64(key.Y.Bytes()))
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(path, []byte(set), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return key, path
+}
+
+// newClientCert generates a CA certificate and a client certificate it
+// issued with the URI SAN.
+func newClientCert(t *testing.T, uri string) (*x509.Certificate, tls.Certificate) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "clients"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "billing"},
+		URIs:         []*url.URL{u},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	raw, err = x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, tls.Certificate{Certificate: [][]byte{raw}, PrivateKey: key}
+}
+
+// oauthPost sends a form encoded OAuth2 request authenticated as the client,
+// decoding the JSON response into v when it is not nil, and returns the
+// response status code.
+func oauthPost(t *testing.T, url, clientID, clientSecret, form string, v interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(form))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(clientID, clientSecret)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if want, have := "no-store", resp.Header.Get("Cache-Control"); want != have {
+		t.Errorf("%s: Cache-Control: want %s, have %s", url, want, have)
+	}
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
 }
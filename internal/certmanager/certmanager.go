@@ -0,0 +1,116 @@
+// Package certmanager serves a TLS certificate reloaded from its files, so
+// that renewed certificates are picked up by the listeners of vaultd without
+// a restart. The certificate is reloaded on demand, such as on SIGHUP, and
+// when its files change.
+package certmanager
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+)
+
+// Manager holds the certificate loaded from a certificate and a key file.
+type Manager struct {
+	certFile, keyFile string
+	expiry            metrics.Gauge
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// New returns a Manager with the certificate of the PEM files loaded. The
+// expiry time of the certificate is set to the gauge, in seconds since the
+// epoch, on every load.
+func New(certFile, keyFile string, expiry metrics.Gauge) (*Manager, error) {
+	m := &Manager{certFile: certFile, keyFile: keyFile, expiry: expiry}
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Reload loads the certificate from its files again. The current certificate
+// is kept when the files cannot be read or do not hold a valid key pair.
+func (m *Manager) Reload() error {
+	modTime, err := m.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(m.certFile, m.keyFile)
+	if err != nil {
+		return err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+	cert.Leaf = leaf
+	m.expiry.Set(float64(leaf.NotAfter.Unix()))
+	m.mu.Lock()
+	m.cert, m.modTime = &cert, modTime
+	m.mu.Unlock()
+	return nil
+}
+
+// GetCertificate returns the current certificate, for tls.Config.
+func (m *Manager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cert, nil
+}
+
+// TLSConfig returns a TLS configuration serving the current certificate.
+func (m *Manager) TLSConfig() *tls.Config {
+	return &tls.Config{GetCertificate: m.GetCertificate, MinVersion: tls.VersionTLS12}
+}
+
+// Run reloads the certificate on every signal of reload, and when its files
+// were modified since the last load, checked every interval, until ctx is
+// done. Failed reloads are logged.
+func (m *Manager) Run(ctx context.Context, interval time.Duration, reload <-chan os.Signal, logger log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reload:
+		case <-ticker.C:
+			modTime, err := m.lastModified()
+			m.mu.RLock()
+			changed := err == nil && !modTime.Equal(m.modTime)
+			m.mu.RUnlock()
+			if !changed {
+				continue
+			}
+		}
+		if err := m.Reload(); err != nil {
+			logger.Log("during", "reload", "cert", m.certFile, "err", err)
+			continue
+		}
+		logger.Log("reloaded", m.certFile)
+	}
+}
+
+// lastModified returns the latest modification time of the files.
+func (m *Manager) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{m.certFile, m.keyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,115 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/generic"
+)
+
+func TestManager(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+	writeCert(t, certFile, keyFile, 1, notAfter)
+
+	expiry := generic.NewGauge("expiry")
+	m, err := New(certFile, keyFile, expiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := float64(notAfter.Unix()), expiry.Value(); want != have {
+		t.Errorf("expiry: want %v, have %v", want, have)
+	}
+	serial := func() int64 {
+		cert, err := m.GetCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert.Leaf.SerialNumber.Int64()
+	}
+
+	// Changed files are reloaded.
+	ctx, cancel := context.WithCancel(context.Background())
+	go m.Run(ctx, 10*time.Millisecond, nil, log.NewNopLogger())
+	writeCert(t, certFile, keyFile, 2, notAfter.Add(time.Hour))
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	waitFor(t, func() bool { return serial() == 2 })
+	cancel()
+	if want, have := float64(notAfter.Add(time.Hour).Unix()), expiry.Value(); want != have {
+		t.Errorf("expiry after reload: want %v, have %v", want, have)
+	}
+
+	// Invalid files keep the current certificate.
+	if err := ioutil.WriteFile(keyFile, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Reload(); err == nil {
+		t.Error("reload of an invalid key: want error")
+	}
+	if want, have := int64(2), serial(); want != have {
+		t.Errorf("serial after failed reload: want %d, have %d", want, have)
+	}
+
+	// Signals reload the files regardless of their modification time.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	reload := make(chan os.Signal)
+	go m.Run(ctx, time.Hour, reload, log.NewNopLogger())
+	writeCert(t, certFile, keyFile, 3, notAfter)
+	reload <- syscall.SIGHUP
+	waitFor(t, func() bool { return serial() == 3 })
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func writeCert(t *testing.T, certFile, keyFile string, serial int64, notAfter time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "vaultd"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
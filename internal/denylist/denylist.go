@@ -0,0 +1,199 @@
+// Package denylist refuses the JWTs revoked by an operator before they
+// expire, either one token by its ID (the jti claim) or every token of a
+// subject. Entries are kept in the encrypted storage, so that every replica
+// of vaultd refuses them, and forgotten through leases when they expire.
+package denylist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/williamlsh/vault/internal/lease"
+	"github.com/williamlsh/vault/internal/store"
+)
+
+// prefix is the storage key and lease path of the entries, followed by their
+// kind and escaped value.
+const prefix = "sys/denylist/"
+
+// The kinds of entries.
+const (
+	// KindTokenID denies the token with the ID.
+	KindTokenID = "jti"
+	// KindSubject denies every token of the subject.
+	KindSubject = "subject"
+)
+
+var (
+	// ErrDenied is returned when checking a denied token.
+	ErrDenied = errors.New("token denied")
+	// ErrInvalidEntry is returned when denying an entry of an unknown kind
+	// or without a value.
+	ErrInvalidEntry = errors.New("invalid denylist entry")
+	// ErrNotFound is returned when reading an entry not on the list.
+	ErrNotFound = errors.New("denylist entry not found")
+)
+
+// Entry is a denied token ID or subject.
+type Entry struct {
+	Kind         string    `json:"kind"`
+	Value        string    `json:"value"`
+	Reason       string    `json:"reason,omitempty"`
+	CreationTime time.Time `json:"creation_time"`
+	// ExpireTime is zero for entries which never expire. A token ID need not
+	// be denied past the expiry of its token.
+	ExpireTime time.Time `json:"expire_time,omitempty"`
+}
+
+// List is the list of the denied token IDs and subjects.
+type List struct {
+	storage store.Storage
+	leases  *lease.Manager
+}
+
+// New returns a List keeping its entries in the storage. Entries with an
+// expire time are forgotten through leases.
+func New(s store.Storage, leases *lease.Manager) *List {
+	l := &List{storage: s, leases: leases}
+	leases.Handle(prefix, l.forget)
+	return l
+}
+
+// Deny adds the entry to the list, replacing any previous one of the same
+// kind and value.
+func (l *List) Deny(ctx context.Context, e Entry) error {
+	if (e.Kind != KindTokenID && e.Kind != KindSubject) || e.Value == "" {
+		return ErrInvalidEntry
+	}
+	if e.CreationTime.IsZero() {
+		e.CreationTime = time.Now().UTC()
+	}
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	k := key(e.Kind, e.Value)
+	if err := l.storage.Put(ctx, k, raw); err != nil {
+		return err
+	}
+	if e.ExpireTime.IsZero() {
+		return nil
+	}
+	_, err = l.leases.Register(ctx, k, time.Until(e.ExpireTime))
+	return err
+}
+
+// Allow removes the entry of the kind and value from the list.
+func (l *List) Allow(ctx context.Context, kind, value string) error {
+	return l.storage.Delete(ctx, key(kind, value))
+}
+
+// Entry returns the entry of the kind and value, or ErrNotFound.
+func (l *List) Entry(ctx context.Context, kind, value string) (*Entry, error) {
+	raw, err := l.storage.Get(ctx, key(kind, value))
+	if err == store.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var e Entry
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Entries returns the unexpired entries, sorted by kind and value.
+func (l *List) Entries(ctx context.Context) ([]Entry, error) {
+	keys, err := l.storage.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	now := time.Now()
+	entries := []Entry{}
+	for _, k := range keys {
+		raw, err := l.storage.Get(ctx, prefix+k)
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var e Entry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, err
+		}
+		if e.expired(now) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Check returns ErrDenied if the token ID or the subject is denied. An empty
+// token ID or subject is not checked.
+func (l *List) Check(ctx context.Context, tokenID, subject string) error {
+	for _, e := range []struct{ kind, value string }{{KindTokenID, tokenID}, {KindSubject, subject}} {
+		if e.value == "" {
+			continue
+		}
+		entry, err := l.Entry(ctx, e.kind, e.value)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !entry.expired(time.Now()) {
+			return fmt.Errorf("%w: %s %s", ErrDenied, e.kind, e.value)
+		}
+	}
+	return nil
+}
+
+func (e *Entry) expired(now time.Time) bool {
+	return !e.ExpireTime.IsZero() && !e.ExpireTime.After(now)
+}
+
+// forget removes an entry once it has expired. Leases are capped by the
+// maximum lease TTL, so the entry is kept under a new lease if it is still
+// valid.
+func (l *List) forget(ctx context.Context, ls *lease.Lease) error {
+	// Lease IDs are sys/denylist/<kind>/<escaped value>/<random>.
+	k := ls.ID[:strings.LastIndex(ls.ID, "/")]
+	raw, err := l.storage.Get(ctx, k)
+	if err == store.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var e Entry
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return err
+	}
+	if e.ExpireTime.IsZero() {
+		// Denied again without expiry since the lease was registered.
+		return nil
+	}
+	if ttl := time.Until(e.ExpireTime); ttl > 0 {
+		_, err := l.leases.Register(ctx, k, ttl)
+		return err
+	}
+	return l.storage.Delete(ctx, k)
+}
+
+// key returns the storage key of an entry. Values are escaped, subjects
+// being URIs at times.
+func key(kind, value string) string {
+	return prefix + kind + "/" + url.PathEscape(value)
+}
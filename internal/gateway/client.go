@@ -0,0 +1,216 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/williamlsh/vault/internal/vaulterr"
+)
+
+// Client calls the annotated unary methods of gRPC services on a gateway
+// over HTTP, with the primary binding of their annotation.
+type Client struct {
+	base   *url.URL
+	client *http.Client
+}
+
+// NewClient returns a Client of the gateway serving its routes under the
+// base URL, such as https://vault:8200, sending the requests with client.
+func NewClient(base *url.URL, client *http.Client) *Client {
+	return &Client{base: base, client: client}
+}
+
+// Invoke calls the method, named /<service>/<method> as in gRPC, with the
+// request message and decodes its response message into reply. The fields
+// of the request bound to neither the path nor the body are sent as query
+// parameters, and the forwarded headers from the outgoing metadata of the
+// context. Errors are the typed errors of their problem details.
+func (c *Client) Invoke(ctx context.Context, method string, request, reply proto.Message) error {
+	rt, err := clientRoute(method)
+	if err != nil {
+		return err
+	}
+	r, err := rt.newRequest(ctx, c.base, request)
+	if err != nil {
+		return err
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for _, h := range forwardedHeaders {
+		for _, v := range md.Get(h) {
+			r.Header.Add(h, v)
+		}
+	}
+	resp, err := c.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return readError(resp, body)
+	}
+	return replyOptions.Unmarshal(body, reply)
+}
+
+// replyOptions decode the responses, ignoring the fields added to them by
+// newer servers.
+var replyOptions = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+// clientRoute returns the route of the primary binding of the method.
+func clientRoute(method string) (route, error) {
+	name := strings.Replace(strings.TrimPrefix(method, "/"), "/", ".", 1)
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return route{}, fmt.Errorf("gateway: method %s: %v", method, err)
+	}
+	md, ok := d.(protoreflect.MethodDescriptor)
+	if !ok {
+		return route{}, fmt.Errorf("gateway: %s is not a method", method)
+	}
+	rule, _ := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+	if rule == nil {
+		return route{}, fmt.Errorf("gateway: method %s has no google.api.http annotation", method)
+	}
+	return newRoute(rule, md, nil, nil)
+}
+
+// newRequest returns the HTTP request of the route for the message: the
+// path variables are expanded from its fields, the body is encoded as JSON
+// and the other fields are set as query parameters.
+func (rt route) newRequest(ctx context.Context, base *url.URL, msg proto.Message) (*http.Request, error) {
+	m := msg.ProtoReflect()
+	path, err := rt.path.expand(m)
+	if err != nil {
+		return nil, err
+	}
+	u := *base
+	u.RawPath = strings.TrimSuffix(base.EscapedPath(), "/") + path
+	if u.Path, err = url.PathUnescape(u.RawPath); err != nil {
+		return nil, err
+	}
+	bound := map[string]bool{}
+	for _, f := range rt.path.fields() {
+		bound[strings.SplitN(f, ".", 2)[0]] = true
+	}
+
+	var body []byte
+	switch rt.body {
+	case "":
+	case "*":
+		if body, err = marshalOptions.Marshal(msg); err != nil {
+			return nil, err
+		}
+	default:
+		fd := m.Descriptor().Fields().ByName(protoreflect.Name(rt.body))
+		if body, err = marshalOptions.Marshal(m.Get(fd).Message().Interface()); err != nil {
+			return nil, err
+		}
+		bound[rt.body] = true
+	}
+	if rt.body != "*" {
+		query := url.Values{}
+		m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			name := string(fd.Name())
+			if bound[name] {
+				return true
+			}
+			if fd.IsMap() || fd.Message() != nil {
+				err = fmt.Errorf("gateway: field %q of %s is not a scalar", name, m.Descriptor().FullName())
+				return false
+			}
+			if !fd.IsList() {
+				query.Add(name, formatScalar(fd, v))
+				return true
+			}
+			for i, l := 0, v.List(); i < l.Len(); i++ {
+				query.Add(name, formatScalar(fd, l.Get(i)))
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	r, err := http.NewRequestWithContext(ctx, rt.verb, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return r, nil
+}
+
+// readError returns the typed error of the problem details of the response.
+func readError(resp *http.Response, body []byte) error {
+	var p vaulterr.Problem
+	if err := json.Unmarshal(body, &p); err != nil {
+		return vaulterr.New(vaulterr.FromHTTPStatus(resp.StatusCode), resp.Status)
+	}
+	if p.Status == 0 {
+		p.Status = resp.StatusCode
+	}
+	return p.Err()
+}
+
+// getField returns the scalar field at the path of the message.
+func getField(m protoreflect.Message, path string) (string, error) {
+	fds, err := fieldPath(m.Descriptor(), path)
+	if err != nil {
+		return "", err
+	}
+	for _, fd := range fds[:len(fds)-1] {
+		m = m.Get(fd).Message()
+	}
+	fd := fds[len(fds)-1]
+	if fd.IsList() || fd.IsMap() || fd.Message() != nil {
+		return "", fmt.Errorf("field %q is not a scalar", path)
+	}
+	return formatScalar(fd, m.Get(fd)), nil
+}
+
+// formatScalar formats a scalar value as parseScalar parses it.
+func formatScalar(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(v.Bool())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(v.Int(), 10)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(v.Uint(), 10)
+	case protoreflect.FloatKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32)
+	case protoreflect.DoubleKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(v.Bytes())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return strconv.Itoa(int(v.Enum()))
+	}
+	return v.String()
+}
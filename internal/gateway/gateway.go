@@ -0,0 +1,379 @@
+// Package gateway serves the unary methods of gRPC services over HTTP with
+// JSON bodies, routed by the google.api.http annotations of their methods in
+// the proto, and describes them with an OpenAPI 3 document. The methods are
+// invoked in process on the same implementations the gRPC server serves, so
+// that both APIs return the same responses and errors. Client calls the
+// methods over HTTP with the same annotations.
+package gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/williamlsh/vault/internal/vaulterr"
+)
+
+// forwardedHeaders are the HTTP headers passed to the methods as metadata.
+var forwardedHeaders = []string{"Authorization"}
+
+// maxBodySize is the size limit of the request bodies.
+const maxBodySize = 1 << 20
+
+// Gateway routes HTTP requests to the annotated unary methods of the
+// services registered with it. It implements grpc.ServiceRegistrar, so that
+// the generated registration functions register services with it as with a
+// gRPC server.
+type Gateway struct {
+	mu     sync.RWMutex
+	routes []route
+}
+
+// route is an HTTP binding of a method.
+type route struct {
+	verb    string
+	path    template
+	body    string
+	method  protoreflect.MethodDescriptor
+	handler methodHandler
+	impl    interface{}
+}
+
+// methodHandler is the type of the Handler of the grpc.MethodDesc.
+type methodHandler = func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error)
+
+// New returns a Gateway without services.
+func New() *Gateway {
+	return &Gateway{}
+}
+
+// RegisterService routes the HTTP bindings of the unary methods of the
+// service to impl. Methods without a google.api.http annotation, and
+// streaming methods, are not routed. It panics if the service is not in the
+// global proto registry or if an annotation is invalid, like gRPC servers do
+// on invalid registrations.
+func (g *Gateway) RegisterService(desc *grpc.ServiceDesc, impl interface{}) {
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(desc.ServiceName))
+	if err != nil {
+		panic(fmt.Sprintf("gateway: service %s: %v", desc.ServiceName, err))
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		panic(fmt.Sprintf("gateway: %s is not a service", desc.ServiceName))
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, m := range desc.Methods {
+		md := sd.Methods().ByName(protoreflect.Name(m.MethodName))
+		if md == nil {
+			panic(fmt.Sprintf("gateway: no method %s in service %s", m.MethodName, desc.ServiceName))
+		}
+		rule, _ := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+		if rule == nil {
+			continue
+		}
+		for _, r := range append([]*annotations.HttpRule{rule}, rule.AdditionalBindings...) {
+			rt, err := newRoute(r, md, m.Handler, impl)
+			if err != nil {
+				panic(fmt.Sprintf("gateway: method %s: %v", md.FullName(), err))
+			}
+			g.routes = append(g.routes, rt)
+		}
+	}
+}
+
+func newRoute(rule *annotations.HttpRule, md protoreflect.MethodDescriptor, handler methodHandler, impl interface{}) (route, error) {
+	var verb, path string
+	switch p := rule.Pattern.(type) {
+	case *annotations.HttpRule_Get:
+		verb, path = http.MethodGet, p.Get
+	case *annotations.HttpRule_Put:
+		verb, path = http.MethodPut, p.Put
+	case *annotations.HttpRule_Post:
+		verb, path = http.MethodPost, p.Post
+	case *annotations.HttpRule_Delete:
+		verb, path = http.MethodDelete, p.Delete
+	case *annotations.HttpRule_Patch:
+		verb, path = http.MethodPatch, p.Patch
+	case *annotations.HttpRule_Custom:
+		verb, path = p.Custom.Kind, p.Custom.Path
+	default:
+		return route{}, fmt.Errorf("no pattern")
+	}
+	t, err := parseTemplate(path)
+	if err != nil {
+		return route{}, err
+	}
+	for _, f := range t.fields() {
+		if _, err := fieldPath(md.Input(), f); err != nil {
+			return route{}, err
+		}
+	}
+	if rule.Body != "" && rule.Body != "*" {
+		fd := md.Input().Fields().ByName(protoreflect.Name(rule.Body))
+		if fd == nil || fd.Message() == nil || fd.IsList() || fd.IsMap() {
+			return route{}, fmt.Errorf("body %q is not a message field", rule.Body)
+		}
+	}
+	return route{verb: verb, path: t, body: rule.Body, method: md, handler: handler, impl: impl}, nil
+}
+
+// ServeHTTP serves a request with the first route matching its method and
+// path. The JSON body and the path and query parameters of the request are
+// decoded into the request message of the method, and its response message
+// is encoded as JSON with the field names of the proto. Errors are encoded as
+// problem details, with the HTTP status of their gRPC status.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.RLock()
+	routes := g.routes
+	g.mu.RUnlock()
+
+	var allowed []string
+	for _, rt := range routes {
+		vars, ok := rt.path.match(r.URL.EscapedPath())
+		if !ok {
+			continue
+		}
+		if rt.verb != r.Method {
+			allowed = append(allowed, rt.verb)
+			continue
+		}
+		rt.serve(w, r, vars)
+		return
+	}
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, vaulterr.Errorf(vaulterr.InvalidArgument, "method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	writeError(w, vaulterr.Errorf(vaulterr.NotFound, "no route for %s", r.URL.Path), 0)
+}
+
+// Unversioned returns a handler serving the routes of the gateway on their
+// paths without the version prefix, such as /kv/data/app for
+// /v1/kv/data/app, so that the unversioned paths of the API are the ones of
+// the annotations too.
+func (g *Gateway) Unversioned(prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = prefix + r.URL.Path
+		if r.URL.RawPath != "" {
+			r2.URL.RawPath = prefix + r.URL.RawPath
+		}
+		g.ServeHTTP(w, r2)
+	})
+}
+
+func (rt route) serve(w http.ResponseWriter, r *http.Request, vars map[string]string) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	var decodeErr error
+	dec := func(v interface{}) error {
+		decodeErr = rt.decode(r, vars, v.(proto.Message))
+		return decodeErr
+	}
+	resp, err := rt.handler(rt.impl, incomingContext(r), dec, nil)
+	if err != nil {
+		if decodeErr != nil {
+			err = vaulterr.Wrap(vaulterr.InvalidArgument, err)
+		}
+		writeError(w, vaulterr.FromGRPCError(err), 0)
+		return
+	}
+	body, err := marshalOptions.Marshal(resp.(proto.Message))
+	if err != nil {
+		writeError(w, vaulterr.Wrap(vaulterr.Internal, err), 0)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+var (
+	marshalOptions   = protojson.MarshalOptions{UseProtoNames: true}
+	unmarshalOptions = protojson.UnmarshalOptions{}
+)
+
+// decode decodes the request into the request message of the method: the
+// body first, then the query parameters for the fields bound to neither the
+// body nor the path, and the path variables last.
+func (rt route) decode(r *http.Request, vars map[string]string, msg proto.Message) error {
+	m := msg.ProtoReflect()
+	if rt.body != "" {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		if len(b) > 0 {
+			target := m
+			if rt.body != "*" {
+				target = m.Mutable(m.Descriptor().Fields().ByName(protoreflect.Name(rt.body))).Message()
+			}
+			if err := unmarshalOptions.Unmarshal(b, target.Interface()); err != nil {
+				return fmt.Errorf("invalid body: %w", err)
+			}
+		}
+	}
+	if rt.body != "*" {
+		for key, values := range r.URL.Query() {
+			if _, ok := vars[key]; ok || key == rt.body {
+				continue
+			}
+			if err := setField(m, key, values); err != nil {
+				return err
+			}
+		}
+	}
+	for key, value := range vars {
+		if err := setField(m, key, []string{value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// incomingContext returns the context of a method serving the request, with
+// the forwarded headers as incoming metadata and the client address and TLS
+// state as the peer.
+func incomingContext(r *http.Request) context.Context {
+	md := metadata.MD{}
+	for _, h := range forwardedHeaders {
+		if v := r.Header.Values(h); len(v) > 0 {
+			md.Set(h, v...)
+		}
+	}
+	p := &peer.Peer{Addr: remoteAddr(r.RemoteAddr)}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{State: *r.TLS}
+	}
+	return peer.NewContext(metadata.NewIncomingContext(r.Context(), md), p)
+}
+
+// remoteAddr is the address of an HTTP client.
+type remoteAddr string
+
+func (a remoteAddr) Network() string { return "tcp" }
+func (a remoteAddr) String() string  { return string(a) }
+
+// writeError writes the problem details of err, with the status, or the
+// status of its code if zero.
+func writeError(w http.ResponseWriter, err error, status int) {
+	p := vaulterr.ProblemOf(err)
+	if status != 0 {
+		p.Status, p.Title = status, http.StatusText(status)
+	}
+	w.Header().Set("Content-Type", vaulterr.ProblemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// fieldPath resolves a dot separated path of fields of the message.
+func fieldPath(md protoreflect.MessageDescriptor, path string) ([]protoreflect.FieldDescriptor, error) {
+	var fds []protoreflect.FieldDescriptor
+	for i, name := range strings.Split(path, ".") {
+		if i > 0 {
+			prev := fds[i-1]
+			if prev.Message() == nil || prev.IsList() || prev.IsMap() {
+				return nil, fmt.Errorf("field %q of %s is not a message", prev.Name(), md.FullName())
+			}
+			md = prev.Message()
+		}
+		fd := md.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = md.Fields().ByJSONName(name)
+		}
+		if fd == nil {
+			return nil, fmt.Errorf("no field %q in %s", name, md.FullName())
+		}
+		fds = append(fds, fd)
+	}
+	return fds, nil
+}
+
+// setField sets the scalar field at the path of the message to the values,
+// appended if the field is repeated.
+func setField(m protoreflect.Message, path string, values []string) error {
+	fds, err := fieldPath(m.Descriptor(), path)
+	if err != nil {
+		return err
+	}
+	for _, fd := range fds[:len(fds)-1] {
+		m = m.Mutable(fd).Message()
+	}
+	fd := fds[len(fds)-1]
+	if fd.IsMap() || fd.Message() != nil {
+		return fmt.Errorf("field %q is not a scalar", path)
+	}
+	if !fd.IsList() && len(values) > 1 {
+		return fmt.Errorf("field %q is not repeated", path)
+	}
+	for _, s := range values {
+		v, err := parseScalar(fd, s)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", path, err)
+		}
+		if fd.IsList() {
+			m.Mutable(fd).List().Append(v)
+		} else {
+			m.Set(fd, v)
+		}
+	}
+	return nil
+}
+
+func parseScalar(fd protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BoolKind:
+		v, err := strconv.ParseBool(s)
+		return protoreflect.ValueOfBool(v), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfInt32(int32(v)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := strconv.ParseInt(s, 10, 64)
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := strconv.ParseUint(s, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(v)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := strconv.ParseUint(s, 10, 64)
+		return protoreflect.ValueOfUint64(v), err
+	case protoreflect.FloatKind:
+		v, err := strconv.ParseFloat(s, 32)
+		return protoreflect.ValueOfFloat32(float32(v)), err
+	case protoreflect.DoubleKind:
+		v, err := strconv.ParseFloat(s, 64)
+		return protoreflect.ValueOfFloat64(v), err
+	case protoreflect.BytesKind:
+		v, err := base64.StdEncoding.DecodeString(s)
+		return protoreflect.ValueOfBytes(v), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		v, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), err
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported kind %s", fd.Kind())
+}
@@ -0,0 +1,267 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/williamlsh/vault/internal/vaulterr"
+	"github.com/williamlsh/vault/pb"
+)
+
+func TestTemplate(t *testing.T) {
+	for _, tc := range []struct {
+		template, path string
+		want           map[string]string
+	}{
+		{"/v1/kv/data/{path=**}", "/v1/kv/data/app/db", map[string]string{"path": "app/db"}},
+		{"/v1/kv/data/{path=**}", "/v1/kv/data/", map[string]string{"path": ""}},
+		{"/v1/kv/data/{path=**}", "/v1/kv/metadata/app", nil},
+		{"/v1/sys/policy/{name}", "/v1/sys/policy/ops%2Fadmin", map[string]string{"name": "ops/admin"}},
+		{"/v1/sys/policy/{name}", "/v1/sys/policy/ops/admin", nil},
+		{"/v1/auth/approle/role/{name}/secret-id", "/v1/auth/approle/role/web/secret-id", map[string]string{"name": "web"}},
+		{"/v1/sys/denylist/{kind}/{value=**}", "/v1/sys/denylist/token/s.abc", map[string]string{"kind": "token", "value": "s.abc"}},
+		{"/v1/*/{a.b=x/*}", "", nil},
+	} {
+		tmpl, err := parseTemplate(tc.template)
+		if tc.path == "" {
+			if err == nil {
+				t.Errorf("%s: parsed an unsupported template", tc.template)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.template, err)
+		}
+		vars, ok := tmpl.match(tc.path)
+		if ok != (tc.want != nil) {
+			t.Errorf("%s %s: want match %t, have %t", tc.template, tc.path, tc.want != nil, ok)
+			continue
+		}
+		for k, v := range tc.want {
+			if vars[k] != v {
+				t.Errorf("%s %s: %s: want %q, have %q", tc.template, tc.path, k, v, vars[k])
+			}
+		}
+	}
+	if _, err := parseTemplate("/v1/{path=**}/data"); err == nil {
+		t.Error("parsed ** before the last segment")
+	}
+}
+
+// kvServer stores the secrets of the put requests, refusing the requests
+// without an authorization header.
+type kvServer struct {
+	pb.UnimplementedKVServer
+	secrets map[string]map[string]string
+}
+
+func (s *kvServer) Put(ctx context.Context, req *pb.KVPutRequest) (*pb.KVPutResponse, error) {
+	if md, _ := metadata.FromIncomingContext(ctx); len(md.Get("authorization")) == 0 {
+		return nil, vaulterr.New(vaulterr.Unauthenticated, "missing token")
+	}
+	s.secrets[req.Path] = req.Data
+	return &pb.KVPutResponse{Metadata: &pb.KVVersionMetadata{Version: 1}}, nil
+}
+
+func (s *kvServer) Get(_ context.Context, req *pb.KVGetRequest) (*pb.KVGetResponse, error) {
+	data, ok := s.secrets[req.Path]
+	if !ok || req.Version > 1 {
+		return nil, vaulterr.Errorf(vaulterr.NotFound, "no secret at %s", req.Path)
+	}
+	return &pb.KVGetResponse{Data: data, Metadata: &pb.KVVersionMetadata{Version: 1, CreatedTime: 1}}, nil
+}
+
+func TestGateway(t *testing.T) {
+	gw := New()
+	pb.RegisterKVServer(gw, &kvServer{secrets: map[string]map[string]string{}})
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+		want               string
+	}{
+		{http.MethodPost, "/v1/kv/data/app/db", `{"data":{"user":"admin"}}`, http.StatusOK, `{"metadata":{"version":1}}`},
+		{http.MethodGet, "/v1/kv/data/app/db", "", http.StatusOK, `{"data":{"user":"admin"},"metadata":{"version":1,"created_time":"1"}}`},
+		{http.MethodGet, "/v1/kv/data/app/db?version=2", "", http.StatusNotFound, ""},
+		{http.MethodGet, "/v1/kv/data/app/db?version=x", "", http.StatusBadRequest, ""},
+		{http.MethodGet, "/v1/kv/data/app/db?unknown=1", "", http.StatusBadRequest, ""},
+		{http.MethodPost, "/v1/kv/data/app/db", `{"unknown":1}`, http.StatusBadRequest, ""},
+		{http.MethodPut, "/v1/kv/data/app/db", "", http.StatusMethodNotAllowed, ""},
+		{http.MethodGet, "/v1/kv/list/app", "", http.StatusInternalServerError, ""},
+		{http.MethodGet, "/v1/pki/roles", "", http.StatusNotFound, ""},
+	} {
+		req, err := http.NewRequest(tc.method, srv.URL+tc.path, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer s.root")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body json.RawMessage
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s %s: %v", tc.method, tc.path, err)
+		}
+		if want, have := tc.status, resp.StatusCode; want != have {
+			t.Errorf("%s %s: want %d, have %d %s", tc.method, tc.path, want, have, body)
+			continue
+		}
+		if tc.status != http.StatusOK {
+			var p vaulterr.Problem
+			if err := json.Unmarshal(body, &p); err != nil || p.Status != tc.status || resp.Header.Get("Content-Type") != vaulterr.ProblemContentType {
+				t.Errorf("%s %s: problem details: have %s", tc.method, tc.path, body)
+			}
+			continue
+		}
+		// protojson randomizes the white space of its output.
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, body); err != nil {
+			t.Fatal(err)
+		}
+		if want, have := tc.want, compact.String(); want != have {
+			t.Errorf("%s %s: want %s, have %s", tc.method, tc.path, want, have)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/kv/data/app/db", strings.NewReader(`{}`))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want, have := http.StatusUnauthorized, resp.StatusCode; want != have {
+		t.Errorf("put without authorization: want %d, have %d", want, have)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	gw := New()
+	pb.RegisterKVServer(gw, &kvServer{})
+	raw, err := json.Marshal(gw.OpenAPI("vault", "v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Parameters  []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+			RequestBody *struct{} `json:"requestBody"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]struct {
+					Type   string `json:"type"`
+					Format string `json:"format"`
+				} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := OpenAPIVersion, doc.OpenAPI; want != have {
+		t.Errorf("version: want %s, have %s", want, have)
+	}
+	data := doc.Paths["/v1/kv/data/{path}"]
+	if want, have := "KV_Put", data["post"].OperationID; want != have {
+		t.Errorf("put operation: want %s, have %s", want, have)
+	}
+	if data["post"].RequestBody == nil {
+		t.Error("put: no request body")
+	}
+	var params []string
+	for _, p := range data["get"].Parameters {
+		params = append(params, p.In+":"+p.Name)
+	}
+	if want, have := "path:path query:version", strings.Join(params, " "); want != have {
+		t.Errorf("get parameters: want %s, have %s", want, have)
+	}
+	created := doc.Components.Schemas["pb.KVVersionMetadata"].Properties["created_time"]
+	if created.Type != "string" || created.Format != "int64" {
+		t.Errorf("int64 schema: have %+v", created)
+	}
+}
+
+func TestUnversioned(t *testing.T) {
+	gw := New()
+	pb.RegisterKVServer(gw, &kvServer{secrets: map[string]map[string]string{"app/db": {"user": "admin"}}})
+	srv := httptest.NewServer(gw.Unversioned("/v1"))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodGet, "/kv/data/app/db", "", http.StatusOK},
+		{http.MethodGet, "/v1/kv/data/app/db", "", http.StatusNotFound},
+		{http.MethodPost, "/kv/data/app/db", `{"data":{"user":"` + strings.Repeat("x", maxBodySize) + `"}}`, http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest(tc.method, srv.URL+tc.path, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer s.root")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if want, have := tc.want, resp.StatusCode; want != have {
+			t.Errorf("%s %s: want %d, have %d", tc.method, tc.path, want, have)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	gw := New()
+	pb.RegisterKVServer(gw, &kvServer{secrets: map[string]map[string]string{}})
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+	base, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(base, srv.Client())
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("authorization", "Bearer s.root"))
+
+	var put pb.KVPutResponse
+	if err := c.Invoke(ctx, "/pb.KV/Put", &pb.KVPutRequest{Path: "app/db name", Data: map[string]string{"user": "admin"}}, &put); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int32(1), put.Metadata.GetVersion(); want != have {
+		t.Errorf("put version: want %d, have %d", want, have)
+	}
+	var get pb.KVGetResponse
+	if err := c.Invoke(ctx, "/pb.KV/Get", &pb.KVGetRequest{Path: "app/db name", Version: 1}, &get); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "admin", get.Data["user"]; want != have {
+		t.Errorf("get: want %q, have %q", want, have)
+	}
+	if err := c.Invoke(ctx, "/pb.KV/Get", &pb.KVGetRequest{Path: "app/db name", Version: 2}, &get); !vaulterr.Is(err, vaulterr.NotFound) {
+		t.Errorf("get missing version: want %s, have %v", vaulterr.NotFound, err)
+	}
+	if err := c.Invoke(context.Background(), "/pb.KV/Put", &pb.KVPutRequest{Path: "app/db"}, &put); !vaulterr.Is(err, vaulterr.Unauthenticated) {
+		t.Errorf("put without authorization: want %s, have %v", vaulterr.Unauthenticated, err)
+	}
+	if err := c.Invoke(ctx, "/pb.Vault/BatchHash", &pb.BatchHashRequest{}, &pb.BatchHashResponse{}); err == nil {
+		t.Error("invoked a method without annotation")
+	}
+}
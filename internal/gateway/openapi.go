@@ -0,0 +1,216 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification of the
+// documents.
+const OpenAPIVersion = "3.0.3"
+
+// problemSchema is the schema of the problem details of the errors.
+const problemSchema = "Problem"
+
+// OpenAPI returns the OpenAPI document of the routes of the gateway, with the
+// title and version as its info.
+func (g *Gateway) OpenAPI(title, version string) map[string]interface{} {
+	g.mu.RLock()
+	routes := g.routes
+	g.mu.RUnlock()
+
+	paths := map[string]interface{}{}
+	schemas := map[string]interface{}{
+		problemSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"type":   map[string]interface{}{"type": "string"},
+				"title":  map[string]interface{}{"type": "string"},
+				"status": map[string]interface{}{"type": "integer"},
+				"detail": map[string]interface{}{"type": "string"},
+				"code":   map[string]interface{}{"type": "string"},
+				"error":  map[string]interface{}{"type": "string"},
+			},
+		},
+	}
+	operationIDs := map[string]int{}
+	for _, rt := range routes {
+		p := rt.path.openAPIPath()
+		item, _ := paths[p].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[p] = item
+		}
+		item[strings.ToLower(rt.verb)] = rt.operation(schemas, operationIDs)
+	}
+	return map[string]interface{}{
+		"openapi": OpenAPIVersion,
+		"info":    map[string]interface{}{"title": title, "version": version},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"bearerAuth": []string{}}},
+	}
+}
+
+// OpenAPIHandler returns a handler serving the OpenAPI document of the
+// gateway as JSON.
+func (g *Gateway) OpenAPIHandler(title, version string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(g.OpenAPI(title, version))
+	})
+}
+
+// operation returns the operation object of the route, adding the schemas of
+// its messages. Additional bindings get operation IDs with a numeric suffix.
+func (rt route) operation(schemas map[string]interface{}, operationIDs map[string]int) map[string]interface{} {
+	service := string(rt.method.Parent().Name())
+	id := service + "_" + string(rt.method.Name())
+	if n := operationIDs[id]; n > 0 {
+		operationIDs[id]++
+		id += "_" + strconv.Itoa(n)
+	} else {
+		operationIDs[id] = 1
+	}
+	op := map[string]interface{}{
+		"operationId": id,
+		"tags":        []string{service},
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "A successful response.",
+				"content":     jsonContent(messageSchema(rt.method.Output(), schemas)),
+			},
+			"default": map[string]interface{}{
+				"description": "An error response.",
+				"content": map[string]interface{}{
+					"application/problem+json": map[string]interface{}{"schema": ref(problemSchema)},
+				},
+			},
+		},
+	}
+
+	in := rt.method.Input()
+	var params []interface{}
+	bound := map[string]bool{}
+	for _, f := range rt.path.fields() {
+		bound[strings.SplitN(f, ".", 2)[0]] = true
+		fds, _ := fieldPath(in, f)
+		params = append(params, map[string]interface{}{
+			"name":     f,
+			"in":       "path",
+			"required": true,
+			"schema":   fieldSchema(fds[len(fds)-1], schemas),
+		})
+	}
+	switch rt.body {
+	case "":
+		fields := in.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			if bound[string(fd.Name())] || fd.IsMap() || fd.Message() != nil {
+				continue
+			}
+			params = append(params, map[string]interface{}{
+				"name":   string(fd.Name()),
+				"in":     "query",
+				"schema": fieldSchema(fd, schemas),
+			})
+		}
+	case "*":
+		op["requestBody"] = map[string]interface{}{"content": jsonContent(messageSchema(in, schemas))}
+	default:
+		fd := in.Fields().ByName(protoreflect.Name(rt.body))
+		op["requestBody"] = map[string]interface{}{"content": jsonContent(messageSchema(fd.Message(), schemas))}
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	return op
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+func ref(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// messageSchema adds the schema of the message, and of the messages of its
+// fields, to the schemas, and returns a reference to it.
+func messageSchema(md protoreflect.MessageDescriptor, schemas map[string]interface{}) map[string]interface{} {
+	name := string(md.FullName())
+	if _, ok := schemas[name]; ok {
+		return ref(name)
+	}
+	properties := map[string]interface{}{}
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	// Added before its fields, for the recursive messages.
+	schemas[name] = schema
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		properties[string(fd.Name())] = fieldSchema(fd, schemas)
+	}
+	return ref(name)
+}
+
+// fieldSchema returns the schema of the field as encoded by protojson.
+func fieldSchema(fd protoreflect.FieldDescriptor, schemas map[string]interface{}) map[string]interface{} {
+	if fd.IsMap() {
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": valueSchema(fd.MapValue(), schemas),
+		}
+	}
+	if fd.IsList() {
+		return map[string]interface{}{"type": "array", "items": valueSchema(fd, schemas)}
+	}
+	return valueSchema(fd, schemas)
+}
+
+// valueSchema returns the schema of a single value of the field.
+func valueSchema(fd protoreflect.FieldDescriptor, schemas map[string]interface{}) map[string]interface{} {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageSchema(fd.Message(), schemas)
+	case protoreflect.EnumKind:
+		var values []string
+		for i, evs := 0, fd.Enum().Values(); i < evs.Len(); i++ {
+			values = append(values, string(evs.Get(i).Name()))
+		}
+		sort.Strings(values)
+		return map[string]interface{}{"type": "string", "enum": values}
+	case protoreflect.BoolKind:
+		return map[string]interface{}{"type": "boolean"}
+	case protoreflect.StringKind:
+		return map[string]interface{}{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]interface{}{"type": "string", "format": "byte"}
+	case protoreflect.FloatKind:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		// protojson encodes the 64 bit integers as strings.
+		return map[string]interface{}{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]interface{}{"type": "string", "format": "uint64"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]interface{}{"type": "integer", "format": "int64", "minimum": 0}
+	default:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	}
+}
@@ -0,0 +1,192 @@
+package gateway
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// template is a parsed google.api.http path template, such as
+// /v1/kv/data/{path=**}. Variables capture one segment by default, and any
+// number of trailing segments with **.
+type template struct {
+	raw      string
+	segments []segment
+}
+
+type segment struct {
+	// literal is the literal segment, empty for a wildcard.
+	literal string
+	// deep matches any number of segments, and is always the last one.
+	deep bool
+	// field is the field path of the variable capturing the segment, if any.
+	field string
+}
+
+// parseTemplate parses a path template. Verbs, as in /v1/a:verb, and
+// variables with literal segments are not supported.
+func parseTemplate(raw string) (template, error) {
+	t := template{raw: raw}
+	if !strings.HasPrefix(raw, "/") {
+		return t, fmt.Errorf("template %q: no leading slash", raw)
+	}
+	rest := raw[1:]
+	for rest != "" {
+		var part string
+		if strings.HasPrefix(rest, "{") {
+			end := strings.IndexByte(rest, '}')
+			if end < 0 {
+				return t, fmt.Errorf("template %q: unterminated variable", raw)
+			}
+			part, rest = rest[1:end], strings.TrimPrefix(rest[end+1:], "/")
+			field, pattern := part, "*"
+			if i := strings.IndexByte(part, '='); i >= 0 {
+				field, pattern = part[:i], part[i+1:]
+			}
+			for _, p := range strings.Split(pattern, "/") {
+				switch p {
+				case "*":
+					t.segments = append(t.segments, segment{field: field})
+				case "**":
+					t.segments = append(t.segments, segment{field: field, deep: true})
+				default:
+					return t, fmt.Errorf("template %q: unsupported variable pattern %q", raw, pattern)
+				}
+			}
+			continue
+		}
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			part, rest = rest[:i], rest[i+1:]
+		} else {
+			part, rest = rest, ""
+		}
+		switch {
+		case part == "" || strings.ContainsAny(part, "{}:"):
+			return t, fmt.Errorf("template %q: invalid segment %q", raw, part)
+		case part == "*":
+			t.segments = append(t.segments, segment{})
+		case part == "**":
+			t.segments = append(t.segments, segment{deep: true})
+		default:
+			t.segments = append(t.segments, segment{literal: part})
+		}
+	}
+	for i, s := range t.segments {
+		if s.deep && i != len(t.segments)-1 {
+			return t, fmt.Errorf("template %q: ** is not the last segment", raw)
+		}
+	}
+	return t, nil
+}
+
+// match matches the escaped path against the template, returning the values
+// of its variables by field path.
+func (t template) match(path string) (map[string]string, bool) {
+	path = strings.Trim(path, "/")
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+	vars := make(map[string]string)
+	capture := func(field string, values []string) bool {
+		for i, v := range values {
+			u, err := url.PathUnescape(v)
+			if err != nil {
+				return false
+			}
+			values[i] = u
+		}
+		if field == "" {
+			return true
+		}
+		v := strings.Join(values, "/")
+		if prev, ok := vars[field]; ok {
+			v = prev + "/" + v
+		}
+		vars[field] = v
+		return true
+	}
+	for i, s := range t.segments {
+		if s.deep {
+			return vars, capture(s.field, append([]string(nil), parts[i:]...))
+		}
+		if i >= len(parts) || (s.literal != "" && parts[i] != s.literal) {
+			return nil, false
+		}
+		if s.literal == "" && !capture(s.field, []string{parts[i]}) {
+			return nil, false
+		}
+	}
+	return vars, len(parts) == len(t.segments)
+}
+
+// expand returns the escaped path of the template with the variables set to
+// the fields of the message. A variable capturing several segments keeps the
+// slashes of its value, and a single segment one escapes them.
+func (t template) expand(m protoreflect.Message) (string, error) {
+	var b strings.Builder
+	for i, s := range t.segments {
+		if s.field == "" {
+			if s.literal == "" {
+				return "", fmt.Errorf("template %q: wildcard without variable", t.raw)
+			}
+			b.WriteString("/" + s.literal)
+			continue
+		}
+		if i > 0 && t.segments[i-1].field == s.field {
+			continue
+		}
+		v, err := getField(m, s.field)
+		if err != nil {
+			return "", err
+		}
+		single := !s.deep && (i+1 == len(t.segments) || t.segments[i+1].field != s.field)
+		if single {
+			b.WriteString("/" + url.PathEscape(v))
+			continue
+		}
+		parts := strings.Split(v, "/")
+		for j, p := range parts {
+			parts[j] = url.PathEscape(p)
+		}
+		b.WriteString("/" + strings.Join(parts, "/"))
+	}
+	return b.String(), nil
+}
+
+// fields returns the field paths of the variables of the template.
+func (t template) fields() []string {
+	var fields []string
+	for _, s := range t.segments {
+		if s.field != "" && (len(fields) == 0 || fields[len(fields)-1] != s.field) {
+			fields = append(fields, s.field)
+		}
+	}
+	return fields
+}
+
+// openAPIPath returns the path of the template in OpenAPI, with each
+// variable as a single path parameter.
+func (t template) openAPIPath() string {
+	var b strings.Builder
+	for i, s := range t.segments {
+		if s.field != "" {
+			if i > 0 && t.segments[i-1].field == s.field {
+				continue
+			}
+			b.WriteString("/{" + s.field + "}")
+			continue
+		}
+		switch {
+		case s.deep:
+			b.WriteString("/**")
+		case s.literal == "":
+			b.WriteString("/*")
+		default:
+			b.WriteString("/" + s.literal)
+		}
+	}
+	return b.String()
+}
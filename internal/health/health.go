@@ -0,0 +1,139 @@
+// Package health reports the serving status of vaultd through the standard
+// grpc.health.v1 Health service. The status of the server, the empty service
+// name, reflects the checks of its dependencies, such as the store and the
+// seal; the status of each service also reflects its own checks, such as its
+// circuit breakers.
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Check returns an error if what it checks is not able to serve.
+type Check func(ctx context.Context) error
+
+// Checker updates the serving status of a Health server with checks.
+type Checker struct {
+	server *health.Server
+
+	mu       sync.Mutex
+	checks   map[string]Check
+	services map[string][]Check
+}
+
+// NewChecker returns a Checker updating the server, which reports every
+// service as not serving until it is first updated.
+func NewChecker(server *health.Server) *Checker {
+	server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	return &Checker{
+		server:   server,
+		checks:   make(map[string]Check),
+		services: make(map[string][]Check),
+	}
+}
+
+// AddCheck adds a check of a dependency of every service, under the name.
+func (c *Checker) AddCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// AddService adds a service name reported by the server, serving when the
+// dependencies and the checks of the service pass.
+func (c *Checker) AddService(service string, checks ...Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.services[service] = append(c.services[service], checks...)
+	c.server.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+// Update runs the checks and updates the serving status of the server and of
+// every service. It returns the failures of the checks, by service name and
+// check name for the dependencies.
+func (c *Checker) Update(ctx context.Context) map[string]error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	failures := make(map[string]error)
+	var failing error
+	for _, name := range sortedKeys(c.checks) {
+		if err := c.checks[name](ctx); err != nil {
+			failures[name] = err
+			failing = err
+		}
+	}
+	c.set("", failing)
+	for service, checks := range c.services {
+		err := failing
+		for _, check := range checks {
+			if err != nil {
+				break
+			}
+			if err = check(ctx); err != nil {
+				failures[service] = err
+			}
+		}
+		c.set(service, err)
+	}
+	return failures
+}
+
+// set sets the serving status of the service, serving if err is nil.
+func (c *Checker) set(service string, err error) {
+	status := healthpb.HealthCheckResponse_SERVING
+	if err != nil {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	c.server.SetServingStatus(service, status)
+}
+
+// Run updates the serving status every interval until the context is done,
+// logging the failures of the checks when they change. Checks taking longer
+// than the interval fail.
+func (c *Checker) Run(ctx context.Context, interval time.Duration, logger log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := make(map[string]string)
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		failures := c.Update(checkCtx)
+		cancel()
+		for name, err := range failures {
+			if last[name] != err.Error() {
+				logger.Log("check", name, "err", err)
+			}
+		}
+		for name := range last {
+			if _, ok := failures[name]; !ok {
+				logger.Log("check", name, "status", "recovered")
+			}
+		}
+		last = make(map[string]string, len(failures))
+		for name, err := range failures {
+			last[name] = err.Error()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func sortedKeys(m map[string]Check) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
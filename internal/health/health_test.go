@@ -0,0 +1,72 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestChecker(t *testing.T) {
+	server := health.NewServer()
+	c := NewChecker(server)
+	var storeErr, breakerErr error
+	c.AddCheck("store", func(context.Context) error { return storeErr })
+	c.AddService("pb.KV", func(context.Context) error { return breakerErr })
+	c.AddService("pb.Sys")
+
+	status := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		t.Helper()
+		resp, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Status
+	}
+	const (
+		serving    = healthpb.HealthCheckResponse_SERVING
+		notServing = healthpb.HealthCheckResponse_NOT_SERVING
+	)
+
+	if want, have := notServing, status(""); want != have {
+		t.Errorf("before update: want %s, have %s", want, have)
+	}
+
+	for _, tc := range []struct {
+		name            string
+		store, breaker  error
+		server, kv, sys healthpb.HealthCheckResponse_ServingStatus
+		wantFailures    int
+	}{
+		{"healthy", nil, nil, serving, serving, serving, 0},
+		{"breaker open", nil, errors.New("circuit breakers open: KVGet"), serving, notServing, serving, 1},
+		{"store down", errors.New("connection refused"), nil, notServing, notServing, notServing, 1},
+		{"recovered", nil, nil, serving, serving, serving, 0},
+	} {
+		storeErr, breakerErr = tc.store, tc.breaker
+		failures := c.Update(context.Background())
+		if want, have := tc.wantFailures, len(failures); want != have {
+			t.Errorf("%s: failures: want %d, have %d: %v", tc.name, want, have, failures)
+		}
+		for service, want := range map[string]healthpb.HealthCheckResponse_ServingStatus{"": tc.server, "pb.KV": tc.kv, "pb.Sys": tc.sys} {
+			if have := status(service); want != have {
+				t.Errorf("%s: service %q: want %s, have %s", tc.name, service, want, have)
+			}
+		}
+	}
+}
+
+func TestRunStops(t *testing.T) {
+	c := NewChecker(health.NewServer())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx, 1, log.NewNopLogger())
+		close(done)
+	}()
+	<-done
+}
@@ -0,0 +1,92 @@
+// Package identity carries the authenticated caller of a request through its
+// context.
+package identity
+
+import (
+	"context"
+	"crypto/x509"
+	"time"
+)
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	// Subject identifies the caller, e.g. the display name of its token.
+	Subject string
+	// Token is the hash of the token the caller authenticated with, if any.
+	Token string
+	// TokenID is the ID (jti claim) of the JWT the caller authenticated
+	// with, if any.
+	TokenID string
+	// Method is the authentication method which established the identity.
+	Method string
+	// Policies are the names of the policies granted to the caller.
+	Policies []string
+	// Scopes are the OAuth2 scopes granted to the caller, if it authenticated
+	// with an access token.
+	Scopes []string
+	// Certificate identifies the TLS client certificate the caller presented,
+	// if any, see CertificateName.
+	Certificate string
+	// ExpireTime is when the token of the caller expires, zero if it does
+	// not.
+	ExpireTime time.Time
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the identity.
+func NewContext(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the identity carried by ctx, if any.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(contextKey{}).(Identity)
+	return id, ok
+}
+
+type remoteAddrKey struct{}
+
+// WithRemoteAddr returns a copy of ctx carrying the network address of the
+// client which sent the request.
+func WithRemoteAddr(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, remoteAddrKey{}, addr)
+}
+
+// RemoteAddr returns the client address carried by ctx, empty if unknown.
+func RemoteAddr(ctx context.Context) string {
+	addr, _ := ctx.Value(remoteAddrKey{}).(string)
+	return addr
+}
+
+type certificateKey struct{}
+
+// WithCertificate returns a copy of ctx carrying the name of the verified TLS
+// client certificate which the request was sent with.
+func WithCertificate(ctx context.Context, cert *x509.Certificate) context.Context {
+	return context.WithValue(ctx, certificateKey{}, CertificateName(cert))
+}
+
+// Certificate returns the name of the client certificate carried by ctx,
+// empty if the request was sent without one.
+func Certificate(ctx context.Context) string {
+	name, _ := ctx.Value(certificateKey{}).(string)
+	return name
+}
+
+// CertificateName returns the identity a client certificate asserts: its
+// SPIFFE ID, else its first DNS name or email address, else its common name.
+func CertificateName(cert *x509.Certificate) string {
+	for _, u := range cert.URIs {
+		if u.Scheme == "spiffe" {
+			return u.String()
+		}
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	if len(cert.EmailAddresses) > 0 {
+		return cert.EmailAddresses[0]
+	}
+	return cert.Subject.CommonName
+}
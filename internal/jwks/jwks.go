@@ -0,0 +1,253 @@
+// Package jwks verifies JWTs issued by external identity providers against
+// the public keys of a JSON Web Key Set (RFC 7517). The key set is loaded from
+// a file or an URL and refreshed periodically, so that providers may rotate
+// their keys; tokens select their key with the kid header.
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// minRefreshInterval limits the refreshes triggered by tokens naming an
+// unknown key.
+const minRefreshInterval = 30 * time.Second
+
+// maxKeySetSize bounds the key set documents read from an URL.
+const maxKeySetSize = 1 << 20
+
+// ErrUnknownKey is returned when no key of the set has the kid of a token.
+var ErrUnknownKey = errors.New("unknown key ID")
+
+// KeySet is a JSON Web Key Set of signature verification keys.
+type KeySet struct {
+	source string
+	client *http.Client
+
+	mu       sync.RWMutex
+	keys     map[string]publicKey
+	loadedAt time.Time
+}
+
+type publicKey struct {
+	// alg is the algorithm the key is restricted to, if any.
+	alg string
+	key interface{}
+}
+
+// NewKeySet returns an empty KeySet loaded from source, an http(s) URL or a
+// file path. URLs are fetched with client, http.DefaultClient if nil.
+func NewKeySet(source string, client *http.Client) *KeySet {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &KeySet{source: source, client: client, keys: map[string]publicKey{}}
+}
+
+// Refresh reloads the key set from its source. The current keys are kept
+// when the source cannot be read or parsed.
+func (s *KeySet) Refresh(ctx context.Context) error {
+	raw, err := s.read(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := parse(raw)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+	s.loadedAt = time.Now()
+	return nil
+}
+
+// Run refreshes the key set every interval until ctx is done, logging the
+// failed refreshes.
+func (s *KeySet) Run(ctx context.Context, interval time.Duration, logger log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				logger.Log("during", "refresh", "source", s.source, "err", err)
+			}
+		}
+	}
+}
+
+// key returns the key with the ID. An unknown ID refreshes the key set
+// first, unless it was loaded recently, in case the provider rotated its
+// keys since.
+func (s *KeySet) key(ctx context.Context, kid string) (publicKey, error) {
+	s.mu.RLock()
+	k, ok := s.keys[kid]
+	stale := time.Since(s.loadedAt) > minRefreshInterval
+	s.mu.RUnlock()
+	if ok {
+		return k, nil
+	}
+	if !stale {
+		return publicKey{}, ErrUnknownKey
+	}
+	if err := s.Refresh(ctx); err != nil {
+		return publicKey{}, err
+	}
+	s.mu.RLock()
+	k, ok = s.keys[kid]
+	s.mu.RUnlock()
+	if !ok {
+		return publicKey{}, ErrUnknownKey
+	}
+	return k, nil
+}
+
+func (s *KeySet) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(s.source, "https://") && !strings.HasPrefix(s.source, "http://") {
+		return os.ReadFile(s.source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: fetching %s: %s", s.source, resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxKeySetSize))
+}
+
+// jsonWebKey is a JSON Web Key, only its public members being read.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parse returns the signature keys of a key set by ID. Keys of unsupported
+// types, or meant for encryption, are skipped.
+func parse(raw []byte) (map[string]publicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %v", err)
+	}
+	keys := make(map[string]publicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: %v", jwk.Kid, err)
+		}
+		if key == nil {
+			continue
+		}
+		keys[jwk.Kid] = publicKey{alg: jwk.Alg, key: key}
+	}
+	return keys, nil
+}
+
+// publicKey returns the key as an *rsa.PublicKey, *ecdsa.PublicKey or
+// ed25519.PublicKey, nil if its type is not supported.
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		if n.BitLen() < 2048 {
+			return nil, errors.New("RSA modulus shorter than 2048 bits")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, nil
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("missing key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
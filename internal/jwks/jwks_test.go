@@ -0,0 +1,138 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	set := map[string][]map[string]string{"keys": {
+		{"kty": "RSA", "kid": "rsa", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes())},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(edPublic), "alg": "EdDSA"},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+	}}
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(set)
+	}))
+	defer srv.Close()
+
+	keys := NewKeySet(srv.URL, srv.Client())
+	if err := keys.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	v := NewVerifier(keys, Config{Issuer: "https://idp.example.com", Audience: "vaultd", ClockSkew: time.Minute})
+
+	now := time.Now()
+	valid := jwt.RegisteredClaims{
+		Issuer:    "https://idp.example.com",
+		Subject:   "billing",
+		Audience:  jwt.ClaimStrings{"other", "vaultd"},
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		NotBefore: jwt.NewNumericDate(now),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, claims jwt.RegisteredClaims) string {
+		tok := jwt.NewWithClaims(method, claims)
+		tok.Header["kid"] = kid
+		raw, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	with := func(f func(c *jwt.RegisteredClaims)) jwt.RegisteredClaims {
+		c := valid
+		f(&c)
+		return c
+	}
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"RS256", sign(jwt.SigningMethodRS256, "rsa", rsaKey, valid), true},
+		{"ES256", sign(jwt.SigningMethodES256, "ec", ecKey, valid), true},
+		{"EdDSA", sign(jwt.SigningMethodEdDSA, "ed", edKey, valid), true},
+		{"expired within skew", sign(jwt.SigningMethodES256, "ec", ecKey, with(func(c *jwt.RegisteredClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-30 * time.Second)) })), true},
+		{"expired", sign(jwt.SigningMethodES256, "ec", ecKey, with(func(c *jwt.RegisteredClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-2 * time.Minute)) })), false},
+		{"no expiry", sign(jwt.SigningMethodES256, "ec", ecKey, with(func(c *jwt.RegisteredClaims) { c.ExpiresAt = nil })), false},
+		{"not yet valid", sign(jwt.SigningMethodES256, "ec", ecKey, with(func(c *jwt.RegisteredClaims) { c.NotBefore = jwt.NewNumericDate(now.Add(2 * time.Minute)) })), false},
+		{"wrong issuer", sign(jwt.SigningMethodES256, "ec", ecKey, with(func(c *jwt.RegisteredClaims) { c.Issuer = "https://evil.example.com" })), false},
+		{"wrong audience", sign(jwt.SigningMethodES256, "ec", ecKey, with(func(c *jwt.RegisteredClaims) { c.Audience = jwt.ClaimStrings{"other"} })), false},
+		{"wrong key", sign(jwt.SigningMethodES256, "ec", mustECKey(t), valid), false},
+		{"key of another type", sign(jwt.SigningMethodES256, "rsa", ecKey, valid), false},
+		{"encryption key", sign(jwt.SigningMethodRS256, "enc", rsaKey, valid), false},
+		{"HS256", sign(jwt.SigningMethodHS256, "rsa", []byte("secret"), valid), false},
+	} {
+		_, err := v.Verify(context.Background(), tc.token)
+		if have := err == nil; have != tc.valid {
+			t.Errorf("%s: want valid %v, have err %v", tc.name, tc.valid, err)
+		}
+	}
+
+	scoped := jwt.NewWithClaims(jwt.SigningMethodES256, Claims{RegisteredClaims: valid, Scope: "vault:hash", Scp: []string{"vault:validate"}})
+	scoped.Header["kid"] = "ec"
+	raw, err := scoped.SignedString(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := v.Verify(context.Background(), raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"vault:hash", "vault:validate"}, claims.Scopes(); !reflect.DeepEqual(want, have) {
+		t.Errorf("scopes: want %v, have %v", want, have)
+	}
+
+	// Unknown keys only refresh a recently loaded key set after a while.
+	rotated := mustECKey(t)
+	set["keys"] = append(set["keys"], map[string]string{"kty": "EC", "kid": "ec2", "crv": "P-256", "x": b64(rotated.X.Bytes()), "y": b64(rotated.Y.Bytes())})
+	token := sign(jwt.SigningMethodES256, "ec2", rotated, valid)
+	if _, err := v.Verify(context.Background(), token); err == nil || fetches != 1 {
+		t.Errorf("rotated key before refresh: want error without fetch, have %v after %d fetches", err, fetches)
+	}
+	keys.loadedAt = time.Now().Add(-time.Hour)
+	if _, err := v.Verify(context.Background(), token); err != nil || fetches != 2 {
+		t.Errorf("rotated key: want refresh, have %v after %d fetches", err, fetches)
+	}
+}
+
+func mustECKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
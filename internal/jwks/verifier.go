@@ -0,0 +1,142 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// DefaultAlgorithms are the signature algorithms accepted when none are
+// configured.
+var DefaultAlgorithms = []string{"RS256", "ES256", "EdDSA"}
+
+// ErrInvalidToken is returned when verifying a JWT which is malformed, not
+// signed by a key of the set, or whose claims are not valid.
+var ErrInvalidToken = errors.New("invalid JWT")
+
+// Config holds the claims required of the tokens.
+type Config struct {
+	// Issuer must equal the iss claim.
+	Issuer string
+	// Audience must be one of the aud claim.
+	Audience string
+	// ClockSkew is tolerated when checking the exp, nbf and iat claims.
+	ClockSkew time.Duration
+	// Algorithms are the accepted signature algorithms, DefaultAlgorithms
+	// if empty.
+	Algorithms []string
+}
+
+// Claims are the claims of a verified token.
+type Claims struct {
+	jwt.RegisteredClaims
+	// Scope is the space separated list of the scopes granted to the token,
+	// as in OAuth2 access tokens (RFC 8693).
+	Scope string `json:"scope,omitempty"`
+	// Scp lists the scopes granted to the token, as some providers issue
+	// them.
+	Scp []string `json:"scp,omitempty"`
+}
+
+// Scopes returns the scopes granted to the token.
+func (c *Claims) Scopes() []string {
+	return append(strings.Fields(c.Scope), c.Scp...)
+}
+
+// Verifier verifies JWTs signed by the keys of a KeySet.
+type Verifier struct {
+	keys   *KeySet
+	config Config
+	parser *jwt.Parser
+	now    func() time.Time
+}
+
+// NewVerifier returns a Verifier of the tokens signed by the keys and
+// carrying the claims required by config.
+func NewVerifier(keys *KeySet, config Config) *Verifier {
+	if len(config.Algorithms) == 0 {
+		config.Algorithms = DefaultAlgorithms
+	}
+	return &Verifier{
+		keys:   keys,
+		config: config,
+		// The time based claims are checked by Verify, tolerating the
+		// clock skew.
+		parser: jwt.NewParser(jwt.WithValidMethods(config.Algorithms), jwt.WithoutClaimsValidation()),
+		now:    time.Now,
+	}
+}
+
+// Issuer returns the issuer of the tokens verified by v.
+func (v *Verifier) Issuer() string {
+	return v.config.Issuer
+}
+
+// Verify returns the claims of a token signed by a key of the set, issued by
+// the configured issuer to the configured audience, which has not expired.
+func (v *Verifier) Verify(ctx context.Context, raw string) (*Claims, error) {
+	var claims Claims
+	_, err := v.parser.ParseWithClaims(raw, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		k, err := v.keys.key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if k.alg != "" && k.alg != t.Method.Alg() {
+			return nil, ErrInvalidToken
+		}
+		if !compatible(t.Method, k.key) {
+			return nil, ErrInvalidToken
+		}
+		return k.key, nil
+	})
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := v.validate(&claims.RegisteredClaims); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+// validate checks the registered claims. The exp claim is required, nbf and
+// iat are checked when present.
+func (v *Verifier) validate(claims *jwt.RegisteredClaims) error {
+	now := v.now()
+	switch {
+	case claims.Issuer != v.config.Issuer:
+		return ErrInvalidToken
+	case !claims.VerifyAudience(v.config.Audience, true):
+		return ErrInvalidToken
+	case claims.ExpiresAt == nil || !now.Add(-v.config.ClockSkew).Before(claims.ExpiresAt.Time):
+		return ErrInvalidToken
+	case claims.NotBefore != nil && now.Add(v.config.ClockSkew).Before(claims.NotBefore.Time):
+		return ErrInvalidToken
+	case claims.IssuedAt != nil && now.Add(v.config.ClockSkew).Before(claims.IssuedAt.Time):
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// compatible reports whether the signing method applies to the key, so that
+// e.g. an ES384 token is not checked against a P-256 key.
+func compatible(method jwt.SigningMethod, key interface{}) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		_, ok := method.(*jwt.SigningMethodRSA)
+		return ok
+	case *ecdsa.PublicKey:
+		m, ok := method.(*jwt.SigningMethodECDSA)
+		return ok && m.CurveBits == k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		_, ok := method.(*jwt.SigningMethodEd25519)
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,341 @@
+// Package lease implements the leases of the secrets and tokens issued by
+// vaultd. Every lease has a TTL after which the expiration manager revokes it
+// through the revocation handler registered by the issuing backend.
+package lease
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+)
+
+var (
+	// ErrNotFound is returned when a lease does not exist.
+	ErrNotFound = errors.New("lease not found")
+	// ErrNotRenewable is returned when renewing a lease which is not
+	// renewable.
+	ErrNotRenewable = errors.New("lease is not renewable")
+	// ErrNoHandler is returned when registering a lease under a prefix no
+	// revocation handler is registered for.
+	ErrNoHandler = errors.New("no revocation handler for lease")
+	// ErrInvalidPrefix is returned for empty lease prefixes.
+	ErrInvalidPrefix = errors.New("invalid lease prefix")
+)
+
+// Lease is a time bound grant of a secret or a token.
+type Lease struct {
+	// ID is the prefix of the issuing backend followed by a random suffix.
+	ID         string    `json:"id"`
+	IssueTime  time.Time `json:"issue_time"`
+	ExpireTime time.Time `json:"expire_time"`
+	// LastRenewalTime is zero until the lease is renewed.
+	LastRenewalTime time.Time `json:"last_renewal_time,omitempty"`
+	// MaxExpireTime bounds the renewals of the lease.
+	MaxExpireTime time.Time `json:"max_expire_time"`
+	Renewable     bool      `json:"renewable"`
+	// RevokeAttempts counts the failed revocations of the lease once
+	// expired. Each failure postpones the next attempt, see revokeBackoff.
+	RevokeAttempts int `json:"revoke_attempts,omitempty"`
+}
+
+// TTL returns the time left before the lease expires.
+func (l *Lease) TTL() time.Duration {
+	ttl := time.Until(l.ExpireTime)
+	if ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// Storage persists leases.
+type Storage interface {
+	// Lease returns the lease with the ID, or ErrNotFound.
+	Lease(ctx context.Context, id string) (*Lease, error)
+	// PutLease creates or replaces a lease.
+	PutLease(ctx context.Context, l *Lease) error
+	// DeleteLease removes a lease. Deleting a missing lease is not an error.
+	DeleteLease(ctx context.Context, id string) error
+	// ListLeases returns the IDs of the leases starting with prefix.
+	ListLeases(ctx context.Context, prefix string) ([]string, error)
+	// ExpiredLeases returns up to limit IDs of the leases expired at now,
+	// earliest first.
+	ExpiredLeases(ctx context.Context, now time.Time, limit int) ([]string, error)
+	// CountLeases returns the number of leases.
+	CountLeases(ctx context.Context) (int, error)
+}
+
+// RevokeFunc revokes the secret or token a lease was issued for. It is called
+// before the lease is deleted, and must succeed if the secret is already
+// gone.
+type RevokeFunc func(ctx context.Context, l *Lease) error
+
+// Metrics collects the lease metrics. Revoked is labeled with "reason",
+// either "expired" or "revoked".
+type Metrics struct {
+	Issued  metrics.Counter
+	Renewed metrics.Counter
+	Revoked metrics.Counter
+	Active  metrics.Gauge
+}
+
+// Manager issues, renews and revokes leases, and revokes them when they
+// expire.
+type Manager struct {
+	storage    Storage
+	logger     log.Logger
+	metrics    Metrics
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+
+	mu       sync.RWMutex
+	handlers map[string]RevokeFunc
+}
+
+// NewManager returns a Manager persisting leases in the storage. Leases are
+// issued for defaultTTL unless requested otherwise, and never outlive maxTTL.
+func NewManager(logger log.Logger, storage Storage, m Metrics, defaultTTL, maxTTL time.Duration) *Manager {
+	if maxTTL < defaultTTL {
+		maxTTL = defaultTTL
+	}
+	return &Manager{
+		storage:    storage,
+		logger:     logger,
+		metrics:    m,
+		defaultTTL: defaultTTL,
+		maxTTL:     maxTTL,
+		handlers:   make(map[string]RevokeFunc),
+	}
+}
+
+// Handle registers the revocation handler of the leases issued under prefix.
+// Leases are routed to the handler of their longest matching prefix.
+func (m *Manager) Handle(prefix string, fn RevokeFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[prefix] = fn
+}
+
+func (m *Manager) handler(id string) RevokeFunc {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var best string
+	var fn RevokeFunc
+	for prefix, h := range m.handlers {
+		if strings.HasPrefix(id, prefix) && len(prefix) >= len(best) {
+			best, fn = prefix, h
+		}
+	}
+	return fn
+}
+
+// Register issues a renewable lease under prefix for ttl, or the default TTL
+// if ttl is zero, capped by the maximum TTL.
+func (m *Manager) Register(ctx context.Context, prefix string, ttl time.Duration) (*Lease, error) {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return nil, ErrInvalidPrefix
+	}
+	id := prefix + "/" + randomID()
+	if m.handler(id) == nil {
+		return nil, ErrNoHandler
+	}
+	if ttl <= 0 {
+		ttl = m.defaultTTL
+	}
+	if ttl > m.maxTTL {
+		ttl = m.maxTTL
+	}
+	now := time.Now().UTC()
+	l := &Lease{
+		ID:            id,
+		IssueTime:     now,
+		ExpireTime:    now.Add(ttl),
+		MaxExpireTime: now.Add(m.maxTTL),
+		Renewable:     true,
+	}
+	if err := m.storage.PutLease(ctx, l); err != nil {
+		return nil, err
+	}
+	m.metrics.Issued.Add(1)
+	m.metrics.Active.Add(1)
+	return l, nil
+}
+
+// Lookup returns the lease with the ID.
+func (m *Manager) Lookup(ctx context.Context, id string) (*Lease, error) {
+	return m.storage.Lease(ctx, id)
+}
+
+// List returns the IDs of the leases issued under prefix.
+func (m *Manager) List(ctx context.Context, prefix string) ([]string, error) {
+	ids, err := m.storage.ListLeases(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Renew extends a lease by increment from now, or by the default TTL if
+// increment is zero, without exceeding its maximum expiration time.
+func (m *Manager) Renew(ctx context.Context, id string, increment time.Duration) (*Lease, error) {
+	l, err := m.storage.Lease(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !l.Renewable {
+		return nil, ErrNotRenewable
+	}
+	now := time.Now().UTC()
+	if !l.ExpireTime.After(now) || l.RevokeAttempts > 0 {
+		return nil, ErrNotFound
+	}
+	if increment <= 0 {
+		increment = m.defaultTTL
+	}
+	l.ExpireTime = now.Add(increment)
+	if l.ExpireTime.After(l.MaxExpireTime) {
+		l.ExpireTime = l.MaxExpireTime
+	}
+	l.LastRenewalTime = now
+	if err := m.storage.PutLease(ctx, l); err != nil {
+		return nil, err
+	}
+	m.metrics.Renewed.Add(1)
+	return l, nil
+}
+
+// Revoke revokes the secret of a lease and deletes the lease.
+func (m *Manager) Revoke(ctx context.Context, id string) error {
+	l, err := m.storage.Lease(ctx, id)
+	if err != nil {
+		return err
+	}
+	return m.revoke(ctx, l, "revoked")
+}
+
+// RevokePrefix revokes all the leases issued under prefix.
+func (m *Manager) RevokePrefix(ctx context.Context, prefix string) error {
+	if strings.Trim(prefix, "/") == "" {
+		return ErrInvalidPrefix
+	}
+	ids, err := m.storage.ListLeases(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := m.Revoke(ctx, id); err != nil && err != ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) revoke(ctx context.Context, l *Lease, reason string) error {
+	if fn := m.handler(l.ID); fn != nil {
+		if err := fn(ctx, l); err != nil {
+			return err
+		}
+	}
+	if err := m.storage.DeleteLease(ctx, l.ID); err != nil {
+		return err
+	}
+	m.metrics.Revoked.With("reason", reason).Add(1)
+	m.metrics.Active.Add(-1)
+	return nil
+}
+
+// Run revokes expired leases every interval until ctx is done. Ticks are
+// skipped while sealed reports true, since the secrets cannot be revoked
+// then.
+func (m *Manager) Run(ctx context.Context, interval time.Duration, sealed func() bool) error {
+	if n, err := m.storage.CountLeases(ctx); err == nil {
+		m.metrics.Active.Set(float64(n))
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if sealed() {
+				continue
+			}
+			m.expire(ctx)
+		}
+	}
+}
+
+const (
+	// expireBatch bounds the number of leases revoked per tick.
+	expireBatch = 100
+
+	// minRevokeBackoff and maxRevokeBackoff bound the delay before the next
+	// revocation of an expired lease whose revocation failed.
+	minRevokeBackoff = 10 * time.Second
+	maxRevokeBackoff = time.Hour
+)
+
+func (m *Manager) expire(ctx context.Context) {
+	ids, err := m.storage.ExpiredLeases(ctx, time.Now().UTC(), expireBatch)
+	if err != nil {
+		level.Error(m.logger).Log("during", "list expired leases", "err", err)
+		return
+	}
+	for _, id := range ids {
+		l, err := m.storage.Lease(ctx, id)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			level.Error(m.logger).Log("during", "lookup expired lease", "lease_id", id, "err", err)
+			continue
+		}
+		if err := m.revoke(ctx, l, "expired"); err != nil {
+			level.Error(m.logger).Log("during", "revoke expired lease", "lease_id", id, "attempts", l.RevokeAttempts+1, "err", err)
+			m.postpone(ctx, l)
+		}
+	}
+}
+
+// postpone moves the expiration of a lease whose revocation failed past its
+// backoff, so that the leases failing to be revoked do not hold up the batches
+// of the leases expiring after them.
+func (m *Manager) postpone(ctx context.Context, l *Lease) {
+	l.RevokeAttempts++
+	l.ExpireTime = time.Now().UTC().Add(revokeBackoff(l.RevokeAttempts))
+	if err := m.storage.PutLease(ctx, l); err != nil {
+		level.Error(m.logger).Log("during", "postpone expired lease", "lease_id", l.ID, "err", err)
+	}
+}
+
+// revokeBackoff returns the delay before the next revocation of a lease after
+// attempts failed ones, doubling from minRevokeBackoff up to maxRevokeBackoff.
+func revokeBackoff(attempts int) time.Duration {
+	d := minRevokeBackoff
+	for i := 1; i < attempts && d < maxRevokeBackoff; i++ {
+		d *= 2
+	}
+	if d > maxRevokeBackoff {
+		d = maxRevokeBackoff
+	}
+	return d
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
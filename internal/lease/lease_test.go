@@ -0,0 +1,135 @@
+package lease_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/discard"
+
+	"github.com/williamlsh/vault/internal/lease"
+	"github.com/williamlsh/vault/internal/mock"
+)
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	m := lease.NewManager(log.NewNopLogger(), mock.NewLeaseStorage(), lease.Metrics{
+		Issued:  discard.NewCounter(),
+		Renewed: discard.NewCounter(),
+		Revoked: discard.NewCounter(),
+		Active:  discard.NewGauge(),
+	}, time.Minute, time.Hour)
+
+	revoked := make(chan string, 10)
+	m.Handle("test/", func(ctx context.Context, l *lease.Lease) error {
+		revoked <- l.ID
+		return nil
+	})
+
+	if _, err := m.Register(ctx, "other", 0); err != lease.ErrNoHandler {
+		t.Errorf("register without handler: want %v, have %v", lease.ErrNoHandler, err)
+	}
+
+	l, err := m.Register(ctx, "test/a", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := l.TTL(); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("default ttl: want (0, 1m], have %s", ttl)
+	}
+	l, err = m.Renew(ctx, l.ID, 2*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.ExpireTime.Equal(l.MaxExpireTime) {
+		t.Errorf("renewal past max ttl: want %s, have %s", l.MaxExpireTime, l.ExpireTime)
+	}
+
+	short, err := m.Register(ctx, "test/b", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go m.Run(runCtx, time.Millisecond, func() bool { return false })
+	select {
+	case id := <-revoked:
+		if id != short.ID {
+			t.Errorf("expired lease: want %s, have %s", short.ID, id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expired lease not revoked")
+	}
+
+	if err := m.RevokePrefix(ctx, "test/"); err != nil {
+		t.Fatal(err)
+	}
+	if <-revoked != l.ID {
+		t.Error("revoke by prefix skipped a lease")
+	}
+	if _, err := m.Lookup(ctx, l.ID); err != lease.ErrNotFound {
+		t.Errorf("lookup revoked lease: want %v, have %v", lease.ErrNotFound, err)
+	}
+}
+
+func TestExpireBackoff(t *testing.T) {
+	ctx := context.Background()
+	m := lease.NewManager(log.NewNopLogger(), mock.NewLeaseStorage(), lease.Metrics{
+		Issued:  discard.NewCounter(),
+		Renewed: discard.NewCounter(),
+		Revoked: discard.NewCounter(),
+		Active:  discard.NewGauge(),
+	}, time.Minute, time.Hour)
+
+	m.Handle("failing/", func(ctx context.Context, l *lease.Lease) error {
+		return errors.New("backend unavailable")
+	})
+	revoked := make(chan string, 1)
+	m.Handle("test/", func(ctx context.Context, l *lease.Lease) error {
+		revoked <- l.ID
+		return nil
+	})
+
+	// More failing leases than revoked per tick expire first.
+	var failing *lease.Lease
+	for i := 0; i < 150; i++ {
+		l, err := m.Register(ctx, "failing", time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		failing = l
+	}
+	time.Sleep(5 * time.Millisecond)
+	l, err := m.Register(ctx, "test", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go m.Run(runCtx, time.Millisecond, func() bool { return false })
+	select {
+	case id := <-revoked:
+		if id != l.ID {
+			t.Errorf("expired lease: want %s, have %s", l.ID, id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expired lease held up by the failing leases")
+	}
+	cancel()
+
+	failed, err := m.Lookup(ctx, failing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed.RevokeAttempts != 1 || failed.TTL() <= 0 {
+		t.Errorf("failing lease: want one attempt postponed, have %d attempts and ttl %s", failed.RevokeAttempts, failed.TTL())
+	}
+	if _, err := m.Renew(ctx, failed.ID, 0); err != lease.ErrNotFound {
+		t.Errorf("renew postponed lease: want %v, have %v", lease.ErrNotFound, err)
+	}
+}
@@ -1,7 +1,17 @@
 package mock
 
 import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/williamlsh/vault/internal/lease"
+	"github.com/williamlsh/vault/internal/replay"
+	"github.com/williamlsh/vault/internal/seal"
 	"github.com/williamlsh/vault/internal/store"
+	"github.com/williamlsh/vault/internal/token"
 )
 
 type nopStore struct{}
@@ -17,3 +27,338 @@ func (m nopStore) KeepSecret(secret []byte) <-chan error {
 	errc <- nil
 	return errc
 }
+
+func (m nopStore) Secret(ctx context.Context, id int64) ([]byte, error) {
+	return nil, store.ErrNotFound
+}
+
+type sealStorage struct {
+	mu  sync.Mutex
+	cfg *seal.Config
+}
+
+// NewSealStorage returns a seal storage keeping the configuration in memory,
+// so a vault backed by it can be initialized and unsealed in tests.
+func NewSealStorage() seal.Storage {
+	return &sealStorage{}
+}
+
+func (m *sealStorage) SealConfig(ctx context.Context) (*seal.Config, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cfg, nil
+}
+
+func (m *sealStorage) SetSealConfig(ctx context.Context, cfg *seal.Config) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg = cfg
+	return nil
+}
+
+type storage struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	// locks serializes updates per key, so that update functions may use
+	// the storage themselves.
+	locks map[string]*sync.Mutex
+}
+
+// NewStorage returns a storage keeping its entries in memory.
+func NewStorage() store.Storage {
+	return &storage{
+		entries: make(map[string][]byte),
+		locks:   make(map[string]*sync.Mutex),
+	}
+}
+
+func (m *storage) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.entries[key]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return append([]byte(nil), v...), nil
+}
+
+func (m *storage) Put(ctx context.Context, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (m *storage) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *storage) List(ctx context.Context, prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for k := range m.entries {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, strings.TrimPrefix(k, prefix))
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// lock returns the lock serializing the updates of key.
+func (m *storage) lock(key string) *sync.Mutex {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		m.locks[key] = l
+	}
+	return l
+}
+
+func (m *storage) Update(ctx context.Context, key string, fn func(value []byte) ([]byte, error)) error {
+	l := m.lock(key)
+	l.Lock()
+	defer l.Unlock()
+
+	old, err := m.Get(ctx, key)
+	if err == store.ErrNotFound {
+		old, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	value, err := fn(old)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return m.Delete(ctx, key)
+	}
+	return m.Put(ctx, key, value)
+}
+
+// Transaction locks the keys the first time fn touches them and applies the
+// writes once fn returns nil.
+func (m *storage) Transaction(ctx context.Context, fn func(tx store.Tx) error) error {
+	tx := &storageTx{m: m, writes: make(map[string][]byte)}
+	defer func() {
+		for _, l := range tx.locked {
+			l.Unlock()
+		}
+	}()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, v := range tx.writes {
+		if v == nil {
+			delete(m.entries, k)
+		} else {
+			m.entries[k] = v
+		}
+	}
+	return nil
+}
+
+type storageTx struct {
+	m      *storage
+	locked map[string]*sync.Mutex
+	// writes holds the pending values, nil for deletions.
+	writes map[string][]byte
+}
+
+func (t *storageTx) lock(key string) {
+	if t.locked == nil {
+		t.locked = make(map[string]*sync.Mutex)
+	}
+	if _, ok := t.locked[key]; ok {
+		return
+	}
+	l := t.m.lock(key)
+	l.Lock()
+	t.locked[key] = l
+}
+
+func (t *storageTx) Get(key string) ([]byte, error) {
+	t.lock(key)
+	if v, ok := t.writes[key]; ok {
+		if v == nil {
+			return nil, store.ErrNotFound
+		}
+		return append([]byte(nil), v...), nil
+	}
+	return t.m.Get(context.Background(), key)
+}
+
+func (t *storageTx) Put(key string, value []byte) error {
+	t.lock(key)
+	t.writes[key] = append([]byte{}, value...)
+	return nil
+}
+
+func (t *storageTx) Delete(key string) error {
+	t.lock(key)
+	t.writes[key] = nil
+	return nil
+}
+
+type leaseStorage struct {
+	mu     sync.Mutex
+	leases map[string]lease.Lease
+}
+
+// NewLeaseStorage returns a lease storage keeping the leases in memory.
+func NewLeaseStorage() lease.Storage {
+	return &leaseStorage{leases: make(map[string]lease.Lease)}
+}
+
+func (m *leaseStorage) Lease(ctx context.Context, id string) (*lease.Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.leases[id]
+	if !ok {
+		return nil, lease.ErrNotFound
+	}
+	return &l, nil
+}
+
+func (m *leaseStorage) PutLease(ctx context.Context, l *lease.Lease) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.leases[l.ID] = *l
+	return nil
+}
+
+func (m *leaseStorage) DeleteLease(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.leases, id)
+	return nil
+}
+
+func (m *leaseStorage) ListLeases(ctx context.Context, prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for id := range m.leases {
+		if strings.HasPrefix(id, prefix) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (m *leaseStorage) ExpiredLeases(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var expired []lease.Lease
+	for _, l := range m.leases {
+		if !l.ExpireTime.After(now) {
+			expired = append(expired, l)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].ExpireTime.Before(expired[j].ExpireTime) })
+	var ids []string
+	for _, l := range expired {
+		if len(ids) == limit {
+			break
+		}
+		ids = append(ids, l.ID)
+	}
+	return ids, nil
+}
+
+func (m *leaseStorage) CountLeases(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.leases), nil
+}
+
+type tokenStorage struct {
+	mu     sync.Mutex
+	tokens map[string]token.Token
+}
+
+// NewTokenStorage returns a token storage keeping the tokens in memory.
+func NewTokenStorage() token.Storage {
+	return &tokenStorage{tokens: make(map[string]token.Token)}
+}
+
+func (m *tokenStorage) Token(ctx context.Context, hash string) (*token.Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tokens[hash]
+	if !ok {
+		return nil, token.ErrNotFound
+	}
+	t.ID = ""
+	t.Policies = append([]string(nil), t.Policies...)
+	return &t, nil
+}
+
+func (m *tokenStorage) PutToken(ctx context.Context, t *token.Token) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[t.Hash] = *t
+	return nil
+}
+
+func (m *tokenStorage) DeleteToken(ctx context.Context, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.tokens, hash)
+	return nil
+}
+
+func (m *tokenStorage) ChildTokens(ctx context.Context, parent string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var hashes []string
+	for hash, t := range m.tokens {
+		if t.Parent == parent {
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Strings(hashes)
+	return hashes, nil
+}
+
+type replayStorage struct {
+	mu  sync.Mutex
+	ids map[string]time.Time
+}
+
+// NewReplayStorage returns a replay storage keeping the token IDs in memory.
+// Caches sharing it behave as replicas sharing a database.
+func NewReplayStorage() replay.Storage {
+	return &replayStorage{ids: make(map[string]time.Time)}
+}
+
+func (m *replayStorage) Record(ctx context.Context, id string, exp time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.ids[id]; ok && e.After(time.Now()) {
+		return false, nil
+	}
+	m.ids[id] = exp
+	return true, nil
+}
+
+func (m *replayStorage) Purge(ctx context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, exp := range m.ids {
+		if !exp.After(now) {
+			delete(m.ids, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+// Package oauth implements the OAuth2 access tokens issued by vaultd. Access
+// tokens are JWTs signed with an ECDSA P-256 key kept in the encrypted
+// storage, so they are verified without a lookup; revoked tokens are listed
+// by their ID until they expire.
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/williamlsh/vault/internal/lease"
+	"github.com/williamlsh/vault/internal/store"
+)
+
+const (
+	// keyPath is the storage key of the signing key.
+	keyPath = "auth/oauth/key"
+	// revokedPrefix is the storage key and lease path of the revoked tokens,
+	// followed by the token ID.
+	revokedPrefix = "auth/oauth/revoked/"
+)
+
+// ErrInvalidToken is returned when verifying an access token which is
+// malformed, not signed by vaultd, expired or revoked.
+var ErrInvalidToken = errors.New("invalid access token")
+
+// Claims are the claims of an access token.
+type Claims struct {
+	jwt.RegisteredClaims
+	ClientID string `json:"client_id"`
+	// Scope is the space separated list of the scopes granted to the token.
+	Scope string `json:"scope,omitempty"`
+	// Policies are the ACL policies granted to the token.
+	Policies []string `json:"policies,omitempty"`
+}
+
+// Scopes returns the scopes granted to the token.
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// Issuer signs and verifies access tokens.
+type Issuer struct {
+	storage store.Storage
+	leases  *lease.Manager
+	name    string
+
+	mu  sync.Mutex
+	key *signingKey
+}
+
+type signingKey struct {
+	ID      string `json:"kid"`
+	Private []byte `json:"private"`
+
+	private *ecdsa.PrivateKey
+}
+
+// NewIssuer returns an Issuer keeping its signing key and revoked tokens in
+// the storage. Tokens carry name as their issuer. The revoked tokens are
+// forgotten through leases once they expire.
+func NewIssuer(s store.Storage, leases *lease.Manager, name string) *Issuer {
+	i := &Issuer{storage: s, leases: leases, name: name}
+	leases.Handle(revokedPrefix, i.forget)
+	return i
+}
+
+// Issue signs an access token with the claims, valid for ttl. The issuer,
+// token ID and times are set by Issue.
+func (i *Issuer) Issue(ctx context.Context, claims Claims, ttl time.Duration) (string, *Claims, error) {
+	key, err := i.signingKey(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	claims.Issuer = i.name
+	claims.ID = randomID()
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, &claims)
+	t.Header["kid"] = key.ID
+	raw, err := t.SignedString(key.private)
+	if err != nil {
+		return "", nil, err
+	}
+	return raw, &claims, nil
+}
+
+// Verify returns the claims of an access token issued by i which has not
+// expired nor been revoked.
+func (i *Issuer) Verify(ctx context.Context, raw string) (*Claims, error) {
+	key, err := i.signingKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var claims Claims
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}))
+	_, err = parser.ParseWithClaims(raw, &claims, func(t *jwt.Token) (interface{}, error) {
+		if kid, _ := t.Header["kid"].(string); kid != key.ID {
+			return nil, ErrInvalidToken
+		}
+		return &key.private.PublicKey, nil
+	})
+	if err != nil || !claims.VerifyIssuer(i.name, true) || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, ErrInvalidToken
+	}
+
+	_, err = i.storage.Get(ctx, revokedPrefix+claims.ID)
+	if err == nil {
+		return nil, ErrInvalidToken
+	}
+	if err != store.ErrNotFound {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+// Revoke lists a verified token as revoked until it expires.
+func (i *Issuer) Revoke(ctx context.Context, claims *Claims) error {
+	raw, err := json.Marshal(claims.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if err := i.storage.Put(ctx, revokedPrefix+claims.ID, raw); err != nil {
+		return err
+	}
+	_, err = i.leases.Register(ctx, revokedPrefix+claims.ID, time.Until(claims.ExpiresAt.Time))
+	return err
+}
+
+// forget removes a revoked token once it has expired. Leases are capped by
+// the maximum lease TTL, so the token is listed under a new lease if it is
+// still valid.
+func (i *Issuer) forget(ctx context.Context, l *lease.Lease) error {
+	// Lease IDs are auth/oauth/revoked/<token ID>/<random>.
+	key := l.ID[:strings.LastIndex(l.ID, "/")]
+	raw, err := i.storage.Get(ctx, key)
+	if err == store.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var exp jwt.NumericDate
+	if err := json.Unmarshal(raw, &exp); err != nil {
+		return err
+	}
+	if ttl := time.Until(exp.Time); ttl > 0 {
+		_, err := i.leases.Register(ctx, key, ttl)
+		return err
+	}
+	return i.storage.Delete(ctx, key)
+}
+
+// signingKey returns the signing key, generating it on first use.
+func (i *Issuer) signingKey(ctx context.Context) (*signingKey, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.key != nil {
+		return i.key, nil
+	}
+
+	var key signingKey
+	err := i.storage.Update(ctx, keyPath, func(raw []byte) ([]byte, error) {
+		if raw != nil {
+			return raw, json.Unmarshal(raw, &key)
+		}
+		private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(private)
+		if err != nil {
+			return nil, err
+		}
+		key = signingKey{ID: randomID(), Private: der}
+		return json.Marshal(key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(key.Private)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("oauth: signing key is not an ECDSA key")
+	}
+	key.private = private
+	i.key = &key
+	return i.key, nil
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
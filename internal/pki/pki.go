@@ -0,0 +1,207 @@
+// Package pki generates keys and X.509 certificates: certificate authorities,
+// and the server and client certificates they issue for TLS and mutual TLS.
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"time"
+)
+
+// The key types.
+const (
+	KeyTypeRSA     = "rsa"
+	KeyTypeEC      = "ec"
+	KeyTypeEd25519 = "ed25519"
+)
+
+// backdate is how long before their creation certificates become valid, to
+// tolerate clock skew between their issuer and their verifiers.
+const backdate = time.Minute
+
+// ErrInvalidKey is returned for unknown key types or sizes, and for PEM data
+// not holding a supported private key.
+var ErrInvalidKey = errors.New("invalid key")
+
+// GenerateKey generates a private key of the type. Bits is the size of RSA
+// keys, 2048 if zero, or the curve size of EC keys, 224, 256, 384 or 521, 256
+// if zero; it is ignored for Ed25519 keys.
+func GenerateKey(keyType string, bits int) (crypto.Signer, error) {
+	switch keyType {
+	case KeyTypeRSA:
+		if bits == 0 {
+			bits = 2048
+		}
+		if bits < 2048 {
+			return nil, fmt.Errorf("%w: RSA keys of %d bits", ErrInvalidKey, bits)
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	case KeyTypeEC:
+		var curve elliptic.Curve
+		switch bits {
+		case 224:
+			curve = elliptic.P224()
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: EC keys of %d bits", ErrInvalidKey, bits)
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, fmt.Errorf("%w: unknown key type %q", ErrInvalidKey, keyType)
+}
+
+// Template describes a certificate to create.
+type Template struct {
+	CommonName string
+	// DNSNames, IPAddresses, EmailAddresses and URIs are the subject
+	// alternative names, such as a SPIFFE ID URI.
+	DNSNames       []string
+	IPAddresses    []net.IP
+	EmailAddresses []string
+	URIs           []*url.URL
+	TTL            time.Duration
+	// IsCA makes a certificate authority.
+	IsCA bool
+	// Server and Client allow the certificate for TLS server and client
+	// authentication.
+	Server, Client bool
+}
+
+// NewCA creates a self-signed certificate authority with the key.
+func NewCA(t Template, key crypto.Signer) (*x509.Certificate, error) {
+	t.IsCA = true
+	cert, err := t.certificate()
+	if err != nil {
+		return nil, err
+	}
+	return create(cert, cert, key.Public(), key)
+}
+
+// Issue creates a certificate of the public key signed by the certificate
+// authority. The certificate does not outlive the authority.
+func Issue(t Template, pub crypto.PublicKey, ca *x509.Certificate, caKey crypto.Signer) (*x509.Certificate, error) {
+	cert, err := t.certificate()
+	if err != nil {
+		return nil, err
+	}
+	if cert.NotAfter.After(ca.NotAfter) {
+		cert.NotAfter = ca.NotAfter
+	}
+	return create(cert, ca, pub, caKey)
+}
+
+func (t Template) certificate() (*x509.Certificate, error) {
+	serial, err := SerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	cert := &x509.Certificate{
+		SerialNumber:   serial,
+		Subject:        pkix.Name{CommonName: t.CommonName},
+		DNSNames:       t.DNSNames,
+		IPAddresses:    t.IPAddresses,
+		EmailAddresses: t.EmailAddresses,
+		URIs:           t.URIs,
+		NotBefore:      now.Add(-backdate),
+		NotAfter:       now.Add(t.TTL),
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+	}
+	if t.IsCA {
+		cert.KeyUsage |= x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+		cert.BasicConstraintsValid = true
+		cert.IsCA = true
+	}
+	if t.Server {
+		cert.ExtKeyUsage = append(cert.ExtKeyUsage, x509.ExtKeyUsageServerAuth)
+	}
+	if t.Client {
+		cert.ExtKeyUsage = append(cert.ExtKeyUsage, x509.ExtKeyUsageClientAuth)
+	}
+	return cert, nil
+}
+
+func create(template, parent *x509.Certificate, pub crypto.PublicKey, key crypto.Signer) (*x509.Certificate, error) {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, key)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// SerialNumber returns a random 128-bit certificate serial number.
+func SerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// EncodeCertificate returns the PEM encoding of the certificate.
+func EncodeCertificate(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+// EncodeKey returns the PEM encoding of the private key, in PKCS #8 form.
+func EncodeKey(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// ParseCertificate parses the first PEM encoded certificate of raw.
+func ParseCertificate(raw []byte) (*x509.Certificate, error) {
+	for {
+		var block *pem.Block
+		block, raw = pem.Decode(raw)
+		if block == nil {
+			return nil, errors.New("no PEM certificate")
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+// ParseKey parses a PEM encoded RSA, EC or Ed25519 private key, in PKCS #8,
+// PKCS #1 or SEC 1 form.
+func ParseKey(raw []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block", ErrInvalidKey)
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported private key %T", ErrInvalidKey, key)
+	}
+	return signer, nil
+}
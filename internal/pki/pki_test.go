@@ -0,0 +1,60 @@
+package pki
+
+import (
+	"crypto/x509"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestIssue(t *testing.T) {
+	caKey, err := GenerateKey(KeyTypeEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := NewCA(Template{CommonName: "ca", TTL: time.Hour}, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	spiffe, _ := url.Parse("spiffe://example.org/billing")
+	for _, keyType := range []string{KeyTypeRSA, KeyTypeEC, KeyTypeEd25519} {
+		key, err := GenerateKey(keyType, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := Issue(Template{CommonName: "billing", URIs: []*url.URL{spiffe}, TTL: 2 * time.Hour, Client: true}, key.Public(), ca, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+			t.Errorf("%s: %v", keyType, err)
+		}
+		if cert.NotAfter.After(ca.NotAfter) {
+			t.Errorf("%s: certificate outlives its CA", keyType)
+		}
+
+		raw, err := EncodeKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseKey(raw); err != nil {
+			t.Errorf("%s: parse key: %v", keyType, err)
+		}
+		parsed, err := ParseCertificate(EncodeCertificate(cert))
+		if err != nil || !parsed.Equal(cert) {
+			t.Errorf("%s: parse certificate: %v", keyType, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		keyType string
+		bits    int
+	}{{KeyTypeRSA, 1024}, {KeyTypeEC, 512}, {"dsa", 0}} {
+		if _, err := GenerateKey(tc.keyType, tc.bits); err == nil {
+			t.Errorf("%s key of %d bits: want error", tc.keyType, tc.bits)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package policy
+
+import (
+	"sort"
+	"strings"
+)
+
+// ACL decides which capabilities a set of policies grant on a path.
+type ACL struct {
+	root  bool
+	rules map[string]map[string]bool
+}
+
+// NewACL compiles the policies into an ACL. Capabilities granted on the same
+// glob by several policies are merged.
+func NewACL(policies ...*Policy) *ACL {
+	acl := &ACL{rules: make(map[string]map[string]bool)}
+	for _, p := range policies {
+		if p.Name == RootPolicy {
+			acl.root = true
+			continue
+		}
+		for glob, r := range p.Paths {
+			caps, ok := acl.rules[glob]
+			if !ok {
+				caps = make(map[string]bool)
+				acl.rules[glob] = caps
+			}
+			for _, c := range r.Capabilities {
+				caps[c] = true
+			}
+		}
+	}
+	return acl
+}
+
+// Capabilities returns the capabilities granted on path by the most specific
+// matching glob, sorted.
+func (a *ACL) Capabilities(path string) []string {
+	if a.root {
+		return []string{RootPolicy}
+	}
+	var best string
+	var found bool
+	for glob := range a.rules {
+		if match(glob, path) && (!found || moreSpecific(glob, best)) {
+			best, found = glob, true
+		}
+	}
+	if !found || a.rules[best][Deny] {
+		return nil
+	}
+	caps := make([]string, 0, len(a.rules[best]))
+	for c := range a.rules[best] {
+		caps = append(caps, c)
+	}
+	sort.Strings(caps)
+	return caps
+}
+
+// Allowed reports whether any of the capabilities is granted on path.
+func (a *ACL) Allowed(path string, capabilities ...string) bool {
+	if a.root {
+		return true
+	}
+	granted := a.Capabilities(path)
+	for _, c := range capabilities {
+		for _, g := range granted {
+			if c == g {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// match reports whether path matches glob, where a trailing "*" matches any
+// suffix and a "+" segment matches exactly one non-empty path segment.
+func match(glob, path string) bool {
+	prefix := strings.HasSuffix(glob, "*")
+	glob = strings.TrimSuffix(glob, "*")
+	for {
+		i := strings.IndexByte(glob, '+')
+		if i < 0 {
+			break
+		}
+		if !strings.HasPrefix(path, glob[:i]) {
+			return false
+		}
+		path, glob = path[i:], glob[i+1:]
+		j := strings.IndexByte(path, '/')
+		if j < 0 {
+			j = len(path)
+		}
+		if j == 0 {
+			return false
+		}
+		path = path[j:]
+	}
+	if prefix {
+		return strings.HasPrefix(path, glob)
+	}
+	return path == glob
+}
+
+// moreSpecific reports whether glob a takes precedence over glob b when both
+// match a path. The glob whose first wildcard comes later wins, then exact
+// globs over prefixes, fewer "+" segments, longer globs and finally the
+// lexically greater one.
+func moreSpecific(a, b string) bool {
+	if wa, wb := firstWildcard(a), firstWildcard(b); wa != wb {
+		return wa > wb
+	}
+	if pa, pb := strings.HasSuffix(a, "*"), strings.HasSuffix(b, "*"); pa != pb {
+		return !pa
+	}
+	if ca, cb := strings.Count(a, "+"), strings.Count(b, "+"); ca != cb {
+		return ca < cb
+	}
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return a > b
+}
+
+func firstWildcard(glob string) int {
+	if i := strings.IndexAny(glob, "+*"); i >= 0 {
+		return i
+	}
+	return len(glob)
+}
@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestACL(t *testing.T) {
+	app, err := Parse("app", []byte(`{"path": {
+		"hash": {"capabilities": ["hash"]},
+		"kv/data/app/*": {"capabilities": ["read", "list"]},
+		"kv/data/app/+/config": {"capabilities": ["update"]},
+		"kv/data/app/secret": {"capabilities": ["deny"]}
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops, err := Parse("ops", []byte(`{"path": {"kv/data/app/*": {"capabilities": ["create"]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	acl := NewACL(app, ops)
+
+	for _, tc := range []struct {
+		path string
+		want []string
+	}{
+		{"hash", []string{"hash"}},
+		{"validate", nil},
+		{"kv/data/app/db", []string{"create", "list", "read"}},
+		{"kv/data/app/web/config", []string{"update"}},
+		{"kv/data/app/secret", nil},
+		{"kv/data/other", nil},
+	} {
+		if have := acl.Capabilities(tc.path); !reflect.DeepEqual(tc.want, have) {
+			t.Errorf("%s: want %v, have %v", tc.path, tc.want, have)
+		}
+	}
+
+	if !acl.Allowed("kv/data/app/db", Update, Create) {
+		t.Error("create on kv/data/app/db: want allowed")
+	}
+	if acl.Allowed("kv/data/app/db", Delete) {
+		t.Error("delete on kv/data/app/db: want denied")
+	}
+	if !NewACL(&Policy{Name: RootPolicy}).Allowed("sys/rotate", Update) {
+		t.Error("root policy: want allowed")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, rules := range []string{
+		`{"path": {"kv/*/data": {"capabilities": ["read"]}}}`,
+		`{"path": {"kv/a+b": {"capabilities": ["read"]}}}`,
+		`{"path": {"kv/data": {"capabilities": ["write"]}}}`,
+		`{"paths": {}}`,
+	} {
+		if _, err := Parse("p", []byte(rules)); err == nil {
+			t.Errorf("%s: want error", rules)
+		}
+	}
+}
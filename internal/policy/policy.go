@@ -0,0 +1,135 @@
+// Package policy implements path based access control lists. A policy grants
+// capabilities on path globs, and the policies granted to a caller are
+// compiled into an ACL deciding whether a request is allowed.
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Capabilities granted by policies on paths.
+const (
+	Create   = "create"
+	Read     = "read"
+	Update   = "update"
+	Delete   = "delete"
+	List     = "list"
+	Sudo     = "sudo"
+	Hash     = "hash"
+	Validate = "validate"
+	// Deny overrides every other capability granted on a path.
+	Deny = "deny"
+)
+
+// Built-in policies.
+const (
+	// RootPolicy grants every capability on every path. It cannot be
+	// modified.
+	RootPolicy = "root"
+	// DefaultPolicy is granted to every authenticated caller. It is empty
+	// until written.
+	DefaultPolicy = "default"
+)
+
+var capabilities = map[string]bool{
+	Create: true, Read: true, Update: true, Delete: true, List: true,
+	Sudo: true, Hash: true, Validate: true, Deny: true,
+}
+
+var (
+	// ErrPermissionDenied is returned when a caller is not granted the
+	// capability required by a request.
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrInvalidPolicy is returned for malformed policy names or rules.
+	ErrInvalidPolicy = errors.New("invalid policy")
+	// ErrNotFound is returned when a policy does not exist.
+	ErrNotFound = errors.New("policy not found")
+	// ErrBuiltin is returned when modifying the root policy.
+	ErrBuiltin = errors.New("cannot modify built-in policy")
+	// ErrInvalidSubject is returned for subjects without a known auth
+	// method.
+	ErrInvalidSubject = errors.New("invalid subject")
+)
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// Policy grants capabilities on path globs. It is written in JSON:
+//
+//	{
+//	  "path": {
+//	    "hash":          {"capabilities": ["hash"]},
+//	    "kv/data/app/*": {"capabilities": ["create", "read", "update"]},
+//	    "kv/metadata/+": {"capabilities": ["list"]}
+//	  }
+//	}
+//
+// A trailing "*" matches any suffix and a "+" matches exactly one path
+// segment.
+type Policy struct {
+	Name  string               `json:"-"`
+	Paths map[string]PathRules `json:"path"`
+}
+
+// PathRules holds the rules of a path glob.
+type PathRules struct {
+	Capabilities []string `json:"capabilities"`
+}
+
+// Parse parses and validates the JSON rules of the named policy.
+func Parse(name string, rules []byte) (*Policy, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	p := &Policy{Name: name}
+	dec := json.NewDecoder(bytes.NewReader(rules))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPolicy, err)
+	}
+	for path, r := range p.Paths {
+		if err := validatePath(path); err != nil {
+			return nil, err
+		}
+		for _, c := range r.Capabilities {
+			if !capabilities[c] {
+				return nil, fmt.Errorf("%w: unknown capability %q on %q", ErrInvalidPolicy, c, path)
+			}
+		}
+	}
+	return p, nil
+}
+
+// ValidateName checks that name is a valid policy name: lowercase letters,
+// digits, dots, dashes and underscores.
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("%w: invalid name %q", ErrInvalidPolicy, name)
+	}
+	return nil
+}
+
+// Rules returns the JSON rules of the policy.
+func (p *Policy) Rules() []byte {
+	b, _ := json.Marshal(p)
+	return b
+}
+
+func validatePath(path string) error {
+	if path == "" {
+		return fmt.Errorf("%w: empty path", ErrInvalidPolicy)
+	}
+	if i := strings.IndexByte(path, '*'); i >= 0 && i != len(path)-1 {
+		return fmt.Errorf("%w: %q: \"*\" is only allowed at the end of a path", ErrInvalidPolicy, path)
+	}
+	for _, segment := range strings.Split(strings.TrimSuffix(path, "*"), "/") {
+		if strings.Contains(segment, "+") && segment != "+" {
+			return fmt.Errorf("%w: %q: \"+\" must be a whole path segment", ErrInvalidPolicy, path)
+		}
+	}
+	return nil
+}
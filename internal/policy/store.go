@@ -0,0 +1,182 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/williamlsh/vault/internal/store"
+)
+
+const (
+	policyPrefix  = "sys/policy/"
+	subjectPrefix = "sys/subject/"
+)
+
+// The auth methods whose subjects policies are attached to. Their subjects
+// are named independently, so they are kept apart.
+const (
+	MethodCert = "cert"
+	MethodJWT  = "jwt"
+)
+
+// Store persists policies and the policies attached to subjects.
+type Store struct {
+	storage store.Storage
+}
+
+// NewStore returns a Store persisting policies in the storage.
+func NewStore(s store.Storage) *Store {
+	return &Store{storage: s}
+}
+
+// Policy returns the named policy.
+func (s *Store) Policy(ctx context.Context, name string) (*Policy, error) {
+	if name == RootPolicy {
+		return &Policy{Name: RootPolicy}, nil
+	}
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	raw, err := s.storage.Get(ctx, policyPrefix+name)
+	if err == store.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(name, raw)
+}
+
+// SetPolicy creates or replaces a policy.
+func (s *Store) SetPolicy(ctx context.Context, p *Policy) error {
+	if p.Name == RootPolicy {
+		return ErrBuiltin
+	}
+	if err := ValidateName(p.Name); err != nil {
+		return err
+	}
+	return s.storage.Put(ctx, policyPrefix+p.Name, p.Rules())
+}
+
+// DeletePolicy removes the named policy. Subjects it is attached to simply
+// stop being granted its capabilities.
+func (s *Store) DeletePolicy(ctx context.Context, name string) error {
+	if name == RootPolicy {
+		return ErrBuiltin
+	}
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	return s.storage.Delete(ctx, policyPrefix+name)
+}
+
+// ListPolicies returns the names of all policies, including the built-in
+// root policy.
+func (s *Store) ListPolicies(ctx context.Context) ([]string, error) {
+	names, err := s.storage.List(ctx, policyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	names = append(names, RootPolicy)
+	sort.Strings(names)
+	return names, nil
+}
+
+// SubjectPolicies returns the names of the policies attached to the subject
+// authenticated by the auth method, always including the default policy.
+func (s *Store) SubjectPolicies(ctx context.Context, method, subject string) ([]string, error) {
+	names := []string{DefaultPolicy}
+	if subject == "" {
+		return names, nil
+	}
+	key, err := subjectKey(method, subject)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := s.storage.Get(ctx, key)
+	if err == store.ErrNotFound {
+		return names, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var attached []string
+	if err := json.Unmarshal(raw, &attached); err != nil {
+		return nil, err
+	}
+	return dedup(append(names, attached...)), nil
+}
+
+// SetSubjectPolicies attaches the named policies to the subject authenticated
+// by the auth method, replacing the previous ones. An empty list detaches all
+// policies.
+func (s *Store) SetSubjectPolicies(ctx context.Context, method, subject string, names []string) error {
+	key, err := subjectKey(method, subject)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := ValidateName(name); err != nil {
+			return err
+		}
+	}
+	if len(names) == 0 {
+		return s.storage.Delete(ctx, key)
+	}
+	raw, err := json.Marshal(dedup(names))
+	if err != nil {
+		return err
+	}
+	return s.storage.Put(ctx, key, raw)
+}
+
+// SplitSubject splits a subject path, the auth method followed by the
+// subject like "jwt/ci", into its method and subject.
+func SplitSubject(path string) (method, subject string, err error) {
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 {
+		return "", "", ErrInvalidSubject
+	}
+	if _, err := subjectKey(parts[0], parts[1]); err != nil {
+		return "", "", err
+	}
+	return parts[0], parts[1], nil
+}
+
+func subjectKey(method, subject string) (string, error) {
+	if subject == "" || (method != MethodCert && method != MethodJWT) {
+		return "", ErrInvalidSubject
+	}
+	return subjectPrefix + method + "/" + subject, nil
+}
+
+// ACL compiles the named policies into an ACL. Policies which do not exist
+// are ignored.
+func (s *Store) ACL(ctx context.Context, names ...string) (*ACL, error) {
+	policies := make([]*Policy, 0, len(names))
+	for _, name := range names {
+		p, err := s.Policy(ctx, name)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return NewACL(policies...), nil
+}
+
+func dedup(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	out := names[:0:0]
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	return out
+}
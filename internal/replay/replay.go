@@ -0,0 +1,113 @@
+// Package replay refuses the JWTs presented more than once. Token IDs (the
+// jti claim) are remembered until the token expires, in memory and, so that
+// every replica of vaultd refuses a token already accepted by another, in a
+// shared storage.
+package replay
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+var (
+	// ErrReplayed is returned when checking a token ID already seen.
+	ErrReplayed = errors.New("token replayed")
+	// ErrMissingID is returned when checking a token without an ID, which
+	// cannot be told apart from its replays.
+	ErrMissingID = errors.New("token has no ID")
+)
+
+// Storage remembers token IDs across replicas.
+type Storage interface {
+	// Record remembers the token ID until exp, reporting false if it was
+	// already remembered.
+	Record(ctx context.Context, id string, exp time.Time) (bool, error)
+	// Purge forgets the token IDs expired at now.
+	Purge(ctx context.Context, now time.Time) error
+}
+
+// Cache remembers the IDs of the tokens seen until they expire.
+type Cache struct {
+	storage Storage
+	now     func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewCache returns a Cache backed by the storage. A nil storage keeps the
+// token IDs in memory only, which suits a single replica.
+func NewCache(s Storage) *Cache {
+	return &Cache{storage: s, now: time.Now, seen: make(map[string]time.Time)}
+}
+
+// Check remembers the token ID until exp, returning ErrReplayed if it was
+// seen before. Tokens seen by this replica are refused without a storage
+// lookup.
+func (c *Cache) Check(ctx context.Context, id string, exp time.Time) error {
+	if id == "" {
+		return ErrMissingID
+	}
+	now := c.now()
+	c.mu.Lock()
+	if e, ok := c.seen[id]; ok && e.After(now) {
+		c.mu.Unlock()
+		return ErrReplayed
+	}
+	c.seen[id] = exp
+	c.mu.Unlock()
+
+	if c.storage == nil {
+		return nil
+	}
+	fresh, err := c.storage.Record(ctx, id, exp)
+	if err != nil {
+		// Let the token be retried once the storage is back.
+		c.mu.Lock()
+		delete(c.seen, id)
+		c.mu.Unlock()
+		return err
+	}
+	if !fresh {
+		return ErrReplayed
+	}
+	return nil
+}
+
+// Purge forgets the token IDs expired at now, in memory and in the storage.
+func (c *Cache) Purge(ctx context.Context) error {
+	now := c.now()
+	c.mu.Lock()
+	for id, exp := range c.seen {
+		if !exp.After(now) {
+			delete(c.seen, id)
+		}
+	}
+	c.mu.Unlock()
+
+	if c.storage == nil {
+		return nil
+	}
+	return c.storage.Purge(ctx, now)
+}
+
+// Run purges the expired token IDs every interval until ctx is done, logging
+// the failed purges.
+func (c *Cache) Run(ctx context.Context, interval time.Duration, logger log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Purge(ctx); err != nil {
+				logger.Log("during", "purge", "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package replay_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/williamlsh/vault/internal/mock"
+	"github.com/williamlsh/vault/internal/replay"
+)
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	storage := mock.NewReplayStorage()
+	a, b := replay.NewCache(storage), replay.NewCache(storage)
+	exp := time.Now().Add(time.Hour)
+
+	if err := a.Check(ctx, "1", exp); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if want, have := replay.ErrReplayed, a.Check(ctx, "1", exp); want != have {
+		t.Errorf("replay on the same replica: want %v, have %v", want, have)
+	}
+	if want, have := replay.ErrReplayed, b.Check(ctx, "1", exp); want != have {
+		t.Errorf("replay on another replica: want %v, have %v", want, have)
+	}
+	if err := b.Check(ctx, "2", exp); err != nil {
+		t.Errorf("other token: %v", err)
+	}
+	if want, have := replay.ErrMissingID, a.Check(ctx, "", exp); want != have {
+		t.Errorf("no ID: want %v, have %v", want, have)
+	}
+
+	// Expired IDs are forgotten, the tokens being refused on their own.
+	if err := a.Check(ctx, "3", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("expired: %v", err)
+	}
+	if err := a.Purge(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Check(ctx, "3", exp); err != nil {
+		t.Errorf("expired ID: want forgotten, have %v", err)
+	}
+
+	local := replay.NewCache(nil)
+	if err := local.Check(ctx, "1", exp); err != nil {
+		t.Errorf("memory only: %v", err)
+	}
+	if want, have := replay.ErrReplayed, local.Check(ctx, "1", exp); want != have {
+		t.Errorf("memory only replay: want %v, have %v", want, have)
+	}
+}
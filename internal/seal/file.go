@@ -0,0 +1,52 @@
+package seal
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+func init() {
+	Register("file", func(config map[string]string) (KeyProvider, error) {
+		path := config["path"]
+		if path == "" {
+			return nil, errors.New("seal: file key provider requires a path")
+		}
+		return NewFileKeyProvider(path)
+	})
+}
+
+// NewFileKeyProvider returns a KeyProvider wrapping the root key with the
+// AES-256 key read from path. The file holds either 32 raw bytes or their hex
+// encoding, e.g. the output of `openssl rand -hex 32`.
+func NewFileKeyProvider(path string) (KeyProvider, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("seal: reading key file: %w", err)
+	}
+	key := b
+	if trimmed := bytes.TrimSpace(b); len(trimmed) == 2*keySize {
+		if key, err = hex.DecodeString(string(trimmed)); err != nil {
+			return nil, fmt.Errorf("seal: decoding key file: %w", err)
+		}
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("seal: key file must hold a %d byte key", keySize)
+	}
+	return fileKeyProvider{key: key}, nil
+}
+
+type fileKeyProvider struct {
+	key []byte
+}
+
+func (p fileKeyProvider) Wrap(_ context.Context, key []byte) ([]byte, error) {
+	return encrypt(p.key, key, nil)
+}
+
+func (p fileKeyProvider) Unwrap(_ context.Context, wrapped []byte) ([]byte, error) {
+	return decrypt(p.key, wrapped, nil)
+}
@@ -0,0 +1,201 @@
+package seal
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// keyring holds the versioned data encryption keys. It is persisted encrypted
+// with the root key, so rotating a term does not require new unseal keys.
+type keyring struct {
+	ActiveTerm uint32           `json:"active_term"`
+	Terms      map[uint32]*term `json:"terms"`
+}
+
+type term struct {
+	Key         []byte    `json:"key"`
+	InstallTime time.Time `json:"install_time"`
+}
+
+// KeyStatus describes the active keyring term.
+type KeyStatus struct {
+	Term        uint32    `json:"term"`
+	InstallTime time.Time `json:"install_time"`
+}
+
+// Envelope is a value encrypted with its own random data key. The data key is
+// in turn encrypted with the keyring term identified by KeyVersion.
+type Envelope struct {
+	Ciphertext []byte
+	DataKey    []byte
+	KeyVersion uint32
+}
+
+func newKeyring() (*keyring, error) {
+	kr := &keyring{Terms: make(map[uint32]*term)}
+	if err := kr.rotate(); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+func (kr *keyring) rotate() error {
+	key, err := randomKey()
+	if err != nil {
+		return err
+	}
+	kr.ActiveTerm++
+	kr.Terms[kr.ActiveTerm] = &term{Key: key, InstallTime: time.Now().UTC()}
+	return nil
+}
+
+func (kr *keyring) status() KeyStatus {
+	return KeyStatus{Term: kr.ActiveTerm, InstallTime: kr.Terms[kr.ActiveTerm].InstallTime}
+}
+
+func encryptKeyring(rootKey []byte, kr *keyring) ([]byte, error) {
+	b, err := json.Marshal(kr)
+	if err != nil {
+		return nil, err
+	}
+	return encrypt(rootKey, b, nil)
+}
+
+func decryptKeyring(rootKey, ciphertext []byte) (*keyring, error) {
+	b, err := decrypt(rootKey, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("seal: decrypting keyring: %w", err)
+	}
+	var kr keyring
+	if err := json.Unmarshal(b, &kr); err != nil {
+		return nil, err
+	}
+	return &kr, nil
+}
+
+// unlock installs the root key and loads the keyring, creating it for vaults
+// initialized before keyrings existed. The caller holds the write lock.
+func (s *Seal) unlock(ctx context.Context, cfg *Config, rootKey []byte) error {
+	var (
+		kr  *keyring
+		err error
+	)
+	if cfg.EncryptedKeyring != nil {
+		if kr, err = decryptKeyring(rootKey, cfg.EncryptedKeyring); err != nil {
+			return err
+		}
+	} else {
+		if kr, err = newKeyring(); err != nil {
+			return err
+		}
+		if cfg.EncryptedKeyring, err = encryptKeyring(rootKey, kr); err != nil {
+			return err
+		}
+		if err := s.storage.SetSealConfig(ctx, cfg); err != nil {
+			return err
+		}
+	}
+	s.rootKey = rootKey
+	s.keyring = kr
+	return nil
+}
+
+// Rotate installs a new keyring term used for all subsequent encryptions.
+// Values encrypted with older terms remain readable.
+func (s *Seal) Rotate(ctx context.Context) (KeyStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rootKey == nil {
+		return KeyStatus{}, ErrSealed
+	}
+
+	cfg, err := s.storage.SealConfig(ctx)
+	if err != nil {
+		return KeyStatus{}, err
+	}
+	kr := &keyring{ActiveTerm: s.keyring.ActiveTerm, Terms: make(map[uint32]*term, len(s.keyring.Terms)+1)}
+	for v, t := range s.keyring.Terms {
+		kr.Terms[v] = t
+	}
+	if err := kr.rotate(); err != nil {
+		return KeyStatus{}, err
+	}
+	if cfg.EncryptedKeyring, err = encryptKeyring(s.rootKey, kr); err != nil {
+		return KeyStatus{}, err
+	}
+	if err := s.storage.SetSealConfig(ctx, cfg); err != nil {
+		return KeyStatus{}, err
+	}
+	s.keyring = kr
+	return kr.status(), nil
+}
+
+// KeyStatus returns the active keyring term.
+func (s *Seal) KeyStatus() (KeyStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.keyring == nil {
+		return KeyStatus{}, ErrSealed
+	}
+	return s.keyring.status(), nil
+}
+
+// Encrypt encrypts plaintext with a fresh data key wrapped by the active
+// keyring term. The envelope is bound to the additional data, such as the key
+// of the row storing it, and to its key version, so that it only decrypts
+// with the same additional data: envelopes swapped between rows fail to
+// decrypt.
+func (s *Seal) Encrypt(plaintext, additionalData []byte) (Envelope, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.keyring == nil {
+		return Envelope{}, ErrSealed
+	}
+
+	dataKey, err := randomKey()
+	if err != nil {
+		return Envelope{}, err
+	}
+	aad := envelopeAAD(additionalData, s.keyring.ActiveTerm)
+	ciphertext, err := encrypt(dataKey, plaintext, aad)
+	if err != nil {
+		return Envelope{}, err
+	}
+	wrapped, err := encrypt(s.keyring.Terms[s.keyring.ActiveTerm].Key, dataKey, aad)
+	if err != nil {
+		return Envelope{}, err
+	}
+	return Envelope{Ciphertext: ciphertext, DataKey: wrapped, KeyVersion: s.keyring.ActiveTerm}, nil
+}
+
+// Decrypt opens an envelope produced by Encrypt with the same additional
+// data.
+func (s *Seal) Decrypt(e Envelope, additionalData []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.keyring == nil {
+		return nil, ErrSealed
+	}
+
+	t, ok := s.keyring.Terms[e.KeyVersion]
+	if !ok {
+		return nil, fmt.Errorf("seal: unknown key version %d", e.KeyVersion)
+	}
+	aad := envelopeAAD(additionalData, e.KeyVersion)
+	dataKey, err := decrypt(t.Key, e.DataKey, aad)
+	if err != nil {
+		return nil, fmt.Errorf("seal: decrypting data key: %w", err)
+	}
+	return decrypt(dataKey, e.Ciphertext, aad)
+}
+
+// envelopeAAD returns the additional data authenticated by the envelopes: the
+// key version, big endian, followed by the additional data of the caller.
+func envelopeAAD(additionalData []byte, keyVersion uint32) []byte {
+	aad := make([]byte, 4, 4+len(additionalData))
+	binary.BigEndian.PutUint32(aad, keyVersion)
+	return append(aad, additionalData...)
+}
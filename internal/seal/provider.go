@@ -0,0 +1,91 @@
+package seal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// KeyProvider wraps the root key with a key held outside of the database,
+// such as a local key file or an external key management service, so that
+// vaultd can unseal itself on boot.
+type KeyProvider interface {
+	// Wrap encrypts the root key.
+	Wrap(ctx context.Context, key []byte) ([]byte, error)
+	// Unwrap decrypts a root key previously encrypted by Wrap.
+	Unwrap(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// Factory makes a KeyProvider from its key/value configuration.
+type Factory func(config map[string]string) (KeyProvider, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]Factory)
+)
+
+// Register makes a key provider available by name. External KMS plugins call
+// it from an init function, in the same way database/sql drivers register
+// themselves. Register panics if it is called twice with the same name.
+func Register(name string, factory Factory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	if factory == nil {
+		panic("seal: Register factory is nil")
+	}
+	if _, dup := providers[name]; dup {
+		panic("seal: Register called twice for key provider " + name)
+	}
+	providers[name] = factory
+}
+
+// Providers returns the sorted names of the registered key providers.
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewKeyProvider makes the key provider registered under name.
+func NewKeyProvider(name string, config map[string]string) (KeyProvider, error) {
+	providersMu.RLock()
+	factory, ok := providers[name]
+	providersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("seal: unknown key provider %q (registered: %v)", name, Providers())
+	}
+	return factory(config)
+}
+
+// KMS is implemented by external key management plugins. It is modeled after
+// the PKCS#11 C_WrapKey and C_UnwrapKey operations: the wrapping key never
+// leaves the device and is referred to by its label.
+type KMS interface {
+	WrapKey(ctx context.Context, label string, key []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, label string, wrapped []byte) ([]byte, error)
+}
+
+// NewKMSKeyProvider adapts a KMS plugin to a KeyProvider wrapping the root key
+// with the device key named label.
+func NewKMSKeyProvider(kms KMS, label string) KeyProvider {
+	return kmsKeyProvider{kms: kms, label: label}
+}
+
+type kmsKeyProvider struct {
+	kms   KMS
+	label string
+}
+
+func (p kmsKeyProvider) Wrap(ctx context.Context, key []byte) ([]byte, error) {
+	return p.kms.WrapKey(ctx, p.label, key)
+}
+
+func (p kmsKeyProvider) Unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return p.kms.UnwrapKey(ctx, p.label, wrapped)
+}
@@ -0,0 +1,312 @@
+// Package seal guards the root key of vaultd. The root key never touches the
+// disk in the clear: it is encrypted with an unseal key which is split into
+// Shamir shares at initialization time and handed out to key holders. Until a
+// threshold of shares is submitted again the vault stays sealed.
+//
+// When a KeyProvider is configured the root key is additionally wrapped by it
+// so the vault unseals itself on boot, while the Shamir shares remain usable
+// as recovery keys.
+//
+// While unsealed, the root key unlocks a keyring of versioned data keys used
+// to envelope encrypt everything vaultd stores.
+package seal
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/williamlsh/vault/internal/shamir"
+)
+
+const keySize = 32
+
+var (
+	// ErrSealed is returned by operations that need the root key while the
+	// vault is sealed.
+	ErrSealed = errors.New("vault is sealed")
+	// ErrNotInitialized is returned when unsealing a vault that has never
+	// been initialized.
+	ErrNotInitialized = errors.New("vault is not initialized")
+	// ErrAlreadyInitialized is returned when initializing twice.
+	ErrAlreadyInitialized = errors.New("vault is already initialized")
+	// ErrInvalidKey is returned when the submitted unseal keys do not
+	// reconstruct the unseal key.
+	ErrInvalidKey = errors.New("invalid unseal key")
+	// ErrInvalidConfig is returned for unusable share and threshold counts.
+	ErrInvalidConfig = errors.New("invalid seal configuration")
+)
+
+// Config is the persisted seal configuration.
+type Config struct {
+	SecretShares    int `json:"secret_shares"`
+	SecretThreshold int `json:"secret_threshold"`
+	// EncryptedRootKey is the root key encrypted with the unseal key.
+	EncryptedRootKey []byte `json:"encrypted_root_key"`
+	// WrappedRootKey is the root key wrapped by the key provider, if any.
+	WrappedRootKey []byte `json:"wrapped_root_key,omitempty"`
+	// EncryptedKeyring is the keyring encrypted with the root key.
+	EncryptedKeyring []byte `json:"encrypted_keyring,omitempty"`
+}
+
+// Storage persists the seal configuration.
+type Storage interface {
+	// SealConfig returns the stored configuration, or nil if the vault has
+	// not been initialized yet.
+	SealConfig(ctx context.Context) (*Config, error)
+	// SetSealConfig stores the configuration.
+	SetSealConfig(ctx context.Context, cfg *Config) error
+}
+
+// Status describes the seal state of the vault.
+type Status struct {
+	Initialized bool `json:"initialized"`
+	Sealed      bool `json:"sealed"`
+	Threshold   int  `json:"t"`
+	Shares      int  `json:"n"`
+	Progress    int  `json:"progress"`
+	AutoUnseal  bool `json:"auto_unseal"`
+}
+
+// Seal holds the root key while the vault is unsealed.
+type Seal struct {
+	storage  Storage
+	provider KeyProvider
+
+	mu       sync.RWMutex
+	rootKey  []byte
+	keyring  *keyring
+	progress [][]byte
+}
+
+// New returns a sealed Seal backed by the storage.
+func New(storage Storage) *Seal {
+	return &Seal{storage: storage}
+}
+
+// NewAutoSeal returns a sealed Seal backed by the storage which wraps the root
+// key with the key provider and can therefore unseal itself with AutoUnseal.
+func NewAutoSeal(storage Storage, provider KeyProvider) *Seal {
+	return &Seal{storage: storage, provider: provider}
+}
+
+// Initialize generates a new root key and returns the unseal key split into
+// shares, threshold of which are needed to unseal. The vault stays sealed,
+// unless a key provider is configured: then it is unsealed right away and the
+// shares only serve as recovery keys.
+func (s *Seal) Initialize(ctx context.Context, shares, threshold int) ([][]byte, error) {
+	if shares < 1 || threshold < 1 || threshold > shares || (shares > 1 && threshold < 2) {
+		return nil, ErrInvalidConfig
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg, err := s.storage.SealConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cfg != nil {
+		return nil, ErrAlreadyInitialized
+	}
+
+	rootKey, err := randomKey()
+	if err != nil {
+		return nil, err
+	}
+	unsealKey, err := randomKey()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := encrypt(unsealKey, rootKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	kr, err := newKeyring()
+	if err != nil {
+		return nil, err
+	}
+	encryptedKeyring, err := encryptKeyring(rootKey, kr)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := [][]byte{unsealKey}
+	if shares > 1 {
+		if keys, err = shamir.Split(unsealKey, shares, threshold); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg = &Config{
+		SecretShares:     shares,
+		SecretThreshold:  threshold,
+		EncryptedRootKey: encrypted,
+		EncryptedKeyring: encryptedKeyring,
+	}
+	if s.provider != nil {
+		if cfg.WrappedRootKey, err = s.provider.Wrap(ctx, rootKey); err != nil {
+			return nil, fmt.Errorf("seal: wrapping root key: %w", err)
+		}
+	}
+	if err := s.storage.SetSealConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+	if s.provider != nil {
+		s.rootKey = rootKey
+		s.keyring = kr
+	}
+	return keys, nil
+}
+
+// AutoUnseal unseals the vault with the root key wrapped by the key provider.
+func (s *Seal) AutoUnseal(ctx context.Context) error {
+	if s.provider == nil {
+		return errors.New("seal: no key provider configured")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg, err := s.storage.SealConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		return ErrNotInitialized
+	}
+	if s.rootKey != nil {
+		return nil
+	}
+	if cfg.WrappedRootKey == nil {
+		// The vault was initialized before the key provider was configured.
+		// The root key is wrapped on the next unseal with the recovery keys.
+		return errors.New("seal: root key is not wrapped by the key provider yet, unseal with the recovery keys")
+	}
+	rootKey, err := s.provider.Unwrap(ctx, cfg.WrappedRootKey)
+	if err != nil {
+		return fmt.Errorf("seal: unwrapping root key: %w", err)
+	}
+	s.progress = nil
+	return s.unlock(ctx, cfg, rootKey)
+}
+
+// Unseal submits one unseal key share. Once the threshold is reached the root
+// key is recovered and the vault is unsealed. Submitting a share to an
+// unsealed vault is a no-op.
+func (s *Seal) Unseal(ctx context.Context, key []byte) (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg, err := s.storage.SealConfig(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	if cfg == nil {
+		return Status{}, ErrNotInitialized
+	}
+	if s.rootKey != nil {
+		return s.status(cfg), nil
+	}
+
+	for _, p := range s.progress {
+		if bytes.Equal(p, key) {
+			return s.status(cfg), nil
+		}
+	}
+	s.progress = append(s.progress, append([]byte(nil), key...))
+	if len(s.progress) < cfg.SecretThreshold {
+		return s.status(cfg), nil
+	}
+
+	// Whatever the outcome, the submitted shares are discarded.
+	progress := s.progress
+	s.progress = nil
+
+	unsealKey := progress[0]
+	if cfg.SecretThreshold > 1 {
+		if unsealKey, err = shamir.Combine(progress); err != nil {
+			return s.status(cfg), fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+	}
+	rootKey, err := decrypt(unsealKey, cfg.EncryptedRootKey, nil)
+	if err != nil {
+		return s.status(cfg), ErrInvalidKey
+	}
+	if s.provider != nil && cfg.WrappedRootKey == nil {
+		// Migrate a Shamir sealed vault to auto-unseal.
+		if cfg.WrappedRootKey, err = s.provider.Wrap(ctx, rootKey); err != nil {
+			return s.status(cfg), fmt.Errorf("seal: wrapping root key: %w", err)
+		}
+		if err := s.storage.SetSealConfig(ctx, cfg); err != nil {
+			return s.status(cfg), err
+		}
+	}
+	if err := s.unlock(ctx, cfg, rootKey); err != nil {
+		return s.status(cfg), err
+	}
+	return s.status(cfg), nil
+}
+
+// ResetUnseal discards the shares submitted so far.
+func (s *Seal) ResetUnseal() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.progress = nil
+}
+
+// Seal forgets the root key. The vault has to be unsealed again before it can
+// serve requests.
+func (s *Seal) Seal() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.rootKey {
+		s.rootKey[i] = 0
+	}
+	s.rootKey = nil
+	s.keyring = nil
+	s.progress = nil
+}
+
+// Sealed reports whether the vault is sealed.
+func (s *Seal) Sealed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rootKey == nil
+}
+
+// Status returns the current seal status.
+func (s *Seal) Status(ctx context.Context) (Status, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cfg, err := s.storage.SealConfig(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	return s.status(cfg), nil
+}
+
+func (s *Seal) status(cfg *Config) Status {
+	if cfg == nil {
+		return Status{Sealed: true, AutoUnseal: s.provider != nil}
+	}
+	return Status{
+		Initialized: true,
+		Sealed:      s.rootKey == nil,
+		Threshold:   cfg.SecretThreshold,
+		Shares:      cfg.SecretShares,
+		Progress:    len(s.progress),
+		AutoUnseal:  s.provider != nil,
+	}
+}
+
+func randomKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,131 @@
+package seal
+
+import (
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+type memStorage struct {
+	cfg *Config
+}
+
+func (m *memStorage) SealConfig(context.Context) (*Config, error) { return m.cfg, nil }
+
+func (m *memStorage) SetSealConfig(_ context.Context, cfg *Config) error {
+	m.cfg = cfg
+	return nil
+}
+
+func TestShamirUnseal(t *testing.T) {
+	ctx := context.Background()
+	s := New(&memStorage{})
+
+	if _, err := s.Unseal(ctx, []byte("key")); err != ErrNotInitialized {
+		t.Fatalf("unseal before init: want %v, have %v", ErrNotInitialized, err)
+	}
+	if _, err := s.Initialize(ctx, 3, 1); err != ErrInvalidConfig {
+		t.Fatalf("threshold of one with three shares: want %v, have %v", ErrInvalidConfig, err)
+	}
+	keys, err := s.Initialize(ctx, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Initialize(ctx, 3, 2); err != ErrAlreadyInitialized {
+		t.Fatalf("second init: want %v, have %v", ErrAlreadyInitialized, err)
+	}
+	if !s.Sealed() {
+		t.Fatal("vault unsealed by init")
+	}
+
+	// A corrupted share fails the attempt and resets the progress.
+	bad := append([]byte(nil), keys[1]...)
+	bad[0] ^= 0xff
+	s.Unseal(ctx, keys[0])
+	if _, err := s.Unseal(ctx, bad); err != ErrInvalidKey {
+		t.Fatalf("corrupted share: want %v, have %v", ErrInvalidKey, err)
+	}
+
+	status, err := s.Unseal(ctx, keys[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Sealed || status.Progress != 1 {
+		t.Fatalf("after one share: want sealed with progress 1, have %+v", status)
+	}
+	if status, err = s.Unseal(ctx, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	if status.Sealed || s.Sealed() {
+		t.Fatalf("after threshold shares: want unsealed, have %+v", status)
+	}
+
+	env, err := s.Encrypt([]byte("hash"), []byte("secret/1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks, err := s.Rotate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := env.KeyVersion+1, ks.Term; want != have {
+		t.Errorf("rotated term: want %d, have %d", want, have)
+	}
+	if b, err := s.Decrypt(env, []byte("secret/1")); err != nil || string(b) != "hash" {
+		t.Errorf("decrypt with previous term: have %q, %v", b, err)
+	}
+	if _, err := s.Decrypt(env, []byte("secret/2")); err == nil {
+		t.Error("decrypted an envelope moved to another row")
+	}
+	if env, _ = s.Encrypt([]byte("hash"), []byte("secret/1")); env.KeyVersion != ks.Term {
+		t.Errorf("encrypt after rotation: want key version %d, have %d", ks.Term, env.KeyVersion)
+	}
+
+	s.Seal()
+	if !s.Sealed() {
+		t.Fatal("vault still unsealed after seal")
+	}
+	if _, err := s.Decrypt(env, []byte("secret/1")); err != ErrSealed {
+		t.Errorf("decrypt while sealed: want %v, have %v", ErrSealed, err)
+	}
+}
+
+func TestAutoUnseal(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "unseal.key")
+	if err := ioutil.WriteFile(path, []byte(hex.EncodeToString(make([]byte, keySize))+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := NewKeyProvider("file", map[string]string{"path": path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage := &memStorage{}
+	s := NewAutoSeal(storage, provider)
+	recovery, err := s.Initialize(ctx, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Sealed() {
+		t.Fatal("auto seal still sealed after init")
+	}
+
+	// A restarted daemon unseals itself.
+	restarted := NewAutoSeal(storage, provider)
+	if err := restarted.AutoUnseal(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if restarted.Sealed() {
+		t.Fatal("auto unseal left the vault sealed")
+	}
+
+	// The recovery keys still unseal the vault without the provider.
+	manual := New(storage)
+	manual.Unseal(ctx, recovery[0])
+	if status, err := manual.Unseal(ctx, recovery[1]); err != nil || status.Sealed {
+		t.Fatalf("recovery unseal: have %+v, %v", status, err)
+	}
+}
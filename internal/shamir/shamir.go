@@ -0,0 +1,151 @@
+// Package shamir implements Shamir's secret sharing over GF(2^8). A secret is
+// split into n shares of which any t can reconstruct it, while t-1 shares
+// reveal nothing about it.
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+const (
+	// ShareOverhead is the number of bytes a share adds to the secret length,
+	// it holds the x coordinate of the share.
+	ShareOverhead = 1
+	maxParts      = 255
+)
+
+var (
+	ErrInvalidParts     = errors.New("shamir: parts must be between threshold and 255")
+	ErrInvalidThreshold = errors.New("shamir: threshold must be between 2 and 255")
+	ErrEmptySecret      = errors.New("shamir: cannot split an empty secret")
+	ErrTooFewParts      = errors.New("shamir: at least two parts are required to reconstruct the secret")
+	ErrPartLength       = errors.New("shamir: all parts must be the same length and longer than one byte")
+	ErrDuplicatePart    = errors.New("shamir: duplicate part detected")
+)
+
+// Split divides secret into parts shares, threshold of which are required to
+// reconstruct it. Each share is len(secret)+ShareOverhead bytes long.
+func Split(secret []byte, parts, threshold int) ([][]byte, error) {
+	switch {
+	case threshold < 2 || threshold > maxParts:
+		return nil, ErrInvalidThreshold
+	case parts < threshold || parts > maxParts:
+		return nil, ErrInvalidParts
+	case len(secret) == 0:
+		return nil, ErrEmptySecret
+	}
+
+	xs, err := xCoordinates(parts)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([][]byte, parts)
+	for i := range out {
+		out[i] = make([]byte, len(secret)+ShareOverhead)
+		out[i][len(secret)] = xs[i]
+	}
+
+	coeffs := make([]byte, threshold)
+	for idx, b := range secret {
+		// The intercept of each polynomial is one byte of the secret, the
+		// remaining coefficients are random.
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, fmt.Errorf("shamir: generating polynomial: %w", err)
+		}
+		coeffs[0] = b
+		for i, x := range xs {
+			out[i][idx] = evaluate(coeffs, x)
+		}
+	}
+	return out, nil
+}
+
+// Combine reconstructs a secret from shares produced by Split. It cannot tell
+// whether enough shares were supplied: too few shares yield a wrong secret
+// rather than an error, so callers must verify the result.
+func Combine(parts [][]byte) ([]byte, error) {
+	if len(parts) < 2 {
+		return nil, ErrTooFewParts
+	}
+	length := len(parts[0])
+	if length < 2 {
+		return nil, ErrPartLength
+	}
+	xs := make([]byte, len(parts))
+	seen := make(map[byte]bool, len(parts))
+	for i, p := range parts {
+		if len(p) != length {
+			return nil, ErrPartLength
+		}
+		x := p[length-1]
+		if seen[x] {
+			return nil, ErrDuplicatePart
+		}
+		seen[x] = true
+		xs[i] = x
+	}
+
+	secret := make([]byte, length-1)
+	ys := make([]byte, len(parts))
+	for idx := range secret {
+		for i, p := range parts {
+			ys[i] = p[idx]
+		}
+		secret[idx] = interpolate(xs, ys)
+	}
+	return secret, nil
+}
+
+// xCoordinates returns n distinct, non-zero x coordinates in random order.
+func xCoordinates(n int) ([]byte, error) {
+	perm := make([]byte, maxParts)
+	for i := range perm {
+		perm[i] = byte(i + 1)
+	}
+	// Fisher-Yates shuffle driven by crypto/rand.
+	var r [1]byte
+	for i := len(perm) - 1; i > 0; i-- {
+		for {
+			if _, err := rand.Read(r[:]); err != nil {
+				return nil, fmt.Errorf("shamir: generating coordinates: %w", err)
+			}
+			// Reject values that would bias the modulo.
+			if int(r[0]) < 256-256%(i+1) {
+				break
+			}
+		}
+		j := int(r[0]) % (i + 1)
+		perm[i], perm[j] = perm[j], perm[i]
+	}
+	return perm[:n], nil
+}
+
+// evaluate returns the value of the polynomial with the given coefficients
+// at x using Horner's method.
+func evaluate(coeffs []byte, x byte) byte {
+	var out byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		out = add(mul(out, x), coeffs[i])
+	}
+	return out
+}
+
+// interpolate evaluates the Lagrange polynomial through the points (xs, ys)
+// at x = 0.
+func interpolate(xs, ys []byte) byte {
+	var out byte
+	for i := range xs {
+		basis := byte(1)
+		for j := range xs {
+			if i == j {
+				continue
+			}
+			basis = mul(basis, div(xs[j], add(xs[i], xs[j])))
+		}
+		out = add(out, mul(ys[i], basis))
+	}
+	return out
+}
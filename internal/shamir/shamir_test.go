@@ -0,0 +1,65 @@
+package shamir
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("correct horse battery staple")
+	parts, err := Split(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 5, len(parts); want != have {
+		t.Fatalf("parts: want %d, have %d", want, have)
+	}
+
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		var chosen [][]byte
+		for _, i := range subset {
+			chosen = append(chosen, parts[i])
+		}
+		have, err := Combine(chosen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(secret, have) {
+			t.Errorf("subset %v: want %q, have %q", subset, secret, have)
+		}
+	}
+
+	have, err := Combine(parts[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(secret, have) {
+		t.Error("two of three required parts reconstructed the secret")
+	}
+}
+
+func TestInvalidInput(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		secret           []byte
+		parts, threshold int
+		want             error
+	}{
+		{"threshold too low", []byte("s"), 3, 1, ErrInvalidThreshold},
+		{"parts below threshold", []byte("s"), 2, 3, ErrInvalidParts},
+		{"too many parts", []byte("s"), 256, 3, ErrInvalidParts},
+		{"empty secret", nil, 3, 2, ErrEmptySecret},
+	} {
+		if _, err := Split(tc.secret, tc.parts, tc.threshold); err != tc.want {
+			t.Errorf("%s: want %v, have %v", tc.name, tc.want, err)
+		}
+	}
+
+	parts, _ := Split([]byte("secret"), 3, 2)
+	if _, err := Combine([][]byte{parts[0], parts[0]}); err != ErrDuplicatePart {
+		t.Errorf("duplicate parts: want %v, have %v", ErrDuplicatePart, err)
+	}
+	if _, err := Combine([][]byte{parts[0], parts[1][:3]}); err != ErrPartLength {
+		t.Errorf("mismatched parts: want %v, have %v", ErrPartLength, err)
+	}
+}
@@ -1,4 +1,72 @@
-create table secret (
+-- The schema is applied by vaultd on startup, so every statement must be
+-- idempotent: existing databases are migrated by the same statements.
+
+-- hash holds the password hash encrypted with a per row data key. data_key is
+-- that data key encrypted with the keyring term key_version. Both are added
+-- as nullable columns to the tables of previous versions, whose rows are
+-- encrypted once the vault is unsealed before the columns are made not null,
+-- see store.MigrateSecrets.
+create table if not exists secret (
   id serial primary key,
-  hash bytea not null
-);
\ No newline at end of file
+  hash bytea not null,
+  data_key bytea not null,
+  key_version integer not null
+);
+
+alter table secret add column if not exists data_key bytea;
+alter table secret add column if not exists key_version integer;
+
+create table if not exists seal_config (
+  id integer primary key check (id = 1),
+  config bytea not null
+);
+
+-- entry is the encrypted key/value storage shared by the secrets engines.
+create table if not exists entry (
+  key text primary key,
+  value bytea not null,
+  data_key bytea not null,
+  key_version integer not null
+);
+
+-- lease tracks the TTL of the secrets and tokens issued by vaultd. The lease
+-- ID is prefixed with the path of the issuing backend. revoke_attempts counts
+-- the failed revocations of an expired lease, each postponing its
+-- expire_time.
+create table if not exists lease (
+  id text primary key,
+  issue_time timestamptz not null,
+  expire_time timestamptz not null,
+  last_renewal_time timestamptz,
+  max_expire_time timestamptz not null,
+  renewable boolean not null,
+  revoke_attempts integer not null default 0
+);
+
+alter table lease add column if not exists revoke_attempts integer not null default 0;
+
+create index if not exists lease_expire_time_idx on lease (expire_time);
+
+-- token holds the tokens issued by vaultd under the SHA-256 hash of the
+-- token, so that a database dump reveals no usable token. Children are
+-- revoked with their parent unless they are orphans.
+create table if not exists token (
+  id_hash text primary key,
+  parent_hash text,
+  policies text[] not null,
+  display_name text not null,
+  creation_time timestamptz not null,
+  expire_time timestamptz,
+  lease_id text
+);
+
+create index if not exists token_parent_hash_idx on token (parent_hash);
+
+-- jti holds the IDs of the JWTs accepted by any replica until they expire, so
+-- that a token is not accepted twice.
+create table if not exists jti (
+  id text primary key,
+  expire_time timestamptz not null
+);
+
+create index if not exists jti_expire_time_idx on jti (expire_time);
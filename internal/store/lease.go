@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/williamlsh/vault/internal/lease"
+)
+
+// leaseStorage implements lease.Storage interface.
+type leaseStorage struct {
+	logger log.Logger
+	db     *sqlx.DB
+}
+
+// NewLeaseStorage returns a storage for leases. Leases carry no secret
+// material and are stored unencrypted, so expired leases are found while the
+// vault is sealed too.
+func NewLeaseStorage(logger log.Logger, db *sqlx.DB) lease.Storage {
+	return leaseStorage{
+		logger: logger,
+		db:     db,
+	}
+}
+
+type leaseRow struct {
+	ID              string       `db:"id"`
+	IssueTime       time.Time    `db:"issue_time"`
+	ExpireTime      time.Time    `db:"expire_time"`
+	LastRenewalTime sql.NullTime `db:"last_renewal_time"`
+	MaxExpireTime   time.Time    `db:"max_expire_time"`
+	Renewable       bool         `db:"renewable"`
+	RevokeAttempts  int          `db:"revoke_attempts"`
+}
+
+// Lease returns the lease with the ID.
+func (s leaseStorage) Lease(ctx context.Context, id string) (*lease.Lease, error) {
+	q := `select id, issue_time, expire_time, last_renewal_time, max_expire_time, renewable, revoke_attempts from lease where id = $1;`
+
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+
+	var row leaseRow
+	err := s.db.GetContext(ctx, &row, q, id)
+	if err == sql.ErrNoRows {
+		return nil, lease.ErrNotFound
+	}
+	if err != nil {
+		level.Error(s.logger).Log("during", "select lease", "err", err)
+		return nil, err
+	}
+	l := &lease.Lease{
+		ID:             row.ID,
+		IssueTime:      row.IssueTime.UTC(),
+		ExpireTime:     row.ExpireTime.UTC(),
+		MaxExpireTime:  row.MaxExpireTime.UTC(),
+		Renewable:      row.Renewable,
+		RevokeAttempts: row.RevokeAttempts,
+	}
+	if row.LastRenewalTime.Valid {
+		l.LastRenewalTime = row.LastRenewalTime.Time.UTC()
+	}
+	return l, nil
+}
+
+// PutLease creates or replaces a lease.
+func (s leaseStorage) PutLease(ctx context.Context, l *lease.Lease) error {
+	q := `insert into lease (id, issue_time, expire_time, last_renewal_time, max_expire_time, renewable, revoke_attempts)
+	values ($1, $2, $3, $4, $5, $6, $7)
+	on conflict (id) do update set expire_time = excluded.expire_time,
+	last_renewal_time = excluded.last_renewal_time, renewable = excluded.renewable,
+	revoke_attempts = excluded.revoke_attempts;`
+
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+
+	renewal := sql.NullTime{Time: l.LastRenewalTime, Valid: !l.LastRenewalTime.IsZero()}
+	if _, err := s.db.ExecContext(ctx, q, l.ID, l.IssueTime, l.ExpireTime, renewal, l.MaxExpireTime, l.Renewable, l.RevokeAttempts); err != nil {
+		level.Error(s.logger).Log("during", "upsert lease", "err", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteLease removes a lease.
+func (s leaseStorage) DeleteLease(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+	if _, err := s.db.ExecContext(ctx, `delete from lease where id = $1;`, id); err != nil {
+		level.Error(s.logger).Log("during", "delete lease", "err", err)
+		return err
+	}
+	return nil
+}
+
+// ListLeases returns the IDs of the leases starting with prefix.
+func (s leaseStorage) ListLeases(ctx context.Context, prefix string) ([]string, error) {
+	q := `select id from lease where id like $1 escape '\' order by id;`
+
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+
+	var ids []string
+	if err := s.db.SelectContext(ctx, &ids, q, likePrefix(prefix)); err != nil {
+		level.Error(s.logger).Log("during", "list leases", "err", err)
+		return nil, err
+	}
+	return ids, nil
+}
+
+// ExpiredLeases returns up to limit IDs of the leases expired at now.
+func (s leaseStorage) ExpiredLeases(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	q := `select id from lease where expire_time <= $1 order by expire_time limit $2;`
+
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+
+	var ids []string
+	if err := s.db.SelectContext(ctx, &ids, q, now, limit); err != nil {
+		level.Error(s.logger).Log("during", "select expired leases", "err", err)
+		return nil, err
+	}
+	return ids, nil
+}
+
+// CountLeases returns the number of leases.
+func (s leaseStorage) CountLeases(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+
+	var n int
+	if err := s.db.GetContext(ctx, &n, `select count(*) from lease;`); err != nil {
+		level.Error(s.logger).Log("during", "count leases", "err", err)
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	_ "embed" // schema
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/jmoiron/sqlx"
+)
+
+// schema creates the tables missing from the database, and adds the columns
+// missing from the tables of previous versions.
+//
+//go:embed .sql/schema.sql
+var schema string
+
+// migrationTimeout bounds the migration, which waits for the table locks.
+const migrationTimeout = 30 * time.Second
+
+const (
+	// secretMigrationInterval is the delay between the attempts to encrypt
+	// the legacy secret rows, while the vault is sealed or the attempt fails.
+	secretMigrationInterval = 10 * time.Second
+	// secretMigrationBatch bounds the number of rows encrypted per
+	// transaction.
+	secretMigrationBatch = 100
+)
+
+// Migrate applies the schema to the database. It is idempotent, so that it
+// runs on every start.
+func Migrate(logger log.Logger, db *sqlx.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		level.Error(logger).Log("during", "migrate schema", "err", err)
+		return err
+	}
+	level.Info(logger).Log("migrate", "success")
+	return nil
+}
+
+// MigrateSecrets envelope encrypts the secret rows written before envelope
+// encryption, then makes the envelope columns mandatory so that no plaintext
+// hash can be written again. The rows can only be encrypted once the vault is
+// unsealed, so it waits for sealed to report false, and retries until it
+// succeeds or ctx is done.
+func MigrateSecrets(ctx context.Context, logger log.Logger, db *sqlx.DB, barrier Barrier, sealed func() bool) error {
+	ticker := time.NewTicker(secretMigrationInterval)
+	defer ticker.Stop()
+	for {
+		if !sealed() {
+			n, err := migrateSecrets(ctx, db, barrier)
+			if err == nil {
+				level.Info(logger).Log("migrate", "secrets", "encrypted", n)
+				return nil
+			}
+			level.Error(logger).Log("during", "encrypt legacy secrets", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// migrateSecrets encrypts the legacy secret rows batch by batch, and returns
+// their number.
+func migrateSecrets(ctx context.Context, db *sqlx.DB, barrier Barrier) (int, error) {
+	var total int
+	for {
+		n, err := encryptSecrets(ctx, db, barrier)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < secretMigrationBatch {
+			break
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, migrationTimeout)
+	defer cancel()
+	q := `alter table secret alter column data_key set not null, alter column key_version set not null;`
+	if _, err := db.ExecContext(ctx, q); err != nil {
+		return total, err
+	}
+	return total, nil
+}
+
+// encryptSecrets encrypts a batch of legacy secret rows in a transaction.
+func encryptSecrets(ctx context.Context, db *sqlx.DB, barrier Barrier) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, migrationTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var rows []struct {
+		ID   int64  `db:"id"`
+		Hash []byte `db:"hash"`
+	}
+	q := `select id, hash from secret where data_key is null order by id limit $1 for update;`
+	if err := tx.SelectContext(ctx, &rows, q, secretMigrationBatch); err != nil {
+		return 0, err
+	}
+	for _, row := range rows {
+		env, err := barrier.Encrypt(row.Hash, secretAAD(row.ID))
+		if err != nil {
+			return 0, err
+		}
+		q := `update secret set hash = $2, data_key = $3, key_version = $4 where id = $1;`
+		if _, err := tx.ExecContext(ctx, q, row.ID, env.Ciphertext, env.DataKey, env.KeyVersion); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
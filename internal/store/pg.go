@@ -3,12 +3,17 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq" // postgres driver
+
+	"github.com/williamlsh/vault/internal/seal"
 )
 
 const (
@@ -22,21 +27,41 @@ func newDB(logger log.Logger, dsn string) *sqlx.DB {
 	return db
 }
 
-// KeepSecret keeps the password hash bytes in database.
+// KeepSecret keeps the password hash bytes in database. The hash is envelope
+// encrypted, so a database dump alone reveals no hashes.
 func (s store) KeepSecret(secret []byte) <-chan error {
 	errc := make(chan error, 1)
-	go keepSecret(s.logger, s.db, secret, errc)
+	go keepSecret(s.logger, s.db, s.barrier, secret, errc)
 	return errc
 }
 
-func keepSecret(logger log.Logger, db *sqlx.DB, secret []byte, errc chan<- error) {
-	q := `insert into secret (hash) values ($1);`
+func keepSecret(logger log.Logger, db *sqlx.DB, barrier Barrier, secret []byte, errc chan<- error) {
+	q := `insert into secret (id, hash, data_key, key_version) values ($1, $2, $3, $4);`
 
 	ctx, cancel := context.WithTimeout(context.Background(), sqlTimout)
 	defer cancel()
 
+	// The id is allocated first, since the envelope is bound to it.
+	var id int64
+	if err := db.GetContext(ctx, &id, `select nextval(pg_get_serial_sequence('secret', 'id'));`); err != nil {
+		level.Error(logger).Log("during", "allocate secret id", "err", err)
+		errc <- err
+		return
+	}
+	env, err := barrier.Encrypt(secret, secretAAD(id))
+	if err != nil {
+		level.Error(logger).Log("during", "encrypt secret", "err", err)
+		errc <- err
+		return
+	}
+
 	tx, err := db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
-	_, err = tx.ExecContext(ctx, q, secret)
+	if err != nil {
+		level.Error(logger).Log("during", "transaction begin", "err", err)
+		errc <- err
+		return
+	}
+	_, err = tx.ExecContext(ctx, q, id, env.Ciphertext, env.DataKey, env.KeyVersion)
 
 	if err != nil {
 		level.Error(logger).Log("during", "transaction exec", "err", err)
@@ -54,3 +79,83 @@ func keepSecret(logger log.Logger, db *sqlx.DB, secret []byte, errc chan<- error
 	level.Info(logger).Log("keepSecret", "success")
 	errc <- nil
 }
+
+// Secret returns the decrypted password hash stored under id. The rows written
+// before envelope encryption are refused until MigrateSecrets encrypts them.
+func (s store) Secret(ctx context.Context, id int64) ([]byte, error) {
+	q := `select hash, data_key, coalesce(key_version, 0) as key_version from secret where id = $1;`
+
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+
+	var row struct {
+		Hash       []byte `db:"hash"`
+		DataKey    []byte `db:"data_key"`
+		KeyVersion uint32 `db:"key_version"`
+	}
+	err := s.db.GetContext(ctx, &row, q, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		level.Error(s.logger).Log("during", "select secret", "err", err)
+		return nil, err
+	}
+	if row.DataKey == nil {
+		return nil, fmt.Errorf("secret %d is not encrypted yet", id)
+	}
+	return s.barrier.Decrypt(seal.Envelope{
+		Ciphertext: row.Hash,
+		DataKey:    row.DataKey,
+		KeyVersion: row.KeyVersion,
+	}, secretAAD(id))
+}
+
+// secretAAD is the additional data of the envelope of the secret row id.
+func secretAAD(id int64) []byte {
+	return []byte("secret/" + strconv.FormatInt(id, 10))
+}
+
+// SealConfig returns the seal configuration, or nil if the vault has not been
+// initialized.
+func (s sealStorage) SealConfig(ctx context.Context) (*seal.Config, error) {
+	q := `select config from seal_config where id = 1;`
+
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+
+	var raw []byte
+	err := s.db.GetContext(ctx, &raw, q)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		level.Error(s.logger).Log("during", "select seal config", "err", err)
+		return nil, err
+	}
+	var cfg seal.Config
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// SetSealConfig stores the seal configuration, replacing any previous one.
+func (s sealStorage) SetSealConfig(ctx context.Context, cfg *seal.Config) error {
+	q := `insert into seal_config (id, config) values (1, $1)
+	on conflict (id) do update set config = excluded.config;`
+
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, q, raw); err != nil {
+		level.Error(s.logger).Log("during", "upsert seal config", "err", err)
+		return err
+	}
+	return nil
+}
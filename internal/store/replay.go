@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/williamlsh/vault/internal/replay"
+)
+
+// replayStorage implements replay.Storage interface.
+type replayStorage struct {
+	logger log.Logger
+	db     *sqlx.DB
+}
+
+// NewReplayStorage returns a storage for the IDs of the tokens seen, shared by
+// the replicas of vaultd. IDs are stored unencrypted, so that tokens are
+// checked while the vault is sealed.
+func NewReplayStorage(logger log.Logger, db *sqlx.DB) replay.Storage {
+	return replayStorage{
+		logger: logger,
+		db:     db,
+	}
+}
+
+// Record remembers the token ID until exp. An expired row of the same ID,
+// not purged yet, is replaced.
+func (s replayStorage) Record(ctx context.Context, id string, exp time.Time) (bool, error) {
+	q := `insert into jti (id, expire_time) values ($1, $2)
+	on conflict (id) do update set expire_time = excluded.expire_time where jti.expire_time <= now();`
+
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, q, id, exp)
+	if err != nil {
+		level.Error(s.logger).Log("during", "insert jti", "err", err)
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Purge forgets the token IDs expired at now.
+func (s replayStorage) Purge(ctx context.Context, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+	if _, err := s.db.ExecContext(ctx, `delete from jti where expire_time <= $1;`, now); err != nil {
+		level.Error(s.logger).Log("during", "delete jti", "err", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/williamlsh/vault/internal/seal"
+)
+
+type entryRow struct {
+	Value      []byte `db:"value"`
+	DataKey    []byte `db:"data_key"`
+	KeyVersion uint32 `db:"key_version"`
+}
+
+// entryAAD is the additional data of the envelope of the entry under key.
+func entryAAD(key string) []byte {
+	return []byte("entry/" + key)
+}
+
+// Get returns the decrypted value stored under key.
+func (s storage) Get(ctx context.Context, key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+	return s.get(ctx, s.db, key)
+}
+
+func (s storage) get(ctx context.Context, q sqlx.QueryerContext, key string) ([]byte, error) {
+	var row entryRow
+	err := sqlx.GetContext(ctx, q, &row, `select value, data_key, key_version from entry where key = $1;`, key)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		level.Error(s.logger).Log("during", "select entry", "err", err)
+		return nil, err
+	}
+	return s.barrier.Decrypt(seal.Envelope{
+		Ciphertext: row.Value,
+		DataKey:    row.DataKey,
+		KeyVersion: row.KeyVersion,
+	}, entryAAD(key))
+}
+
+// Put encrypts and stores the value under key.
+func (s storage) Put(ctx context.Context, key string, value []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+	return s.put(ctx, s.db, key, value)
+}
+
+func (s storage) put(ctx context.Context, e sqlx.ExecerContext, key string, value []byte) error {
+	q := `insert into entry (key, value, data_key, key_version) values ($1, $2, $3, $4)
+	on conflict (key) do update set value = excluded.value, data_key = excluded.data_key, key_version = excluded.key_version;`
+
+	env, err := s.barrier.Encrypt(value, entryAAD(key))
+	if err != nil {
+		return err
+	}
+	if _, err := e.ExecContext(ctx, q, key, env.Ciphertext, env.DataKey, env.KeyVersion); err != nil {
+		level.Error(s.logger).Log("during", "upsert entry", "err", err)
+		return err
+	}
+	return nil
+}
+
+// Delete removes the entry under key.
+func (s storage) Delete(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+	if _, err := s.db.ExecContext(ctx, `delete from entry where key = $1;`, key); err != nil {
+		level.Error(s.logger).Log("during", "delete entry", "err", err)
+		return err
+	}
+	return nil
+}
+
+// List returns the keys starting with prefix, with the prefix trimmed.
+func (s storage) List(ctx context.Context, prefix string) ([]string, error) {
+	q := `select key from entry where key like $1 escape '\' order by key;`
+
+	// Keys are listed while sealed too, but the values stay unreadable.
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+
+	var keys []string
+	if err := s.db.SelectContext(ctx, &keys, q, likePrefix(prefix)); err != nil {
+		level.Error(s.logger).Log("during", "list entries", "err", err)
+		return nil, err
+	}
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, prefix)
+	}
+	return keys, nil
+}
+
+// Update atomically replaces the value under key with the result of fn. The
+// key is serialized with a transaction scoped advisory lock, which also covers
+// entries that do not exist yet.
+func (s storage) Update(ctx context.Context, key string, fn func(value []byte) ([]byte, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		level.Error(s.logger).Log("during", "transaction begin", "err", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `select pg_advisory_xact_lock(hashtext($1));`, key); err != nil {
+		level.Error(s.logger).Log("during", "advisory lock", "err", err)
+		return err
+	}
+	old, err := s.get(ctx, tx, key)
+	if err == ErrNotFound {
+		old, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	value, err := fn(old)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		_, err = tx.ExecContext(ctx, `delete from entry where key = $1;`, key)
+	} else {
+		err = s.put(ctx, tx, key, value)
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		level.Error(s.logger).Log("during", "transaction commit", "err", err)
+		return err
+	}
+	return nil
+}
+
+// likePrefix returns a LIKE pattern matching strings starting with prefix.
+func likePrefix(prefix string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(prefix) + "%"
+}
+
+// Transaction runs fn in a transaction. The keys are serialized with the same
+// advisory locks as Update, taken the first time fn touches them.
+func (s storage) Transaction(ctx context.Context, fn func(tx Tx) error) error {
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		level.Error(s.logger).Log("during", "transaction begin", "err", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&storageTx{s: s, ctx: ctx, tx: tx, locked: map[string]bool{}}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		level.Error(s.logger).Log("during", "transaction commit", "err", err)
+		return err
+	}
+	return nil
+}
+
+// storageTx implements Tx interface.
+type storageTx struct {
+	s      storage
+	ctx    context.Context
+	tx     *sqlx.Tx
+	locked map[string]bool
+}
+
+func (t *storageTx) lock(key string) error {
+	if t.locked[key] {
+		return nil
+	}
+	if _, err := t.tx.ExecContext(t.ctx, `select pg_advisory_xact_lock(hashtext($1));`, key); err != nil {
+		level.Error(t.s.logger).Log("during", "advisory lock", "err", err)
+		return err
+	}
+	t.locked[key] = true
+	return nil
+}
+
+func (t *storageTx) Get(key string) ([]byte, error) {
+	if err := t.lock(key); err != nil {
+		return nil, err
+	}
+	return t.s.get(t.ctx, t.tx, key)
+}
+
+func (t *storageTx) Put(key string, value []byte) error {
+	if err := t.lock(key); err != nil {
+		return err
+	}
+	return t.s.put(t.ctx, t.tx, key, value)
+}
+
+func (t *storageTx) Delete(key string) error {
+	if err := t.lock(key); err != nil {
+		return err
+	}
+	if _, err := t.tx.ExecContext(t.ctx, `delete from entry where key = $1;`, key); err != nil {
+		level.Error(t.s.logger).Log("during", "delete entry", "err", err)
+		return err
+	}
+	return nil
+}
@@ -1,26 +1,113 @@
 package store
 
 import (
+	"context"
+	"errors"
+
 	"github.com/go-kit/kit/log"
 	"github.com/jmoiron/sqlx"
+
+	"github.com/williamlsh/vault/internal/seal"
 )
 
 // Store represents a database store.
 type Store interface {
 	// KeepSecret keeps the password hash bytes in database.
 	KeepSecret(secret []byte) <-chan error
+	// Secret returns the decrypted password hash stored under id, or
+	// ErrNotFound.
+	Secret(ctx context.Context, id int64) ([]byte, error)
+}
+
+// ErrNotFound is returned when a storage entry does not exist.
+var ErrNotFound = errors.New("entry not found")
+
+// Storage is an encrypted key/value storage shared by the secrets engines.
+// Keys are slash separated paths.
+type Storage interface {
+	// Get returns the value stored under key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Put stores the value under key, replacing any previous one.
+	Put(ctx context.Context, key string, value []byte) error
+	// Delete removes the entry under key. Deleting a missing entry is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+	// List returns the keys starting with prefix, with the prefix trimmed.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Update atomically replaces the value under key with the result of fn,
+	// which is passed nil if the entry does not exist. A nil result deletes
+	// the entry.
+	Update(ctx context.Context, key string, fn func(value []byte) ([]byte, error)) error
+	// Transaction runs fn in a transaction, committing its writes if it
+	// returns nil. Every key fn touches is locked until the end of the
+	// transaction.
+	Transaction(ctx context.Context, fn func(tx Tx) error) error
+}
+
+// Tx is the storage as seen by a transaction.
+type Tx interface {
+	Get(key string) ([]byte, error)
+	Put(key string, value []byte) error
+	Delete(key string) error
+}
+
+// Barrier envelope encrypts values before they reach the database. It is
+// implemented by *seal.Seal and fails while the vault is sealed. The
+// envelopes are bound to the additional data, the key of their row, so that
+// they do not decrypt once moved to another row.
+type Barrier interface {
+	Encrypt(plaintext, additionalData []byte) (seal.Envelope, error)
+	Decrypt(e seal.Envelope, additionalData []byte) ([]byte, error)
 }
 
 // store implements Store interface.
 type store struct {
+	logger  log.Logger
+	db      *sqlx.DB
+	barrier Barrier
+}
+
+// Connect connects to the database. The connection is shared by the seal
+// storage and the store.
+func Connect(logger log.Logger, dsn string) *sqlx.DB {
+	return newDB(logger, dsn)
+}
+
+// New returns a new store encrypting everything it writes with the barrier.
+func New(logger log.Logger, db *sqlx.DB, barrier Barrier) Store {
+	return store{
+		logger:  logger,
+		db:      db,
+		barrier: barrier,
+	}
+}
+
+// storage implements Storage interface.
+type storage struct {
+	logger  log.Logger
+	db      *sqlx.DB
+	barrier Barrier
+}
+
+// NewStorage returns a storage encrypting every value with the barrier.
+func NewStorage(logger log.Logger, db *sqlx.DB, barrier Barrier) Storage {
+	return storage{
+		logger:  logger,
+		db:      db,
+		barrier: barrier,
+	}
+}
+
+// sealStorage implements seal.Storage interface.
+type sealStorage struct {
 	logger log.Logger
 	db     *sqlx.DB
 }
 
-// New returns a new store.
-func New(logger log.Logger, dsn string) Store {
-	db := newDB(logger, dsn)
-	return store{
+// NewSealStorage returns a storage for the seal configuration. The seal
+// configuration is stored unencrypted since it is needed to unseal the vault.
+func NewSealStorage(logger log.Logger, db *sqlx.DB) seal.Storage {
+	return sealStorage{
 		logger: logger,
 		db:     db,
 	}
@@ -0,0 +1,297 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/williamlsh/vault/internal/mock"
+	"github.com/williamlsh/vault/internal/seal"
+	"github.com/williamlsh/vault/internal/store"
+)
+
+// The tests run against the Postgres database named by the DSN in
+// VAULT_TEST_PG_DSN, and are skipped without it. They create rows of their
+// own, so the database may be shared, but they change the schema of the
+// secret table.
+const dsnEnv = "VAULT_TEST_PG_DSN"
+
+func newDB(t *testing.T) (*sqlx.DB, *seal.Seal) {
+	t.Helper()
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", dsnEnv)
+	}
+	db := store.Connect(log.NewNopLogger(), dsn)
+	t.Cleanup(func() { db.Close() })
+	if err := store.Migrate(log.NewNopLogger(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	sl := seal.New(mock.NewSealStorage())
+	keys, err := sl.Initialize(ctx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sl.Unseal(ctx, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	return db, sl
+}
+
+// testPrefix returns a key prefix no other run of the test uses.
+func testPrefix(t *testing.T) string {
+	return fmt.Sprintf("test/%s/%d/", t.Name(), time.Now().UnixNano())
+}
+
+func TestStorage(t *testing.T) {
+	db, sl := newDB(t)
+	s := store.NewStorage(log.NewNopLogger(), db, sl)
+	ctx := context.Background()
+	prefix := testPrefix(t)
+
+	if _, err := s.Get(ctx, prefix+"a"); err != store.ErrNotFound {
+		t.Errorf("missing entry: want %v, have %v", store.ErrNotFound, err)
+	}
+	if err := s.Put(ctx, prefix+"a", []byte("plaintext")); err != nil {
+		t.Fatal(err)
+	}
+	value, err := s.Get(ctx, prefix+"a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "plaintext", string(value); want != have {
+		t.Errorf("value: want %q, have %q", want, have)
+	}
+
+	// The row holds the envelope, never the value.
+	var row struct {
+		Value      []byte `db:"value"`
+		DataKey    []byte `db:"data_key"`
+		KeyVersion uint32 `db:"key_version"`
+	}
+	if err := db.Get(&row, `select value, data_key, key_version from entry where key = $1;`, prefix+"a"); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(row.Value, []byte("plaintext")) || len(row.DataKey) == 0 || row.KeyVersion == 0 {
+		t.Errorf("entry row not envelope encrypted: %+v", row)
+	}
+
+	// An envelope moved to another row does not decrypt.
+	q := `insert into entry (key, value, data_key, key_version) values ($1, $2, $3, $4);`
+	if _, err := db.Exec(q, prefix+"b", row.Value, row.DataKey, row.KeyVersion); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, prefix+"b"); err == nil {
+		t.Error("envelope moved to another key decrypted")
+	}
+
+	keys, err := s.List(ctx, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "[a b]", fmt.Sprint(keys); want != have {
+		t.Errorf("list: want %s, have %s", want, have)
+	}
+	for _, k := range keys {
+		if err := s.Delete(ctx, prefix+k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if keys, _ = s.List(ctx, prefix); len(keys) != 0 {
+		t.Errorf("deleted entries listed: %v", keys)
+	}
+}
+
+// TestLock increments a counter concurrently with Update and Transaction,
+// which serialize the key with the same advisory lock, so that no increment
+// is lost.
+func TestLock(t *testing.T) {
+	db, sl := newDB(t)
+	s := store.NewStorage(log.NewNopLogger(), db, sl)
+	ctx := context.Background()
+	key := testPrefix(t) + "counter"
+
+	increment := func(value []byte) ([]byte, error) {
+		n, _ := strconv.Atoi(string(value))
+		return []byte(strconv.Itoa(n + 1)), nil
+	}
+	const n = 10
+	var wg sync.WaitGroup
+	errc := make(chan error, 2*n)
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errc <- s.Update(ctx, key, increment)
+		}()
+		go func() {
+			defer wg.Done()
+			errc <- s.Transaction(ctx, func(tx store.Tx) error {
+				value, err := tx.Get(key)
+				if err != nil && err != store.ErrNotFound {
+					return err
+				}
+				value, _ = increment(value)
+				return tx.Put(key, value)
+			})
+		}()
+	}
+	wg.Wait()
+	close(errc)
+	for err := range errc {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	value, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := strconv.Itoa(2*n), string(value); want != have {
+		t.Errorf("counter: want %s, have %s", want, have)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	db, sl := newDB(t)
+	s := store.NewStorage(log.NewNopLogger(), db, sl)
+	ctx := context.Background()
+	prefix := testPrefix(t)
+
+	if err := s.Put(ctx, prefix+"old", []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	write := func(tx store.Tx) error {
+		if err := tx.Put(prefix+"a", []byte("a")); err != nil {
+			return err
+		}
+		if err := tx.Put(prefix+"b", []byte("b")); err != nil {
+			return err
+		}
+		return tx.Delete(prefix + "old")
+	}
+
+	failed := errors.New("failed")
+	err := s.Transaction(ctx, func(tx store.Tx) error {
+		if err := write(tx); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("failed transaction: want %v, have %v", failed, err)
+	}
+	keys, err := s.List(ctx, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "[old]", fmt.Sprint(keys); want != have {
+		t.Errorf("after rollback: want %s, have %s", want, have)
+	}
+
+	if err := s.Transaction(ctx, write); err != nil {
+		t.Fatal(err)
+	}
+	if keys, err = s.List(ctx, prefix); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "[a b]", fmt.Sprint(keys); want != have {
+		t.Errorf("after commit: want %s, have %s", want, have)
+	}
+	for _, k := range keys {
+		if err := s.Delete(ctx, prefix+k); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSecret(t *testing.T) {
+	db, sl := newDB(t)
+	s := store.New(log.NewNopLogger(), db, sl)
+	ctx := context.Background()
+
+	if err := <-s.KeepSecret([]byte("hash")); err != nil {
+		t.Fatal(err)
+	}
+	var row struct {
+		ID      int64  `db:"id"`
+		Hash    []byte `db:"hash"`
+		DataKey []byte `db:"data_key"`
+	}
+	if err := db.Get(&row, `select id, hash, data_key from secret order by id desc limit 1;`); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(row.Hash, []byte("hash")) || len(row.DataKey) == 0 {
+		t.Errorf("secret row not envelope encrypted: %+v", row)
+	}
+	hash, err := s.Secret(ctx, row.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "hash", string(hash); want != have {
+		t.Errorf("secret: want %q, have %q", want, have)
+	}
+	if _, err := s.Secret(ctx, -1); err != store.ErrNotFound {
+		t.Errorf("missing secret: want %v, have %v", store.ErrNotFound, err)
+	}
+}
+
+// TestMigrateSecrets writes a secret row like the versions before envelope
+// encryption, which MigrateSecrets encrypts before making the envelope
+// columns mandatory again.
+func TestMigrateSecrets(t *testing.T) {
+	db, sl := newDB(t)
+	s := store.New(log.NewNopLogger(), db, sl)
+	ctx := context.Background()
+
+	if _, err := db.Exec(`alter table secret alter column data_key drop not null, alter column key_version drop not null;`); err != nil {
+		t.Fatal(err)
+	}
+	var id int64
+	if err := db.Get(&id, `insert into secret (hash) values ($1) returning id;`, []byte("legacy")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Secret(ctx, id); err == nil {
+		t.Error("legacy secret read before it is encrypted")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := store.MigrateSecrets(ctx, log.NewNopLogger(), db, sl, sl.Sealed); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := s.Secret(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "legacy", string(hash); want != have {
+		t.Errorf("migrated secret: want %q, have %q", want, have)
+	}
+
+	var nullable []string
+	q := `select is_nullable from information_schema.columns where table_name = 'secret' and column_name in ('data_key', 'key_version');`
+	if err := db.Select(&nullable, q); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "[NO NO]", fmt.Sprint(nullable); want != have {
+		t.Errorf("envelope columns nullable: want %s, have %s", want, have)
+	}
+	if _, err := db.Exec(`insert into secret (hash) values ($1);`, []byte("plaintext")); err == nil {
+		t.Error("plaintext secret written after the migration")
+	}
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/williamlsh/vault/internal/token"
+)
+
+// tokenStorage implements token.Storage interface.
+type tokenStorage struct {
+	logger log.Logger
+	db     *sqlx.DB
+}
+
+// NewTokenStorage returns a storage for tokens. Tokens are stored by hash
+// and unencrypted, so that the root token can be issued before the vault is
+// first unsealed.
+func NewTokenStorage(logger log.Logger, db *sqlx.DB) token.Storage {
+	return tokenStorage{
+		logger: logger,
+		db:     db,
+	}
+}
+
+type tokenRow struct {
+	IDHash       string         `db:"id_hash"`
+	ParentHash   sql.NullString `db:"parent_hash"`
+	Policies     pq.StringArray `db:"policies"`
+	DisplayName  string         `db:"display_name"`
+	CreationTime time.Time      `db:"creation_time"`
+	ExpireTime   sql.NullTime   `db:"expire_time"`
+	LeaseID      sql.NullString `db:"lease_id"`
+}
+
+// Token returns the token with the hash.
+func (s tokenStorage) Token(ctx context.Context, hash string) (*token.Token, error) {
+	q := `select id_hash, parent_hash, policies, display_name, creation_time, expire_time, lease_id from token where id_hash = $1;`
+
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+
+	var row tokenRow
+	err := s.db.GetContext(ctx, &row, q, hash)
+	if err == sql.ErrNoRows {
+		return nil, token.ErrNotFound
+	}
+	if err != nil {
+		level.Error(s.logger).Log("during", "select token", "err", err)
+		return nil, err
+	}
+	t := &token.Token{
+		Hash:         row.IDHash,
+		Parent:       row.ParentHash.String,
+		Policies:     row.Policies,
+		DisplayName:  row.DisplayName,
+		CreationTime: row.CreationTime.UTC(),
+		LeaseID:      row.LeaseID.String,
+	}
+	if row.ExpireTime.Valid {
+		t.ExpireTime = row.ExpireTime.Time.UTC()
+	}
+	return t, nil
+}
+
+// PutToken creates or replaces a token.
+func (s tokenStorage) PutToken(ctx context.Context, t *token.Token) error {
+	q := `insert into token (id_hash, parent_hash, policies, display_name, creation_time, expire_time, lease_id)
+	values ($1, $2, $3, $4, $5, $6, $7)
+	on conflict (id_hash) do update set parent_hash = excluded.parent_hash, expire_time = excluded.expire_time;`
+
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+
+	var (
+		parent  = sql.NullString{String: t.Parent, Valid: t.Parent != ""}
+		expire  = sql.NullTime{Time: t.ExpireTime, Valid: !t.ExpireTime.IsZero()}
+		leaseID = sql.NullString{String: t.LeaseID, Valid: t.LeaseID != ""}
+	)
+	if _, err := s.db.ExecContext(ctx, q, t.Hash, parent, pq.StringArray(t.Policies), t.DisplayName, t.CreationTime, expire, leaseID); err != nil {
+		level.Error(s.logger).Log("during", "upsert token", "err", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteToken removes a token.
+func (s tokenStorage) DeleteToken(ctx context.Context, hash string) error {
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+	if _, err := s.db.ExecContext(ctx, `delete from token where id_hash = $1;`, hash); err != nil {
+		level.Error(s.logger).Log("during", "delete token", "err", err)
+		return err
+	}
+	return nil
+}
+
+// ChildTokens returns the hashes of the children of a token.
+func (s tokenStorage) ChildTokens(ctx context.Context, parent string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, sqlTimout)
+	defer cancel()
+
+	var hashes []string
+	if err := s.db.SelectContext(ctx, &hashes, `select id_hash from token where parent_hash = $1;`, parent); err != nil {
+		level.Error(s.logger).Log("during", "select child tokens", "err", err)
+		return nil, err
+	}
+	return hashes, nil
+}
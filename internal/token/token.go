@@ -0,0 +1,286 @@
+// Package token implements the service tokens issued by vaultd. Tokens are
+// random bearer strings; only their SHA-256 hash is stored, so the token
+// store alone cannot be used to authenticate.
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/williamlsh/vault/internal/lease"
+)
+
+// Prefix starts every token issued by vaultd.
+const Prefix = "s."
+
+// LeasePrefix is the lease path of the tokens, followed by the token hash.
+const LeasePrefix = "auth/token/create/"
+
+var (
+	// ErrMissingToken is returned when a request carries no token.
+	ErrMissingToken = errors.New("missing token")
+	// ErrInvalidToken is returned when a request carries an unknown or
+	// expired token.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrNotFound is returned when looking up an unknown token.
+	ErrNotFound = errors.New("token not found")
+)
+
+// Token is a service token.
+type Token struct {
+	// ID is the bearer string of the token. It is only known when the token
+	// is created.
+	ID string `json:"id,omitempty"`
+	// Hash is the SHA-256 hash of the ID, which the token is stored under.
+	Hash string `json:"-"`
+	// Parent is the hash of the token which created this one, empty for
+	// orphan tokens. Revoking a token revokes its children.
+	Parent       string    `json:"-"`
+	Policies     []string  `json:"policies"`
+	DisplayName  string    `json:"display_name"`
+	CreationTime time.Time `json:"creation_time"`
+	// ExpireTime is zero for tokens which never expire, like the root token.
+	ExpireTime time.Time `json:"expire_time,omitempty"`
+	// LeaseID is the lease revoking the token when it expires.
+	LeaseID string `json:"lease_id,omitempty"`
+}
+
+// Orphan reports whether the token has no parent.
+func (t *Token) Orphan() bool {
+	return t.Parent == ""
+}
+
+// Expired reports whether the token has expired at now.
+func (t *Token) Expired(now time.Time) bool {
+	return !t.ExpireTime.IsZero() && !t.ExpireTime.After(now)
+}
+
+// Storage persists tokens by hash.
+type Storage interface {
+	// Token returns the token with the hash, or ErrNotFound.
+	Token(ctx context.Context, hash string) (*Token, error)
+	// PutToken creates or replaces a token.
+	PutToken(ctx context.Context, t *Token) error
+	// DeleteToken removes a token. Deleting a missing token is not an error.
+	DeleteToken(ctx context.Context, hash string) error
+	// ChildTokens returns the hashes of the children of a token.
+	ChildTokens(ctx context.Context, parent string) ([]string, error)
+}
+
+// CreateOptions configures a new token.
+type CreateOptions struct {
+	Policies []string
+	// TTL defaults to the lease default TTL.
+	TTL         time.Duration
+	DisplayName string
+	// Orphan creates a token without parent, which outlives the token
+	// creating it.
+	Orphan bool
+}
+
+// Store issues, looks up and revokes tokens.
+type Store struct {
+	storage Storage
+	leases  *lease.Manager
+}
+
+// NewStore returns a Store persisting tokens in the storage. Token TTLs are
+// enforced by leases, whose revocation revokes the token.
+func NewStore(storage Storage, leases *lease.Manager) *Store {
+	s := &Store{storage: storage, leases: leases}
+	leases.Handle(LeasePrefix, s.revokeLease)
+	return s
+}
+
+// CreateRoot issues an orphan token which never expires, meant for the root
+// policy.
+func (s *Store) CreateRoot(ctx context.Context, policies []string) (*Token, error) {
+	t := newToken("root")
+	t.Policies = policies
+	if err := s.storage.PutToken(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Create issues a token with the options. Unless it is an orphan the token
+// is a child of parent and cannot outlive it. Checking that parent may grant
+// the policies is up to the caller.
+func (s *Store) Create(ctx context.Context, parent *Token, opts CreateOptions) (*Token, error) {
+	name := opts.DisplayName
+	if name == "" {
+		name = "token"
+	}
+	t := newToken(name)
+	t.Policies = dedup(opts.Policies)
+	if parent == nil {
+		opts.Orphan = true
+	}
+	if !opts.Orphan {
+		t.Parent = parent.Hash
+	}
+
+	ttl := opts.TTL
+	if !opts.Orphan && !parent.ExpireTime.IsZero() {
+		left := time.Until(parent.ExpireTime)
+		if ttl <= 0 || ttl > left {
+			ttl = left
+		}
+	}
+	l, err := s.leases.Register(ctx, LeasePrefix+t.Hash, ttl)
+	if err != nil {
+		return nil, err
+	}
+	t.LeaseID, t.ExpireTime = l.ID, l.ExpireTime
+	if err := s.storage.PutToken(ctx, t); err != nil {
+		s.leases.Revoke(ctx, l.ID)
+		return nil, err
+	}
+	return t, nil
+}
+
+// Lookup returns the token with the ID, or ErrInvalidToken if it does not
+// exist or has expired.
+func (s *Store) Lookup(ctx context.Context, id string) (*Token, error) {
+	if id == "" {
+		return nil, ErrMissingToken
+	}
+	t, err := s.LookupHash(ctx, Hash(id))
+	if err == ErrNotFound {
+		return nil, ErrInvalidToken
+	}
+	return t, err
+}
+
+// LookupHash returns the token with the hash, or ErrNotFound if it does not
+// exist or has expired.
+func (s *Store) LookupHash(ctx context.Context, hash string) (*Token, error) {
+	t, err := s.storage.Token(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if t.Expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return t, nil
+}
+
+// Renew extends the lease of a token by increment, zero meaning the lease
+// default TTL. Tokens which never expire are not renewable.
+func (s *Store) Renew(ctx context.Context, t *Token, increment time.Duration) (*Token, error) {
+	if t.LeaseID == "" {
+		return nil, lease.ErrNotRenewable
+	}
+	l, err := s.leases.Renew(ctx, t.LeaseID, increment)
+	if err != nil {
+		return nil, err
+	}
+	t.ExpireTime = l.ExpireTime
+	if err := s.storage.PutToken(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Revoke revokes a token and, recursively, all its children.
+func (s *Store) Revoke(ctx context.Context, t *Token) error {
+	if t.LeaseID != "" {
+		err := s.leases.Revoke(ctx, t.LeaseID)
+		if err != lease.ErrNotFound {
+			return err
+		}
+	}
+	return s.revokeTree(ctx, t.Hash)
+}
+
+// RevokeOrphan revokes a token and turns its children into orphans.
+func (s *Store) RevokeOrphan(ctx context.Context, t *Token) error {
+	children, err := s.storage.ChildTokens(ctx, t.Hash)
+	if err != nil {
+		return err
+	}
+	for _, hash := range children {
+		child, err := s.storage.Token(ctx, hash)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		child.Parent = ""
+		if err := s.storage.PutToken(ctx, child); err != nil {
+			return err
+		}
+	}
+	return s.Revoke(ctx, t)
+}
+
+// revokeLease revokes the token a lease was issued for.
+func (s *Store) revokeLease(ctx context.Context, l *lease.Lease) error {
+	// Lease IDs are auth/token/create/<hash>/<random>.
+	hash := strings.TrimPrefix(l.ID, LeasePrefix)
+	if i := strings.Index(hash, "/"); i >= 0 {
+		hash = hash[:i]
+	}
+	return s.revokeTree(ctx, hash)
+}
+
+// revokeTree deletes a token after its children, so that a failed
+// revocation can be retried from the top.
+func (s *Store) revokeTree(ctx context.Context, hash string) error {
+	children, err := s.storage.ChildTokens(ctx, hash)
+	if err != nil {
+		return err
+	}
+	for _, h := range children {
+		child, err := s.storage.Token(ctx, h)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.Revoke(ctx, child); err != nil {
+			return err
+		}
+	}
+	return s.storage.DeleteToken(ctx, hash)
+}
+
+// Hash returns the hash a token is stored under.
+func Hash(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+func newToken(name string) *Token {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	id := Prefix + base64.RawURLEncoding.EncodeToString(b)
+	return &Token{
+		ID:           id,
+		Hash:         Hash(id),
+		DisplayName:  name,
+		CreationTime: time.Now().UTC(),
+	}
+}
+
+func dedup(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	out := names[:0:0]
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	return out
+}
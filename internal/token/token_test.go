@@ -0,0 +1,160 @@
+package token_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/discard"
+
+	"github.com/williamlsh/vault/internal/lease"
+	"github.com/williamlsh/vault/internal/mock"
+	"github.com/williamlsh/vault/internal/token"
+)
+
+func newStore() (*token.Store, *lease.Manager) {
+	leases := lease.NewManager(log.NewNopLogger(), mock.NewLeaseStorage(), lease.Metrics{
+		Issued:  discard.NewCounter(),
+		Renewed: discard.NewCounter(),
+		Revoked: discard.NewCounter(),
+		Active:  discard.NewGauge(),
+	}, time.Minute, time.Hour)
+	return token.NewStore(mock.NewTokenStorage(), leases), leases
+}
+
+func TestLookup(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newStore()
+
+	root, err := s.CreateRoot(ctx, []string{"root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Lookup(ctx, ""); err != token.ErrMissingToken {
+		t.Errorf("empty token: want %v, have %v", token.ErrMissingToken, err)
+	}
+	if _, err := s.Lookup(ctx, token.Prefix+"unknown"); err != token.ErrInvalidToken {
+		t.Errorf("unknown token: want %v, have %v", token.ErrInvalidToken, err)
+	}
+	found, err := s.Lookup(ctx, root.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Hash != token.Hash(root.ID) || found.LeaseID != "" || !found.ExpireTime.IsZero() {
+		t.Errorf("root token: have %+v", found)
+	}
+	if _, err := s.Renew(ctx, found, 0); err != lease.ErrNotRenewable {
+		t.Errorf("renew root token: want %v, have %v", lease.ErrNotRenewable, err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	ctx := context.Background()
+	s, leases := newStore()
+
+	root, err := s.CreateRoot(ctx, []string{"root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := s.Create(ctx, root, token.CreateOptions{Policies: []string{"app", "app"}, TTL: 10 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "[app]", fmt.Sprint(parent.Policies); want != have {
+		t.Errorf("policies: want %s, have %s", want, have)
+	}
+	if parent.Parent != root.Hash {
+		t.Errorf("parent: want %s, have %s", root.Hash, parent.Parent)
+	}
+	if _, err := leases.Lookup(ctx, parent.LeaseID); err != nil {
+		t.Errorf("token lease: %v", err)
+	}
+
+	// The TTL of a child is capped by the time its parent has left.
+	child, err := s.Create(ctx, parent, token.CreateOptions{TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.ExpireTime.After(parent.ExpireTime.Add(time.Second)) {
+		t.Errorf("child outlives its parent: %s > %s", child.ExpireTime, parent.ExpireTime)
+	}
+
+	renewed, err := s.Renew(ctx, parent, 30*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := time.Until(renewed.ExpireTime); ttl <= 20*time.Minute {
+		t.Errorf("renewed ttl: want more than 20m, have %s", ttl)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	ctx := context.Background()
+	s, leases := newStore()
+
+	root, err := s.CreateRoot(ctx, []string{"root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := s.Create(ctx, root, token.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := s.Create(ctx, parent, token.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphan, err := s.Create(ctx, parent, token.CreateOptions{Orphan: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Revoke(ctx, parent); err != nil {
+		t.Fatal(err)
+	}
+	for _, tok := range []*token.Token{parent, child} {
+		if _, err := s.Lookup(ctx, tok.ID); err != token.ErrInvalidToken {
+			t.Errorf("revoked token: want %v, have %v", token.ErrInvalidToken, err)
+		}
+		if _, err := leases.Lookup(ctx, tok.LeaseID); err != lease.ErrNotFound {
+			t.Errorf("lease of revoked token: want %v, have %v", lease.ErrNotFound, err)
+		}
+	}
+	if _, err := s.Lookup(ctx, orphan.ID); err != nil {
+		t.Errorf("orphan revoked with the token creating it: %v", err)
+	}
+
+	// Revoking the lease of a token revokes the token.
+	if err := leases.Revoke(ctx, orphan.LeaseID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Lookup(ctx, orphan.ID); err != token.ErrInvalidToken {
+		t.Errorf("token of revoked lease: want %v, have %v", token.ErrInvalidToken, err)
+	}
+}
+
+func TestRevokeOrphan(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newStore()
+
+	parent, err := s.Create(ctx, nil, token.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := s.Create(ctx, parent, token.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RevokeOrphan(ctx, parent); err != nil {
+		t.Fatal(err)
+	}
+	found, err := s.Lookup(ctx, child.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found.Orphan() {
+		t.Errorf("child of token revoked alone: want orphan, have parent %s", found.Parent)
+	}
+}
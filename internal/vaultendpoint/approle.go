@@ -0,0 +1,257 @@
+package vaultendpoint
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/token"
+	"github.com/williamlsh/vault/internal/vaultservice"
+)
+
+// AppRoleSet collects all of the endpoints of the AppRole auth method.
+type AppRoleSet struct {
+	ReadRoleEndpoint         endpoint.Endpoint
+	WriteRoleEndpoint        endpoint.Endpoint
+	DeleteRoleEndpoint       endpoint.Endpoint
+	ListRolesEndpoint        endpoint.Endpoint
+	GenerateSecretIDEndpoint endpoint.Endpoint
+	DestroySecretIDEndpoint  endpoint.Endpoint
+	LoginEndpoint            endpoint.Endpoint
+}
+
+// NewAppRoleSet returns an AppRoleSet that wraps the provided AppRole
+// service. Roles are managed on the auth/approle/role/<name> policy paths,
+// secret IDs with update on auth/approle/role/<name>/secret-id and
+// auth/approle/role/<name>/secret-id-accessor/destroy. Login is reachable
+// without a token, the role and secret IDs being the credentials.
+func NewAppRoleSet(svc vaultservice.AppRoleService, auth *Authorizer, duration metrics.Histogram, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) AppRoleSet {
+	wrap := func(name string, e endpoint.Endpoint) endpoint.Endpoint {
+		e = opentracing.TraceServer(otTracer, name)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, name)(e)
+		e = LoggingMiddleware(log.With(logger, "method", name))(e)
+		e = InstrumentingMiddleware(duration.With("method", name))(e)
+		return e
+	}
+	return AppRoleSet{
+		ReadRoleEndpoint:         wrap("ReadRole", authorize(auth, roleResource("", policy.Read))(MakeReadRoleEndpoint(svc))),
+		WriteRoleEndpoint:        wrap("WriteRole", authorize(auth, roleResource("", policy.Create, policy.Update))(MakeWriteRoleEndpoint(svc))),
+		DeleteRoleEndpoint:       wrap("DeleteRole", authorize(auth, roleResource("", policy.Delete))(MakeDeleteRoleEndpoint(svc))),
+		ListRolesEndpoint:        wrap("ListRoles", authorize(auth, At("auth/approle/role", policy.List))(MakeListRolesEndpoint(svc))),
+		GenerateSecretIDEndpoint: wrap("GenerateSecretID", authorize(auth, roleResource("/secret-id", policy.Update))(MakeGenerateSecretIDEndpoint(svc))),
+		DestroySecretIDEndpoint:  wrap("DestroySecretID", authorize(auth, roleResource("/secret-id-accessor/destroy", policy.Update))(MakeDestroySecretIDEndpoint(svc))),
+		LoginEndpoint:            wrap("AppRoleLogin", MakeAppRoleLoginEndpoint(svc)),
+	}
+}
+
+func roleResource(suffix string, capabilities ...string) Resource {
+	return func(request interface{}) (string, []string) {
+		var name string
+		switch req := request.(type) {
+		case RoleRequest:
+			name = req.Name
+		case WriteRoleRequest:
+			name = req.Name
+		case DestroySecretIDRequest:
+			name = req.Name
+		}
+		return "auth/approle/role/" + name + suffix, capabilities
+	}
+}
+
+// ReadRole implements vaultservice.AppRoleService interface, so AppRoleSet
+// may be used as a service. This is primarily useful in the context of a
+// client library.
+func (s AppRoleSet) ReadRole(ctx context.Context, name string) (vaultservice.AppRole, error) {
+	resp, err := s.ReadRoleEndpoint(ctx, RoleRequest{Name: name})
+	if err != nil {
+		return vaultservice.AppRole{}, err
+	}
+	response := resp.(RoleResponse)
+	return response.AppRole, response.Err
+}
+
+// WriteRole implements vaultservice.AppRoleService interface.
+func (s AppRoleSet) WriteRole(ctx context.Context, name string, role vaultservice.AppRole) error {
+	resp, err := s.WriteRoleEndpoint(ctx, WriteRoleRequest{Name: name, AppRole: role})
+	if err != nil {
+		return err
+	}
+	return resp.(RoleResponse).Err
+}
+
+// DeleteRole implements vaultservice.AppRoleService interface.
+func (s AppRoleSet) DeleteRole(ctx context.Context, name string) error {
+	resp, err := s.DeleteRoleEndpoint(ctx, RoleRequest{Name: name})
+	if err != nil {
+		return err
+	}
+	return resp.(RoleResponse).Err
+}
+
+// ListRoles implements vaultservice.AppRoleService interface.
+func (s AppRoleSet) ListRoles(ctx context.Context) ([]string, error) {
+	resp, err := s.ListRolesEndpoint(ctx, ListRolesRequest{})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(ListRolesResponse)
+	return response.Roles, response.Err
+}
+
+// GenerateSecretID implements vaultservice.AppRoleService interface.
+func (s AppRoleSet) GenerateSecretID(ctx context.Context, name string) (vaultservice.AppRoleSecretID, error) {
+	resp, err := s.GenerateSecretIDEndpoint(ctx, RoleRequest{Name: name})
+	if err != nil {
+		return vaultservice.AppRoleSecretID{}, err
+	}
+	response := resp.(SecretIDResponse)
+	return response.AppRoleSecretID, response.Err
+}
+
+// DestroySecretID implements vaultservice.AppRoleService interface.
+func (s AppRoleSet) DestroySecretID(ctx context.Context, name, accessor string) error {
+	resp, err := s.DestroySecretIDEndpoint(ctx, DestroySecretIDRequest{Name: name, Accessor: accessor})
+	if err != nil {
+		return err
+	}
+	return resp.(SecretIDResponse).Err
+}
+
+// Login implements vaultservice.AppRoleService interface.
+func (s AppRoleSet) Login(ctx context.Context, roleID, secretID string) (token.Token, error) {
+	resp, err := s.LoginEndpoint(ctx, AppRoleLoginRequest{RoleID: roleID, SecretID: secretID})
+	if err != nil {
+		return token.Token{}, err
+	}
+	response := resp.(TokenResponse)
+	return response.Token, response.Err
+}
+
+// MakeReadRoleEndpoint constructs a ReadRole endpoint wrapping the service.
+func MakeReadRoleEndpoint(s vaultservice.AppRoleService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RoleRequest)
+		role, err := s.ReadRole(ctx, req.Name)
+		return RoleResponse{Name: req.Name, AppRole: role, Err: err}, nil
+	}
+}
+
+// MakeWriteRoleEndpoint constructs a WriteRole endpoint wrapping the service.
+func MakeWriteRoleEndpoint(s vaultservice.AppRoleService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(WriteRoleRequest)
+		err := s.WriteRole(ctx, req.Name, req.AppRole)
+		return RoleResponse{Name: req.Name, Err: err}, nil
+	}
+}
+
+// MakeDeleteRoleEndpoint constructs a DeleteRole endpoint wrapping the
+// service.
+func MakeDeleteRoleEndpoint(s vaultservice.AppRoleService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RoleRequest)
+		err := s.DeleteRole(ctx, req.Name)
+		return RoleResponse{Name: req.Name, Err: err}, nil
+	}
+}
+
+// MakeListRolesEndpoint constructs a ListRoles endpoint wrapping the service.
+func MakeListRolesEndpoint(s vaultservice.AppRoleService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		names, err := s.ListRoles(ctx)
+		return ListRolesResponse{Roles: names, Err: err}, nil
+	}
+}
+
+// MakeGenerateSecretIDEndpoint constructs a GenerateSecretID endpoint
+// wrapping the service.
+func MakeGenerateSecretIDEndpoint(s vaultservice.AppRoleService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RoleRequest)
+		secretID, err := s.GenerateSecretID(ctx, req.Name)
+		return SecretIDResponse{AppRoleSecretID: secretID, Err: err}, nil
+	}
+}
+
+// MakeDestroySecretIDEndpoint constructs a DestroySecretID endpoint wrapping
+// the service.
+func MakeDestroySecretIDEndpoint(s vaultservice.AppRoleService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DestroySecretIDRequest)
+		err := s.DestroySecretID(ctx, req.Name, req.Accessor)
+		return SecretIDResponse{Err: err}, nil
+	}
+}
+
+// MakeAppRoleLoginEndpoint constructs a Login endpoint wrapping the service.
+func MakeAppRoleLoginEndpoint(s vaultservice.AppRoleService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(AppRoleLoginRequest)
+		t, err := s.Login(ctx, req.RoleID, req.SecretID)
+		return newTokenResponse(t, err), nil
+	}
+}
+
+// Compile time assertions for the response types implementing endpoint.Failer.
+var (
+	_ endpoint.Failer = RoleResponse{}
+	_ endpoint.Failer = ListRolesResponse{}
+	_ endpoint.Failer = SecretIDResponse{}
+)
+
+type RoleRequest struct {
+	Name string `json:"-"`
+}
+
+type WriteRoleRequest struct {
+	Name string `json:"-"`
+	vaultservice.AppRole
+}
+
+type RoleResponse struct {
+	Name string `json:"name"`
+	vaultservice.AppRole
+	Err error `json:"-"`
+}
+
+func (r RoleResponse) Failed() error {
+	return r.Err
+}
+
+type ListRolesRequest struct{}
+
+type ListRolesResponse struct {
+	Roles []string `json:"roles"`
+	Err   error    `json:"-"`
+}
+
+func (r ListRolesResponse) Failed() error {
+	return r.Err
+}
+
+type DestroySecretIDRequest struct {
+	Name     string `json:"-"`
+	Accessor string `json:"secret_id_accessor"`
+}
+
+type SecretIDResponse struct {
+	vaultservice.AppRoleSecretID
+	Err error `json:"-"`
+}
+
+func (r SecretIDResponse) Failed() error {
+	return r.Err
+}
+
+type AppRoleLoginRequest struct {
+	RoleID   string `json:"role_id"`
+	SecretID string `json:"secret_id"`
+}
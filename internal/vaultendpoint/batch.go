@@ -0,0 +1,198 @@
+package vaultendpoint
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/ratelimit"
+	"github.com/sony/gobreaker"
+	"golang.org/x/time/rate"
+
+	"github.com/williamlsh/vault/internal/identity"
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/vaultservice"
+)
+
+// BatchSet collects the endpoints serving the items of the BatchHash and
+// BatchValidate streams, one item per call, and the functions opening the
+// streams.
+type BatchSet struct {
+	BatchHashEndpoint     endpoint.Endpoint
+	BatchValidateEndpoint endpoint.Endpoint
+	OpenBatchHash         StreamOpener
+	OpenBatchValidate     StreamOpener
+}
+
+// StreamOpener is called once when a stream opens, and returns the context
+// of its items or the error refusing the stream.
+type StreamOpener func(ctx context.Context) (context.Context, error)
+
+const (
+	// StreamRecheckInterval is how often the credentials of the caller of a
+	// stream are checked again while it sends items, so that a revoked or
+	// denied token ends the streams it opened.
+	StreamRecheckInterval = 10 * time.Second
+
+	// batchItemRate and batchItemBurst bound the items served per second by
+	// all the streams of a method.
+	batchItemRate  = 100
+	batchItemBurst = 64
+)
+
+// NewBatchSet returns a BatchSet that wraps the provided service. The caller
+// of a stream is authenticated when the stream opens, and each item is
+// authorized against the ACL of the caller compiled then, so that JWTs are
+// only used once however many items they carry. The credentials are checked
+// again every StreamRecheckInterval while items arrive, and the transport
+// ends the stream when they expire. Streams are rate limited like the Hash
+// and Validate calls, each stream counting as one call, and their items by a
+// limiter of their own, which they wait for so that the flow control of a
+// stream slows its client down. Unlike those calls, the items are not traced
+// or logged one by one, and the errors ending the streams are logged by the
+// transport.
+func NewBatchSet(svc vaultservice.Service, auth *Authorizer, breakers *BreakerRegistry, duration metrics.Histogram) BatchSet {
+	var batchHashEndpoint endpoint.Endpoint
+	{
+		batchHashEndpoint = MakeBatchHashEndpoint(svc)
+		batchHashEndpoint = ratelimit.NewDelayingLimiter(rate.NewLimiter(batchItemRate, batchItemBurst))(batchHashEndpoint)
+		batchHashEndpoint = breakers.Breaker("pb.Vault", gobreaker.Settings{Name: "BatchHash"})(batchHashEndpoint)
+		batchHashEndpoint = ScopeMiddleware(ScopeHash)(batchHashEndpoint)
+		batchHashEndpoint = AuthorizationMiddleware(auth.policies, At("hash", policy.Hash))(batchHashEndpoint)
+		batchHashEndpoint = recheck(auth)(batchHashEndpoint)
+		batchHashEndpoint = InstrumentingMiddleware(duration.With("method", "BatchHash"))(batchHashEndpoint)
+	}
+	var batchValidateEndpoint endpoint.Endpoint
+	{
+		batchValidateEndpoint = MakeBatchValidateEndpoint(svc)
+		batchValidateEndpoint = ratelimit.NewDelayingLimiter(rate.NewLimiter(batchItemRate, batchItemBurst))(batchValidateEndpoint)
+		batchValidateEndpoint = breakers.Breaker("pb.Vault", gobreaker.Settings{Name: "BatchValidate"})(batchValidateEndpoint)
+		batchValidateEndpoint = ScopeMiddleware(ScopeValidate)(batchValidateEndpoint)
+		batchValidateEndpoint = AuthorizationMiddleware(auth.policies, At("validate", policy.Validate))(batchValidateEndpoint)
+		batchValidateEndpoint = recheck(auth)(batchValidateEndpoint)
+		batchValidateEndpoint = InstrumentingMiddleware(duration.With("method", "BatchValidate"))(batchValidateEndpoint)
+	}
+	return BatchSet{
+		BatchHashEndpoint:     batchHashEndpoint,
+		BatchValidateEndpoint: batchValidateEndpoint,
+		OpenBatchHash:         openStream(auth, rate.NewLimiter(rate.Every(time.Second), 1)),
+		OpenBatchValidate:     openStream(auth, rate.NewLimiter(rate.Every(time.Second), 1)),
+	}
+}
+
+// openStream returns a StreamOpener authenticating the caller of the stream
+// and compiling the ACL of its policies for the items, then admitting the
+// stream through the limiter.
+func openStream(auth *Authorizer, limiter *rate.Limiter) StreamOpener {
+	return func(ctx context.Context) (context.Context, error) {
+		ctx, err := auth.Authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		id, _ := identity.FromContext(ctx)
+		acl, err := auth.policies.ACL(ctx, id.Policies...)
+		if err != nil {
+			return nil, err
+		}
+		if !limiter.Allow() {
+			return nil, ratelimit.ErrLimited
+		}
+		ctx = context.WithValue(ctx, aclContextKey{}, acl)
+		return context.WithValue(ctx, streamAuthContextKey{}, &streamAuth{checked: time.Now()}), nil
+	}
+}
+
+// streamAuthContextKey is the context key of the streamAuth of a stream.
+type streamAuthContextKey struct{}
+
+// streamAuth tracks when the credentials of the caller of a stream were last
+// checked.
+type streamAuth struct {
+	mu      sync.Mutex
+	checked time.Time
+}
+
+// recheck returns an endpoint middleware checking again the credentials of
+// the caller of a stream once they were last checked StreamRecheckInterval
+// ago. The items served concurrently wait for the same check.
+func recheck(auth *Authorizer) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if s, ok := ctx.Value(streamAuthContextKey{}).(*streamAuth); ok {
+				s.mu.Lock()
+				if time.Since(s.checked) >= StreamRecheckInterval {
+					if err := auth.Recheck(ctx); err != nil {
+						s.mu.Unlock()
+						return nil, err
+					}
+					s.checked = time.Now()
+				}
+				s.mu.Unlock()
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// MakeBatchHashEndpoint constructs a BatchHash item endpoint wrapping the
+// service.
+func MakeBatchHashEndpoint(s vaultservice.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(BatchHashRequest)
+		v, err := s.Hash(ctx, req.Password)
+		return BatchHashResponse{ID: req.ID, Hash: v, Err: err}, nil
+	}
+}
+
+// MakeBatchValidateEndpoint constructs a BatchValidate item endpoint wrapping
+// the service.
+func MakeBatchValidateEndpoint(s vaultservice.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(BatchValidateRequest)
+		v, err := s.Validate(ctx, req.Password, req.Hash)
+		return BatchValidateResponse{ID: req.ID, Valid: v, Err: err}, nil
+	}
+}
+
+// Compile time assertions for the response types implementing endpoint.Failer.
+var (
+	_ endpoint.Failer = BatchHashResponse{}
+	_ endpoint.Failer = BatchValidateResponse{}
+)
+
+// BatchHashRequest is an item of a BatchHash stream, correlated to its
+// response by its ID.
+type BatchHashRequest struct {
+	ID       string `json:"id"`
+	Password string `json:"password"`
+}
+
+type BatchHashResponse struct {
+	ID   string `json:"id"`
+	Hash string `json:"hash"`
+	Err  error  `json:"-"`
+}
+
+func (r BatchHashResponse) Failed() error {
+	return r.Err
+}
+
+// BatchValidateRequest is an item of a BatchValidate stream, correlated to its
+// response by its ID.
+type BatchValidateRequest struct {
+	ID       string `json:"id"`
+	Password string `json:"password"`
+	Hash     string `json:"hash"`
+}
+
+type BatchValidateResponse struct {
+	ID    string `json:"id"`
+	Valid bool   `json:"valid"`
+	Err   error  `json:"-"`
+}
+
+func (r BatchValidateResponse) Failed() error {
+	return r.Err
+}
@@ -0,0 +1,60 @@
+package vaultendpoint
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/sony/gobreaker"
+)
+
+// BreakerRegistry keeps the circuit breakers of the endpoints by the name of
+// the gRPC service serving them, such as pb.KV, for health checks.
+type BreakerRegistry struct {
+	mu       sync.Mutex
+	breakers map[string][]*gobreaker.CircuitBreaker
+}
+
+// NewBreakerRegistry returns an empty BreakerRegistry.
+func NewBreakerRegistry() *BreakerRegistry {
+	return &BreakerRegistry{breakers: make(map[string][]*gobreaker.CircuitBreaker)}
+}
+
+// Breaker returns an endpoint middleware with a new circuit breaker, which is
+// registered for the service.
+func (r *BreakerRegistry) Breaker(service string, st gobreaker.Settings) endpoint.Middleware {
+	cb := gobreaker.NewCircuitBreaker(st)
+	r.mu.Lock()
+	r.breakers[service] = append(r.breakers[service], cb)
+	r.mu.Unlock()
+	return circuitbreaker.Gobreaker(cb)
+}
+
+// Open returns the sorted names of the open circuit breakers of the service.
+func (r *BreakerRegistry) Open(service string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var open []string
+	for _, cb := range r.breakers[service] {
+		if cb.State() == gobreaker.StateOpen {
+			open = append(open, cb.Name())
+		}
+	}
+	sort.Strings(open)
+	return open
+}
+
+// Check returns a health check of the service, failing while any of its
+// circuit breakers is open.
+func (r *BreakerRegistry) Check(service string) func(context.Context) error {
+	return func(context.Context) error {
+		if open := r.Open(service); len(open) > 0 {
+			return fmt.Errorf("circuit breakers open: %s", strings.Join(open, ", "))
+		}
+		return nil
+	}
+}
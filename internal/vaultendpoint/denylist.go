@@ -0,0 +1,174 @@
+package vaultendpoint
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+
+	"github.com/williamlsh/vault/internal/denylist"
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/vaultservice"
+)
+
+// DenylistSet collects all of the endpoints that manage the denylist.
+type DenylistSet struct {
+	DenyEndpoint       endpoint.Endpoint
+	AllowEndpoint      endpoint.Endpoint
+	ReadDeniedEndpoint endpoint.Endpoint
+	ListDeniedEndpoint endpoint.Endpoint
+}
+
+// NewDenylistSet returns a DenylistSet that wraps the provided denylist
+// service. Requests are authorized on the sys/denylist/<kind>/<value> policy
+// paths.
+func NewDenylistSet(svc vaultservice.DenylistService, auth *Authorizer, duration metrics.Histogram, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) DenylistSet {
+	wrap := func(name string, resource Resource, e endpoint.Endpoint) endpoint.Endpoint {
+		e = authorize(auth, resource)(e)
+		e = opentracing.TraceServer(otTracer, name)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, name)(e)
+		e = LoggingMiddleware(log.With(logger, "method", name))(e)
+		e = InstrumentingMiddleware(duration.With("method", name))(e)
+		return e
+	}
+	return DenylistSet{
+		DenyEndpoint:       wrap("Deny", denylistResource(policy.Create, policy.Update), MakeDenyEndpoint(svc)),
+		AllowEndpoint:      wrap("Allow", denylistResource(policy.Delete), MakeAllowEndpoint(svc)),
+		ReadDeniedEndpoint: wrap("ReadDenied", denylistResource(policy.Read), MakeReadDeniedEndpoint(svc)),
+		ListDeniedEndpoint: wrap("ListDenied", At("sys/denylist", policy.List), MakeListDeniedEndpoint(svc)),
+	}
+}
+
+func denylistResource(capabilities ...string) Resource {
+	return func(request interface{}) (string, []string) {
+		var kind, value string
+		switch req := request.(type) {
+		case DenyRequest:
+			kind, value = req.Kind, req.Value
+		case DenylistEntryRequest:
+			kind, value = req.Kind, req.Value
+		}
+		return "sys/denylist/" + kind + "/" + value, capabilities
+	}
+}
+
+// Deny implements vaultservice.DenylistService interface, so DenylistSet may
+// be used as a service. This is primarily useful in the context of a client
+// library.
+func (s DenylistSet) Deny(ctx context.Context, opts vaultservice.DenyOptions) (denylist.Entry, error) {
+	resp, err := s.DenyEndpoint(ctx, DenyRequest(opts))
+	if err != nil {
+		return denylist.Entry{}, err
+	}
+	response := resp.(DenylistEntryResponse)
+	return response.Entry, response.Err
+}
+
+// Allow implements vaultservice.DenylistService interface.
+func (s DenylistSet) Allow(ctx context.Context, kind, value string) error {
+	resp, err := s.AllowEndpoint(ctx, DenylistEntryRequest{Kind: kind, Value: value})
+	if err != nil {
+		return err
+	}
+	return resp.(DenylistEntryResponse).Err
+}
+
+// ReadDenied implements vaultservice.DenylistService interface.
+func (s DenylistSet) ReadDenied(ctx context.Context, kind, value string) (denylist.Entry, error) {
+	resp, err := s.ReadDeniedEndpoint(ctx, DenylistEntryRequest{Kind: kind, Value: value})
+	if err != nil {
+		return denylist.Entry{}, err
+	}
+	response := resp.(DenylistEntryResponse)
+	return response.Entry, response.Err
+}
+
+// ListDenied implements vaultservice.DenylistService interface.
+func (s DenylistSet) ListDenied(ctx context.Context) ([]denylist.Entry, error) {
+	resp, err := s.ListDeniedEndpoint(ctx, ListDeniedRequest{})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(ListDeniedResponse)
+	return response.Entries, response.Err
+}
+
+// MakeDenyEndpoint constructs a Deny endpoint wrapping the service.
+func MakeDenyEndpoint(s vaultservice.DenylistService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DenyRequest)
+		e, err := s.Deny(ctx, vaultservice.DenyOptions(req))
+		return DenylistEntryResponse{Entry: e, Err: err}, nil
+	}
+}
+
+// MakeAllowEndpoint constructs an Allow endpoint wrapping the service.
+func MakeAllowEndpoint(s vaultservice.DenylistService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DenylistEntryRequest)
+		err := s.Allow(ctx, req.Kind, req.Value)
+		return DenylistEntryResponse{Entry: denylist.Entry{Kind: req.Kind, Value: req.Value}, Err: err}, nil
+	}
+}
+
+// MakeReadDeniedEndpoint constructs a ReadDenied endpoint wrapping the
+// service.
+func MakeReadDeniedEndpoint(s vaultservice.DenylistService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DenylistEntryRequest)
+		e, err := s.ReadDenied(ctx, req.Kind, req.Value)
+		return DenylistEntryResponse{Entry: e, Err: err}, nil
+	}
+}
+
+// MakeListDeniedEndpoint constructs a ListDenied endpoint wrapping the
+// service.
+func MakeListDeniedEndpoint(s vaultservice.DenylistService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		entries, err := s.ListDenied(ctx)
+		return ListDeniedResponse{Entries: entries, Err: err}, nil
+	}
+}
+
+// Compile time assertions for the response types implementing endpoint.Failer.
+var (
+	_ endpoint.Failer = DenylistEntryResponse{}
+	_ endpoint.Failer = ListDeniedResponse{}
+)
+
+type DenyRequest struct {
+	Kind   string `json:"-"`
+	Value  string `json:"-"`
+	Reason string `json:"reason,omitempty"`
+	TTL    string `json:"ttl,omitempty"`
+}
+
+type DenylistEntryRequest struct {
+	Kind  string `json:"-"`
+	Value string `json:"-"`
+}
+
+type DenylistEntryResponse struct {
+	denylist.Entry
+	Err error `json:"-"`
+}
+
+func (r DenylistEntryResponse) Failed() error {
+	return r.Err
+}
+
+type ListDeniedRequest struct{}
+
+type ListDeniedResponse struct {
+	Entries []denylist.Entry `json:"entries"`
+	Err     error            `json:"-"`
+}
+
+func (r ListDeniedResponse) Failed() error {
+	return r.Err
+}
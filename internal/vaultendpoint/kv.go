@@ -0,0 +1,349 @@
+package vaultendpoint
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+	"github.com/sony/gobreaker"
+
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/vaultservice"
+)
+
+// KVSet collects all of the endpoints that compose the key-value secrets
+// engine.
+type KVSet struct {
+	PutEndpoint            endpoint.Endpoint
+	GetEndpoint            endpoint.Endpoint
+	DeleteEndpoint         endpoint.Endpoint
+	UndeleteEndpoint       endpoint.Endpoint
+	DestroyEndpoint        endpoint.Endpoint
+	ListEndpoint           endpoint.Endpoint
+	ReadMetadataEndpoint   endpoint.Endpoint
+	WriteMetadataEndpoint  endpoint.Endpoint
+	DeleteMetadataEndpoint endpoint.Endpoint
+}
+
+// NewKVSet returns a KVSet that wraps the provided key-value service, and
+// wires in all of the expected endpoint middlewares. Requests are authorized
+// on the kv/data/, kv/delete/, kv/undelete/, kv/destroy/ and kv/metadata/
+// policy paths of the secret, like the HTTP routes.
+func NewKVSet(svc vaultservice.KVService, auth *Authorizer, breakers *BreakerRegistry, duration metrics.Histogram, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) KVSet {
+	wrap := func(name string, resource Resource, e endpoint.Endpoint) endpoint.Endpoint {
+		e = breakers.Breaker("pb.KV", gobreaker.Settings{Name: name})(e)
+		e = authorize(auth, resource)(e)
+		e = opentracing.TraceServer(otTracer, name)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, name)(e)
+		e = LoggingMiddleware(log.With(logger, "method", name))(e)
+		e = InstrumentingMiddleware(duration.With("method", name))(e)
+		return e
+	}
+	return KVSet{
+		PutEndpoint:            wrap("KVPut", kvResource("kv/data/", policy.Create, policy.Update), MakeKVPutEndpoint(svc)),
+		GetEndpoint:            wrap("KVGet", kvResource("kv/data/", policy.Read), MakeKVGetEndpoint(svc)),
+		DeleteEndpoint:         wrap("KVDelete", kvDeleteResource, MakeKVDeleteEndpoint(svc)),
+		UndeleteEndpoint:       wrap("KVUndelete", kvResource("kv/undelete/", policy.Update), MakeKVUndeleteEndpoint(svc)),
+		DestroyEndpoint:        wrap("KVDestroy", kvResource("kv/destroy/", policy.Update), MakeKVDestroyEndpoint(svc)),
+		ListEndpoint:           wrap("KVList", kvResource("kv/metadata/", policy.List), MakeKVListEndpoint(svc)),
+		ReadMetadataEndpoint:   wrap("KVReadMetadata", kvResource("kv/metadata/", policy.Read), MakeKVReadMetadataEndpoint(svc)),
+		WriteMetadataEndpoint:  wrap("KVWriteMetadata", kvResource("kv/metadata/", policy.Create, policy.Update), MakeKVWriteMetadataEndpoint(svc)),
+		DeleteMetadataEndpoint: wrap("KVDeleteMetadata", kvResource("kv/metadata/", policy.Delete), MakeKVDeleteMetadataEndpoint(svc)),
+	}
+}
+
+// kvResource returns the Resource of key-value requests on the secret path
+// under prefix. Listed folders keep a trailing slash.
+func kvResource(prefix string, capabilities ...string) Resource {
+	return func(request interface{}) (string, []string) {
+		var path string
+		switch req := request.(type) {
+		case KVPutRequest:
+			path = req.Path
+		case KVGetRequest:
+			path = req.Path
+		case KVVersionsRequest:
+			path = req.Path
+		case KVListRequest:
+			if path = strings.Trim(req.Path, "/"); path != "" {
+				path += "/"
+			}
+			return prefix + path, capabilities
+		case KVMetadataRequest:
+			path = req.Path
+		case KVWriteMetadataRequest:
+			path = req.Path
+		}
+		return prefix + strings.Trim(path, "/"), capabilities
+	}
+}
+
+// kvDeleteResource requires the delete capability on kv/data/ to delete the
+// current version, and update on kv/delete/ to delete given versions.
+func kvDeleteResource(request interface{}) (string, []string) {
+	if len(request.(KVVersionsRequest).Versions) == 0 {
+		return kvResource("kv/data/", policy.Delete)(request)
+	}
+	return kvResource("kv/delete/", policy.Update)(request)
+}
+
+// Put implements vaultservice.KVService interface, so KVSet may be used as a
+// service. This is primarily useful in the context of a client library.
+func (s KVSet) Put(ctx context.Context, path string, data map[string]string, cas *int) (vaultservice.KVVersionMetadata, error) {
+	resp, err := s.PutEndpoint(ctx, KVPutRequest{Path: path, Data: data, Options: KVPutOptions{CAS: cas}})
+	if err != nil {
+		return vaultservice.KVVersionMetadata{}, err
+	}
+	response := resp.(KVPutResponse)
+	return response.KVVersionMetadata, response.Err
+}
+
+// Get implements vaultservice.KVService interface.
+func (s KVSet) Get(ctx context.Context, path string, version int) (vaultservice.KVSecret, error) {
+	resp, err := s.GetEndpoint(ctx, KVGetRequest{Path: path, Version: version})
+	if err != nil {
+		return vaultservice.KVSecret{}, err
+	}
+	response := resp.(KVGetResponse)
+	return response.KVSecret, response.Err
+}
+
+// Delete implements vaultservice.KVService interface.
+func (s KVSet) Delete(ctx context.Context, path string, versions []int) error {
+	return s.versions(ctx, s.DeleteEndpoint, path, versions)
+}
+
+// Undelete implements vaultservice.KVService interface.
+func (s KVSet) Undelete(ctx context.Context, path string, versions []int) error {
+	return s.versions(ctx, s.UndeleteEndpoint, path, versions)
+}
+
+// Destroy implements vaultservice.KVService interface.
+func (s KVSet) Destroy(ctx context.Context, path string, versions []int) error {
+	return s.versions(ctx, s.DestroyEndpoint, path, versions)
+}
+
+func (s KVSet) versions(ctx context.Context, e endpoint.Endpoint, path string, versions []int) error {
+	resp, err := e(ctx, KVVersionsRequest{Path: path, Versions: versions})
+	if err != nil {
+		return err
+	}
+	return resp.(KVResponse).Err
+}
+
+// List implements vaultservice.KVService interface.
+func (s KVSet) List(ctx context.Context, path string) ([]string, error) {
+	resp, err := s.ListEndpoint(ctx, KVListRequest{Path: path})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(KVListResponse)
+	return response.Keys, response.Err
+}
+
+// ReadMetadata implements vaultservice.KVService interface.
+func (s KVSet) ReadMetadata(ctx context.Context, path string) (vaultservice.KVMetadata, error) {
+	resp, err := s.ReadMetadataEndpoint(ctx, KVMetadataRequest{Path: path})
+	if err != nil {
+		return vaultservice.KVMetadata{}, err
+	}
+	response := resp.(KVMetadataResponse)
+	return response.KVMetadata, response.Err
+}
+
+// WriteMetadata implements vaultservice.KVService interface.
+func (s KVSet) WriteMetadata(ctx context.Context, path string, cfg vaultservice.KVMetadataConfig) error {
+	resp, err := s.WriteMetadataEndpoint(ctx, KVWriteMetadataRequest{Path: path, KVMetadataConfig: cfg})
+	if err != nil {
+		return err
+	}
+	return resp.(KVResponse).Err
+}
+
+// DeleteMetadata implements vaultservice.KVService interface.
+func (s KVSet) DeleteMetadata(ctx context.Context, path string) error {
+	resp, err := s.DeleteMetadataEndpoint(ctx, KVMetadataRequest{Path: path})
+	if err != nil {
+		return err
+	}
+	return resp.(KVResponse).Err
+}
+
+// MakeKVPutEndpoint constructs a Put endpoint wrapping the service.
+func MakeKVPutEndpoint(s vaultservice.KVService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(KVPutRequest)
+		md, err := s.Put(ctx, req.Path, req.Data, req.Options.CAS)
+		return KVPutResponse{KVVersionMetadata: md, Err: err}, nil
+	}
+}
+
+// MakeKVGetEndpoint constructs a Get endpoint wrapping the service.
+func MakeKVGetEndpoint(s vaultservice.KVService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(KVGetRequest)
+		secret, err := s.Get(ctx, req.Path, req.Version)
+		return KVGetResponse{KVSecret: secret, Err: err}, nil
+	}
+}
+
+// MakeKVDeleteEndpoint constructs a Delete endpoint wrapping the service.
+func MakeKVDeleteEndpoint(s vaultservice.KVService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(KVVersionsRequest)
+		err := s.Delete(ctx, req.Path, req.Versions)
+		return KVResponse{Err: err}, nil
+	}
+}
+
+// MakeKVUndeleteEndpoint constructs an Undelete endpoint wrapping the
+// service.
+func MakeKVUndeleteEndpoint(s vaultservice.KVService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(KVVersionsRequest)
+		err := s.Undelete(ctx, req.Path, req.Versions)
+		return KVResponse{Err: err}, nil
+	}
+}
+
+// MakeKVDestroyEndpoint constructs a Destroy endpoint wrapping the service.
+func MakeKVDestroyEndpoint(s vaultservice.KVService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(KVVersionsRequest)
+		err := s.Destroy(ctx, req.Path, req.Versions)
+		return KVResponse{Err: err}, nil
+	}
+}
+
+// MakeKVListEndpoint constructs a List endpoint wrapping the service.
+func MakeKVListEndpoint(s vaultservice.KVService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(KVListRequest)
+		keys, err := s.List(ctx, req.Path)
+		return KVListResponse{Keys: keys, Err: err}, nil
+	}
+}
+
+// MakeKVReadMetadataEndpoint constructs a ReadMetadata endpoint wrapping the
+// service.
+func MakeKVReadMetadataEndpoint(s vaultservice.KVService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(KVMetadataRequest)
+		md, err := s.ReadMetadata(ctx, req.Path)
+		return KVMetadataResponse{KVMetadata: md, Err: err}, nil
+	}
+}
+
+// MakeKVWriteMetadataEndpoint constructs a WriteMetadata endpoint wrapping
+// the service.
+func MakeKVWriteMetadataEndpoint(s vaultservice.KVService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(KVWriteMetadataRequest)
+		err := s.WriteMetadata(ctx, req.Path, req.KVMetadataConfig)
+		return KVResponse{Err: err}, nil
+	}
+}
+
+// MakeKVDeleteMetadataEndpoint constructs a DeleteMetadata endpoint wrapping
+// the service.
+func MakeKVDeleteMetadataEndpoint(s vaultservice.KVService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(KVMetadataRequest)
+		err := s.DeleteMetadata(ctx, req.Path)
+		return KVResponse{Err: err}, nil
+	}
+}
+
+// Compile time assertions for the response types implementing endpoint.Failer.
+var (
+	_ endpoint.Failer = KVResponse{}
+	_ endpoint.Failer = KVPutResponse{}
+	_ endpoint.Failer = KVGetResponse{}
+	_ endpoint.Failer = KVListResponse{}
+	_ endpoint.Failer = KVMetadataResponse{}
+)
+
+type KVPutRequest struct {
+	Path    string            `json:"-"`
+	Data    map[string]string `json:"data"`
+	Options KVPutOptions      `json:"options"`
+}
+
+type KVPutOptions struct {
+	CAS *int `json:"cas,omitempty"`
+}
+
+type KVPutResponse struct {
+	vaultservice.KVVersionMetadata
+	Err error `json:"-"`
+}
+
+func (r KVPutResponse) Failed() error {
+	return r.Err
+}
+
+type KVGetRequest struct {
+	Path    string `json:"-"`
+	Version int    `json:"version"`
+}
+
+type KVGetResponse struct {
+	vaultservice.KVSecret
+	Err error `json:"-"`
+}
+
+func (r KVGetResponse) Failed() error {
+	return r.Err
+}
+
+type KVVersionsRequest struct {
+	Path     string `json:"-"`
+	Versions []int  `json:"versions"`
+}
+
+type KVListRequest struct {
+	Path string `json:"-"`
+}
+
+type KVListResponse struct {
+	Keys []string `json:"keys"`
+	Err  error    `json:"-"`
+}
+
+func (r KVListResponse) Failed() error {
+	return r.Err
+}
+
+type KVMetadataRequest struct {
+	Path string `json:"-"`
+}
+
+type KVMetadataResponse struct {
+	vaultservice.KVMetadata
+	Err error `json:"-"`
+}
+
+func (r KVMetadataResponse) Failed() error {
+	return r.Err
+}
+
+type KVWriteMetadataRequest struct {
+	Path string `json:"-"`
+	vaultservice.KVMetadataConfig
+}
+
+// KVResponse is the response of the key-value operations returning nothing
+// but an error.
+type KVResponse struct {
+	Err error `json:"-"`
+}
+
+func (r KVResponse) Failed() error {
+	return r.Err
+}
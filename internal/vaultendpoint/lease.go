@@ -0,0 +1,198 @@
+package vaultendpoint
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+
+	"github.com/williamlsh/vault/internal/lease"
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/vaultservice"
+)
+
+// LeaseSet collects all of the endpoints that manage leases.
+type LeaseSet struct {
+	LookupEndpoint       endpoint.Endpoint
+	RenewEndpoint        endpoint.Endpoint
+	RevokeEndpoint       endpoint.Endpoint
+	RevokePrefixEndpoint endpoint.Endpoint
+	ListEndpoint         endpoint.Endpoint
+}
+
+// NewLeaseSet returns a LeaseSet that wraps the provided lease service.
+// Single leases are authorized on the sys/leases/lookup, sys/leases/renew and
+// sys/leases/revoke policy paths. Revoking by prefix requires sudo on
+// sys/leases/revoke-prefix/<prefix>, and listing requires list on
+// sys/leases/lookup/<prefix>.
+func NewLeaseSet(svc vaultservice.LeaseService, auth *Authorizer, duration metrics.Histogram, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) LeaseSet {
+	wrap := func(name string, resource Resource, e endpoint.Endpoint) endpoint.Endpoint {
+		e = authorize(auth, resource)(e)
+		e = opentracing.TraceServer(otTracer, name)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, name)(e)
+		e = LoggingMiddleware(log.With(logger, "method", name))(e)
+		e = InstrumentingMiddleware(duration.With("method", name))(e)
+		return e
+	}
+	return LeaseSet{
+		LookupEndpoint:       wrap("LeaseLookup", At("sys/leases/lookup", policy.Update), MakeLeaseLookupEndpoint(svc)),
+		RenewEndpoint:        wrap("LeaseRenew", At("sys/leases/renew", policy.Update), MakeLeaseRenewEndpoint(svc)),
+		RevokeEndpoint:       wrap("LeaseRevoke", At("sys/leases/revoke", policy.Update), MakeLeaseRevokeEndpoint(svc)),
+		RevokePrefixEndpoint: wrap("LeaseRevokePrefix", leasePrefixResource("sys/leases/revoke-prefix/", policy.Sudo), MakeLeaseRevokePrefixEndpoint(svc)),
+		ListEndpoint:         wrap("LeaseList", leasePrefixResource("sys/leases/lookup/", policy.List), MakeLeaseListEndpoint(svc)),
+	}
+}
+
+func leasePrefixResource(path string, capabilities ...string) Resource {
+	return func(request interface{}) (string, []string) {
+		req, _ := request.(LeasePrefixRequest)
+		return path + strings.TrimPrefix(req.Prefix, "/"), capabilities
+	}
+}
+
+// Lookup implements vaultservice.LeaseService interface, so LeaseSet may be
+// used as a service. This is primarily useful in the context of a client
+// library.
+func (s LeaseSet) Lookup(ctx context.Context, id string) (lease.Lease, error) {
+	resp, err := s.LookupEndpoint(ctx, LeaseRequest{LeaseID: id})
+	if err != nil {
+		return lease.Lease{}, err
+	}
+	response := resp.(LeaseResponse)
+	return response.Lease, response.Err
+}
+
+// Renew implements vaultservice.LeaseService interface.
+func (s LeaseSet) Renew(ctx context.Context, id string, increment time.Duration) (lease.Lease, error) {
+	resp, err := s.RenewEndpoint(ctx, RenewLeaseRequest{LeaseID: id, Increment: int64(increment / time.Second)})
+	if err != nil {
+		return lease.Lease{}, err
+	}
+	response := resp.(LeaseResponse)
+	return response.Lease, response.Err
+}
+
+// Revoke implements vaultservice.LeaseService interface.
+func (s LeaseSet) Revoke(ctx context.Context, id string) error {
+	resp, err := s.RevokeEndpoint(ctx, LeaseRequest{LeaseID: id})
+	if err != nil {
+		return err
+	}
+	return resp.(LeaseResponse).Err
+}
+
+// RevokePrefix implements vaultservice.LeaseService interface.
+func (s LeaseSet) RevokePrefix(ctx context.Context, prefix string) error {
+	resp, err := s.RevokePrefixEndpoint(ctx, LeasePrefixRequest{Prefix: prefix})
+	if err != nil {
+		return err
+	}
+	return resp.(LeaseResponse).Err
+}
+
+// List implements vaultservice.LeaseService interface.
+func (s LeaseSet) List(ctx context.Context, prefix string) ([]string, error) {
+	resp, err := s.ListEndpoint(ctx, LeasePrefixRequest{Prefix: prefix})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(ListLeasesResponse)
+	return response.Keys, response.Err
+}
+
+// MakeLeaseLookupEndpoint constructs a Lookup endpoint wrapping the service.
+func MakeLeaseLookupEndpoint(s vaultservice.LeaseService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(LeaseRequest)
+		l, err := s.Lookup(ctx, req.LeaseID)
+		return newLeaseResponse(l, err), nil
+	}
+}
+
+// MakeLeaseRenewEndpoint constructs a Renew endpoint wrapping the service.
+func MakeLeaseRenewEndpoint(s vaultservice.LeaseService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RenewLeaseRequest)
+		l, err := s.Renew(ctx, req.LeaseID, time.Duration(req.Increment)*time.Second)
+		return newLeaseResponse(l, err), nil
+	}
+}
+
+// MakeLeaseRevokeEndpoint constructs a Revoke endpoint wrapping the service.
+func MakeLeaseRevokeEndpoint(s vaultservice.LeaseService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(LeaseRequest)
+		err := s.Revoke(ctx, req.LeaseID)
+		return LeaseResponse{Lease: lease.Lease{ID: req.LeaseID}, Err: err}, nil
+	}
+}
+
+// MakeLeaseRevokePrefixEndpoint constructs a RevokePrefix endpoint wrapping
+// the service.
+func MakeLeaseRevokePrefixEndpoint(s vaultservice.LeaseService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(LeasePrefixRequest)
+		err := s.RevokePrefix(ctx, req.Prefix)
+		return LeaseResponse{Err: err}, nil
+	}
+}
+
+// MakeLeaseListEndpoint constructs a List endpoint wrapping the service.
+func MakeLeaseListEndpoint(s vaultservice.LeaseService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(LeasePrefixRequest)
+		ids, err := s.List(ctx, req.Prefix)
+		return ListLeasesResponse{Keys: ids, Err: err}, nil
+	}
+}
+
+// Compile time assertions for the response types implementing endpoint.Failer.
+var (
+	_ endpoint.Failer = LeaseResponse{}
+	_ endpoint.Failer = ListLeasesResponse{}
+)
+
+type LeaseRequest struct {
+	LeaseID string `json:"lease_id"`
+}
+
+type RenewLeaseRequest struct {
+	LeaseID string `json:"lease_id"`
+	// Increment is the requested extension in seconds.
+	Increment int64 `json:"increment"`
+}
+
+type LeasePrefixRequest struct {
+	Prefix string `json:"-"`
+}
+
+type LeaseResponse struct {
+	lease.Lease
+	// TTL is the number of seconds left before the lease expires.
+	TTL int64 `json:"ttl"`
+	Err error `json:"-"`
+}
+
+func newLeaseResponse(l lease.Lease, err error) LeaseResponse {
+	return LeaseResponse{Lease: l, TTL: int64(l.TTL() / time.Second), Err: err}
+}
+
+func (r LeaseResponse) Failed() error {
+	return r.Err
+}
+
+type ListLeasesResponse struct {
+	Keys []string `json:"keys"`
+	Err  error    `json:"-"`
+}
+
+func (r ListLeasesResponse) Failed() error {
+	return r.Err
+}
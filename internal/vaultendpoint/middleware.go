@@ -3,11 +3,18 @@ package vaultendpoint
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/go-kit/kit/auth/jwt"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics"
+	stdjwt "github.com/golang-jwt/jwt/v4"
+
+	"github.com/williamlsh/vault/internal/identity"
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/token"
 )
 
 // InstrumentingMiddleware returns an endpoint middleware that records
@@ -26,14 +33,150 @@ func InstrumentingMiddleware(duration metrics.Histogram) endpoint.Middleware {
 }
 
 // LoggingMiddleware returns an endpoint middleware that logs the
-// duration of each invocation, and the resulting error, if any.
+// duration of each invocation, and the resulting error, if any. The identity
+// of the TLS client certificate of the request is logged too, if any.
 func LoggingMiddleware(logger log.Logger) endpoint.Middleware {
 	return func(next endpoint.Endpoint) endpoint.Endpoint {
 		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 			defer func(begin time.Time) {
-				logger.Log("transport_error", err, "took", time.Since(begin))
+				keyvals := []interface{}{"transport_error", err, "took", time.Since(begin)}
+				if cert := identity.Certificate(ctx); cert != "" {
+					keyvals = append(keyvals, "client_cert", cert)
+				}
+				logger.Log(keyvals...)
 			}(time.Now())
 			return next(ctx, request)
 		}
 	}
 }
+
+// Resource returns the policy path a request acts on, and the capabilities
+// any of which allows the request.
+type Resource func(request interface{}) (path string, capabilities []string)
+
+// At returns a Resource for requests acting on a fixed path.
+func At(path string, capabilities ...string) Resource {
+	return func(interface{}) (string, []string) {
+		return path, capabilities
+	}
+}
+
+// AuthenticationMiddleware returns an endpoint middleware that establishes the
+// identity of the caller from the token parsed into the context. Service
+// tokens are looked up in the token store, granting the caller the policies
+// of the token. JWTs of the external issuer are verified against its key set,
+// granting the caller the policies attached to their subject; any other
+// bearer is verified as an OAuth2 access token. JWTs whose ID or subject is
+// denied are refused, as are replayed external JWTs if replays are tracked.
+// Requests without a token, sent with a verified TLS client certificate, are
+// granted the policies attached to the subject named by the certificate.
+func AuthenticationMiddleware(auth *Authorizer) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, err := auth.Authenticate(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// Authenticate establishes the identity of the caller like
+// AuthenticationMiddleware, and returns a context holding it.
+func (a *Authorizer) Authenticate(ctx context.Context) (context.Context, error) {
+	id, _ := ctx.Value(jwt.JWTContextKey).(string)
+	var (
+		caller identity.Identity
+		err    error
+	)
+	cert := identity.Certificate(ctx)
+	switch {
+	case id == "" && cert != "":
+		caller, err = a.verifyCertificate(ctx, cert)
+	case id == "" || strings.HasPrefix(id, token.Prefix):
+		caller, err = a.lookupToken(ctx, id)
+	case a.verifier != nil && unverifiedIssuer(id) == a.verifier.Issuer():
+		caller, err = a.verifyJWT(ctx, id)
+	case a.issuer != nil:
+		caller, err = a.verifyAccessToken(ctx, id)
+	default:
+		caller, err = a.lookupToken(ctx, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	caller.Certificate = cert
+	return identity.NewContext(ctx, caller), nil
+}
+
+// aclContextKey is the context key of the ACL compiled once for the items of
+// a stream.
+type aclContextKey struct{}
+
+// AuthorizationMiddleware returns an endpoint middleware that refuses requests
+// with policy.ErrPermissionDenied unless the policies of the caller identity
+// grant one of the capabilities required by the resource. The ACL of the
+// policies is compiled for each request, unless the context holds the ACL of
+// a stream.
+func AuthorizationMiddleware(policies *policy.Store, resource Resource) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			id, ok := identity.FromContext(ctx)
+			if !ok {
+				return nil, policy.ErrPermissionDenied
+			}
+			acl, ok := ctx.Value(aclContextKey{}).(*policy.ACL)
+			if !ok {
+				var err error
+				if acl, err = policies.ACL(ctx, id.Policies...); err != nil {
+					return nil, err
+				}
+			}
+			path, capabilities := resource(request)
+			if !acl.Allowed(path, capabilities...) {
+				return nil, policy.ErrPermissionDenied
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// ScopeMiddleware returns an endpoint middleware that refuses requests with
+// policy.ErrPermissionDenied unless the caller was granted the scope. Scopes
+// only restrict the callers authenticated with a JWT, either an OAuth2 access
+// token or an external JWT; service tokens and client certificates are
+// authorized by their policies alone.
+func ScopeMiddleware(scope string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			id, ok := identity.FromContext(ctx)
+			if !ok {
+				return nil, policy.ErrPermissionDenied
+			}
+			if (id.Method == "oauth" || id.Method == "jwt") && !contains(id.Scopes, scope) {
+				return nil, fmt.Errorf("%w: missing scope %s", policy.ErrPermissionDenied, scope)
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// unverifiedIssuer returns the iss claim of a JWT without verifying it, only
+// to pick the verifier of the token.
+func unverifiedIssuer(raw string) string {
+	var claims stdjwt.RegisteredClaims
+	if _, _, err := stdjwt.NewParser().ParseUnverified(raw, &claims); err != nil {
+		return ""
+	}
+	return claims.Issuer
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
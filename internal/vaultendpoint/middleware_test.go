@@ -0,0 +1,185 @@
+package vaultendpoint_test
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/discard"
+
+	"github.com/williamlsh/vault/internal/denylist"
+	"github.com/williamlsh/vault/internal/identity"
+	"github.com/williamlsh/vault/internal/lease"
+	"github.com/williamlsh/vault/internal/mock"
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/token"
+	"github.com/williamlsh/vault/internal/vaultendpoint"
+)
+
+type authFixture struct {
+	tokens   *token.Store
+	policies *policy.Store
+	denylist *denylist.List
+	auth     *vaultendpoint.Authorizer
+}
+
+func newAuthFixture(t *testing.T) authFixture {
+	t.Helper()
+	storage := mock.NewStorage()
+	leases := lease.NewManager(log.NewNopLogger(), mock.NewLeaseStorage(), lease.Metrics{
+		Issued:  discard.NewCounter(),
+		Renewed: discard.NewCounter(),
+		Revoked: discard.NewCounter(),
+		Active:  discard.NewGauge(),
+	}, time.Hour, 2*time.Hour)
+	f := authFixture{
+		tokens:   token.NewStore(mock.NewTokenStorage(), leases),
+		policies: policy.NewStore(storage),
+		denylist: denylist.New(storage, leases),
+	}
+	f.auth = vaultendpoint.NewAuthorizer(f.tokens, f.policies, nil, nil, f.denylist, nil)
+
+	app, err := policy.Parse("app", []byte(`{"path": {"hash": {"capabilities": ["hash"]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.policies.SetPolicy(context.Background(), app); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// withToken returns a context carrying the token like the transports parse
+// it.
+func withToken(tok string) context.Context {
+	return context.WithValue(context.Background(), jwt.JWTContextKey, tok)
+}
+
+func nopEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	return struct{}{}, nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	f := newAuthFixture(t)
+	ctx := context.Background()
+
+	if _, err := f.auth.Authenticate(ctx); err != token.ErrMissingToken {
+		t.Errorf("no token: want %v, have %v", token.ErrMissingToken, err)
+	}
+	if _, err := f.auth.Authenticate(withToken(token.Prefix + "unknown")); err != token.ErrInvalidToken {
+		t.Errorf("unknown token: want %v, have %v", token.ErrInvalidToken, err)
+	}
+
+	tok, err := f.tokens.Create(ctx, nil, token.CreateOptions{Policies: []string{"app"}, DisplayName: "billing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authCtx, err := f.auth.Authenticate(withToken(tok.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, ok := identity.FromContext(authCtx)
+	if !ok {
+		t.Fatal("no identity in the context")
+	}
+	if id.Method != "token" || id.Subject != "billing" || !id.ExpireTime.Equal(tok.ExpireTime) {
+		t.Errorf("token identity: have %+v", id)
+	}
+
+	// Requests without a token are authenticated by their client certificate.
+	if err := f.policies.SetSubjectPolicies(ctx, policy.MethodCert, "billing", []string{"app"}); err != nil {
+		t.Fatal(err)
+	}
+	certCtx := identity.WithCertificate(ctx, &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}})
+	if authCtx, err = f.auth.Authenticate(certCtx); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ = identity.FromContext(authCtx); id.Method != "cert" || id.Certificate != "billing" || fmt.Sprint(id.Policies) != "[default app]" {
+		t.Errorf("certificate identity: have %+v", id)
+	}
+}
+
+func TestAuthorizationMiddleware(t *testing.T) {
+	f := newAuthFixture(t)
+	ctx := context.Background()
+	e := vaultendpoint.AuthorizationMiddleware(f.policies, vaultendpoint.At("hash", policy.Hash))(nopEndpoint)
+
+	if _, err := e(ctx, nil); err != policy.ErrPermissionDenied {
+		t.Errorf("no identity: want %v, have %v", policy.ErrPermissionDenied, err)
+	}
+	for _, tc := range []struct {
+		policies []string
+		want     error
+	}{
+		{[]string{"app"}, nil},
+		{[]string{"default"}, policy.ErrPermissionDenied},
+		{[]string{policy.RootPolicy}, nil},
+	} {
+		idCtx := identity.NewContext(ctx, identity.Identity{Method: "token", Policies: tc.policies})
+		if _, err := e(idCtx, nil); err != tc.want {
+			t.Errorf("%v: want %v, have %v", tc.policies, tc.want, err)
+		}
+	}
+}
+
+func TestScopeMiddleware(t *testing.T) {
+	ctx := context.Background()
+	e := vaultendpoint.ScopeMiddleware(vaultendpoint.ScopeHash)(nopEndpoint)
+
+	for _, tc := range []struct {
+		id   identity.Identity
+		want error
+	}{
+		{identity.Identity{Method: "token"}, nil},
+		{identity.Identity{Method: "cert"}, nil},
+		{identity.Identity{Method: "oauth", Scopes: []string{vaultendpoint.ScopeHash}}, nil},
+		{identity.Identity{Method: "oauth", Scopes: []string{vaultendpoint.ScopeValidate}}, policy.ErrPermissionDenied},
+		{identity.Identity{Method: "jwt"}, policy.ErrPermissionDenied},
+	} {
+		if _, err := e(identity.NewContext(ctx, tc.id), nil); !errors.Is(err, tc.want) {
+			t.Errorf("%s %v: want %v, have %v", tc.id.Method, tc.id.Scopes, tc.want, err)
+		}
+	}
+}
+
+func TestRecheck(t *testing.T) {
+	f := newAuthFixture(t)
+	ctx := context.Background()
+
+	tok, err := f.tokens.Create(ctx, nil, token.CreateOptions{Policies: []string{"app"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authCtx, err := f.auth.Authenticate(withToken(tok.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.auth.Recheck(authCtx); err != nil {
+		t.Fatalf("valid token: %v", err)
+	}
+	if err := f.tokens.Revoke(ctx, tok); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.auth.Recheck(authCtx); err != token.ErrInvalidToken {
+		t.Errorf("revoked token: want %v, have %v", token.ErrInvalidToken, err)
+	}
+
+	// Denying the subject of a client certificate ends its requests.
+	subject := "spiffe://example.org/billing"
+	certCtx := identity.NewContext(ctx, identity.Identity{Subject: subject, Method: "cert"})
+	if err := f.auth.Recheck(certCtx); err != nil {
+		t.Fatalf("allowed subject: %v", err)
+	}
+	if err := f.denylist.Deny(ctx, denylist.Entry{Kind: denylist.KindSubject, Value: subject}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.auth.Recheck(certCtx); !errors.Is(err, denylist.ErrDenied) {
+		t.Errorf("denied subject: want %v, have %v", denylist.ErrDenied, err)
+	}
+}
@@ -0,0 +1,281 @@
+package vaultendpoint
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/vaultservice"
+)
+
+// OAuthSet collects all of the endpoints of the OAuth2 authorization server.
+type OAuthSet struct {
+	ReadClientEndpoint   endpoint.Endpoint
+	WriteClientEndpoint  endpoint.Endpoint
+	DeleteClientEndpoint endpoint.Endpoint
+	ListClientsEndpoint  endpoint.Endpoint
+	TokenEndpoint        endpoint.Endpoint
+	IntrospectEndpoint   endpoint.Endpoint
+	RevokeEndpoint       endpoint.Endpoint
+}
+
+// NewOAuthSet returns an OAuthSet that wraps the provided OAuth2 service.
+// Clients are managed on the oauth/clients/<client_id> policy paths. The
+// token, introspection and revocation endpoints are reachable without a
+// token, the client credentials authenticating them.
+func NewOAuthSet(svc vaultservice.OAuthService, auth *Authorizer, duration metrics.Histogram, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) OAuthSet {
+	wrap := func(name string, e endpoint.Endpoint) endpoint.Endpoint {
+		e = opentracing.TraceServer(otTracer, name)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, name)(e)
+		e = LoggingMiddleware(log.With(logger, "method", name))(e)
+		e = InstrumentingMiddleware(duration.With("method", name))(e)
+		return e
+	}
+	return OAuthSet{
+		ReadClientEndpoint:   wrap("ReadClient", authorize(auth, clientResource(policy.Read))(MakeReadClientEndpoint(svc))),
+		WriteClientEndpoint:  wrap("WriteClient", authorize(auth, clientResource(policy.Create, policy.Update))(MakeWriteClientEndpoint(svc))),
+		DeleteClientEndpoint: wrap("DeleteClient", authorize(auth, clientResource(policy.Delete))(MakeDeleteClientEndpoint(svc))),
+		ListClientsEndpoint:  wrap("ListClients", authorize(auth, At("oauth/clients", policy.List))(MakeListClientsEndpoint(svc))),
+		TokenEndpoint:        wrap("Token", MakeTokenEndpoint(svc)),
+		IntrospectEndpoint:   wrap("Introspect", MakeIntrospectEndpoint(svc)),
+		RevokeEndpoint:       wrap("Revoke", MakeRevokeEndpoint(svc)),
+	}
+}
+
+func clientResource(capabilities ...string) Resource {
+	return func(request interface{}) (string, []string) {
+		var id string
+		switch req := request.(type) {
+		case ClientRequest:
+			id = req.ClientID
+		case WriteClientRequest:
+			id = req.ClientID
+		}
+		return "oauth/clients/" + id, capabilities
+	}
+}
+
+// ReadClient implements vaultservice.OAuthService interface, so OAuthSet may
+// be used as a service. This is primarily useful in the context of a client
+// library.
+func (s OAuthSet) ReadClient(ctx context.Context, id string) (vaultservice.OAuthClient, error) {
+	resp, err := s.ReadClientEndpoint(ctx, ClientRequest{ClientID: id})
+	if err != nil {
+		return vaultservice.OAuthClient{}, err
+	}
+	response := resp.(ClientResponse)
+	return response.OAuthClient, response.Err
+}
+
+// WriteClient implements vaultservice.OAuthService interface.
+func (s OAuthSet) WriteClient(ctx context.Context, id string, client vaultservice.OAuthClient) (string, error) {
+	resp, err := s.WriteClientEndpoint(ctx, WriteClientRequest{ClientID: id, OAuthClient: client})
+	if err != nil {
+		return "", err
+	}
+	response := resp.(ClientResponse)
+	return response.ClientSecret, response.Err
+}
+
+// DeleteClient implements vaultservice.OAuthService interface.
+func (s OAuthSet) DeleteClient(ctx context.Context, id string) error {
+	resp, err := s.DeleteClientEndpoint(ctx, ClientRequest{ClientID: id})
+	if err != nil {
+		return err
+	}
+	return resp.(ClientResponse).Err
+}
+
+// ListClients implements vaultservice.OAuthService interface.
+func (s OAuthSet) ListClients(ctx context.Context) ([]string, error) {
+	resp, err := s.ListClientsEndpoint(ctx, ListClientsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(ListClientsResponse)
+	return response.Clients, response.Err
+}
+
+// Token implements vaultservice.OAuthService interface.
+func (s OAuthSet) Token(ctx context.Context, req vaultservice.AccessTokenRequest) (vaultservice.AccessToken, error) {
+	resp, err := s.TokenEndpoint(ctx, AccessTokenRequest{AccessTokenRequest: req})
+	if err != nil {
+		return vaultservice.AccessToken{}, err
+	}
+	response := resp.(AccessTokenResponse)
+	return response.AccessToken, response.Err
+}
+
+// Introspect implements vaultservice.OAuthService interface.
+func (s OAuthSet) Introspect(ctx context.Context, creds vaultservice.ClientCredentials, accessToken string) (vaultservice.Introspection, error) {
+	resp, err := s.IntrospectEndpoint(ctx, IntrospectRequest{ClientCredentials: creds, Token: accessToken})
+	if err != nil {
+		return vaultservice.Introspection{}, err
+	}
+	response := resp.(IntrospectResponse)
+	return response.Introspection, response.Err
+}
+
+// Revoke implements vaultservice.OAuthService interface.
+func (s OAuthSet) Revoke(ctx context.Context, creds vaultservice.ClientCredentials, accessToken string) error {
+	resp, err := s.RevokeEndpoint(ctx, OAuthRevokeRequest{ClientCredentials: creds, Token: accessToken})
+	if err != nil {
+		return err
+	}
+	return resp.(OAuthRevokeResponse).Err
+}
+
+// MakeReadClientEndpoint constructs a ReadClient endpoint wrapping the
+// service.
+func MakeReadClientEndpoint(s vaultservice.OAuthService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ClientRequest)
+		client, err := s.ReadClient(ctx, req.ClientID)
+		return ClientResponse{ClientID: req.ClientID, OAuthClient: client, Err: err}, nil
+	}
+}
+
+// MakeWriteClientEndpoint constructs a WriteClient endpoint wrapping the
+// service.
+func MakeWriteClientEndpoint(s vaultservice.OAuthService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(WriteClientRequest)
+		secret, err := s.WriteClient(ctx, req.ClientID, req.OAuthClient)
+		return ClientResponse{ClientID: req.ClientID, ClientSecret: secret, Err: err}, nil
+	}
+}
+
+// MakeDeleteClientEndpoint constructs a DeleteClient endpoint wrapping the
+// service.
+func MakeDeleteClientEndpoint(s vaultservice.OAuthService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ClientRequest)
+		err := s.DeleteClient(ctx, req.ClientID)
+		return ClientResponse{ClientID: req.ClientID, Err: err}, nil
+	}
+}
+
+// MakeListClientsEndpoint constructs a ListClients endpoint wrapping the
+// service.
+func MakeListClientsEndpoint(s vaultservice.OAuthService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		ids, err := s.ListClients(ctx)
+		return ListClientsResponse{Clients: ids, Err: err}, nil
+	}
+}
+
+// MakeTokenEndpoint constructs a Token endpoint wrapping the service.
+func MakeTokenEndpoint(s vaultservice.OAuthService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(AccessTokenRequest)
+		t, err := s.Token(ctx, req.AccessTokenRequest)
+		return AccessTokenResponse{AccessToken: t, Err: err}, nil
+	}
+}
+
+// MakeIntrospectEndpoint constructs an Introspect endpoint wrapping the
+// service.
+func MakeIntrospectEndpoint(s vaultservice.OAuthService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(IntrospectRequest)
+		i, err := s.Introspect(ctx, req.ClientCredentials, req.Token)
+		return IntrospectResponse{Introspection: i, Err: err}, nil
+	}
+}
+
+// MakeRevokeEndpoint constructs a Revoke endpoint wrapping the service.
+func MakeRevokeEndpoint(s vaultservice.OAuthService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(OAuthRevokeRequest)
+		err := s.Revoke(ctx, req.ClientCredentials, req.Token)
+		return OAuthRevokeResponse{Err: err}, nil
+	}
+}
+
+// Compile time assertions for the response types implementing endpoint.Failer.
+var (
+	_ endpoint.Failer = ClientResponse{}
+	_ endpoint.Failer = ListClientsResponse{}
+	_ endpoint.Failer = AccessTokenResponse{}
+	_ endpoint.Failer = IntrospectResponse{}
+	_ endpoint.Failer = OAuthRevokeResponse{}
+)
+
+type ClientRequest struct {
+	ClientID string `json:"-"`
+}
+
+type WriteClientRequest struct {
+	ClientID string `json:"-"`
+	vaultservice.OAuthClient
+}
+
+type ClientResponse struct {
+	ClientID string `json:"client_id"`
+	// ClientSecret is only set when a client is registered.
+	ClientSecret string `json:"client_secret,omitempty"`
+	vaultservice.OAuthClient
+	Err error `json:"-"`
+}
+
+func (r ClientResponse) Failed() error {
+	return r.Err
+}
+
+type ListClientsRequest struct{}
+
+type ListClientsResponse struct {
+	Clients []string `json:"clients"`
+	Err     error    `json:"-"`
+}
+
+func (r ListClientsResponse) Failed() error {
+	return r.Err
+}
+
+type AccessTokenRequest struct {
+	vaultservice.AccessTokenRequest
+}
+
+type AccessTokenResponse struct {
+	vaultservice.AccessToken
+	Err error `json:"-"`
+}
+
+func (r AccessTokenResponse) Failed() error {
+	return r.Err
+}
+
+type IntrospectRequest struct {
+	vaultservice.ClientCredentials
+	Token string `json:"token"`
+}
+
+type IntrospectResponse struct {
+	vaultservice.Introspection
+	Err error `json:"-"`
+}
+
+func (r IntrospectResponse) Failed() error {
+	return r.Err
+}
+
+type OAuthRevokeRequest struct {
+	vaultservice.ClientCredentials
+	Token string `json:"token"`
+}
+
+type OAuthRevokeResponse struct {
+	Err error `json:"-"`
+}
+
+func (r OAuthRevokeResponse) Failed() error {
+	return r.Err
+}
@@ -0,0 +1,434 @@
+package vaultendpoint
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/vaultservice"
+)
+
+// PKISet collects all of the endpoints of the PKI secrets engine.
+type PKISet struct {
+	GenerateCAEndpoint       endpoint.Endpoint
+	ImportCAEndpoint         endpoint.Endpoint
+	ReadCAEndpoint           endpoint.Endpoint
+	WriteRoleEndpoint        endpoint.Endpoint
+	ReadRoleEndpoint         endpoint.Endpoint
+	DeleteRoleEndpoint       endpoint.Endpoint
+	ListRolesEndpoint        endpoint.Endpoint
+	IssueEndpoint            endpoint.Endpoint
+	SignEndpoint             endpoint.Endpoint
+	RevokeEndpoint           endpoint.Endpoint
+	ReadCertificateEndpoint  endpoint.Endpoint
+	ListCertificatesEndpoint endpoint.Endpoint
+	ReadCRLEndpoint          endpoint.Endpoint
+}
+
+// NewPKISet returns a PKISet that wraps the provided PKI service. The CA is
+// replaced with sudo on pki/ca, roles are managed on the pki/roles/<name>
+// policy paths, certificates are issued and signed with update on
+// pki/issue/<role> and pki/sign/<role>, revoked with update on pki/revoke and
+// read on the pki/certs/<serial> paths. The CA certificate and the CRL are
+// public, for the verifiers of the certificates.
+func NewPKISet(svc vaultservice.PKIService, auth *Authorizer, duration metrics.Histogram, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) PKISet {
+	wrap := func(name string, e endpoint.Endpoint) endpoint.Endpoint {
+		e = opentracing.TraceServer(otTracer, name)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, name)(e)
+		e = LoggingMiddleware(log.With(logger, "method", name))(e)
+		e = InstrumentingMiddleware(duration.With("method", name))(e)
+		return e
+	}
+	return PKISet{
+		GenerateCAEndpoint:       wrap("PKIGenerateCA", authorize(auth, At("pki/ca", policy.Sudo))(MakePKIGenerateCAEndpoint(svc))),
+		ImportCAEndpoint:         wrap("PKIImportCA", authorize(auth, At("pki/ca", policy.Sudo))(MakePKIImportCAEndpoint(svc))),
+		ReadCAEndpoint:           wrap("PKIReadCA", MakePKIReadCAEndpoint(svc)),
+		WriteRoleEndpoint:        wrap("PKIWriteRole", authorize(auth, pkiResource("pki/roles/", policy.Create, policy.Update))(MakePKIWriteRoleEndpoint(svc))),
+		ReadRoleEndpoint:         wrap("PKIReadRole", authorize(auth, pkiResource("pki/roles/", policy.Read))(MakePKIReadRoleEndpoint(svc))),
+		DeleteRoleEndpoint:       wrap("PKIDeleteRole", authorize(auth, pkiResource("pki/roles/", policy.Delete))(MakePKIDeleteRoleEndpoint(svc))),
+		ListRolesEndpoint:        wrap("PKIListRoles", authorize(auth, At("pki/roles", policy.List))(MakePKIListRolesEndpoint(svc))),
+		IssueEndpoint:            wrap("PKIIssue", authorize(auth, pkiResource("pki/issue/", policy.Update))(MakePKIIssueEndpoint(svc))),
+		SignEndpoint:             wrap("PKISign", authorize(auth, pkiResource("pki/sign/", policy.Update))(MakePKISignEndpoint(svc))),
+		RevokeEndpoint:           wrap("PKIRevoke", authorize(auth, At("pki/revoke", policy.Update))(MakePKIRevokeEndpoint(svc))),
+		ReadCertificateEndpoint:  wrap("PKIReadCertificate", authorize(auth, pkiResource("pki/certs/", policy.Read))(MakePKIReadCertificateEndpoint(svc))),
+		ListCertificatesEndpoint: wrap("PKIListCertificates", authorize(auth, At("pki/certs", policy.List))(MakePKIListCertificatesEndpoint(svc))),
+		ReadCRLEndpoint:          wrap("PKIReadCRL", MakePKIReadCRLEndpoint(svc)),
+	}
+}
+
+// pkiResource returns the Resource of the role or serial number of the
+// request under the prefix.
+func pkiResource(prefix string, capabilities ...string) Resource {
+	return func(request interface{}) (string, []string) {
+		var name string
+		switch req := request.(type) {
+		case PKIRoleRequest:
+			name = req.Name
+		case PKIWriteRoleRequest:
+			name = req.Name
+		case PKIIssueRequest:
+			name = req.Role
+		case PKISignRequest:
+			name = req.Role
+		case PKICertificateRequest:
+			name = req.SerialNumber
+		}
+		return prefix + name, capabilities
+	}
+}
+
+// GenerateCA implements vaultservice.PKIService interface, so PKISet may be
+// used as a service. This is primarily useful in the context of a client
+// library.
+func (s PKISet) GenerateCA(ctx context.Context, opts vaultservice.PKIGenerateOptions) (vaultservice.PKICA, error) {
+	resp, err := s.GenerateCAEndpoint(ctx, PKIGenerateCARequest(opts))
+	if err != nil {
+		return vaultservice.PKICA{}, err
+	}
+	response := resp.(PKICAResponse)
+	return response.PKICA, response.Err
+}
+
+// ImportCA implements vaultservice.PKIService interface.
+func (s PKISet) ImportCA(ctx context.Context, bundle string) (vaultservice.PKICA, error) {
+	resp, err := s.ImportCAEndpoint(ctx, PKIImportCARequest{PEMBundle: bundle})
+	if err != nil {
+		return vaultservice.PKICA{}, err
+	}
+	response := resp.(PKICAResponse)
+	return response.PKICA, response.Err
+}
+
+// ReadCA implements vaultservice.PKIService interface.
+func (s PKISet) ReadCA(ctx context.Context) (vaultservice.PKICA, error) {
+	resp, err := s.ReadCAEndpoint(ctx, PKIReadCARequest{})
+	if err != nil {
+		return vaultservice.PKICA{}, err
+	}
+	response := resp.(PKICAResponse)
+	return response.PKICA, response.Err
+}
+
+// WriteRole implements vaultservice.PKIService interface.
+func (s PKISet) WriteRole(ctx context.Context, name string, role vaultservice.PKIRole) error {
+	resp, err := s.WriteRoleEndpoint(ctx, PKIWriteRoleRequest{Name: name, PKIRole: role})
+	if err != nil {
+		return err
+	}
+	return resp.(PKIRoleResponse).Err
+}
+
+// ReadRole implements vaultservice.PKIService interface.
+func (s PKISet) ReadRole(ctx context.Context, name string) (vaultservice.PKIRole, error) {
+	resp, err := s.ReadRoleEndpoint(ctx, PKIRoleRequest{Name: name})
+	if err != nil {
+		return vaultservice.PKIRole{}, err
+	}
+	response := resp.(PKIRoleResponse)
+	return response.PKIRole, response.Err
+}
+
+// DeleteRole implements vaultservice.PKIService interface.
+func (s PKISet) DeleteRole(ctx context.Context, name string) error {
+	resp, err := s.DeleteRoleEndpoint(ctx, PKIRoleRequest{Name: name})
+	if err != nil {
+		return err
+	}
+	return resp.(PKIRoleResponse).Err
+}
+
+// ListRoles implements vaultservice.PKIService interface.
+func (s PKISet) ListRoles(ctx context.Context) ([]string, error) {
+	resp, err := s.ListRolesEndpoint(ctx, PKIListRolesRequest{})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(ListRolesResponse)
+	return response.Roles, response.Err
+}
+
+// Issue implements vaultservice.PKIService interface.
+func (s PKISet) Issue(ctx context.Context, role string, req vaultservice.PKIIssueRequest) (vaultservice.PKICertificate, error) {
+	resp, err := s.IssueEndpoint(ctx, PKIIssueRequest{Role: role, PKIIssueRequest: req})
+	if err != nil {
+		return vaultservice.PKICertificate{}, err
+	}
+	response := resp.(PKICertificateResponse)
+	return response.PKICertificate, response.Err
+}
+
+// Sign implements vaultservice.PKIService interface.
+func (s PKISet) Sign(ctx context.Context, role string, req vaultservice.PKISignRequest) (vaultservice.PKICertificate, error) {
+	resp, err := s.SignEndpoint(ctx, PKISignRequest{Role: role, PKISignRequest: req})
+	if err != nil {
+		return vaultservice.PKICertificate{}, err
+	}
+	response := resp.(PKICertificateResponse)
+	return response.PKICertificate, response.Err
+}
+
+// Revoke implements vaultservice.PKIService interface.
+func (s PKISet) Revoke(ctx context.Context, serial string) (vaultservice.PKICertificate, error) {
+	resp, err := s.RevokeEndpoint(ctx, PKICertificateRequest{SerialNumber: serial})
+	if err != nil {
+		return vaultservice.PKICertificate{}, err
+	}
+	response := resp.(PKICertificateResponse)
+	return response.PKICertificate, response.Err
+}
+
+// ReadCertificate implements vaultservice.PKIService interface.
+func (s PKISet) ReadCertificate(ctx context.Context, serial string) (vaultservice.PKICertificate, error) {
+	resp, err := s.ReadCertificateEndpoint(ctx, PKICertificateRequest{SerialNumber: serial})
+	if err != nil {
+		return vaultservice.PKICertificate{}, err
+	}
+	response := resp.(PKICertificateResponse)
+	return response.PKICertificate, response.Err
+}
+
+// ListCertificates implements vaultservice.PKIService interface.
+func (s PKISet) ListCertificates(ctx context.Context) ([]string, error) {
+	resp, err := s.ListCertificatesEndpoint(ctx, PKIListCertificatesRequest{})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(PKIListCertificatesResponse)
+	return response.SerialNumbers, response.Err
+}
+
+// ReadCRL implements vaultservice.PKIService interface.
+func (s PKISet) ReadCRL(ctx context.Context) (string, error) {
+	resp, err := s.ReadCRLEndpoint(ctx, PKIReadCRLRequest{})
+	if err != nil {
+		return "", err
+	}
+	response := resp.(PKICRLResponse)
+	return response.CRL, response.Err
+}
+
+// MakePKIGenerateCAEndpoint constructs a GenerateCA endpoint wrapping the
+// service.
+func MakePKIGenerateCAEndpoint(s vaultservice.PKIService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PKIGenerateCARequest)
+		ca, err := s.GenerateCA(ctx, vaultservice.PKIGenerateOptions(req))
+		return PKICAResponse{PKICA: ca, Err: err}, nil
+	}
+}
+
+// MakePKIImportCAEndpoint constructs an ImportCA endpoint wrapping the
+// service.
+func MakePKIImportCAEndpoint(s vaultservice.PKIService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PKIImportCARequest)
+		ca, err := s.ImportCA(ctx, req.PEMBundle)
+		return PKICAResponse{PKICA: ca, Err: err}, nil
+	}
+}
+
+// MakePKIReadCAEndpoint constructs a ReadCA endpoint wrapping the service.
+func MakePKIReadCAEndpoint(s vaultservice.PKIService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		ca, err := s.ReadCA(ctx)
+		return PKICAResponse{PKICA: ca, Err: err}, nil
+	}
+}
+
+// MakePKIWriteRoleEndpoint constructs a WriteRole endpoint wrapping the
+// service.
+func MakePKIWriteRoleEndpoint(s vaultservice.PKIService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PKIWriteRoleRequest)
+		err := s.WriteRole(ctx, req.Name, req.PKIRole)
+		return PKIRoleResponse{Name: req.Name, Err: err}, nil
+	}
+}
+
+// MakePKIReadRoleEndpoint constructs a ReadRole endpoint wrapping the
+// service.
+func MakePKIReadRoleEndpoint(s vaultservice.PKIService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PKIRoleRequest)
+		role, err := s.ReadRole(ctx, req.Name)
+		return PKIRoleResponse{Name: req.Name, PKIRole: role, Err: err}, nil
+	}
+}
+
+// MakePKIDeleteRoleEndpoint constructs a DeleteRole endpoint wrapping the
+// service.
+func MakePKIDeleteRoleEndpoint(s vaultservice.PKIService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PKIRoleRequest)
+		err := s.DeleteRole(ctx, req.Name)
+		return PKIRoleResponse{Name: req.Name, Err: err}, nil
+	}
+}
+
+// MakePKIListRolesEndpoint constructs a ListRoles endpoint wrapping the
+// service.
+func MakePKIListRolesEndpoint(s vaultservice.PKIService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		names, err := s.ListRoles(ctx)
+		return ListRolesResponse{Roles: names, Err: err}, nil
+	}
+}
+
+// MakePKIIssueEndpoint constructs an Issue endpoint wrapping the service.
+func MakePKIIssueEndpoint(s vaultservice.PKIService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PKIIssueRequest)
+		cert, err := s.Issue(ctx, req.Role, req.PKIIssueRequest)
+		return PKICertificateResponse{PKICertificate: cert, Err: err}, nil
+	}
+}
+
+// MakePKISignEndpoint constructs a Sign endpoint wrapping the service.
+func MakePKISignEndpoint(s vaultservice.PKIService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PKISignRequest)
+		cert, err := s.Sign(ctx, req.Role, req.PKISignRequest)
+		return PKICertificateResponse{PKICertificate: cert, Err: err}, nil
+	}
+}
+
+// MakePKIRevokeEndpoint constructs a Revoke endpoint wrapping the service.
+func MakePKIRevokeEndpoint(s vaultservice.PKIService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PKICertificateRequest)
+		cert, err := s.Revoke(ctx, req.SerialNumber)
+		return PKICertificateResponse{PKICertificate: cert, Err: err}, nil
+	}
+}
+
+// MakePKIReadCertificateEndpoint constructs a ReadCertificate endpoint
+// wrapping the service.
+func MakePKIReadCertificateEndpoint(s vaultservice.PKIService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PKICertificateRequest)
+		cert, err := s.ReadCertificate(ctx, req.SerialNumber)
+		return PKICertificateResponse{PKICertificate: cert, Err: err}, nil
+	}
+}
+
+// MakePKIListCertificatesEndpoint constructs a ListCertificates endpoint
+// wrapping the service.
+func MakePKIListCertificatesEndpoint(s vaultservice.PKIService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		serials, err := s.ListCertificates(ctx)
+		return PKIListCertificatesResponse{SerialNumbers: serials, Err: err}, nil
+	}
+}
+
+// MakePKIReadCRLEndpoint constructs a ReadCRL endpoint wrapping the service.
+func MakePKIReadCRLEndpoint(s vaultservice.PKIService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		crl, err := s.ReadCRL(ctx)
+		return PKICRLResponse{CRL: crl, Err: err}, nil
+	}
+}
+
+// Compile time assertions for the response types implementing endpoint.Failer.
+var (
+	_ endpoint.Failer = PKICAResponse{}
+	_ endpoint.Failer = PKIRoleResponse{}
+	_ endpoint.Failer = PKICertificateResponse{}
+	_ endpoint.Failer = PKIListCertificatesResponse{}
+	_ endpoint.Failer = PKICRLResponse{}
+)
+
+type PKIGenerateCARequest struct {
+	Intermediate bool   `json:"-"`
+	CommonName   string `json:"common_name"`
+	TTL          string `json:"ttl,omitempty"`
+	KeyType      string `json:"key_type,omitempty"`
+	KeyBits      int    `json:"key_bits,omitempty"`
+}
+
+type PKIImportCARequest struct {
+	PEMBundle string `json:"pem_bundle"`
+}
+
+type PKIReadCARequest struct{}
+
+type PKICAResponse struct {
+	vaultservice.PKICA
+	Err error `json:"-"`
+}
+
+func (r PKICAResponse) Failed() error {
+	return r.Err
+}
+
+type PKIRoleRequest struct {
+	Name string `json:"-"`
+}
+
+type PKIWriteRoleRequest struct {
+	Name string `json:"-"`
+	vaultservice.PKIRole
+}
+
+type PKIRoleResponse struct {
+	Name string `json:"name"`
+	vaultservice.PKIRole
+	Err error `json:"-"`
+}
+
+func (r PKIRoleResponse) Failed() error {
+	return r.Err
+}
+
+type PKIListRolesRequest struct{}
+
+type PKIIssueRequest struct {
+	Role string `json:"-"`
+	vaultservice.PKIIssueRequest
+}
+
+type PKISignRequest struct {
+	Role string `json:"-"`
+	vaultservice.PKISignRequest
+}
+
+type PKICertificateRequest struct {
+	SerialNumber string `json:"serial_number"`
+}
+
+type PKICertificateResponse struct {
+	vaultservice.PKICertificate
+	Err error `json:"-"`
+}
+
+func (r PKICertificateResponse) Failed() error {
+	return r.Err
+}
+
+type PKIListCertificatesRequest struct{}
+
+type PKIListCertificatesResponse struct {
+	SerialNumbers []string `json:"serial_numbers"`
+	Err           error    `json:"-"`
+}
+
+func (r PKIListCertificatesResponse) Failed() error {
+	return r.Err
+}
+
+type PKIReadCRLRequest struct{}
+
+type PKICRLResponse struct {
+	CRL string `json:"crl"`
+	Err error  `json:"-"`
+}
+
+func (r PKICRLResponse) Failed() error {
+	return r.Err
+}
@@ -0,0 +1,248 @@
+package vaultendpoint
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/vaultservice"
+)
+
+// PolicySet collects all of the endpoints that manage the ACL policies.
+type PolicySet struct {
+	ReadPolicyEndpoint   endpoint.Endpoint
+	WritePolicyEndpoint  endpoint.Endpoint
+	DeletePolicyEndpoint endpoint.Endpoint
+	ListPoliciesEndpoint endpoint.Endpoint
+	ReadSubjectEndpoint  endpoint.Endpoint
+	WriteSubjectEndpoint endpoint.Endpoint
+}
+
+// NewPolicySet returns a PolicySet that wraps the provided policy service.
+// Requests are authorized on the sys/policy/<name> and sys/subject/<subject>
+// policy paths.
+func NewPolicySet(svc vaultservice.PolicyService, auth *Authorizer, duration metrics.Histogram, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) PolicySet {
+	wrap := func(name string, resource Resource, e endpoint.Endpoint) endpoint.Endpoint {
+		e = authorize(auth, resource)(e)
+		e = opentracing.TraceServer(otTracer, name)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, name)(e)
+		e = LoggingMiddleware(log.With(logger, "method", name))(e)
+		e = InstrumentingMiddleware(duration.With("method", name))(e)
+		return e
+	}
+	return PolicySet{
+		ReadPolicyEndpoint:   wrap("ReadPolicy", policyResource(policy.Read), MakeReadPolicyEndpoint(svc)),
+		WritePolicyEndpoint:  wrap("WritePolicy", policyResource(policy.Create, policy.Update), MakeWritePolicyEndpoint(svc)),
+		DeletePolicyEndpoint: wrap("DeletePolicy", policyResource(policy.Delete), MakeDeletePolicyEndpoint(svc)),
+		ListPoliciesEndpoint: wrap("ListPolicies", At("sys/policy", policy.List), MakeListPoliciesEndpoint(svc)),
+		ReadSubjectEndpoint:  wrap("ReadSubject", subjectResource(policy.Read), MakeReadSubjectEndpoint(svc)),
+		WriteSubjectEndpoint: wrap("WriteSubject", subjectResource(policy.Create, policy.Update), MakeWriteSubjectEndpoint(svc)),
+	}
+}
+
+func policyResource(capabilities ...string) Resource {
+	return func(request interface{}) (string, []string) {
+		var name string
+		switch req := request.(type) {
+		case PolicyRequest:
+			name = req.Name
+		case WritePolicyRequest:
+			name = req.Name
+		}
+		return "sys/policy/" + name, capabilities
+	}
+}
+
+func subjectResource(capabilities ...string) Resource {
+	return func(request interface{}) (string, []string) {
+		var subject string
+		switch req := request.(type) {
+		case SubjectRequest:
+			subject = req.Subject
+		case WriteSubjectRequest:
+			subject = req.Subject
+		}
+		return "sys/subject/" + subject, capabilities
+	}
+}
+
+// ReadPolicy implements vaultservice.PolicyService interface, so PolicySet
+// may be used as a service. This is primarily useful in the context of a
+// client library.
+func (s PolicySet) ReadPolicy(ctx context.Context, name string) (string, error) {
+	resp, err := s.ReadPolicyEndpoint(ctx, PolicyRequest{Name: name})
+	if err != nil {
+		return "", err
+	}
+	response := resp.(PolicyResponse)
+	return response.Policy, response.Err
+}
+
+// WritePolicy implements vaultservice.PolicyService interface.
+func (s PolicySet) WritePolicy(ctx context.Context, name, rules string) error {
+	resp, err := s.WritePolicyEndpoint(ctx, WritePolicyRequest{Name: name, Policy: rules})
+	if err != nil {
+		return err
+	}
+	return resp.(PolicyResponse).Err
+}
+
+// DeletePolicy implements vaultservice.PolicyService interface.
+func (s PolicySet) DeletePolicy(ctx context.Context, name string) error {
+	resp, err := s.DeletePolicyEndpoint(ctx, PolicyRequest{Name: name})
+	if err != nil {
+		return err
+	}
+	return resp.(PolicyResponse).Err
+}
+
+// ListPolicies implements vaultservice.PolicyService interface.
+func (s PolicySet) ListPolicies(ctx context.Context) ([]string, error) {
+	resp, err := s.ListPoliciesEndpoint(ctx, ListPoliciesRequest{})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(ListPoliciesResponse)
+	return response.Policies, response.Err
+}
+
+// ReadSubject implements vaultservice.PolicyService interface.
+func (s PolicySet) ReadSubject(ctx context.Context, subject string) ([]string, error) {
+	resp, err := s.ReadSubjectEndpoint(ctx, SubjectRequest{Subject: subject})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(SubjectResponse)
+	return response.Policies, response.Err
+}
+
+// WriteSubject implements vaultservice.PolicyService interface.
+func (s PolicySet) WriteSubject(ctx context.Context, subject string, policies []string) error {
+	resp, err := s.WriteSubjectEndpoint(ctx, WriteSubjectRequest{Subject: subject, Policies: policies})
+	if err != nil {
+		return err
+	}
+	return resp.(SubjectResponse).Err
+}
+
+// MakeReadPolicyEndpoint constructs a ReadPolicy endpoint wrapping the
+// service.
+func MakeReadPolicyEndpoint(s vaultservice.PolicyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PolicyRequest)
+		rules, err := s.ReadPolicy(ctx, req.Name)
+		return PolicyResponse{Name: req.Name, Policy: rules, Err: err}, nil
+	}
+}
+
+// MakeWritePolicyEndpoint constructs a WritePolicy endpoint wrapping the
+// service.
+func MakeWritePolicyEndpoint(s vaultservice.PolicyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(WritePolicyRequest)
+		err := s.WritePolicy(ctx, req.Name, req.Policy)
+		return PolicyResponse{Name: req.Name, Err: err}, nil
+	}
+}
+
+// MakeDeletePolicyEndpoint constructs a DeletePolicy endpoint wrapping the
+// service.
+func MakeDeletePolicyEndpoint(s vaultservice.PolicyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PolicyRequest)
+		err := s.DeletePolicy(ctx, req.Name)
+		return PolicyResponse{Name: req.Name, Err: err}, nil
+	}
+}
+
+// MakeListPoliciesEndpoint constructs a ListPolicies endpoint wrapping the
+// service.
+func MakeListPoliciesEndpoint(s vaultservice.PolicyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		names, err := s.ListPolicies(ctx)
+		return ListPoliciesResponse{Policies: names, Err: err}, nil
+	}
+}
+
+// MakeReadSubjectEndpoint constructs a ReadSubject endpoint wrapping the
+// service.
+func MakeReadSubjectEndpoint(s vaultservice.PolicyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SubjectRequest)
+		names, err := s.ReadSubject(ctx, req.Subject)
+		return SubjectResponse{Subject: req.Subject, Policies: names, Err: err}, nil
+	}
+}
+
+// MakeWriteSubjectEndpoint constructs a WriteSubject endpoint wrapping the
+// service.
+func MakeWriteSubjectEndpoint(s vaultservice.PolicyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(WriteSubjectRequest)
+		err := s.WriteSubject(ctx, req.Subject, req.Policies)
+		return SubjectResponse{Subject: req.Subject, Policies: req.Policies, Err: err}, nil
+	}
+}
+
+// Compile time assertions for the response types implementing endpoint.Failer.
+var (
+	_ endpoint.Failer = PolicyResponse{}
+	_ endpoint.Failer = ListPoliciesResponse{}
+	_ endpoint.Failer = SubjectResponse{}
+)
+
+type PolicyRequest struct {
+	Name string `json:"-"`
+}
+
+type WritePolicyRequest struct {
+	Name   string `json:"-"`
+	Policy string `json:"policy"`
+}
+
+type PolicyResponse struct {
+	Name   string `json:"name"`
+	Policy string `json:"policy,omitempty"`
+	Err    error  `json:"-"`
+}
+
+func (r PolicyResponse) Failed() error {
+	return r.Err
+}
+
+type ListPoliciesRequest struct{}
+
+type ListPoliciesResponse struct {
+	Policies []string `json:"policies"`
+	Err      error    `json:"-"`
+}
+
+func (r ListPoliciesResponse) Failed() error {
+	return r.Err
+}
+
+type SubjectRequest struct {
+	Subject string `json:"-"`
+}
+
+type WriteSubjectRequest struct {
+	Subject  string   `json:"-"`
+	Policies []string `json:"policies"`
+}
+
+type SubjectResponse struct {
+	Subject  string   `json:"subject"`
+	Policies []string `json:"policies"`
+	Err      error    `json:"-"`
+}
+
+func (r SubjectResponse) Failed() error {
+	return r.Err
+}
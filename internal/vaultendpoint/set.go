@@ -5,45 +5,49 @@ import (
 	"time"
 
 	"github.com/go-kit/kit/auth/jwt"
-	"github.com/go-kit/kit/circuitbreaker"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics"
 	"github.com/go-kit/kit/ratelimit"
 	"github.com/go-kit/kit/tracing/opentracing"
 	"github.com/go-kit/kit/tracing/zipkin"
-	stdjwt "github.com/golang-jwt/jwt/v4"
 	stdopentracing "github.com/opentracing/opentracing-go"
 	stdzipkin "github.com/openzipkin/zipkin-go"
 	"github.com/sony/gobreaker"
 	"golang.org/x/time/rate"
 
+	"github.com/williamlsh/vault/internal/denylist"
+	"github.com/williamlsh/vault/internal/identity"
+	"github.com/williamlsh/vault/internal/jwks"
+	"github.com/williamlsh/vault/internal/oauth"
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/replay"
+	"github.com/williamlsh/vault/internal/token"
 	"github.com/williamlsh/vault/internal/vaultservice"
 )
 
-// SigningKey is a JWT signing key.
-var SigningKey = []byte("zmh298onj30")
-
 // Set collects all of the endpoints that compose a vault service.
 type Set struct {
 	HashEndpoint     endpoint.Endpoint
 	ValidateEndpoint endpoint.Endpoint
 }
 
+// The scopes required of the JWTs calling the Hash and Validate endpoints.
+const (
+	ScopeHash     = "vault:hash"
+	ScopeValidate = "vault:validate"
+)
+
 // New returns a Set that wraps the provided server, and wires in all of the
 // expected endpoint middlewares via the various parameters
-func New(svc vaultservice.Service, duration metrics.Histogram, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) Set {
-	jwtParser := jwt.NewParser(
-		func(token *stdjwt.Token) (interface{}, error) { return SigningKey, nil }, stdjwt.SigningMethodHS256,
-		jwt.StandardClaimsFactory,
-	)
-
+func New(svc vaultservice.Service, auth *Authorizer, breakers *BreakerRegistry, duration metrics.Histogram, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) Set {
 	var hashEndpoint endpoint.Endpoint
 	{
 		hashEndpoint = MakeHashEndpoint(svc)
 		hashEndpoint = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Second), 1))(hashEndpoint)
-		hashEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(hashEndpoint)
-		hashEndpoint = jwtParser(hashEndpoint)
+		hashEndpoint = breakers.Breaker("pb.Vault", gobreaker.Settings{Name: "Hash"})(hashEndpoint)
+		hashEndpoint = ScopeMiddleware(ScopeHash)(hashEndpoint)
+		hashEndpoint = authorize(auth, At("hash", policy.Hash))(hashEndpoint)
 		hashEndpoint = opentracing.TraceServer(otTracer, "Hash")(hashEndpoint)
 		hashEndpoint = zipkin.TraceEndpoint(zipkinTracer, "Hash")(hashEndpoint)
 		hashEndpoint = LoggingMiddleware(log.With(logger, "method", "Hash"))(hashEndpoint)
@@ -53,8 +57,9 @@ func New(svc vaultservice.Service, duration metrics.Histogram, otTracer stdopent
 	{
 		validateEndpoint = MakeValidateEndpoint(svc)
 		validateEndpoint = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Second), 1))(validateEndpoint)
-		validateEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(validateEndpoint)
-		validateEndpoint = jwtParser(validateEndpoint)
+		validateEndpoint = breakers.Breaker("pb.Vault", gobreaker.Settings{Name: "Validate"})(validateEndpoint)
+		validateEndpoint = ScopeMiddleware(ScopeValidate)(validateEndpoint)
+		validateEndpoint = authorize(auth, At("validate", policy.Validate))(validateEndpoint)
 		validateEndpoint = opentracing.TraceServer(otTracer, "Validate")(validateEndpoint)
 		validateEndpoint = zipkin.TraceEndpoint(zipkinTracer, "Validate")(validateEndpoint)
 		validateEndpoint = LoggingMiddleware(log.With(logger, "method", "Validate"))(validateEndpoint)
@@ -66,6 +71,127 @@ func New(svc vaultservice.Service, duration metrics.Histogram, otTracer stdopent
 	}
 }
 
+// Authorizer authenticates requests with the tokens of the token store,
+// OAuth2 access tokens, JWTs of an external issuer or TLS client
+// certificates, and authorizes them against the policies granted to their
+// token or subject.
+type Authorizer struct {
+	tokens   *token.Store
+	policies *policy.Store
+	issuer   *oauth.Issuer
+	verifier *jwks.Verifier
+	denylist *denylist.List
+	replays  *replay.Cache
+}
+
+// NewAuthorizer returns an Authorizer looking up tokens in the token store
+// and policies in the policy store. OAuth2 access tokens are verified by the
+// issuer and external JWTs by the verifier; either kind of token is refused
+// if its verifier is nil. Both kinds are refused if their ID or subject is on
+// the denylist, and external JWTs are only accepted once if replays is not
+// nil.
+func NewAuthorizer(tokens *token.Store, policies *policy.Store, issuer *oauth.Issuer, verifier *jwks.Verifier, denylist *denylist.List, replays *replay.Cache) *Authorizer {
+	return &Authorizer{tokens: tokens, policies: policies, issuer: issuer, verifier: verifier, denylist: denylist, replays: replays}
+}
+
+func (a *Authorizer) lookupToken(ctx context.Context, id string) (identity.Identity, error) {
+	t, err := a.tokens.Lookup(ctx, id)
+	if err != nil {
+		return identity.Identity{}, err
+	}
+	return identity.Identity{Subject: t.DisplayName, Token: t.Hash, Method: "token", Policies: t.Policies, ExpireTime: t.ExpireTime}, nil
+}
+
+func (a *Authorizer) verifyAccessToken(ctx context.Context, raw string) (identity.Identity, error) {
+	claims, err := a.issuer.Verify(ctx, raw)
+	if err != nil {
+		return identity.Identity{}, err
+	}
+	if err := a.checkDenylist(ctx, claims.ID, claims.ClientID); err != nil {
+		return identity.Identity{}, err
+	}
+	return identity.Identity{Subject: claims.ClientID, TokenID: claims.ID, Method: "oauth", Policies: claims.Policies, Scopes: claims.Scopes(), ExpireTime: claims.ExpiresAt.Time}, nil
+}
+
+func (a *Authorizer) verifyJWT(ctx context.Context, raw string) (identity.Identity, error) {
+	claims, err := a.verifier.Verify(ctx, raw)
+	if err != nil {
+		return identity.Identity{}, err
+	}
+	if claims.Subject == "" {
+		return identity.Identity{}, jwks.ErrInvalidToken
+	}
+	if err := a.checkDenylist(ctx, claims.ID, claims.Subject); err != nil {
+		return identity.Identity{}, err
+	}
+	if a.replays != nil {
+		if err := a.replays.Check(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+			return identity.Identity{}, err
+		}
+	}
+	policies, err := a.policies.SubjectPolicies(ctx, policy.MethodJWT, claims.Subject)
+	if err != nil {
+		return identity.Identity{}, err
+	}
+	return identity.Identity{Subject: claims.Subject, TokenID: claims.ID, Method: "jwt", Policies: policies, Scopes: claims.Scopes(), ExpireTime: claims.ExpiresAt.Time}, nil
+}
+
+func (a *Authorizer) verifyCertificate(ctx context.Context, name string) (identity.Identity, error) {
+	if err := a.checkDenylist(ctx, "", name); err != nil {
+		return identity.Identity{}, err
+	}
+	policies, err := a.policies.SubjectPolicies(ctx, policy.MethodCert, name)
+	if err != nil {
+		return identity.Identity{}, err
+	}
+	return identity.Identity{Subject: name, Method: "cert", Policies: policies}, nil
+}
+
+// Recheck checks again the credentials of the caller authenticated by
+// Authenticate, so that the long lived requests of a caller end once its token
+// is revoked or denied. External JWTs are not checked against the replay
+// cache again, since they were accepted once already.
+func (a *Authorizer) Recheck(ctx context.Context) error {
+	id, ok := identity.FromContext(ctx)
+	if !ok {
+		return token.ErrMissingToken
+	}
+	switch id.Method {
+	case "token":
+		raw, _ := ctx.Value(jwt.JWTContextKey).(string)
+		_, err := a.tokens.Lookup(ctx, raw)
+		return err
+	case "oauth":
+		raw, _ := ctx.Value(jwt.JWTContextKey).(string)
+		_, err := a.verifyAccessToken(ctx, raw)
+		return err
+	default:
+		return a.checkDenylist(ctx, id.TokenID, id.Subject)
+	}
+}
+
+func (a *Authorizer) checkDenylist(ctx context.Context, tokenID, subject string) error {
+	if a.denylist == nil {
+		return nil
+	}
+	return a.denylist.Check(ctx, tokenID, subject)
+}
+
+// authenticate returns an endpoint middleware that only requires a valid
+// token.
+func authenticate(auth *Authorizer) endpoint.Middleware {
+	return AuthenticationMiddleware(auth)
+}
+
+// authorize returns an endpoint middleware that authenticates requests with
+// their token and authorizes them against the policies of the token.
+func authorize(auth *Authorizer, resource Resource) endpoint.Middleware {
+	return endpoint.Chain(
+		AuthenticationMiddleware(auth),
+		AuthorizationMiddleware(auth.policies, resource),
+	)
+}
+
 // Hash implements vaultservice.Service interface, so Set may be used as a
 // service. This is primarily  useful in the context of a client library.
 func (s Set) Hash(ctx context.Context, password string) (string, error) {
@@ -0,0 +1,260 @@
+package vaultendpoint
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/vaultservice"
+)
+
+// SSHSet collects all of the endpoints of the SSH secrets engine.
+type SSHSet struct {
+	ConfigureCAEndpoint endpoint.Endpoint
+	ReadCAEndpoint      endpoint.Endpoint
+	WriteRoleEndpoint   endpoint.Endpoint
+	ReadRoleEndpoint    endpoint.Endpoint
+	DeleteRoleEndpoint  endpoint.Endpoint
+	ListRolesEndpoint   endpoint.Endpoint
+	SignEndpoint        endpoint.Endpoint
+}
+
+// NewSSHSet returns an SSHSet that wraps the provided SSH service. The CA is
+// configured with sudo on ssh/ca, roles are managed on the ssh/roles/<name>
+// policy paths and keys are signed with update on ssh/sign/<role>. The public
+// key of the CA is public, for the SSH servers and clients trusting it.
+func NewSSHSet(svc vaultservice.SSHService, auth *Authorizer, duration metrics.Histogram, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) SSHSet {
+	wrap := func(name string, e endpoint.Endpoint) endpoint.Endpoint {
+		e = opentracing.TraceServer(otTracer, name)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, name)(e)
+		e = LoggingMiddleware(log.With(logger, "method", name))(e)
+		e = InstrumentingMiddleware(duration.With("method", name))(e)
+		return e
+	}
+	return SSHSet{
+		ConfigureCAEndpoint: wrap("SSHConfigureCA", authorize(auth, At("ssh/ca", policy.Sudo))(MakeSSHConfigureCAEndpoint(svc))),
+		ReadCAEndpoint:      wrap("SSHReadCA", MakeSSHReadCAEndpoint(svc)),
+		WriteRoleEndpoint:   wrap("SSHWriteRole", authorize(auth, sshResource("ssh/roles/", policy.Create, policy.Update))(MakeSSHWriteRoleEndpoint(svc))),
+		ReadRoleEndpoint:    wrap("SSHReadRole", authorize(auth, sshResource("ssh/roles/", policy.Read))(MakeSSHReadRoleEndpoint(svc))),
+		DeleteRoleEndpoint:  wrap("SSHDeleteRole", authorize(auth, sshResource("ssh/roles/", policy.Delete))(MakeSSHDeleteRoleEndpoint(svc))),
+		ListRolesEndpoint:   wrap("SSHListRoles", authorize(auth, At("ssh/roles", policy.List))(MakeSSHListRolesEndpoint(svc))),
+		SignEndpoint:        wrap("SSHSign", authorize(auth, sshResource("ssh/sign/", policy.Update))(MakeSSHSignEndpoint(svc))),
+	}
+}
+
+// sshResource returns the Resource of the role of the request under the
+// prefix.
+func sshResource(prefix string, capabilities ...string) Resource {
+	return func(request interface{}) (string, []string) {
+		var name string
+		switch req := request.(type) {
+		case SSHRoleRequest:
+			name = req.Name
+		case SSHWriteRoleRequest:
+			name = req.Name
+		case SSHSignRequest:
+			name = req.Role
+		}
+		return prefix + name, capabilities
+	}
+}
+
+// ConfigureCA implements vaultservice.SSHService interface, so SSHSet may be
+// used as a service. This is primarily useful in the context of a client
+// library.
+func (s SSHSet) ConfigureCA(ctx context.Context, opts vaultservice.SSHCAOptions) (vaultservice.SSHCA, error) {
+	resp, err := s.ConfigureCAEndpoint(ctx, SSHConfigureCARequest{SSHCAOptions: opts})
+	if err != nil {
+		return vaultservice.SSHCA{}, err
+	}
+	response := resp.(SSHCAResponse)
+	return response.SSHCA, response.Err
+}
+
+// ReadCA implements vaultservice.SSHService interface.
+func (s SSHSet) ReadCA(ctx context.Context) (vaultservice.SSHCA, error) {
+	resp, err := s.ReadCAEndpoint(ctx, SSHReadCARequest{})
+	if err != nil {
+		return vaultservice.SSHCA{}, err
+	}
+	response := resp.(SSHCAResponse)
+	return response.SSHCA, response.Err
+}
+
+// WriteRole implements vaultservice.SSHService interface.
+func (s SSHSet) WriteRole(ctx context.Context, name string, role vaultservice.SSHRole) error {
+	resp, err := s.WriteRoleEndpoint(ctx, SSHWriteRoleRequest{Name: name, SSHRole: role})
+	if err != nil {
+		return err
+	}
+	return resp.(SSHRoleResponse).Err
+}
+
+// ReadRole implements vaultservice.SSHService interface.
+func (s SSHSet) ReadRole(ctx context.Context, name string) (vaultservice.SSHRole, error) {
+	resp, err := s.ReadRoleEndpoint(ctx, SSHRoleRequest{Name: name})
+	if err != nil {
+		return vaultservice.SSHRole{}, err
+	}
+	response := resp.(SSHRoleResponse)
+	return response.SSHRole, response.Err
+}
+
+// DeleteRole implements vaultservice.SSHService interface.
+func (s SSHSet) DeleteRole(ctx context.Context, name string) error {
+	resp, err := s.DeleteRoleEndpoint(ctx, SSHRoleRequest{Name: name})
+	if err != nil {
+		return err
+	}
+	return resp.(SSHRoleResponse).Err
+}
+
+// ListRoles implements vaultservice.SSHService interface.
+func (s SSHSet) ListRoles(ctx context.Context) ([]string, error) {
+	resp, err := s.ListRolesEndpoint(ctx, SSHListRolesRequest{})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(ListRolesResponse)
+	return response.Roles, response.Err
+}
+
+// Sign implements vaultservice.SSHService interface.
+func (s SSHSet) Sign(ctx context.Context, role string, req vaultservice.SSHSignRequest) (vaultservice.SSHCertificate, error) {
+	resp, err := s.SignEndpoint(ctx, SSHSignRequest{Role: role, SSHSignRequest: req})
+	if err != nil {
+		return vaultservice.SSHCertificate{}, err
+	}
+	response := resp.(SSHCertificateResponse)
+	return response.SSHCertificate, response.Err
+}
+
+// MakeSSHConfigureCAEndpoint constructs a ConfigureCA endpoint wrapping the
+// service.
+func MakeSSHConfigureCAEndpoint(s vaultservice.SSHService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SSHConfigureCARequest)
+		ca, err := s.ConfigureCA(ctx, req.SSHCAOptions)
+		return SSHCAResponse{SSHCA: ca, Err: err}, nil
+	}
+}
+
+// MakeSSHReadCAEndpoint constructs a ReadCA endpoint wrapping the service.
+func MakeSSHReadCAEndpoint(s vaultservice.SSHService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		ca, err := s.ReadCA(ctx)
+		return SSHCAResponse{SSHCA: ca, Err: err}, nil
+	}
+}
+
+// MakeSSHWriteRoleEndpoint constructs a WriteRole endpoint wrapping the
+// service.
+func MakeSSHWriteRoleEndpoint(s vaultservice.SSHService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SSHWriteRoleRequest)
+		err := s.WriteRole(ctx, req.Name, req.SSHRole)
+		return SSHRoleResponse{Name: req.Name, Err: err}, nil
+	}
+}
+
+// MakeSSHReadRoleEndpoint constructs a ReadRole endpoint wrapping the
+// service.
+func MakeSSHReadRoleEndpoint(s vaultservice.SSHService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SSHRoleRequest)
+		role, err := s.ReadRole(ctx, req.Name)
+		return SSHRoleResponse{Name: req.Name, SSHRole: role, Err: err}, nil
+	}
+}
+
+// MakeSSHDeleteRoleEndpoint constructs a DeleteRole endpoint wrapping the
+// service.
+func MakeSSHDeleteRoleEndpoint(s vaultservice.SSHService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SSHRoleRequest)
+		err := s.DeleteRole(ctx, req.Name)
+		return SSHRoleResponse{Name: req.Name, Err: err}, nil
+	}
+}
+
+// MakeSSHListRolesEndpoint constructs a ListRoles endpoint wrapping the
+// service.
+func MakeSSHListRolesEndpoint(s vaultservice.SSHService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		names, err := s.ListRoles(ctx)
+		return ListRolesResponse{Roles: names, Err: err}, nil
+	}
+}
+
+// MakeSSHSignEndpoint constructs a Sign endpoint wrapping the service.
+func MakeSSHSignEndpoint(s vaultservice.SSHService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SSHSignRequest)
+		cert, err := s.Sign(ctx, req.Role, req.SSHSignRequest)
+		return SSHCertificateResponse{SSHCertificate: cert, Err: err}, nil
+	}
+}
+
+// Compile time assertions for the response types implementing endpoint.Failer.
+var (
+	_ endpoint.Failer = SSHCAResponse{}
+	_ endpoint.Failer = SSHRoleResponse{}
+	_ endpoint.Failer = SSHCertificateResponse{}
+)
+
+type SSHConfigureCARequest struct {
+	vaultservice.SSHCAOptions
+}
+
+type SSHReadCARequest struct{}
+
+type SSHCAResponse struct {
+	vaultservice.SSHCA
+	Err error `json:"-"`
+}
+
+func (r SSHCAResponse) Failed() error {
+	return r.Err
+}
+
+type SSHRoleRequest struct {
+	Name string `json:"-"`
+}
+
+type SSHWriteRoleRequest struct {
+	Name string `json:"-"`
+	vaultservice.SSHRole
+}
+
+type SSHRoleResponse struct {
+	Name string `json:"name"`
+	vaultservice.SSHRole
+	Err error `json:"-"`
+}
+
+func (r SSHRoleResponse) Failed() error {
+	return r.Err
+}
+
+type SSHListRolesRequest struct{}
+
+type SSHSignRequest struct {
+	Role string `json:"-"`
+	vaultservice.SSHSignRequest
+}
+
+type SSHCertificateResponse struct {
+	vaultservice.SSHCertificate
+	Err error `json:"-"`
+}
+
+func (r SSHCertificateResponse) Failed() error {
+	return r.Err
+}
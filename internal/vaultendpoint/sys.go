@@ -0,0 +1,200 @@
+package vaultendpoint
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/seal"
+	"github.com/williamlsh/vault/internal/vaultservice"
+)
+
+// SysSet collects all of the endpoints that compose the system backend.
+type SysSet struct {
+	InitEndpoint       endpoint.Endpoint
+	UnsealEndpoint     endpoint.Endpoint
+	SealEndpoint       endpoint.Endpoint
+	SealStatusEndpoint endpoint.Endpoint
+	RotateEndpoint     endpoint.Endpoint
+}
+
+// NewSysSet returns a SysSet that wraps the provided system service. Init,
+// Unseal and SealStatus are reachable without a token since no token can be
+// verified before the vault is unsealed; sealing and rotating require the
+// update capability on sys/seal and sys/rotate.
+func NewSysSet(svc vaultservice.SysService, auth *Authorizer, duration metrics.Histogram, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) SysSet {
+	wrap := func(name string, e endpoint.Endpoint) endpoint.Endpoint {
+		e = opentracing.TraceServer(otTracer, name)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, name)(e)
+		e = LoggingMiddleware(log.With(logger, "method", name))(e)
+		e = InstrumentingMiddleware(duration.With("method", name))(e)
+		return e
+	}
+	return SysSet{
+		InitEndpoint:       wrap("Init", MakeInitEndpoint(svc)),
+		UnsealEndpoint:     wrap("Unseal", MakeUnsealEndpoint(svc)),
+		SealEndpoint:       wrap("Seal", authorize(auth, At("sys/seal", policy.Update))(MakeSealEndpoint(svc))),
+		SealStatusEndpoint: wrap("SealStatus", MakeSealStatusEndpoint(svc)),
+		RotateEndpoint:     wrap("Rotate", authorize(auth, At("sys/rotate", policy.Update))(MakeRotateEndpoint(svc))),
+	}
+}
+
+// Init implements vaultservice.SysService interface, so SysSet may be used as
+// a service. This is primarily useful in the context of a client library.
+func (s SysSet) Init(ctx context.Context, shares, threshold int) ([]string, string, error) {
+	resp, err := s.InitEndpoint(ctx, InitRequest{SecretShares: shares, SecretThreshold: threshold})
+	if err != nil {
+		return nil, "", err
+	}
+	response := resp.(InitResponse)
+	return response.Keys, response.RootToken, response.Err
+}
+
+// Unseal implements vaultservice.SysService interface.
+func (s SysSet) Unseal(ctx context.Context, key string, reset bool) (seal.Status, error) {
+	resp, err := s.UnsealEndpoint(ctx, UnsealRequest{Key: key, Reset: reset})
+	if err != nil {
+		return seal.Status{}, err
+	}
+	response := resp.(SealStatusResponse)
+	return response.Status, response.Err
+}
+
+// Seal implements vaultservice.SysService interface.
+func (s SysSet) Seal(ctx context.Context) error {
+	resp, err := s.SealEndpoint(ctx, SealRequest{})
+	if err != nil {
+		return err
+	}
+	return resp.(SealResponse).Err
+}
+
+// SealStatus implements vaultservice.SysService interface.
+func (s SysSet) SealStatus(ctx context.Context) (seal.Status, error) {
+	resp, err := s.SealStatusEndpoint(ctx, SealStatusRequest{})
+	if err != nil {
+		return seal.Status{}, err
+	}
+	response := resp.(SealStatusResponse)
+	return response.Status, response.Err
+}
+
+// Rotate implements vaultservice.SysService interface.
+func (s SysSet) Rotate(ctx context.Context) (seal.KeyStatus, error) {
+	resp, err := s.RotateEndpoint(ctx, RotateRequest{})
+	if err != nil {
+		return seal.KeyStatus{}, err
+	}
+	response := resp.(RotateResponse)
+	return response.KeyStatus, response.Err
+}
+
+// MakeInitEndpoint constructs an Init endpoint wrapping the service.
+func MakeInitEndpoint(s vaultservice.SysService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(InitRequest)
+		keys, root, err := s.Init(ctx, req.SecretShares, req.SecretThreshold)
+		return InitResponse{Keys: keys, RootToken: root, Err: err}, nil
+	}
+}
+
+// MakeUnsealEndpoint constructs an Unseal endpoint wrapping the service.
+func MakeUnsealEndpoint(s vaultservice.SysService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UnsealRequest)
+		status, err := s.Unseal(ctx, req.Key, req.Reset)
+		return SealStatusResponse{Status: status, Err: err}, nil
+	}
+}
+
+// MakeSealEndpoint constructs a Seal endpoint wrapping the service.
+func MakeSealEndpoint(s vaultservice.SysService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		err := s.Seal(ctx)
+		return SealResponse{Err: err}, nil
+	}
+}
+
+// MakeSealStatusEndpoint constructs a SealStatus endpoint wrapping the
+// service.
+func MakeSealStatusEndpoint(s vaultservice.SysService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		status, err := s.SealStatus(ctx)
+		return SealStatusResponse{Status: status, Err: err}, nil
+	}
+}
+
+// MakeRotateEndpoint constructs a Rotate endpoint wrapping the service.
+func MakeRotateEndpoint(s vaultservice.SysService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		status, err := s.Rotate(ctx)
+		return RotateResponse{KeyStatus: status, Err: err}, nil
+	}
+}
+
+// Compile time assertions for the response types implementing endpoint.Failer.
+var (
+	_ endpoint.Failer = InitResponse{}
+	_ endpoint.Failer = SealResponse{}
+	_ endpoint.Failer = SealStatusResponse{}
+	_ endpoint.Failer = RotateResponse{}
+)
+
+type InitRequest struct {
+	SecretShares    int `json:"secret_shares"`
+	SecretThreshold int `json:"secret_threshold"`
+}
+
+type InitResponse struct {
+	Keys      []string `json:"keys"`
+	RootToken string   `json:"root_token"`
+	Err       error    `json:"-"`
+}
+
+func (r InitResponse) Failed() error {
+	return r.Err
+}
+
+type UnsealRequest struct {
+	Key   string `json:"key"`
+	Reset bool   `json:"reset"`
+}
+
+type SealRequest struct{}
+
+type SealResponse struct {
+	Err error `json:"-"`
+}
+
+func (r SealResponse) Failed() error {
+	return r.Err
+}
+
+type SealStatusRequest struct{}
+
+type SealStatusResponse struct {
+	seal.Status
+	Err error `json:"-"`
+}
+
+func (r SealStatusResponse) Failed() error {
+	return r.Err
+}
+
+type RotateRequest struct{}
+
+type RotateResponse struct {
+	seal.KeyStatus
+	Err error `json:"-"`
+}
+
+func (r RotateResponse) Failed() error {
+	return r.Err
+}
@@ -0,0 +1,231 @@
+package vaultendpoint
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/token"
+	"github.com/williamlsh/vault/internal/vaultservice"
+)
+
+// TokenSet collects all of the endpoints that manage tokens.
+type TokenSet struct {
+	CreateEndpoint       endpoint.Endpoint
+	LookupEndpoint       endpoint.Endpoint
+	LookupSelfEndpoint   endpoint.Endpoint
+	RenewEndpoint        endpoint.Endpoint
+	RenewSelfEndpoint    endpoint.Endpoint
+	RevokeEndpoint       endpoint.Endpoint
+	RevokeSelfEndpoint   endpoint.Endpoint
+	RevokeOrphanEndpoint endpoint.Endpoint
+}
+
+// NewTokenSet returns a TokenSet that wraps the provided token service.
+// Creating a token requires create or update on auth/token/create, and sudo
+// for orphan tokens. Other tokens are looked up, renewed and revoked with
+// update on auth/token/lookup, auth/token/renew and auth/token/revoke, and
+// revoke-orphan requires sudo. The self operations only require a valid
+// token, and always operate on it.
+func NewTokenSet(svc vaultservice.TokenService, auth *Authorizer, duration metrics.Histogram, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) TokenSet {
+	wrap := func(name string, mw endpoint.Middleware, e endpoint.Endpoint) endpoint.Endpoint {
+		e = mw(e)
+		e = opentracing.TraceServer(otTracer, name)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, name)(e)
+		e = LoggingMiddleware(log.With(logger, "method", name))(e)
+		e = InstrumentingMiddleware(duration.With("method", name))(e)
+		return e
+	}
+	return TokenSet{
+		CreateEndpoint:       wrap("TokenCreate", authorize(auth, createTokenResource), MakeTokenCreateEndpoint(svc)),
+		LookupEndpoint:       wrap("TokenLookup", authorize(auth, At("auth/token/lookup", policy.Update)), MakeTokenLookupEndpoint(svc)),
+		LookupSelfEndpoint:   wrap("TokenLookupSelf", authenticate(auth), self(MakeTokenLookupEndpoint(svc))),
+		RenewEndpoint:        wrap("TokenRenew", authorize(auth, At("auth/token/renew", policy.Update)), MakeTokenRenewEndpoint(svc)),
+		RenewSelfEndpoint:    wrap("TokenRenewSelf", authenticate(auth), self(MakeTokenRenewEndpoint(svc))),
+		RevokeEndpoint:       wrap("TokenRevoke", authorize(auth, At("auth/token/revoke", policy.Update)), MakeTokenRevokeEndpoint(svc)),
+		RevokeSelfEndpoint:   wrap("TokenRevokeSelf", authenticate(auth), self(MakeTokenRevokeEndpoint(svc))),
+		RevokeOrphanEndpoint: wrap("TokenRevokeOrphan", authorize(auth, At("auth/token/revoke-orphan", policy.Sudo)), MakeTokenRevokeOrphanEndpoint(svc)),
+	}
+}
+
+// self is a middleware serving the self operations with the token of the
+// caller, ignoring any token named by the request: the self operations are not
+// authorized for other tokens.
+func self(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		switch req := request.(type) {
+		case TokenRequest:
+			req.Token = ""
+			request = req
+		case RenewTokenRequest:
+			req.Token = ""
+			request = req
+		}
+		return next(ctx, request)
+	}
+}
+
+func createTokenResource(request interface{}) (string, []string) {
+	if req, _ := request.(CreateTokenRequest); req.NoParent {
+		return "auth/token/create", []string{policy.Sudo}
+	}
+	return "auth/token/create", []string{policy.Create, policy.Update}
+}
+
+// Create implements vaultservice.TokenService interface, so TokenSet may be
+// used as a service. This is primarily useful in the context of a client
+// library.
+func (s TokenSet) Create(ctx context.Context, opts vaultservice.TokenOptions) (token.Token, error) {
+	resp, err := s.CreateEndpoint(ctx, CreateTokenRequest{TokenOptions: opts})
+	if err != nil {
+		return token.Token{}, err
+	}
+	response := resp.(TokenResponse)
+	return response.Token, response.Err
+}
+
+// Lookup implements vaultservice.TokenService interface.
+func (s TokenSet) Lookup(ctx context.Context, id string) (token.Token, error) {
+	e := s.LookupEndpoint
+	if id == "" {
+		e = s.LookupSelfEndpoint
+	}
+	resp, err := e(ctx, TokenRequest{Token: id})
+	if err != nil {
+		return token.Token{}, err
+	}
+	response := resp.(TokenResponse)
+	return response.Token, response.Err
+}
+
+// Renew implements vaultservice.TokenService interface.
+func (s TokenSet) Renew(ctx context.Context, id string, increment time.Duration) (token.Token, error) {
+	e := s.RenewEndpoint
+	if id == "" {
+		e = s.RenewSelfEndpoint
+	}
+	resp, err := e(ctx, RenewTokenRequest{Token: id, Increment: int64(increment / time.Second)})
+	if err != nil {
+		return token.Token{}, err
+	}
+	response := resp.(TokenResponse)
+	return response.Token, response.Err
+}
+
+// Revoke implements vaultservice.TokenService interface.
+func (s TokenSet) Revoke(ctx context.Context, id string) error {
+	e := s.RevokeEndpoint
+	if id == "" {
+		e = s.RevokeSelfEndpoint
+	}
+	resp, err := e(ctx, TokenRequest{Token: id})
+	if err != nil {
+		return err
+	}
+	return resp.(TokenResponse).Err
+}
+
+// RevokeOrphan implements vaultservice.TokenService interface.
+func (s TokenSet) RevokeOrphan(ctx context.Context, id string) error {
+	resp, err := s.RevokeOrphanEndpoint(ctx, TokenRequest{Token: id})
+	if err != nil {
+		return err
+	}
+	return resp.(TokenResponse).Err
+}
+
+// MakeTokenCreateEndpoint constructs a Create endpoint wrapping the service.
+func MakeTokenCreateEndpoint(s vaultservice.TokenService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(CreateTokenRequest)
+		t, err := s.Create(ctx, req.TokenOptions)
+		return newTokenResponse(t, err), nil
+	}
+}
+
+// MakeTokenLookupEndpoint constructs a Lookup endpoint wrapping the service.
+// It serves both lookup and lookup-self, the latter with an empty token.
+func MakeTokenLookupEndpoint(s vaultservice.TokenService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(TokenRequest)
+		t, err := s.Lookup(ctx, req.Token)
+		return newTokenResponse(t, err), nil
+	}
+}
+
+// MakeTokenRenewEndpoint constructs a Renew endpoint wrapping the service.
+func MakeTokenRenewEndpoint(s vaultservice.TokenService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RenewTokenRequest)
+		t, err := s.Renew(ctx, req.Token, time.Duration(req.Increment)*time.Second)
+		return newTokenResponse(t, err), nil
+	}
+}
+
+// MakeTokenRevokeEndpoint constructs a Revoke endpoint wrapping the service.
+func MakeTokenRevokeEndpoint(s vaultservice.TokenService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(TokenRequest)
+		err := s.Revoke(ctx, req.Token)
+		return TokenResponse{Err: err}, nil
+	}
+}
+
+// MakeTokenRevokeOrphanEndpoint constructs a RevokeOrphan endpoint wrapping
+// the service.
+func MakeTokenRevokeOrphanEndpoint(s vaultservice.TokenService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(TokenRequest)
+		err := s.RevokeOrphan(ctx, req.Token)
+		return TokenResponse{Err: err}, nil
+	}
+}
+
+// Compile time assertions for the response types implementing endpoint.Failer.
+var (
+	_ endpoint.Failer = TokenResponse{}
+)
+
+type CreateTokenRequest struct {
+	vaultservice.TokenOptions
+}
+
+type TokenRequest struct {
+	Token string `json:"token"`
+}
+
+type RenewTokenRequest struct {
+	Token string `json:"token"`
+	// Increment is the requested extension in seconds.
+	Increment int64 `json:"increment"`
+}
+
+type TokenResponse struct {
+	token.Token
+	// Orphan reports whether the token has no parent.
+	Orphan bool `json:"orphan"`
+	// TTL is the number of seconds left before the token expires, zero for
+	// tokens which never expire.
+	TTL int64 `json:"ttl"`
+	Err error `json:"-"`
+}
+
+func newTokenResponse(t token.Token, err error) TokenResponse {
+	resp := TokenResponse{Token: t, Orphan: t.Orphan(), Err: err}
+	if !t.ExpireTime.IsZero() {
+		resp.TTL = int64(time.Until(t.ExpireTime) / time.Second)
+	}
+	return resp
+}
+
+func (r TokenResponse) Failed() error {
+	return r.Err
+}
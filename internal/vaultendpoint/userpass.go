@@ -0,0 +1,196 @@
+package vaultendpoint
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/token"
+	"github.com/williamlsh/vault/internal/vaultservice"
+)
+
+// UserpassSet collects all of the endpoints of the userpass auth method.
+type UserpassSet struct {
+	ReadUserEndpoint   endpoint.Endpoint
+	WriteUserEndpoint  endpoint.Endpoint
+	DeleteUserEndpoint endpoint.Endpoint
+	ListUsersEndpoint  endpoint.Endpoint
+	LoginEndpoint      endpoint.Endpoint
+}
+
+// NewUserpassSet returns a UserpassSet that wraps the provided userpass
+// service. Users are managed on the auth/userpass/users/<username> policy
+// paths. Login is reachable without a token, the password being the
+// credential.
+func NewUserpassSet(svc vaultservice.UserpassService, auth *Authorizer, duration metrics.Histogram, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) UserpassSet {
+	wrap := func(name string, e endpoint.Endpoint) endpoint.Endpoint {
+		e = opentracing.TraceServer(otTracer, name)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, name)(e)
+		e = LoggingMiddleware(log.With(logger, "method", name))(e)
+		e = InstrumentingMiddleware(duration.With("method", name))(e)
+		return e
+	}
+	return UserpassSet{
+		ReadUserEndpoint:   wrap("ReadUser", authorize(auth, userResource(policy.Read))(MakeReadUserEndpoint(svc))),
+		WriteUserEndpoint:  wrap("WriteUser", authorize(auth, userResource(policy.Create, policy.Update))(MakeWriteUserEndpoint(svc))),
+		DeleteUserEndpoint: wrap("DeleteUser", authorize(auth, userResource(policy.Delete))(MakeDeleteUserEndpoint(svc))),
+		ListUsersEndpoint:  wrap("ListUsers", authorize(auth, At("auth/userpass/users", policy.List))(MakeListUsersEndpoint(svc))),
+		LoginEndpoint:      wrap("UserpassLogin", MakeUserpassLoginEndpoint(svc)),
+	}
+}
+
+func userResource(capabilities ...string) Resource {
+	return func(request interface{}) (string, []string) {
+		var username string
+		switch req := request.(type) {
+		case UserRequest:
+			username = req.Username
+		case WriteUserRequest:
+			username = req.Username
+		}
+		return "auth/userpass/users/" + username, capabilities
+	}
+}
+
+// ReadUser implements vaultservice.UserpassService interface, so UserpassSet
+// may be used as a service. This is primarily useful in the context of a
+// client library.
+func (s UserpassSet) ReadUser(ctx context.Context, username string) (vaultservice.User, error) {
+	resp, err := s.ReadUserEndpoint(ctx, UserRequest{Username: username})
+	if err != nil {
+		return vaultservice.User{}, err
+	}
+	response := resp.(UserResponse)
+	return response.User, response.Err
+}
+
+// WriteUser implements vaultservice.UserpassService interface.
+func (s UserpassSet) WriteUser(ctx context.Context, username string, user vaultservice.User) error {
+	resp, err := s.WriteUserEndpoint(ctx, WriteUserRequest{Username: username, User: user})
+	if err != nil {
+		return err
+	}
+	return resp.(UserResponse).Err
+}
+
+// DeleteUser implements vaultservice.UserpassService interface.
+func (s UserpassSet) DeleteUser(ctx context.Context, username string) error {
+	resp, err := s.DeleteUserEndpoint(ctx, UserRequest{Username: username})
+	if err != nil {
+		return err
+	}
+	return resp.(UserResponse).Err
+}
+
+// ListUsers implements vaultservice.UserpassService interface.
+func (s UserpassSet) ListUsers(ctx context.Context) ([]string, error) {
+	resp, err := s.ListUsersEndpoint(ctx, ListUsersRequest{})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(ListUsersResponse)
+	return response.Users, response.Err
+}
+
+// Login implements vaultservice.UserpassService interface.
+func (s UserpassSet) Login(ctx context.Context, username, password string) (token.Token, error) {
+	resp, err := s.LoginEndpoint(ctx, UserpassLoginRequest{Username: username, Password: password})
+	if err != nil {
+		return token.Token{}, err
+	}
+	response := resp.(TokenResponse)
+	return response.Token, response.Err
+}
+
+// MakeReadUserEndpoint constructs a ReadUser endpoint wrapping the service.
+func MakeReadUserEndpoint(s vaultservice.UserpassService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UserRequest)
+		user, err := s.ReadUser(ctx, req.Username)
+		return UserResponse{Username: req.Username, User: user, Err: err}, nil
+	}
+}
+
+// MakeWriteUserEndpoint constructs a WriteUser endpoint wrapping the service.
+func MakeWriteUserEndpoint(s vaultservice.UserpassService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(WriteUserRequest)
+		err := s.WriteUser(ctx, req.Username, req.User)
+		return UserResponse{Username: req.Username, Err: err}, nil
+	}
+}
+
+// MakeDeleteUserEndpoint constructs a DeleteUser endpoint wrapping the
+// service.
+func MakeDeleteUserEndpoint(s vaultservice.UserpassService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UserRequest)
+		err := s.DeleteUser(ctx, req.Username)
+		return UserResponse{Username: req.Username, Err: err}, nil
+	}
+}
+
+// MakeListUsersEndpoint constructs a ListUsers endpoint wrapping the service.
+func MakeListUsersEndpoint(s vaultservice.UserpassService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		names, err := s.ListUsers(ctx)
+		return ListUsersResponse{Users: names, Err: err}, nil
+	}
+}
+
+// MakeUserpassLoginEndpoint constructs a Login endpoint wrapping the service.
+func MakeUserpassLoginEndpoint(s vaultservice.UserpassService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UserpassLoginRequest)
+		t, err := s.Login(ctx, req.Username, req.Password)
+		return newTokenResponse(t, err), nil
+	}
+}
+
+// Compile time assertions for the response types implementing endpoint.Failer.
+var (
+	_ endpoint.Failer = UserResponse{}
+	_ endpoint.Failer = ListUsersResponse{}
+)
+
+type UserRequest struct {
+	Username string `json:"-"`
+}
+
+type WriteUserRequest struct {
+	Username string `json:"-"`
+	vaultservice.User
+}
+
+type UserResponse struct {
+	Username string `json:"username"`
+	vaultservice.User
+	Err error `json:"-"`
+}
+
+func (r UserResponse) Failed() error {
+	return r.Err
+}
+
+type ListUsersRequest struct{}
+
+type ListUsersResponse struct {
+	Users []string `json:"users"`
+	Err   error    `json:"-"`
+}
+
+func (r ListUsersResponse) Failed() error {
+	return r.Err
+}
+
+type UserpassLoginRequest struct {
+	Username string `json:"-"`
+	Password string `json:"password"`
+}
@@ -0,0 +1,160 @@
+package vaultendpoint
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/vaultservice"
+)
+
+// WrapPath is the creation path of the data wrapped by the Wrap endpoint.
+const WrapPath = "sys/wrapping/wrap"
+
+// WrappingSet collects all of the endpoints of the response wrapping service.
+type WrappingSet struct {
+	WrapEndpoint   endpoint.Endpoint
+	UnwrapEndpoint endpoint.Endpoint
+	LookupEndpoint endpoint.Endpoint
+	// WrapResponseEndpoint wraps the response of another endpoint. It is not
+	// authorized, the request it answers having been, and is used by the
+	// transports rather than served on its own.
+	WrapResponseEndpoint endpoint.Endpoint
+}
+
+// NewWrappingSet returns a WrappingSet that wraps the provided response
+// wrapping service. Arbitrary data is wrapped with update on the
+// sys/wrapping/wrap policy path, a one-time cubbyhole. Unwrapping and looking
+// up a wrapping token take no other token, the wrapping token being the
+// credential.
+func NewWrappingSet(svc vaultservice.WrappingService, auth *Authorizer, duration metrics.Histogram, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) WrappingSet {
+	wrap := func(name string, e endpoint.Endpoint) endpoint.Endpoint {
+		e = opentracing.TraceServer(otTracer, name)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, name)(e)
+		e = LoggingMiddleware(log.With(logger, "method", name))(e)
+		e = InstrumentingMiddleware(duration.With("method", name))(e)
+		return e
+	}
+	return WrappingSet{
+		WrapEndpoint:         wrap("Wrap", authorize(auth, At(WrapPath, policy.Update))(MakeWrapEndpoint(svc))),
+		UnwrapEndpoint:       wrap("Unwrap", MakeUnwrapEndpoint(svc)),
+		LookupEndpoint:       wrap("WrapLookup", MakeWrapLookupEndpoint(svc)),
+		WrapResponseEndpoint: wrap("WrapResponse", MakeWrapResponseEndpoint(svc)),
+	}
+}
+
+// Wrap implements vaultservice.WrappingService interface, so WrappingSet may
+// be used as a service. This is primarily useful in the context of a client
+// library, which wraps data on WrapPath whatever the path.
+func (s WrappingSet) Wrap(ctx context.Context, _ string, data json.RawMessage, ttl string) (vaultservice.WrapInfo, error) {
+	resp, err := s.WrapEndpoint(ctx, WrapRequest{Data: data, TTL: ttl})
+	if err != nil {
+		return vaultservice.WrapInfo{}, err
+	}
+	response := resp.(WrapInfoResponse)
+	return response.WrapInfo, response.Err
+}
+
+// Unwrap implements vaultservice.WrappingService interface.
+func (s WrappingSet) Unwrap(ctx context.Context, token string) (json.RawMessage, error) {
+	resp, err := s.UnwrapEndpoint(ctx, UnwrapRequest{Token: token})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(UnwrapResponse)
+	return response.Data, response.Err
+}
+
+// Lookup implements vaultservice.WrappingService interface.
+func (s WrappingSet) Lookup(ctx context.Context, token string) (vaultservice.WrapInfo, error) {
+	resp, err := s.LookupEndpoint(ctx, UnwrapRequest{Token: token})
+	if err != nil {
+		return vaultservice.WrapInfo{}, err
+	}
+	response := resp.(WrapInfoResponse)
+	return response.WrapInfo, response.Err
+}
+
+// MakeWrapEndpoint constructs a Wrap endpoint wrapping the service.
+func MakeWrapEndpoint(s vaultservice.WrappingService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(WrapRequest)
+		info, err := s.Wrap(ctx, WrapPath, req.Data, req.TTL)
+		return WrapInfoResponse{WrapInfo: info, Err: err}, nil
+	}
+}
+
+// MakeUnwrapEndpoint constructs an Unwrap endpoint wrapping the service.
+func MakeUnwrapEndpoint(s vaultservice.WrappingService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UnwrapRequest)
+		data, err := s.Unwrap(ctx, req.Token)
+		return UnwrapResponse{Data: data, Err: err}, nil
+	}
+}
+
+// MakeWrapLookupEndpoint constructs a Lookup endpoint wrapping the service.
+func MakeWrapLookupEndpoint(s vaultservice.WrappingService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UnwrapRequest)
+		info, err := s.Lookup(ctx, req.Token)
+		return WrapInfoResponse{WrapInfo: info, Err: err}, nil
+	}
+}
+
+// MakeWrapResponseEndpoint constructs an endpoint wrapping the responses of
+// the other endpoints with the service.
+func MakeWrapResponseEndpoint(s vaultservice.WrappingService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(WrapResponseRequest)
+		info, err := s.Wrap(ctx, req.Path, req.Data, req.TTL)
+		return WrapInfoResponse{WrapInfo: info, Err: err}, nil
+	}
+}
+
+// Compile time assertions for the response types implementing endpoint.Failer.
+var (
+	_ endpoint.Failer = WrapInfoResponse{}
+	_ endpoint.Failer = UnwrapResponse{}
+)
+
+type WrapRequest struct {
+	Data json.RawMessage `json:"data"`
+	TTL  string          `json:"ttl,omitempty"`
+}
+
+type WrapResponseRequest struct {
+	Path string
+	Data json.RawMessage
+	TTL  string
+}
+
+type WrapInfoResponse struct {
+	WrapInfo vaultservice.WrapInfo `json:"wrap_info"`
+	Err      error                 `json:"-"`
+}
+
+func (r WrapInfoResponse) Failed() error {
+	return r.Err
+}
+
+type UnwrapRequest struct {
+	Token string `json:"token"`
+}
+
+type UnwrapResponse struct {
+	Data json.RawMessage `json:"data"`
+	Err  error           `json:"-"`
+}
+
+func (r UnwrapResponse) Failed() error {
+	return r.Err
+}
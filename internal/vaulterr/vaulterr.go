@@ -0,0 +1,239 @@
+// Package vaulterr classifies the errors of vault by codes, which the
+// transports map to HTTP statuses with a problem details body (RFC 7807) and
+// to gRPC status codes with error details, and which clients decode back into
+// the same typed errors.
+package vaulterr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Code classifies an error.
+type Code string
+
+// The codes of the errors.
+const (
+	// InvalidArgument refuses a malformed or invalid request.
+	InvalidArgument Code = "invalid_argument"
+	// Unauthenticated refuses a request without valid credentials.
+	Unauthenticated Code = "unauthenticated"
+	// PermissionDenied refuses a request its credentials do not allow.
+	PermissionDenied Code = "permission_denied"
+	// NotFound refuses a request for something which does not exist.
+	NotFound Code = "not_found"
+	// ResourceExhausted refuses a request over a rate limit or quota.
+	ResourceExhausted Code = "resource_exhausted"
+	// Unavailable refuses a request which may succeed later, such as while
+	// the vault is sealed or a circuit breaker is open.
+	Unavailable Code = "unavailable"
+	// Internal is the code of any other error.
+	Internal Code = "internal"
+)
+
+// Domain is the domain of the ErrorInfo details of the gRPC status errors.
+const Domain = "vault"
+
+var codes2http = map[Code]int{
+	InvalidArgument:   http.StatusBadRequest,
+	Unauthenticated:   http.StatusUnauthorized,
+	PermissionDenied:  http.StatusForbidden,
+	NotFound:          http.StatusNotFound,
+	ResourceExhausted: http.StatusTooManyRequests,
+	Unavailable:       http.StatusServiceUnavailable,
+	Internal:          http.StatusInternalServerError,
+}
+
+var codes2grpc = map[Code]codes.Code{
+	InvalidArgument:   codes.InvalidArgument,
+	Unauthenticated:   codes.Unauthenticated,
+	PermissionDenied:  codes.PermissionDenied,
+	NotFound:          codes.NotFound,
+	ResourceExhausted: codes.ResourceExhausted,
+	Unavailable:       codes.Unavailable,
+	Internal:          codes.Internal,
+}
+
+// HTTPStatus returns the HTTP status code of the errors of the code.
+func (c Code) HTTPStatus() int {
+	if s, ok := codes2http[c]; ok {
+		return s
+	}
+	return http.StatusInternalServerError
+}
+
+// GRPCCode returns the gRPC status code of the errors of the code.
+func (c Code) GRPCCode() codes.Code {
+	if s, ok := codes2grpc[c]; ok {
+		return s
+	}
+	return codes.Internal
+}
+
+// FromHTTPStatus returns the code of an HTTP status code, Internal if it has
+// none.
+func FromHTTPStatus(status int) Code {
+	for c, s := range codes2http {
+		if s == status {
+			return c
+		}
+	}
+	if status >= 400 && status < 500 {
+		return InvalidArgument
+	}
+	return Internal
+}
+
+// FromGRPCCode returns the code of a gRPC status code, Internal if it has
+// none.
+func FromGRPCCode(code codes.Code) Code {
+	for c, s := range codes2grpc {
+		if s == code {
+			return c
+		}
+	}
+	return Internal
+}
+
+// Error is an error classified by its code.
+type Error struct {
+	Code    Code
+	Message string
+	// Err is the classified error, nil for the errors decoded by clients.
+	Err error
+}
+
+// New returns an error of the code with the message.
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Errorf returns an error of the code with the formatted message, wrapping
+// the error of a %w verb.
+func Errorf(code Code, format string, a ...interface{}) *Error {
+	err := fmt.Errorf(format, a...)
+	return &Error{Code: code, Message: err.Error(), Err: errors.Unwrap(err)}
+}
+
+// Wrap classifies err with the code, unless it wraps an Error whose code it
+// keeps. It returns nil if err is nil, and err if it is an Error.
+func Wrap(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		if e == err {
+			return err
+		}
+		code = e.Code
+	}
+	return &Error{Code: code, Message: err.Error(), Err: err}
+}
+
+// CodeOf returns the code of err, Internal if it is not classified, or the
+// empty code if err is nil.
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return Internal
+}
+
+// Is reports whether err is classified with the code.
+func Is(err error, code Code) bool {
+	return CodeOf(err) == code
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// GRPCStatus returns the gRPC status of the error, with its code in the
+// ErrorInfo details, so that gRPC servers return errors as their status.
+func (e *Error) GRPCStatus() *status.Status {
+	s := status.New(e.Code.GRPCCode(), e.Message)
+	if d, err := s.WithDetails(&errdetails.ErrorInfo{
+		Reason: strings.ToUpper(string(e.Code)),
+		Domain: Domain,
+	}); err == nil {
+		return d
+	}
+	return s
+}
+
+// FromGRPCError returns the typed error of a gRPC status error, or err if it
+// is not one.
+func FromGRPCError(err error) error {
+	s, ok := status.FromError(err)
+	if !ok || s.Code() == codes.OK {
+		return err
+	}
+	code := FromGRPCCode(s.Code())
+	for _, d := range s.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.Domain == Domain {
+			code = Code(strings.ToLower(info.Reason))
+		}
+	}
+	return &Error{Code: code, Message: s.Message()}
+}
+
+// Problem is the problem details body (RFC 7807) of the HTTP error responses.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   Code   `json:"code"`
+	// Error is the detail again, for the clients reading the error member of
+	// the responses.
+	Error string `json:"error,omitempty"`
+}
+
+// ProblemContentType is the media type of the problem details.
+const ProblemContentType = "application/problem+json"
+
+// ProblemOf returns the problem details of err.
+func ProblemOf(err error) Problem {
+	code := CodeOf(err)
+	status := code.HTTPStatus()
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: err.Error(),
+		Code:   code,
+		Error:  err.Error(),
+	}
+}
+
+// Err returns the typed error of the problem details, classified by the
+// status code when they have no code.
+func (p Problem) Err() error {
+	code := p.Code
+	if _, ok := codes2http[code]; !ok {
+		code = FromHTTPStatus(p.Status)
+	}
+	msg := p.Detail
+	if msg == "" {
+		msg = p.Error
+	}
+	if msg == "" {
+		msg = p.Title
+	}
+	return &Error{Code: code, Message: msg}
+}
@@ -0,0 +1,115 @@
+package vaulterr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCodes(t *testing.T) {
+	for _, tc := range []struct {
+		code Code
+		http int
+		grpc codes.Code
+	}{
+		{InvalidArgument, 400, codes.InvalidArgument},
+		{Unauthenticated, 401, codes.Unauthenticated},
+		{PermissionDenied, 403, codes.PermissionDenied},
+		{NotFound, 404, codes.NotFound},
+		{ResourceExhausted, 429, codes.ResourceExhausted},
+		{Unavailable, 503, codes.Unavailable},
+		{Internal, 500, codes.Internal},
+	} {
+		if want, have := tc.http, tc.code.HTTPStatus(); want != have {
+			t.Errorf("%s: HTTP status: want %d, have %d", tc.code, want, have)
+		}
+		if want, have := tc.grpc, tc.code.GRPCCode(); want != have {
+			t.Errorf("%s: gRPC code: want %s, have %s", tc.code, want, have)
+		}
+		if want, have := tc.code, FromHTTPStatus(tc.http); want != have {
+			t.Errorf("status %d: want %s, have %s", tc.http, want, have)
+		}
+		if want, have := tc.code, FromGRPCCode(tc.grpc); want != have {
+			t.Errorf("gRPC code %s: want %s, have %s", tc.grpc, want, have)
+		}
+	}
+	if want, have := InvalidArgument, FromHTTPStatus(409); want != have {
+		t.Errorf("status 409: want %s, have %s", want, have)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	sentinel := errors.New("no such role")
+	err := fmt.Errorf("approle: %w", Wrap(NotFound, sentinel))
+	if want, have := NotFound, CodeOf(err); want != have {
+		t.Errorf("wrapped code: want %s, have %s", want, have)
+	}
+	if !errors.Is(err, sentinel) {
+		t.Error("wrapped error does not wrap the sentinel")
+	}
+	if want, have := NotFound, CodeOf(Wrap(Internal, err)); want != have {
+		t.Errorf("classified twice: want %s, have %s", want, have)
+	}
+	if want, have := Internal, CodeOf(sentinel); want != have {
+		t.Errorf("unclassified: want %s, have %s", want, have)
+	}
+	if Wrap(Internal, nil) != nil || CodeOf(nil) != "" {
+		t.Error("nil error classified")
+	}
+	if e := Errorf(InvalidArgument, "bad ttl: %w", sentinel); !errors.Is(e, sentinel) || e.Error() != "bad ttl: no such role" {
+		t.Errorf("Errorf: have %v", e)
+	}
+}
+
+func TestProblem(t *testing.T) {
+	raw, err := json.Marshal(ProblemOf(New(PermissionDenied, "permission denied")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"about:blank","title":"Forbidden","status":403,"detail":"permission denied","code":"permission_denied","error":"permission denied"}`
+	if have := string(raw); want != have {
+		t.Errorf("problem: want %s, have %s", want, have)
+	}
+
+	var p Problem
+	if err := json.Unmarshal(raw, &p); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Err(); !Is(err, PermissionDenied) || err.Error() != "permission denied" {
+		t.Errorf("decoded problem: have %s %v", CodeOf(err), err)
+	}
+
+	// Responses with only an error member are classified by their status.
+	if err := (Problem{Status: 503, Error: "vault is sealed"}).Err(); !Is(err, Unavailable) || err.Error() != "vault is sealed" {
+		t.Errorf("legacy error: have %s %v", CodeOf(err), err)
+	}
+}
+
+func TestGRPCStatus(t *testing.T) {
+	s, ok := status.FromError(Wrap(Internal, fmt.Errorf("kv: %w", New(ResourceExhausted, "rate limit exceeded"))))
+	if !ok {
+		t.Fatal("not a status error")
+	}
+	if want, have := codes.ResourceExhausted, s.Code(); want != have {
+		t.Errorf("status code: want %s, have %s", want, have)
+	}
+	if want, have := 1, len(s.Details()); want != have {
+		t.Fatalf("details: want %d, have %d", want, have)
+	}
+
+	err := FromGRPCError(s.Err())
+	if !Is(err, ResourceExhausted) || err.Error() != "kv: rate limit exceeded" {
+		t.Errorf("decoded status: have %s %v", CodeOf(err), err)
+	}
+	if err := FromGRPCError(status.Error(codes.Unavailable, "connection refused")); !Is(err, Unavailable) {
+		t.Errorf("status without details: have %s", CodeOf(err))
+	}
+	plain := errors.New("plain")
+	if FromGRPCError(plain) != plain {
+		t.Error("non-status error decoded")
+	}
+}
@@ -0,0 +1,296 @@
+package vaultransport
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+	"github.com/sony/gobreaker"
+	"google.golang.org/grpc"
+
+	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaultservice"
+	"github.com/williamlsh/vault/pb"
+)
+
+// NewHTTPAppRoleClient returns an AppRoleService backed by an HTTP server
+// living at the remote instance.
+func NewHTTPAppRoleClient(instance string, tlsOpts ClientTLS, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) (vaultservice.AppRoleService, error) {
+	c, err := gatewayClient(instance, tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+	before := []grpctransport.ClientRequestFunc{
+		opentracing.ContextToGRPC(otTracer, logger),
+		jwt.ContextToGRPC(),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = newGatewayEndpoint(c, "pb.AppRole", method, enc, dec, reply, before...)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.AppRoleSet{
+		ReadRoleEndpoint:         endpointFor("ReadRole", encodeGRPCRoleRequest, decodeGRPCRoleResponse, pb.RoleResponse{}),
+		WriteRoleEndpoint:        endpointFor("WriteRole", encodeGRPCWriteRoleRequest, decodeGRPCRoleResponse, pb.RoleResponse{}),
+		DeleteRoleEndpoint:       endpointFor("DeleteRole", encodeGRPCRoleRequest, decodeGRPCRoleResponse, pb.RoleResponse{}),
+		ListRolesEndpoint:        endpointFor("ListRoles", encodeGRPCListRolesRequest, decodeGRPCListRolesResponse, pb.ListRolesResponse{}),
+		GenerateSecretIDEndpoint: endpointFor("GenerateSecretID", encodeGRPCRoleRequest, decodeGRPCSecretIDResponse, pb.SecretIDResponse{}),
+		DestroySecretIDEndpoint:  endpointFor("DestroySecretID", encodeGRPCDestroySecretIDRequest, decodeGRPCSecretIDResponse, pb.SecretIDResponse{}),
+		LoginEndpoint:            endpointFor("Login", encodeGRPCAppRoleLoginRequest, decodeGRPCTokenResponse, pb.TokenResponse{}),
+	}, nil
+}
+
+type grpcAppRoleServer struct {
+	readRole         grpctransport.Handler
+	writeRole        grpctransport.Handler
+	deleteRole       grpctransport.Handler
+	listRoles        grpctransport.Handler
+	generateSecretID grpctransport.Handler
+	destroySecretID  grpctransport.Handler
+	login            grpctransport.Handler
+}
+
+// NewGRPCAppRoleServer makes the AppRole endpoints available as a gRPC
+// AppRoleServer.
+func NewGRPCAppRoleServer(endpoints vaultendpoint.AppRoleSet, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) pb.AppRoleServer {
+	options := grpcServerOptions(zipkinTracer, logger)
+	handler := func(name string, e endpoint.Endpoint, dec grpctransport.DecodeRequestFunc, enc grpctransport.EncodeResponseFunc) grpctransport.Handler {
+		return grpctransport.NewServer(
+			failedAsError(e),
+			dec,
+			enc,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, name, logger)))...,
+		)
+	}
+	return &grpcAppRoleServer{
+		readRole:         handler("ReadRole", endpoints.ReadRoleEndpoint, decodeGRPCRoleRequest, encodeGRPCRoleResponse),
+		writeRole:        handler("WriteRole", endpoints.WriteRoleEndpoint, decodeGRPCWriteRoleRequest, encodeGRPCRoleResponse),
+		deleteRole:       handler("DeleteRole", endpoints.DeleteRoleEndpoint, decodeGRPCRoleRequest, encodeGRPCRoleResponse),
+		listRoles:        handler("ListRoles", endpoints.ListRolesEndpoint, decodeGRPCListRolesRequest, encodeGRPCListRolesResponse),
+		generateSecretID: handler("GenerateSecretID", endpoints.GenerateSecretIDEndpoint, decodeGRPCRoleRequest, encodeGRPCSecretIDResponse),
+		destroySecretID:  handler("DestroySecretID", endpoints.DestroySecretIDEndpoint, decodeGRPCDestroySecretIDRequest, encodeGRPCSecretIDResponse),
+		login:            handler("AppRoleLogin", endpoints.LoginEndpoint, decodeGRPCAppRoleLoginRequest, encodeGRPCTokenResponse),
+	}
+}
+
+// NewGRPCAppRoleClient returns an AppRoleService backed by a gRPC server at
+// the other end of the conn.
+func NewGRPCAppRoleClient(conn *grpc.ClientConn, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) vaultservice.AppRoleService {
+	options := []grpctransport.ClientOption{
+		grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)),
+		grpctransport.ClientBefore(jwt.ContextToGRPC()),
+		zipkin.GRPCClientTrace(zipkinTracer),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = grpctransport.NewClient(conn, "pb.AppRole", method, enc, dec, reply, options...).Endpoint()
+		e = grpcErrorDecoder(e)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.AppRoleSet{
+		ReadRoleEndpoint:         endpointFor("ReadRole", encodeGRPCRoleRequest, decodeGRPCRoleResponse, pb.RoleResponse{}),
+		WriteRoleEndpoint:        endpointFor("WriteRole", encodeGRPCWriteRoleRequest, decodeGRPCRoleResponse, pb.RoleResponse{}),
+		DeleteRoleEndpoint:       endpointFor("DeleteRole", encodeGRPCRoleRequest, decodeGRPCRoleResponse, pb.RoleResponse{}),
+		ListRolesEndpoint:        endpointFor("ListRoles", encodeGRPCListRolesRequest, decodeGRPCListRolesResponse, pb.ListRolesResponse{}),
+		GenerateSecretIDEndpoint: endpointFor("GenerateSecretID", encodeGRPCRoleRequest, decodeGRPCSecretIDResponse, pb.SecretIDResponse{}),
+		DestroySecretIDEndpoint:  endpointFor("DestroySecretID", encodeGRPCDestroySecretIDRequest, decodeGRPCSecretIDResponse, pb.SecretIDResponse{}),
+		LoginEndpoint:            endpointFor("Login", encodeGRPCAppRoleLoginRequest, decodeGRPCTokenResponse, pb.TokenResponse{}),
+	}
+}
+
+func (s *grpcAppRoleServer) ReadRole(ctx context.Context, r *pb.RoleRequest) (*pb.RoleResponse, error) {
+	_, resp, err := s.readRole.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.RoleResponse), nil
+}
+
+func (s *grpcAppRoleServer) WriteRole(ctx context.Context, r *pb.WriteRoleRequest) (*pb.RoleResponse, error) {
+	_, resp, err := s.writeRole.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.RoleResponse), nil
+}
+
+func (s *grpcAppRoleServer) DeleteRole(ctx context.Context, r *pb.RoleRequest) (*pb.RoleResponse, error) {
+	_, resp, err := s.deleteRole.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.RoleResponse), nil
+}
+
+func (s *grpcAppRoleServer) ListRoles(ctx context.Context, r *pb.ListRolesRequest) (*pb.ListRolesResponse, error) {
+	_, resp, err := s.listRoles.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.ListRolesResponse), nil
+}
+
+func (s *grpcAppRoleServer) GenerateSecretID(ctx context.Context, r *pb.RoleRequest) (*pb.SecretIDResponse, error) {
+	_, resp, err := s.generateSecretID.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.SecretIDResponse), nil
+}
+
+func (s *grpcAppRoleServer) DestroySecretID(ctx context.Context, r *pb.DestroySecretIDRequest) (*pb.SecretIDResponse, error) {
+	_, resp, err := s.destroySecretID.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.SecretIDResponse), nil
+}
+
+func (s *grpcAppRoleServer) Login(ctx context.Context, r *pb.AppRoleLoginRequest) (*pb.TokenResponse, error) {
+	return serveGRPCToken(ctx, s.login, r)
+}
+
+func toPBRole(r vaultservice.AppRole) *pb.Role {
+	return &pb.Role{
+		RoleId:          r.RoleID,
+		BoundCidrs:      r.BoundCIDRs,
+		TokenPolicies:   r.TokenPolicies,
+		TokenTtl:        r.TokenTTL,
+		SecretIdNumUses: int64(r.SecretIDNumUses),
+		SecretIdTtl:     r.SecretIDTTL,
+	}
+}
+
+func fromPBRole(r *pb.Role) vaultservice.AppRole {
+	if r == nil {
+		return vaultservice.AppRole{}
+	}
+	return vaultservice.AppRole{
+		RoleID:          r.RoleId,
+		BoundCIDRs:      r.BoundCidrs,
+		TokenPolicies:   r.TokenPolicies,
+		TokenTTL:        r.TokenTtl,
+		SecretIDNumUses: int(r.SecretIdNumUses),
+		SecretIDTTL:     r.SecretIdTtl,
+	}
+}
+
+func decodeGRPCRoleRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.RoleRequest)
+	return vaultendpoint.RoleRequest{Name: req.Name}, nil
+}
+
+func decodeGRPCWriteRoleRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.WriteRoleRequest)
+	return vaultendpoint.WriteRoleRequest{Name: req.Name, AppRole: fromPBRole(req.Role)}, nil
+}
+
+func decodeGRPCListRolesRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return vaultendpoint.ListRolesRequest{}, nil
+}
+
+func decodeGRPCDestroySecretIDRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.DestroySecretIDRequest)
+	return vaultendpoint.DestroySecretIDRequest{Name: req.Name, Accessor: req.SecretIdAccessor}, nil
+}
+
+func decodeGRPCAppRoleLoginRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.AppRoleLoginRequest)
+	return vaultendpoint.AppRoleLoginRequest{RoleID: req.RoleId, SecretID: req.SecretId}, nil
+}
+
+func encodeGRPCRoleResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.RoleResponse)
+	return &pb.RoleResponse{Name: resp.Name, Role: toPBRole(resp.AppRole), Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCListRolesResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.ListRolesResponse)
+	return &pb.ListRolesResponse{Roles: resp.Roles, Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCSecretIDResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.SecretIDResponse)
+	return &pb.SecretIDResponse{
+		SecretId:         resp.SecretID,
+		SecretIdAccessor: resp.Accessor,
+		SecretIdNumUses:  int64(resp.NumUses),
+		ExpireTime:       unixNano(resp.ExpireTime),
+		Err:              err2str(resp.Err),
+	}, nil
+}
+
+func encodeGRPCRoleRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.RoleRequest)
+	return &pb.RoleRequest{Name: req.Name}, nil
+}
+
+func encodeGRPCWriteRoleRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.WriteRoleRequest)
+	return &pb.WriteRoleRequest{Name: req.Name, Role: toPBRole(req.AppRole)}, nil
+}
+
+func encodeGRPCListRolesRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.ListRolesRequest{}, nil
+}
+
+func encodeGRPCDestroySecretIDRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.DestroySecretIDRequest)
+	return &pb.DestroySecretIDRequest{Name: req.Name, SecretIdAccessor: req.Accessor}, nil
+}
+
+func encodeGRPCAppRoleLoginRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.AppRoleLoginRequest)
+	return &pb.AppRoleLoginRequest{RoleId: req.RoleID, SecretId: req.SecretID}, nil
+}
+
+func decodeGRPCRoleResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.RoleResponse)
+	return vaultendpoint.RoleResponse{Name: reply.Name, AppRole: fromPBRole(reply.Role), Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCListRolesResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.ListRolesResponse)
+	return vaultendpoint.ListRolesResponse{Roles: reply.Roles, Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCSecretIDResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.SecretIDResponse)
+	return vaultendpoint.SecretIDResponse{
+		AppRoleSecretID: vaultservice.AppRoleSecretID{
+			SecretID:   reply.SecretId,
+			Accessor:   reply.SecretIdAccessor,
+			NumUses:    int(reply.SecretIdNumUses),
+			ExpireTime: fromUnixNano(reply.ExpireTime),
+		},
+		Err: str2err(reply.Err),
+	}, nil
+}
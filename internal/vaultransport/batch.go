@@ -0,0 +1,416 @@
+package vaultransport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/transport"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/williamlsh/vault/internal/identity"
+	"github.com/williamlsh/vault/internal/token"
+	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaulterr"
+	"github.com/williamlsh/vault/pb"
+)
+
+// DefaultStreamWindow is the default number of items of a stream which are
+// in flight at once, served concurrently by a StreamServer or sent and not
+// yet answered by a BatchClient.
+const DefaultStreamWindow = 64
+
+// StreamServer wraps an endpoint serving the items of a bidirectional gRPC
+// stream, one call per item, the way grpctransport.Server wraps an endpoint
+// serving a unary call. Items are served concurrently within a window, and
+// their responses are sent as they complete, so that responses are correlated
+// to their items by the items themselves. Once the window is full the server
+// stops reading the stream, and gRPC flow control in turn stops the client.
+//
+// An item which fails to decode or which the endpoint refuses is answered by
+// the error encoder and the stream goes on, unless the item is refused for
+// its credentials: as every following item would be refused too, the stream
+// then ends with the error.
+type StreamServer struct {
+	e            endpoint.Endpoint
+	newRequest   func() interface{}
+	dec          grpctransport.DecodeRequestFunc
+	enc          grpctransport.EncodeResponseFunc
+	errorEncoder StreamErrorEncoder
+	before       []grpctransport.ServerRequestFunc
+	open         vaultendpoint.StreamOpener
+	window       int
+	errorHandler transport.ErrorHandler
+}
+
+// StreamErrorEncoder encodes the error of an item of a stream into the gRPC
+// response answering it.
+type StreamErrorEncoder func(grpcReq interface{}, err error) interface{}
+
+// NewStreamServer constructs a new StreamServer, which receives the items of
+// the stream into the messages returned by newRequest.
+func NewStreamServer(
+	e endpoint.Endpoint,
+	newRequest func() interface{},
+	dec grpctransport.DecodeRequestFunc,
+	enc grpctransport.EncodeResponseFunc,
+	errorEncoder StreamErrorEncoder,
+	options ...StreamServerOption,
+) *StreamServer {
+	s := &StreamServer{
+		e:            e,
+		newRequest:   newRequest,
+		dec:          dec,
+		enc:          enc,
+		errorEncoder: errorEncoder,
+		window:       DefaultStreamWindow,
+		errorHandler: transport.NewLogErrorHandler(log.NewNopLogger()),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// StreamServerOption sets an optional parameter for stream servers.
+type StreamServerOption func(*StreamServer)
+
+// StreamServerBefore functions are executed on the metadata of the stream
+// once, before its first item is served.
+func StreamServerBefore(before ...grpctransport.ServerRequestFunc) StreamServerOption {
+	return func(s *StreamServer) { s.before = append(s.before, before...) }
+}
+
+// StreamServerOpen sets the function called once the before functions have
+// run, before the first item is read. The stream ends with its error, if any.
+func StreamServerOpen(open vaultendpoint.StreamOpener) StreamServerOption {
+	return func(s *StreamServer) { s.open = open }
+}
+
+// StreamServerWindow sets the number of items served at once, at least one.
+func StreamServerWindow(window int) StreamServerOption {
+	return func(s *StreamServer) {
+		if window > 0 {
+			s.window = window
+		}
+	}
+}
+
+// StreamServerErrorHandler is used to handle the errors ending the streams.
+func StreamServerErrorHandler(errorHandler transport.ErrorHandler) StreamServerOption {
+	return func(s *StreamServer) { s.errorHandler = errorHandler }
+}
+
+// ServeStream serves the items of the stream until the client closes it, and
+// returns the error ending the stream, if any.
+func (s *StreamServer) ServeStream(stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		md = metadata.MD{}
+	}
+	for _, f := range s.before {
+		ctx = f(ctx, md)
+	}
+	if s.open != nil {
+		var err error
+		if ctx, err = s.open(ctx); err != nil {
+			s.errorHandler.Handle(stream.Context(), err)
+			return grpcError(err)
+		}
+	}
+	// The stream does not outlive the token of its caller.
+	var cancel context.CancelFunc
+	if id, ok := identity.FromContext(ctx); ok && !id.ExpireTime.IsZero() {
+		ctx, cancel = context.WithDeadline(ctx, id.ExpireTime)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	var (
+		window  = make(chan struct{}, s.window)
+		items   sync.WaitGroup
+		sending sync.Mutex
+		closed  bool
+		eof     = make(chan struct{})
+		errc    = make(chan error, 1)
+	)
+	fail := func(err error) {
+		select {
+		case errc <- err:
+		default:
+		}
+	}
+	go func() {
+		for {
+			window <- struct{}{}
+			grpcReq := s.newRequest()
+			if err := stream.RecvMsg(grpcReq); err != nil {
+				<-window
+				if err == io.EOF {
+					close(eof)
+				} else {
+					fail(err)
+				}
+				return
+			}
+			items.Add(1)
+			go func() {
+				defer func() {
+					<-window
+					items.Done()
+				}()
+				if ctx.Err() != nil {
+					return
+				}
+				grpcResp, err := s.serve(ctx, grpcReq)
+				if err != nil {
+					fail(err)
+					return
+				}
+				sending.Lock()
+				defer sending.Unlock()
+				if closed {
+					return
+				}
+				if err := stream.SendMsg(grpcResp); err != nil {
+					fail(err)
+				}
+			}()
+		}
+	}()
+
+	var err error
+	select {
+	case <-eof:
+		items.Wait()
+		select {
+		case err = <-errc:
+		default:
+		}
+	case err = <-errc:
+	case <-ctx.Done():
+		if err = stream.Context().Err(); err == nil {
+			err = fmt.Errorf("%w: token expired during the stream", token.ErrInvalidToken)
+		}
+	}
+	// No response may be sent once the handler returns.
+	sending.Lock()
+	closed = true
+	sending.Unlock()
+	if err != nil {
+		s.errorHandler.Handle(ctx, err)
+		return grpcError(err)
+	}
+	return nil
+}
+
+// serve returns the gRPC response of an item, or the error ending the stream.
+func (s *StreamServer) serve(ctx context.Context, grpcReq interface{}) (interface{}, error) {
+	request, err := s.dec(ctx, grpcReq)
+	if err != nil {
+		return s.errorEncoder(grpcReq, err), nil
+	}
+	response, err := s.e(ctx, request)
+	if err != nil {
+		switch errorCode(err) {
+		case vaulterr.Unauthenticated, vaulterr.PermissionDenied:
+			return nil, err
+		}
+		return s.errorEncoder(grpcReq, err), nil
+	}
+	return s.enc(ctx, response)
+}
+
+// batchError encodes the error of an item of a batch, nil if it succeeded.
+func batchError(err error) *pb.BatchError {
+	if err == nil {
+		return nil
+	}
+	err = classify(err)
+	return &pb.BatchError{Code: string(vaulterr.CodeOf(err)), Message: err.Error()}
+}
+
+// batchErr decodes the error of an item of a batch.
+func batchErr(e *pb.BatchError) error {
+	if e == nil {
+		return nil
+	}
+	return vaulterr.New(vaulterr.Code(e.Code), e.Message)
+}
+
+func decodeGRPCBatchHashRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.BatchHashRequest)
+	return vaultendpoint.BatchHashRequest{ID: req.Id, Password: req.Password}, nil
+}
+
+func encodeGRPCBatchHashResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.BatchHashResponse)
+	return &pb.BatchHashResponse{Id: resp.ID, Hash: resp.Hash, Error: batchError(resp.Err)}, nil
+}
+
+func encodeGRPCBatchHashError(grpcReq interface{}, err error) interface{} {
+	return &pb.BatchHashResponse{Id: grpcReq.(*pb.BatchHashRequest).Id, Error: batchError(err)}
+}
+
+func decodeGRPCBatchValidateRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.BatchValidateRequest)
+	if req.Hash == "" {
+		return nil, vaulterr.New(vaulterr.InvalidArgument, "missing hash")
+	}
+	return vaultendpoint.BatchValidateRequest{ID: req.Id, Password: req.Password, Hash: req.Hash}, nil
+}
+
+func encodeGRPCBatchValidateResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.BatchValidateResponse)
+	return &pb.BatchValidateResponse{Id: resp.ID, Valid: resp.Valid, Error: batchError(resp.Err)}, nil
+}
+
+func encodeGRPCBatchValidateError(grpcReq interface{}, err error) interface{} {
+	return &pb.BatchValidateResponse{Id: grpcReq.(*pb.BatchValidateRequest).Id, Error: batchError(err)}
+}
+
+func (s *grpcServer) BatchHash(stream pb.Vault_BatchHashServer) error {
+	return s.batchHash.ServeStream(stream)
+}
+
+func (s *grpcServer) BatchValidate(stream pb.Vault_BatchValidateServer) error {
+	return s.batchValidate.ServeStream(stream)
+}
+
+// BatchClient hashes and validates batches of passwords over the BatchHash
+// and BatchValidate streams of a gRPC server, each stream authenticated by a
+// token of its source.
+type BatchClient struct {
+	client pb.VaultClient
+	tokens TokenSource
+	window int
+}
+
+// NewGRPCBatchClient returns a BatchClient backed by a gRPC server at the
+// other end of the conn, keeping at most window items of a stream in flight,
+// or DefaultStreamWindow if window is not positive.
+func NewGRPCBatchClient(conn *grpc.ClientConn, tokens TokenSource, window int) *BatchClient {
+	if window <= 0 {
+		window = DefaultStreamWindow
+	}
+	return &BatchClient{client: pb.NewVaultClient(conn), tokens: tokens, window: window}
+}
+
+// Hash sends the items to a BatchHash stream until the channel is closed, and
+// calls fn with the response of each item as it arrives, which need not be in
+// the order of the items. Responses carry the errors of their item. Hash
+// returns once every item is answered, or with the error ending the stream.
+func (c *BatchClient) Hash(ctx context.Context, items <-chan vaultendpoint.BatchHashRequest, fn func(vaultendpoint.BatchHashResponse)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctx, err := streamContext(ctx, c.tokens)
+	if err != nil {
+		return err
+	}
+	stream, err := c.client.BatchHash(ctx)
+	if err != nil {
+		return vaulterr.FromGRPCError(err)
+	}
+	return runBatch(ctx, c.window, func() (bool, error) {
+		select {
+		case item, ok := <-items:
+			if !ok {
+				return false, stream.CloseSend()
+			}
+			return true, stream.Send(&pb.BatchHashRequest{Id: item.ID, Password: item.Password})
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}, func() error {
+		reply, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		fn(vaultendpoint.BatchHashResponse{ID: reply.Id, Hash: reply.Hash, Err: batchErr(reply.Error)})
+		return nil
+	})
+}
+
+// Validate is like Hash for a BatchValidate stream.
+func (c *BatchClient) Validate(ctx context.Context, items <-chan vaultendpoint.BatchValidateRequest, fn func(vaultendpoint.BatchValidateResponse)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctx, err := streamContext(ctx, c.tokens)
+	if err != nil {
+		return err
+	}
+	stream, err := c.client.BatchValidate(ctx)
+	if err != nil {
+		return vaulterr.FromGRPCError(err)
+	}
+	return runBatch(ctx, c.window, func() (bool, error) {
+		select {
+		case item, ok := <-items:
+			if !ok {
+				return false, stream.CloseSend()
+			}
+			return true, stream.Send(&pb.BatchValidateRequest{Id: item.ID, Password: item.Password, Hash: item.Hash})
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}, func() error {
+		reply, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		fn(vaultendpoint.BatchValidateResponse{ID: reply.Id, Valid: reply.Valid, Err: batchErr(reply.Error)})
+		return nil
+	})
+}
+
+// streamContext returns the context of a stream, with a token of the source
+// in its metadata.
+func streamContext(ctx context.Context, tokens TokenSource) (context.Context, error) {
+	ctx, err := withToken(ctx, tokens)
+	if err != nil {
+		return nil, err
+	}
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		md = metadata.MD{}
+	}
+	md = md.Copy()
+	ctx = jwt.ContextToGRPC()(ctx, &md)
+	return metadata.NewOutgoingContext(ctx, md), nil
+}
+
+// runBatch sends the items of a stream with send, which reports whether it
+// sent one, while at most window of them are not yet answered, and receives
+// their responses with recv until the stream ends.
+func runBatch(ctx context.Context, window int, send func() (bool, error), recv func() error) error {
+	inflight := make(chan struct{}, window)
+	go func() {
+		for {
+			select {
+			case inflight <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			// A failed send breaks the stream, whose error recv returns.
+			if more, err := send(); !more || err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		if err := recv(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return vaulterr.FromGRPCError(err)
+		}
+		<-inflight
+	}
+}
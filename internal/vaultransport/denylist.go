@@ -0,0 +1,227 @@
+package vaultransport
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+	"github.com/sony/gobreaker"
+	"google.golang.org/grpc"
+
+	"github.com/williamlsh/vault/internal/denylist"
+	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaultservice"
+	"github.com/williamlsh/vault/pb"
+)
+
+// NewHTTPDenylistClient returns a DenylistService backed by an HTTP server
+// living at the remote instance.
+func NewHTTPDenylistClient(instance string, tlsOpts ClientTLS, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) (vaultservice.DenylistService, error) {
+	c, err := gatewayClient(instance, tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+	before := []grpctransport.ClientRequestFunc{
+		opentracing.ContextToGRPC(otTracer, logger),
+		jwt.ContextToGRPC(),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = newGatewayEndpoint(c, "pb.Denylist", method, enc, dec, reply, before...)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.DenylistSet{
+		DenyEndpoint:       endpointFor("Deny", encodeGRPCDenyRequest, decodeGRPCDenylistEntryResponse, pb.DenylistEntryResponse{}),
+		AllowEndpoint:      endpointFor("Allow", encodeGRPCDenylistEntryRequest, decodeGRPCDenylistEntryResponse, pb.DenylistEntryResponse{}),
+		ReadDeniedEndpoint: endpointFor("ReadDenied", encodeGRPCDenylistEntryRequest, decodeGRPCDenylistEntryResponse, pb.DenylistEntryResponse{}),
+		ListDeniedEndpoint: endpointFor("ListDenied", encodeGRPCListDeniedRequest, decodeGRPCListDeniedResponse, pb.ListDeniedResponse{}),
+	}, nil
+}
+
+type grpcDenylistServer struct {
+	deny       grpctransport.Handler
+	allow      grpctransport.Handler
+	readDenied grpctransport.Handler
+	listDenied grpctransport.Handler
+}
+
+// NewGRPCDenylistServer makes the denylist endpoints available as a gRPC
+// DenylistServer.
+func NewGRPCDenylistServer(endpoints vaultendpoint.DenylistSet, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) pb.DenylistServer {
+	options := grpcServerOptions(zipkinTracer, logger)
+	handler := func(name string, e endpoint.Endpoint, dec grpctransport.DecodeRequestFunc, enc grpctransport.EncodeResponseFunc) grpctransport.Handler {
+		return grpctransport.NewServer(
+			failedAsError(e),
+			dec,
+			enc,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, name, logger)))...,
+		)
+	}
+	return &grpcDenylistServer{
+		deny:       handler("Deny", endpoints.DenyEndpoint, decodeGRPCDenyRequest, encodeGRPCDenylistEntryResponse),
+		allow:      handler("Allow", endpoints.AllowEndpoint, decodeGRPCDenylistEntryRequest, encodeGRPCDenylistEntryResponse),
+		readDenied: handler("ReadDenied", endpoints.ReadDeniedEndpoint, decodeGRPCDenylistEntryRequest, encodeGRPCDenylistEntryResponse),
+		listDenied: handler("ListDenied", endpoints.ListDeniedEndpoint, decodeGRPCListDeniedRequest, encodeGRPCListDeniedResponse),
+	}
+}
+
+// NewGRPCDenylistClient returns a DenylistService backed by a gRPC server at
+// the other end of the conn.
+func NewGRPCDenylistClient(conn *grpc.ClientConn, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) vaultservice.DenylistService {
+	options := []grpctransport.ClientOption{
+		grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)),
+		grpctransport.ClientBefore(jwt.ContextToGRPC()),
+		zipkin.GRPCClientTrace(zipkinTracer),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = grpctransport.NewClient(conn, "pb.Denylist", method, enc, dec, reply, options...).Endpoint()
+		e = grpcErrorDecoder(e)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.DenylistSet{
+		DenyEndpoint:       endpointFor("Deny", encodeGRPCDenyRequest, decodeGRPCDenylistEntryResponse, pb.DenylistEntryResponse{}),
+		AllowEndpoint:      endpointFor("Allow", encodeGRPCDenylistEntryRequest, decodeGRPCDenylistEntryResponse, pb.DenylistEntryResponse{}),
+		ReadDeniedEndpoint: endpointFor("ReadDenied", encodeGRPCDenylistEntryRequest, decodeGRPCDenylistEntryResponse, pb.DenylistEntryResponse{}),
+		ListDeniedEndpoint: endpointFor("ListDenied", encodeGRPCListDeniedRequest, decodeGRPCListDeniedResponse, pb.ListDeniedResponse{}),
+	}
+}
+
+func (s *grpcDenylistServer) Deny(ctx context.Context, r *pb.DenyRequest) (*pb.DenylistEntryResponse, error) {
+	_, resp, err := s.deny.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.DenylistEntryResponse), nil
+}
+
+func (s *grpcDenylistServer) Allow(ctx context.Context, r *pb.DenylistEntryRequest) (*pb.DenylistEntryResponse, error) {
+	_, resp, err := s.allow.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.DenylistEntryResponse), nil
+}
+
+func (s *grpcDenylistServer) ReadDenied(ctx context.Context, r *pb.DenylistEntryRequest) (*pb.DenylistEntryResponse, error) {
+	_, resp, err := s.readDenied.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.DenylistEntryResponse), nil
+}
+
+func (s *grpcDenylistServer) ListDenied(ctx context.Context, r *pb.ListDeniedRequest) (*pb.ListDeniedResponse, error) {
+	_, resp, err := s.listDenied.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.ListDeniedResponse), nil
+}
+
+func decodeGRPCDenyRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.DenyRequest)
+	return vaultendpoint.DenyRequest{Kind: req.Kind, Value: req.Value, Reason: req.Reason, TTL: req.Ttl}, nil
+}
+
+func decodeGRPCDenylistEntryRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.DenylistEntryRequest)
+	return vaultendpoint.DenylistEntryRequest{Kind: req.Kind, Value: req.Value}, nil
+}
+
+func decodeGRPCListDeniedRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return vaultendpoint.ListDeniedRequest{}, nil
+}
+
+func encodeGRPCDenylistEntryResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.DenylistEntryResponse)
+	return &pb.DenylistEntryResponse{Entry: toPBDenylistEntry(resp.Entry), Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCListDeniedResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.ListDeniedResponse)
+	entries := make([]*pb.DenylistEntry, len(resp.Entries))
+	for i, e := range resp.Entries {
+		entries[i] = toPBDenylistEntry(e)
+	}
+	return &pb.ListDeniedResponse{Entries: entries, Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCDenyRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.DenyRequest)
+	return &pb.DenyRequest{Kind: req.Kind, Value: req.Value, Reason: req.Reason, Ttl: req.TTL}, nil
+}
+
+func encodeGRPCDenylistEntryRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.DenylistEntryRequest)
+	return &pb.DenylistEntryRequest{Kind: req.Kind, Value: req.Value}, nil
+}
+
+func encodeGRPCListDeniedRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.ListDeniedRequest{}, nil
+}
+
+func decodeGRPCDenylistEntryResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.DenylistEntryResponse)
+	return vaultendpoint.DenylistEntryResponse{Entry: fromPBDenylistEntry(reply.Entry), Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCListDeniedResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.ListDeniedResponse)
+	entries := make([]denylist.Entry, len(reply.Entries))
+	for i, e := range reply.Entries {
+		entries[i] = fromPBDenylistEntry(e)
+	}
+	return vaultendpoint.ListDeniedResponse{Entries: entries, Err: str2err(reply.Err)}, nil
+}
+
+func toPBDenylistEntry(e denylist.Entry) *pb.DenylistEntry {
+	return &pb.DenylistEntry{
+		Kind:         e.Kind,
+		Value:        e.Value,
+		Reason:       e.Reason,
+		CreationTime: unixNano(e.CreationTime),
+		ExpireTime:   unixNano(e.ExpireTime),
+	}
+}
+
+func fromPBDenylistEntry(e *pb.DenylistEntry) denylist.Entry {
+	if e == nil {
+		return denylist.Entry{}
+	}
+	return denylist.Entry{
+		Kind:         e.Kind,
+		Value:        e.Value,
+		Reason:       e.Reason,
+		CreationTime: fromUnixNano(e.CreationTime),
+		ExpireTime:   fromUnixNano(e.ExpireTime),
+	}
+}
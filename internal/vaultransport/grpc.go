@@ -5,7 +5,6 @@ import (
 	"errors"
 	"time"
 
-	stdjwt "github.com/golang-jwt/jwt/v4"
 	"github.com/go-kit/kit/auth/jwt"
 	"github.com/go-kit/kit/circuitbreaker"
 	"github.com/go-kit/kit/endpoint"
@@ -20,49 +19,100 @@ import (
 	"github.com/sony/gobreaker"
 	"golang.org/x/time/rate"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 
+	"github.com/williamlsh/vault/internal/identity"
 	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaulterr"
 	"github.com/williamlsh/vault/internal/vaultservice"
 	"github.com/williamlsh/vault/pb"
 )
 
-const (
-	kid = "kid-header"
-	// A token's lifetime since issued.
-	tokExp = 1 * time.Second
-)
-
 type grpcServer struct {
-	hash     grpctransport.Handler
-	validate grpctransport.Handler
+	hash          grpctransport.Handler
+	validate      grpctransport.Handler
+	batchHash     *StreamServer
+	batchValidate *StreamServer
 }
 
-// NewGRPCServer makes a set of endpoints available as a gRPC VaultServer.
-func NewGRPCServer(endpoints vaultendpoint.Set, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) pb.VaultServer {
-	options := []grpctransport.ServerOption{
-		grpctransport.ServerBefore(jwt.GRPCToContext()),
-		grpctransport.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
-		zipkin.GRPCServerTrace(zipkinTracer),
+// NewGRPCServer makes a set of endpoints available as a gRPC VaultServer,
+// with the batch endpoints serving the items of its streams.
+func NewGRPCServer(endpoints vaultendpoint.Set, batch vaultendpoint.BatchSet, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) pb.VaultServer {
+	options := grpcServerOptions(zipkinTracer, logger)
+	streamOptions := []StreamServerOption{
+		StreamServerBefore(jwt.GRPCToContext(), remoteAddrToGRPCContext, certificateToGRPCContext),
+		StreamServerErrorHandler(transport.NewLogErrorHandler(logger)),
 	}
 
 	return &grpcServer{
 		hash: grpctransport.NewServer(
-			endpoints.HashEndpoint,
+			failedAsError(endpoints.HashEndpoint),
 			decodeGRPCHashRequest,
 			encodeGRPCHashResponse,
 			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, "Hash", logger)))...,
 		),
 		validate: grpctransport.NewServer(
-			endpoints.ValidateEndpoint,
+			failedAsError(endpoints.ValidateEndpoint),
 			decodeGRPCValidateRequest,
 			encodeGRPCValidateResponse,
 			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, "Validate", logger)))...,
 		),
+		batchHash: NewStreamServer(
+			batch.BatchHashEndpoint,
+			func() interface{} { return new(pb.BatchHashRequest) },
+			decodeGRPCBatchHashRequest,
+			encodeGRPCBatchHashResponse,
+			encodeGRPCBatchHashError,
+			append(streamOptions, StreamServerOpen(batch.OpenBatchHash))...,
+		),
+		batchValidate: NewStreamServer(
+			batch.BatchValidateEndpoint,
+			func() interface{} { return new(pb.BatchValidateRequest) },
+			decodeGRPCBatchValidateRequest,
+			encodeGRPCBatchValidateResponse,
+			encodeGRPCBatchValidateError,
+			append(streamOptions, StreamServerOpen(batch.OpenBatchValidate))...,
+		),
+	}
+}
+
+// grpcServerOptions returns the options shared by all gRPC servers.
+func grpcServerOptions(zipkinTracer *stdzipkin.Tracer, logger log.Logger) []grpctransport.ServerOption {
+	return []grpctransport.ServerOption{
+		grpctransport.ServerBefore(jwt.GRPCToContext()),
+		grpctransport.ServerBefore(remoteAddrToGRPCContext),
+		grpctransport.ServerBefore(certificateToGRPCContext),
+		grpctransport.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
+		zipkin.GRPCServerTrace(zipkinTracer),
+	}
+}
+
+// remoteAddrToGRPCContext moves the address of the peer into the context, for
+// auth methods bound to source addresses.
+func remoteAddrToGRPCContext(ctx context.Context, _ metadata.MD) context.Context {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return identity.WithRemoteAddr(ctx, p.Addr.String())
 	}
+	return ctx
+}
+
+// certificateToGRPCContext moves the identity of the verified client
+// certificate of the peer into the context, for authentication and audit.
+func certificateToGRPCContext(ctx context.Context, _ metadata.MD) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+		return identity.WithCertificate(ctx, info.State.VerifiedChains[0][0])
+	}
+	return ctx
 }
 
 // NewGRPCClient returns a VaultService backed by  a gRPC server at the other end of the conn. The caller is responsible for constructuring the conn, and eventually closing the underlying transport.
-func NewGRPCClient(conn *grpc.ClientConn, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) vaultservice.Service {
+func NewGRPCClient(conn *grpc.ClientConn, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) vaultservice.Service {
 	options := []grpctransport.ClientOption{
 		grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)),
 		grpctransport.ClientBefore(jwt.ContextToGRPC()),
@@ -72,16 +122,8 @@ func NewGRPCClient(conn *grpc.ClientConn, otTracer stdopentracing.Tracer, zipkin
 	// Client scop rate limiter.
 	limiter := ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Second), 100))
 
-	// Client scop JWT signer.
-	signer := jwt.NewSigner(
-		kid,
-		vaultendpoint.SigningKey,
-		stdjwt.SigningMethodHS256,
-		stdjwt.StandardClaims{
-			IssuedAt:  time.Now().Unix(),
-			ExpiresAt: time.Now().Add(tokExp).Unix(),
-		},
-	)
+	// Client scope token setter.
+	tokenSetter := newTokenSetter(tokens)
 
 	var hashEndpoint endpoint.Endpoint
 	{
@@ -94,9 +136,10 @@ func NewGRPCClient(conn *grpc.ClientConn, otTracer stdopentracing.Tracer, zipkin
 			pb.HashResponse{},
 			options...,
 		).Endpoint()
+		hashEndpoint = grpcErrorDecoder(hashEndpoint)
 		hashEndpoint = opentracing.TraceClient(otTracer, "Hash")(hashEndpoint)
 		hashEndpoint = zipkin.TraceEndpoint(zipkinTracer, "Hash")(hashEndpoint)
-		hashEndpoint = signer(hashEndpoint)
+		hashEndpoint = tokenSetter(hashEndpoint)
 		hashEndpoint = limiter(hashEndpoint)
 		hashEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
 			Name:    "Hash",
@@ -114,9 +157,10 @@ func NewGRPCClient(conn *grpc.ClientConn, otTracer stdopentracing.Tracer, zipkin
 			pb.ValidateResponse{},
 			options...,
 		).Endpoint()
+		validateEndpoint = grpcErrorDecoder(validateEndpoint)
 		validateEndpoint = opentracing.TraceClient(otTracer, "Validate")(validateEndpoint)
 		validateEndpoint = zipkin.TraceEndpoint(zipkinTracer, "Validate")(validateEndpoint)
-		validateEndpoint = signer(validateEndpoint)
+		validateEndpoint = tokenSetter(validateEndpoint)
 		validateEndpoint = limiter(validateEndpoint)
 		validateEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
 			Name:    "Validate",
@@ -133,7 +177,7 @@ func NewGRPCClient(conn *grpc.ClientConn, otTracer stdopentracing.Tracer, zipkin
 func (s *grpcServer) Hash(ctx context.Context, r *pb.HashRequest) (*pb.HashResponse, error) {
 	_, resp, err := s.hash.ServeGRPC(ctx, r)
 	if err != nil {
-		return nil, err
+		return nil, grpcError(err)
 	}
 	return resp.(*pb.HashResponse), nil
 }
@@ -141,7 +185,7 @@ func (s *grpcServer) Hash(ctx context.Context, r *pb.HashRequest) (*pb.HashRespo
 func (s *grpcServer) Validate(ctx context.Context, r *pb.ValidateRequest) (*pb.ValidateResponse, error) {
 	_, resp, err := s.validate.ServeGRPC(ctx, r)
 	if err != nil {
-		return nil, err
+		return nil, grpcError(err)
 	}
 	return resp.(*pb.ValidateResponse), nil
 }
@@ -187,7 +231,41 @@ func decodeGRPCHashResponse(_ context.Context, grpcReply interface{}) (interface
 
 func decodeGRPCValidateResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
 	reply := grpcReply.(*pb.ValidateResponse)
-	return vaultendpoint.ValidateResponse{Valid: reply.Valid, Err: str2err("")}, nil
+	return vaultendpoint.ValidateResponse{Valid: reply.Valid}, nil
+}
+
+// grpcError classifies err with its vaulterr code, which gRPC servers return
+// as the status of the call.
+func grpcError(err error) error {
+	return classify(err)
+}
+
+// failedAsError is a server endpoint middleware returning the errors of the
+// failed responses, so that they reach the clients as gRPC status errors
+// rather than as a string in the response.
+func failedAsError(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		resp, err := next(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		if f, ok := resp.(endpoint.Failer); ok && f.Failed() != nil {
+			return nil, f.Failed()
+		}
+		return resp, nil
+	}
+}
+
+// grpcErrorDecoder is a client endpoint middleware decoding the gRPC status
+// errors of the server into vaulterr errors.
+func grpcErrorDecoder(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		resp, err := next(ctx, request)
+		if err != nil {
+			return nil, vaulterr.FromGRPCError(err)
+		}
+		return resp, nil
+	}
 }
 
 func err2str(err error) string {
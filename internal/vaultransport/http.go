@@ -1,18 +1,16 @@
 package vaultransport
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"errors"
-	"io/ioutil"
+	"fmt"
 	"net/http"
-	"net/url"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
 
-	stdjwt "github.com/golang-jwt/jwt/v4"
 	"github.com/go-kit/kit/auth/jwt"
 	"github.com/go-kit/kit/circuitbreaker"
 	"github.com/go-kit/kit/endpoint"
@@ -21,96 +19,128 @@ import (
 	"github.com/go-kit/kit/tracing/opentracing"
 	"github.com/go-kit/kit/tracing/zipkin"
 	"github.com/go-kit/kit/transport"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
 	httptransport "github.com/go-kit/kit/transport/http"
 	stdopentracing "github.com/opentracing/opentracing-go"
 	stdzipkin "github.com/openzipkin/zipkin-go"
 	"github.com/sony/gobreaker"
 	"golang.org/x/time/rate"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/williamlsh/vault/internal/denylist"
+	"github.com/williamlsh/vault/internal/gateway"
+	"github.com/williamlsh/vault/internal/identity"
+	"github.com/williamlsh/vault/internal/jwks"
+	"github.com/williamlsh/vault/internal/lease"
+	"github.com/williamlsh/vault/internal/oauth"
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/replay"
+	"github.com/williamlsh/vault/internal/seal"
+	"github.com/williamlsh/vault/internal/token"
 	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaulterr"
 	"github.com/williamlsh/vault/internal/vaultservice"
+	"github.com/williamlsh/vault/pb"
 )
 
-// NewHTTPHandler returns an HTTP handler thant makes a set of endpoints
-// available on predefined paths.
-func NewHTTPHandler(endpoints vaultendpoint.Set, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) http.Handler {
+// NewHTTPHandler returns an HTTP handler serving the REST API of the
+// google.api.http annotations of the proto with the gateway, under /v1/ and
+// on the same paths without the version, and its OpenAPI document at
+// /openapi.json. Only the endpoints whose format is not the one of the
+// gateway are served by their own handlers: the health check with the status
+// codes of the seal status, the OAuth2 token, introspection and revocation
+// endpoints with the form encoded requests of their RFCs, and the SSH CA
+// public key as plain text.
+func NewHTTPHandler(sys vaultendpoint.SysSet, oauth vaultendpoint.OAuthSet, sshs vaultendpoint.SSHSet, wrapping vaultendpoint.WrappingSet, gw *gateway.Gateway, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) http.Handler {
 	options := []httptransport.ServerOption{
 		httptransport.ServerBefore(jwt.HTTPToContext()),
+		httptransport.ServerBefore(remoteAddrToHTTPContext),
+		httptransport.ServerBefore(certificateToHTTPContext),
 		httptransport.ServerErrorEncoder(errorEncoder),
 		httptransport.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
 		zipkin.HTTPServerTrace(zipkinTracer),
 	}
 
 	m := http.NewServeMux()
-	m.Handle("/hash", httptransport.NewServer(
-		endpoints.HashEndpoint,
-		decodeHTTPHashRequest,
-		encodeHTTPGenericResponse,
-		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(otTracer, "Hash", logger)))...,
-	))
-	m.Handle("/validate", httptransport.NewServer(
-		endpoints.ValidateEndpoint,
-		decodeHTTPValidateRequest,
-		encodeHTTPGenericResponse,
-		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(otTracer, "Validate", logger)))...,
-	))
-	return m
+	registerSysHandlers(m, sys, options, otTracer, logger)
+	registerOAuthHandlers(m, oauth, options, otTracer, logger)
+	registerSSHHandlers(m, sshs, options, otTracer, logger)
+	m.Handle(apiPrefix+"/", gw)
+	m.Handle("/", gw.Unversioned(apiPrefix))
+	m.Handle("/openapi.json", gw.OpenAPIHandler("vault", "v1"))
+	return wrapHTTPResponses(m, wrapping.WrapResponseEndpoint)
+}
+
+// apiPrefix is the path prefix of the version of the REST API.
+const apiPrefix = "/v1"
+
+// methodMux dispatches requests to a handler by HTTP method, answering 405
+// for methods it does not know.
+type methodMux map[string]http.Handler
+
+func (m methodMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h, ok := m[r.Method]; ok {
+		h.ServeHTTP(w, r)
+		return
+	}
+	allowed := make([]string, 0, len(m))
+	for method := range m {
+		allowed = append(allowed, method)
+	}
+	sort.Strings(allowed)
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	w.WriteHeader(http.StatusMethodNotAllowed)
+}
+
+// remoteAddrToHTTPContext moves the client address of the request into the
+// context, for auth methods bound to source addresses.
+func remoteAddrToHTTPContext(ctx context.Context, r *http.Request) context.Context {
+	return identity.WithRemoteAddr(ctx, r.RemoteAddr)
+}
+
+// certificateToHTTPContext moves the identity of the verified client
+// certificate of the request into the context, for authentication and audit.
+func certificateToHTTPContext(ctx context.Context, r *http.Request) context.Context {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return identity.WithCertificate(ctx, r.TLS.VerifiedChains[0][0])
+	}
+	return ctx
 }
 
 // NewHTTPClient returns an VaultService backed by an HTTP server living at the
 // remote instance. We expect instance to come from a service discovery system,
 // so likely of the form "host:port". We bake-in certain middleware,
 // implementing the client library pattern.
-func NewHTTPClient(instance string, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) (vaultservice.Service, error) {
-	if !strings.HasPrefix(instance, "https") {
-		instance = "https://" + instance
-	}
-	u, err := url.Parse(instance)
+func NewHTTPClient(instance string, tlsOpts ClientTLS, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) (vaultservice.Service, error) {
+	c, err := gatewayClient(instance, tlsOpts)
 	if err != nil {
 		return nil, err
 	}
-
-	// Use customized http client, especially useful for localhost TLS test.
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		},
-	}
-
-	options := []httptransport.ClientOption{
-		httptransport.ClientBefore(opentracing.ContextToHTTP(otTracer, logger)),
-		httptransport.ClientBefore(jwt.ContextToHTTP()),
-		httptransport.SetClient(client),
-		zipkin.HTTPClientTrace(zipkinTracer),
+	before := []grpctransport.ClientRequestFunc{
+		opentracing.ContextToGRPC(otTracer, logger),
+		jwt.ContextToGRPC(),
 	}
 
 	limiter := ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Second), 100))
 
-	// Client scope JWT signer.
-	jwtSigner := jwt.NewSigner(
-		kid,
-		vaultendpoint.SigningKey,
-		stdjwt.SigningMethodHS256,
-		stdjwt.StandardClaims{
-			IssuedAt:  time.Now().Unix(),
-			ExpiresAt: time.Now().Add(tokExp).Unix(),
-		},
-	)
+	// Client scope token setter.
+	tokenSetter := newTokenSetter(tokens)
 
 	var hashEndpoint endpoint.Endpoint
 	{
-		hashEndpoint = httptransport.NewClient(
-			"POST",
-			copyURL(u, "/hash"),
-			encodeHTTPGenericRequest,
-			decodeHTTPHashResponse,
-			options...,
-		).Endpoint()
+		hashEndpoint = newGatewayEndpoint(
+			c,
+			"pb.Vault",
+			"Hash",
+			encodeGRPCHashRequest,
+			decodeGRPCHashResponse,
+			pb.HashResponse{},
+			before...,
+		)
 		hashEndpoint = opentracing.TraceClient(otTracer, "Hash")(hashEndpoint)
 		hashEndpoint = zipkin.TraceEndpoint(zipkinTracer, "Hash")(hashEndpoint)
-		hashEndpoint = jwtSigner(hashEndpoint)
+		hashEndpoint = tokenSetter(hashEndpoint)
 		hashEndpoint = limiter(hashEndpoint)
 		hashEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
 			Name:    "Hash",
@@ -119,16 +149,18 @@ func NewHTTPClient(instance string, otTracer stdopentracing.Tracer, zipkinTracer
 	}
 	var validateEndpoint endpoint.Endpoint
 	{
-		validateEndpoint = httptransport.NewClient(
-			"POST",
-			copyURL(u, "/validate"),
-			encodeHTTPGenericRequest,
-			decodeHTTPValidateResponse,
-			options...,
-		).Endpoint()
+		validateEndpoint = newGatewayEndpoint(
+			c,
+			"pb.Vault",
+			"Validate",
+			encodeGRPCValidateRequest,
+			decodeGRPCValidateResponse,
+			pb.ValidateResponse{},
+			before...,
+		)
 		validateEndpoint = opentracing.TraceClient(otTracer, "Validate")(validateEndpoint)
 		validateEndpoint = zipkin.TraceEndpoint(zipkinTracer, "Validate")(validateEndpoint)
-		validateEndpoint = jwtSigner(validateEndpoint)
+		validateEndpoint = tokenSetter(validateEndpoint)
 		validateEndpoint = limiter(validateEndpoint)
 		validateEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
 			Name:    "Validate",
@@ -141,88 +173,108 @@ func NewHTTPClient(instance string, otTracer stdopentracing.Tracer, zipkinTracer
 	}, nil
 }
 
-func copyURL(base *url.URL, path string) *url.URL {
-	next := *base
-	next.Path = path
-	return &next
-}
-
-func errorEncoder(_ context.Context, err error, w http.ResponseWriter) {
-	w.WriteHeader(err2code(err))
-	json.NewEncoder(w).Encode(errorWrapper{Error: err.Error()})
-}
-
-func err2code(err error) int {
-	// In practical use case, a error switch will be used.
-	return http.StatusInternalServerError
-}
-
-func errDecoder(r *http.Response) error {
-	var w errorWrapper
-	if err := json.NewDecoder(r.Body).Decode(&w); err != nil {
-		return err
+// gatewayClient returns a client of the gateway of the remote instance.
+func gatewayClient(instance string, tlsOpts ClientTLS) (*gateway.Client, error) {
+	u, client, err := httpClient(instance, tlsOpts)
+	if err != nil {
+		return nil, err
 	}
-	return errors.New(w.Error)
+	return gateway.NewClient(u, client), nil
 }
 
-type errorWrapper struct {
-	Error string `json:"error"`
-}
-
-// decodeHTTPHashRequest is a transport/http.DecodeRequestFunc that decodes a
-// JSON-encoded hash request from the HTTP request body. Primarily useful in a
-// server.
-func decodeHTTPHashRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	var req vaultendpoint.HashRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	return req, err
+// newGatewayEndpoint returns a client endpoint calling the method of the gRPC
+// service through the gateway, with the request and reply conversions of the
+// gRPC clients. The before funcs set the outgoing metadata of the call, as
+// the options of the gRPC clients do.
+func newGatewayEndpoint(c *gateway.Client, service, method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}, before ...grpctransport.ClientRequestFunc) endpoint.Endpoint {
+	fullMethod := fmt.Sprintf("/%s/%s", service, method)
+	replyType := reflect.Indirect(reflect.ValueOf(reply)).Type()
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, err := enc(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		md := metadata.MD{}
+		for _, f := range before {
+			ctx = f(ctx, &md)
+		}
+		r := reflect.New(replyType).Interface().(proto.Message)
+		if err := c.Invoke(metadata.NewOutgoingContext(ctx, md), fullMethod, req.(proto.Message), r); err != nil {
+			return nil, err
+		}
+		return dec(ctx, r)
+	}
 }
 
-func decodeHTTPValidateRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	var req vaultendpoint.ValidateRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	return req, err
+// httpsURL returns the URL of the instance, defaulting to the https scheme
+// when the instance has none, such as "host:port".
+func httpsURL(instance string) string {
+	if !strings.Contains(instance, "://") {
+		instance = "https://" + instance
+	}
+	return instance
 }
 
-// decodeHTTPHashResponse is a transport/http.DecodeResponseFunc that decodes a
-// JSON-encoded hash response from the HTTP response body. If the response has
-// a non-200 status code, we will interpret that as an error and attempt to
-// decode the specific error message form the response body. Primarily useful
-// in a client.
-func decodeHTTPHashResponse(_ context.Context, r *http.Response) (interface{}, error) {
-	if r.StatusCode != http.StatusOK {
-		return nil, errors.New(r.Status)
-	}
-	var resp vaultendpoint.HashResponse
-	err := json.NewDecoder(r.Body).Decode(&resp)
-	return resp, err
+// errorEncoder writes err as problem details (RFC 7807), with the HTTP status
+// of its code.
+func errorEncoder(_ context.Context, err error, w http.ResponseWriter) {
+	p := vaulterr.ProblemOf(classify(err))
+	w.Header().Set("Content-Type", vaulterr.ProblemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
 }
 
-func decodeHTTPValidateResponse(_ context.Context, r *http.Response) (interface{}, error) {
-	if r.StatusCode != http.StatusOK {
-		return nil, errors.New(r.Status)
-	}
-	var resp vaultendpoint.ValidateResponse
-	err := json.NewDecoder(r.Body).Decode(&resp)
-	return resp, err
+// classify returns err classified with its vaulterr code.
+func classify(err error) error {
+	return vaulterr.Wrap(errorCode(err), err)
 }
 
-// encodeHTTPGenericRequest is a transport/http.DecodeRequestFunc that
-// JSON-encodes any request to the request body. Primarily useful in a client.
-func encodeHTTPGenericRequest(_ context.Context, r *http.Request, request interface{}) error {
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(request); err != nil {
-		return err
+// errorCode returns the vaulterr code of the errors of the services and of
+// the middlewares refusing requests before they reach them.
+func errorCode(err error) vaulterr.Code {
+	var e *vaulterr.Error
+	switch {
+	case errors.As(err, &e):
+		return e.Code
+	case errors.Is(err, seal.ErrSealed), errors.Is(err, seal.ErrNotInitialized):
+		return vaulterr.Unavailable
+	case errors.Is(err, seal.ErrInvalidKey), errors.Is(err, seal.ErrInvalidConfig), errors.Is(err, seal.ErrAlreadyInitialized):
+		return vaulterr.InvalidArgument
+	case isAuthError(err):
+		return vaulterr.Unauthenticated
+	case errors.Is(err, policy.ErrPermissionDenied):
+		return vaulterr.PermissionDenied
+	case errors.Is(err, policy.ErrInvalidPolicy), errors.Is(err, policy.ErrBuiltin), errors.Is(err, policy.ErrInvalidSubject):
+		return vaulterr.InvalidArgument
+	case errors.Is(err, vaultservice.ErrNotFound), errors.Is(err, policy.ErrNotFound), errors.Is(err, lease.ErrNotFound),
+		errors.Is(err, token.ErrNotFound):
+		return vaulterr.NotFound
+	case errors.Is(err, vaultservice.ErrCASMismatch), errors.Is(err, vaultservice.ErrCASRequired),
+		errors.Is(err, vaultservice.ErrInvalidPath), errors.Is(err, vaultservice.ErrInvalidArgument),
+		errors.Is(err, vaultservice.ErrInvalidWrappingToken):
+		return vaulterr.InvalidArgument
+	case errors.Is(err, lease.ErrNotRenewable), errors.Is(err, lease.ErrInvalidPrefix), errors.Is(err, vaultservice.ErrTokenLease):
+		return vaulterr.InvalidArgument
+	case errors.Is(err, vaultservice.ErrRoleNotFound), errors.Is(err, vaultservice.ErrUserNotFound),
+		errors.Is(err, vaultservice.ErrClientNotFound):
+		return vaulterr.NotFound
+	case errors.Is(err, vaultservice.ErrInvalidCredentials):
+		return vaulterr.InvalidArgument
+	case errors.Is(err, denylist.ErrInvalidEntry):
+		return vaulterr.InvalidArgument
+	case errors.Is(err, denylist.ErrNotFound):
+		return vaulterr.NotFound
+	case errors.Is(err, ratelimit.ErrLimited):
+		return vaulterr.ResourceExhausted
+	case errors.Is(err, gobreaker.ErrOpenState), errors.Is(err, gobreaker.ErrTooManyRequests):
+		return vaulterr.Unavailable
 	}
-	r.Body = ioutil.NopCloser(&buf)
-	return nil
+	return vaulterr.Internal
 }
 
-func encodeHTTPGenericResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
-	if f, ok := response.(endpoint.Failer); ok && f.Failed() != nil {
-		errorEncoder(ctx, f.Failed(), w)
-		return nil
-	}
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	return json.NewEncoder(w).Encode(response)
+// isAuthError reports whether err is an authentication failure.
+func isAuthError(err error) bool {
+	return errors.Is(err, token.ErrMissingToken) || errors.Is(err, token.ErrInvalidToken) ||
+		errors.Is(err, oauth.ErrInvalidToken) || errors.Is(err, jwks.ErrInvalidToken) ||
+		errors.Is(err, denylist.ErrDenied) || errors.Is(err, replay.ErrReplayed) || errors.Is(err, replay.ErrMissingID)
 }
@@ -0,0 +1,428 @@
+package vaultransport
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+	"github.com/sony/gobreaker"
+	"google.golang.org/grpc"
+
+	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaultservice"
+	"github.com/williamlsh/vault/pb"
+)
+
+// NewHTTPKVClient returns a KVService backed by an HTTP server living at the
+// remote instance.
+func NewHTTPKVClient(instance string, tlsOpts ClientTLS, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) (vaultservice.KVService, error) {
+	c, err := gatewayClient(instance, tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+	before := []grpctransport.ClientRequestFunc{
+		opentracing.ContextToGRPC(otTracer, logger),
+		jwt.ContextToGRPC(),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method, name string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = newGatewayEndpoint(c, "pb.KV", method, enc, dec, reply, before...)
+		e = opentracing.TraceClient(otTracer, name)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, name)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    name,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.KVSet{
+		PutEndpoint:            endpointFor("Put", "KVPut", encodeGRPCKVPutRequest, decodeGRPCKVPutResponse, pb.KVPutResponse{}),
+		GetEndpoint:            endpointFor("Get", "KVGet", encodeGRPCKVGetRequest, decodeGRPCKVGetResponse, pb.KVGetResponse{}),
+		DeleteEndpoint:         endpointFor("Delete", "KVDelete", encodeGRPCKVVersionsRequest, decodeGRPCKVResponse, pb.KVResponse{}),
+		UndeleteEndpoint:       endpointFor("Undelete", "KVUndelete", encodeGRPCKVVersionsRequest, decodeGRPCKVResponse, pb.KVResponse{}),
+		DestroyEndpoint:        endpointFor("Destroy", "KVDestroy", encodeGRPCKVVersionsRequest, decodeGRPCKVResponse, pb.KVResponse{}),
+		ListEndpoint:           endpointFor("List", "KVList", encodeGRPCKVListRequest, decodeGRPCKVListResponse, pb.KVListResponse{}),
+		ReadMetadataEndpoint:   endpointFor("ReadMetadata", "KVReadMetadata", encodeGRPCKVMetadataRequest, decodeGRPCKVMetadataResponse, pb.KVMetadataResponse{}),
+		WriteMetadataEndpoint:  endpointFor("WriteMetadata", "KVWriteMetadata", encodeGRPCKVWriteMetadataRequest, decodeGRPCKVResponse, pb.KVResponse{}),
+		DeleteMetadataEndpoint: endpointFor("DeleteMetadata", "KVDeleteMetadata", encodeGRPCKVMetadataRequest, decodeGRPCKVResponse, pb.KVResponse{}),
+	}, nil
+}
+
+type grpcKVServer struct {
+	put            grpctransport.Handler
+	get            grpctransport.Handler
+	delete         grpctransport.Handler
+	undelete       grpctransport.Handler
+	destroy        grpctransport.Handler
+	list           grpctransport.Handler
+	readMetadata   grpctransport.Handler
+	writeMetadata  grpctransport.Handler
+	deleteMetadata grpctransport.Handler
+}
+
+// NewGRPCKVServer makes the key-value endpoints available as a gRPC
+// KVServer.
+func NewGRPCKVServer(endpoints vaultendpoint.KVSet, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) pb.KVServer {
+	options := grpcServerOptions(zipkinTracer, logger)
+	handler := func(name string, e endpoint.Endpoint, dec grpctransport.DecodeRequestFunc, enc grpctransport.EncodeResponseFunc) grpctransport.Handler {
+		return grpctransport.NewServer(
+			failedAsError(e),
+			dec,
+			enc,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, name, logger)))...,
+		)
+	}
+	return &grpcKVServer{
+		put:            handler("KVPut", endpoints.PutEndpoint, decodeGRPCKVPutRequest, encodeGRPCKVPutResponse),
+		get:            handler("KVGet", endpoints.GetEndpoint, decodeGRPCKVGetRequest, encodeGRPCKVGetResponse),
+		delete:         handler("KVDelete", endpoints.DeleteEndpoint, decodeGRPCKVVersionsRequest, encodeGRPCKVResponse),
+		undelete:       handler("KVUndelete", endpoints.UndeleteEndpoint, decodeGRPCKVVersionsRequest, encodeGRPCKVResponse),
+		destroy:        handler("KVDestroy", endpoints.DestroyEndpoint, decodeGRPCKVVersionsRequest, encodeGRPCKVResponse),
+		list:           handler("KVList", endpoints.ListEndpoint, decodeGRPCKVListRequest, encodeGRPCKVListResponse),
+		readMetadata:   handler("KVReadMetadata", endpoints.ReadMetadataEndpoint, decodeGRPCKVMetadataRequest, encodeGRPCKVMetadataResponse),
+		writeMetadata:  handler("KVWriteMetadata", endpoints.WriteMetadataEndpoint, decodeGRPCKVWriteMetadataRequest, encodeGRPCKVResponse),
+		deleteMetadata: handler("KVDeleteMetadata", endpoints.DeleteMetadataEndpoint, decodeGRPCKVMetadataRequest, encodeGRPCKVResponse),
+	}
+}
+
+// NewGRPCKVClient returns a KVService backed by a gRPC server at the other
+// end of the conn.
+func NewGRPCKVClient(conn *grpc.ClientConn, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) vaultservice.KVService {
+	options := []grpctransport.ClientOption{
+		grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)),
+		grpctransport.ClientBefore(jwt.ContextToGRPC()),
+		zipkin.GRPCClientTrace(zipkinTracer),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method, name string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = grpctransport.NewClient(conn, "pb.KV", method, enc, dec, reply, options...).Endpoint()
+		e = grpcErrorDecoder(e)
+		e = opentracing.TraceClient(otTracer, name)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, name)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    name,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.KVSet{
+		PutEndpoint:            endpointFor("Put", "KVPut", encodeGRPCKVPutRequest, decodeGRPCKVPutResponse, pb.KVPutResponse{}),
+		GetEndpoint:            endpointFor("Get", "KVGet", encodeGRPCKVGetRequest, decodeGRPCKVGetResponse, pb.KVGetResponse{}),
+		DeleteEndpoint:         endpointFor("Delete", "KVDelete", encodeGRPCKVVersionsRequest, decodeGRPCKVResponse, pb.KVResponse{}),
+		UndeleteEndpoint:       endpointFor("Undelete", "KVUndelete", encodeGRPCKVVersionsRequest, decodeGRPCKVResponse, pb.KVResponse{}),
+		DestroyEndpoint:        endpointFor("Destroy", "KVDestroy", encodeGRPCKVVersionsRequest, decodeGRPCKVResponse, pb.KVResponse{}),
+		ListEndpoint:           endpointFor("List", "KVList", encodeGRPCKVListRequest, decodeGRPCKVListResponse, pb.KVListResponse{}),
+		ReadMetadataEndpoint:   endpointFor("ReadMetadata", "KVReadMetadata", encodeGRPCKVMetadataRequest, decodeGRPCKVMetadataResponse, pb.KVMetadataResponse{}),
+		WriteMetadataEndpoint:  endpointFor("WriteMetadata", "KVWriteMetadata", encodeGRPCKVWriteMetadataRequest, decodeGRPCKVResponse, pb.KVResponse{}),
+		DeleteMetadataEndpoint: endpointFor("DeleteMetadata", "KVDeleteMetadata", encodeGRPCKVMetadataRequest, decodeGRPCKVResponse, pb.KVResponse{}),
+	}
+}
+
+func (s *grpcKVServer) Put(ctx context.Context, r *pb.KVPutRequest) (*pb.KVPutResponse, error) {
+	_, resp, err := s.put.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.KVPutResponse), nil
+}
+
+func (s *grpcKVServer) Get(ctx context.Context, r *pb.KVGetRequest) (*pb.KVGetResponse, error) {
+	_, resp, err := s.get.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.KVGetResponse), nil
+}
+
+func (s *grpcKVServer) Delete(ctx context.Context, r *pb.KVVersionsRequest) (*pb.KVResponse, error) {
+	_, resp, err := s.delete.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.KVResponse), nil
+}
+
+func (s *grpcKVServer) Undelete(ctx context.Context, r *pb.KVVersionsRequest) (*pb.KVResponse, error) {
+	_, resp, err := s.undelete.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.KVResponse), nil
+}
+
+func (s *grpcKVServer) Destroy(ctx context.Context, r *pb.KVVersionsRequest) (*pb.KVResponse, error) {
+	_, resp, err := s.destroy.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.KVResponse), nil
+}
+
+func (s *grpcKVServer) List(ctx context.Context, r *pb.KVListRequest) (*pb.KVListResponse, error) {
+	_, resp, err := s.list.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.KVListResponse), nil
+}
+
+func (s *grpcKVServer) ReadMetadata(ctx context.Context, r *pb.KVMetadataRequest) (*pb.KVMetadataResponse, error) {
+	_, resp, err := s.readMetadata.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.KVMetadataResponse), nil
+}
+
+func (s *grpcKVServer) WriteMetadata(ctx context.Context, r *pb.KVWriteMetadataRequest) (*pb.KVResponse, error) {
+	_, resp, err := s.writeMetadata.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.KVResponse), nil
+}
+
+func (s *grpcKVServer) DeleteMetadata(ctx context.Context, r *pb.KVMetadataRequest) (*pb.KVResponse, error) {
+	_, resp, err := s.deleteMetadata.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.KVResponse), nil
+}
+
+func decodeGRPCKVPutRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.KVPutRequest)
+	r := vaultendpoint.KVPutRequest{Path: req.Path, Data: req.Data}
+	if req.HasCas {
+		cas := int(req.Cas)
+		r.Options.CAS = &cas
+	}
+	return r, nil
+}
+
+func decodeGRPCKVGetRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.KVGetRequest)
+	return vaultendpoint.KVGetRequest{Path: req.Path, Version: int(req.Version)}, nil
+}
+
+func decodeGRPCKVVersionsRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.KVVersionsRequest)
+	versions := make([]int, len(req.Versions))
+	for i, v := range req.Versions {
+		versions[i] = int(v)
+	}
+	return vaultendpoint.KVVersionsRequest{Path: req.Path, Versions: versions}, nil
+}
+
+func decodeGRPCKVListRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.KVListRequest)
+	return vaultendpoint.KVListRequest{Path: req.Path}, nil
+}
+
+func decodeGRPCKVMetadataRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.KVMetadataRequest)
+	return vaultendpoint.KVMetadataRequest{Path: req.Path}, nil
+}
+
+func decodeGRPCKVWriteMetadataRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.KVWriteMetadataRequest)
+	return vaultendpoint.KVWriteMetadataRequest{
+		Path: req.Path,
+		KVMetadataConfig: vaultservice.KVMetadataConfig{
+			MaxVersions:        int(req.MaxVersions),
+			CASRequired:        req.CasRequired,
+			CustomMetadata:     req.CustomMetadata,
+			DeleteVersionAfter: req.DeleteVersionAfter,
+		},
+	}, nil
+}
+
+func encodeGRPCKVPutResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.KVPutResponse)
+	return &pb.KVPutResponse{Metadata: pbKVVersionMetadata(resp.KVVersionMetadata), Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCKVGetResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.KVGetResponse)
+	return &pb.KVGetResponse{Data: resp.Data, Metadata: pbKVVersionMetadata(resp.Metadata), Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCKVListResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.KVListResponse)
+	return &pb.KVListResponse{Keys: resp.Keys, Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCKVMetadataResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.KVMetadataResponse)
+	reply := &pb.KVMetadataResponse{
+		MaxVersions:        int32(resp.MaxVersions),
+		CasRequired:        resp.CASRequired,
+		CustomMetadata:     resp.CustomMetadata,
+		CurrentVersion:     int32(resp.CurrentVersion),
+		OldestVersion:      int32(resp.OldestVersion),
+		CreatedTime:        unixNano(resp.CreatedTime),
+		UpdatedTime:        unixNano(resp.UpdatedTime),
+		Err:                err2str(resp.Err),
+		DeleteVersionAfter: resp.DeleteVersionAfter,
+	}
+	for _, vm := range resp.Versions {
+		reply.Versions = append(reply.Versions, pbKVVersionMetadata(vm))
+	}
+	sort.Slice(reply.Versions, func(i, j int) bool { return reply.Versions[i].Version < reply.Versions[j].Version })
+	return reply, nil
+}
+
+func encodeGRPCKVResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.KVResponse)
+	return &pb.KVResponse{Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCKVPutRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.KVPutRequest)
+	r := &pb.KVPutRequest{Path: req.Path, Data: req.Data}
+	if req.Options.CAS != nil {
+		r.HasCas = true
+		r.Cas = int32(*req.Options.CAS)
+	}
+	return r, nil
+}
+
+func encodeGRPCKVGetRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.KVGetRequest)
+	return &pb.KVGetRequest{Path: req.Path, Version: int32(req.Version)}, nil
+}
+
+func encodeGRPCKVVersionsRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.KVVersionsRequest)
+	versions := make([]int32, len(req.Versions))
+	for i, v := range req.Versions {
+		versions[i] = int32(v)
+	}
+	return &pb.KVVersionsRequest{Path: req.Path, Versions: versions}, nil
+}
+
+func encodeGRPCKVListRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.KVListRequest)
+	return &pb.KVListRequest{Path: req.Path}, nil
+}
+
+func encodeGRPCKVMetadataRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.KVMetadataRequest)
+	return &pb.KVMetadataRequest{Path: req.Path}, nil
+}
+
+func encodeGRPCKVWriteMetadataRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.KVWriteMetadataRequest)
+	return &pb.KVWriteMetadataRequest{
+		Path:               req.Path,
+		MaxVersions:        int32(req.MaxVersions),
+		CasRequired:        req.CASRequired,
+		CustomMetadata:     req.CustomMetadata,
+		DeleteVersionAfter: req.DeleteVersionAfter,
+	}, nil
+}
+
+func decodeGRPCKVPutResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.KVPutResponse)
+	return vaultendpoint.KVPutResponse{KVVersionMetadata: kvVersionMetadata(reply.Metadata), Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCKVGetResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.KVGetResponse)
+	return vaultendpoint.KVGetResponse{
+		KVSecret: vaultservice.KVSecret{Data: reply.Data, Metadata: kvVersionMetadata(reply.Metadata)},
+		Err:      str2err(reply.Err),
+	}, nil
+}
+
+func decodeGRPCKVListResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.KVListResponse)
+	return vaultendpoint.KVListResponse{Keys: reply.Keys, Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCKVMetadataResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.KVMetadataResponse)
+	md := vaultservice.KVMetadata{
+		KVMetadataConfig: vaultservice.KVMetadataConfig{
+			MaxVersions:        int(reply.MaxVersions),
+			CASRequired:        reply.CasRequired,
+			CustomMetadata:     reply.CustomMetadata,
+			DeleteVersionAfter: reply.DeleteVersionAfter,
+		},
+		CurrentVersion: int(reply.CurrentVersion),
+		OldestVersion:  int(reply.OldestVersion),
+		CreatedTime:    fromUnixNano(reply.CreatedTime),
+		UpdatedTime:    fromUnixNano(reply.UpdatedTime),
+		Versions:       make(map[int]vaultservice.KVVersionMetadata, len(reply.Versions)),
+	}
+	for _, vm := range reply.Versions {
+		md.Versions[int(vm.Version)] = kvVersionMetadata(vm)
+	}
+	return vaultendpoint.KVMetadataResponse{KVMetadata: md, Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCKVResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.KVResponse)
+	return vaultendpoint.KVResponse{Err: str2err(reply.Err)}, nil
+}
+
+func pbKVVersionMetadata(vm vaultservice.KVVersionMetadata) *pb.KVVersionMetadata {
+	m := &pb.KVVersionMetadata{
+		Version:     int32(vm.Version),
+		CreatedTime: unixNano(vm.CreatedTime),
+		Destroyed:   vm.Destroyed,
+		LeaseId:     vm.LeaseID,
+	}
+	if vm.DeletionTime != nil {
+		m.DeletionTime = unixNano(*vm.DeletionTime)
+	}
+	return m
+}
+
+func kvVersionMetadata(m *pb.KVVersionMetadata) vaultservice.KVVersionMetadata {
+	if m == nil {
+		return vaultservice.KVVersionMetadata{}
+	}
+	vm := vaultservice.KVVersionMetadata{
+		Version:     int(m.Version),
+		CreatedTime: fromUnixNano(m.CreatedTime),
+		Destroyed:   m.Destroyed,
+		LeaseID:     m.LeaseId,
+	}
+	if m.DeletionTime != 0 {
+		t := fromUnixNano(m.DeletionTime)
+		vm.DeletionTime = &t
+	}
+	return vm
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n).UTC()
+}
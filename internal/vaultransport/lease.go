@@ -0,0 +1,226 @@
+package vaultransport
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+	"github.com/sony/gobreaker"
+	"google.golang.org/grpc"
+
+	"github.com/williamlsh/vault/internal/lease"
+	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaultservice"
+	"github.com/williamlsh/vault/pb"
+)
+
+// NewHTTPLeaseClient returns a LeaseService backed by an HTTP server living
+// at the remote instance.
+func NewHTTPLeaseClient(instance string, tlsOpts ClientTLS, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) (vaultservice.LeaseService, error) {
+	c, err := gatewayClient(instance, tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+	before := []grpctransport.ClientRequestFunc{
+		opentracing.ContextToGRPC(otTracer, logger),
+		jwt.ContextToGRPC(),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = newGatewayEndpoint(c, "pb.Lease", method, enc, dec, reply, before...)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.LeaseSet{
+		LookupEndpoint:       endpointFor("Lookup", encodeGRPCLeaseRequest, decodeGRPCLeaseResponse, pb.LeaseResponse{}),
+		RenewEndpoint:        endpointFor("Renew", encodeGRPCRenewLeaseRequest, decodeGRPCLeaseResponse, pb.LeaseResponse{}),
+		RevokeEndpoint:       endpointFor("Revoke", encodeGRPCLeaseRequest, decodeGRPCLeaseResponse, pb.LeaseResponse{}),
+		RevokePrefixEndpoint: endpointFor("RevokePrefix", encodeGRPCLeasePrefixRequest, decodeGRPCLeaseResponse, pb.LeaseResponse{}),
+		ListEndpoint:         endpointFor("List", encodeGRPCLeasePrefixRequest, decodeGRPCListLeasesResponse, pb.ListLeasesResponse{}),
+	}, nil
+}
+
+type grpcLeaseServer struct {
+	lookup       grpctransport.Handler
+	renew        grpctransport.Handler
+	revoke       grpctransport.Handler
+	revokePrefix grpctransport.Handler
+	list         grpctransport.Handler
+}
+
+// NewGRPCLeaseServer makes the lease endpoints available as a gRPC
+// LeaseServer.
+func NewGRPCLeaseServer(endpoints vaultendpoint.LeaseSet, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) pb.LeaseServer {
+	options := grpcServerOptions(zipkinTracer, logger)
+	handler := func(name string, e endpoint.Endpoint, dec grpctransport.DecodeRequestFunc, enc grpctransport.EncodeResponseFunc) grpctransport.Handler {
+		return grpctransport.NewServer(
+			failedAsError(e),
+			dec,
+			enc,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, name, logger)))...,
+		)
+	}
+	return &grpcLeaseServer{
+		lookup:       handler("LeaseLookup", endpoints.LookupEndpoint, decodeGRPCLeaseRequest, encodeGRPCLeaseResponse),
+		renew:        handler("LeaseRenew", endpoints.RenewEndpoint, decodeGRPCRenewLeaseRequest, encodeGRPCLeaseResponse),
+		revoke:       handler("LeaseRevoke", endpoints.RevokeEndpoint, decodeGRPCLeaseRequest, encodeGRPCLeaseResponse),
+		revokePrefix: handler("LeaseRevokePrefix", endpoints.RevokePrefixEndpoint, decodeGRPCLeasePrefixRequest, encodeGRPCLeaseResponse),
+		list:         handler("LeaseList", endpoints.ListEndpoint, decodeGRPCLeasePrefixRequest, encodeGRPCListLeasesResponse),
+	}
+}
+
+// NewGRPCLeaseClient returns a LeaseService backed by a gRPC server at the
+// other end of the conn.
+func NewGRPCLeaseClient(conn *grpc.ClientConn, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) vaultservice.LeaseService {
+	options := []grpctransport.ClientOption{
+		grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)),
+		grpctransport.ClientBefore(jwt.ContextToGRPC()),
+		zipkin.GRPCClientTrace(zipkinTracer),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = grpctransport.NewClient(conn, "pb.Lease", method, enc, dec, reply, options...).Endpoint()
+		e = grpcErrorDecoder(e)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.LeaseSet{
+		LookupEndpoint:       endpointFor("Lookup", encodeGRPCLeaseRequest, decodeGRPCLeaseResponse, pb.LeaseResponse{}),
+		RenewEndpoint:        endpointFor("Renew", encodeGRPCRenewLeaseRequest, decodeGRPCLeaseResponse, pb.LeaseResponse{}),
+		RevokeEndpoint:       endpointFor("Revoke", encodeGRPCLeaseRequest, decodeGRPCLeaseResponse, pb.LeaseResponse{}),
+		RevokePrefixEndpoint: endpointFor("RevokePrefix", encodeGRPCLeasePrefixRequest, decodeGRPCLeaseResponse, pb.LeaseResponse{}),
+		ListEndpoint:         endpointFor("List", encodeGRPCLeasePrefixRequest, decodeGRPCListLeasesResponse, pb.ListLeasesResponse{}),
+	}
+}
+
+func (s *grpcLeaseServer) Lookup(ctx context.Context, r *pb.LeaseRequest) (*pb.LeaseResponse, error) {
+	_, resp, err := s.lookup.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.LeaseResponse), nil
+}
+
+func (s *grpcLeaseServer) Renew(ctx context.Context, r *pb.RenewLeaseRequest) (*pb.LeaseResponse, error) {
+	_, resp, err := s.renew.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.LeaseResponse), nil
+}
+
+func (s *grpcLeaseServer) Revoke(ctx context.Context, r *pb.LeaseRequest) (*pb.LeaseResponse, error) {
+	_, resp, err := s.revoke.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.LeaseResponse), nil
+}
+
+func (s *grpcLeaseServer) RevokePrefix(ctx context.Context, r *pb.LeasePrefixRequest) (*pb.LeaseResponse, error) {
+	_, resp, err := s.revokePrefix.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.LeaseResponse), nil
+}
+
+func (s *grpcLeaseServer) List(ctx context.Context, r *pb.LeasePrefixRequest) (*pb.ListLeasesResponse, error) {
+	_, resp, err := s.list.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.ListLeasesResponse), nil
+}
+
+func decodeGRPCLeaseRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.LeaseRequest)
+	return vaultendpoint.LeaseRequest{LeaseID: req.LeaseId}, nil
+}
+
+func decodeGRPCRenewLeaseRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.RenewLeaseRequest)
+	return vaultendpoint.RenewLeaseRequest{LeaseID: req.LeaseId, Increment: req.Increment}, nil
+}
+
+func decodeGRPCLeasePrefixRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.LeasePrefixRequest)
+	return vaultendpoint.LeasePrefixRequest{Prefix: req.Prefix}, nil
+}
+
+func encodeGRPCLeaseResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.LeaseResponse)
+	return &pb.LeaseResponse{
+		LeaseId:         resp.ID,
+		IssueTime:       unixNano(resp.IssueTime),
+		ExpireTime:      unixNano(resp.ExpireTime),
+		LastRenewalTime: unixNano(resp.LastRenewalTime),
+		MaxExpireTime:   unixNano(resp.MaxExpireTime),
+		Renewable:       resp.Renewable,
+		Err:             err2str(resp.Err),
+	}, nil
+}
+
+func encodeGRPCListLeasesResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.ListLeasesResponse)
+	return &pb.ListLeasesResponse{Keys: resp.Keys, Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCLeaseRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.LeaseRequest)
+	return &pb.LeaseRequest{LeaseId: req.LeaseID}, nil
+}
+
+func encodeGRPCRenewLeaseRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.RenewLeaseRequest)
+	return &pb.RenewLeaseRequest{LeaseId: req.LeaseID, Increment: req.Increment}, nil
+}
+
+func encodeGRPCLeasePrefixRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.LeasePrefixRequest)
+	return &pb.LeasePrefixRequest{Prefix: req.Prefix}, nil
+}
+
+func decodeGRPCLeaseResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.LeaseResponse)
+	l := lease.Lease{
+		ID:              reply.LeaseId,
+		IssueTime:       fromUnixNano(reply.IssueTime),
+		ExpireTime:      fromUnixNano(reply.ExpireTime),
+		LastRenewalTime: fromUnixNano(reply.LastRenewalTime),
+		MaxExpireTime:   fromUnixNano(reply.MaxExpireTime),
+		Renewable:       reply.Renewable,
+	}
+	return vaultendpoint.LeaseResponse{Lease: l, TTL: int64(l.TTL() / time.Second), Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCListLeasesResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.ListLeasesResponse)
+	return vaultendpoint.ListLeasesResponse{Keys: reply.Keys, Err: str2err(reply.Err)}, nil
+}
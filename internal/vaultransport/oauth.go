@@ -0,0 +1,536 @@
+package vaultransport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	httptransport "github.com/go-kit/kit/transport/http"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+	"github.com/sony/gobreaker"
+	"google.golang.org/grpc"
+
+	"github.com/williamlsh/vault/internal/seal"
+	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaultservice"
+	"github.com/williamlsh/vault/pb"
+)
+
+const (
+	oauthTokenPath      = "/oauth/token"
+	oauthIntrospectPath = "/oauth/introspect"
+	oauthRevokePath     = "/oauth/revoke"
+)
+
+// oauthErrors are the errors reported with their own RFC 6749 error code.
+var oauthErrors = []error{
+	vaultservice.ErrInvalidRequest,
+	vaultservice.ErrInvalidClient,
+	vaultservice.ErrInvalidScope,
+	vaultservice.ErrUnauthorizedClient,
+	vaultservice.ErrUnsupportedGrantType,
+}
+
+// registerOAuthHandlers makes the token, introspection and revocation
+// endpoints of the OAuth2 authorization server available under /oauth/, with
+// the form encoded requests and the RFC 6749 errors of their RFCs.
+func registerOAuthHandlers(m *http.ServeMux, endpoints vaultendpoint.OAuthSet, options []httptransport.ServerOption, otTracer stdopentracing.Tracer, logger log.Logger) {
+	oauthServer := func(name string, e endpoint.Endpoint, dec httptransport.DecodeRequestFunc) http.Handler {
+		return httptransport.NewServer(
+			e,
+			dec,
+			encodeHTTPOAuthResponse,
+			append(options,
+				httptransport.ServerBefore(opentracing.HTTPToContext(otTracer, name, logger)),
+				httptransport.ServerErrorEncoder(oauthErrorEncoder),
+			)...,
+		)
+	}
+	m.Handle(oauthTokenPath, methodMux{
+		http.MethodPost: oauthServer("Token", endpoints.TokenEndpoint, decodeHTTPAccessTokenRequest),
+	})
+	m.Handle(oauthIntrospectPath, methodMux{
+		http.MethodPost: oauthServer("Introspect", endpoints.IntrospectEndpoint, decodeHTTPIntrospectRequest),
+	})
+	m.Handle(oauthRevokePath, methodMux{
+		http.MethodPost: oauthServer("Revoke", endpoints.RevokeEndpoint, decodeHTTPOAuthRevokeRequest),
+	})
+}
+
+// NewHTTPOAuthClient returns an OAuthService backed by an HTTP server living
+// at the remote instance.
+func NewHTTPOAuthClient(instance string, tlsOpts ClientTLS, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) (vaultservice.OAuthService, error) {
+	c, err := gatewayClient(instance, tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+	before := []grpctransport.ClientRequestFunc{
+		opentracing.ContextToGRPC(otTracer, logger),
+		jwt.ContextToGRPC(),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = newGatewayEndpoint(c, "pb.OAuth", method, enc, dec, reply, before...)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.OAuthSet{
+		ReadClientEndpoint:   endpointFor("ReadClient", encodeGRPCClientRequest, decodeGRPCClientResponse, pb.ClientResponse{}),
+		WriteClientEndpoint:  endpointFor("WriteClient", encodeGRPCWriteClientRequest, decodeGRPCClientResponse, pb.ClientResponse{}),
+		DeleteClientEndpoint: endpointFor("DeleteClient", encodeGRPCClientRequest, decodeGRPCClientResponse, pb.ClientResponse{}),
+		ListClientsEndpoint:  endpointFor("ListClients", encodeGRPCListClientsRequest, decodeGRPCListClientsResponse, pb.ListClientsResponse{}),
+		TokenEndpoint:        endpointFor("Token", encodeGRPCAccessTokenRequest, decodeGRPCAccessTokenResponse, pb.AccessTokenResponse{}),
+		IntrospectEndpoint:   endpointFor("Introspect", encodeGRPCIntrospectRequest, decodeGRPCIntrospectResponse, pb.IntrospectResponse{}),
+		RevokeEndpoint:       endpointFor("Revoke", encodeGRPCOAuthRevokeRequest, decodeGRPCOAuthRevokeResponse, pb.OAuthRevokeResponse{}),
+	}, nil
+}
+
+func decodeHTTPAccessTokenRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	creds, err := decodeClientCredentials(r)
+	if err != nil {
+		return nil, err
+	}
+	return vaultendpoint.AccessTokenRequest{AccessTokenRequest: vaultservice.AccessTokenRequest{
+		ClientCredentials: creds,
+		GrantType:         r.PostForm.Get("grant_type"),
+		Scope:             r.PostForm.Get("scope"),
+	}}, nil
+}
+
+func decodeHTTPIntrospectRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	creds, err := decodeClientCredentials(r)
+	if err != nil {
+		return nil, err
+	}
+	return vaultendpoint.IntrospectRequest{ClientCredentials: creds, Token: r.PostForm.Get("token")}, nil
+}
+
+func decodeHTTPOAuthRevokeRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	creds, err := decodeClientCredentials(r)
+	if err != nil {
+		return nil, err
+	}
+	return vaultendpoint.OAuthRevokeRequest{ClientCredentials: creds, Token: r.PostForm.Get("token")}, nil
+}
+
+// decodeClientCredentials parses the form encoded body of an OAuth2 request
+// and returns the client credentials, sent with HTTP Basic authentication or
+// in the body as RFC 6749 section 2.3.1 allows.
+func decodeClientCredentials(r *http.Request) (vaultservice.ClientCredentials, error) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return vaultservice.ClientCredentials{}, fmt.Errorf("%w: body must be form encoded", vaultservice.ErrInvalidRequest)
+	}
+	if err := r.ParseForm(); err != nil {
+		return vaultservice.ClientCredentials{}, fmt.Errorf("%w: %v", vaultservice.ErrInvalidRequest, err)
+	}
+	creds := vaultservice.ClientCredentials{
+		ClientID:     r.PostForm.Get("client_id"),
+		ClientSecret: r.PostForm.Get("client_secret"),
+	}
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		return creds, nil
+	}
+	if creds.ClientSecret != "" {
+		return vaultservice.ClientCredentials{}, fmt.Errorf("%w: multiple client authentication methods", vaultservice.ErrInvalidRequest)
+	}
+	// The credentials are form encoded before being sent with Basic
+	// authentication.
+	var err error
+	if creds.ClientID, err = url.QueryUnescape(id); err != nil {
+		return vaultservice.ClientCredentials{}, vaultservice.ErrInvalidClient
+	}
+	if creds.ClientSecret, err = url.QueryUnescape(secret); err != nil {
+		return vaultservice.ClientCredentials{}, vaultservice.ErrInvalidClient
+	}
+	return creds, nil
+}
+
+// encodeHTTPOAuthResponse encodes the responses of the token, introspection
+// and revocation endpoints, which must not be cached.
+func encodeHTTPOAuthResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	if f, ok := response.(endpoint.Failer); ok && f.Failed() != nil {
+		oauthErrorEncoder(ctx, f.Failed(), w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
+// oauthErrorEncoder writes err as an RFC 6749 section 5.2 error response.
+func oauthErrorEncoder(_ context.Context, err error, w http.ResponseWriter) {
+	code, status := oauthErrorCode(err)
+	resp := oauthErrorWrapper{Error: code}
+	if msg := err.Error(); msg != code {
+		resp.Description = msg
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if errors.Is(err, vaultservice.ErrInvalidClient) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="vaultd"`)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// oauthErrorCode returns the RFC 6749 error code and the status code of err.
+func oauthErrorCode(err error) (string, int) {
+	for _, e := range oauthErrors {
+		if !errors.Is(err, e) {
+			continue
+		}
+		if e == vaultservice.ErrInvalidClient {
+			return e.Error(), http.StatusUnauthorized
+		}
+		return e.Error(), http.StatusBadRequest
+	}
+	if errors.Is(err, seal.ErrSealed) || errors.Is(err, seal.ErrNotInitialized) {
+		return "temporarily_unavailable", http.StatusServiceUnavailable
+	}
+	return "server_error", http.StatusInternalServerError
+}
+
+type oauthErrorWrapper struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+type grpcOAuthServer struct {
+	readClient   grpctransport.Handler
+	writeClient  grpctransport.Handler
+	deleteClient grpctransport.Handler
+	listClients  grpctransport.Handler
+	token        grpctransport.Handler
+	introspect   grpctransport.Handler
+	revoke       grpctransport.Handler
+}
+
+// NewGRPCOAuthServer makes the OAuth2 endpoints available as a gRPC
+// OAuthServer.
+func NewGRPCOAuthServer(endpoints vaultendpoint.OAuthSet, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) pb.OAuthServer {
+	options := grpcServerOptions(zipkinTracer, logger)
+	handler := func(name string, e endpoint.Endpoint, dec grpctransport.DecodeRequestFunc, enc grpctransport.EncodeResponseFunc) grpctransport.Handler {
+		return grpctransport.NewServer(
+			failedAsError(e),
+			dec,
+			enc,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, name, logger)))...,
+		)
+	}
+	return &grpcOAuthServer{
+		readClient:   handler("ReadClient", endpoints.ReadClientEndpoint, decodeGRPCClientRequest, encodeGRPCClientResponse),
+		writeClient:  handler("WriteClient", endpoints.WriteClientEndpoint, decodeGRPCWriteClientRequest, encodeGRPCClientResponse),
+		deleteClient: handler("DeleteClient", endpoints.DeleteClientEndpoint, decodeGRPCClientRequest, encodeGRPCClientResponse),
+		listClients:  handler("ListClients", endpoints.ListClientsEndpoint, decodeGRPCListClientsRequest, encodeGRPCListClientsResponse),
+		token:        handler("Token", endpoints.TokenEndpoint, decodeGRPCAccessTokenRequest, encodeGRPCAccessTokenResponse),
+		introspect:   handler("Introspect", endpoints.IntrospectEndpoint, decodeGRPCIntrospectRequest, encodeGRPCIntrospectResponse),
+		revoke:       handler("Revoke", endpoints.RevokeEndpoint, decodeGRPCOAuthRevokeRequest, encodeGRPCOAuthRevokeResponse),
+	}
+}
+
+// NewGRPCOAuthClient returns an OAuthService backed by a gRPC server at the
+// other end of the conn.
+func NewGRPCOAuthClient(conn *grpc.ClientConn, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) vaultservice.OAuthService {
+	options := []grpctransport.ClientOption{
+		grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)),
+		grpctransport.ClientBefore(jwt.ContextToGRPC()),
+		zipkin.GRPCClientTrace(zipkinTracer),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = grpctransport.NewClient(conn, "pb.OAuth", method, enc, dec, reply, options...).Endpoint()
+		e = grpcErrorDecoder(e)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.OAuthSet{
+		ReadClientEndpoint:   endpointFor("ReadClient", encodeGRPCClientRequest, decodeGRPCClientResponse, pb.ClientResponse{}),
+		WriteClientEndpoint:  endpointFor("WriteClient", encodeGRPCWriteClientRequest, decodeGRPCClientResponse, pb.ClientResponse{}),
+		DeleteClientEndpoint: endpointFor("DeleteClient", encodeGRPCClientRequest, decodeGRPCClientResponse, pb.ClientResponse{}),
+		ListClientsEndpoint:  endpointFor("ListClients", encodeGRPCListClientsRequest, decodeGRPCListClientsResponse, pb.ListClientsResponse{}),
+		TokenEndpoint:        endpointFor("Token", encodeGRPCAccessTokenRequest, decodeGRPCAccessTokenResponse, pb.AccessTokenResponse{}),
+		IntrospectEndpoint:   endpointFor("Introspect", encodeGRPCIntrospectRequest, decodeGRPCIntrospectResponse, pb.IntrospectResponse{}),
+		RevokeEndpoint:       endpointFor("Revoke", encodeGRPCOAuthRevokeRequest, decodeGRPCOAuthRevokeResponse, pb.OAuthRevokeResponse{}),
+	}
+}
+
+func (s *grpcOAuthServer) ReadClient(ctx context.Context, r *pb.ClientRequest) (*pb.ClientResponse, error) {
+	_, resp, err := s.readClient.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.ClientResponse), nil
+}
+
+func (s *grpcOAuthServer) WriteClient(ctx context.Context, r *pb.WriteClientRequest) (*pb.ClientResponse, error) {
+	_, resp, err := s.writeClient.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.ClientResponse), nil
+}
+
+func (s *grpcOAuthServer) DeleteClient(ctx context.Context, r *pb.ClientRequest) (*pb.ClientResponse, error) {
+	_, resp, err := s.deleteClient.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.ClientResponse), nil
+}
+
+func (s *grpcOAuthServer) ListClients(ctx context.Context, r *pb.ListClientsRequest) (*pb.ListClientsResponse, error) {
+	_, resp, err := s.listClients.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.ListClientsResponse), nil
+}
+
+func (s *grpcOAuthServer) Token(ctx context.Context, r *pb.AccessTokenRequest) (*pb.AccessTokenResponse, error) {
+	_, resp, err := s.token.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.AccessTokenResponse), nil
+}
+
+func (s *grpcOAuthServer) Introspect(ctx context.Context, r *pb.IntrospectRequest) (*pb.IntrospectResponse, error) {
+	_, resp, err := s.introspect.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.IntrospectResponse), nil
+}
+
+func (s *grpcOAuthServer) Revoke(ctx context.Context, r *pb.OAuthRevokeRequest) (*pb.OAuthRevokeResponse, error) {
+	_, resp, err := s.revoke.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.OAuthRevokeResponse), nil
+}
+
+func decodeGRPCClientRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ClientRequest)
+	return vaultendpoint.ClientRequest{ClientID: req.ClientId}, nil
+}
+
+func decodeGRPCWriteClientRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.WriteClientRequest)
+	return vaultendpoint.WriteClientRequest{
+		ClientID: req.ClientId,
+		OAuthClient: vaultservice.OAuthClient{
+			Scopes:        req.Scopes,
+			TokenPolicies: req.TokenPolicies,
+			TokenTTL:      req.TokenTtl,
+		},
+	}, nil
+}
+
+func decodeGRPCListClientsRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return vaultendpoint.ListClientsRequest{}, nil
+}
+
+func decodeGRPCAccessTokenRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.AccessTokenRequest)
+	return vaultendpoint.AccessTokenRequest{AccessTokenRequest: vaultservice.AccessTokenRequest{
+		ClientCredentials: vaultservice.ClientCredentials{ClientID: req.ClientId, ClientSecret: req.ClientSecret},
+		GrantType:         req.GrantType,
+		Scope:             req.Scope,
+	}}, nil
+}
+
+func decodeGRPCIntrospectRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.IntrospectRequest)
+	return vaultendpoint.IntrospectRequest{
+		ClientCredentials: vaultservice.ClientCredentials{ClientID: req.ClientId, ClientSecret: req.ClientSecret},
+		Token:             req.Token,
+	}, nil
+}
+
+func decodeGRPCOAuthRevokeRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.OAuthRevokeRequest)
+	return vaultendpoint.OAuthRevokeRequest{
+		ClientCredentials: vaultservice.ClientCredentials{ClientID: req.ClientId, ClientSecret: req.ClientSecret},
+		Token:             req.Token,
+	}, nil
+}
+
+func encodeGRPCClientResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.ClientResponse)
+	return &pb.ClientResponse{
+		ClientId:      resp.ClientID,
+		ClientSecret:  resp.ClientSecret,
+		Scopes:        resp.Scopes,
+		TokenPolicies: resp.TokenPolicies,
+		TokenTtl:      resp.TokenTTL,
+		Err:           err2str(resp.Err),
+	}, nil
+}
+
+func encodeGRPCListClientsResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.ListClientsResponse)
+	return &pb.ListClientsResponse{Clients: resp.Clients, Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCAccessTokenResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.AccessTokenResponse)
+	return &pb.AccessTokenResponse{
+		AccessToken: resp.AccessToken.AccessToken,
+		TokenType:   resp.TokenType,
+		ExpiresIn:   resp.ExpiresIn,
+		Scope:       resp.Scope,
+		Err:         err2str(resp.Err),
+	}, nil
+}
+
+func encodeGRPCIntrospectResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.IntrospectResponse)
+	return &pb.IntrospectResponse{
+		Active:    resp.Active,
+		Scope:     resp.Scope,
+		ClientId:  resp.ClientID,
+		TokenType: resp.TokenType,
+		Exp:       resp.ExpiresAt,
+		Iat:       resp.IssuedAt,
+		Nbf:       resp.NotBefore,
+		Sub:       resp.Subject,
+		Iss:       resp.Issuer,
+		Jti:       resp.ID,
+		Err:       err2str(resp.Err),
+	}, nil
+}
+
+func encodeGRPCOAuthRevokeResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.OAuthRevokeResponse)
+	return &pb.OAuthRevokeResponse{Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCClientRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.ClientRequest)
+	return &pb.ClientRequest{ClientId: req.ClientID}, nil
+}
+
+func encodeGRPCWriteClientRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.WriteClientRequest)
+	return &pb.WriteClientRequest{
+		ClientId:      req.ClientID,
+		Scopes:        req.Scopes,
+		TokenPolicies: req.TokenPolicies,
+		TokenTtl:      req.TokenTTL,
+	}, nil
+}
+
+func encodeGRPCListClientsRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.ListClientsRequest{}, nil
+}
+
+func encodeGRPCAccessTokenRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.AccessTokenRequest)
+	return &pb.AccessTokenRequest{
+		GrantType:    req.GrantType,
+		ClientId:     req.ClientID,
+		ClientSecret: req.ClientSecret,
+		Scope:        req.Scope,
+	}, nil
+}
+
+func encodeGRPCIntrospectRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.IntrospectRequest)
+	return &pb.IntrospectRequest{ClientId: req.ClientID, ClientSecret: req.ClientSecret, Token: req.Token}, nil
+}
+
+func encodeGRPCOAuthRevokeRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.OAuthRevokeRequest)
+	return &pb.OAuthRevokeRequest{ClientId: req.ClientID, ClientSecret: req.ClientSecret, Token: req.Token}, nil
+}
+
+func decodeGRPCClientResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.ClientResponse)
+	return vaultendpoint.ClientResponse{
+		ClientID:     reply.ClientId,
+		ClientSecret: reply.ClientSecret,
+		OAuthClient: vaultservice.OAuthClient{
+			Scopes:        reply.Scopes,
+			TokenPolicies: reply.TokenPolicies,
+			TokenTTL:      reply.TokenTtl,
+		},
+		Err: str2err(reply.Err),
+	}, nil
+}
+
+func decodeGRPCListClientsResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.ListClientsResponse)
+	return vaultendpoint.ListClientsResponse{Clients: reply.Clients, Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCAccessTokenResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.AccessTokenResponse)
+	return vaultendpoint.AccessTokenResponse{
+		AccessToken: vaultservice.AccessToken{
+			AccessToken: reply.AccessToken,
+			TokenType:   reply.TokenType,
+			ExpiresIn:   reply.ExpiresIn,
+			Scope:       reply.Scope,
+		},
+		Err: str2err(reply.Err),
+	}, nil
+}
+
+func decodeGRPCIntrospectResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.IntrospectResponse)
+	return vaultendpoint.IntrospectResponse{
+		Introspection: vaultservice.Introspection{
+			Active:    reply.Active,
+			Scope:     reply.Scope,
+			ClientID:  reply.ClientId,
+			TokenType: reply.TokenType,
+			ExpiresAt: reply.Exp,
+			IssuedAt:  reply.Iat,
+			NotBefore: reply.Nbf,
+			Subject:   reply.Sub,
+			Issuer:    reply.Iss,
+			ID:        reply.Jti,
+		},
+		Err: str2err(reply.Err),
+	}, nil
+}
+
+func decodeGRPCOAuthRevokeResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.OAuthRevokeResponse)
+	return vaultendpoint.OAuthRevokeResponse{Err: str2err(reply.Err)}, nil
+}
@@ -0,0 +1,525 @@
+package vaultransport
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+	"github.com/sony/gobreaker"
+	"google.golang.org/grpc"
+
+	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaultservice"
+	"github.com/williamlsh/vault/pb"
+)
+
+// NewHTTPPKIClient returns a PKIService backed by an HTTP server living at
+// the remote instance.
+func NewHTTPPKIClient(instance string, tlsOpts ClientTLS, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) (vaultservice.PKIService, error) {
+	c, err := gatewayClient(instance, tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+	before := []grpctransport.ClientRequestFunc{
+		opentracing.ContextToGRPC(otTracer, logger),
+		jwt.ContextToGRPC(),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = newGatewayEndpoint(c, "pb.PKI", method, enc, dec, reply, before...)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.PKISet{
+		GenerateCAEndpoint:       endpointFor("GenerateCA", encodeGRPCPKIGenerateCARequest, decodeGRPCPKICAResponse, pb.PKICAResponse{}),
+		ImportCAEndpoint:         endpointFor("ImportCA", encodeGRPCPKIImportCARequest, decodeGRPCPKICAResponse, pb.PKICAResponse{}),
+		ReadCAEndpoint:           endpointFor("ReadCA", encodeGRPCPKIReadCARequest, decodeGRPCPKICAResponse, pb.PKICAResponse{}),
+		WriteRoleEndpoint:        endpointFor("WriteRole", encodeGRPCPKIWriteRoleRequest, decodeGRPCPKIRoleResponse, pb.PKIRoleResponse{}),
+		ReadRoleEndpoint:         endpointFor("ReadRole", encodeGRPCPKIRoleRequest, decodeGRPCPKIRoleResponse, pb.PKIRoleResponse{}),
+		DeleteRoleEndpoint:       endpointFor("DeleteRole", encodeGRPCPKIRoleRequest, decodeGRPCPKIRoleResponse, pb.PKIRoleResponse{}),
+		ListRolesEndpoint:        endpointFor("ListRoles", encodeGRPCPKIListRolesRequest, decodeGRPCPKIListRolesResponse, pb.PKIListRolesResponse{}),
+		IssueEndpoint:            endpointFor("Issue", encodeGRPCPKIIssueRequest, decodeGRPCPKICertificateResponse, pb.PKICertificateResponse{}),
+		SignEndpoint:             endpointFor("Sign", encodeGRPCPKISignRequest, decodeGRPCPKICertificateResponse, pb.PKICertificateResponse{}),
+		RevokeEndpoint:           endpointFor("Revoke", encodeGRPCPKICertificateRequest, decodeGRPCPKICertificateResponse, pb.PKICertificateResponse{}),
+		ReadCertificateEndpoint:  endpointFor("ReadCertificate", encodeGRPCPKICertificateRequest, decodeGRPCPKICertificateResponse, pb.PKICertificateResponse{}),
+		ListCertificatesEndpoint: endpointFor("ListCertificates", encodeGRPCPKIListCertificatesRequest, decodeGRPCPKIListCertificatesResponse, pb.PKIListCertificatesResponse{}),
+		ReadCRLEndpoint:          endpointFor("ReadCRL", encodeGRPCPKIReadCRLRequest, decodeGRPCPKICRLResponse, pb.PKICRLResponse{}),
+	}, nil
+}
+
+type grpcPKIServer struct {
+	generateCA       grpctransport.Handler
+	importCA         grpctransport.Handler
+	readCA           grpctransport.Handler
+	writeRole        grpctransport.Handler
+	readRole         grpctransport.Handler
+	deleteRole       grpctransport.Handler
+	listRoles        grpctransport.Handler
+	issue            grpctransport.Handler
+	sign             grpctransport.Handler
+	revoke           grpctransport.Handler
+	readCertificate  grpctransport.Handler
+	listCertificates grpctransport.Handler
+	readCRL          grpctransport.Handler
+}
+
+// NewGRPCPKIServer makes the PKI endpoints available as a gRPC PKIServer.
+func NewGRPCPKIServer(endpoints vaultendpoint.PKISet, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) pb.PKIServer {
+	options := grpcServerOptions(zipkinTracer, logger)
+	handler := func(name string, e endpoint.Endpoint, dec grpctransport.DecodeRequestFunc, enc grpctransport.EncodeResponseFunc) grpctransport.Handler {
+		return grpctransport.NewServer(
+			failedAsError(e),
+			dec,
+			enc,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, name, logger)))...,
+		)
+	}
+	return &grpcPKIServer{
+		generateCA:       handler("PKIGenerateCA", endpoints.GenerateCAEndpoint, decodeGRPCPKIGenerateCARequest, encodeGRPCPKICAResponse),
+		importCA:         handler("PKIImportCA", endpoints.ImportCAEndpoint, decodeGRPCPKIImportCARequest, encodeGRPCPKICAResponse),
+		readCA:           handler("PKIReadCA", endpoints.ReadCAEndpoint, decodeGRPCPKIReadCARequest, encodeGRPCPKICAResponse),
+		writeRole:        handler("PKIWriteRole", endpoints.WriteRoleEndpoint, decodeGRPCPKIWriteRoleRequest, encodeGRPCPKIRoleResponse),
+		readRole:         handler("PKIReadRole", endpoints.ReadRoleEndpoint, decodeGRPCPKIRoleRequest, encodeGRPCPKIRoleResponse),
+		deleteRole:       handler("PKIDeleteRole", endpoints.DeleteRoleEndpoint, decodeGRPCPKIRoleRequest, encodeGRPCPKIRoleResponse),
+		listRoles:        handler("PKIListRoles", endpoints.ListRolesEndpoint, decodeGRPCPKIListRolesRequest, encodeGRPCPKIListRolesResponse),
+		issue:            handler("PKIIssue", endpoints.IssueEndpoint, decodeGRPCPKIIssueRequest, encodeGRPCPKICertificateResponse),
+		sign:             handler("PKISign", endpoints.SignEndpoint, decodeGRPCPKISignRequest, encodeGRPCPKICertificateResponse),
+		revoke:           handler("PKIRevoke", endpoints.RevokeEndpoint, decodeGRPCPKICertificateRequest, encodeGRPCPKICertificateResponse),
+		readCertificate:  handler("PKIReadCertificate", endpoints.ReadCertificateEndpoint, decodeGRPCPKICertificateRequest, encodeGRPCPKICertificateResponse),
+		listCertificates: handler("PKIListCertificates", endpoints.ListCertificatesEndpoint, decodeGRPCPKIListCertificatesRequest, encodeGRPCPKIListCertificatesResponse),
+		readCRL:          handler("PKIReadCRL", endpoints.ReadCRLEndpoint, decodeGRPCPKIReadCRLRequest, encodeGRPCPKICRLResponse),
+	}
+}
+
+// NewGRPCPKIClient returns a PKIService backed by a gRPC server at the other
+// end of the conn.
+func NewGRPCPKIClient(conn *grpc.ClientConn, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) vaultservice.PKIService {
+	options := []grpctransport.ClientOption{
+		grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)),
+		grpctransport.ClientBefore(jwt.ContextToGRPC()),
+		zipkin.GRPCClientTrace(zipkinTracer),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = grpctransport.NewClient(conn, "pb.PKI", method, enc, dec, reply, options...).Endpoint()
+		e = grpcErrorDecoder(e)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.PKISet{
+		GenerateCAEndpoint:       endpointFor("GenerateCA", encodeGRPCPKIGenerateCARequest, decodeGRPCPKICAResponse, pb.PKICAResponse{}),
+		ImportCAEndpoint:         endpointFor("ImportCA", encodeGRPCPKIImportCARequest, decodeGRPCPKICAResponse, pb.PKICAResponse{}),
+		ReadCAEndpoint:           endpointFor("ReadCA", encodeGRPCPKIReadCARequest, decodeGRPCPKICAResponse, pb.PKICAResponse{}),
+		WriteRoleEndpoint:        endpointFor("WriteRole", encodeGRPCPKIWriteRoleRequest, decodeGRPCPKIRoleResponse, pb.PKIRoleResponse{}),
+		ReadRoleEndpoint:         endpointFor("ReadRole", encodeGRPCPKIRoleRequest, decodeGRPCPKIRoleResponse, pb.PKIRoleResponse{}),
+		DeleteRoleEndpoint:       endpointFor("DeleteRole", encodeGRPCPKIRoleRequest, decodeGRPCPKIRoleResponse, pb.PKIRoleResponse{}),
+		ListRolesEndpoint:        endpointFor("ListRoles", encodeGRPCPKIListRolesRequest, decodeGRPCPKIListRolesResponse, pb.PKIListRolesResponse{}),
+		IssueEndpoint:            endpointFor("Issue", encodeGRPCPKIIssueRequest, decodeGRPCPKICertificateResponse, pb.PKICertificateResponse{}),
+		SignEndpoint:             endpointFor("Sign", encodeGRPCPKISignRequest, decodeGRPCPKICertificateResponse, pb.PKICertificateResponse{}),
+		RevokeEndpoint:           endpointFor("Revoke", encodeGRPCPKICertificateRequest, decodeGRPCPKICertificateResponse, pb.PKICertificateResponse{}),
+		ReadCertificateEndpoint:  endpointFor("ReadCertificate", encodeGRPCPKICertificateRequest, decodeGRPCPKICertificateResponse, pb.PKICertificateResponse{}),
+		ListCertificatesEndpoint: endpointFor("ListCertificates", encodeGRPCPKIListCertificatesRequest, decodeGRPCPKIListCertificatesResponse, pb.PKIListCertificatesResponse{}),
+		ReadCRLEndpoint:          endpointFor("ReadCRL", encodeGRPCPKIReadCRLRequest, decodeGRPCPKICRLResponse, pb.PKICRLResponse{}),
+	}
+}
+
+func (s *grpcPKIServer) GenerateCA(ctx context.Context, r *pb.PKIGenerateCARequest) (*pb.PKICAResponse, error) {
+	_, resp, err := s.generateCA.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.PKICAResponse), nil
+}
+
+func (s *grpcPKIServer) ImportCA(ctx context.Context, r *pb.PKIImportCARequest) (*pb.PKICAResponse, error) {
+	_, resp, err := s.importCA.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.PKICAResponse), nil
+}
+
+func (s *grpcPKIServer) ReadCA(ctx context.Context, r *pb.PKIReadCARequest) (*pb.PKICAResponse, error) {
+	_, resp, err := s.readCA.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.PKICAResponse), nil
+}
+
+func (s *grpcPKIServer) WriteRole(ctx context.Context, r *pb.PKIWriteRoleRequest) (*pb.PKIRoleResponse, error) {
+	_, resp, err := s.writeRole.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.PKIRoleResponse), nil
+}
+
+func (s *grpcPKIServer) ReadRole(ctx context.Context, r *pb.PKIRoleRequest) (*pb.PKIRoleResponse, error) {
+	_, resp, err := s.readRole.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.PKIRoleResponse), nil
+}
+
+func (s *grpcPKIServer) DeleteRole(ctx context.Context, r *pb.PKIRoleRequest) (*pb.PKIRoleResponse, error) {
+	_, resp, err := s.deleteRole.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.PKIRoleResponse), nil
+}
+
+func (s *grpcPKIServer) ListRoles(ctx context.Context, r *pb.PKIListRolesRequest) (*pb.PKIListRolesResponse, error) {
+	_, resp, err := s.listRoles.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.PKIListRolesResponse), nil
+}
+
+func (s *grpcPKIServer) Issue(ctx context.Context, r *pb.PKIIssueRequest) (*pb.PKICertificateResponse, error) {
+	_, resp, err := s.issue.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.PKICertificateResponse), nil
+}
+
+func (s *grpcPKIServer) Sign(ctx context.Context, r *pb.PKISignRequest) (*pb.PKICertificateResponse, error) {
+	_, resp, err := s.sign.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.PKICertificateResponse), nil
+}
+
+func (s *grpcPKIServer) Revoke(ctx context.Context, r *pb.PKICertificateRequest) (*pb.PKICertificateResponse, error) {
+	_, resp, err := s.revoke.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.PKICertificateResponse), nil
+}
+
+func (s *grpcPKIServer) ReadCertificate(ctx context.Context, r *pb.PKICertificateRequest) (*pb.PKICertificateResponse, error) {
+	_, resp, err := s.readCertificate.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.PKICertificateResponse), nil
+}
+
+func (s *grpcPKIServer) ListCertificates(ctx context.Context, r *pb.PKIListCertificatesRequest) (*pb.PKIListCertificatesResponse, error) {
+	_, resp, err := s.listCertificates.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.PKIListCertificatesResponse), nil
+}
+
+func (s *grpcPKIServer) ReadCRL(ctx context.Context, r *pb.PKIReadCRLRequest) (*pb.PKICRLResponse, error) {
+	_, resp, err := s.readCRL.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.PKICRLResponse), nil
+}
+
+func decodeGRPCPKIGenerateCARequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.PKIGenerateCARequest)
+	return vaultendpoint.PKIGenerateCARequest{
+		Intermediate: req.Intermediate,
+		CommonName:   req.CommonName,
+		TTL:          req.Ttl,
+		KeyType:      req.KeyType,
+		KeyBits:      int(req.KeyBits),
+	}, nil
+}
+
+func decodeGRPCPKIImportCARequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.PKIImportCARequest)
+	return vaultendpoint.PKIImportCARequest{PEMBundle: req.PemBundle}, nil
+}
+
+func decodeGRPCPKIReadCARequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return vaultendpoint.PKIReadCARequest{}, nil
+}
+
+func decodeGRPCPKIWriteRoleRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.PKIWriteRoleRequest)
+	return vaultendpoint.PKIWriteRoleRequest{Name: req.Name, PKIRole: fromPBPKIRole(req.Role)}, nil
+}
+
+func decodeGRPCPKIRoleRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.PKIRoleRequest)
+	return vaultendpoint.PKIRoleRequest{Name: req.Name}, nil
+}
+
+func decodeGRPCPKIListRolesRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return vaultendpoint.PKIListRolesRequest{}, nil
+}
+
+func decodeGRPCPKIIssueRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.PKIIssueRequest)
+	return vaultendpoint.PKIIssueRequest{
+		Role: req.Role,
+		PKIIssueRequest: vaultservice.PKIIssueRequest{
+			CommonName: req.CommonName,
+			AltNames:   req.AltNames,
+			IPSANs:     req.IpSans,
+			URISANs:    req.UriSans,
+			TTL:        req.Ttl,
+		},
+	}, nil
+}
+
+func decodeGRPCPKISignRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.PKISignRequest)
+	return vaultendpoint.PKISignRequest{Role: req.Role, PKISignRequest: vaultservice.PKISignRequest{CSR: req.Csr, TTL: req.Ttl}}, nil
+}
+
+func decodeGRPCPKICertificateRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.PKICertificateRequest)
+	return vaultendpoint.PKICertificateRequest{SerialNumber: req.SerialNumber}, nil
+}
+
+func decodeGRPCPKIListCertificatesRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return vaultendpoint.PKIListCertificatesRequest{}, nil
+}
+
+func decodeGRPCPKIReadCRLRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return vaultendpoint.PKIReadCRLRequest{}, nil
+}
+
+func encodeGRPCPKICAResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.PKICAResponse)
+	return &pb.PKICAResponse{
+		Ca: &pb.PKICA{
+			Certificate:  resp.Certificate,
+			CaChain:      resp.CAChain,
+			SerialNumber: resp.SerialNumber,
+			Expiration:   unixNano(resp.Expiration),
+			Csr:          resp.CSR,
+		},
+		Err: err2str(resp.Err),
+	}, nil
+}
+
+func encodeGRPCPKIRoleResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.PKIRoleResponse)
+	return &pb.PKIRoleResponse{Name: resp.Name, Role: toPBPKIRole(resp.PKIRole), Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCPKIListRolesResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.ListRolesResponse)
+	return &pb.PKIListRolesResponse{Roles: resp.Roles, Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCPKICertificateResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.PKICertificateResponse)
+	return &pb.PKICertificateResponse{
+		Certificate: &pb.PKICertificate{
+			SerialNumber:   resp.SerialNumber,
+			Certificate:    resp.Certificate,
+			PrivateKey:     resp.PrivateKey,
+			IssuingCa:      resp.IssuingCA,
+			CaChain:        resp.CAChain,
+			Expiration:     unixNano(resp.Expiration),
+			RevocationTime: unixNano(resp.RevocationTime),
+		},
+		Err: err2str(resp.Err),
+	}, nil
+}
+
+func encodeGRPCPKIListCertificatesResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.PKIListCertificatesResponse)
+	return &pb.PKIListCertificatesResponse{SerialNumbers: resp.SerialNumbers, Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCPKICRLResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.PKICRLResponse)
+	return &pb.PKICRLResponse{Crl: resp.CRL, Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCPKIGenerateCARequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.PKIGenerateCARequest)
+	return &pb.PKIGenerateCARequest{
+		Intermediate: req.Intermediate,
+		CommonName:   req.CommonName,
+		Ttl:          req.TTL,
+		KeyType:      req.KeyType,
+		KeyBits:      int32(req.KeyBits),
+	}, nil
+}
+
+func encodeGRPCPKIImportCARequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.PKIImportCARequest)
+	return &pb.PKIImportCARequest{PemBundle: req.PEMBundle}, nil
+}
+
+func encodeGRPCPKIReadCARequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.PKIReadCARequest{}, nil
+}
+
+func encodeGRPCPKIWriteRoleRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.PKIWriteRoleRequest)
+	return &pb.PKIWriteRoleRequest{Name: req.Name, Role: toPBPKIRole(req.PKIRole)}, nil
+}
+
+func encodeGRPCPKIRoleRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.PKIRoleRequest)
+	return &pb.PKIRoleRequest{Name: req.Name}, nil
+}
+
+func encodeGRPCPKIListRolesRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.PKIListRolesRequest{}, nil
+}
+
+func encodeGRPCPKIIssueRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.PKIIssueRequest)
+	return &pb.PKIIssueRequest{
+		Role:       req.Role,
+		CommonName: req.CommonName,
+		AltNames:   req.AltNames,
+		IpSans:     req.IPSANs,
+		UriSans:    req.URISANs,
+		Ttl:        req.TTL,
+	}, nil
+}
+
+func encodeGRPCPKISignRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.PKISignRequest)
+	return &pb.PKISignRequest{Role: req.Role, Csr: req.CSR, Ttl: req.TTL}, nil
+}
+
+func encodeGRPCPKICertificateRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.PKICertificateRequest)
+	return &pb.PKICertificateRequest{SerialNumber: req.SerialNumber}, nil
+}
+
+func encodeGRPCPKIListCertificatesRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.PKIListCertificatesRequest{}, nil
+}
+
+func encodeGRPCPKIReadCRLRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.PKIReadCRLRequest{}, nil
+}
+
+func decodeGRPCPKICAResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.PKICAResponse)
+	var ca vaultservice.PKICA
+	if c := reply.Ca; c != nil {
+		ca = vaultservice.PKICA{
+			Certificate:  c.Certificate,
+			CAChain:      c.CaChain,
+			SerialNumber: c.SerialNumber,
+			Expiration:   fromUnixNano(c.Expiration),
+			CSR:          c.Csr,
+		}
+	}
+	return vaultendpoint.PKICAResponse{PKICA: ca, Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCPKIRoleResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.PKIRoleResponse)
+	return vaultendpoint.PKIRoleResponse{Name: reply.Name, PKIRole: fromPBPKIRole(reply.Role), Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCPKIListRolesResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.PKIListRolesResponse)
+	return vaultendpoint.ListRolesResponse{Roles: reply.Roles, Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCPKICertificateResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.PKICertificateResponse)
+	var cert vaultservice.PKICertificate
+	if c := reply.Certificate; c != nil {
+		cert = vaultservice.PKICertificate{
+			SerialNumber:   c.SerialNumber,
+			Certificate:    c.Certificate,
+			PrivateKey:     c.PrivateKey,
+			IssuingCA:      c.IssuingCa,
+			CAChain:        c.CaChain,
+			Expiration:     fromUnixNano(c.Expiration),
+			RevocationTime: fromUnixNano(c.RevocationTime),
+		}
+	}
+	return vaultendpoint.PKICertificateResponse{PKICertificate: cert, Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCPKIListCertificatesResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.PKIListCertificatesResponse)
+	return vaultendpoint.PKIListCertificatesResponse{SerialNumbers: reply.SerialNumbers, Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCPKICRLResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.PKICRLResponse)
+	return vaultendpoint.PKICRLResponse{CRL: reply.Crl, Err: str2err(reply.Err)}, nil
+}
+
+func toPBPKIRole(r vaultservice.PKIRole) *pb.PKIRole {
+	return &pb.PKIRole{
+		AllowedDomains:   r.AllowedDomains,
+		AllowBareDomains: r.AllowBareDomains,
+		AllowSubdomains:  r.AllowSubdomains,
+		AllowLocalhost:   r.AllowLocalhost,
+		AllowAnyName:     r.AllowAnyName,
+		AllowIpSans:      r.AllowIPSANs,
+		AllowedUriSans:   r.AllowedURISANs,
+		Ttl:              r.TTL,
+		MaxTtl:           r.MaxTTL,
+		KeyType:          r.KeyType,
+		KeyBits:          int32(r.KeyBits),
+		ServerFlag:       r.ServerFlag,
+		ClientFlag:       r.ClientFlag,
+	}
+}
+
+func fromPBPKIRole(r *pb.PKIRole) vaultservice.PKIRole {
+	if r == nil {
+		return vaultservice.PKIRole{}
+	}
+	return vaultservice.PKIRole{
+		AllowedDomains:   r.AllowedDomains,
+		AllowBareDomains: r.AllowBareDomains,
+		AllowSubdomains:  r.AllowSubdomains,
+		AllowLocalhost:   r.AllowLocalhost,
+		AllowAnyName:     r.AllowAnyName,
+		AllowIPSANs:      r.AllowIpSans,
+		AllowedURISANs:   r.AllowedUriSans,
+		TTL:              r.Ttl,
+		MaxTTL:           r.MaxTtl,
+		KeyType:          r.KeyType,
+		KeyBits:          int(r.KeyBits),
+		ServerFlag:       r.ServerFlag,
+		ClientFlag:       r.ClientFlag,
+	}
+}
@@ -0,0 +1,249 @@
+package vaultransport
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+	"github.com/sony/gobreaker"
+	"google.golang.org/grpc"
+
+	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaultservice"
+	"github.com/williamlsh/vault/pb"
+)
+
+// NewHTTPPolicyClient returns a PolicyService backed by an HTTP server living
+// at the remote instance.
+func NewHTTPPolicyClient(instance string, tlsOpts ClientTLS, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) (vaultservice.PolicyService, error) {
+	c, err := gatewayClient(instance, tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+	before := []grpctransport.ClientRequestFunc{
+		opentracing.ContextToGRPC(otTracer, logger),
+		jwt.ContextToGRPC(),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = newGatewayEndpoint(c, "pb.Policy", method, enc, dec, reply, before...)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.PolicySet{
+		ReadPolicyEndpoint:   endpointFor("ReadPolicy", encodeGRPCPolicyRequest, decodeGRPCPolicyResponse, pb.PolicyResponse{}),
+		WritePolicyEndpoint:  endpointFor("WritePolicy", encodeGRPCWritePolicyRequest, decodeGRPCPolicyResponse, pb.PolicyResponse{}),
+		DeletePolicyEndpoint: endpointFor("DeletePolicy", encodeGRPCPolicyRequest, decodeGRPCPolicyResponse, pb.PolicyResponse{}),
+		ListPoliciesEndpoint: endpointFor("ListPolicies", encodeGRPCListPoliciesRequest, decodeGRPCListPoliciesResponse, pb.ListPoliciesResponse{}),
+		ReadSubjectEndpoint:  endpointFor("ReadSubject", encodeGRPCSubjectRequest, decodeGRPCSubjectResponse, pb.SubjectResponse{}),
+		WriteSubjectEndpoint: endpointFor("WriteSubject", encodeGRPCWriteSubjectRequest, decodeGRPCSubjectResponse, pb.SubjectResponse{}),
+	}, nil
+}
+
+type grpcPolicyServer struct {
+	readPolicy   grpctransport.Handler
+	writePolicy  grpctransport.Handler
+	deletePolicy grpctransport.Handler
+	listPolicies grpctransport.Handler
+	readSubject  grpctransport.Handler
+	writeSubject grpctransport.Handler
+}
+
+// NewGRPCPolicyServer makes the policy endpoints available as a gRPC
+// PolicyServer.
+func NewGRPCPolicyServer(endpoints vaultendpoint.PolicySet, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) pb.PolicyServer {
+	options := grpcServerOptions(zipkinTracer, logger)
+	handler := func(name string, e endpoint.Endpoint, dec grpctransport.DecodeRequestFunc, enc grpctransport.EncodeResponseFunc) grpctransport.Handler {
+		return grpctransport.NewServer(
+			failedAsError(e),
+			dec,
+			enc,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, name, logger)))...,
+		)
+	}
+	return &grpcPolicyServer{
+		readPolicy:   handler("ReadPolicy", endpoints.ReadPolicyEndpoint, decodeGRPCPolicyRequest, encodeGRPCPolicyResponse),
+		writePolicy:  handler("WritePolicy", endpoints.WritePolicyEndpoint, decodeGRPCWritePolicyRequest, encodeGRPCPolicyResponse),
+		deletePolicy: handler("DeletePolicy", endpoints.DeletePolicyEndpoint, decodeGRPCPolicyRequest, encodeGRPCPolicyResponse),
+		listPolicies: handler("ListPolicies", endpoints.ListPoliciesEndpoint, decodeGRPCListPoliciesRequest, encodeGRPCListPoliciesResponse),
+		readSubject:  handler("ReadSubject", endpoints.ReadSubjectEndpoint, decodeGRPCSubjectRequest, encodeGRPCSubjectResponse),
+		writeSubject: handler("WriteSubject", endpoints.WriteSubjectEndpoint, decodeGRPCWriteSubjectRequest, encodeGRPCSubjectResponse),
+	}
+}
+
+// NewGRPCPolicyClient returns a PolicyService backed by a gRPC server at the
+// other end of the conn.
+func NewGRPCPolicyClient(conn *grpc.ClientConn, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) vaultservice.PolicyService {
+	options := []grpctransport.ClientOption{
+		grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)),
+		grpctransport.ClientBefore(jwt.ContextToGRPC()),
+		zipkin.GRPCClientTrace(zipkinTracer),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = grpctransport.NewClient(conn, "pb.Policy", method, enc, dec, reply, options...).Endpoint()
+		e = grpcErrorDecoder(e)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.PolicySet{
+		ReadPolicyEndpoint:   endpointFor("ReadPolicy", encodeGRPCPolicyRequest, decodeGRPCPolicyResponse, pb.PolicyResponse{}),
+		WritePolicyEndpoint:  endpointFor("WritePolicy", encodeGRPCWritePolicyRequest, decodeGRPCPolicyResponse, pb.PolicyResponse{}),
+		DeletePolicyEndpoint: endpointFor("DeletePolicy", encodeGRPCPolicyRequest, decodeGRPCPolicyResponse, pb.PolicyResponse{}),
+		ListPoliciesEndpoint: endpointFor("ListPolicies", encodeGRPCListPoliciesRequest, decodeGRPCListPoliciesResponse, pb.ListPoliciesResponse{}),
+		ReadSubjectEndpoint:  endpointFor("ReadSubject", encodeGRPCSubjectRequest, decodeGRPCSubjectResponse, pb.SubjectResponse{}),
+		WriteSubjectEndpoint: endpointFor("WriteSubject", encodeGRPCWriteSubjectRequest, decodeGRPCSubjectResponse, pb.SubjectResponse{}),
+	}
+}
+
+func (s *grpcPolicyServer) ReadPolicy(ctx context.Context, r *pb.PolicyRequest) (*pb.PolicyResponse, error) {
+	_, resp, err := s.readPolicy.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.PolicyResponse), nil
+}
+
+func (s *grpcPolicyServer) WritePolicy(ctx context.Context, r *pb.WritePolicyRequest) (*pb.PolicyResponse, error) {
+	_, resp, err := s.writePolicy.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.PolicyResponse), nil
+}
+
+func (s *grpcPolicyServer) DeletePolicy(ctx context.Context, r *pb.PolicyRequest) (*pb.PolicyResponse, error) {
+	_, resp, err := s.deletePolicy.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.PolicyResponse), nil
+}
+
+func (s *grpcPolicyServer) ListPolicies(ctx context.Context, r *pb.ListPoliciesRequest) (*pb.ListPoliciesResponse, error) {
+	_, resp, err := s.listPolicies.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.ListPoliciesResponse), nil
+}
+
+func (s *grpcPolicyServer) ReadSubject(ctx context.Context, r *pb.SubjectRequest) (*pb.SubjectResponse, error) {
+	_, resp, err := s.readSubject.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.SubjectResponse), nil
+}
+
+func (s *grpcPolicyServer) WriteSubject(ctx context.Context, r *pb.WriteSubjectRequest) (*pb.SubjectResponse, error) {
+	_, resp, err := s.writeSubject.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.SubjectResponse), nil
+}
+
+func decodeGRPCPolicyRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.PolicyRequest)
+	return vaultendpoint.PolicyRequest{Name: req.Name}, nil
+}
+
+func decodeGRPCWritePolicyRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.WritePolicyRequest)
+	return vaultendpoint.WritePolicyRequest{Name: req.Name, Policy: req.Policy}, nil
+}
+
+func decodeGRPCListPoliciesRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return vaultendpoint.ListPoliciesRequest{}, nil
+}
+
+func decodeGRPCSubjectRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.SubjectRequest)
+	return vaultendpoint.SubjectRequest{Subject: req.Subject}, nil
+}
+
+func decodeGRPCWriteSubjectRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.WriteSubjectRequest)
+	return vaultendpoint.WriteSubjectRequest{Subject: req.Subject, Policies: req.Policies}, nil
+}
+
+func encodeGRPCPolicyResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.PolicyResponse)
+	return &pb.PolicyResponse{Name: resp.Name, Policy: resp.Policy, Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCListPoliciesResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.ListPoliciesResponse)
+	return &pb.ListPoliciesResponse{Policies: resp.Policies, Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCSubjectResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.SubjectResponse)
+	return &pb.SubjectResponse{Subject: resp.Subject, Policies: resp.Policies, Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCPolicyRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.PolicyRequest)
+	return &pb.PolicyRequest{Name: req.Name}, nil
+}
+
+func encodeGRPCWritePolicyRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.WritePolicyRequest)
+	return &pb.WritePolicyRequest{Name: req.Name, Policy: req.Policy}, nil
+}
+
+func encodeGRPCListPoliciesRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.ListPoliciesRequest{}, nil
+}
+
+func encodeGRPCSubjectRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.SubjectRequest)
+	return &pb.SubjectRequest{Subject: req.Subject}, nil
+}
+
+func encodeGRPCWriteSubjectRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.WriteSubjectRequest)
+	return &pb.WriteSubjectRequest{Subject: req.Subject, Policies: req.Policies}, nil
+}
+
+func decodeGRPCPolicyResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.PolicyResponse)
+	return vaultendpoint.PolicyResponse{Name: reply.Name, Policy: reply.Policy, Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCListPoliciesResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.ListPoliciesResponse)
+	return vaultendpoint.ListPoliciesResponse{Policies: reply.Policies, Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCSubjectResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.SubjectResponse)
+	return vaultendpoint.SubjectResponse{Subject: reply.Subject, Policies: reply.Policies, Err: str2err(reply.Err)}, nil
+}
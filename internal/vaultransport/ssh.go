@@ -0,0 +1,394 @@
+package vaultransport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	httptransport "github.com/go-kit/kit/transport/http"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+	"github.com/sony/gobreaker"
+	"google.golang.org/grpc"
+
+	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaultservice"
+	"github.com/williamlsh/vault/pb"
+)
+
+const sshPublicKeyPath = "/ssh/public_key"
+
+// registerSSHHandlers makes the public key of the SSH CA available as plain
+// text on /ssh/public_key, for sshd TrustedUserCAKeys.
+func registerSSHHandlers(m *http.ServeMux, endpoints vaultendpoint.SSHSet, options []httptransport.ServerOption, otTracer stdopentracing.Tracer, logger log.Logger) {
+	m.Handle(sshPublicKeyPath, methodMux{
+		http.MethodGet: httptransport.NewServer(
+			endpoints.ReadCAEndpoint,
+			decodeHTTPSSHReadCARequest,
+			encodeHTTPSSHPublicKeyResponse,
+			append(options, httptransport.ServerBefore(opentracing.HTTPToContext(otTracer, "SSHReadCA", logger)))...,
+		),
+	})
+}
+
+// NewHTTPSSHClient returns an SSHService backed by an HTTP server living at
+// the remote instance.
+func NewHTTPSSHClient(instance string, tlsOpts ClientTLS, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) (vaultservice.SSHService, error) {
+	c, err := gatewayClient(instance, tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+	before := []grpctransport.ClientRequestFunc{
+		opentracing.ContextToGRPC(otTracer, logger),
+		jwt.ContextToGRPC(),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = newGatewayEndpoint(c, "pb.SSH", method, enc, dec, reply, before...)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.SSHSet{
+		ConfigureCAEndpoint: endpointFor("ConfigureCA", encodeGRPCSSHConfigureCARequest, decodeGRPCSSHCAResponse, pb.SSHCAResponse{}),
+		ReadCAEndpoint:      endpointFor("ReadCA", encodeGRPCSSHReadCARequest, decodeGRPCSSHCAResponse, pb.SSHCAResponse{}),
+		WriteRoleEndpoint:   endpointFor("WriteRole", encodeGRPCSSHWriteRoleRequest, decodeGRPCSSHRoleResponse, pb.SSHRoleResponse{}),
+		ReadRoleEndpoint:    endpointFor("ReadRole", encodeGRPCSSHRoleRequest, decodeGRPCSSHRoleResponse, pb.SSHRoleResponse{}),
+		DeleteRoleEndpoint:  endpointFor("DeleteRole", encodeGRPCSSHRoleRequest, decodeGRPCSSHRoleResponse, pb.SSHRoleResponse{}),
+		ListRolesEndpoint:   endpointFor("ListRoles", encodeGRPCSSHListRolesRequest, decodeGRPCSSHListRolesResponse, pb.SSHListRolesResponse{}),
+		SignEndpoint:        endpointFor("Sign", encodeGRPCSSHSignRequest, decodeGRPCSSHCertificateResponse, pb.SSHCertificateResponse{}),
+	}, nil
+}
+
+func decodeHTTPSSHReadCARequest(_ context.Context, _ *http.Request) (interface{}, error) {
+	return vaultendpoint.SSHReadCARequest{}, nil
+}
+
+// encodeHTTPSSHPublicKeyResponse writes the public key of the CA as is, to be
+// fetched into the TrustedUserCAKeys file of sshd.
+func encodeHTTPSSHPublicKeyResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(vaultendpoint.SSHCAResponse)
+	if resp.Err != nil {
+		errorEncoder(ctx, resp.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err := io.WriteString(w, resp.PublicKey)
+	return err
+}
+
+type grpcSSHServer struct {
+	configureCA grpctransport.Handler
+	readCA      grpctransport.Handler
+	writeRole   grpctransport.Handler
+	readRole    grpctransport.Handler
+	deleteRole  grpctransport.Handler
+	listRoles   grpctransport.Handler
+	sign        grpctransport.Handler
+}
+
+// NewGRPCSSHServer makes the SSH endpoints available as a gRPC SSHServer.
+func NewGRPCSSHServer(endpoints vaultendpoint.SSHSet, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) pb.SSHServer {
+	options := grpcServerOptions(zipkinTracer, logger)
+	handler := func(name string, e endpoint.Endpoint, dec grpctransport.DecodeRequestFunc, enc grpctransport.EncodeResponseFunc) grpctransport.Handler {
+		return grpctransport.NewServer(
+			failedAsError(e),
+			dec,
+			enc,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, name, logger)))...,
+		)
+	}
+	return &grpcSSHServer{
+		configureCA: handler("SSHConfigureCA", endpoints.ConfigureCAEndpoint, decodeGRPCSSHConfigureCARequest, encodeGRPCSSHCAResponse),
+		readCA:      handler("SSHReadCA", endpoints.ReadCAEndpoint, decodeGRPCSSHReadCARequest, encodeGRPCSSHCAResponse),
+		writeRole:   handler("SSHWriteRole", endpoints.WriteRoleEndpoint, decodeGRPCSSHWriteRoleRequest, encodeGRPCSSHRoleResponse),
+		readRole:    handler("SSHReadRole", endpoints.ReadRoleEndpoint, decodeGRPCSSHRoleRequest, encodeGRPCSSHRoleResponse),
+		deleteRole:  handler("SSHDeleteRole", endpoints.DeleteRoleEndpoint, decodeGRPCSSHRoleRequest, encodeGRPCSSHRoleResponse),
+		listRoles:   handler("SSHListRoles", endpoints.ListRolesEndpoint, decodeGRPCSSHListRolesRequest, encodeGRPCSSHListRolesResponse),
+		sign:        handler("SSHSign", endpoints.SignEndpoint, decodeGRPCSSHSignRequest, encodeGRPCSSHCertificateResponse),
+	}
+}
+
+// NewGRPCSSHClient returns an SSHService backed by a gRPC server at the other
+// end of the conn.
+func NewGRPCSSHClient(conn *grpc.ClientConn, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) vaultservice.SSHService {
+	options := []grpctransport.ClientOption{
+		grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)),
+		grpctransport.ClientBefore(jwt.ContextToGRPC()),
+		zipkin.GRPCClientTrace(zipkinTracer),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = grpctransport.NewClient(conn, "pb.SSH", method, enc, dec, reply, options...).Endpoint()
+		e = grpcErrorDecoder(e)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.SSHSet{
+		ConfigureCAEndpoint: endpointFor("ConfigureCA", encodeGRPCSSHConfigureCARequest, decodeGRPCSSHCAResponse, pb.SSHCAResponse{}),
+		ReadCAEndpoint:      endpointFor("ReadCA", encodeGRPCSSHReadCARequest, decodeGRPCSSHCAResponse, pb.SSHCAResponse{}),
+		WriteRoleEndpoint:   endpointFor("WriteRole", encodeGRPCSSHWriteRoleRequest, decodeGRPCSSHRoleResponse, pb.SSHRoleResponse{}),
+		ReadRoleEndpoint:    endpointFor("ReadRole", encodeGRPCSSHRoleRequest, decodeGRPCSSHRoleResponse, pb.SSHRoleResponse{}),
+		DeleteRoleEndpoint:  endpointFor("DeleteRole", encodeGRPCSSHRoleRequest, decodeGRPCSSHRoleResponse, pb.SSHRoleResponse{}),
+		ListRolesEndpoint:   endpointFor("ListRoles", encodeGRPCSSHListRolesRequest, decodeGRPCSSHListRolesResponse, pb.SSHListRolesResponse{}),
+		SignEndpoint:        endpointFor("Sign", encodeGRPCSSHSignRequest, decodeGRPCSSHCertificateResponse, pb.SSHCertificateResponse{}),
+	}
+}
+
+func (s *grpcSSHServer) ConfigureCA(ctx context.Context, r *pb.SSHConfigureCARequest) (*pb.SSHCAResponse, error) {
+	_, resp, err := s.configureCA.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.SSHCAResponse), nil
+}
+
+func (s *grpcSSHServer) ReadCA(ctx context.Context, r *pb.SSHReadCARequest) (*pb.SSHCAResponse, error) {
+	_, resp, err := s.readCA.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.SSHCAResponse), nil
+}
+
+func (s *grpcSSHServer) WriteRole(ctx context.Context, r *pb.SSHWriteRoleRequest) (*pb.SSHRoleResponse, error) {
+	_, resp, err := s.writeRole.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.SSHRoleResponse), nil
+}
+
+func (s *grpcSSHServer) ReadRole(ctx context.Context, r *pb.SSHRoleRequest) (*pb.SSHRoleResponse, error) {
+	_, resp, err := s.readRole.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.SSHRoleResponse), nil
+}
+
+func (s *grpcSSHServer) DeleteRole(ctx context.Context, r *pb.SSHRoleRequest) (*pb.SSHRoleResponse, error) {
+	_, resp, err := s.deleteRole.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.SSHRoleResponse), nil
+}
+
+func (s *grpcSSHServer) ListRoles(ctx context.Context, r *pb.SSHListRolesRequest) (*pb.SSHListRolesResponse, error) {
+	_, resp, err := s.listRoles.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.SSHListRolesResponse), nil
+}
+
+func (s *grpcSSHServer) Sign(ctx context.Context, r *pb.SSHSignRequest) (*pb.SSHCertificateResponse, error) {
+	_, resp, err := s.sign.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.SSHCertificateResponse), nil
+}
+
+func decodeGRPCSSHConfigureCARequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.SSHConfigureCARequest)
+	return vaultendpoint.SSHConfigureCARequest{
+		SSHCAOptions: vaultservice.SSHCAOptions{
+			PrivateKey: req.PrivateKey,
+			KeyType:    req.KeyType,
+			KeyBits:    int(req.KeyBits),
+		},
+	}, nil
+}
+
+func decodeGRPCSSHReadCARequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return vaultendpoint.SSHReadCARequest{}, nil
+}
+
+func decodeGRPCSSHWriteRoleRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.SSHWriteRoleRequest)
+	return vaultendpoint.SSHWriteRoleRequest{Name: req.Name, SSHRole: fromPBSSHRole(req.Role)}, nil
+}
+
+func decodeGRPCSSHRoleRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.SSHRoleRequest)
+	return vaultendpoint.SSHRoleRequest{Name: req.Name}, nil
+}
+
+func decodeGRPCSSHListRolesRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return vaultendpoint.SSHListRolesRequest{}, nil
+}
+
+func decodeGRPCSSHSignRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.SSHSignRequest)
+	return vaultendpoint.SSHSignRequest{
+		Role: req.Role,
+		SSHSignRequest: vaultservice.SSHSignRequest{
+			PublicKey:       req.PublicKey,
+			CertType:        req.CertType,
+			ValidPrincipals: req.ValidPrincipals,
+			TTL:             req.Ttl,
+			CriticalOptions: req.CriticalOptions,
+			Extensions:      req.Extensions,
+		},
+	}, nil
+}
+
+func encodeGRPCSSHCAResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.SSHCAResponse)
+	return &pb.SSHCAResponse{PublicKey: resp.PublicKey, Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCSSHRoleResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.SSHRoleResponse)
+	return &pb.SSHRoleResponse{Name: resp.Name, Role: toPBSSHRole(resp.SSHRole), Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCSSHListRolesResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.ListRolesResponse)
+	return &pb.SSHListRolesResponse{Roles: resp.Roles, Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCSSHCertificateResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.SSHCertificateResponse)
+	return &pb.SSHCertificateResponse{
+		SerialNumber: resp.SerialNumber,
+		SignedKey:    resp.SignedKey,
+		Expiration:   unixNano(resp.Expiration),
+		Err:          err2str(resp.Err),
+	}, nil
+}
+
+func encodeGRPCSSHConfigureCARequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.SSHConfigureCARequest)
+	return &pb.SSHConfigureCARequest{
+		PrivateKey: req.PrivateKey,
+		KeyType:    req.KeyType,
+		KeyBits:    int32(req.KeyBits),
+	}, nil
+}
+
+func encodeGRPCSSHReadCARequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.SSHReadCARequest{}, nil
+}
+
+func encodeGRPCSSHWriteRoleRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.SSHWriteRoleRequest)
+	return &pb.SSHWriteRoleRequest{Name: req.Name, Role: toPBSSHRole(req.SSHRole)}, nil
+}
+
+func encodeGRPCSSHRoleRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.SSHRoleRequest)
+	return &pb.SSHRoleRequest{Name: req.Name}, nil
+}
+
+func encodeGRPCSSHListRolesRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.SSHListRolesRequest{}, nil
+}
+
+func encodeGRPCSSHSignRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.SSHSignRequest)
+	return &pb.SSHSignRequest{
+		Role:            req.Role,
+		PublicKey:       req.PublicKey,
+		CertType:        req.CertType,
+		ValidPrincipals: req.ValidPrincipals,
+		Ttl:             req.TTL,
+		CriticalOptions: req.CriticalOptions,
+		Extensions:      req.Extensions,
+	}, nil
+}
+
+func decodeGRPCSSHCAResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.SSHCAResponse)
+	return vaultendpoint.SSHCAResponse{SSHCA: vaultservice.SSHCA{PublicKey: reply.PublicKey}, Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCSSHRoleResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.SSHRoleResponse)
+	return vaultendpoint.SSHRoleResponse{Name: reply.Name, SSHRole: fromPBSSHRole(reply.Role), Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCSSHListRolesResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.SSHListRolesResponse)
+	return vaultendpoint.ListRolesResponse{Roles: reply.Roles, Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCSSHCertificateResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.SSHCertificateResponse)
+	return vaultendpoint.SSHCertificateResponse{
+		SSHCertificate: vaultservice.SSHCertificate{
+			SerialNumber: reply.SerialNumber,
+			SignedKey:    reply.SignedKey,
+			Expiration:   fromUnixNano(reply.Expiration),
+		},
+		Err: str2err(reply.Err),
+	}, nil
+}
+
+func toPBSSHRole(r vaultservice.SSHRole) *pb.SSHRole {
+	return &pb.SSHRole{
+		AllowUserCertificates:  r.AllowUserCertificates,
+		AllowHostCertificates:  r.AllowHostCertificates,
+		AllowedUsers:           r.AllowedUsers,
+		DefaultUser:            r.DefaultUser,
+		AllowedDomains:         r.AllowedDomains,
+		AllowBareDomains:       r.AllowBareDomains,
+		AllowSubdomains:        r.AllowSubdomains,
+		Ttl:                    r.TTL,
+		MaxTtl:                 r.MaxTTL,
+		AllowedCriticalOptions: r.AllowedCriticalOptions,
+		DefaultCriticalOptions: r.DefaultCriticalOptions,
+		AllowedExtensions:      r.AllowedExtensions,
+		DefaultExtensions:      r.DefaultExtensions,
+		AllowedKeyTypes:        r.AllowedKeyTypes,
+	}
+}
+
+func fromPBSSHRole(r *pb.SSHRole) vaultservice.SSHRole {
+	if r == nil {
+		return vaultservice.SSHRole{}
+	}
+	return vaultservice.SSHRole{
+		AllowUserCertificates:  r.AllowUserCertificates,
+		AllowHostCertificates:  r.AllowHostCertificates,
+		AllowedUsers:           r.AllowedUsers,
+		DefaultUser:            r.DefaultUser,
+		AllowedDomains:         r.AllowedDomains,
+		AllowBareDomains:       r.AllowBareDomains,
+		AllowSubdomains:        r.AllowSubdomains,
+		TTL:                    r.Ttl,
+		MaxTTL:                 r.MaxTtl,
+		AllowedCriticalOptions: r.AllowedCriticalOptions,
+		DefaultCriticalOptions: r.DefaultCriticalOptions,
+		AllowedExtensions:      r.AllowedExtensions,
+		DefaultExtensions:      r.DefaultExtensions,
+		AllowedKeyTypes:        r.AllowedKeyTypes,
+	}
+}
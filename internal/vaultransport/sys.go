@@ -0,0 +1,320 @@
+package vaultransport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	httptransport "github.com/go-kit/kit/transport/http"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+	"github.com/sony/gobreaker"
+	"google.golang.org/grpc"
+
+	"github.com/williamlsh/vault/internal/seal"
+	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaultservice"
+	"github.com/williamlsh/vault/pb"
+)
+
+// registerSysHandlers makes the health check available on /sys/health, with
+// the seal status as its status code for load balancers.
+func registerSysHandlers(m *http.ServeMux, endpoints vaultendpoint.SysSet, options []httptransport.ServerOption, otTracer stdopentracing.Tracer, logger log.Logger) {
+	m.Handle("/sys/health", httptransport.NewServer(
+		endpoints.SealStatusEndpoint,
+		decodeHTTPSealStatusRequest,
+		encodeHTTPHealthResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(otTracer, "SealStatus", logger)))...,
+	))
+}
+
+// NewHTTPSysClient returns a SysService backed by an HTTP server living at
+// the remote instance.
+func NewHTTPSysClient(instance string, tlsOpts ClientTLS, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) (vaultservice.SysService, error) {
+	c, err := gatewayClient(instance, tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+	before := []grpctransport.ClientRequestFunc{
+		opentracing.ContextToGRPC(otTracer, logger),
+		jwt.ContextToGRPC(),
+	}
+
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = newGatewayEndpoint(c, "pb.Sys", method, enc, dec, reply, before...)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.SysSet{
+		InitEndpoint:       endpointFor("Init", encodeGRPCInitRequest, decodeGRPCInitResponse, pb.InitResponse{}),
+		UnsealEndpoint:     endpointFor("Unseal", encodeGRPCUnsealRequest, decodeGRPCSealStatusResponse, pb.SealStatusResponse{}),
+		SealEndpoint:       newTokenSetter(tokens)(endpointFor("Seal", encodeGRPCSealRequest, decodeGRPCSealResponse, pb.SealResponse{})),
+		SealStatusEndpoint: endpointFor("SealStatus", encodeGRPCSealStatusRequest, decodeGRPCSealStatusResponse, pb.SealStatusResponse{}),
+		RotateEndpoint:     newTokenSetter(tokens)(endpointFor("Rotate", encodeGRPCRotateRequest, decodeGRPCRotateResponse, pb.RotateResponse{})),
+	}, nil
+}
+
+// httpClient parses the instance URL and returns a client reaching it with
+// the TLS options, and wrapping the responses of the calls made WithWrapTTL.
+func httpClient(instance string, tlsOpts ClientTLS) (*url.URL, *http.Client, error) {
+	u, err := url.Parse(httpsURL(instance))
+	if err != nil {
+		return nil, nil, err
+	}
+	config, err := tlsOpts.Config()
+	if err != nil {
+		return nil, nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	return u, &http.Client{Transport: wrappingRoundTripper{next: transport}}, nil
+}
+
+func decodeHTTPSealStatusRequest(_ context.Context, _ *http.Request) (interface{}, error) {
+	return vaultendpoint.SealStatusRequest{}, nil
+}
+
+// encodeHTTPHealthResponse reports the seal status with a status code load
+// balancers understand: 200 when unsealed, 501 when not initialized and 503
+// when sealed.
+func encodeHTTPHealthResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(vaultendpoint.SealStatusResponse)
+	if resp.Err != nil {
+		errorEncoder(ctx, resp.Err, w)
+		return nil
+	}
+	code := http.StatusOK
+	switch {
+	case !resp.Initialized:
+		code = http.StatusNotImplemented
+	case resp.Sealed:
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	return json.NewEncoder(w).Encode(resp)
+}
+
+type grpcSysServer struct {
+	init       grpctransport.Handler
+	unseal     grpctransport.Handler
+	seal       grpctransport.Handler
+	sealStatus grpctransport.Handler
+	rotate     grpctransport.Handler
+}
+
+// NewGRPCSysServer makes the system endpoints available as a gRPC SysServer.
+func NewGRPCSysServer(endpoints vaultendpoint.SysSet, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) pb.SysServer {
+	options := grpcServerOptions(zipkinTracer, logger)
+	handler := func(name string, e endpoint.Endpoint, dec grpctransport.DecodeRequestFunc, enc grpctransport.EncodeResponseFunc) grpctransport.Handler {
+		return grpctransport.NewServer(
+			failedAsError(e),
+			dec,
+			enc,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, name, logger)))...,
+		)
+	}
+	return &grpcSysServer{
+		init:       handler("Init", endpoints.InitEndpoint, decodeGRPCInitRequest, encodeGRPCInitResponse),
+		unseal:     handler("Unseal", endpoints.UnsealEndpoint, decodeGRPCUnsealRequest, encodeGRPCSealStatusResponse),
+		seal:       handler("Seal", endpoints.SealEndpoint, decodeGRPCSealRequest, encodeGRPCSealResponse),
+		sealStatus: handler("SealStatus", endpoints.SealStatusEndpoint, decodeGRPCSealStatusRequest, encodeGRPCSealStatusResponse),
+		rotate:     handler("Rotate", endpoints.RotateEndpoint, decodeGRPCRotateRequest, encodeGRPCRotateResponse),
+	}
+}
+
+// NewGRPCSysClient returns a SysService backed by a gRPC server at the other
+// end of the conn.
+func NewGRPCSysClient(conn *grpc.ClientConn, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) vaultservice.SysService {
+	options := []grpctransport.ClientOption{
+		grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)),
+		grpctransport.ClientBefore(jwt.ContextToGRPC()),
+		zipkin.GRPCClientTrace(zipkinTracer),
+	}
+
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = grpctransport.NewClient(conn, "pb.Sys", method, enc, dec, reply, options...).Endpoint()
+		e = grpcErrorDecoder(e)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.SysSet{
+		InitEndpoint:       endpointFor("Init", encodeGRPCInitRequest, decodeGRPCInitResponse, pb.InitResponse{}),
+		UnsealEndpoint:     endpointFor("Unseal", encodeGRPCUnsealRequest, decodeGRPCSealStatusResponse, pb.SealStatusResponse{}),
+		SealEndpoint:       newTokenSetter(tokens)(endpointFor("Seal", encodeGRPCSealRequest, decodeGRPCSealResponse, pb.SealResponse{})),
+		SealStatusEndpoint: endpointFor("SealStatus", encodeGRPCSealStatusRequest, decodeGRPCSealStatusResponse, pb.SealStatusResponse{}),
+		RotateEndpoint:     newTokenSetter(tokens)(endpointFor("Rotate", encodeGRPCRotateRequest, decodeGRPCRotateResponse, pb.RotateResponse{})),
+	}
+}
+
+func (s *grpcSysServer) Init(ctx context.Context, r *pb.InitRequest) (*pb.InitResponse, error) {
+	_, resp, err := s.init.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.InitResponse), nil
+}
+
+func (s *grpcSysServer) Unseal(ctx context.Context, r *pb.UnsealRequest) (*pb.SealStatusResponse, error) {
+	_, resp, err := s.unseal.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.SealStatusResponse), nil
+}
+
+func (s *grpcSysServer) Seal(ctx context.Context, r *pb.SealRequest) (*pb.SealResponse, error) {
+	_, resp, err := s.seal.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.SealResponse), nil
+}
+
+func (s *grpcSysServer) SealStatus(ctx context.Context, r *pb.SealStatusRequest) (*pb.SealStatusResponse, error) {
+	_, resp, err := s.sealStatus.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.SealStatusResponse), nil
+}
+
+func (s *grpcSysServer) Rotate(ctx context.Context, r *pb.RotateRequest) (*pb.RotateResponse, error) {
+	_, resp, err := s.rotate.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.RotateResponse), nil
+}
+
+func decodeGRPCInitRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.InitRequest)
+	return vaultendpoint.InitRequest{SecretShares: int(req.SecretShares), SecretThreshold: int(req.SecretThreshold)}, nil
+}
+
+func decodeGRPCUnsealRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.UnsealRequest)
+	return vaultendpoint.UnsealRequest{Key: req.Key, Reset: req.ResetProgress}, nil
+}
+
+func decodeGRPCSealRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return vaultendpoint.SealRequest{}, nil
+}
+
+func decodeGRPCSealStatusRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return vaultendpoint.SealStatusRequest{}, nil
+}
+
+func encodeGRPCInitResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.InitResponse)
+	return &pb.InitResponse{Keys: resp.Keys, RootToken: resp.RootToken, Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCSealResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.SealResponse)
+	return &pb.SealResponse{Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCSealStatusResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.SealStatusResponse)
+	return &pb.SealStatusResponse{
+		Initialized: resp.Initialized,
+		Sealed:      resp.Sealed,
+		T:           int32(resp.Threshold),
+		N:           int32(resp.Shares),
+		Progress:    int32(resp.Progress),
+		Err:         err2str(resp.Err),
+		AutoUnseal:  resp.AutoUnseal,
+	}, nil
+}
+
+func encodeGRPCInitRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.InitRequest)
+	return &pb.InitRequest{SecretShares: int32(req.SecretShares), SecretThreshold: int32(req.SecretThreshold)}, nil
+}
+
+func encodeGRPCUnsealRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.UnsealRequest)
+	return &pb.UnsealRequest{Key: req.Key, ResetProgress: req.Reset}, nil
+}
+
+func encodeGRPCSealRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.SealRequest{}, nil
+}
+
+func encodeGRPCSealStatusRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.SealStatusRequest{}, nil
+}
+
+func decodeGRPCInitResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.InitResponse)
+	return vaultendpoint.InitResponse{Keys: reply.Keys, RootToken: reply.RootToken, Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCSealResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.SealResponse)
+	return vaultendpoint.SealResponse{Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCSealStatusResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.SealStatusResponse)
+	return vaultendpoint.SealStatusResponse{
+		Status: seal.Status{
+			Initialized: reply.Initialized,
+			Sealed:      reply.Sealed,
+			Threshold:   int(reply.T),
+			Shares:      int(reply.N),
+			Progress:    int(reply.Progress),
+			AutoUnseal:  reply.AutoUnseal,
+		},
+		Err: str2err(reply.Err),
+	}, nil
+}
+
+func decodeGRPCRotateRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return vaultendpoint.RotateRequest{}, nil
+}
+
+func encodeGRPCRotateResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.RotateResponse)
+	reply := &pb.RotateResponse{Term: resp.Term, Err: err2str(resp.Err)}
+	if !resp.InstallTime.IsZero() {
+		reply.InstallTime = resp.InstallTime.Unix()
+	}
+	return reply, nil
+}
+
+func encodeGRPCRotateRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.RotateRequest{}, nil
+}
+
+func decodeGRPCRotateResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.RotateResponse)
+	return vaultendpoint.RotateResponse{
+		KeyStatus: seal.KeyStatus{Term: reply.Term, InstallTime: time.Unix(reply.InstallTime, 0).UTC()},
+		Err:       str2err(reply.Err),
+	}, nil
+}
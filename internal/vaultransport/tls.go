@@ -0,0 +1,90 @@
+package vaultransport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// ClientTLS configures the TLS connections of the HTTP and gRPC clients.
+type ClientTLS struct {
+	// CAFile is a PEM bundle of the CA certificates verifying the server,
+	// the system roots if empty.
+	CAFile string
+	// ServerName overrides the name the server certificate is verified
+	// against, the host of the address if empty.
+	ServerName string
+	// CertFile and KeyFile are the PEM certificate and key the client
+	// authenticates with, if any.
+	CertFile string
+	KeyFile  string
+	// MinVersion is the minimum TLS version, TLS 1.2 if zero.
+	MinVersion uint16
+	// Insecure skips the verification of the server certificate, which
+	// leaves the connections open to interception. Only meant for tests.
+	Insecure bool
+}
+
+// Config returns the TLS configuration of the options.
+func (o ClientTLS) Config() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         o.ServerName,
+		MinVersion:         o.MinVersion,
+		InsecureSkipVerify: o.Insecure,
+	}
+	if config.MinVersion == 0 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	if o.CAFile != "" {
+		raw, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(raw) {
+			return nil, fmt.Errorf("no CA certificate in %s", o.CAFile)
+		}
+	}
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return nil, errors.New("client certificate and key must be given together")
+	}
+	if o.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// Credentials returns the gRPC transport credentials of the options.
+func (o ClientTLS) Credentials() (credentials.TransportCredentials, error) {
+	config, err := o.Config()
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// ParseTLSVersion parses a TLS version such as "1.2", returning zero for an
+// empty string.
+func ParseTLSVersion(s string) (uint16, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version %q", s)
+}
@@ -0,0 +1,245 @@
+package vaultransport
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+	"github.com/sony/gobreaker"
+	"google.golang.org/grpc"
+
+	"github.com/williamlsh/vault/internal/token"
+	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaultservice"
+	"github.com/williamlsh/vault/pb"
+)
+
+// NewHTTPTokenClient returns a TokenService backed by an HTTP server living
+// at the remote instance.
+func NewHTTPTokenClient(instance string, tlsOpts ClientTLS, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) (vaultservice.TokenService, error) {
+	c, err := gatewayClient(instance, tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+	before := []grpctransport.ClientRequestFunc{
+		opentracing.ContextToGRPC(otTracer, logger),
+		jwt.ContextToGRPC(),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = newGatewayEndpoint(c, "pb.Token", method, enc, decodeGRPCTokenResponse, pb.TokenResponse{}, before...)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.TokenSet{
+		CreateEndpoint:       endpointFor("Create", encodeGRPCCreateTokenRequest),
+		LookupEndpoint:       endpointFor("Lookup", encodeGRPCTokenRequest),
+		LookupSelfEndpoint:   endpointFor("LookupSelf", encodeGRPCTokenRequest),
+		RenewEndpoint:        endpointFor("Renew", encodeGRPCRenewTokenRequest),
+		RenewSelfEndpoint:    endpointFor("RenewSelf", encodeGRPCRenewTokenRequest),
+		RevokeEndpoint:       endpointFor("Revoke", encodeGRPCTokenRequest),
+		RevokeSelfEndpoint:   endpointFor("RevokeSelf", encodeGRPCTokenRequest),
+		RevokeOrphanEndpoint: endpointFor("RevokeOrphan", encodeGRPCTokenRequest),
+	}, nil
+}
+
+type grpcTokenServer struct {
+	create       grpctransport.Handler
+	lookup       grpctransport.Handler
+	lookupSelf   grpctransport.Handler
+	renew        grpctransport.Handler
+	renewSelf    grpctransport.Handler
+	revoke       grpctransport.Handler
+	revokeSelf   grpctransport.Handler
+	revokeOrphan grpctransport.Handler
+}
+
+// NewGRPCTokenServer makes the token endpoints available as a gRPC
+// TokenServer.
+func NewGRPCTokenServer(endpoints vaultendpoint.TokenSet, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) pb.TokenServer {
+	options := grpcServerOptions(zipkinTracer, logger)
+	handler := func(name string, e endpoint.Endpoint, dec grpctransport.DecodeRequestFunc) grpctransport.Handler {
+		return grpctransport.NewServer(
+			failedAsError(e),
+			dec,
+			encodeGRPCTokenResponse,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, name, logger)))...,
+		)
+	}
+	return &grpcTokenServer{
+		create:       handler("TokenCreate", endpoints.CreateEndpoint, decodeGRPCCreateTokenRequest),
+		lookup:       handler("TokenLookup", endpoints.LookupEndpoint, decodeGRPCTokenRequest),
+		lookupSelf:   handler("TokenLookupSelf", endpoints.LookupSelfEndpoint, decodeGRPCTokenRequest),
+		renew:        handler("TokenRenew", endpoints.RenewEndpoint, decodeGRPCRenewTokenRequest),
+		renewSelf:    handler("TokenRenewSelf", endpoints.RenewSelfEndpoint, decodeGRPCRenewTokenRequest),
+		revoke:       handler("TokenRevoke", endpoints.RevokeEndpoint, decodeGRPCTokenRequest),
+		revokeSelf:   handler("TokenRevokeSelf", endpoints.RevokeSelfEndpoint, decodeGRPCTokenRequest),
+		revokeOrphan: handler("TokenRevokeOrphan", endpoints.RevokeOrphanEndpoint, decodeGRPCTokenRequest),
+	}
+}
+
+// NewGRPCTokenClient returns a TokenService backed by a gRPC server at the
+// other end of the conn.
+func NewGRPCTokenClient(conn *grpc.ClientConn, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) vaultservice.TokenService {
+	options := []grpctransport.ClientOption{
+		grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)),
+		grpctransport.ClientBefore(jwt.ContextToGRPC()),
+		zipkin.GRPCClientTrace(zipkinTracer),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = grpctransport.NewClient(conn, "pb.Token", method, enc, decodeGRPCTokenResponse, pb.TokenResponse{}, options...).Endpoint()
+		e = grpcErrorDecoder(e)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.TokenSet{
+		CreateEndpoint:       endpointFor("Create", encodeGRPCCreateTokenRequest),
+		LookupEndpoint:       endpointFor("Lookup", encodeGRPCTokenRequest),
+		LookupSelfEndpoint:   endpointFor("LookupSelf", encodeGRPCTokenRequest),
+		RenewEndpoint:        endpointFor("Renew", encodeGRPCRenewTokenRequest),
+		RenewSelfEndpoint:    endpointFor("RenewSelf", encodeGRPCRenewTokenRequest),
+		RevokeEndpoint:       endpointFor("Revoke", encodeGRPCTokenRequest),
+		RevokeSelfEndpoint:   endpointFor("RevokeSelf", encodeGRPCTokenRequest),
+		RevokeOrphanEndpoint: endpointFor("RevokeOrphan", encodeGRPCTokenRequest),
+	}
+}
+
+func (s *grpcTokenServer) Create(ctx context.Context, r *pb.CreateTokenRequest) (*pb.TokenResponse, error) {
+	return serveGRPCToken(ctx, s.create, r)
+}
+
+func (s *grpcTokenServer) Lookup(ctx context.Context, r *pb.TokenRequest) (*pb.TokenResponse, error) {
+	return serveGRPCToken(ctx, s.lookup, r)
+}
+
+func (s *grpcTokenServer) LookupSelf(ctx context.Context, r *pb.TokenRequest) (*pb.TokenResponse, error) {
+	return serveGRPCToken(ctx, s.lookupSelf, r)
+}
+
+func (s *grpcTokenServer) Renew(ctx context.Context, r *pb.RenewTokenRequest) (*pb.TokenResponse, error) {
+	return serveGRPCToken(ctx, s.renew, r)
+}
+
+func (s *grpcTokenServer) RenewSelf(ctx context.Context, r *pb.RenewTokenRequest) (*pb.TokenResponse, error) {
+	return serveGRPCToken(ctx, s.renewSelf, r)
+}
+
+func (s *grpcTokenServer) Revoke(ctx context.Context, r *pb.TokenRequest) (*pb.TokenResponse, error) {
+	return serveGRPCToken(ctx, s.revoke, r)
+}
+
+func (s *grpcTokenServer) RevokeSelf(ctx context.Context, r *pb.TokenRequest) (*pb.TokenResponse, error) {
+	return serveGRPCToken(ctx, s.revokeSelf, r)
+}
+
+func (s *grpcTokenServer) RevokeOrphan(ctx context.Context, r *pb.TokenRequest) (*pb.TokenResponse, error) {
+	return serveGRPCToken(ctx, s.revokeOrphan, r)
+}
+
+func serveGRPCToken(ctx context.Context, h grpctransport.Handler, r interface{}) (*pb.TokenResponse, error) {
+	_, resp, err := h.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.TokenResponse), nil
+}
+
+func decodeGRPCCreateTokenRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.CreateTokenRequest)
+	return vaultendpoint.CreateTokenRequest{TokenOptions: vaultservice.TokenOptions{
+		Policies:        req.Policies,
+		NoDefaultPolicy: req.NoDefaultPolicy,
+		TTL:             req.Ttl,
+		DisplayName:     req.DisplayName,
+		NoParent:        req.NoParent,
+	}}, nil
+}
+
+func decodeGRPCTokenRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.TokenRequest)
+	return vaultendpoint.TokenRequest{Token: req.Token}, nil
+}
+
+func decodeGRPCRenewTokenRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.RenewTokenRequest)
+	return vaultendpoint.RenewTokenRequest{Token: req.Token, Increment: req.Increment}, nil
+}
+
+func encodeGRPCTokenResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.TokenResponse)
+	return &pb.TokenResponse{
+		Token:        resp.ID,
+		Policies:     resp.Policies,
+		DisplayName:  resp.DisplayName,
+		CreationTime: unixNano(resp.CreationTime),
+		ExpireTime:   unixNano(resp.ExpireTime),
+		LeaseId:      resp.LeaseID,
+		Orphan:       resp.Orphan,
+		Err:          err2str(resp.Err),
+	}, nil
+}
+
+func encodeGRPCCreateTokenRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.CreateTokenRequest)
+	return &pb.CreateTokenRequest{
+		Policies:        req.Policies,
+		NoDefaultPolicy: req.NoDefaultPolicy,
+		Ttl:             req.TTL,
+		DisplayName:     req.DisplayName,
+		NoParent:        req.NoParent,
+	}, nil
+}
+
+func encodeGRPCTokenRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.TokenRequest)
+	return &pb.TokenRequest{Token: req.Token}, nil
+}
+
+func encodeGRPCRenewTokenRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.RenewTokenRequest)
+	return &pb.RenewTokenRequest{Token: req.Token, Increment: req.Increment}, nil
+}
+
+func decodeGRPCTokenResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.TokenResponse)
+	t := token.Token{
+		ID:           reply.Token,
+		Policies:     reply.Policies,
+		DisplayName:  reply.DisplayName,
+		CreationTime: fromUnixNano(reply.CreationTime),
+		ExpireTime:   fromUnixNano(reply.ExpireTime),
+		LeaseID:      reply.LeaseId,
+	}
+	resp := vaultendpoint.TokenResponse{Token: t, Orphan: reply.Orphan, Err: str2err(reply.Err)}
+	if !t.ExpireTime.IsZero() {
+		resp.TTL = int64(time.Until(t.ExpireTime) / time.Second)
+	}
+	return resp, nil
+}
@@ -0,0 +1,255 @@
+package vaultransport
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/endpoint"
+	stdjwt "github.com/golang-jwt/jwt/v4"
+
+	"github.com/williamlsh/vault/internal/vaultservice"
+)
+
+// tokenExpiryDelta is how long before their expiry cached tokens are
+// replaced, so that a token does not expire in flight.
+const tokenExpiryDelta = 10 * time.Second
+
+// TokenSource supplies the bearer tokens the clients authenticate their
+// requests with. The clients are passed their source when constructed, a nil
+// source sending no token, as for the logins of the token sources.
+type TokenSource interface {
+	// Token returns a token valid for the next request.
+	Token(ctx context.Context) (string, error)
+}
+
+// newTokenSetter returns a client scope endpoint middleware putting a token
+// of the source into the request context, from where it is sent as a bearer
+// token. The requests a token source sends to obtain its tokens are sent
+// without a token.
+func newTokenSetter(tokens TokenSource) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, err := withToken(ctx, tokens)
+			if err != nil {
+				return nil, err
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// withToken returns the context with a token of the source, unless the source
+// is nil or it is the context of a request of a token source.
+func withToken(ctx context.Context, tokens TokenSource) (context.Context, error) {
+	if tokens == nil || ctx.Value(tokenSourceKey{}) != nil {
+		return ctx, nil
+	}
+	t, err := tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, jwt.JWTContextKey, t), nil
+}
+
+type tokenSourceKey struct{}
+
+// StaticTokenSource returns a TokenSource always supplying the token, such as
+// a service token issued by vaultd.
+func StaticTokenSource(token string) TokenSource {
+	return staticTokenSource(token)
+}
+
+type staticTokenSource string
+
+func (s staticTokenSource) Token(context.Context) (string, error) {
+	return string(s), nil
+}
+
+// TokenFunc obtains a token, and its expiry time which is zero if the token
+// does not expire.
+type TokenFunc func(ctx context.Context) (token string, expiry time.Time, err error)
+
+// NewCachingTokenSource returns a TokenSource supplying the tokens of fetch,
+// each until shortly before its expiry.
+func NewCachingTokenSource(fetch TokenFunc) TokenSource {
+	return &cachingTokenSource{fetch: fetch, now: time.Now}
+}
+
+type cachingTokenSource struct {
+	fetch TokenFunc
+	now   func() time.Time
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func (s *cachingTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && (s.expiry.IsZero() || s.now().Add(tokenExpiryDelta).Before(s.expiry)) {
+		return s.token, nil
+	}
+	t, expiry, err := s.fetch(context.WithValue(ctx, tokenSourceKey{}, true))
+	if err != nil {
+		return "", err
+	}
+	s.token, s.expiry = t, expiry
+	return t, nil
+}
+
+// NewOAuthTokenSource returns a TokenSource supplying OAuth2 access tokens
+// issued by vaultd to the client with the scope, through the client
+// credentials grant. Access tokens are reused until shortly before they
+// expire.
+func NewOAuthTokenSource(svc vaultservice.OAuthService, creds vaultservice.ClientCredentials, scope string) TokenSource {
+	return NewCachingTokenSource(func(ctx context.Context) (string, time.Time, error) {
+		t, err := svc.Token(ctx, vaultservice.AccessTokenRequest{
+			ClientCredentials: creds,
+			GrantType:         vaultservice.GrantClientCredentials,
+			Scope:             scope,
+		})
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		return t.AccessToken, time.Now().Add(time.Duration(t.ExpiresIn) * time.Second), nil
+	})
+}
+
+// NewAppRoleTokenSource returns a TokenSource supplying the service tokens
+// issued by vaultd on AppRole logins with the role ID and secret ID. Tokens
+// are reused until shortly before they expire, a new login being made then.
+func NewAppRoleTokenSource(svc vaultservice.AppRoleService, roleID, secretID string) TokenSource {
+	return NewCachingTokenSource(func(ctx context.Context) (string, time.Time, error) {
+		t, err := svc.Login(ctx, roleID, secretID)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		return t.ID, t.ExpireTime, nil
+	})
+}
+
+// SelfSignedConfig configures the JWTs signed by the client itself, verified
+// by vaultd against the key set of their issuer.
+type SelfSignedConfig struct {
+	// Key is the private key signing the tokens, see LoadSigningKey.
+	Key crypto.Signer
+	// KeyID is the kid header naming the key in the key set.
+	KeyID    string
+	Issuer   string
+	Subject  string
+	Audience string
+	// Scope is the space separated list of the scopes claimed.
+	Scope string
+	// TTL is the lifetime of the tokens, one minute if zero.
+	TTL time.Duration
+}
+
+// NewSelfSignedTokenSource returns a TokenSource signing a new JWT for every
+// request. Every token has its own ID, so that vaultd may accept each token
+// once.
+func NewSelfSignedTokenSource(config SelfSignedConfig) (TokenSource, error) {
+	method, err := signingMethod(config.Key)
+	if err != nil {
+		return nil, err
+	}
+	if config.TTL == 0 {
+		config.TTL = time.Minute
+	}
+	return &selfSignedTokenSource{config: config, method: method}, nil
+}
+
+type selfSignedTokenSource struct {
+	config SelfSignedConfig
+	method stdjwt.SigningMethod
+}
+
+type selfSignedClaims struct {
+	stdjwt.RegisteredClaims
+	Scope string `json:"scope,omitempty"`
+}
+
+func (s *selfSignedTokenSource) Token(context.Context) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := selfSignedClaims{
+		RegisteredClaims: stdjwt.RegisteredClaims{
+			Issuer:    s.config.Issuer,
+			Subject:   s.config.Subject,
+			Audience:  stdjwt.ClaimStrings{s.config.Audience},
+			ExpiresAt: stdjwt.NewNumericDate(now.Add(s.config.TTL)),
+			IssuedAt:  stdjwt.NewNumericDate(now),
+			ID:        hex.EncodeToString(id),
+		},
+		Scope: s.config.Scope,
+	}
+	t := stdjwt.NewWithClaims(s.method, claims)
+	if s.config.KeyID != "" {
+		t.Header["kid"] = s.config.KeyID
+	}
+	return t.SignedString(s.config.Key)
+}
+
+// signingMethod returns the JWT signature algorithm of the key.
+func signingMethod(key crypto.Signer) (stdjwt.SigningMethod, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return stdjwt.SigningMethodRS256, nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			return stdjwt.SigningMethodES256, nil
+		case 384:
+			return stdjwt.SigningMethodES384, nil
+		case 521:
+			return stdjwt.SigningMethodES512, nil
+		}
+	case ed25519.PrivateKey:
+		return stdjwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("unsupported signing key %T", key)
+}
+
+// LoadSigningKey reads an RSA, ECDSA or Ed25519 private key from a PEM file,
+// in PKCS #8, PKCS #1 or SEC 1 form.
+func LoadSigningKey(path string) (crypto.Signer, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block in " + path)
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key %T", key)
+	}
+	return signer, nil
+}
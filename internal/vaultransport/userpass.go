@@ -0,0 +1,237 @@
+package vaultransport
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+	"github.com/sony/gobreaker"
+	"google.golang.org/grpc"
+
+	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaultservice"
+	"github.com/williamlsh/vault/pb"
+)
+
+// NewHTTPUserpassClient returns a UserpassService backed by an HTTP server
+// living at the remote instance.
+func NewHTTPUserpassClient(instance string, tlsOpts ClientTLS, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) (vaultservice.UserpassService, error) {
+	c, err := gatewayClient(instance, tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+	before := []grpctransport.ClientRequestFunc{
+		opentracing.ContextToGRPC(otTracer, logger),
+		jwt.ContextToGRPC(),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = newGatewayEndpoint(c, "pb.Userpass", method, enc, dec, reply, before...)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.UserpassSet{
+		ReadUserEndpoint:   endpointFor("ReadUser", encodeGRPCUserRequest, decodeGRPCUserResponse, pb.UserResponse{}),
+		WriteUserEndpoint:  endpointFor("WriteUser", encodeGRPCWriteUserRequest, decodeGRPCUserResponse, pb.UserResponse{}),
+		DeleteUserEndpoint: endpointFor("DeleteUser", encodeGRPCUserRequest, decodeGRPCUserResponse, pb.UserResponse{}),
+		ListUsersEndpoint:  endpointFor("ListUsers", encodeGRPCListUsersRequest, decodeGRPCListUsersResponse, pb.ListUsersResponse{}),
+		LoginEndpoint:      endpointFor("Login", encodeGRPCUserpassLoginRequest, decodeGRPCTokenResponse, pb.TokenResponse{}),
+	}, nil
+}
+
+type grpcUserpassServer struct {
+	readUser   grpctransport.Handler
+	writeUser  grpctransport.Handler
+	deleteUser grpctransport.Handler
+	listUsers  grpctransport.Handler
+	login      grpctransport.Handler
+}
+
+// NewGRPCUserpassServer makes the userpass endpoints available as a gRPC
+// UserpassServer.
+func NewGRPCUserpassServer(endpoints vaultendpoint.UserpassSet, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) pb.UserpassServer {
+	options := grpcServerOptions(zipkinTracer, logger)
+	handler := func(name string, e endpoint.Endpoint, dec grpctransport.DecodeRequestFunc, enc grpctransport.EncodeResponseFunc) grpctransport.Handler {
+		return grpctransport.NewServer(
+			failedAsError(e),
+			dec,
+			enc,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, name, logger)))...,
+		)
+	}
+	return &grpcUserpassServer{
+		readUser:   handler("ReadUser", endpoints.ReadUserEndpoint, decodeGRPCUserRequest, encodeGRPCUserResponse),
+		writeUser:  handler("WriteUser", endpoints.WriteUserEndpoint, decodeGRPCWriteUserRequest, encodeGRPCUserResponse),
+		deleteUser: handler("DeleteUser", endpoints.DeleteUserEndpoint, decodeGRPCUserRequest, encodeGRPCUserResponse),
+		listUsers:  handler("ListUsers", endpoints.ListUsersEndpoint, decodeGRPCListUsersRequest, encodeGRPCListUsersResponse),
+		login:      handler("UserpassLogin", endpoints.LoginEndpoint, decodeGRPCUserpassLoginRequest, encodeGRPCTokenResponse),
+	}
+}
+
+// NewGRPCUserpassClient returns a UserpassService backed by a gRPC server at
+// the other end of the conn.
+func NewGRPCUserpassClient(conn *grpc.ClientConn, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) vaultservice.UserpassService {
+	options := []grpctransport.ClientOption{
+		grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)),
+		grpctransport.ClientBefore(jwt.ContextToGRPC()),
+		zipkin.GRPCClientTrace(zipkinTracer),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = grpctransport.NewClient(conn, "pb.Userpass", method, enc, dec, reply, options...).Endpoint()
+		e = grpcErrorDecoder(e)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.UserpassSet{
+		ReadUserEndpoint:   endpointFor("ReadUser", encodeGRPCUserRequest, decodeGRPCUserResponse, pb.UserResponse{}),
+		WriteUserEndpoint:  endpointFor("WriteUser", encodeGRPCWriteUserRequest, decodeGRPCUserResponse, pb.UserResponse{}),
+		DeleteUserEndpoint: endpointFor("DeleteUser", encodeGRPCUserRequest, decodeGRPCUserResponse, pb.UserResponse{}),
+		ListUsersEndpoint:  endpointFor("ListUsers", encodeGRPCListUsersRequest, decodeGRPCListUsersResponse, pb.ListUsersResponse{}),
+		LoginEndpoint:      endpointFor("Login", encodeGRPCUserpassLoginRequest, decodeGRPCTokenResponse, pb.TokenResponse{}),
+	}
+}
+
+func (s *grpcUserpassServer) ReadUser(ctx context.Context, r *pb.UserRequest) (*pb.UserResponse, error) {
+	_, resp, err := s.readUser.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.UserResponse), nil
+}
+
+func (s *grpcUserpassServer) WriteUser(ctx context.Context, r *pb.WriteUserRequest) (*pb.UserResponse, error) {
+	_, resp, err := s.writeUser.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.UserResponse), nil
+}
+
+func (s *grpcUserpassServer) DeleteUser(ctx context.Context, r *pb.UserRequest) (*pb.UserResponse, error) {
+	_, resp, err := s.deleteUser.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.UserResponse), nil
+}
+
+func (s *grpcUserpassServer) ListUsers(ctx context.Context, r *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	_, resp, err := s.listUsers.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.ListUsersResponse), nil
+}
+
+func (s *grpcUserpassServer) Login(ctx context.Context, r *pb.UserpassLoginRequest) (*pb.TokenResponse, error) {
+	return serveGRPCToken(ctx, s.login, r)
+}
+
+func decodeGRPCUserRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.UserRequest)
+	return vaultendpoint.UserRequest{Username: req.Username}, nil
+}
+
+func decodeGRPCWriteUserRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.WriteUserRequest)
+	return vaultendpoint.WriteUserRequest{
+		Username: req.Username,
+		User: vaultservice.User{
+			Password:      req.Password,
+			TokenPolicies: req.TokenPolicies,
+			TokenTTL:      req.TokenTtl,
+		},
+	}, nil
+}
+
+func decodeGRPCListUsersRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return vaultendpoint.ListUsersRequest{}, nil
+}
+
+func decodeGRPCUserpassLoginRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.UserpassLoginRequest)
+	return vaultendpoint.UserpassLoginRequest{Username: req.Username, Password: req.Password}, nil
+}
+
+func encodeGRPCUserResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.UserResponse)
+	return &pb.UserResponse{
+		Username:      resp.Username,
+		TokenPolicies: resp.TokenPolicies,
+		TokenTtl:      resp.TokenTTL,
+		Err:           err2str(resp.Err),
+	}, nil
+}
+
+func encodeGRPCListUsersResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.ListUsersResponse)
+	return &pb.ListUsersResponse{Users: resp.Users, Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCUserRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.UserRequest)
+	return &pb.UserRequest{Username: req.Username}, nil
+}
+
+func encodeGRPCWriteUserRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.WriteUserRequest)
+	return &pb.WriteUserRequest{
+		Username:      req.Username,
+		Password:      req.Password,
+		TokenPolicies: req.TokenPolicies,
+		TokenTtl:      req.TokenTTL,
+	}, nil
+}
+
+func encodeGRPCListUsersRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.ListUsersRequest{}, nil
+}
+
+func encodeGRPCUserpassLoginRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.UserpassLoginRequest)
+	return &pb.UserpassLoginRequest{Username: req.Username, Password: req.Password}, nil
+}
+
+func decodeGRPCUserResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.UserResponse)
+	return vaultendpoint.UserResponse{
+		Username: reply.Username,
+		User: vaultservice.User{
+			TokenPolicies: reply.TokenPolicies,
+			TokenTTL:      reply.TokenTtl,
+		},
+		Err: str2err(reply.Err),
+	}, nil
+}
+
+func decodeGRPCListUsersResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.ListUsersResponse)
+	return vaultendpoint.ListUsersResponse{Users: reply.Users, Err: str2err(reply.Err)}, nil
+}
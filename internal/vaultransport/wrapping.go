@@ -0,0 +1,455 @@
+package vaultransport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/opentracing"
+	"github.com/go-kit/kit/tracing/zipkin"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	stdzipkin "github.com/openzipkin/zipkin-go"
+	"github.com/sony/gobreaker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/williamlsh/vault/internal/vaultendpoint"
+	"github.com/williamlsh/vault/internal/vaulterr"
+	"github.com/williamlsh/vault/internal/vaultservice"
+	"github.com/williamlsh/vault/pb"
+)
+
+const wrappingPath = "/sys/wrapping/"
+
+// WrapTTLHeader is the HTTP header requesting a response to be wrapped behind
+// a wrapping token valid for its TTL, such as 5m.
+const WrapTTLHeader = "X-Vault-Wrap-TTL"
+
+// The gRPC metadata requesting a response to be wrapped, and returning the
+// JSON wrap info of the wrapped response.
+const (
+	wrapTTLMetadata  = "vault-wrap-ttl"
+	wrapInfoMetadata = "vault-wrap-info"
+)
+
+// wrapHTTPResponses wraps the successful responses of the requests carrying
+// the WrapTTLHeader with the endpoint, replacing them with their wrap info.
+// Only JSON responses are wrapped, and the requests of the wrapping endpoints
+// never are. The wrap info is encoded as the gateway encodes the responses of
+// the Wrapping service.
+func wrapHTTPResponses(next http.Handler, wrap endpoint.Endpoint) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ttl := r.Header.Get(WrapTTLHeader)
+		path := strings.TrimPrefix(r.URL.Path, apiPrefix)
+		if ttl == "" || strings.HasPrefix(path, wrappingPath) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Refusing invalid TTLs before serving the request, rather than
+		// once it took effect.
+		if d, err := time.ParseDuration(ttl); err != nil || d < 0 {
+			errorEncoder(r.Context(), fmt.Errorf("%w: invalid %s %q", vaultservice.ErrInvalidArgument, WrapTTLHeader, ttl), w)
+			return
+		}
+		rec := &bufferedResponseWriter{header: make(http.Header), code: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.code != http.StatusOK {
+			rec.flush(w)
+			return
+		}
+		if !json.Valid(rec.body.Bytes()) {
+			errorEncoder(r.Context(), fmt.Errorf("%w: the response of %s is not JSON and cannot be wrapped", vaultservice.ErrInvalidArgument, r.URL.Path), w)
+			return
+		}
+		resp, err := wrap(r.Context(), vaultendpoint.WrapResponseRequest{
+			Path: strings.TrimPrefix(path, "/"),
+			Data: rec.body.Bytes(),
+			TTL:  ttl,
+		})
+		if err != nil {
+			errorEncoder(r.Context(), err, w)
+			return
+		}
+		if f, ok := resp.(endpoint.Failer); ok && f.Failed() != nil {
+			errorEncoder(r.Context(), f.Failed(), w)
+			return
+		}
+		info, _ := encodeGRPCWrapInfoResponse(r.Context(), resp)
+		body, err := wrapInfoOptions.Marshal(info.(proto.Message))
+		if err != nil {
+			errorEncoder(r.Context(), err, w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+// wrapInfoOptions encode the wrap info with the field names of the proto, as
+// the gateway does.
+var wrapInfoOptions = protojson.MarshalOptions{UseProtoNames: true}
+
+// bufferedResponseWriter keeps a response until it is wrapped or flushed.
+type bufferedResponseWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+// flush writes the buffered response to w.
+func (w *bufferedResponseWriter) flush(dst http.ResponseWriter) {
+	for k, v := range w.header {
+		dst.Header()[k] = v
+	}
+	dst.WriteHeader(w.code)
+	dst.Write(w.body.Bytes())
+}
+
+// NewHTTPWrappingClient returns a WrappingService backed by an HTTP server
+// living at the remote instance.
+func NewHTTPWrappingClient(instance string, tlsOpts ClientTLS, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) (vaultservice.WrappingService, error) {
+	c, err := gatewayClient(instance, tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+	before := []grpctransport.ClientRequestFunc{
+		opentracing.ContextToGRPC(otTracer, logger),
+		jwt.ContextToGRPC(),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = newGatewayEndpoint(c, "pb.Wrapping", method, enc, dec, reply, before...)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.WrappingSet{
+		WrapEndpoint:   endpointFor("Wrap", encodeGRPCWrapRequest, decodeGRPCWrapInfoResponse, pb.WrapInfoResponse{}),
+		UnwrapEndpoint: endpointFor("Unwrap", encodeGRPCUnwrapRequest, decodeGRPCUnwrapResponse, pb.UnwrapResponse{}),
+		LookupEndpoint: endpointFor("Lookup", encodeGRPCUnwrapRequest, decodeGRPCWrapInfoResponse, pb.WrapInfoResponse{}),
+	}, nil
+}
+
+// clientWrapping is the wrapping requested for a client call, and the wrap
+// info of its response.
+type clientWrapping struct {
+	ttl string
+
+	mu   sync.Mutex
+	info *vaultservice.WrapInfo
+}
+
+type clientWrappingKey struct{}
+
+// WithWrapTTL returns a context requesting the response of the client call
+// made with it to be wrapped behind a wrapping token valid for ttl. The wrap
+// info is then returned by WrapInfoFromContext, the call returning an empty
+// response. It is supported by the HTTP clients, and by the gRPC clients
+// dialed with WrappingUnaryClientInterceptor.
+func WithWrapTTL(ctx context.Context, ttl string) context.Context {
+	return context.WithValue(ctx, clientWrappingKey{}, &clientWrapping{ttl: ttl})
+}
+
+// WrapInfoFromContext returns the wrap info of the response of the call made
+// with the context, if it was wrapped.
+func WrapInfoFromContext(ctx context.Context) (vaultservice.WrapInfo, bool) {
+	w, ok := ctx.Value(clientWrappingKey{}).(*clientWrapping)
+	if !ok {
+		return vaultservice.WrapInfo{}, false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.info == nil {
+		return vaultservice.WrapInfo{}, false
+	}
+	return *w.info, true
+}
+
+func (w *clientWrapping) set(info vaultservice.WrapInfo) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.info = &info
+}
+
+// replyOptions decode the wrap info of the responses, ignoring the fields of
+// the responses which were not wrapped.
+var replyOptions = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+// wrappingRoundTripper sends the WrapTTLHeader of the requests made with
+// WithWrapTTL, and keeps the wrap info of their responses.
+type wrappingRoundTripper struct {
+	next http.RoundTripper
+}
+
+func (t wrappingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	w, ok := r.Context().Value(clientWrappingKey{}).(*clientWrapping)
+	if !ok {
+		return t.next.RoundTrip(r)
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set(WrapTTLHeader, w.ttl)
+	resp, err := t.next.RoundTrip(r)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	var reply pb.WrapInfoResponse
+	if err := replyOptions.Unmarshal(body, &reply); err == nil && reply.WrapInfo.GetToken() != "" {
+		wrapped, _ := decodeGRPCWrapInfoResponse(r.Context(), &reply)
+		w.set(wrapped.(vaultendpoint.WrapInfoResponse).WrapInfo)
+	}
+	return resp, nil
+}
+
+// NewGRPCWrappingInterceptor returns a gRPC server interceptor wrapping the
+// successful responses of the requests carrying the vault-wrap-ttl metadata
+// with the endpoints. The wrap info is returned as JSON in the vault-wrap-info
+// header metadata, along with an empty response. The requests of the Wrapping
+// service are never wrapped.
+func NewGRPCWrappingInterceptor(endpoints vaultendpoint.WrappingSet) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		ttls := md.Get(wrapTTLMetadata)
+		if len(ttls) == 0 || strings.HasPrefix(info.FullMethod, "/pb.Wrapping/") {
+			return handler(ctx, req)
+		}
+		if d, err := time.ParseDuration(ttls[0]); err != nil || d < 0 {
+			return nil, vaulterr.Errorf(vaulterr.InvalidArgument, "invalid %s %q", wrapTTLMetadata, ttls[0])
+		}
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+		m, ok := resp.(proto.Message)
+		if !ok {
+			return resp, nil
+		}
+		data, err := protojson.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		wrapped, err := endpoints.WrapResponseEndpoint(ctx, vaultendpoint.WrapResponseRequest{
+			Path: info.FullMethod,
+			Data: data,
+			TTL:  ttls[0],
+		})
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		wr := wrapped.(vaultendpoint.WrapInfoResponse)
+		if wr.Err != nil {
+			return nil, grpcError(wr.Err)
+		}
+		raw, err := json.Marshal(wr.WrapInfo)
+		if err != nil {
+			return nil, err
+		}
+		if err := grpc.SetHeader(ctx, metadata.Pairs(wrapInfoMetadata, string(raw))); err != nil {
+			return nil, err
+		}
+		empty := proto.Clone(m)
+		proto.Reset(empty)
+		return empty, nil
+	}
+}
+
+// WrappingUnaryClientInterceptor is a gRPC client interceptor sending the
+// vault-wrap-ttl metadata of the calls made with WithWrapTTL, and keeping the
+// wrap info of their responses.
+func WrappingUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	w, ok := ctx.Value(clientWrappingKey{}).(*clientWrapping)
+	if !ok {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, wrapTTLMetadata, w.ttl)
+	var header metadata.MD
+	err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header))...)
+	if v := header.Get(wrapInfoMetadata); len(v) > 0 {
+		var info vaultservice.WrapInfo
+		if err := json.Unmarshal([]byte(v[0]), &info); err == nil {
+			w.set(info)
+		}
+	}
+	return err
+}
+
+type grpcWrappingServer struct {
+	wrap   grpctransport.Handler
+	unwrap grpctransport.Handler
+	lookup grpctransport.Handler
+}
+
+// NewGRPCWrappingServer makes the response wrapping endpoints available as a
+// gRPC WrappingServer.
+func NewGRPCWrappingServer(endpoints vaultendpoint.WrappingSet, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) pb.WrappingServer {
+	options := grpcServerOptions(zipkinTracer, logger)
+	handler := func(name string, e endpoint.Endpoint, dec grpctransport.DecodeRequestFunc, enc grpctransport.EncodeResponseFunc) grpctransport.Handler {
+		return grpctransport.NewServer(
+			failedAsError(e),
+			dec,
+			enc,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(otTracer, name, logger)))...,
+		)
+	}
+	return &grpcWrappingServer{
+		wrap:   handler("Wrap", endpoints.WrapEndpoint, decodeGRPCWrapRequest, encodeGRPCWrapInfoResponse),
+		unwrap: handler("Unwrap", endpoints.UnwrapEndpoint, decodeGRPCUnwrapRequest, encodeGRPCUnwrapResponse),
+		lookup: handler("WrapLookup", endpoints.LookupEndpoint, decodeGRPCUnwrapRequest, encodeGRPCWrapInfoResponse),
+	}
+}
+
+// NewGRPCWrappingClient returns a WrappingService backed by a gRPC server at
+// the other end of the conn.
+func NewGRPCWrappingClient(conn *grpc.ClientConn, tokens TokenSource, otTracer stdopentracing.Tracer, zipkinTracer *stdzipkin.Tracer, logger log.Logger) vaultservice.WrappingService {
+	options := []grpctransport.ClientOption{
+		grpctransport.ClientBefore(opentracing.ContextToGRPC(otTracer, logger)),
+		grpctransport.ClientBefore(jwt.ContextToGRPC()),
+		zipkin.GRPCClientTrace(zipkinTracer),
+	}
+
+	tokenSetter := newTokenSetter(tokens)
+	endpointFor := func(method string, enc grpctransport.EncodeRequestFunc, dec grpctransport.DecodeResponseFunc, reply interface{}) endpoint.Endpoint {
+		var e endpoint.Endpoint
+		e = grpctransport.NewClient(conn, "pb.Wrapping", method, enc, dec, reply, options...).Endpoint()
+		e = grpcErrorDecoder(e)
+		e = opentracing.TraceClient(otTracer, method)(e)
+		e = zipkin.TraceEndpoint(zipkinTracer, method)(e)
+		e = tokenSetter(e)
+		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    method,
+			Timeout: 10 * time.Second,
+		}))(e)
+		return e
+	}
+
+	return vaultendpoint.WrappingSet{
+		WrapEndpoint:   endpointFor("Wrap", encodeGRPCWrapRequest, decodeGRPCWrapInfoResponse, pb.WrapInfoResponse{}),
+		UnwrapEndpoint: endpointFor("Unwrap", encodeGRPCUnwrapRequest, decodeGRPCUnwrapResponse, pb.UnwrapResponse{}),
+		LookupEndpoint: endpointFor("Lookup", encodeGRPCUnwrapRequest, decodeGRPCWrapInfoResponse, pb.WrapInfoResponse{}),
+	}
+}
+
+func (s *grpcWrappingServer) Wrap(ctx context.Context, r *pb.WrapRequest) (*pb.WrapInfoResponse, error) {
+	_, resp, err := s.wrap.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.WrapInfoResponse), nil
+}
+
+func (s *grpcWrappingServer) Unwrap(ctx context.Context, r *pb.UnwrapRequest) (*pb.UnwrapResponse, error) {
+	_, resp, err := s.unwrap.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.UnwrapResponse), nil
+}
+
+func (s *grpcWrappingServer) Lookup(ctx context.Context, r *pb.UnwrapRequest) (*pb.WrapInfoResponse, error) {
+	_, resp, err := s.lookup.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.WrapInfoResponse), nil
+}
+
+func decodeGRPCWrapRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.WrapRequest)
+	return vaultendpoint.WrapRequest{Data: json.RawMessage(req.Data), TTL: req.Ttl}, nil
+}
+
+func decodeGRPCUnwrapRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.UnwrapRequest)
+	return vaultendpoint.UnwrapRequest{Token: req.Token}, nil
+}
+
+func encodeGRPCWrapInfoResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.WrapInfoResponse)
+	return &pb.WrapInfoResponse{
+		WrapInfo: &pb.WrapInfo{
+			Token:          resp.WrapInfo.Token,
+			Ttl:            resp.WrapInfo.TTL,
+			CreationTime:   unixNano(resp.WrapInfo.CreationTime),
+			ExpirationTime: unixNano(resp.WrapInfo.ExpirationTime),
+			CreationPath:   resp.WrapInfo.CreationPath,
+		},
+		Err: err2str(resp.Err),
+	}, nil
+}
+
+func encodeGRPCUnwrapResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(vaultendpoint.UnwrapResponse)
+	return &pb.UnwrapResponse{Data: string(resp.Data), Err: err2str(resp.Err)}, nil
+}
+
+func encodeGRPCWrapRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.WrapRequest)
+	return &pb.WrapRequest{Data: string(req.Data), Ttl: req.TTL}, nil
+}
+
+func encodeGRPCUnwrapRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(vaultendpoint.UnwrapRequest)
+	return &pb.UnwrapRequest{Token: req.Token}, nil
+}
+
+func decodeGRPCWrapInfoResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.WrapInfoResponse)
+	var info vaultservice.WrapInfo
+	if w := reply.WrapInfo; w != nil {
+		info = vaultservice.WrapInfo{
+			Token:          w.Token,
+			TTL:            w.Ttl,
+			CreationTime:   fromUnixNano(w.CreationTime),
+			ExpirationTime: fromUnixNano(w.ExpirationTime),
+			CreationPath:   w.CreationPath,
+		}
+	}
+	return vaultendpoint.WrapInfoResponse{WrapInfo: info, Err: str2err(reply.Err)}, nil
+}
+
+func decodeGRPCUnwrapResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.UnwrapResponse)
+	var data json.RawMessage
+	if reply.Data != "" {
+		data = json.RawMessage(reply.Data)
+	}
+	return vaultendpoint.UnwrapResponse{Data: data, Err: str2err(reply.Err)}, nil
+}
@@ -0,0 +1,477 @@
+package vaultservice
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+
+	"github.com/williamlsh/vault/internal/identity"
+	"github.com/williamlsh/vault/internal/lease"
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/store"
+	"github.com/williamlsh/vault/internal/token"
+)
+
+const (
+	appRolePrefix         = "auth/approle/role/"
+	appRoleIDPrefix       = "auth/approle/role-id/"
+	appRoleSecretIDPrefix = "auth/approle/secret-id/"
+)
+
+var (
+	// ErrRoleNotFound is returned when an AppRole role does not exist.
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrInvalidCredentials is returned when a login presents unknown,
+	// wrong, expired or used up credentials.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// AppRoleService describes the AppRole auth method, which lets machines log
+// in with a role ID and a secret ID.
+type AppRoleService interface {
+	// WriteRole creates or replaces a role. A role ID is generated unless
+	// one is given or the role already has one.
+	WriteRole(ctx context.Context, name string, role AppRole) error
+	// ReadRole returns a role.
+	ReadRole(ctx context.Context, name string) (AppRole, error)
+	// DeleteRole removes a role and all its secret IDs.
+	DeleteRole(ctx context.Context, name string) error
+	// ListRoles returns the names of the roles.
+	ListRoles(ctx context.Context) ([]string, error)
+	// GenerateSecretID issues a new secret ID for a role.
+	GenerateSecretID(ctx context.Context, name string) (AppRoleSecretID, error)
+	// DestroySecretID removes the secret ID with the accessor.
+	DestroySecretID(ctx context.Context, name, accessor string) error
+	// Login exchanges a role ID and a secret ID for a token carrying the
+	// policies of the role.
+	Login(ctx context.Context, roleID, secretID string) (token.Token, error)
+}
+
+// AppRole is a role of the AppRole auth method.
+type AppRole struct {
+	RoleID string `json:"role_id,omitempty"`
+	// BoundCIDRs restrict the source addresses allowed to log in.
+	BoundCIDRs []string `json:"bound_cidrs,omitempty"`
+	// TokenPolicies are granted to the tokens, besides the default policy.
+	TokenPolicies []string `json:"token_policies,omitempty"`
+	// TokenTTL is a duration such as "1h", the lease default TTL if empty.
+	TokenTTL string `json:"token_ttl,omitempty"`
+	// SecretIDNumUses is the number of logins a secret ID is good for, zero
+	// meaning unlimited.
+	SecretIDNumUses int `json:"secret_id_num_uses,omitempty"`
+	// SecretIDTTL is a duration such as "24h", empty meaning secret IDs
+	// never expire.
+	SecretIDTTL string `json:"secret_id_ttl,omitempty"`
+}
+
+// AppRoleSecretID is a secret ID as returned when it is generated. The
+// secret ID starts with its accessor.
+type AppRoleSecretID struct {
+	SecretID   string    `json:"secret_id"`
+	Accessor   string    `json:"secret_id_accessor"`
+	NumUses    int       `json:"secret_id_num_uses"`
+	ExpireTime time.Time `json:"expire_time,omitempty"`
+}
+
+// appRoleSecretIDEntry is a stored secret ID. Only its bcrypt hash is kept.
+type appRoleSecretIDEntry struct {
+	Hash         string    `json:"hash"`
+	NumUses      int       `json:"num_uses"`
+	CreationTime time.Time `json:"creation_time"`
+	ExpireTime   time.Time `json:"expire_time,omitempty"`
+	LeaseID      string    `json:"lease_id,omitempty"`
+}
+
+type appRoleService struct {
+	storage store.Storage
+	hasher  Service
+	tokens  *token.Store
+	leases  *lease.Manager
+}
+
+// NewAppRoleService makes a new AppRole auth method persisting roles in the
+// storage. Secret IDs are hashed with bcrypt, their hashes kept with the
+// roles, and expire through leases. Logins are issued orphan tokens.
+func NewAppRoleService(logger log.Logger, ints metrics.Counter, s store.Storage, tokens *token.Store, leases *lease.Manager) AppRoleService {
+	var svc AppRoleService
+	{
+		ar := &appRoleService{storage: s, hasher: bcryptHasher{}, tokens: tokens, leases: leases}
+		leases.Handle(appRoleSecretIDPrefix, ar.revokeSecretID)
+		svc = ar
+		svc = AppRoleLoggingMiddleware(logger)(svc)
+		svc = AppRoleInstrumentingMiddleware(ints)(svc)
+	}
+	return svc
+}
+
+func (s *appRoleService) WriteRole(ctx context.Context, name string, role AppRole) error {
+	if err := validateRoleName(name); err != nil {
+		return err
+	}
+	if err := role.validate(); err != nil {
+		return err
+	}
+
+	// The role and its role ID mapping change in one transaction, so that
+	// two roles cannot claim the same role ID.
+	return s.storage.Transaction(ctx, func(tx store.Tx) error {
+		var previous string
+		raw, err := tx.Get(appRolePrefix + name)
+		if err != nil && err != store.ErrNotFound {
+			return err
+		}
+		if err == nil {
+			var old AppRole
+			if err := json.Unmarshal(raw, &old); err != nil {
+				return err
+			}
+			previous = old.RoleID
+		}
+		if role.RoleID == "" {
+			role.RoleID = previous
+		}
+		if role.RoleID == "" {
+			role.RoleID = randomHex(16)
+		}
+		if role.RoleID != previous {
+			owner, err := tx.Get(appRoleIDPrefix + role.RoleID)
+			if err == nil && string(owner) != name {
+				return fmt.Errorf("%w: role_id is in use", ErrInvalidArgument)
+			}
+			if err != nil && err != store.ErrNotFound {
+				return err
+			}
+			if err := tx.Put(appRoleIDPrefix+role.RoleID, []byte(name)); err != nil {
+				return err
+			}
+			if previous != "" {
+				if err := tx.Delete(appRoleIDPrefix + previous); err != nil {
+					return err
+				}
+			}
+		}
+		raw, err = json.Marshal(role)
+		if err != nil {
+			return err
+		}
+		return tx.Put(appRolePrefix+name, raw)
+	})
+}
+
+func (s *appRoleService) ReadRole(ctx context.Context, name string) (AppRole, error) {
+	if err := validateRoleName(name); err != nil {
+		return AppRole{}, err
+	}
+	role, err := s.role(ctx, name)
+	if err != nil {
+		return AppRole{}, err
+	}
+	return *role, nil
+}
+
+func (s *appRoleService) DeleteRole(ctx context.Context, name string) error {
+	if err := validateRoleName(name); err != nil {
+		return err
+	}
+	role, err := s.role(ctx, name)
+	if err != nil {
+		return err
+	}
+	accessors, err := s.storage.List(ctx, appRoleSecretIDPrefix+name+"/")
+	if err != nil {
+		return err
+	}
+	for _, accessor := range accessors {
+		if err := s.DestroySecretID(ctx, name, accessor); err != nil {
+			return err
+		}
+	}
+	if err := s.storage.Delete(ctx, appRoleIDPrefix+role.RoleID); err != nil {
+		return err
+	}
+	return s.storage.Delete(ctx, appRolePrefix+name)
+}
+
+func (s *appRoleService) ListRoles(ctx context.Context) ([]string, error) {
+	names, err := s.storage.List(ctx, appRolePrefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *appRoleService) GenerateSecretID(ctx context.Context, name string) (AppRoleSecretID, error) {
+	if err := validateRoleName(name); err != nil {
+		return AppRoleSecretID{}, err
+	}
+	role, err := s.role(ctx, name)
+	if err != nil {
+		return AppRoleSecretID{}, err
+	}
+
+	accessor, secret := randomHex(8), randomHex(24)
+	hash, err := s.hasher.Hash(ctx, secret)
+	if err != nil {
+		return AppRoleSecretID{}, err
+	}
+	entry := appRoleSecretIDEntry{
+		Hash:         hash,
+		NumUses:      role.SecretIDNumUses,
+		CreationTime: time.Now().UTC(),
+	}
+	key := appRoleSecretIDPrefix + name + "/" + accessor
+	if ttl, _ := parseTTL(role.SecretIDTTL); ttl > 0 {
+		l, err := s.leases.Register(ctx, key, ttl)
+		if err != nil {
+			return AppRoleSecretID{}, err
+		}
+		entry.LeaseID, entry.ExpireTime = l.ID, l.ExpireTime
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return AppRoleSecretID{}, err
+	}
+	if err := s.storage.Put(ctx, key, raw); err != nil {
+		return AppRoleSecretID{}, err
+	}
+	return AppRoleSecretID{
+		SecretID:   accessor + "." + secret,
+		Accessor:   accessor,
+		NumUses:    entry.NumUses,
+		ExpireTime: entry.ExpireTime,
+	}, nil
+}
+
+func (s *appRoleService) DestroySecretID(ctx context.Context, name, accessor string) error {
+	if err := validateRoleName(name); err != nil {
+		return err
+	}
+	if accessor == "" || strings.Contains(accessor, "/") {
+		return fmt.Errorf("%w: invalid secret_id_accessor", ErrInvalidArgument)
+	}
+	key := appRoleSecretIDPrefix + name + "/" + accessor
+	raw, err := s.storage.Get(ctx, key)
+	if err == store.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var entry appRoleSecretIDEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return err
+	}
+	if err := s.storage.Delete(ctx, key); err != nil {
+		return err
+	}
+	return s.revokeLease(ctx, entry.LeaseID)
+}
+
+func (s *appRoleService) Login(ctx context.Context, roleID, secretID string) (token.Token, error) {
+	if roleID == "" || strings.Contains(roleID, "/") {
+		return token.Token{}, ErrInvalidCredentials
+	}
+	name, err := s.storage.Get(ctx, appRoleIDPrefix+roleID)
+	if err == store.ErrNotFound {
+		return token.Token{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return token.Token{}, err
+	}
+	role, err := s.role(ctx, string(name))
+	if err == ErrRoleNotFound || err == nil && role.RoleID != roleID {
+		return token.Token{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return token.Token{}, err
+	}
+	if !boundAddr(role.BoundCIDRs, identity.RemoteAddr(ctx)) {
+		return token.Token{}, fmt.Errorf("%w: source address not allowed", ErrInvalidCredentials)
+	}
+	if err := s.useSecretID(ctx, string(name), secretID); err != nil {
+		return token.Token{}, err
+	}
+
+	ttl, _ := parseTTL(role.TokenTTL)
+	t, err := s.tokens.Create(ctx, nil, token.CreateOptions{
+		Policies:    append([]string{policy.DefaultPolicy}, role.TokenPolicies...),
+		TTL:         ttl,
+		DisplayName: "approle-" + string(name),
+		Orphan:      true,
+	})
+	if err != nil {
+		return token.Token{}, err
+	}
+	return *t, nil
+}
+
+// useSecretID verifies a secret ID of the named role and consumes one of its
+// uses. Expired and used up secret IDs are removed.
+func (s *appRoleService) useSecretID(ctx context.Context, name, secretID string) error {
+	parts := strings.SplitN(secretID, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || strings.Contains(parts[0], "/") {
+		return ErrInvalidCredentials
+	}
+	accessor, secret := parts[0], parts[1]
+
+	var (
+		expired bool
+		removed string // lease of the entry if it was removed
+	)
+	err := s.storage.Update(ctx, appRoleSecretIDPrefix+name+"/"+accessor, func(raw []byte) ([]byte, error) {
+		if raw == nil {
+			return nil, ErrInvalidCredentials
+		}
+		var entry appRoleSecretIDEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return nil, err
+		}
+		if !entry.ExpireTime.IsZero() && !entry.ExpireTime.After(time.Now()) {
+			expired, removed = true, entry.LeaseID
+			return nil, nil
+		}
+		ok, err := s.hasher.Validate(ctx, secret, entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrInvalidCredentials
+		}
+		if entry.NumUses == 0 {
+			return raw, nil
+		}
+		if entry.NumUses--; entry.NumUses == 0 {
+			removed = entry.LeaseID
+			return nil, nil
+		}
+		return json.Marshal(entry)
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.revokeLease(ctx, removed); err != nil {
+		return err
+	}
+	if expired {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+func (s *appRoleService) role(ctx context.Context, name string) (*AppRole, error) {
+	raw, err := s.storage.Get(ctx, appRolePrefix+name)
+	if err == store.ErrNotFound {
+		return nil, ErrRoleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var role AppRole
+	if err := json.Unmarshal(raw, &role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// revokeSecretID removes the secret ID a lease was issued for.
+func (s *appRoleService) revokeSecretID(ctx context.Context, l *lease.Lease) error {
+	// Lease IDs are auth/approle/secret-id/<role>/<accessor>/<random>.
+	key := l.ID[:strings.LastIndex(l.ID, "/")]
+	return s.storage.Delete(ctx, key)
+}
+
+func (s *appRoleService) revokeLease(ctx context.Context, id string) error {
+	if id == "" {
+		return nil
+	}
+	if err := s.leases.Revoke(ctx, id); err != nil && err != lease.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+func (r AppRole) validate() error {
+	if strings.Contains(r.RoleID, "/") {
+		return fmt.Errorf("%w: invalid role_id", ErrInvalidArgument)
+	}
+	for _, cidr := range r.BoundCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("%w: invalid bound_cidrs: %v", ErrInvalidArgument, err)
+		}
+	}
+	for _, name := range r.TokenPolicies {
+		if err := policy.ValidateName(name); err != nil {
+			return fmt.Errorf("%w: invalid token_policies: %v", ErrInvalidArgument, err)
+		}
+	}
+	if _, err := parseTTL(r.TokenTTL); err != nil {
+		return fmt.Errorf("%w: token_ttl must be a non-negative duration", ErrInvalidArgument)
+	}
+	if _, err := parseTTL(r.SecretIDTTL); err != nil {
+		return fmt.Errorf("%w: secret_id_ttl must be a non-negative duration", ErrInvalidArgument)
+	}
+	if r.SecretIDNumUses < 0 {
+		return fmt.Errorf("%w: secret_id_num_uses must not be negative", ErrInvalidArgument)
+	}
+	return nil
+}
+
+func validateRoleName(name string) error {
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("%w: invalid role name", ErrInvalidArgument)
+	}
+	return nil
+}
+
+// parseTTL parses a non-negative duration, empty meaning zero.
+func parseTTL(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err == nil && d < 0 {
+		err = errors.New("negative duration")
+	}
+	return d, err
+}
+
+// boundAddr reports whether the host of addr is within one of the CIDRs.
+// Any address is allowed when no CIDRs are given.
+func boundAddr(cidrs []string, addr string) bool {
+	if len(cidrs) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range cidrs {
+		if _, n, err := net.ParseCIDR(cidr); err == nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
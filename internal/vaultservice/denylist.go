@@ -0,0 +1,86 @@
+package vaultservice
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+
+	"github.com/williamlsh/vault/internal/denylist"
+)
+
+// DenylistService manages the denylist of the JWT IDs and subjects refused
+// before their tokens expire.
+type DenylistService interface {
+	// Deny adds a token ID or subject to the denylist.
+	Deny(ctx context.Context, opts DenyOptions) (denylist.Entry, error)
+	// Allow removes a token ID or subject from the denylist.
+	Allow(ctx context.Context, kind, value string) error
+	ReadDenied(ctx context.Context, kind, value string) (denylist.Entry, error)
+	ListDenied(ctx context.Context) ([]denylist.Entry, error)
+}
+
+// DenyOptions configures a denylist entry.
+type DenyOptions struct {
+	// Kind is denylist.KindTokenID or denylist.KindSubject.
+	Kind  string `json:"-"`
+	Value string `json:"-"`
+	// Reason is recorded for the operators.
+	Reason string `json:"reason,omitempty"`
+	// TTL is a duration such as "1h" after which the entry expires, never if
+	// empty. Token IDs need not be denied past the expiry of their token.
+	TTL string `json:"ttl,omitempty"`
+}
+
+type denylistService struct {
+	denylist *denylist.List
+}
+
+// NewDenylistService makes a new denylist service managing the list.
+func NewDenylistService(logger log.Logger, ints metrics.Counter, list *denylist.List) DenylistService {
+	var svc DenylistService
+	{
+		svc = &denylistService{denylist: list}
+		svc = DenylistLoggingMiddleware(logger)(svc)
+		svc = DenylistInstrumentingMiddleware(ints)(svc)
+	}
+	return svc
+}
+
+func (s *denylistService) Deny(ctx context.Context, opts DenyOptions) (denylist.Entry, error) {
+	ttl, err := parseTTL(opts.TTL)
+	if err != nil {
+		return denylist.Entry{}, fmt.Errorf("%w: invalid ttl: %v", ErrInvalidArgument, err)
+	}
+	e := denylist.Entry{
+		Kind:         opts.Kind,
+		Value:        opts.Value,
+		Reason:       opts.Reason,
+		CreationTime: time.Now().UTC(),
+	}
+	if ttl > 0 {
+		e.ExpireTime = e.CreationTime.Add(ttl)
+	}
+	if err := s.denylist.Deny(ctx, e); err != nil {
+		return denylist.Entry{}, err
+	}
+	return e, nil
+}
+
+func (s *denylistService) Allow(ctx context.Context, kind, value string) error {
+	return s.denylist.Allow(ctx, kind, value)
+}
+
+func (s *denylistService) ReadDenied(ctx context.Context, kind, value string) (denylist.Entry, error) {
+	e, err := s.denylist.Entry(ctx, kind, value)
+	if err != nil {
+		return denylist.Entry{}, err
+	}
+	return *e, nil
+}
+
+func (s *denylistService) ListDenied(ctx context.Context) ([]denylist.Entry, error) {
+	return s.denylist.Entries(ctx)
+}
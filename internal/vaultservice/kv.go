@@ -0,0 +1,541 @@
+package vaultservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+
+	"github.com/williamlsh/vault/internal/lease"
+	"github.com/williamlsh/vault/internal/store"
+)
+
+const (
+	kvMetadataPrefix = "kv/metadata/"
+	kvDataPrefix     = "kv/data/"
+
+	// DefaultKVMaxVersions is the number of versions kept per secret when
+	// neither the secret metadata nor the engine configure a limit.
+	DefaultKVMaxVersions = 10
+)
+
+var (
+	// ErrNotFound is returned when a secret or one of its versions does not
+	// exist.
+	ErrNotFound = errors.New("secret not found")
+	// ErrCASMismatch is returned when a check-and-set write does not match
+	// the current version of the secret.
+	ErrCASMismatch = errors.New("check-and-set parameter did not match the current version")
+	// ErrCASRequired is returned when a write omits the check-and-set
+	// parameter on a secret requiring it.
+	ErrCASRequired = errors.New("check-and-set parameter required for this call")
+	// ErrInvalidPath is returned for empty or malformed secret paths.
+	ErrInvalidPath = errors.New("invalid secret path")
+	// ErrInvalidArgument is returned for malformed request parameters.
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+// KVService describes a versioned key-value secrets engine.
+type KVService interface {
+	// Put writes a new version of the secret at path. If cas is not nil the
+	// write only succeeds if the current version equals *cas, zero meaning
+	// the secret must not exist yet.
+	Put(ctx context.Context, path string, data map[string]string, cas *int) (KVVersionMetadata, error)
+	// Get reads a version of the secret at path, zero meaning the current
+	// version. Deleted and destroyed versions are returned without data.
+	Get(ctx context.Context, path string, version int) (KVSecret, error)
+	// Delete soft deletes versions of the secret, the current version if
+	// none are given. Deleted versions can be undeleted.
+	Delete(ctx context.Context, path string, versions []int) error
+	// Undelete restores soft deleted versions.
+	Undelete(ctx context.Context, path string, versions []int) error
+	// Destroy permanently removes the data of versions.
+	Destroy(ctx context.Context, path string, versions []int) error
+	// List returns the secrets and folders directly under path. Folders end
+	// with a slash.
+	List(ctx context.Context, path string) ([]string, error)
+	// ReadMetadata returns the metadata and version history of a secret.
+	ReadMetadata(ctx context.Context, path string) (KVMetadata, error)
+	// WriteMetadata configures a secret, creating it if needed.
+	WriteMetadata(ctx context.Context, path string, cfg KVMetadataConfig) error
+	// DeleteMetadata permanently removes a secret and all its versions.
+	DeleteMetadata(ctx context.Context, path string) error
+}
+
+// KVVersionMetadata describes one version of a secret.
+type KVVersionMetadata struct {
+	Version      int        `json:"version"`
+	CreatedTime  time.Time  `json:"created_time"`
+	DeletionTime *time.Time `json:"deletion_time,omitempty"`
+	Destroyed    bool       `json:"destroyed"`
+	// LeaseID is the lease soft deleting the version when it expires, if
+	// the secret has a delete_version_after.
+	LeaseID string `json:"lease_id,omitempty"`
+}
+
+// KVSecret is one version of a secret.
+type KVSecret struct {
+	Data     map[string]string `json:"data"`
+	Metadata KVVersionMetadata `json:"metadata"`
+}
+
+// KVMetadataConfig holds the user configurable metadata of a secret.
+type KVMetadataConfig struct {
+	// MaxVersions is the number of versions to keep, zero meaning the
+	// engine default.
+	MaxVersions    int               `json:"max_versions"`
+	CASRequired    bool              `json:"cas_required"`
+	CustomMetadata map[string]string `json:"custom_metadata,omitempty"`
+	// DeleteVersionAfter is the duration after which new versions are soft
+	// deleted, e.g. "24h". Empty means the engine default, and "0s" never.
+	DeleteVersionAfter string `json:"delete_version_after,omitempty"`
+}
+
+// KVMetadata is the metadata and version history of a secret.
+type KVMetadata struct {
+	KVMetadataConfig
+	CurrentVersion int                       `json:"current_version"`
+	OldestVersion  int                       `json:"oldest_version"`
+	CreatedTime    time.Time                 `json:"created_time"`
+	UpdatedTime    time.Time                 `json:"updated_time"`
+	Versions       map[int]KVVersionMetadata `json:"versions"`
+}
+
+type kvService struct {
+	storage            store.Storage
+	leases             *lease.Manager
+	maxVersions        int
+	deleteVersionAfter time.Duration
+}
+
+// NewKVService makes a new versioned key-value secrets engine persisting
+// secrets in the storage. maxVersions is the default number of versions kept
+// per secret, and deleteVersionAfter the default lease of new versions, zero
+// meaning versions never expire.
+func NewKVService(logger log.Logger, ints metrics.Counter, s store.Storage, leases *lease.Manager, maxVersions int, deleteVersionAfter time.Duration) KVService {
+	if maxVersions <= 0 {
+		maxVersions = DefaultKVMaxVersions
+	}
+	var svc KVService
+	{
+		kv := &kvService{storage: s, leases: leases, maxVersions: maxVersions, deleteVersionAfter: deleteVersionAfter}
+		leases.Handle(kvDataPrefix, kv.revokeVersion)
+		svc = kv
+		svc = KVLoggingMiddleware(logger)(svc)
+		svc = KVInstrumentingMiddleware(ints)(svc)
+	}
+	return svc
+}
+
+func (s *kvService) Put(ctx context.Context, path string, data map[string]string, cas *int) (KVVersionMetadata, error) {
+	path, err := cleanPath(path)
+	if err != nil {
+		return KVVersionMetadata{}, err
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return KVVersionMetadata{}, err
+	}
+
+	var (
+		created KVVersionMetadata
+		trimmed []KVVersionMetadata
+	)
+	err = s.updateMetadata(ctx, path, true, func(tx store.Tx, md *KVMetadata) error {
+		if cas == nil && md.CASRequired {
+			return ErrCASRequired
+		}
+		if cas != nil && *cas != md.CurrentVersion {
+			return ErrCASMismatch
+		}
+
+		now := time.Now().UTC()
+		created = KVVersionMetadata{Version: md.CurrentVersion + 1, CreatedTime: now}
+		if err := tx.Put(kvDataKey(path, created.Version), raw); err != nil {
+			return err
+		}
+		md.CurrentVersion = created.Version
+		md.UpdatedTime = now
+		if md.OldestVersion == 0 {
+			md.OldestVersion = created.Version
+		}
+		md.Versions[created.Version] = created
+		trimmed = s.trim(md)
+		if err := s.removeData(tx, path, trimmed); err != nil {
+			return err
+		}
+		if ttl := s.versionTTL(md); ttl > 0 {
+			// The lease is registered last, as it is not part of the
+			// transaction. A lease outliving a failed commit finds no
+			// version carrying its ID, and is dropped when it expires.
+			l, err := s.leases.Register(ctx, kvDataKey(path, created.Version), ttl)
+			if err != nil {
+				return err
+			}
+			created.LeaseID = l.ID
+			md.Versions[created.Version] = created
+		}
+		return nil
+	})
+	if err != nil {
+		return KVVersionMetadata{}, err
+	}
+	if err := s.revokeLeases(ctx, trimmed); err != nil {
+		return created, err
+	}
+	return created, nil
+}
+
+func (s *kvService) Get(ctx context.Context, path string, version int) (KVSecret, error) {
+	path, err := cleanPath(path)
+	if err != nil {
+		return KVSecret{}, err
+	}
+	md, err := s.readMetadata(ctx, path)
+	if err != nil {
+		return KVSecret{}, err
+	}
+	if version == 0 {
+		version = md.CurrentVersion
+	}
+	vm, ok := md.Versions[version]
+	if !ok {
+		return KVSecret{}, ErrNotFound
+	}
+	secret := KVSecret{Metadata: vm}
+	if vm.DeletionTime != nil || vm.Destroyed {
+		return secret, nil
+	}
+	raw, err := s.storage.Get(ctx, kvDataKey(path, version))
+	if err == store.ErrNotFound {
+		return KVSecret{}, ErrNotFound
+	}
+	if err != nil {
+		return KVSecret{}, err
+	}
+	if err := json.Unmarshal(raw, &secret.Data); err != nil {
+		return KVSecret{}, err
+	}
+	return secret, nil
+}
+
+func (s *kvService) Delete(ctx context.Context, path string, versions []int) error {
+	path, err := cleanPath(path)
+	if err != nil {
+		return err
+	}
+	return s.updateMetadata(ctx, path, false, func(tx store.Tx, md *KVMetadata) error {
+		if len(versions) == 0 {
+			versions = []int{md.CurrentVersion}
+		}
+		now := time.Now().UTC()
+		for _, v := range versions {
+			vm, ok := md.Versions[v]
+			if !ok || vm.Destroyed || vm.DeletionTime != nil {
+				continue
+			}
+			vm.DeletionTime = &now
+			md.Versions[v] = vm
+		}
+		md.UpdatedTime = now
+		return nil
+	})
+}
+
+func (s *kvService) Undelete(ctx context.Context, path string, versions []int) error {
+	path, err := cleanPath(path)
+	if err != nil {
+		return err
+	}
+	return s.updateMetadata(ctx, path, false, func(tx store.Tx, md *KVMetadata) error {
+		for _, v := range versions {
+			vm, ok := md.Versions[v]
+			if !ok || vm.Destroyed {
+				continue
+			}
+			vm.DeletionTime = nil
+			md.Versions[v] = vm
+		}
+		md.UpdatedTime = time.Now().UTC()
+		return nil
+	})
+}
+
+func (s *kvService) Destroy(ctx context.Context, path string, versions []int) error {
+	path, err := cleanPath(path)
+	if err != nil {
+		return err
+	}
+	var destroyed []KVVersionMetadata
+	err = s.updateMetadata(ctx, path, false, func(tx store.Tx, md *KVMetadata) error {
+		for _, v := range versions {
+			vm, ok := md.Versions[v]
+			if !ok || vm.Destroyed {
+				continue
+			}
+			vm.Destroyed = true
+			md.Versions[v] = vm
+			destroyed = append(destroyed, vm)
+		}
+		md.UpdatedTime = time.Now().UTC()
+		return s.removeData(tx, path, destroyed)
+	})
+	if err != nil {
+		return err
+	}
+	return s.revokeLeases(ctx, destroyed)
+}
+
+func (s *kvService) List(ctx context.Context, path string) ([]string, error) {
+	prefix := strings.Trim(path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	keys, err := s.storage.List(ctx, kvMetadataPrefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	out := []string{}
+	for _, k := range keys {
+		if i := strings.Index(k, "/"); i >= 0 {
+			k = k[:i+1]
+		}
+		if !seen[k] {
+			seen[k] = true
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func (s *kvService) ReadMetadata(ctx context.Context, path string) (KVMetadata, error) {
+	path, err := cleanPath(path)
+	if err != nil {
+		return KVMetadata{}, err
+	}
+	md, err := s.readMetadata(ctx, path)
+	if err != nil {
+		return KVMetadata{}, err
+	}
+	return *md, nil
+}
+
+func (s *kvService) WriteMetadata(ctx context.Context, path string, cfg KVMetadataConfig) error {
+	path, err := cleanPath(path)
+	if err != nil {
+		return err
+	}
+	if cfg.MaxVersions < 0 {
+		return fmt.Errorf("%w: max_versions must not be negative", ErrInvalidArgument)
+	}
+	if cfg.DeleteVersionAfter != "" {
+		if d, err := time.ParseDuration(cfg.DeleteVersionAfter); err != nil || d < 0 {
+			return fmt.Errorf("%w: delete_version_after must be a non-negative duration", ErrInvalidArgument)
+		}
+	}
+	var trimmed []KVVersionMetadata
+	err = s.updateMetadata(ctx, path, true, func(tx store.Tx, md *KVMetadata) error {
+		md.KVMetadataConfig = cfg
+		md.UpdatedTime = time.Now().UTC()
+		trimmed = s.trim(md)
+		return s.removeData(tx, path, trimmed)
+	})
+	if err != nil {
+		return err
+	}
+	return s.revokeLeases(ctx, trimmed)
+}
+
+func (s *kvService) DeleteMetadata(ctx context.Context, path string) error {
+	path, err := cleanPath(path)
+	if err != nil {
+		return err
+	}
+	var versions []KVVersionMetadata
+	err = s.storage.Transaction(ctx, func(tx store.Tx) error {
+		md, err := getMetadata(tx, path)
+		if err != nil {
+			return err
+		}
+		for _, vm := range md.Versions {
+			versions = append(versions, vm)
+		}
+		if err := s.removeData(tx, path, versions); err != nil {
+			return err
+		}
+		return tx.Delete(kvMetadataPrefix + path)
+	})
+	if err != nil {
+		return err
+	}
+	return s.revokeLeases(ctx, versions)
+}
+
+// removeData deletes the data of versions dropped from the metadata in the
+// transaction updating it.
+func (s *kvService) removeData(tx store.Tx, path string, versions []KVVersionMetadata) error {
+	for _, vm := range versions {
+		if err := tx.Delete(kvDataKey(path, vm.Version)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeLeases revokes the leases of versions whose data was removed. Only the
+// lease recorded for a version is revoked, as the lease IDs of a version
+// prefix those of the secrets nested under it.
+func (s *kvService) revokeLeases(ctx context.Context, versions []KVVersionMetadata) error {
+	for _, vm := range versions {
+		if vm.LeaseID == "" {
+			continue
+		}
+		if err := s.leases.Revoke(ctx, vm.LeaseID); err != nil && err != lease.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeVersion soft deletes the version a lease was issued for. Versions
+// which are gone or already deleted are left alone.
+func (s *kvService) revokeVersion(ctx context.Context, l *lease.Lease) error {
+	// Lease IDs are kv/data/<path>/<version>/<random>.
+	key := l.ID[:strings.LastIndex(l.ID, "/")]
+	i := strings.LastIndex(key, "/")
+	path := strings.TrimPrefix(key[:i], kvDataPrefix)
+	version, err := strconv.Atoi(key[i+1:])
+	if err != nil {
+		return nil
+	}
+	err = s.updateMetadata(ctx, path, false, func(tx store.Tx, md *KVMetadata) error {
+		vm, ok := md.Versions[version]
+		if !ok || vm.LeaseID != l.ID {
+			return nil
+		}
+		vm.LeaseID = ""
+		if !vm.Destroyed && vm.DeletionTime == nil {
+			now := time.Now().UTC()
+			vm.DeletionTime = &now
+			md.UpdatedTime = now
+		}
+		md.Versions[version] = vm
+		return nil
+	})
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// versionTTL returns the lease duration of the new versions of a secret.
+func (s *kvService) versionTTL(md *KVMetadata) time.Duration {
+	if md.DeleteVersionAfter == "" {
+		return s.deleteVersionAfter
+	}
+	d, _ := time.ParseDuration(md.DeleteVersionAfter)
+	return d
+}
+
+func (s *kvService) readMetadata(ctx context.Context, path string) (*KVMetadata, error) {
+	raw, err := s.storage.Get(ctx, kvMetadataPrefix+path)
+	if err == store.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var md KVMetadata
+	if err := json.Unmarshal(raw, &md); err != nil {
+		return nil, err
+	}
+	return &md, nil
+}
+
+// updateMetadata atomically applies fn to the metadata of the secret at path,
+// in a transaction fn may use to write the data of the secret. If create is
+// set a missing secret is created, otherwise ErrNotFound is returned.
+func (s *kvService) updateMetadata(ctx context.Context, path string, create bool, fn func(tx store.Tx, md *KVMetadata) error) error {
+	return s.storage.Transaction(ctx, func(tx store.Tx) error {
+		md, err := getMetadata(tx, path)
+		if err == ErrNotFound && create {
+			now := time.Now().UTC()
+			md, err = &KVMetadata{CreatedTime: now, UpdatedTime: now}, nil
+		}
+		if err != nil {
+			return err
+		}
+		if md.Versions == nil {
+			md.Versions = make(map[int]KVVersionMetadata)
+		}
+		if err := fn(tx, md); err != nil {
+			return err
+		}
+		raw, err := json.Marshal(md)
+		if err != nil {
+			return err
+		}
+		return tx.Put(kvMetadataPrefix+path, raw)
+	})
+}
+
+// getMetadata reads the metadata of the secret at path in a transaction.
+func getMetadata(tx store.Tx, path string) (*KVMetadata, error) {
+	raw, err := tx.Get(kvMetadataPrefix + path)
+	if err == store.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var md KVMetadata
+	if err := json.Unmarshal(raw, &md); err != nil {
+		return nil, err
+	}
+	return &md, nil
+}
+
+// trim drops the oldest versions exceeding the version limit from the
+// metadata and returns them, so that their data can be removed.
+func (s *kvService) trim(md *KVMetadata) []KVVersionMetadata {
+	max := md.MaxVersions
+	if max == 0 {
+		max = s.maxVersions
+	}
+	var trimmed []KVVersionMetadata
+	for md.OldestVersion > 0 && md.CurrentVersion-md.OldestVersion+1 > max {
+		if vm, ok := md.Versions[md.OldestVersion]; ok {
+			delete(md.Versions, md.OldestVersion)
+			trimmed = append(trimmed, vm)
+		}
+		md.OldestVersion++
+	}
+	return trimmed
+}
+
+func kvDataKey(path string, version int) string {
+	return fmt.Sprintf("%s%s/%d", kvDataPrefix, path, version)
+}
+
+// cleanPath validates a secret path and strips surrounding slashes.
+func cleanPath(path string) (string, error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "", ErrInvalidPath
+	}
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return "", ErrInvalidPath
+		}
+	}
+	return path, nil
+}
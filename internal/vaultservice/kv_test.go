@@ -0,0 +1,172 @@
+package vaultservice_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/discard"
+
+	"github.com/williamlsh/vault/internal/lease"
+	"github.com/williamlsh/vault/internal/mock"
+	"github.com/williamlsh/vault/internal/vaultservice"
+)
+
+func newKVService(maxVersions int) (vaultservice.KVService, *lease.Manager) {
+	leases := lease.NewManager(log.NewNopLogger(), mock.NewLeaseStorage(), lease.Metrics{
+		Issued:  discard.NewCounter(),
+		Renewed: discard.NewCounter(),
+		Revoked: discard.NewCounter(),
+		Active:  discard.NewGauge(),
+	}, time.Hour, 2*time.Hour)
+	kv := vaultservice.NewKVService(log.NewNopLogger(), discard.NewCounter(), mock.NewStorage(), leases, maxVersions, time.Hour)
+	return kv, leases
+}
+
+func TestKVNestedPaths(t *testing.T) {
+	ctx := context.Background()
+	kv, leases := newKVService(1)
+
+	if _, err := kv.Put(ctx, "a", map[string]string{"k": "a1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	nested, err := kv.Put(ctx, "a/1", map[string]string{"k": "a/1 v1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Trimming version 1 of a must not touch the lease of a/1.
+	second, err := kv.Put(ctx, "a", map[string]string{"k": "a2"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := leases.Lookup(ctx, nested.LeaseID); err != nil {
+		t.Fatalf("lease of a/1 after trimming a: %v", err)
+	}
+
+	// Destroying version 2 of a neither.
+	if err := kv.Destroy(ctx, "a", []int{2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := leases.Lookup(ctx, nested.LeaseID); err != nil {
+		t.Fatalf("lease of a/1 after destroying a: %v", err)
+	}
+	md, err := kv.ReadMetadata(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm := md.Versions[2]; !vm.Destroyed {
+		t.Errorf("destroyed version of a: have %+v", vm)
+	}
+	if _, err := leases.Lookup(ctx, second.LeaseID); err != lease.ErrNotFound {
+		t.Errorf("lease of destroyed version: want %v, have %v", lease.ErrNotFound, err)
+	}
+
+	// Nor deleting the metadata of a.
+	if err := kv.DeleteMetadata(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := leases.Lookup(ctx, nested.LeaseID); err != nil {
+		t.Fatalf("lease of a/1 after deleting a: %v", err)
+	}
+	secret, err := kv.Get(ctx, "a/1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data["k"] != "a/1 v1" || secret.Metadata.DeletionTime != nil {
+		t.Errorf("a/1: have %+v", secret)
+	}
+}
+
+func TestKVCheckAndSet(t *testing.T) {
+	ctx := context.Background()
+	kv, _ := newKVService(10)
+
+	zero, one := 0, 1
+	if _, err := kv.Put(ctx, "app/db", map[string]string{"user": "admin"}, &one); err != vaultservice.ErrCASMismatch {
+		t.Errorf("cas on missing secret: want %v, have %v", vaultservice.ErrCASMismatch, err)
+	}
+	if _, err := kv.Put(ctx, "app/db", map[string]string{"user": "admin"}, &zero); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Put(ctx, "app/db", map[string]string{"user": "root"}, &zero); err != vaultservice.ErrCASMismatch {
+		t.Errorf("cas zero on existing secret: want %v, have %v", vaultservice.ErrCASMismatch, err)
+	}
+
+	if err := kv.WriteMetadata(ctx, "app/db", vaultservice.KVMetadataConfig{CASRequired: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Put(ctx, "app/db", map[string]string{"user": "root"}, nil); err != vaultservice.ErrCASRequired {
+		t.Errorf("put without cas: want %v, have %v", vaultservice.ErrCASRequired, err)
+	}
+	vm, err := kv.Put(ctx, "app/db", map[string]string{"user": "root"}, &one)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 2, vm.Version; want != have {
+		t.Errorf("version: want %d, have %d", want, have)
+	}
+}
+
+func TestKVDeleteUndeleteDestroy(t *testing.T) {
+	ctx := context.Background()
+	kv, leases := newKVService(10)
+
+	first, err := kv.Put(ctx, "app/db", map[string]string{"user": "admin"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Put(ctx, "app/db", map[string]string{"user": "root"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := kv.Delete(ctx, "app/db", []int{1}); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := kv.Get(ctx, "app/db", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data != nil || secret.Metadata.DeletionTime == nil {
+		t.Errorf("deleted version: want no data and a deletion time, have %+v", secret)
+	}
+	if err := kv.Undelete(ctx, "app/db", []int{1}); err != nil {
+		t.Fatal(err)
+	}
+	if secret, err = kv.Get(ctx, "app/db", 1); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "admin", secret.Data["user"]; want != have {
+		t.Errorf("undeleted version: want %q, have %q", want, have)
+	}
+
+	if err := kv.Destroy(ctx, "app/db", []int{1}); err != nil {
+		t.Fatal(err)
+	}
+	if secret, err = kv.Get(ctx, "app/db", 1); err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data != nil || !secret.Metadata.Destroyed {
+		t.Errorf("destroyed version: want no data and destroyed, have %+v", secret)
+	}
+	if _, err := leases.Lookup(ctx, first.LeaseID); err != lease.ErrNotFound {
+		t.Errorf("lease of destroyed version: want %v, have %v", lease.ErrNotFound, err)
+	}
+	if err := kv.Undelete(ctx, "app/db", []int{1}); err != nil {
+		t.Fatal(err)
+	}
+	if secret, _ = kv.Get(ctx, "app/db", 1); secret.Data != nil {
+		t.Error("destroyed version undeleted")
+	}
+
+	if secret, err = kv.Get(ctx, "app/db", 0); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "root", secret.Data["user"]; want != have {
+		t.Errorf("current version: want %q, have %q", want, have)
+	}
+	if err := kv.Delete(ctx, "app/missing", nil); err != vaultservice.ErrNotFound {
+		t.Errorf("delete missing secret: want %v, have %v", vaultservice.ErrNotFound, err)
+	}
+}
@@ -0,0 +1,79 @@
+package vaultservice
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+
+	"github.com/williamlsh/vault/internal/lease"
+	"github.com/williamlsh/vault/internal/token"
+)
+
+// ErrTokenLease is returned when renewing the lease of a token, which would
+// leave the expiry of the token behind.
+var ErrTokenLease = errors.New("token leases are renewed through auth/token/renew")
+
+// LeaseService manages the leases of the issued secrets and tokens.
+type LeaseService interface {
+	// Lookup returns a lease.
+	Lookup(ctx context.Context, id string) (lease.Lease, error)
+	// Renew extends a lease by increment, zero meaning the default TTL. The
+	// leases of tokens are renewed with the tokens.
+	Renew(ctx context.Context, id string, increment time.Duration) (lease.Lease, error)
+	// Revoke revokes a lease and the secret it was issued for.
+	Revoke(ctx context.Context, id string) error
+	// RevokePrefix revokes all the leases issued under prefix.
+	RevokePrefix(ctx context.Context, prefix string) error
+	// List returns the IDs of the leases issued under prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+type leaseService struct {
+	leases *lease.Manager
+}
+
+// NewLeaseService makes a new lease service backed by the lease manager.
+func NewLeaseService(logger log.Logger, ints metrics.Counter, leases *lease.Manager) LeaseService {
+	var svc LeaseService
+	{
+		svc = &leaseService{leases: leases}
+		svc = LeaseLoggingMiddleware(logger)(svc)
+		svc = LeaseInstrumentingMiddleware(ints)(svc)
+	}
+	return svc
+}
+
+func (s *leaseService) Lookup(ctx context.Context, id string) (lease.Lease, error) {
+	l, err := s.leases.Lookup(ctx, id)
+	if err != nil {
+		return lease.Lease{}, err
+	}
+	return *l, nil
+}
+
+func (s *leaseService) Renew(ctx context.Context, id string, increment time.Duration) (lease.Lease, error) {
+	if strings.HasPrefix(id, token.LeasePrefix) {
+		return lease.Lease{}, ErrTokenLease
+	}
+	l, err := s.leases.Renew(ctx, id, increment)
+	if err != nil {
+		return lease.Lease{}, err
+	}
+	return *l, nil
+}
+
+func (s *leaseService) Revoke(ctx context.Context, id string) error {
+	return s.leases.Revoke(ctx, id)
+}
+
+func (s *leaseService) RevokePrefix(ctx context.Context, prefix string) error {
+	return s.leases.RevokePrefix(ctx, prefix)
+}
+
+func (s *leaseService) List(ctx context.Context, prefix string) ([]string, error) {
+	return s.leases.List(ctx, prefix)
+}
@@ -2,10 +2,18 @@ package vaultservice
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"time"
 
-	"github.com/go-kit/kit/auth/jwt"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics"
+
+	"github.com/williamlsh/vault/internal/denylist"
+	"github.com/williamlsh/vault/internal/identity"
+	"github.com/williamlsh/vault/internal/lease"
+	"github.com/williamlsh/vault/internal/seal"
+	"github.com/williamlsh/vault/internal/token"
 )
 
 // Middleware represents a service middleware.
@@ -29,18 +37,25 @@ type loggingMiddleware struct {
 
 func (mw loggingMiddleware) Hash(ctx context.Context, password string) (hash string, err error) {
 	defer func() {
-		mw.logger.Log("method", "Hash", "password", password, "hash", hash, "token", ctx.Value(jwt.JWTTokenContextKey).(string), "err", err)
+		mw.logger.Log("method", "Hash", "password", password, "hash", hash, "subject", subject(ctx), "err", err)
 	}()
 	return mw.next.Hash(ctx, password)
 }
 
 func (mw loggingMiddleware) Validate(ctx context.Context, password, hash string) (v bool, err error) {
 	defer func() {
-		mw.logger.Log("method", "Validate", "password", password, "hash", hash, "valid", v, "token", ctx.Value(jwt.JWTTokenContextKey).(string), "err", err)
+		mw.logger.Log("method", "Validate", "password", password, "hash", hash, "valid", v, "subject", subject(ctx), "err", err)
 	}()
 	return mw.next.Validate(ctx, password, hash)
 }
 
+// subject returns the subject of the caller identity, since tokens must not
+// end up in logs.
+func subject(ctx context.Context) string {
+	id, _ := identity.FromContext(ctx)
+	return id.Subject
+}
+
 // InstrumentingMiddleware returns a service middleware that instruments
 // the number of HTTP requests of the service.
 func InstrumentingMiddleware(ints metrics.Counter) Middleware {
@@ -66,3 +81,1335 @@ func (mw instrumentingMiddleware) Validate(ctx context.Context, password, hash s
 	defer mw.ints.Add(1)
 	return mw.next.Validate(ctx, password, hash)
 }
+
+// SysMiddleware represents a system service middleware.
+type SysMiddleware func(SysService) SysService
+
+// SysLoggingMiddleware takes a logger as a dependency and returns a
+// SysMiddleware. Unseal keys are never logged.
+func SysLoggingMiddleware(logger log.Logger) SysMiddleware {
+	return func(next SysService) SysService {
+		return sysLoggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+type sysLoggingMiddleware struct {
+	logger log.Logger
+	next   SysService
+}
+
+func (mw sysLoggingMiddleware) Init(ctx context.Context, shares, threshold int) (keys []string, rootToken string, err error) {
+	defer func() {
+		mw.logger.Log("method", "Init", "shares", shares, "threshold", threshold, "err", err)
+	}()
+	return mw.next.Init(ctx, shares, threshold)
+}
+
+func (mw sysLoggingMiddleware) Unseal(ctx context.Context, key string, reset bool) (status seal.Status, err error) {
+	defer func() {
+		mw.logger.Log("method", "Unseal", "reset", reset, "sealed", status.Sealed, "progress", status.Progress, "err", err)
+	}()
+	return mw.next.Unseal(ctx, key, reset)
+}
+
+func (mw sysLoggingMiddleware) Seal(ctx context.Context) (err error) {
+	defer func() {
+		mw.logger.Log("method", "Seal", "err", err)
+	}()
+	return mw.next.Seal(ctx)
+}
+
+func (mw sysLoggingMiddleware) SealStatus(ctx context.Context) (status seal.Status, err error) {
+	defer func() {
+		mw.logger.Log("method", "SealStatus", "sealed", status.Sealed, "err", err)
+	}()
+	return mw.next.SealStatus(ctx)
+}
+
+func (mw sysLoggingMiddleware) Rotate(ctx context.Context) (status seal.KeyStatus, err error) {
+	defer func() {
+		mw.logger.Log("method", "Rotate", "term", status.Term, "err", err)
+	}()
+	return mw.next.Rotate(ctx)
+}
+
+// SysInstrumentingMiddleware returns a system service middleware that
+// instruments the number of requests of the service.
+func SysInstrumentingMiddleware(ints metrics.Counter) SysMiddleware {
+	return func(next SysService) SysService {
+		return sysInstrumentingMiddleware{
+			ints: ints,
+			next: next,
+		}
+	}
+}
+
+type sysInstrumentingMiddleware struct {
+	ints metrics.Counter
+	next SysService
+}
+
+func (mw sysInstrumentingMiddleware) Init(ctx context.Context, shares, threshold int) ([]string, string, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Init(ctx, shares, threshold)
+}
+
+func (mw sysInstrumentingMiddleware) Unseal(ctx context.Context, key string, reset bool) (seal.Status, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Unseal(ctx, key, reset)
+}
+
+func (mw sysInstrumentingMiddleware) Seal(ctx context.Context) error {
+	defer mw.ints.Add(1)
+	return mw.next.Seal(ctx)
+}
+
+func (mw sysInstrumentingMiddleware) SealStatus(ctx context.Context) (seal.Status, error) {
+	defer mw.ints.Add(1)
+	return mw.next.SealStatus(ctx)
+}
+
+func (mw sysInstrumentingMiddleware) Rotate(ctx context.Context) (seal.KeyStatus, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Rotate(ctx)
+}
+
+// KVMiddleware represents a key-value service middleware.
+type KVMiddleware func(KVService) KVService
+
+// KVLoggingMiddleware takes a logger as a dependency and returns a
+// KVMiddleware. Secret data is never logged.
+func KVLoggingMiddleware(logger log.Logger) KVMiddleware {
+	return func(next KVService) KVService {
+		return kvLoggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+type kvLoggingMiddleware struct {
+	logger log.Logger
+	next   KVService
+}
+
+func (mw kvLoggingMiddleware) Put(ctx context.Context, path string, data map[string]string, cas *int) (md KVVersionMetadata, err error) {
+	defer func() {
+		mw.logger.Log("method", "Put", "path", path, "version", md.Version, "err", err)
+	}()
+	return mw.next.Put(ctx, path, data, cas)
+}
+
+func (mw kvLoggingMiddleware) Get(ctx context.Context, path string, version int) (secret KVSecret, err error) {
+	defer func() {
+		mw.logger.Log("method", "Get", "path", path, "version", secret.Metadata.Version, "err", err)
+	}()
+	return mw.next.Get(ctx, path, version)
+}
+
+func (mw kvLoggingMiddleware) Delete(ctx context.Context, path string, versions []int) (err error) {
+	defer func() {
+		mw.logger.Log("method", "Delete", "path", path, "versions", fmt.Sprint(versions), "err", err)
+	}()
+	return mw.next.Delete(ctx, path, versions)
+}
+
+func (mw kvLoggingMiddleware) Undelete(ctx context.Context, path string, versions []int) (err error) {
+	defer func() {
+		mw.logger.Log("method", "Undelete", "path", path, "versions", fmt.Sprint(versions), "err", err)
+	}()
+	return mw.next.Undelete(ctx, path, versions)
+}
+
+func (mw kvLoggingMiddleware) Destroy(ctx context.Context, path string, versions []int) (err error) {
+	defer func() {
+		mw.logger.Log("method", "Destroy", "path", path, "versions", fmt.Sprint(versions), "err", err)
+	}()
+	return mw.next.Destroy(ctx, path, versions)
+}
+
+func (mw kvLoggingMiddleware) List(ctx context.Context, path string) (keys []string, err error) {
+	defer func() {
+		mw.logger.Log("method", "List", "path", path, "keys", len(keys), "err", err)
+	}()
+	return mw.next.List(ctx, path)
+}
+
+func (mw kvLoggingMiddleware) ReadMetadata(ctx context.Context, path string) (md KVMetadata, err error) {
+	defer func() {
+		mw.logger.Log("method", "ReadMetadata", "path", path, "err", err)
+	}()
+	return mw.next.ReadMetadata(ctx, path)
+}
+
+func (mw kvLoggingMiddleware) WriteMetadata(ctx context.Context, path string, cfg KVMetadataConfig) (err error) {
+	defer func() {
+		mw.logger.Log("method", "WriteMetadata", "path", path, "max_versions", cfg.MaxVersions, "cas_required", cfg.CASRequired, "err", err)
+	}()
+	return mw.next.WriteMetadata(ctx, path, cfg)
+}
+
+func (mw kvLoggingMiddleware) DeleteMetadata(ctx context.Context, path string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "DeleteMetadata", "path", path, "err", err)
+	}()
+	return mw.next.DeleteMetadata(ctx, path)
+}
+
+// KVInstrumentingMiddleware returns a key-value service middleware that
+// instruments the number of requests of the service.
+func KVInstrumentingMiddleware(ints metrics.Counter) KVMiddleware {
+	return func(next KVService) KVService {
+		return kvInstrumentingMiddleware{
+			ints: ints,
+			next: next,
+		}
+	}
+}
+
+type kvInstrumentingMiddleware struct {
+	ints metrics.Counter
+	next KVService
+}
+
+func (mw kvInstrumentingMiddleware) Put(ctx context.Context, path string, data map[string]string, cas *int) (KVVersionMetadata, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Put(ctx, path, data, cas)
+}
+
+func (mw kvInstrumentingMiddleware) Get(ctx context.Context, path string, version int) (KVSecret, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Get(ctx, path, version)
+}
+
+func (mw kvInstrumentingMiddleware) Delete(ctx context.Context, path string, versions []int) error {
+	defer mw.ints.Add(1)
+	return mw.next.Delete(ctx, path, versions)
+}
+
+func (mw kvInstrumentingMiddleware) Undelete(ctx context.Context, path string, versions []int) error {
+	defer mw.ints.Add(1)
+	return mw.next.Undelete(ctx, path, versions)
+}
+
+func (mw kvInstrumentingMiddleware) Destroy(ctx context.Context, path string, versions []int) error {
+	defer mw.ints.Add(1)
+	return mw.next.Destroy(ctx, path, versions)
+}
+
+func (mw kvInstrumentingMiddleware) List(ctx context.Context, path string) ([]string, error) {
+	defer mw.ints.Add(1)
+	return mw.next.List(ctx, path)
+}
+
+func (mw kvInstrumentingMiddleware) ReadMetadata(ctx context.Context, path string) (KVMetadata, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ReadMetadata(ctx, path)
+}
+
+func (mw kvInstrumentingMiddleware) WriteMetadata(ctx context.Context, path string, cfg KVMetadataConfig) error {
+	defer mw.ints.Add(1)
+	return mw.next.WriteMetadata(ctx, path, cfg)
+}
+
+func (mw kvInstrumentingMiddleware) DeleteMetadata(ctx context.Context, path string) error {
+	defer mw.ints.Add(1)
+	return mw.next.DeleteMetadata(ctx, path)
+}
+
+// PolicyMiddleware represents a policy service middleware.
+type PolicyMiddleware func(PolicyService) PolicyService
+
+// PolicyLoggingMiddleware takes a logger as a dependency and returns a
+// PolicyMiddleware.
+func PolicyLoggingMiddleware(logger log.Logger) PolicyMiddleware {
+	return func(next PolicyService) PolicyService {
+		return policyLoggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+type policyLoggingMiddleware struct {
+	logger log.Logger
+	next   PolicyService
+}
+
+func (mw policyLoggingMiddleware) ReadPolicy(ctx context.Context, name string) (rules string, err error) {
+	defer func() {
+		mw.logger.Log("method", "ReadPolicy", "name", name, "err", err)
+	}()
+	return mw.next.ReadPolicy(ctx, name)
+}
+
+func (mw policyLoggingMiddleware) WritePolicy(ctx context.Context, name, rules string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "WritePolicy", "name", name, "err", err)
+	}()
+	return mw.next.WritePolicy(ctx, name, rules)
+}
+
+func (mw policyLoggingMiddleware) DeletePolicy(ctx context.Context, name string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "DeletePolicy", "name", name, "err", err)
+	}()
+	return mw.next.DeletePolicy(ctx, name)
+}
+
+func (mw policyLoggingMiddleware) ListPolicies(ctx context.Context) (names []string, err error) {
+	defer func() {
+		mw.logger.Log("method", "ListPolicies", "policies", len(names), "err", err)
+	}()
+	return mw.next.ListPolicies(ctx)
+}
+
+func (mw policyLoggingMiddleware) ReadSubject(ctx context.Context, subject string) (policies []string, err error) {
+	defer func() {
+		mw.logger.Log("method", "ReadSubject", "subject", subject, "err", err)
+	}()
+	return mw.next.ReadSubject(ctx, subject)
+}
+
+func (mw policyLoggingMiddleware) WriteSubject(ctx context.Context, subject string, policies []string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "WriteSubject", "subject", subject, "policies", fmt.Sprint(policies), "err", err)
+	}()
+	return mw.next.WriteSubject(ctx, subject, policies)
+}
+
+// PolicyInstrumentingMiddleware returns a policy service middleware that
+// instruments the number of requests of the service.
+func PolicyInstrumentingMiddleware(ints metrics.Counter) PolicyMiddleware {
+	return func(next PolicyService) PolicyService {
+		return policyInstrumentingMiddleware{
+			ints: ints,
+			next: next,
+		}
+	}
+}
+
+type policyInstrumentingMiddleware struct {
+	ints metrics.Counter
+	next PolicyService
+}
+
+func (mw policyInstrumentingMiddleware) ReadPolicy(ctx context.Context, name string) (string, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ReadPolicy(ctx, name)
+}
+
+func (mw policyInstrumentingMiddleware) WritePolicy(ctx context.Context, name, rules string) error {
+	defer mw.ints.Add(1)
+	return mw.next.WritePolicy(ctx, name, rules)
+}
+
+func (mw policyInstrumentingMiddleware) DeletePolicy(ctx context.Context, name string) error {
+	defer mw.ints.Add(1)
+	return mw.next.DeletePolicy(ctx, name)
+}
+
+func (mw policyInstrumentingMiddleware) ListPolicies(ctx context.Context) ([]string, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ListPolicies(ctx)
+}
+
+func (mw policyInstrumentingMiddleware) ReadSubject(ctx context.Context, subject string) ([]string, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ReadSubject(ctx, subject)
+}
+
+func (mw policyInstrumentingMiddleware) WriteSubject(ctx context.Context, subject string, policies []string) error {
+	defer mw.ints.Add(1)
+	return mw.next.WriteSubject(ctx, subject, policies)
+}
+
+// LeaseMiddleware represents a lease service middleware.
+type LeaseMiddleware func(LeaseService) LeaseService
+
+// LeaseLoggingMiddleware takes a logger as a dependency and returns a
+// LeaseMiddleware.
+func LeaseLoggingMiddleware(logger log.Logger) LeaseMiddleware {
+	return func(next LeaseService) LeaseService {
+		return leaseLoggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+type leaseLoggingMiddleware struct {
+	logger log.Logger
+	next   LeaseService
+}
+
+func (mw leaseLoggingMiddleware) Lookup(ctx context.Context, id string) (l lease.Lease, err error) {
+	defer func() {
+		mw.logger.Log("method", "Lookup", "lease_id", id, "err", err)
+	}()
+	return mw.next.Lookup(ctx, id)
+}
+
+func (mw leaseLoggingMiddleware) Renew(ctx context.Context, id string, increment time.Duration) (l lease.Lease, err error) {
+	defer func() {
+		mw.logger.Log("method", "Renew", "lease_id", id, "increment", increment, "expire_time", l.ExpireTime, "err", err)
+	}()
+	return mw.next.Renew(ctx, id, increment)
+}
+
+func (mw leaseLoggingMiddleware) Revoke(ctx context.Context, id string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "Revoke", "lease_id", id, "err", err)
+	}()
+	return mw.next.Revoke(ctx, id)
+}
+
+func (mw leaseLoggingMiddleware) RevokePrefix(ctx context.Context, prefix string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "RevokePrefix", "prefix", prefix, "err", err)
+	}()
+	return mw.next.RevokePrefix(ctx, prefix)
+}
+
+func (mw leaseLoggingMiddleware) List(ctx context.Context, prefix string) (ids []string, err error) {
+	defer func() {
+		mw.logger.Log("method", "List", "prefix", prefix, "leases", len(ids), "err", err)
+	}()
+	return mw.next.List(ctx, prefix)
+}
+
+// LeaseInstrumentingMiddleware returns a lease service middleware that
+// instruments the number of requests of the service.
+func LeaseInstrumentingMiddleware(ints metrics.Counter) LeaseMiddleware {
+	return func(next LeaseService) LeaseService {
+		return leaseInstrumentingMiddleware{
+			ints: ints,
+			next: next,
+		}
+	}
+}
+
+type leaseInstrumentingMiddleware struct {
+	ints metrics.Counter
+	next LeaseService
+}
+
+func (mw leaseInstrumentingMiddleware) Lookup(ctx context.Context, id string) (lease.Lease, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Lookup(ctx, id)
+}
+
+func (mw leaseInstrumentingMiddleware) Renew(ctx context.Context, id string, increment time.Duration) (lease.Lease, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Renew(ctx, id, increment)
+}
+
+func (mw leaseInstrumentingMiddleware) Revoke(ctx context.Context, id string) error {
+	defer mw.ints.Add(1)
+	return mw.next.Revoke(ctx, id)
+}
+
+func (mw leaseInstrumentingMiddleware) RevokePrefix(ctx context.Context, prefix string) error {
+	defer mw.ints.Add(1)
+	return mw.next.RevokePrefix(ctx, prefix)
+}
+
+func (mw leaseInstrumentingMiddleware) List(ctx context.Context, prefix string) ([]string, error) {
+	defer mw.ints.Add(1)
+	return mw.next.List(ctx, prefix)
+}
+
+// TokenMiddleware represents a token service middleware.
+type TokenMiddleware func(TokenService) TokenService
+
+// TokenLoggingMiddleware takes a logger as a dependency and returns a
+// TokenMiddleware. Token IDs are never logged.
+func TokenLoggingMiddleware(logger log.Logger) TokenMiddleware {
+	return func(next TokenService) TokenService {
+		return tokenLoggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+type tokenLoggingMiddleware struct {
+	logger log.Logger
+	next   TokenService
+}
+
+func (mw tokenLoggingMiddleware) Create(ctx context.Context, opts TokenOptions) (t token.Token, err error) {
+	defer func() {
+		mw.logger.Log("method", "Create", "subject", subject(ctx), "display_name", t.DisplayName, "policies", fmt.Sprint(t.Policies), "orphan", opts.NoParent, "expire_time", t.ExpireTime, "err", err)
+	}()
+	return mw.next.Create(ctx, opts)
+}
+
+func (mw tokenLoggingMiddleware) Lookup(ctx context.Context, id string) (t token.Token, err error) {
+	defer func() {
+		mw.logger.Log("method", "Lookup", "subject", subject(ctx), "self", id == "", "err", err)
+	}()
+	return mw.next.Lookup(ctx, id)
+}
+
+func (mw tokenLoggingMiddleware) Renew(ctx context.Context, id string, increment time.Duration) (t token.Token, err error) {
+	defer func() {
+		mw.logger.Log("method", "Renew", "subject", subject(ctx), "self", id == "", "increment", increment, "expire_time", t.ExpireTime, "err", err)
+	}()
+	return mw.next.Renew(ctx, id, increment)
+}
+
+func (mw tokenLoggingMiddleware) Revoke(ctx context.Context, id string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "Revoke", "subject", subject(ctx), "self", id == "", "err", err)
+	}()
+	return mw.next.Revoke(ctx, id)
+}
+
+func (mw tokenLoggingMiddleware) RevokeOrphan(ctx context.Context, id string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "RevokeOrphan", "subject", subject(ctx), "err", err)
+	}()
+	return mw.next.RevokeOrphan(ctx, id)
+}
+
+// TokenInstrumentingMiddleware returns a token service middleware that
+// instruments the number of requests of the service.
+func TokenInstrumentingMiddleware(ints metrics.Counter) TokenMiddleware {
+	return func(next TokenService) TokenService {
+		return tokenInstrumentingMiddleware{
+			ints: ints,
+			next: next,
+		}
+	}
+}
+
+type tokenInstrumentingMiddleware struct {
+	ints metrics.Counter
+	next TokenService
+}
+
+func (mw tokenInstrumentingMiddleware) Create(ctx context.Context, opts TokenOptions) (token.Token, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Create(ctx, opts)
+}
+
+func (mw tokenInstrumentingMiddleware) Lookup(ctx context.Context, id string) (token.Token, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Lookup(ctx, id)
+}
+
+func (mw tokenInstrumentingMiddleware) Renew(ctx context.Context, id string, increment time.Duration) (token.Token, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Renew(ctx, id, increment)
+}
+
+func (mw tokenInstrumentingMiddleware) Revoke(ctx context.Context, id string) error {
+	defer mw.ints.Add(1)
+	return mw.next.Revoke(ctx, id)
+}
+
+func (mw tokenInstrumentingMiddleware) RevokeOrphan(ctx context.Context, id string) error {
+	defer mw.ints.Add(1)
+	return mw.next.RevokeOrphan(ctx, id)
+}
+
+// AppRoleMiddleware represents an AppRole service middleware.
+type AppRoleMiddleware func(AppRoleService) AppRoleService
+
+// AppRoleLoggingMiddleware takes a logger as a dependency and returns an
+// AppRoleMiddleware. Secret IDs are never logged.
+func AppRoleLoggingMiddleware(logger log.Logger) AppRoleMiddleware {
+	return func(next AppRoleService) AppRoleService {
+		return appRoleLoggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+type appRoleLoggingMiddleware struct {
+	logger log.Logger
+	next   AppRoleService
+}
+
+func (mw appRoleLoggingMiddleware) WriteRole(ctx context.Context, name string, role AppRole) (err error) {
+	defer func() {
+		mw.logger.Log("method", "WriteRole", "subject", subject(ctx), "role", name, "policies", fmt.Sprint(role.TokenPolicies), "err", err)
+	}()
+	return mw.next.WriteRole(ctx, name, role)
+}
+
+func (mw appRoleLoggingMiddleware) ReadRole(ctx context.Context, name string) (role AppRole, err error) {
+	defer func() {
+		mw.logger.Log("method", "ReadRole", "subject", subject(ctx), "role", name, "err", err)
+	}()
+	return mw.next.ReadRole(ctx, name)
+}
+
+func (mw appRoleLoggingMiddleware) DeleteRole(ctx context.Context, name string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "DeleteRole", "subject", subject(ctx), "role", name, "err", err)
+	}()
+	return mw.next.DeleteRole(ctx, name)
+}
+
+func (mw appRoleLoggingMiddleware) ListRoles(ctx context.Context) (names []string, err error) {
+	defer func() {
+		mw.logger.Log("method", "ListRoles", "subject", subject(ctx), "roles", len(names), "err", err)
+	}()
+	return mw.next.ListRoles(ctx)
+}
+
+func (mw appRoleLoggingMiddleware) GenerateSecretID(ctx context.Context, name string) (s AppRoleSecretID, err error) {
+	defer func() {
+		mw.logger.Log("method", "GenerateSecretID", "subject", subject(ctx), "role", name, "accessor", s.Accessor, "err", err)
+	}()
+	return mw.next.GenerateSecretID(ctx, name)
+}
+
+func (mw appRoleLoggingMiddleware) DestroySecretID(ctx context.Context, name, accessor string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "DestroySecretID", "subject", subject(ctx), "role", name, "accessor", accessor, "err", err)
+	}()
+	return mw.next.DestroySecretID(ctx, name, accessor)
+}
+
+func (mw appRoleLoggingMiddleware) Login(ctx context.Context, roleID, secretID string) (t token.Token, err error) {
+	defer func() {
+		mw.logger.Log("method", "Login", "display_name", t.DisplayName, "policies", fmt.Sprint(t.Policies), "expire_time", t.ExpireTime, "err", err)
+	}()
+	return mw.next.Login(ctx, roleID, secretID)
+}
+
+// AppRoleInstrumentingMiddleware returns an AppRole service middleware that
+// instruments the number of requests of the service.
+func AppRoleInstrumentingMiddleware(ints metrics.Counter) AppRoleMiddleware {
+	return func(next AppRoleService) AppRoleService {
+		return appRoleInstrumentingMiddleware{
+			ints: ints,
+			next: next,
+		}
+	}
+}
+
+type appRoleInstrumentingMiddleware struct {
+	ints metrics.Counter
+	next AppRoleService
+}
+
+func (mw appRoleInstrumentingMiddleware) WriteRole(ctx context.Context, name string, role AppRole) error {
+	defer mw.ints.Add(1)
+	return mw.next.WriteRole(ctx, name, role)
+}
+
+func (mw appRoleInstrumentingMiddleware) ReadRole(ctx context.Context, name string) (AppRole, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ReadRole(ctx, name)
+}
+
+func (mw appRoleInstrumentingMiddleware) DeleteRole(ctx context.Context, name string) error {
+	defer mw.ints.Add(1)
+	return mw.next.DeleteRole(ctx, name)
+}
+
+func (mw appRoleInstrumentingMiddleware) ListRoles(ctx context.Context) ([]string, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ListRoles(ctx)
+}
+
+func (mw appRoleInstrumentingMiddleware) GenerateSecretID(ctx context.Context, name string) (AppRoleSecretID, error) {
+	defer mw.ints.Add(1)
+	return mw.next.GenerateSecretID(ctx, name)
+}
+
+func (mw appRoleInstrumentingMiddleware) DestroySecretID(ctx context.Context, name, accessor string) error {
+	defer mw.ints.Add(1)
+	return mw.next.DestroySecretID(ctx, name, accessor)
+}
+
+func (mw appRoleInstrumentingMiddleware) Login(ctx context.Context, roleID, secretID string) (token.Token, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Login(ctx, roleID, secretID)
+}
+
+// UserpassMiddleware represents a userpass service middleware.
+type UserpassMiddleware func(UserpassService) UserpassService
+
+// UserpassLoggingMiddleware takes a logger as a dependency and returns a
+// UserpassMiddleware. Passwords are never logged.
+func UserpassLoggingMiddleware(logger log.Logger) UserpassMiddleware {
+	return func(next UserpassService) UserpassService {
+		return userpassLoggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+type userpassLoggingMiddleware struct {
+	logger log.Logger
+	next   UserpassService
+}
+
+func (mw userpassLoggingMiddleware) WriteUser(ctx context.Context, username string, user User) (err error) {
+	defer func() {
+		mw.logger.Log("method", "WriteUser", "subject", subject(ctx), "username", username, "password_changed", user.Password != "", "policies", fmt.Sprint(user.TokenPolicies), "err", err)
+	}()
+	return mw.next.WriteUser(ctx, username, user)
+}
+
+func (mw userpassLoggingMiddleware) ReadUser(ctx context.Context, username string) (user User, err error) {
+	defer func() {
+		mw.logger.Log("method", "ReadUser", "subject", subject(ctx), "username", username, "err", err)
+	}()
+	return mw.next.ReadUser(ctx, username)
+}
+
+func (mw userpassLoggingMiddleware) DeleteUser(ctx context.Context, username string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "DeleteUser", "subject", subject(ctx), "username", username, "err", err)
+	}()
+	return mw.next.DeleteUser(ctx, username)
+}
+
+func (mw userpassLoggingMiddleware) ListUsers(ctx context.Context) (names []string, err error) {
+	defer func() {
+		mw.logger.Log("method", "ListUsers", "subject", subject(ctx), "users", len(names), "err", err)
+	}()
+	return mw.next.ListUsers(ctx)
+}
+
+func (mw userpassLoggingMiddleware) Login(ctx context.Context, username, password string) (t token.Token, err error) {
+	defer func() {
+		mw.logger.Log("method", "Login", "username", username, "policies", fmt.Sprint(t.Policies), "expire_time", t.ExpireTime, "err", err)
+	}()
+	return mw.next.Login(ctx, username, password)
+}
+
+// UserpassInstrumentingMiddleware returns a userpass service middleware that
+// instruments the number of requests of the service.
+func UserpassInstrumentingMiddleware(ints metrics.Counter) UserpassMiddleware {
+	return func(next UserpassService) UserpassService {
+		return userpassInstrumentingMiddleware{
+			ints: ints,
+			next: next,
+		}
+	}
+}
+
+type userpassInstrumentingMiddleware struct {
+	ints metrics.Counter
+	next UserpassService
+}
+
+func (mw userpassInstrumentingMiddleware) WriteUser(ctx context.Context, username string, user User) error {
+	defer mw.ints.Add(1)
+	return mw.next.WriteUser(ctx, username, user)
+}
+
+func (mw userpassInstrumentingMiddleware) ReadUser(ctx context.Context, username string) (User, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ReadUser(ctx, username)
+}
+
+func (mw userpassInstrumentingMiddleware) DeleteUser(ctx context.Context, username string) error {
+	defer mw.ints.Add(1)
+	return mw.next.DeleteUser(ctx, username)
+}
+
+func (mw userpassInstrumentingMiddleware) ListUsers(ctx context.Context) ([]string, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ListUsers(ctx)
+}
+
+func (mw userpassInstrumentingMiddleware) Login(ctx context.Context, username, password string) (token.Token, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Login(ctx, username, password)
+}
+
+// OAuthMiddleware represents an OAuth2 service middleware.
+type OAuthMiddleware func(OAuthService) OAuthService
+
+// OAuthLoggingMiddleware takes a logger as a dependency and returns an
+// OAuthMiddleware. Client secrets and access tokens are never logged.
+func OAuthLoggingMiddleware(logger log.Logger) OAuthMiddleware {
+	return func(next OAuthService) OAuthService {
+		return oauthLoggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+type oauthLoggingMiddleware struct {
+	logger log.Logger
+	next   OAuthService
+}
+
+func (mw oauthLoggingMiddleware) WriteClient(ctx context.Context, id string, client OAuthClient) (secret string, err error) {
+	defer func() {
+		mw.logger.Log("method", "WriteClient", "subject", subject(ctx), "client_id", id, "created", secret != "", "scopes", fmt.Sprint(client.Scopes), "policies", fmt.Sprint(client.TokenPolicies), "err", err)
+	}()
+	return mw.next.WriteClient(ctx, id, client)
+}
+
+func (mw oauthLoggingMiddleware) ReadClient(ctx context.Context, id string) (client OAuthClient, err error) {
+	defer func() {
+		mw.logger.Log("method", "ReadClient", "subject", subject(ctx), "client_id", id, "err", err)
+	}()
+	return mw.next.ReadClient(ctx, id)
+}
+
+func (mw oauthLoggingMiddleware) DeleteClient(ctx context.Context, id string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "DeleteClient", "subject", subject(ctx), "client_id", id, "err", err)
+	}()
+	return mw.next.DeleteClient(ctx, id)
+}
+
+func (mw oauthLoggingMiddleware) ListClients(ctx context.Context) (ids []string, err error) {
+	defer func() {
+		mw.logger.Log("method", "ListClients", "subject", subject(ctx), "clients", len(ids), "err", err)
+	}()
+	return mw.next.ListClients(ctx)
+}
+
+func (mw oauthLoggingMiddleware) Token(ctx context.Context, req AccessTokenRequest) (t AccessToken, err error) {
+	defer func() {
+		mw.logger.Log("method", "Token", "client_id", req.ClientID, "grant_type", req.GrantType, "scope", t.Scope, "expires_in", t.ExpiresIn, "err", err)
+	}()
+	return mw.next.Token(ctx, req)
+}
+
+func (mw oauthLoggingMiddleware) Introspect(ctx context.Context, creds ClientCredentials, accessToken string) (i Introspection, err error) {
+	defer func() {
+		mw.logger.Log("method", "Introspect", "client_id", creds.ClientID, "active", i.Active, "jti", i.ID, "err", err)
+	}()
+	return mw.next.Introspect(ctx, creds, accessToken)
+}
+
+func (mw oauthLoggingMiddleware) Revoke(ctx context.Context, creds ClientCredentials, accessToken string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "Revoke", "client_id", creds.ClientID, "err", err)
+	}()
+	return mw.next.Revoke(ctx, creds, accessToken)
+}
+
+// OAuthInstrumentingMiddleware returns an OAuth2 service middleware that
+// instruments the number of requests of the service.
+func OAuthInstrumentingMiddleware(ints metrics.Counter) OAuthMiddleware {
+	return func(next OAuthService) OAuthService {
+		return oauthInstrumentingMiddleware{
+			ints: ints,
+			next: next,
+		}
+	}
+}
+
+type oauthInstrumentingMiddleware struct {
+	ints metrics.Counter
+	next OAuthService
+}
+
+func (mw oauthInstrumentingMiddleware) WriteClient(ctx context.Context, id string, client OAuthClient) (string, error) {
+	defer mw.ints.Add(1)
+	return mw.next.WriteClient(ctx, id, client)
+}
+
+func (mw oauthInstrumentingMiddleware) ReadClient(ctx context.Context, id string) (OAuthClient, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ReadClient(ctx, id)
+}
+
+func (mw oauthInstrumentingMiddleware) DeleteClient(ctx context.Context, id string) error {
+	defer mw.ints.Add(1)
+	return mw.next.DeleteClient(ctx, id)
+}
+
+func (mw oauthInstrumentingMiddleware) ListClients(ctx context.Context) ([]string, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ListClients(ctx)
+}
+
+func (mw oauthInstrumentingMiddleware) Token(ctx context.Context, req AccessTokenRequest) (AccessToken, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Token(ctx, req)
+}
+
+func (mw oauthInstrumentingMiddleware) Introspect(ctx context.Context, creds ClientCredentials, accessToken string) (Introspection, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Introspect(ctx, creds, accessToken)
+}
+
+func (mw oauthInstrumentingMiddleware) Revoke(ctx context.Context, creds ClientCredentials, accessToken string) error {
+	defer mw.ints.Add(1)
+	return mw.next.Revoke(ctx, creds, accessToken)
+}
+
+// DenylistMiddleware represents a denylist service middleware.
+type DenylistMiddleware func(DenylistService) DenylistService
+
+// DenylistLoggingMiddleware takes a logger as a dependency and returns a
+// DenylistMiddleware.
+func DenylistLoggingMiddleware(logger log.Logger) DenylistMiddleware {
+	return func(next DenylistService) DenylistService {
+		return denylistLoggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+type denylistLoggingMiddleware struct {
+	logger log.Logger
+	next   DenylistService
+}
+
+func (mw denylistLoggingMiddleware) Deny(ctx context.Context, opts DenyOptions) (e denylist.Entry, err error) {
+	defer func() {
+		mw.logger.Log("method", "Deny", "subject", subject(ctx), "kind", opts.Kind, "value", opts.Value, "ttl", opts.TTL, "err", err)
+	}()
+	return mw.next.Deny(ctx, opts)
+}
+
+func (mw denylistLoggingMiddleware) Allow(ctx context.Context, kind, value string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "Allow", "subject", subject(ctx), "kind", kind, "value", value, "err", err)
+	}()
+	return mw.next.Allow(ctx, kind, value)
+}
+
+func (mw denylistLoggingMiddleware) ReadDenied(ctx context.Context, kind, value string) (e denylist.Entry, err error) {
+	defer func() {
+		mw.logger.Log("method", "ReadDenied", "kind", kind, "value", value, "err", err)
+	}()
+	return mw.next.ReadDenied(ctx, kind, value)
+}
+
+func (mw denylistLoggingMiddleware) ListDenied(ctx context.Context) (entries []denylist.Entry, err error) {
+	defer func() {
+		mw.logger.Log("method", "ListDenied", "entries", len(entries), "err", err)
+	}()
+	return mw.next.ListDenied(ctx)
+}
+
+// DenylistInstrumentingMiddleware returns a denylist service middleware that
+// instruments the number of requests of the service.
+func DenylistInstrumentingMiddleware(ints metrics.Counter) DenylistMiddleware {
+	return func(next DenylistService) DenylistService {
+		return denylistInstrumentingMiddleware{
+			ints: ints,
+			next: next,
+		}
+	}
+}
+
+type denylistInstrumentingMiddleware struct {
+	ints metrics.Counter
+	next DenylistService
+}
+
+func (mw denylistInstrumentingMiddleware) Deny(ctx context.Context, opts DenyOptions) (denylist.Entry, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Deny(ctx, opts)
+}
+
+func (mw denylistInstrumentingMiddleware) Allow(ctx context.Context, kind, value string) error {
+	defer mw.ints.Add(1)
+	return mw.next.Allow(ctx, kind, value)
+}
+
+func (mw denylistInstrumentingMiddleware) ReadDenied(ctx context.Context, kind, value string) (denylist.Entry, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ReadDenied(ctx, kind, value)
+}
+
+func (mw denylistInstrumentingMiddleware) ListDenied(ctx context.Context) ([]denylist.Entry, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ListDenied(ctx)
+}
+
+// PKIMiddleware represents a PKI service middleware.
+type PKIMiddleware func(PKIService) PKIService
+
+// PKILoggingMiddleware takes a logger as a dependency and returns a
+// PKIMiddleware.
+func PKILoggingMiddleware(logger log.Logger) PKIMiddleware {
+	return func(next PKIService) PKIService {
+		return pkiLoggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+type pkiLoggingMiddleware struct {
+	logger log.Logger
+	next   PKIService
+}
+
+func (mw pkiLoggingMiddleware) GenerateCA(ctx context.Context, opts PKIGenerateOptions) (ca PKICA, err error) {
+	defer func() {
+		mw.logger.Log("method", "GenerateCA", "subject", subject(ctx), "intermediate", opts.Intermediate, "common_name", opts.CommonName, "serial", ca.SerialNumber, "err", err)
+	}()
+	return mw.next.GenerateCA(ctx, opts)
+}
+
+func (mw pkiLoggingMiddleware) ImportCA(ctx context.Context, bundle string) (ca PKICA, err error) {
+	defer func() {
+		mw.logger.Log("method", "ImportCA", "subject", subject(ctx), "serial", ca.SerialNumber, "err", err)
+	}()
+	return mw.next.ImportCA(ctx, bundle)
+}
+
+func (mw pkiLoggingMiddleware) ReadCA(ctx context.Context) (ca PKICA, err error) {
+	defer func() {
+		mw.logger.Log("method", "ReadCA", "err", err)
+	}()
+	return mw.next.ReadCA(ctx)
+}
+
+func (mw pkiLoggingMiddleware) WriteRole(ctx context.Context, name string, role PKIRole) (err error) {
+	defer func() {
+		mw.logger.Log("method", "WriteRole", "subject", subject(ctx), "role", name, "err", err)
+	}()
+	return mw.next.WriteRole(ctx, name, role)
+}
+
+func (mw pkiLoggingMiddleware) ReadRole(ctx context.Context, name string) (role PKIRole, err error) {
+	defer func() {
+		mw.logger.Log("method", "ReadRole", "subject", subject(ctx), "role", name, "err", err)
+	}()
+	return mw.next.ReadRole(ctx, name)
+}
+
+func (mw pkiLoggingMiddleware) DeleteRole(ctx context.Context, name string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "DeleteRole", "subject", subject(ctx), "role", name, "err", err)
+	}()
+	return mw.next.DeleteRole(ctx, name)
+}
+
+func (mw pkiLoggingMiddleware) ListRoles(ctx context.Context) (names []string, err error) {
+	defer func() {
+		mw.logger.Log("method", "ListRoles", "subject", subject(ctx), "roles", len(names), "err", err)
+	}()
+	return mw.next.ListRoles(ctx)
+}
+
+func (mw pkiLoggingMiddleware) Issue(ctx context.Context, role string, req PKIIssueRequest) (cert PKICertificate, err error) {
+	defer func() {
+		mw.logger.Log("method", "Issue", "subject", subject(ctx), "role", role, "common_name", req.CommonName, "serial", cert.SerialNumber, "err", err)
+	}()
+	return mw.next.Issue(ctx, role, req)
+}
+
+func (mw pkiLoggingMiddleware) Sign(ctx context.Context, role string, req PKISignRequest) (cert PKICertificate, err error) {
+	defer func() {
+		mw.logger.Log("method", "Sign", "subject", subject(ctx), "role", role, "serial", cert.SerialNumber, "err", err)
+	}()
+	return mw.next.Sign(ctx, role, req)
+}
+
+func (mw pkiLoggingMiddleware) Revoke(ctx context.Context, serial string) (cert PKICertificate, err error) {
+	defer func() {
+		mw.logger.Log("method", "Revoke", "subject", subject(ctx), "serial", serial, "err", err)
+	}()
+	return mw.next.Revoke(ctx, serial)
+}
+
+func (mw pkiLoggingMiddleware) ReadCertificate(ctx context.Context, serial string) (cert PKICertificate, err error) {
+	defer func() {
+		mw.logger.Log("method", "ReadCertificate", "subject", subject(ctx), "serial", serial, "err", err)
+	}()
+	return mw.next.ReadCertificate(ctx, serial)
+}
+
+func (mw pkiLoggingMiddleware) ListCertificates(ctx context.Context) (serials []string, err error) {
+	defer func() {
+		mw.logger.Log("method", "ListCertificates", "subject", subject(ctx), "certificates", len(serials), "err", err)
+	}()
+	return mw.next.ListCertificates(ctx)
+}
+
+func (mw pkiLoggingMiddleware) ReadCRL(ctx context.Context) (crl string, err error) {
+	defer func() {
+		mw.logger.Log("method", "ReadCRL", "err", err)
+	}()
+	return mw.next.ReadCRL(ctx)
+}
+
+// PKIInstrumentingMiddleware returns a PKI service middleware that
+// instruments the number of requests of the service.
+func PKIInstrumentingMiddleware(ints metrics.Counter) PKIMiddleware {
+	return func(next PKIService) PKIService {
+		return pkiInstrumentingMiddleware{
+			ints: ints,
+			next: next,
+		}
+	}
+}
+
+type pkiInstrumentingMiddleware struct {
+	ints metrics.Counter
+	next PKIService
+}
+
+func (mw pkiInstrumentingMiddleware) GenerateCA(ctx context.Context, opts PKIGenerateOptions) (PKICA, error) {
+	defer mw.ints.Add(1)
+	return mw.next.GenerateCA(ctx, opts)
+}
+
+func (mw pkiInstrumentingMiddleware) ImportCA(ctx context.Context, bundle string) (PKICA, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ImportCA(ctx, bundle)
+}
+
+func (mw pkiInstrumentingMiddleware) ReadCA(ctx context.Context) (PKICA, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ReadCA(ctx)
+}
+
+func (mw pkiInstrumentingMiddleware) WriteRole(ctx context.Context, name string, role PKIRole) error {
+	defer mw.ints.Add(1)
+	return mw.next.WriteRole(ctx, name, role)
+}
+
+func (mw pkiInstrumentingMiddleware) ReadRole(ctx context.Context, name string) (PKIRole, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ReadRole(ctx, name)
+}
+
+func (mw pkiInstrumentingMiddleware) DeleteRole(ctx context.Context, name string) error {
+	defer mw.ints.Add(1)
+	return mw.next.DeleteRole(ctx, name)
+}
+
+func (mw pkiInstrumentingMiddleware) ListRoles(ctx context.Context) ([]string, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ListRoles(ctx)
+}
+
+func (mw pkiInstrumentingMiddleware) Issue(ctx context.Context, role string, req PKIIssueRequest) (PKICertificate, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Issue(ctx, role, req)
+}
+
+func (mw pkiInstrumentingMiddleware) Sign(ctx context.Context, role string, req PKISignRequest) (PKICertificate, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Sign(ctx, role, req)
+}
+
+func (mw pkiInstrumentingMiddleware) Revoke(ctx context.Context, serial string) (PKICertificate, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Revoke(ctx, serial)
+}
+
+func (mw pkiInstrumentingMiddleware) ReadCertificate(ctx context.Context, serial string) (PKICertificate, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ReadCertificate(ctx, serial)
+}
+
+func (mw pkiInstrumentingMiddleware) ListCertificates(ctx context.Context) ([]string, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ListCertificates(ctx)
+}
+
+func (mw pkiInstrumentingMiddleware) ReadCRL(ctx context.Context) (string, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ReadCRL(ctx)
+}
+
+// SSHMiddleware represents an SSH service middleware.
+type SSHMiddleware func(SSHService) SSHService
+
+// SSHLoggingMiddleware takes a logger as a dependency and returns a
+// SSHMiddleware.
+func SSHLoggingMiddleware(logger log.Logger) SSHMiddleware {
+	return func(next SSHService) SSHService {
+		return sshLoggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+type sshLoggingMiddleware struct {
+	logger log.Logger
+	next   SSHService
+}
+
+func (mw sshLoggingMiddleware) ConfigureCA(ctx context.Context, opts SSHCAOptions) (ca SSHCA, err error) {
+	defer func() {
+		mw.logger.Log("method", "ConfigureCA", "subject", subject(ctx), "imported", opts.PrivateKey != "", "key_type", opts.KeyType, "err", err)
+	}()
+	return mw.next.ConfigureCA(ctx, opts)
+}
+
+func (mw sshLoggingMiddleware) ReadCA(ctx context.Context) (ca SSHCA, err error) {
+	defer func() {
+		mw.logger.Log("method", "ReadCA", "err", err)
+	}()
+	return mw.next.ReadCA(ctx)
+}
+
+func (mw sshLoggingMiddleware) WriteRole(ctx context.Context, name string, role SSHRole) (err error) {
+	defer func() {
+		mw.logger.Log("method", "WriteRole", "subject", subject(ctx), "role", name, "err", err)
+	}()
+	return mw.next.WriteRole(ctx, name, role)
+}
+
+func (mw sshLoggingMiddleware) ReadRole(ctx context.Context, name string) (role SSHRole, err error) {
+	defer func() {
+		mw.logger.Log("method", "ReadRole", "subject", subject(ctx), "role", name, "err", err)
+	}()
+	return mw.next.ReadRole(ctx, name)
+}
+
+func (mw sshLoggingMiddleware) DeleteRole(ctx context.Context, name string) (err error) {
+	defer func() {
+		mw.logger.Log("method", "DeleteRole", "subject", subject(ctx), "role", name, "err", err)
+	}()
+	return mw.next.DeleteRole(ctx, name)
+}
+
+func (mw sshLoggingMiddleware) ListRoles(ctx context.Context) (names []string, err error) {
+	defer func() {
+		mw.logger.Log("method", "ListRoles", "subject", subject(ctx), "roles", len(names), "err", err)
+	}()
+	return mw.next.ListRoles(ctx)
+}
+
+func (mw sshLoggingMiddleware) Sign(ctx context.Context, role string, req SSHSignRequest) (cert SSHCertificate, err error) {
+	defer func() {
+		mw.logger.Log("method", "Sign", "subject", subject(ctx), "role", role, "cert_type", req.CertType, "principals", len(req.ValidPrincipals), "serial", cert.SerialNumber, "err", err)
+	}()
+	return mw.next.Sign(ctx, role, req)
+}
+
+// SSHInstrumentingMiddleware returns an SSH service middleware that
+// instruments the number of requests of the service.
+func SSHInstrumentingMiddleware(ints metrics.Counter) SSHMiddleware {
+	return func(next SSHService) SSHService {
+		return sshInstrumentingMiddleware{
+			ints: ints,
+			next: next,
+		}
+	}
+}
+
+type sshInstrumentingMiddleware struct {
+	ints metrics.Counter
+	next SSHService
+}
+
+func (mw sshInstrumentingMiddleware) ConfigureCA(ctx context.Context, opts SSHCAOptions) (SSHCA, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ConfigureCA(ctx, opts)
+}
+
+func (mw sshInstrumentingMiddleware) ReadCA(ctx context.Context) (SSHCA, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ReadCA(ctx)
+}
+
+func (mw sshInstrumentingMiddleware) WriteRole(ctx context.Context, name string, role SSHRole) error {
+	defer mw.ints.Add(1)
+	return mw.next.WriteRole(ctx, name, role)
+}
+
+func (mw sshInstrumentingMiddleware) ReadRole(ctx context.Context, name string) (SSHRole, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ReadRole(ctx, name)
+}
+
+func (mw sshInstrumentingMiddleware) DeleteRole(ctx context.Context, name string) error {
+	defer mw.ints.Add(1)
+	return mw.next.DeleteRole(ctx, name)
+}
+
+func (mw sshInstrumentingMiddleware) ListRoles(ctx context.Context) ([]string, error) {
+	defer mw.ints.Add(1)
+	return mw.next.ListRoles(ctx)
+}
+
+func (mw sshInstrumentingMiddleware) Sign(ctx context.Context, role string, req SSHSignRequest) (SSHCertificate, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Sign(ctx, role, req)
+}
+
+// WrappingMiddleware represents a response wrapping service middleware.
+type WrappingMiddleware func(WrappingService) WrappingService
+
+// WrappingLoggingMiddleware takes a logger as a dependency and returns a
+// WrappingMiddleware. Wrapped data and tokens are never logged.
+func WrappingLoggingMiddleware(logger log.Logger) WrappingMiddleware {
+	return func(next WrappingService) WrappingService {
+		return wrappingLoggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+type wrappingLoggingMiddleware struct {
+	logger log.Logger
+	next   WrappingService
+}
+
+func (mw wrappingLoggingMiddleware) Wrap(ctx context.Context, path string, data json.RawMessage, ttl string) (info WrapInfo, err error) {
+	defer func() {
+		mw.logger.Log("method", "Wrap", "subject", subject(ctx), "path", path, "ttl", info.TTL, "err", err)
+	}()
+	return mw.next.Wrap(ctx, path, data, ttl)
+}
+
+func (mw wrappingLoggingMiddleware) Unwrap(ctx context.Context, token string) (data json.RawMessage, err error) {
+	defer func() {
+		mw.logger.Log("method", "Unwrap", "err", err)
+	}()
+	return mw.next.Unwrap(ctx, token)
+}
+
+func (mw wrappingLoggingMiddleware) Lookup(ctx context.Context, token string) (info WrapInfo, err error) {
+	defer func() {
+		mw.logger.Log("method", "Lookup", "path", info.CreationPath, "err", err)
+	}()
+	return mw.next.Lookup(ctx, token)
+}
+
+// WrappingInstrumentingMiddleware returns a response wrapping service
+// middleware that instruments the number of requests of the service.
+func WrappingInstrumentingMiddleware(ints metrics.Counter) WrappingMiddleware {
+	return func(next WrappingService) WrappingService {
+		return wrappingInstrumentingMiddleware{
+			ints: ints,
+			next: next,
+		}
+	}
+}
+
+type wrappingInstrumentingMiddleware struct {
+	ints metrics.Counter
+	next WrappingService
+}
+
+func (mw wrappingInstrumentingMiddleware) Wrap(ctx context.Context, path string, data json.RawMessage, ttl string) (WrapInfo, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Wrap(ctx, path, data, ttl)
+}
+
+func (mw wrappingInstrumentingMiddleware) Unwrap(ctx context.Context, token string) (json.RawMessage, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Unwrap(ctx, token)
+}
+
+func (mw wrappingInstrumentingMiddleware) Lookup(ctx context.Context, token string) (WrapInfo, error) {
+	defer mw.ints.Add(1)
+	return mw.next.Lookup(ctx, token)
+}
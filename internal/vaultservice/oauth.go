@@ -0,0 +1,350 @@
+package vaultservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/williamlsh/vault/internal/oauth"
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/store"
+)
+
+const (
+	oauthClientPrefix = "auth/oauth/client/"
+
+	// GrantClientCredentials is the RFC 6749 client credentials grant type.
+	GrantClientCredentials = "client_credentials"
+	// defaultAccessTokenTTL applies to clients without a token TTL.
+	defaultAccessTokenTTL = time.Hour
+)
+
+// The RFC 6749 error codes, returned by the OAuth2 token, introspection and
+// revocation operations.
+var (
+	ErrInvalidRequest       = errors.New("invalid_request")
+	ErrInvalidClient        = errors.New("invalid_client")
+	ErrInvalidScope         = errors.New("invalid_scope")
+	ErrUnauthorizedClient   = errors.New("unauthorized_client")
+	ErrUnsupportedGrantType = errors.New("unsupported_grant_type")
+)
+
+// ErrClientNotFound is returned when an OAuth2 client does not exist.
+var ErrClientNotFound = errors.New("client not found")
+
+// OAuthService describes the OAuth2 authorization server of vaultd, issuing
+// access tokens to registered clients with the client credentials grant.
+type OAuthService interface {
+	// WriteClient registers or updates a client. A client secret is
+	// generated and returned when the client is registered.
+	WriteClient(ctx context.Context, id string, client OAuthClient) (secret string, err error)
+	// ReadClient returns a client.
+	ReadClient(ctx context.Context, id string) (OAuthClient, error)
+	// DeleteClient unregisters a client. Its access tokens stay valid until
+	// they expire or are revoked.
+	DeleteClient(ctx context.Context, id string) error
+	// ListClients returns the client IDs.
+	ListClients(ctx context.Context) ([]string, error)
+	// Token issues an access token as specified by RFC 6749 section 4.4.
+	Token(ctx context.Context, req AccessTokenRequest) (AccessToken, error)
+	// Introspect describes an access token as specified by RFC 7662. Any
+	// registered client may introspect tokens.
+	Introspect(ctx context.Context, creds ClientCredentials, accessToken string) (Introspection, error)
+	// Revoke revokes an access token of the client as specified by RFC 7009.
+	// Revoking an invalid token is not an error.
+	Revoke(ctx context.Context, creds ClientCredentials, accessToken string) error
+}
+
+// OAuthClient is a registered OAuth2 client.
+type OAuthClient struct {
+	// Scopes are the scopes the client may request, all of them being
+	// granted when none is requested.
+	Scopes []string `json:"scopes,omitempty"`
+	// TokenPolicies are granted to the access tokens, besides the default
+	// policy.
+	TokenPolicies []string `json:"token_policies,omitempty"`
+	// TokenTTL is a duration such as "15m", one hour if empty.
+	TokenTTL string `json:"token_ttl,omitempty"`
+}
+
+// ClientCredentials authenticate a client.
+type ClientCredentials struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// AccessTokenRequest is an RFC 6749 access token request.
+type AccessTokenRequest struct {
+	ClientCredentials
+	GrantType string `json:"grant_type"`
+	// Scope is the space separated list of the requested scopes.
+	Scope string `json:"scope,omitempty"`
+}
+
+// AccessToken is an RFC 6749 access token response.
+type AccessToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is the lifetime of the token in seconds.
+	ExpiresIn int64  `json:"expires_in"`
+	Scope     string `json:"scope,omitempty"`
+}
+
+// Introspection is an RFC 7662 introspection response. Only Active is set
+// for invalid tokens.
+type Introspection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	ID        string `json:"jti,omitempty"`
+}
+
+// oauthClientEntry is a stored client. Only the bcrypt hash of its secret is
+// kept.
+type oauthClientEntry struct {
+	OAuthClient
+	SecretHash string `json:"secret_hash"`
+}
+
+type oauthService struct {
+	storage store.Storage
+	hasher  Service
+	issuer  *oauth.Issuer
+}
+
+// NewOAuthService makes a new OAuth2 authorization server persisting clients
+// in the storage. Client secrets are hashed with bcrypt, their hashes kept
+// with the clients. Access tokens are signed by the issuer.
+func NewOAuthService(logger log.Logger, ints metrics.Counter, s store.Storage, issuer *oauth.Issuer) OAuthService {
+	var svc OAuthService
+	{
+		svc = &oauthService{storage: s, hasher: bcryptHasher{}, issuer: issuer}
+		svc = OAuthLoggingMiddleware(logger)(svc)
+		svc = OAuthInstrumentingMiddleware(ints)(svc)
+	}
+	return svc
+}
+
+func (s *oauthService) WriteClient(ctx context.Context, id string, client OAuthClient) (string, error) {
+	if err := validateClientID(id); err != nil {
+		return "", err
+	}
+	for _, scope := range client.Scopes {
+		if scope == "" || strings.ContainsAny(scope, " \"\\") {
+			return "", fmt.Errorf("%w: invalid scope %q", ErrInvalidArgument, scope)
+		}
+	}
+	for _, name := range client.TokenPolicies {
+		if err := policy.ValidateName(name); err != nil {
+			return "", fmt.Errorf("%w: invalid token_policies: %v", ErrInvalidArgument, err)
+		}
+	}
+	if _, err := parseTTL(client.TokenTTL); err != nil {
+		return "", fmt.Errorf("%w: token_ttl must be a non-negative duration", ErrInvalidArgument)
+	}
+
+	// The secret is hashed up front, bcrypt being too slow to run under the
+	// storage lock.
+	secret := randomHex(32)
+	hash, err := s.hasher.Hash(ctx, secret)
+	if err != nil {
+		return "", err
+	}
+	created := false
+	err = s.storage.Update(ctx, oauthClientPrefix+id, func(raw []byte) ([]byte, error) {
+		entry := oauthClientEntry{OAuthClient: client, SecretHash: hash}
+		if raw != nil {
+			var old oauthClientEntry
+			if err := json.Unmarshal(raw, &old); err != nil {
+				return nil, err
+			}
+			entry.SecretHash = old.SecretHash
+		}
+		created = raw == nil
+		return json.Marshal(entry)
+	})
+	if err != nil || !created {
+		return "", err
+	}
+	return secret, nil
+}
+
+func (s *oauthService) ReadClient(ctx context.Context, id string) (OAuthClient, error) {
+	if err := validateClientID(id); err != nil {
+		return OAuthClient{}, err
+	}
+	entry, err := s.client(ctx, id)
+	if err != nil {
+		return OAuthClient{}, err
+	}
+	return entry.OAuthClient, nil
+}
+
+func (s *oauthService) DeleteClient(ctx context.Context, id string) error {
+	if err := validateClientID(id); err != nil {
+		return err
+	}
+	if _, err := s.client(ctx, id); err != nil {
+		return err
+	}
+	return s.storage.Delete(ctx, oauthClientPrefix+id)
+}
+
+func (s *oauthService) ListClients(ctx context.Context) ([]string, error) {
+	ids, err := s.storage.List(ctx, oauthClientPrefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (s *oauthService) Token(ctx context.Context, req AccessTokenRequest) (AccessToken, error) {
+	if req.GrantType == "" {
+		return AccessToken{}, fmt.Errorf("%w: missing grant_type", ErrInvalidRequest)
+	}
+	if req.GrantType != GrantClientCredentials {
+		return AccessToken{}, ErrUnsupportedGrantType
+	}
+	client, err := s.authenticate(ctx, req.ClientCredentials)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	scopes := strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	for _, scope := range scopes {
+		if !contains(client.Scopes, scope) {
+			return AccessToken{}, fmt.Errorf("%w: scope %q is not allowed", ErrInvalidScope, scope)
+		}
+	}
+	ttl, _ := parseTTL(client.TokenTTL)
+	if ttl == 0 {
+		ttl = defaultAccessTokenTTL
+	}
+
+	raw, claims, err := s.issuer.Issue(ctx, oauth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: req.ClientID},
+		ClientID:         req.ClientID,
+		Scope:            strings.Join(scopes, " "),
+		Policies:         append([]string{policy.DefaultPolicy}, client.TokenPolicies...),
+	}, ttl)
+	if err != nil {
+		return AccessToken{}, err
+	}
+	return AccessToken{
+		AccessToken: raw,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(claims.ExpiresAt.Time).Round(time.Second) / time.Second),
+		Scope:       claims.Scope,
+	}, nil
+}
+
+func (s *oauthService) Introspect(ctx context.Context, creds ClientCredentials, accessToken string) (Introspection, error) {
+	if _, err := s.authenticate(ctx, creds); err != nil {
+		return Introspection{}, err
+	}
+	if accessToken == "" {
+		return Introspection{}, fmt.Errorf("%w: missing token", ErrInvalidRequest)
+	}
+	claims, err := s.issuer.Verify(ctx, accessToken)
+	if err == oauth.ErrInvalidToken {
+		return Introspection{Active: false}, nil
+	}
+	if err != nil {
+		return Introspection{}, err
+	}
+	return Introspection{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		TokenType: "Bearer",
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		IssuedAt:  claims.IssuedAt.Unix(),
+		NotBefore: claims.NotBefore.Unix(),
+		Subject:   claims.Subject,
+		Issuer:    claims.Issuer,
+		ID:        claims.ID,
+	}, nil
+}
+
+func (s *oauthService) Revoke(ctx context.Context, creds ClientCredentials, accessToken string) error {
+	if _, err := s.authenticate(ctx, creds); err != nil {
+		return err
+	}
+	if accessToken == "" {
+		return fmt.Errorf("%w: missing token", ErrInvalidRequest)
+	}
+	claims, err := s.issuer.Verify(ctx, accessToken)
+	if err == oauth.ErrInvalidToken {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if claims.ClientID != creds.ClientID {
+		return fmt.Errorf("%w: token was issued to another client", ErrUnauthorizedClient)
+	}
+	return s.issuer.Revoke(ctx, claims)
+}
+
+// authenticate returns the client of the credentials.
+func (s *oauthService) authenticate(ctx context.Context, creds ClientCredentials) (*oauthClientEntry, error) {
+	if validateClientID(creds.ClientID) != nil {
+		return nil, ErrInvalidClient
+	}
+	entry, err := s.client(ctx, creds.ClientID)
+	if err == ErrClientNotFound {
+		s.hasher.Validate(ctx, creds.ClientSecret, timingHash)
+		return nil, ErrInvalidClient
+	}
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.hasher.Validate(ctx, creds.ClientSecret, entry.SecretHash)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidClient
+	}
+	return entry, nil
+}
+
+func (s *oauthService) client(ctx context.Context, id string) (*oauthClientEntry, error) {
+	raw, err := s.storage.Get(ctx, oauthClientPrefix+id)
+	if err == store.ErrNotFound {
+		return nil, ErrClientNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry oauthClientEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func validateClientID(id string) error {
+	if id == "" || strings.ContainsAny(id, "/:") {
+		return fmt.Errorf("%w: invalid client_id", ErrInvalidArgument)
+	}
+	return nil
+}
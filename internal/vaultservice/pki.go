@@ -0,0 +1,802 @@
+package vaultservice
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+
+	"github.com/williamlsh/vault/internal/pki"
+	"github.com/williamlsh/vault/internal/store"
+)
+
+const (
+	pkiCAKey        = "pki/ca"
+	pkiPendingCAKey = "pki/ca-pending"
+	pkiCRLKey       = "pki/crl"
+	pkiRolePrefix   = "pki/roles/"
+	pkiCertPrefix   = "pki/certs/"
+)
+
+// pkiCRLLifetime is how long a CRL is valid. CRLs are rebuilt on every
+// revocation, and when half of their lifetime has passed.
+const pkiCRLLifetime = 72 * time.Hour
+
+// PKIKeyTypeAny lets a role sign CSRs of any key type.
+const PKIKeyTypeAny = "any"
+
+// PKIService describes the PKI secrets engine, an internal certificate
+// authority issuing short-lived certificates on behalf of roles.
+type PKIService interface {
+	// GenerateCA generates the key of the CA. A root CA is self-signed; an
+	// intermediate CA is only a CSR until its certificate, signed by
+	// another CA, is imported.
+	GenerateCA(ctx context.Context, opts PKIGenerateOptions) (PKICA, error)
+	// ImportCA replaces the CA with the PEM bundle of its certificate, the
+	// certificates of its issuers and its private key. The key may be
+	// omitted for the certificate of the intermediate CA last generated.
+	ImportCA(ctx context.Context, bundle string) (PKICA, error)
+	// ReadCA returns the certificate of the CA.
+	ReadCA(ctx context.Context) (PKICA, error)
+	// WriteRole creates or replaces a role.
+	WriteRole(ctx context.Context, name string, role PKIRole) error
+	// ReadRole returns a role.
+	ReadRole(ctx context.Context, name string) (PKIRole, error)
+	// DeleteRole removes a role.
+	DeleteRole(ctx context.Context, name string) error
+	// ListRoles returns the names of the roles.
+	ListRoles(ctx context.Context) ([]string, error)
+	// Issue generates a key and a certificate for it, as allowed by the role.
+	Issue(ctx context.Context, role string, req PKIIssueRequest) (PKICertificate, error)
+	// Sign issues a certificate for the key and names of the CSR, as allowed
+	// by the role.
+	Sign(ctx context.Context, role string, req PKISignRequest) (PKICertificate, error)
+	// Revoke revokes the certificate with the serial number, adding it to
+	// the CRL.
+	Revoke(ctx context.Context, serial string) (PKICertificate, error)
+	// ReadCertificate returns the certificate issued with the serial number.
+	ReadCertificate(ctx context.Context, serial string) (PKICertificate, error)
+	// ListCertificates returns the serial numbers of the certificates
+	// issued.
+	ListCertificates(ctx context.Context) ([]string, error)
+	// ReadCRL returns the PEM encoded certificate revocation list of the CA.
+	ReadCRL(ctx context.Context) (string, error)
+}
+
+// PKIGenerateOptions describes the CA to generate.
+type PKIGenerateOptions struct {
+	Intermediate bool   `json:"-"`
+	CommonName   string `json:"common_name"`
+	// TTL is the lifetime of a root CA, 10 years if empty.
+	TTL     string `json:"ttl,omitempty"`
+	KeyType string `json:"key_type,omitempty"`
+	KeyBits int    `json:"key_bits,omitempty"`
+}
+
+// PKICA is the certificate authority of the engine. Certificates are PEM
+// encoded.
+type PKICA struct {
+	Certificate string `json:"certificate,omitempty"`
+	// CAChain are the certificates of the issuers of an intermediate CA.
+	CAChain      []string  `json:"ca_chain,omitempty"`
+	SerialNumber string    `json:"serial_number,omitempty"`
+	Expiration   time.Time `json:"expiration,omitempty"`
+	// CSR is the certificate signing request of a generated intermediate
+	// CA, whose certificate is then imported.
+	CSR string `json:"csr,omitempty"`
+}
+
+// PKIRole constrains the certificates issued on its behalf.
+type PKIRole struct {
+	// AllowedDomains are the domains of the DNS names and common names.
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+	// AllowBareDomains allows the allowed domains themselves.
+	AllowBareDomains bool `json:"allow_bare_domains,omitempty"`
+	// AllowSubdomains allows the subdomains of the allowed domains,
+	// including wildcards.
+	AllowSubdomains bool `json:"allow_subdomains,omitempty"`
+	AllowLocalhost  bool `json:"allow_localhost,omitempty"`
+	// AllowAnyName allows any common name and DNS name.
+	AllowAnyName bool `json:"allow_any_name,omitempty"`
+	AllowIPSANs  bool `json:"allow_ip_sans,omitempty"`
+	// AllowedURISANs are the URI SANs allowed, such as SPIFFE IDs; a
+	// trailing * matches any suffix.
+	AllowedURISANs []string `json:"allowed_uri_sans,omitempty"`
+	// TTL is the default lifetime of the certificates, 24 hours if empty,
+	// and MaxTTL caps the requested lifetimes.
+	TTL    string `json:"ttl,omitempty"`
+	MaxTTL string `json:"max_ttl,omitempty"`
+	// KeyType is the type of the keys, rsa, ec (the default) or ed25519, or
+	// any for CSRs; KeyBits is their size, the default of the type if zero.
+	KeyType string `json:"key_type,omitempty"`
+	KeyBits int    `json:"key_bits,omitempty"`
+	// ServerFlag and ClientFlag allow the certificates for TLS server and
+	// client authentication.
+	ServerFlag bool `json:"server_flag,omitempty"`
+	ClientFlag bool `json:"client_flag,omitempty"`
+}
+
+// PKIIssueRequest names the certificate to issue.
+type PKIIssueRequest struct {
+	CommonName string   `json:"common_name"`
+	AltNames   []string `json:"alt_names,omitempty"`
+	IPSANs     []string `json:"ip_sans,omitempty"`
+	URISANs    []string `json:"uri_sans,omitempty"`
+	TTL        string   `json:"ttl,omitempty"`
+}
+
+// PKISignRequest is a CSR to sign.
+type PKISignRequest struct {
+	CSR string `json:"csr"`
+	TTL string `json:"ttl,omitempty"`
+}
+
+// PKICertificate is a certificate issued by the engine. The private key is
+// only returned by Issue, and never stored.
+type PKICertificate struct {
+	SerialNumber   string    `json:"serial_number"`
+	Certificate    string    `json:"certificate"`
+	PrivateKey     string    `json:"private_key,omitempty"`
+	IssuingCA      string    `json:"issuing_ca,omitempty"`
+	CAChain        []string  `json:"ca_chain,omitempty"`
+	Expiration     time.Time `json:"expiration"`
+	RevocationTime time.Time `json:"revocation_time,omitempty"`
+}
+
+// pkiCAEntry is the stored CA, or the pending key of an intermediate CA.
+type pkiCAEntry struct {
+	Certificate string   `json:"certificate,omitempty"`
+	Chain       []string `json:"chain,omitempty"`
+	Key         string   `json:"key"`
+}
+
+// pkiCertEntry is a stored issued certificate.
+type pkiCertEntry struct {
+	Certificate    string    `json:"certificate"`
+	RevocationTime time.Time `json:"revocation_time,omitempty"`
+}
+
+// pkiCRLEntry is the last CRL built.
+type pkiCRLEntry struct {
+	Number     int64     `json:"number"`
+	CRL        string    `json:"crl"`
+	ThisUpdate time.Time `json:"this_update"`
+}
+
+type pkiService struct {
+	storage store.Storage
+}
+
+// NewPKIService makes a new PKI secrets engine keeping its CA, roles and the
+// certificates it issued in the storage, encrypted like every secret.
+func NewPKIService(logger log.Logger, ints metrics.Counter, s store.Storage) PKIService {
+	var svc PKIService
+	{
+		svc = &pkiService{storage: s}
+		svc = PKILoggingMiddleware(logger)(svc)
+		svc = PKIInstrumentingMiddleware(ints)(svc)
+	}
+	return svc
+}
+
+func (s *pkiService) GenerateCA(ctx context.Context, opts PKIGenerateOptions) (PKICA, error) {
+	if opts.CommonName == "" {
+		return PKICA{}, fmt.Errorf("%w: common_name is required", ErrInvalidArgument)
+	}
+	if opts.KeyType == "" {
+		opts.KeyType = pki.KeyTypeEC
+	}
+	ttl, err := parseTTL(opts.TTL)
+	if err != nil {
+		return PKICA{}, fmt.Errorf("%w: invalid ttl: %v", ErrInvalidArgument, err)
+	}
+	if ttl == 0 {
+		ttl = 10 * 365 * 24 * time.Hour
+	}
+	key, err := pki.GenerateKey(opts.KeyType, opts.KeyBits)
+	if err != nil {
+		return PKICA{}, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	keyPEM, err := pki.EncodeKey(key)
+	if err != nil {
+		return PKICA{}, err
+	}
+
+	if opts.Intermediate {
+		der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: opts.CommonName}}, key)
+		if err != nil {
+			return PKICA{}, err
+		}
+		if err := s.put(ctx, pkiPendingCAKey, pkiCAEntry{Key: string(keyPEM)}); err != nil {
+			return PKICA{}, err
+		}
+		return PKICA{CSR: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))}, nil
+	}
+
+	cert, err := pki.NewCA(pki.Template{CommonName: opts.CommonName, TTL: ttl}, key)
+	if err != nil {
+		return PKICA{}, err
+	}
+	entry := pkiCAEntry{Certificate: string(pki.EncodeCertificate(cert)), Key: string(keyPEM)}
+	if err := s.setCA(ctx, entry); err != nil {
+		return PKICA{}, err
+	}
+	return entry.ca(cert), nil
+}
+
+func (s *pkiService) ImportCA(ctx context.Context, bundle string) (PKICA, error) {
+	var (
+		certs []*x509.Certificate
+		key   crypto.Signer
+	)
+	for rest := []byte(bundle); ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return PKICA{}, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+			}
+			certs = append(certs, cert)
+			continue
+		}
+		k, err := pki.ParseKey(pem.EncodeToMemory(block))
+		if err != nil {
+			return PKICA{}, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+		}
+		key = k
+	}
+	if len(certs) == 0 {
+		return PKICA{}, fmt.Errorf("%w: no certificate in the bundle", ErrInvalidArgument)
+	}
+	cert := certs[0]
+	if !cert.IsCA || cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return PKICA{}, fmt.Errorf("%w: not a CA certificate", ErrInvalidArgument)
+	}
+	if key == nil {
+		var pending pkiCAEntry
+		if err := s.get(ctx, pkiPendingCAKey, &pending); err == store.ErrNotFound {
+			return PKICA{}, fmt.Errorf("%w: no private key in the bundle", ErrInvalidArgument)
+		} else if err != nil {
+			return PKICA{}, err
+		}
+		k, err := pki.ParseKey([]byte(pending.Key))
+		if err != nil {
+			return PKICA{}, err
+		}
+		key = k
+	}
+	if !publicKeysEqual(cert.PublicKey, key.Public()) {
+		return PKICA{}, fmt.Errorf("%w: the private key does not match the certificate", ErrInvalidArgument)
+	}
+	keyPEM, err := pki.EncodeKey(key)
+	if err != nil {
+		return PKICA{}, err
+	}
+	entry := pkiCAEntry{Certificate: string(pki.EncodeCertificate(cert)), Key: string(keyPEM)}
+	for _, c := range certs[1:] {
+		entry.Chain = append(entry.Chain, string(pki.EncodeCertificate(c)))
+	}
+	if err := s.setCA(ctx, entry); err != nil {
+		return PKICA{}, err
+	}
+	if err := s.storage.Delete(ctx, pkiPendingCAKey); err != nil {
+		return PKICA{}, err
+	}
+	return entry.ca(cert), nil
+}
+
+func (s *pkiService) ReadCA(ctx context.Context) (PKICA, error) {
+	entry, cert, _, err := s.ca(ctx)
+	if err != nil {
+		return PKICA{}, err
+	}
+	return entry.ca(cert), nil
+}
+
+func (s *pkiService) WriteRole(ctx context.Context, name string, role PKIRole) error {
+	if err := validateRoleName(name); err != nil {
+		return err
+	}
+	if err := role.validate(); err != nil {
+		return err
+	}
+	return s.put(ctx, pkiRolePrefix+name, role)
+}
+
+func (s *pkiService) ReadRole(ctx context.Context, name string) (PKIRole, error) {
+	if err := validateRoleName(name); err != nil {
+		return PKIRole{}, err
+	}
+	return s.role(ctx, name)
+}
+
+func (s *pkiService) DeleteRole(ctx context.Context, name string) error {
+	if err := validateRoleName(name); err != nil {
+		return err
+	}
+	return s.storage.Delete(ctx, pkiRolePrefix+name)
+}
+
+func (s *pkiService) ListRoles(ctx context.Context) ([]string, error) {
+	names, err := s.storage.List(ctx, pkiRolePrefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *pkiService) Issue(ctx context.Context, name string, req PKIIssueRequest) (PKICertificate, error) {
+	role, err := s.role(ctx, name)
+	if err != nil {
+		return PKICertificate{}, err
+	}
+	t, err := role.template(req.CommonName, req.AltNames, req.IPSANs, req.URISANs, req.TTL)
+	if err != nil {
+		return PKICertificate{}, err
+	}
+	keyType := role.keyType()
+	if keyType == PKIKeyTypeAny {
+		keyType = pki.KeyTypeEC
+	}
+	key, err := pki.GenerateKey(keyType, role.KeyBits)
+	if err != nil {
+		return PKICertificate{}, err
+	}
+	issued, err := s.issue(ctx, t, key.Public())
+	if err != nil {
+		return PKICertificate{}, err
+	}
+	keyPEM, err := pki.EncodeKey(key)
+	if err != nil {
+		return PKICertificate{}, err
+	}
+	issued.PrivateKey = string(keyPEM)
+	return issued, nil
+}
+
+func (s *pkiService) Sign(ctx context.Context, name string, req PKISignRequest) (PKICertificate, error) {
+	role, err := s.role(ctx, name)
+	if err != nil {
+		return PKICertificate{}, err
+	}
+	block, _ := pem.Decode([]byte(req.CSR))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return PKICertificate{}, fmt.Errorf("%w: no PEM certificate request", ErrInvalidArgument)
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return PKICertificate{}, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return PKICertificate{}, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	if err := role.checkKey(csr.PublicKey); err != nil {
+		return PKICertificate{}, err
+	}
+	ips := make([]string, len(csr.IPAddresses))
+	for i, ip := range csr.IPAddresses {
+		ips[i] = ip.String()
+	}
+	uris := make([]string, len(csr.URIs))
+	for i, u := range csr.URIs {
+		uris[i] = u.String()
+	}
+	t, err := role.template(csr.Subject.CommonName, csr.DNSNames, ips, uris, req.TTL)
+	if err != nil {
+		return PKICertificate{}, err
+	}
+	return s.issue(ctx, t, csr.PublicKey)
+}
+
+func (s *pkiService) Revoke(ctx context.Context, serial string) (PKICertificate, error) {
+	var entry pkiCertEntry
+	err := s.storage.Update(ctx, pkiCertPrefix+serial, func(raw []byte) ([]byte, error) {
+		if raw == nil {
+			return nil, fmt.Errorf("%w: certificate %s", ErrNotFound, serial)
+		}
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return nil, err
+		}
+		if entry.RevocationTime.IsZero() {
+			entry.RevocationTime = time.Now().UTC()
+		}
+		return json.Marshal(entry)
+	})
+	if err != nil {
+		return PKICertificate{}, err
+	}
+	if _, err := s.buildCRL(ctx); err != nil {
+		return PKICertificate{}, err
+	}
+	return entry.certificate(serial)
+}
+
+func (s *pkiService) ReadCertificate(ctx context.Context, serial string) (PKICertificate, error) {
+	var entry pkiCertEntry
+	if err := s.get(ctx, pkiCertPrefix+serial, &entry); err == store.ErrNotFound {
+		return PKICertificate{}, fmt.Errorf("%w: certificate %s", ErrNotFound, serial)
+	} else if err != nil {
+		return PKICertificate{}, err
+	}
+	return entry.certificate(serial)
+}
+
+func (s *pkiService) ListCertificates(ctx context.Context) ([]string, error) {
+	serials, err := s.storage.List(ctx, pkiCertPrefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(serials)
+	return serials, nil
+}
+
+func (s *pkiService) ReadCRL(ctx context.Context) (string, error) {
+	var entry pkiCRLEntry
+	err := s.get(ctx, pkiCRLKey, &entry)
+	if err == nil && time.Since(entry.ThisUpdate) < pkiCRLLifetime/2 {
+		return entry.CRL, nil
+	}
+	if err != nil && err != store.ErrNotFound {
+		return "", err
+	}
+	return s.buildCRL(ctx)
+}
+
+// issue signs the certificate of the template for the public key with the
+// CA, and records it.
+func (s *pkiService) issue(ctx context.Context, t pki.Template, pub crypto.PublicKey) (PKICertificate, error) {
+	ca, caCert, caKey, err := s.ca(ctx)
+	if err != nil {
+		return PKICertificate{}, err
+	}
+	cert, err := pki.Issue(t, pub, caCert, caKey)
+	if err != nil {
+		return PKICertificate{}, err
+	}
+	serial := formatSerial(cert.SerialNumber)
+	certPEM := string(pki.EncodeCertificate(cert))
+	if err := s.put(ctx, pkiCertPrefix+serial, pkiCertEntry{Certificate: certPEM}); err != nil {
+		return PKICertificate{}, err
+	}
+	return PKICertificate{
+		SerialNumber: serial,
+		Certificate:  certPEM,
+		IssuingCA:    ca.Certificate,
+		CAChain:      append([]string{ca.Certificate}, ca.Chain...),
+		Expiration:   cert.NotAfter,
+	}, nil
+}
+
+// buildCRL signs a new CRL of the unexpired revoked certificates issued by
+// the current CA, and stores it.
+func (s *pkiService) buildCRL(ctx context.Context) (string, error) {
+	_, caCert, caKey, err := s.ca(ctx)
+	if err != nil {
+		return "", err
+	}
+	serials, err := s.storage.List(ctx, pkiCertPrefix)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	var revoked []pkix.RevokedCertificate
+	for _, serial := range serials {
+		var entry pkiCertEntry
+		if err := s.get(ctx, pkiCertPrefix+serial, &entry); err != nil {
+			if err == store.ErrNotFound {
+				continue
+			}
+			return "", err
+		}
+		if entry.RevocationTime.IsZero() {
+			continue
+		}
+		cert, err := pki.ParseCertificate([]byte(entry.Certificate))
+		if err != nil {
+			return "", err
+		}
+		if cert.NotAfter.Before(now) || cert.CheckSignatureFrom(caCert) != nil {
+			continue
+		}
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: cert.SerialNumber, RevocationTime: entry.RevocationTime})
+	}
+
+	var crl string
+	err = s.storage.Update(ctx, pkiCRLKey, func(raw []byte) ([]byte, error) {
+		var entry pkiCRLEntry
+		if raw != nil {
+			if err := json.Unmarshal(raw, &entry); err != nil {
+				return nil, err
+			}
+		}
+		entry.Number++
+		der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+			Number:              big.NewInt(entry.Number),
+			ThisUpdate:          now,
+			NextUpdate:          now.Add(pkiCRLLifetime),
+			RevokedCertificates: revoked,
+		}, caCert, caKey)
+		if err != nil {
+			return nil, err
+		}
+		entry.CRL = string(pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}))
+		entry.ThisUpdate = now
+		crl = entry.CRL
+		return json.Marshal(entry)
+	})
+	return crl, err
+}
+
+// ca returns the stored CA, its certificate and its key.
+func (s *pkiService) ca(ctx context.Context) (*pkiCAEntry, *x509.Certificate, crypto.Signer, error) {
+	var entry pkiCAEntry
+	if err := s.get(ctx, pkiCAKey, &entry); err == store.ErrNotFound {
+		return nil, nil, nil, fmt.Errorf("%w: no CA, generate or import one", ErrNotFound)
+	} else if err != nil {
+		return nil, nil, nil, err
+	}
+	cert, err := pki.ParseCertificate([]byte(entry.Certificate))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	key, err := pki.ParseKey([]byte(entry.Key))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return &entry, cert, key, nil
+}
+
+// setCA replaces the CA, and the CRL of the previous one.
+func (s *pkiService) setCA(ctx context.Context, entry pkiCAEntry) error {
+	if err := s.put(ctx, pkiCAKey, entry); err != nil {
+		return err
+	}
+	return s.storage.Delete(ctx, pkiCRLKey)
+}
+
+func (s *pkiService) role(ctx context.Context, name string) (PKIRole, error) {
+	var role PKIRole
+	if err := s.get(ctx, pkiRolePrefix+name, &role); err == store.ErrNotFound {
+		return PKIRole{}, ErrRoleNotFound
+	} else if err != nil {
+		return PKIRole{}, err
+	}
+	return role, nil
+}
+
+func (s *pkiService) get(ctx context.Context, key string, v interface{}) error {
+	raw, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func (s *pkiService) put(ctx context.Context, key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.storage.Put(ctx, key, raw)
+}
+
+func (e *pkiCAEntry) ca(cert *x509.Certificate) PKICA {
+	return PKICA{
+		Certificate:  e.Certificate,
+		CAChain:      e.Chain,
+		SerialNumber: formatSerial(cert.SerialNumber),
+		Expiration:   cert.NotAfter,
+	}
+}
+
+func (e *pkiCertEntry) certificate(serial string) (PKICertificate, error) {
+	cert, err := pki.ParseCertificate([]byte(e.Certificate))
+	if err != nil {
+		return PKICertificate{}, err
+	}
+	return PKICertificate{
+		SerialNumber:   serial,
+		Certificate:    e.Certificate,
+		Expiration:     cert.NotAfter,
+		RevocationTime: e.RevocationTime,
+	}, nil
+}
+
+func (r *PKIRole) validate() error {
+	switch r.keyType() {
+	case pki.KeyTypeRSA:
+		if r.KeyBits != 0 && r.KeyBits < 2048 {
+			return fmt.Errorf("%w: RSA keys of %d bits", ErrInvalidArgument, r.KeyBits)
+		}
+	case pki.KeyTypeEC:
+		switch r.KeyBits {
+		case 0, 224, 256, 384, 521:
+		default:
+			return fmt.Errorf("%w: EC keys of %d bits", ErrInvalidArgument, r.KeyBits)
+		}
+	case pki.KeyTypeEd25519, PKIKeyTypeAny:
+	default:
+		return fmt.Errorf("%w: invalid key_type %q", ErrInvalidArgument, r.KeyType)
+	}
+	for _, ttl := range []string{r.TTL, r.MaxTTL} {
+		if _, err := parseTTL(ttl); err != nil {
+			return fmt.Errorf("%w: invalid ttl %q", ErrInvalidArgument, ttl)
+		}
+	}
+	return nil
+}
+
+func (r *PKIRole) keyType() string {
+	if r.KeyType == "" {
+		return pki.KeyTypeEC
+	}
+	return r.KeyType
+}
+
+// template returns the template of a certificate with the names, refusing
+// the names and TTLs the role does not allow.
+func (r *PKIRole) template(cn string, dnsNames, ips, uris []string, ttl string) (pki.Template, error) {
+	if cn == "" && len(dnsNames) == 0 && len(ips) == 0 && len(uris) == 0 {
+		return pki.Template{}, fmt.Errorf("%w: common_name is required", ErrInvalidArgument)
+	}
+	t := pki.Template{CommonName: cn, Server: r.ServerFlag, Client: r.ClientFlag}
+	for _, name := range append([]string{cn}, dnsNames...) {
+		if name == "" {
+			continue
+		}
+		if !r.allowsName(name) {
+			return pki.Template{}, fmt.Errorf("%w: name %s not allowed by the role", ErrInvalidArgument, name)
+		}
+		t.DNSNames = appendUnique(t.DNSNames, name)
+	}
+	for _, s := range ips {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return pki.Template{}, fmt.Errorf("%w: invalid IP SAN %s", ErrInvalidArgument, s)
+		}
+		if !r.AllowIPSANs {
+			return pki.Template{}, fmt.Errorf("%w: IP SANs not allowed by the role", ErrInvalidArgument)
+		}
+		t.IPAddresses = append(t.IPAddresses, ip)
+	}
+	for _, s := range uris {
+		u, err := url.Parse(s)
+		if err != nil {
+			return pki.Template{}, fmt.Errorf("%w: invalid URI SAN %s", ErrInvalidArgument, s)
+		}
+		if !matchAny(r.AllowedURISANs, s) {
+			return pki.Template{}, fmt.Errorf("%w: URI SAN %s not allowed by the role", ErrInvalidArgument, s)
+		}
+		t.URIs = append(t.URIs, u)
+	}
+
+	d, err := parseTTL(ttl)
+	if err != nil {
+		return pki.Template{}, fmt.Errorf("%w: invalid ttl: %v", ErrInvalidArgument, err)
+	}
+	if d == 0 {
+		d, _ = parseTTL(r.TTL)
+	}
+	if d == 0 {
+		d = 24 * time.Hour
+	}
+	if max, _ := parseTTL(r.MaxTTL); max > 0 && d > max {
+		d = max
+	}
+	t.TTL = d
+	return t, nil
+}
+
+// allowsName reports whether the role allows the DNS name or common name.
+func (r *PKIRole) allowsName(name string) bool {
+	if r.AllowAnyName || (r.AllowLocalhost && name == "localhost") {
+		return true
+	}
+	return matchDomain(r.AllowedDomains, r.AllowBareDomains, r.AllowSubdomains, name)
+}
+
+// matchDomain reports whether the name is one of the domains, if bare, or
+// one of their subdomains, if sub.
+func matchDomain(domains []string, bare, sub bool, name string) bool {
+	name = strings.ToLower(name)
+	for _, domain := range domains {
+		domain = strings.ToLower(domain)
+		if bare && name == domain {
+			return true
+		}
+		if sub && strings.HasSuffix(name, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkKey refuses the public keys of CSRs not of the type and size of the
+// role.
+func (r *PKIRole) checkKey(pub crypto.PublicKey) error {
+	if r.KeyType == PKIKeyTypeAny {
+		return nil
+	}
+	var keyType string
+	var bits int
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		keyType, bits = pki.KeyTypeRSA, k.N.BitLen()
+	case *ecdsa.PublicKey:
+		keyType, bits = pki.KeyTypeEC, k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		keyType = pki.KeyTypeEd25519
+	}
+	if keyType != r.keyType() {
+		return fmt.Errorf("%w: key type %s required by the role", ErrInvalidArgument, r.keyType())
+	}
+	want := r.KeyBits
+	if want == 0 {
+		switch keyType {
+		case pki.KeyTypeRSA:
+			want = 2048
+		case pki.KeyTypeEC:
+			want = 256
+		}
+	}
+	if (keyType == pki.KeyTypeRSA && bits < want) || (keyType == pki.KeyTypeEC && bits != want) {
+		return fmt.Errorf("%w: %s key of %d bits required by the role", ErrInvalidArgument, keyType, want)
+	}
+	return nil
+}
+
+// matchAny reports whether s equals one of the patterns, a pattern ending
+// with * matching any suffix.
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if p == s || (strings.HasSuffix(p, "*") && strings.HasPrefix(s, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(names []string, name string) []string {
+	for _, n := range names {
+		if n == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
+
+// formatSerial formats a serial number as colon separated hex bytes.
+func formatSerial(n *big.Int) string {
+	b := n.Bytes()
+	parts := make([]string, len(b))
+	for i, c := range b {
+		parts[i] = fmt.Sprintf("%02x", c)
+	}
+	return strings.Join(parts, ":")
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(b)
+}
@@ -0,0 +1,82 @@
+package vaultservice
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+
+	"github.com/williamlsh/vault/internal/policy"
+)
+
+// PolicyService manages the ACL policies and the policies attached to token
+// subjects.
+type PolicyService interface {
+	// ReadPolicy returns the JSON rules of the named policy.
+	ReadPolicy(ctx context.Context, name string) (string, error)
+	// WritePolicy creates or replaces a policy from its JSON rules.
+	WritePolicy(ctx context.Context, name, rules string) error
+	DeletePolicy(ctx context.Context, name string) error
+	ListPolicies(ctx context.Context) ([]string, error)
+	// ReadSubject returns the names of the policies granted to a subject,
+	// named by its auth method and subject like "jwt/ci".
+	ReadSubject(ctx context.Context, subject string) ([]string, error)
+	// WriteSubject attaches the named policies to a subject, named like in
+	// ReadSubject, replacing the previous ones.
+	WriteSubject(ctx context.Context, subject string, policies []string) error
+}
+
+type policyService struct {
+	policies *policy.Store
+}
+
+// NewPolicyService makes a new policy service managing the policy store.
+func NewPolicyService(logger log.Logger, ints metrics.Counter, policies *policy.Store) PolicyService {
+	var svc PolicyService
+	{
+		svc = &policyService{policies: policies}
+		svc = PolicyLoggingMiddleware(logger)(svc)
+		svc = PolicyInstrumentingMiddleware(ints)(svc)
+	}
+	return svc
+}
+
+func (s *policyService) ReadPolicy(ctx context.Context, name string) (string, error) {
+	p, err := s.policies.Policy(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return string(p.Rules()), nil
+}
+
+func (s *policyService) WritePolicy(ctx context.Context, name, rules string) error {
+	p, err := policy.Parse(name, []byte(rules))
+	if err != nil {
+		return err
+	}
+	return s.policies.SetPolicy(ctx, p)
+}
+
+func (s *policyService) DeletePolicy(ctx context.Context, name string) error {
+	return s.policies.DeletePolicy(ctx, name)
+}
+
+func (s *policyService) ListPolicies(ctx context.Context) ([]string, error) {
+	return s.policies.ListPolicies(ctx)
+}
+
+func (s *policyService) ReadSubject(ctx context.Context, subject string) ([]string, error) {
+	method, name, err := policy.SplitSubject(subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.policies.SubjectPolicies(ctx, method, name)
+}
+
+func (s *policyService) WriteSubject(ctx context.Context, subject string, policies []string) error {
+	method, name, err := policy.SplitSubject(subject)
+	if err != nil {
+		return err
+	}
+	return s.policies.SetSubjectPolicies(ctx, method, name, policies)
+}
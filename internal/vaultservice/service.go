@@ -7,6 +7,7 @@ import (
 	"github.com/go-kit/kit/metrics"
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/williamlsh/vault/internal/seal"
 	"github.com/williamlsh/vault/internal/store"
 )
 
@@ -21,11 +22,12 @@ type vaultService struct {
 	store  store.Store
 }
 
-// New makes a new service.
-func New(logger log.Logger, ints metrics.Counter, s store.Store) Service {
+// New makes a new service. Requests are refused while the seal is sealed.
+func New(logger log.Logger, ints metrics.Counter, s store.Store, sl *seal.Seal) Service {
 	var svc Service
 	{
 		svc = newBasicService(logger, s)
+		svc = SealedMiddleware(sl)(svc)
 		svc = LoggingMiddleware(logger)(svc)
 		svc = InstrumentingMiddleware(ints)(svc)
 	}
@@ -40,18 +42,34 @@ func newBasicService(logger log.Logger, s store.Store) Service {
 }
 
 func (s *vaultService) Hash(ctx context.Context, password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hash, err := bcryptHasher{}.Hash(ctx, password)
 	if err != nil {
 		return "", err
 	}
-	errc := s.store.KeepSecret(hash)
+	errc := s.store.KeepSecret([]byte(hash))
 	if err := <-errc; err != nil {
 		return "", err
 	}
-	return string(hash), nil
+	return hash, nil
 }
 
 func (s *vaultService) Validate(ctx context.Context, password, hash string) (bool, error) {
+	return bcryptHasher{}.Validate(ctx, password, hash)
+}
+
+// bcryptHasher hashes and validates passwords with bcrypt only. The auth
+// methods use it for their credentials, which they store themselves.
+type bcryptHasher struct{}
+
+func (bcryptHasher) Hash(ctx context.Context, password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (bcryptHasher) Validate(ctx context.Context, password, hash string) (bool, error) {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	if err != nil {
 		return false, nil
@@ -0,0 +1,50 @@
+package vaultservice_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/discard"
+
+	"github.com/williamlsh/vault/internal/mock"
+	"github.com/williamlsh/vault/internal/seal"
+	"github.com/williamlsh/vault/internal/vaultservice"
+)
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	sl := seal.New(mock.NewSealStorage())
+	svc := vaultservice.New(log.NewNopLogger(), discard.NewCounter(), mock.NewNopStore(), sl)
+
+	if _, err := svc.Hash(ctx, "znm9832nmrfz4egwy43rn8"); err != seal.ErrSealed {
+		t.Errorf("hash while sealed: want %v, have %v", seal.ErrSealed, err)
+	}
+
+	keys, err := sl.Initialize(ctx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sl.Unseal(ctx, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := svc.Hash(ctx, "znm9832nmrfz4egwy43rn8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		password string
+		want     bool
+	}{
+		{"znm9832nmrfz4egwy43rn8", true},
+		{"wrong", false},
+	} {
+		valid, err := svc.Validate(ctx, tc.password, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if valid != tc.want {
+			t.Errorf("validate %q: want %v, have %v", tc.password, tc.want, valid)
+		}
+	}
+}
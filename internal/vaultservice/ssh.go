@@ -0,0 +1,396 @@
+package vaultservice
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/williamlsh/vault/internal/pki"
+	"github.com/williamlsh/vault/internal/store"
+)
+
+const (
+	sshCAKey      = "ssh/ca"
+	sshRolePrefix = "ssh/roles/"
+)
+
+// The types of the SSH certificates.
+const (
+	SSHCertTypeUser = "user"
+	SSHCertTypeHost = "host"
+)
+
+// sshAny allows any user, critical option or extension to a role.
+const sshAny = "*"
+
+// sshBackdate is how long before their signature certificates become valid,
+// to tolerate clock skew between vaultd and the SSH servers.
+const sshBackdate = time.Minute
+
+// SSHService describes the SSH secrets engine, a certificate authority
+// signing the SSH keys of users and hosts on behalf of roles.
+type SSHService interface {
+	// ConfigureCA replaces the key of the CA with the private key of the
+	// options, or a new key when it is empty.
+	ConfigureCA(ctx context.Context, opts SSHCAOptions) (SSHCA, error)
+	// ReadCA returns the public key of the CA.
+	ReadCA(ctx context.Context) (SSHCA, error)
+	// WriteRole creates or replaces a role.
+	WriteRole(ctx context.Context, name string, role SSHRole) error
+	// ReadRole returns a role.
+	ReadRole(ctx context.Context, name string) (SSHRole, error)
+	// DeleteRole removes a role.
+	DeleteRole(ctx context.Context, name string) error
+	// ListRoles returns the names of the roles.
+	ListRoles(ctx context.Context) ([]string, error)
+	// Sign signs the public key of the request into a certificate, as
+	// allowed by the role.
+	Sign(ctx context.Context, role string, req SSHSignRequest) (SSHCertificate, error)
+}
+
+// SSHCAOptions configures the key of the CA.
+type SSHCAOptions struct {
+	// PrivateKey is a PEM private key, in PKCS #8, PKCS #1, SEC 1 or
+	// OpenSSH form, to import. A key of KeyType and KeyBits, ed25519 by
+	// default, is generated when it is empty.
+	PrivateKey string `json:"private_key,omitempty"`
+	KeyType    string `json:"key_type,omitempty"`
+	KeyBits    int    `json:"key_bits,omitempty"`
+}
+
+// SSHCA is the certificate authority of the engine.
+type SSHCA struct {
+	// PublicKey is the public key of the CA in the authorized_keys format,
+	// as trusted by sshd TrustedUserCAKeys and ssh_known_hosts
+	// @cert-authority lines.
+	PublicKey string `json:"public_key"`
+}
+
+// SSHRole constrains the certificates signed on its behalf.
+type SSHRole struct {
+	AllowUserCertificates bool `json:"allow_user_certificates,omitempty"`
+	AllowHostCertificates bool `json:"allow_host_certificates,omitempty"`
+	// AllowedUsers are the principals of user certificates, * allowing any.
+	// DefaultUser is the principal of the requests naming none.
+	AllowedUsers []string `json:"allowed_users,omitempty"`
+	DefaultUser  string   `json:"default_user,omitempty"`
+	// AllowedDomains are the domains of the principals of host
+	// certificates, with their subdomains if AllowSubdomains.
+	AllowedDomains   []string `json:"allowed_domains,omitempty"`
+	AllowBareDomains bool     `json:"allow_bare_domains,omitempty"`
+	AllowSubdomains  bool     `json:"allow_subdomains,omitempty"`
+	// TTL is the default validity of the certificates, 1 hour if empty, and
+	// MaxTTL caps the requested validities.
+	TTL    string `json:"ttl,omitempty"`
+	MaxTTL string `json:"max_ttl,omitempty"`
+	// AllowedCriticalOptions and AllowedExtensions are the names of the
+	// critical options and extensions user certificates may request, *
+	// allowing any. The defaults are set on the requests asking for none.
+	AllowedCriticalOptions []string          `json:"allowed_critical_options,omitempty"`
+	DefaultCriticalOptions map[string]string `json:"default_critical_options,omitempty"`
+	AllowedExtensions      []string          `json:"allowed_extensions,omitempty"`
+	DefaultExtensions      map[string]string `json:"default_extensions,omitempty"`
+	// AllowedKeyTypes are the SSH key types signed, such as ssh-ed25519,
+	// any if empty.
+	AllowedKeyTypes []string `json:"allowed_key_types,omitempty"`
+}
+
+// SSHSignRequest is a public key to sign into a certificate.
+type SSHSignRequest struct {
+	// PublicKey is the key in the authorized_keys format.
+	PublicKey string `json:"public_key"`
+	// CertType is user, the default, or host.
+	CertType        string            `json:"cert_type,omitempty"`
+	ValidPrincipals []string          `json:"valid_principals,omitempty"`
+	TTL             string            `json:"ttl,omitempty"`
+	CriticalOptions map[string]string `json:"critical_options,omitempty"`
+	Extensions      map[string]string `json:"extensions,omitempty"`
+}
+
+// SSHCertificate is a signed SSH certificate.
+type SSHCertificate struct {
+	SerialNumber string `json:"serial_number"`
+	// SignedKey is the certificate in the authorized_keys format, as
+	// written to the id_<type>-cert.pub file next to the private key.
+	SignedKey  string    `json:"signed_key"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// sshCAEntry is the stored key of the CA.
+type sshCAEntry struct {
+	Key string `json:"key"`
+}
+
+type sshService struct {
+	storage store.Storage
+}
+
+// NewSSHService makes a new SSH secrets engine keeping the key of its CA and
+// its roles in the storage, encrypted like every secret.
+func NewSSHService(logger log.Logger, ints metrics.Counter, s store.Storage) SSHService {
+	var svc SSHService
+	{
+		svc = &sshService{storage: s}
+		svc = SSHLoggingMiddleware(logger)(svc)
+		svc = SSHInstrumentingMiddleware(ints)(svc)
+	}
+	return svc
+}
+
+func (s *sshService) ConfigureCA(ctx context.Context, opts SSHCAOptions) (SSHCA, error) {
+	var key crypto.Signer
+	if opts.PrivateKey != "" {
+		raw, err := ssh.ParseRawPrivateKey([]byte(opts.PrivateKey))
+		if err != nil {
+			return SSHCA{}, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+		}
+		// OpenSSH Ed25519 keys are parsed as pointers.
+		if k, ok := raw.(*ed25519.PrivateKey); ok {
+			raw = *k
+		}
+		signer, ok := raw.(crypto.Signer)
+		if !ok {
+			return SSHCA{}, fmt.Errorf("%w: unsupported private key %T", ErrInvalidArgument, raw)
+		}
+		key = signer
+	} else {
+		if opts.KeyType == "" {
+			opts.KeyType = pki.KeyTypeEd25519
+		}
+		k, err := pki.GenerateKey(opts.KeyType, opts.KeyBits)
+		if err != nil {
+			return SSHCA{}, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+		}
+		key = k
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return SSHCA{}, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	keyPEM, err := pki.EncodeKey(key)
+	if err != nil {
+		return SSHCA{}, err
+	}
+	raw, err := json.Marshal(sshCAEntry{Key: string(keyPEM)})
+	if err != nil {
+		return SSHCA{}, err
+	}
+	if err := s.storage.Put(ctx, sshCAKey, raw); err != nil {
+		return SSHCA{}, err
+	}
+	return SSHCA{PublicKey: string(ssh.MarshalAuthorizedKey(signer.PublicKey()))}, nil
+}
+
+func (s *sshService) ReadCA(ctx context.Context) (SSHCA, error) {
+	signer, err := s.signer(ctx)
+	if err != nil {
+		return SSHCA{}, err
+	}
+	return SSHCA{PublicKey: string(ssh.MarshalAuthorizedKey(signer.PublicKey()))}, nil
+}
+
+func (s *sshService) WriteRole(ctx context.Context, name string, role SSHRole) error {
+	if err := validateRoleName(name); err != nil {
+		return err
+	}
+	if !role.AllowUserCertificates && !role.AllowHostCertificates {
+		return fmt.Errorf("%w: the role allows neither user nor host certificates", ErrInvalidArgument)
+	}
+	for _, ttl := range []string{role.TTL, role.MaxTTL} {
+		if _, err := parseTTL(ttl); err != nil {
+			return fmt.Errorf("%w: invalid ttl %q", ErrInvalidArgument, ttl)
+		}
+	}
+	raw, err := json.Marshal(role)
+	if err != nil {
+		return err
+	}
+	return s.storage.Put(ctx, sshRolePrefix+name, raw)
+}
+
+func (s *sshService) ReadRole(ctx context.Context, name string) (SSHRole, error) {
+	if err := validateRoleName(name); err != nil {
+		return SSHRole{}, err
+	}
+	return s.role(ctx, name)
+}
+
+func (s *sshService) DeleteRole(ctx context.Context, name string) error {
+	if err := validateRoleName(name); err != nil {
+		return err
+	}
+	return s.storage.Delete(ctx, sshRolePrefix+name)
+}
+
+func (s *sshService) ListRoles(ctx context.Context) ([]string, error) {
+	names, err := s.storage.List(ctx, sshRolePrefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *sshService) Sign(ctx context.Context, name string, req SSHSignRequest) (SSHCertificate, error) {
+	role, err := s.role(ctx, name)
+	if err != nil {
+		return SSHCertificate{}, err
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(req.PublicKey))
+	if err != nil {
+		return SSHCertificate{}, fmt.Errorf("%w: invalid public key: %v", ErrInvalidArgument, err)
+	}
+	if _, ok := pub.(*ssh.Certificate); ok {
+		return SSHCertificate{}, fmt.Errorf("%w: the public key is a certificate", ErrInvalidArgument)
+	}
+	if len(role.AllowedKeyTypes) > 0 && !contains(role.AllowedKeyTypes, pub.Type()) {
+		return SSHCertificate{}, fmt.Errorf("%w: key type %s not allowed by the role", ErrInvalidArgument, pub.Type())
+	}
+	cert, err := role.certificate(req)
+	if err != nil {
+		return SSHCertificate{}, err
+	}
+	signer, err := s.signer(ctx)
+	if err != nil {
+		return SSHCertificate{}, err
+	}
+
+	var serial [8]byte
+	if _, err := rand.Read(serial[:]); err != nil {
+		return SSHCertificate{}, err
+	}
+	cert.Key = pub
+	cert.Serial = binary.BigEndian.Uint64(serial[:])
+	cert.KeyId = fmt.Sprintf("vault-%s-%s", name, ssh.FingerprintSHA256(pub))
+	if err := cert.SignCert(rand.Reader, signer); err != nil {
+		return SSHCertificate{}, err
+	}
+	return SSHCertificate{
+		SerialNumber: strconv.FormatUint(cert.Serial, 10),
+		SignedKey:    string(ssh.MarshalAuthorizedKey(cert)),
+		Expiration:   time.Unix(int64(cert.ValidBefore), 0).UTC(),
+	}, nil
+}
+
+// signer returns the signer of the CA.
+func (s *sshService) signer(ctx context.Context) (ssh.Signer, error) {
+	raw, err := s.storage.Get(ctx, sshCAKey)
+	if err == store.ErrNotFound {
+		return nil, fmt.Errorf("%w: no SSH CA, configure one", ErrNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+	var entry sshCAEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, err
+	}
+	key, err := pki.ParseKey([]byte(entry.Key))
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromKey(key)
+}
+
+func (s *sshService) role(ctx context.Context, name string) (SSHRole, error) {
+	raw, err := s.storage.Get(ctx, sshRolePrefix+name)
+	if err == store.ErrNotFound {
+		return SSHRole{}, ErrRoleNotFound
+	} else if err != nil {
+		return SSHRole{}, err
+	}
+	var role SSHRole
+	err = json.Unmarshal(raw, &role)
+	return role, err
+}
+
+// certificate returns the unsigned certificate of the request, refusing the
+// principals, options and TTLs the role does not allow.
+func (r *SSHRole) certificate(req SSHSignRequest) (*ssh.Certificate, error) {
+	cert := &ssh.Certificate{ValidPrincipals: req.ValidPrincipals}
+	switch req.CertType {
+	case "", SSHCertTypeUser:
+		if !r.AllowUserCertificates {
+			return nil, fmt.Errorf("%w: user certificates not allowed by the role", ErrInvalidArgument)
+		}
+		cert.CertType = ssh.UserCert
+		if len(cert.ValidPrincipals) == 0 && r.DefaultUser != "" {
+			cert.ValidPrincipals = []string{r.DefaultUser}
+		}
+		for _, p := range cert.ValidPrincipals {
+			if !contains(r.AllowedUsers, sshAny) && !contains(r.AllowedUsers, p) {
+				return nil, fmt.Errorf("%w: principal %s not allowed by the role", ErrInvalidArgument, p)
+			}
+		}
+		options, err := sshOptions("critical option", req.CriticalOptions, r.AllowedCriticalOptions, r.DefaultCriticalOptions)
+		if err != nil {
+			return nil, err
+		}
+		extensions, err := sshOptions("extension", req.Extensions, r.AllowedExtensions, r.DefaultExtensions)
+		if err != nil {
+			return nil, err
+		}
+		cert.Permissions = ssh.Permissions{CriticalOptions: options, Extensions: extensions}
+	case SSHCertTypeHost:
+		if !r.AllowHostCertificates {
+			return nil, fmt.Errorf("%w: host certificates not allowed by the role", ErrInvalidArgument)
+		}
+		if len(req.CriticalOptions) > 0 || len(req.Extensions) > 0 {
+			return nil, fmt.Errorf("%w: host certificates have no critical options nor extensions", ErrInvalidArgument)
+		}
+		cert.CertType = ssh.HostCert
+		for _, p := range cert.ValidPrincipals {
+			if !matchDomain(r.AllowedDomains, r.AllowBareDomains, r.AllowSubdomains, p) {
+				return nil, fmt.Errorf("%w: principal %s not allowed by the role", ErrInvalidArgument, p)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w: invalid cert_type %q", ErrInvalidArgument, req.CertType)
+	}
+	// A certificate without principals is valid for any of them.
+	if len(cert.ValidPrincipals) == 0 {
+		return nil, fmt.Errorf("%w: valid_principals is required", ErrInvalidArgument)
+	}
+
+	ttl, err := parseTTL(req.TTL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid ttl: %v", ErrInvalidArgument, err)
+	}
+	if ttl == 0 {
+		ttl, _ = parseTTL(r.TTL)
+	}
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+	if max, _ := parseTTL(r.MaxTTL); max > 0 && ttl > max {
+		ttl = max
+	}
+	now := time.Now()
+	cert.ValidAfter = uint64(now.Add(-sshBackdate).Unix())
+	cert.ValidBefore = uint64(now.Add(ttl).Unix())
+	return cert, nil
+}
+
+// sshOptions returns the critical options or extensions requested, or the
+// defaults when none is, refusing those not allowed.
+func sshOptions(kind string, requested map[string]string, allowed []string, defaults map[string]string) (map[string]string, error) {
+	if len(requested) == 0 {
+		return defaults, nil
+	}
+	for k := range requested {
+		if !contains(allowed, sshAny) && !contains(allowed, k) {
+			return nil, fmt.Errorf("%w: %s %s not allowed by the role", ErrInvalidArgument, kind, k)
+		}
+	}
+	return requested, nil
+}
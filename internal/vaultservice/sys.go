@@ -0,0 +1,124 @@
+package vaultservice
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/seal"
+	"github.com/williamlsh/vault/internal/token"
+)
+
+// SysService describes the system backend which initializes, seals and
+// unseals the vault.
+type SysService interface {
+	// Init initializes the vault, returning the unseal keys and the initial
+	// root token.
+	Init(ctx context.Context, shares, threshold int) (keys []string, rootToken string, err error)
+	Unseal(ctx context.Context, key string, reset bool) (seal.Status, error)
+	Seal(ctx context.Context) error
+	SealStatus(ctx context.Context) (seal.Status, error)
+	Rotate(ctx context.Context) (seal.KeyStatus, error)
+}
+
+type sysService struct {
+	seal   *seal.Seal
+	tokens *token.Store
+}
+
+// NewSysService makes a new system service managing the seal. The root token
+// is issued by the token store when the vault is initialized.
+func NewSysService(logger log.Logger, ints metrics.Counter, sl *seal.Seal, tokens *token.Store) SysService {
+	var svc SysService
+	{
+		svc = &sysService{seal: sl, tokens: tokens}
+		svc = SysLoggingMiddleware(logger)(svc)
+		svc = SysInstrumentingMiddleware(ints)(svc)
+	}
+	return svc
+}
+
+// Init initializes the vault and returns the hex encoded unseal keys. The
+// root token is stored first so that an initialized vault always has one; it
+// is revoked again if the initialization fails, and a failure to revoke it is
+// returned with the error.
+func (s *sysService) Init(ctx context.Context, shares, threshold int) ([]string, string, error) {
+	root, err := s.tokens.CreateRoot(ctx, []string{policy.RootPolicy})
+	if err != nil {
+		return nil, "", err
+	}
+	keys, err := s.seal.Initialize(ctx, shares, threshold)
+	if err != nil {
+		if rerr := s.tokens.Revoke(ctx, root); rerr != nil {
+			return nil, "", fmt.Errorf("%w (revoking the root token: %v)", err, rerr)
+		}
+		return nil, "", err
+	}
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		out[i] = hex.EncodeToString(k)
+	}
+	return out, root.ID, nil
+}
+
+// Unseal submits a hex encoded unseal key, or discards the submitted keys if
+// reset is set.
+func (s *sysService) Unseal(ctx context.Context, key string, reset bool) (seal.Status, error) {
+	if reset {
+		s.seal.ResetUnseal()
+		return s.seal.Status(ctx)
+	}
+	k, err := hex.DecodeString(key)
+	if err != nil {
+		return seal.Status{}, fmt.Errorf("%w: %v", seal.ErrInvalidKey, err)
+	}
+	return s.seal.Unseal(ctx, k)
+}
+
+func (s *sysService) Seal(ctx context.Context) error {
+	s.seal.Seal()
+	return nil
+}
+
+func (s *sysService) SealStatus(ctx context.Context) (seal.Status, error) {
+	return s.seal.Status(ctx)
+}
+
+// Rotate installs a new keyring term for encrypting data at rest.
+func (s *sysService) Rotate(ctx context.Context) (seal.KeyStatus, error) {
+	return s.seal.Rotate(ctx)
+}
+
+// SealedMiddleware returns a service middleware that refuses requests while
+// the vault is sealed.
+func SealedMiddleware(sl *seal.Seal) Middleware {
+	return func(next Service) Service {
+		return sealedMiddleware{
+			seal: sl,
+			next: next,
+		}
+	}
+}
+
+type sealedMiddleware struct {
+	seal *seal.Seal
+	next Service
+}
+
+func (mw sealedMiddleware) Hash(ctx context.Context, password string) (string, error) {
+	if mw.seal.Sealed() {
+		return "", seal.ErrSealed
+	}
+	return mw.next.Hash(ctx, password)
+}
+
+func (mw sealedMiddleware) Validate(ctx context.Context, password, hash string) (bool, error) {
+	if mw.seal.Sealed() {
+		return false, seal.ErrSealed
+	}
+	return mw.next.Validate(ctx, password, hash)
+}
@@ -0,0 +1,168 @@
+package vaultservice
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+
+	"github.com/williamlsh/vault/internal/identity"
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/token"
+)
+
+// TokenService issues and manages the tokens authenticating the requests.
+// Tokens are identified by their ID, an empty ID meaning the token of the
+// caller.
+type TokenService interface {
+	// Create issues a child of the caller token, or an orphan token.
+	Create(ctx context.Context, opts TokenOptions) (token.Token, error)
+	// Lookup returns a token without its ID.
+	Lookup(ctx context.Context, id string) (token.Token, error)
+	// Renew extends a token by increment, zero meaning the default TTL.
+	Renew(ctx context.Context, id string, increment time.Duration) (token.Token, error)
+	// Revoke revokes a token and all its children.
+	Revoke(ctx context.Context, id string) error
+	// RevokeOrphan revokes a token and turns its children into orphans.
+	RevokeOrphan(ctx context.Context, id string) error
+}
+
+// TokenOptions configures a new token.
+type TokenOptions struct {
+	// Policies default to the policies of the caller token. Unless the
+	// caller has the root policy they must be a subset of its policies.
+	Policies []string `json:"policies,omitempty"`
+	// NoDefaultPolicy leaves out the default policy.
+	NoDefaultPolicy bool `json:"no_default_policy,omitempty"`
+	// TTL is a duration such as "1h", the lease default TTL if empty.
+	TTL         string `json:"ttl,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	// NoParent creates an orphan token, which outlives the caller token.
+	NoParent bool `json:"no_parent,omitempty"`
+}
+
+type tokenService struct {
+	tokens *token.Store
+}
+
+// NewTokenService makes a new token service backed by the token store.
+func NewTokenService(logger log.Logger, ints metrics.Counter, tokens *token.Store) TokenService {
+	var svc TokenService
+	{
+		svc = &tokenService{tokens: tokens}
+		svc = TokenLoggingMiddleware(logger)(svc)
+		svc = TokenInstrumentingMiddleware(ints)(svc)
+	}
+	return svc
+}
+
+func (s *tokenService) Create(ctx context.Context, opts TokenOptions) (token.Token, error) {
+	parent, err := s.caller(ctx)
+	if err != nil {
+		return token.Token{}, err
+	}
+	var ttl time.Duration
+	if opts.TTL != "" {
+		if ttl, err = time.ParseDuration(opts.TTL); err != nil || ttl < 0 {
+			return token.Token{}, fmt.Errorf("%w: ttl must be a non-negative duration", ErrInvalidArgument)
+		}
+	}
+
+	root := contains(parent.Policies, policy.RootPolicy)
+	requested := opts.Policies
+	if len(requested) == 0 {
+		requested = parent.Policies
+	}
+	policies := make([]string, 0, len(requested)+1)
+	if !opts.NoDefaultPolicy && !contains(requested, policy.RootPolicy) {
+		policies = append(policies, policy.DefaultPolicy)
+	}
+	for _, name := range requested {
+		if name == policy.DefaultPolicy {
+			continue
+		}
+		if !root && !contains(parent.Policies, name) {
+			return token.Token{}, fmt.Errorf("%w: cannot grant policy %q", policy.ErrPermissionDenied, name)
+		}
+		policies = append(policies, name)
+	}
+
+	t, err := s.tokens.Create(ctx, parent, token.CreateOptions{
+		Policies:    policies,
+		TTL:         ttl,
+		DisplayName: opts.DisplayName,
+		Orphan:      opts.NoParent,
+	})
+	if err != nil {
+		return token.Token{}, err
+	}
+	return *t, nil
+}
+
+func (s *tokenService) Lookup(ctx context.Context, id string) (token.Token, error) {
+	t, err := s.token(ctx, id)
+	if err != nil {
+		return token.Token{}, err
+	}
+	return *t, nil
+}
+
+func (s *tokenService) Renew(ctx context.Context, id string, increment time.Duration) (token.Token, error) {
+	t, err := s.token(ctx, id)
+	if err != nil {
+		return token.Token{}, err
+	}
+	t, err = s.tokens.Renew(ctx, t, increment)
+	if err != nil {
+		return token.Token{}, err
+	}
+	return *t, nil
+}
+
+func (s *tokenService) Revoke(ctx context.Context, id string) error {
+	t, err := s.token(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.tokens.Revoke(ctx, t)
+}
+
+func (s *tokenService) RevokeOrphan(ctx context.Context, id string) error {
+	t, err := s.token(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.tokens.RevokeOrphan(ctx, t)
+}
+
+// caller returns the token the request was authenticated with.
+func (s *tokenService) caller(ctx context.Context) (*token.Token, error) {
+	id, ok := identity.FromContext(ctx)
+	if !ok || id.Token == "" {
+		return nil, token.ErrMissingToken
+	}
+	t, err := s.tokens.LookupHash(ctx, id.Token)
+	if err == token.ErrNotFound {
+		return nil, token.ErrInvalidToken
+	}
+	return t, err
+}
+
+// token returns the token with the ID, or the caller token if id is empty.
+func (s *tokenService) token(ctx context.Context, id string) (*token.Token, error) {
+	if id == "" {
+		return s.caller(ctx)
+	}
+	return s.tokens.LookupHash(ctx, token.Hash(id))
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
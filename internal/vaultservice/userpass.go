@@ -0,0 +1,200 @@
+package vaultservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+
+	"github.com/williamlsh/vault/internal/policy"
+	"github.com/williamlsh/vault/internal/store"
+	"github.com/williamlsh/vault/internal/token"
+)
+
+const userpassPrefix = "auth/userpass/users/"
+
+// timingHash is validated against when a login names an unknown user, so
+// that unknown users take as long to refuse as wrong passwords.
+const timingHash = "$2a$10$8e4JwCH9mCppJpTQ3Ax1PevFIt79her0oOg7AFy3eA4BNoeOMX1w."
+
+// ErrUserNotFound is returned when a userpass user does not exist.
+var ErrUserNotFound = errors.New("user not found")
+
+// UserpassService describes the userpass auth method, which lets users log
+// in with a username and a password.
+type UserpassService interface {
+	// WriteUser creates or updates a user. The password may be left out
+	// when updating a user, keeping its current password.
+	WriteUser(ctx context.Context, username string, user User) error
+	// ReadUser returns a user without its password.
+	ReadUser(ctx context.Context, username string) (User, error)
+	// DeleteUser removes a user.
+	DeleteUser(ctx context.Context, username string) error
+	// ListUsers returns the usernames.
+	ListUsers(ctx context.Context) ([]string, error)
+	// Login exchanges a username and a password for a token carrying the
+	// policies of the user.
+	Login(ctx context.Context, username, password string) (token.Token, error)
+}
+
+// User is a user of the userpass auth method.
+type User struct {
+	// Password is only set when writing a user.
+	Password string `json:"password,omitempty"`
+	// TokenPolicies are granted to the tokens, besides the default policy.
+	TokenPolicies []string `json:"token_policies,omitempty"`
+	// TokenTTL is a duration such as "30m", the lease default TTL if empty.
+	TokenTTL string `json:"token_ttl,omitempty"`
+}
+
+// userEntry is a stored user. Only the bcrypt hash of its password is kept.
+type userEntry struct {
+	Hash          string   `json:"hash"`
+	TokenPolicies []string `json:"token_policies,omitempty"`
+	TokenTTL      string   `json:"token_ttl,omitempty"`
+}
+
+type userpassService struct {
+	storage store.Storage
+	hasher  Service
+	tokens  *token.Store
+}
+
+// NewUserpassService makes a new userpass auth method persisting users in
+// the storage. Passwords are hashed with bcrypt, their hashes kept with the
+// users. Logins are issued orphan tokens.
+func NewUserpassService(logger log.Logger, ints metrics.Counter, s store.Storage, tokens *token.Store) UserpassService {
+	var svc UserpassService
+	{
+		svc = &userpassService{storage: s, hasher: bcryptHasher{}, tokens: tokens}
+		svc = UserpassLoggingMiddleware(logger)(svc)
+		svc = UserpassInstrumentingMiddleware(ints)(svc)
+	}
+	return svc
+}
+
+func (s *userpassService) WriteUser(ctx context.Context, username string, user User) error {
+	if err := validateUsername(username); err != nil {
+		return err
+	}
+	for _, name := range user.TokenPolicies {
+		if err := policy.ValidateName(name); err != nil {
+			return fmt.Errorf("%w: invalid token_policies: %v", ErrInvalidArgument, err)
+		}
+	}
+	if _, err := parseTTL(user.TokenTTL); err != nil {
+		return fmt.Errorf("%w: token_ttl must be a non-negative duration", ErrInvalidArgument)
+	}
+
+	var hash string
+	if user.Password != "" {
+		var err error
+		if hash, err = s.hasher.Hash(ctx, user.Password); err != nil {
+			return err
+		}
+	}
+	return s.storage.Update(ctx, userpassPrefix+username, func(raw []byte) ([]byte, error) {
+		entry := userEntry{Hash: hash, TokenPolicies: user.TokenPolicies, TokenTTL: user.TokenTTL}
+		if hash == "" {
+			if raw == nil {
+				return nil, fmt.Errorf("%w: password is required", ErrInvalidArgument)
+			}
+			var old userEntry
+			if err := json.Unmarshal(raw, &old); err != nil {
+				return nil, err
+			}
+			entry.Hash = old.Hash
+		}
+		return json.Marshal(entry)
+	})
+}
+
+func (s *userpassService) ReadUser(ctx context.Context, username string) (User, error) {
+	if err := validateUsername(username); err != nil {
+		return User{}, err
+	}
+	entry, err := s.user(ctx, username)
+	if err != nil {
+		return User{}, err
+	}
+	return User{TokenPolicies: entry.TokenPolicies, TokenTTL: entry.TokenTTL}, nil
+}
+
+func (s *userpassService) DeleteUser(ctx context.Context, username string) error {
+	if err := validateUsername(username); err != nil {
+		return err
+	}
+	if _, err := s.user(ctx, username); err != nil {
+		return err
+	}
+	return s.storage.Delete(ctx, userpassPrefix+username)
+}
+
+func (s *userpassService) ListUsers(ctx context.Context) ([]string, error) {
+	names, err := s.storage.List(ctx, userpassPrefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *userpassService) Login(ctx context.Context, username, password string) (token.Token, error) {
+	if validateUsername(username) != nil {
+		return token.Token{}, ErrInvalidCredentials
+	}
+	entry, err := s.user(ctx, username)
+	if err == ErrUserNotFound {
+		s.hasher.Validate(ctx, password, timingHash)
+		return token.Token{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return token.Token{}, err
+	}
+	ok, err := s.hasher.Validate(ctx, password, entry.Hash)
+	if err != nil {
+		return token.Token{}, err
+	}
+	if !ok {
+		return token.Token{}, ErrInvalidCredentials
+	}
+
+	ttl, _ := parseTTL(entry.TokenTTL)
+	t, err := s.tokens.Create(ctx, nil, token.CreateOptions{
+		Policies:    append([]string{policy.DefaultPolicy}, entry.TokenPolicies...),
+		TTL:         ttl,
+		DisplayName: "userpass-" + username,
+		Orphan:      true,
+	})
+	if err != nil {
+		return token.Token{}, err
+	}
+	return *t, nil
+}
+
+func (s *userpassService) user(ctx context.Context, username string) (*userEntry, error) {
+	raw, err := s.storage.Get(ctx, userpassPrefix+username)
+	if err == store.ErrNotFound {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry userEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func validateUsername(username string) error {
+	if username == "" || strings.Contains(username, "/") {
+		return fmt.Errorf("%w: invalid username", ErrInvalidArgument)
+	}
+	return nil
+}
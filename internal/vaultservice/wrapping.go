@@ -0,0 +1,188 @@
+package vaultservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+
+	"github.com/williamlsh/vault/internal/lease"
+	"github.com/williamlsh/vault/internal/store"
+	"github.com/williamlsh/vault/internal/token"
+)
+
+// wrappingPrefix is the storage key and lease path of the wrapped responses,
+// followed by the hash of their wrapping token.
+const wrappingPrefix = "sys/wrapping/"
+
+// wrappingTokenPrefix distinguishes the wrapping tokens from the tokens
+// authenticating requests.
+const wrappingTokenPrefix = "w."
+
+// DefaultWrapTTL is the TTL of the wrapping tokens requested without one.
+const DefaultWrapTTL = 5 * time.Minute
+
+// ErrInvalidWrappingToken is returned when unwrapping with a token which was
+// never issued, has expired or was already unwrapped.
+var ErrInvalidWrappingToken = errors.New("wrapping token is not valid or does not exist")
+
+// WrappingService describes the response wrapping service, keeping responses
+// behind single-use tokens so that they are handed to a workload without
+// exposing them to whoever passes the token along.
+type WrappingService interface {
+	// Wrap keeps the JSON data of the response to the request on path behind
+	// a new wrapping token valid for ttl, DefaultWrapTTL if empty.
+	Wrap(ctx context.Context, path string, data json.RawMessage, ttl string) (WrapInfo, error)
+	// Unwrap returns the data wrapped with the token, and deletes it, so that
+	// it is returned exactly once.
+	Unwrap(ctx context.Context, token string) (json.RawMessage, error)
+	// Lookup returns the wrap info of the token without unwrapping it.
+	Lookup(ctx context.Context, token string) (WrapInfo, error)
+}
+
+// WrapInfo describes the wrapping token of a wrapped response.
+type WrapInfo struct {
+	// Token is the single-use wrapping token, only returned when wrapping.
+	Token          string    `json:"token,omitempty"`
+	TTL            string    `json:"ttl"`
+	CreationTime   time.Time `json:"creation_time"`
+	ExpirationTime time.Time `json:"expiration_time"`
+	// CreationPath is the path of the request whose response is wrapped,
+	// such as kv/data/app or /pb.KV/Get.
+	CreationPath string `json:"creation_path"`
+}
+
+// wrappedEntry is a stored wrapped response.
+type wrappedEntry struct {
+	Info WrapInfo        `json:"info"`
+	Data json.RawMessage `json:"data"`
+}
+
+type wrappingService struct {
+	storage store.Storage
+	leases  *lease.Manager
+}
+
+// NewWrappingService makes a new response wrapping service keeping the
+// wrapped responses in the storage, encrypted like every secret, and deleting
+// them through leases when their token expires.
+func NewWrappingService(logger log.Logger, ints metrics.Counter, s store.Storage, leases *lease.Manager) WrappingService {
+	var svc WrappingService
+	{
+		w := &wrappingService{storage: s, leases: leases}
+		leases.Handle(wrappingPrefix, w.expire)
+		svc = w
+		svc = WrappingLoggingMiddleware(logger)(svc)
+		svc = WrappingInstrumentingMiddleware(ints)(svc)
+	}
+	return svc
+}
+
+func (s *wrappingService) Wrap(ctx context.Context, path string, data json.RawMessage, ttl string) (WrapInfo, error) {
+	d, err := parseTTL(ttl)
+	if err != nil {
+		return WrapInfo{}, fmt.Errorf("%w: invalid wrap ttl %q", ErrInvalidArgument, ttl)
+	}
+	if d == 0 {
+		d = DefaultWrapTTL
+	}
+	if !json.Valid(data) {
+		return WrapInfo{}, fmt.Errorf("%w: wrapped data is not JSON", ErrInvalidArgument)
+	}
+	now := time.Now().UTC()
+	info := WrapInfo{
+		TTL:            d.String(),
+		CreationTime:   now,
+		ExpirationTime: now.Add(d),
+		CreationPath:   path,
+	}
+	raw, err := json.Marshal(wrappedEntry{Info: info, Data: data})
+	if err != nil {
+		return WrapInfo{}, err
+	}
+	tok := wrappingTokenPrefix + randomHex(24)
+	key := wrappingPrefix + token.Hash(tok)
+	if err := s.storage.Put(ctx, key, raw); err != nil {
+		return WrapInfo{}, err
+	}
+	if _, err := s.leases.Register(ctx, key, d); err != nil {
+		s.storage.Delete(ctx, key)
+		return WrapInfo{}, err
+	}
+	info.Token = tok
+	return info, nil
+}
+
+func (s *wrappingService) Unwrap(ctx context.Context, tok string) (json.RawMessage, error) {
+	if !strings.HasPrefix(tok, wrappingTokenPrefix) {
+		return nil, ErrInvalidWrappingToken
+	}
+	var entry *wrappedEntry
+	err := s.storage.Update(ctx, wrappingPrefix+token.Hash(tok), func(raw []byte) ([]byte, error) {
+		if raw == nil {
+			return nil, ErrInvalidWrappingToken
+		}
+		entry = new(wrappedEntry)
+		if err := json.Unmarshal(raw, entry); err != nil {
+			return nil, err
+		}
+		// Deleting the entry as it is read, so that concurrent unwraps
+		// return it at most once.
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !entry.Info.ExpirationTime.After(time.Now()) {
+		return nil, ErrInvalidWrappingToken
+	}
+	return entry.Data, nil
+}
+
+func (s *wrappingService) Lookup(ctx context.Context, tok string) (WrapInfo, error) {
+	if !strings.HasPrefix(tok, wrappingTokenPrefix) {
+		return WrapInfo{}, ErrInvalidWrappingToken
+	}
+	raw, err := s.storage.Get(ctx, wrappingPrefix+token.Hash(tok))
+	if err == store.ErrNotFound {
+		return WrapInfo{}, ErrInvalidWrappingToken
+	} else if err != nil {
+		return WrapInfo{}, err
+	}
+	var entry wrappedEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return WrapInfo{}, err
+	}
+	if !entry.Info.ExpirationTime.After(time.Now()) {
+		return WrapInfo{}, ErrInvalidWrappingToken
+	}
+	return entry.Info, nil
+}
+
+// expire deletes a wrapped response once its token has expired. Leases are
+// capped by the maximum lease TTL, so the response is kept under a new lease
+// if its token is still valid.
+func (s *wrappingService) expire(ctx context.Context, l *lease.Lease) error {
+	// Lease IDs are sys/wrapping/<token hash>/<random>.
+	key := l.ID[:strings.LastIndex(l.ID, "/")]
+	raw, err := s.storage.Get(ctx, key)
+	if err == store.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var entry wrappedEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return err
+	}
+	if left := time.Until(entry.Info.ExpirationTime); left > 0 {
+		_, err := s.leases.Register(ctx, key, left)
+		return err
+	}
+	return s.storage.Delete(ctx, key)
+}
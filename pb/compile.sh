@@ -1,2 +1,4 @@
 #!/usr/bin/env sh
-protoc vault.proto --go_out=plugins=grpc:.
\ No newline at end of file
+protoc -I . -I ../third_party/googleapis vault.proto \
+	--go_out=. \
+	--go-grpc_out=. --go-grpc_opt=require_unimplemented_servers=false
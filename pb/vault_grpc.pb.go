@@ -0,0 +1,3696 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.11.4
+// source: vault.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// VaultClient is the client API for Vault service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type VaultClient interface {
+	Hash(ctx context.Context, in *HashRequest, opts ...grpc.CallOption) (*HashResponse, error)
+	Validate(ctx context.Context, in *ValidateRequest, opts ...grpc.CallOption) (*ValidateResponse, error)
+	BatchHash(ctx context.Context, opts ...grpc.CallOption) (Vault_BatchHashClient, error)
+	BatchValidate(ctx context.Context, opts ...grpc.CallOption) (Vault_BatchValidateClient, error)
+}
+
+type vaultClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVaultClient(cc grpc.ClientConnInterface) VaultClient {
+	return &vaultClient{cc}
+}
+
+func (c *vaultClient) Hash(ctx context.Context, in *HashRequest, opts ...grpc.CallOption) (*HashResponse, error) {
+	out := new(HashResponse)
+	err := c.cc.Invoke(ctx, "/pb.Vault/Hash", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vaultClient) Validate(ctx context.Context, in *ValidateRequest, opts ...grpc.CallOption) (*ValidateResponse, error) {
+	out := new(ValidateResponse)
+	err := c.cc.Invoke(ctx, "/pb.Vault/Validate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vaultClient) BatchHash(ctx context.Context, opts ...grpc.CallOption) (Vault_BatchHashClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Vault_ServiceDesc.Streams[0], "/pb.Vault/BatchHash", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &vaultBatchHashClient{stream}
+	return x, nil
+}
+
+type Vault_BatchHashClient interface {
+	Send(*BatchHashRequest) error
+	Recv() (*BatchHashResponse, error)
+	grpc.ClientStream
+}
+
+type vaultBatchHashClient struct {
+	grpc.ClientStream
+}
+
+func (x *vaultBatchHashClient) Send(m *BatchHashRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *vaultBatchHashClient) Recv() (*BatchHashResponse, error) {
+	m := new(BatchHashResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *vaultClient) BatchValidate(ctx context.Context, opts ...grpc.CallOption) (Vault_BatchValidateClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Vault_ServiceDesc.Streams[1], "/pb.Vault/BatchValidate", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &vaultBatchValidateClient{stream}
+	return x, nil
+}
+
+type Vault_BatchValidateClient interface {
+	Send(*BatchValidateRequest) error
+	Recv() (*BatchValidateResponse, error)
+	grpc.ClientStream
+}
+
+type vaultBatchValidateClient struct {
+	grpc.ClientStream
+}
+
+func (x *vaultBatchValidateClient) Send(m *BatchValidateRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *vaultBatchValidateClient) Recv() (*BatchValidateResponse, error) {
+	m := new(BatchValidateResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// VaultServer is the server API for Vault service.
+// All implementations should embed UnimplementedVaultServer
+// for forward compatibility
+type VaultServer interface {
+	Hash(context.Context, *HashRequest) (*HashResponse, error)
+	Validate(context.Context, *ValidateRequest) (*ValidateResponse, error)
+	BatchHash(Vault_BatchHashServer) error
+	BatchValidate(Vault_BatchValidateServer) error
+}
+
+// UnimplementedVaultServer should be embedded to have forward compatible implementations.
+type UnimplementedVaultServer struct {
+}
+
+func (UnimplementedVaultServer) Hash(context.Context, *HashRequest) (*HashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Hash not implemented")
+}
+func (UnimplementedVaultServer) Validate(context.Context, *ValidateRequest) (*ValidateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Validate not implemented")
+}
+func (UnimplementedVaultServer) BatchHash(Vault_BatchHashServer) error {
+	return status.Errorf(codes.Unimplemented, "method BatchHash not implemented")
+}
+func (UnimplementedVaultServer) BatchValidate(Vault_BatchValidateServer) error {
+	return status.Errorf(codes.Unimplemented, "method BatchValidate not implemented")
+}
+
+// UnsafeVaultServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VaultServer will
+// result in compilation errors.
+type UnsafeVaultServer interface {
+	mustEmbedUnimplementedVaultServer()
+}
+
+func RegisterVaultServer(s grpc.ServiceRegistrar, srv VaultServer) {
+	s.RegisterService(&Vault_ServiceDesc, srv)
+}
+
+func _Vault_Hash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VaultServer).Hash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Vault/Hash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VaultServer).Hash(ctx, req.(*HashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Vault_Validate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VaultServer).Validate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Vault/Validate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VaultServer).Validate(ctx, req.(*ValidateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Vault_BatchHash_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(VaultServer).BatchHash(&vaultBatchHashServer{stream})
+}
+
+type Vault_BatchHashServer interface {
+	Send(*BatchHashResponse) error
+	Recv() (*BatchHashRequest, error)
+	grpc.ServerStream
+}
+
+type vaultBatchHashServer struct {
+	grpc.ServerStream
+}
+
+func (x *vaultBatchHashServer) Send(m *BatchHashResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *vaultBatchHashServer) Recv() (*BatchHashRequest, error) {
+	m := new(BatchHashRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Vault_BatchValidate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(VaultServer).BatchValidate(&vaultBatchValidateServer{stream})
+}
+
+type Vault_BatchValidateServer interface {
+	Send(*BatchValidateResponse) error
+	Recv() (*BatchValidateRequest, error)
+	grpc.ServerStream
+}
+
+type vaultBatchValidateServer struct {
+	grpc.ServerStream
+}
+
+func (x *vaultBatchValidateServer) Send(m *BatchValidateResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *vaultBatchValidateServer) Recv() (*BatchValidateRequest, error) {
+	m := new(BatchValidateRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Vault_ServiceDesc is the grpc.ServiceDesc for Vault service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Vault_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.Vault",
+	HandlerType: (*VaultServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Hash",
+			Handler:    _Vault_Hash_Handler,
+		},
+		{
+			MethodName: "Validate",
+			Handler:    _Vault_Validate_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BatchHash",
+			Handler:       _Vault_BatchHash_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "BatchValidate",
+			Handler:       _Vault_BatchValidate_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "vault.proto",
+}
+
+// SysClient is the client API for Sys service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SysClient interface {
+	Init(ctx context.Context, in *InitRequest, opts ...grpc.CallOption) (*InitResponse, error)
+	Unseal(ctx context.Context, in *UnsealRequest, opts ...grpc.CallOption) (*SealStatusResponse, error)
+	Seal(ctx context.Context, in *SealRequest, opts ...grpc.CallOption) (*SealResponse, error)
+	SealStatus(ctx context.Context, in *SealStatusRequest, opts ...grpc.CallOption) (*SealStatusResponse, error)
+	Rotate(ctx context.Context, in *RotateRequest, opts ...grpc.CallOption) (*RotateResponse, error)
+}
+
+type sysClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSysClient(cc grpc.ClientConnInterface) SysClient {
+	return &sysClient{cc}
+}
+
+func (c *sysClient) Init(ctx context.Context, in *InitRequest, opts ...grpc.CallOption) (*InitResponse, error) {
+	out := new(InitResponse)
+	err := c.cc.Invoke(ctx, "/pb.Sys/Init", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sysClient) Unseal(ctx context.Context, in *UnsealRequest, opts ...grpc.CallOption) (*SealStatusResponse, error) {
+	out := new(SealStatusResponse)
+	err := c.cc.Invoke(ctx, "/pb.Sys/Unseal", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sysClient) Seal(ctx context.Context, in *SealRequest, opts ...grpc.CallOption) (*SealResponse, error) {
+	out := new(SealResponse)
+	err := c.cc.Invoke(ctx, "/pb.Sys/Seal", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sysClient) SealStatus(ctx context.Context, in *SealStatusRequest, opts ...grpc.CallOption) (*SealStatusResponse, error) {
+	out := new(SealStatusResponse)
+	err := c.cc.Invoke(ctx, "/pb.Sys/SealStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sysClient) Rotate(ctx context.Context, in *RotateRequest, opts ...grpc.CallOption) (*RotateResponse, error) {
+	out := new(RotateResponse)
+	err := c.cc.Invoke(ctx, "/pb.Sys/Rotate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SysServer is the server API for Sys service.
+// All implementations should embed UnimplementedSysServer
+// for forward compatibility
+type SysServer interface {
+	Init(context.Context, *InitRequest) (*InitResponse, error)
+	Unseal(context.Context, *UnsealRequest) (*SealStatusResponse, error)
+	Seal(context.Context, *SealRequest) (*SealResponse, error)
+	SealStatus(context.Context, *SealStatusRequest) (*SealStatusResponse, error)
+	Rotate(context.Context, *RotateRequest) (*RotateResponse, error)
+}
+
+// UnimplementedSysServer should be embedded to have forward compatible implementations.
+type UnimplementedSysServer struct {
+}
+
+func (UnimplementedSysServer) Init(context.Context, *InitRequest) (*InitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Init not implemented")
+}
+func (UnimplementedSysServer) Unseal(context.Context, *UnsealRequest) (*SealStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unseal not implemented")
+}
+func (UnimplementedSysServer) Seal(context.Context, *SealRequest) (*SealResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Seal not implemented")
+}
+func (UnimplementedSysServer) SealStatus(context.Context, *SealStatusRequest) (*SealStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SealStatus not implemented")
+}
+func (UnimplementedSysServer) Rotate(context.Context, *RotateRequest) (*RotateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rotate not implemented")
+}
+
+// UnsafeSysServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SysServer will
+// result in compilation errors.
+type UnsafeSysServer interface {
+	mustEmbedUnimplementedSysServer()
+}
+
+func RegisterSysServer(s grpc.ServiceRegistrar, srv SysServer) {
+	s.RegisterService(&Sys_ServiceDesc, srv)
+}
+
+func _Sys_Init_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SysServer).Init(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Sys/Init",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SysServer).Init(ctx, req.(*InitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sys_Unseal_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnsealRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SysServer).Unseal(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Sys/Unseal",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SysServer).Unseal(ctx, req.(*UnsealRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sys_Seal_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SealRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SysServer).Seal(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Sys/Seal",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SysServer).Seal(ctx, req.(*SealRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sys_SealStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SealStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SysServer).SealStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Sys/SealStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SysServer).SealStatus(ctx, req.(*SealStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sys_Rotate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SysServer).Rotate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Sys/Rotate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SysServer).Rotate(ctx, req.(*RotateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Sys_ServiceDesc is the grpc.ServiceDesc for Sys service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Sys_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.Sys",
+	HandlerType: (*SysServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Init",
+			Handler:    _Sys_Init_Handler,
+		},
+		{
+			MethodName: "Unseal",
+			Handler:    _Sys_Unseal_Handler,
+		},
+		{
+			MethodName: "Seal",
+			Handler:    _Sys_Seal_Handler,
+		},
+		{
+			MethodName: "SealStatus",
+			Handler:    _Sys_SealStatus_Handler,
+		},
+		{
+			MethodName: "Rotate",
+			Handler:    _Sys_Rotate_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "vault.proto",
+}
+
+// KVClient is the client API for KV service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type KVClient interface {
+	Put(ctx context.Context, in *KVPutRequest, opts ...grpc.CallOption) (*KVPutResponse, error)
+	Get(ctx context.Context, in *KVGetRequest, opts ...grpc.CallOption) (*KVGetResponse, error)
+	Delete(ctx context.Context, in *KVVersionsRequest, opts ...grpc.CallOption) (*KVResponse, error)
+	Undelete(ctx context.Context, in *KVVersionsRequest, opts ...grpc.CallOption) (*KVResponse, error)
+	Destroy(ctx context.Context, in *KVVersionsRequest, opts ...grpc.CallOption) (*KVResponse, error)
+	List(ctx context.Context, in *KVListRequest, opts ...grpc.CallOption) (*KVListResponse, error)
+	ReadMetadata(ctx context.Context, in *KVMetadataRequest, opts ...grpc.CallOption) (*KVMetadataResponse, error)
+	WriteMetadata(ctx context.Context, in *KVWriteMetadataRequest, opts ...grpc.CallOption) (*KVResponse, error)
+	DeleteMetadata(ctx context.Context, in *KVMetadataRequest, opts ...grpc.CallOption) (*KVResponse, error)
+}
+
+type kVClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewKVClient(cc grpc.ClientConnInterface) KVClient {
+	return &kVClient{cc}
+}
+
+func (c *kVClient) Put(ctx context.Context, in *KVPutRequest, opts ...grpc.CallOption) (*KVPutResponse, error) {
+	out := new(KVPutResponse)
+	err := c.cc.Invoke(ctx, "/pb.KV/Put", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) Get(ctx context.Context, in *KVGetRequest, opts ...grpc.CallOption) (*KVGetResponse, error) {
+	out := new(KVGetResponse)
+	err := c.cc.Invoke(ctx, "/pb.KV/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) Delete(ctx context.Context, in *KVVersionsRequest, opts ...grpc.CallOption) (*KVResponse, error) {
+	out := new(KVResponse)
+	err := c.cc.Invoke(ctx, "/pb.KV/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) Undelete(ctx context.Context, in *KVVersionsRequest, opts ...grpc.CallOption) (*KVResponse, error) {
+	out := new(KVResponse)
+	err := c.cc.Invoke(ctx, "/pb.KV/Undelete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) Destroy(ctx context.Context, in *KVVersionsRequest, opts ...grpc.CallOption) (*KVResponse, error) {
+	out := new(KVResponse)
+	err := c.cc.Invoke(ctx, "/pb.KV/Destroy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) List(ctx context.Context, in *KVListRequest, opts ...grpc.CallOption) (*KVListResponse, error) {
+	out := new(KVListResponse)
+	err := c.cc.Invoke(ctx, "/pb.KV/List", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) ReadMetadata(ctx context.Context, in *KVMetadataRequest, opts ...grpc.CallOption) (*KVMetadataResponse, error) {
+	out := new(KVMetadataResponse)
+	err := c.cc.Invoke(ctx, "/pb.KV/ReadMetadata", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) WriteMetadata(ctx context.Context, in *KVWriteMetadataRequest, opts ...grpc.CallOption) (*KVResponse, error) {
+	out := new(KVResponse)
+	err := c.cc.Invoke(ctx, "/pb.KV/WriteMetadata", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) DeleteMetadata(ctx context.Context, in *KVMetadataRequest, opts ...grpc.CallOption) (*KVResponse, error) {
+	out := new(KVResponse)
+	err := c.cc.Invoke(ctx, "/pb.KV/DeleteMetadata", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// KVServer is the server API for KV service.
+// All implementations should embed UnimplementedKVServer
+// for forward compatibility
+type KVServer interface {
+	Put(context.Context, *KVPutRequest) (*KVPutResponse, error)
+	Get(context.Context, *KVGetRequest) (*KVGetResponse, error)
+	Delete(context.Context, *KVVersionsRequest) (*KVResponse, error)
+	Undelete(context.Context, *KVVersionsRequest) (*KVResponse, error)
+	Destroy(context.Context, *KVVersionsRequest) (*KVResponse, error)
+	List(context.Context, *KVListRequest) (*KVListResponse, error)
+	ReadMetadata(context.Context, *KVMetadataRequest) (*KVMetadataResponse, error)
+	WriteMetadata(context.Context, *KVWriteMetadataRequest) (*KVResponse, error)
+	DeleteMetadata(context.Context, *KVMetadataRequest) (*KVResponse, error)
+}
+
+// UnimplementedKVServer should be embedded to have forward compatible implementations.
+type UnimplementedKVServer struct {
+}
+
+func (UnimplementedKVServer) Put(context.Context, *KVPutRequest) (*KVPutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Put not implemented")
+}
+func (UnimplementedKVServer) Get(context.Context, *KVGetRequest) (*KVGetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedKVServer) Delete(context.Context, *KVVersionsRequest) (*KVResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedKVServer) Undelete(context.Context, *KVVersionsRequest) (*KVResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Undelete not implemented")
+}
+func (UnimplementedKVServer) Destroy(context.Context, *KVVersionsRequest) (*KVResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Destroy not implemented")
+}
+func (UnimplementedKVServer) List(context.Context, *KVListRequest) (*KVListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedKVServer) ReadMetadata(context.Context, *KVMetadataRequest) (*KVMetadataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadMetadata not implemented")
+}
+func (UnimplementedKVServer) WriteMetadata(context.Context, *KVWriteMetadataRequest) (*KVResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WriteMetadata not implemented")
+}
+func (UnimplementedKVServer) DeleteMetadata(context.Context, *KVMetadataRequest) (*KVResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteMetadata not implemented")
+}
+
+// UnsafeKVServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to KVServer will
+// result in compilation errors.
+type UnsafeKVServer interface {
+	mustEmbedUnimplementedKVServer()
+}
+
+func RegisterKVServer(s grpc.ServiceRegistrar, srv KVServer) {
+	s.RegisterService(&KV_ServiceDesc, srv)
+}
+
+func _KV_Put_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KVPutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).Put(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.KV/Put",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).Put(ctx, req.(*KVPutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KVGetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.KV/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).Get(ctx, req.(*KVGetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KVVersionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.KV/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).Delete(ctx, req.(*KVVersionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_Undelete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KVVersionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).Undelete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.KV/Undelete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).Undelete(ctx, req.(*KVVersionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_Destroy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KVVersionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).Destroy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.KV/Destroy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).Destroy(ctx, req.(*KVVersionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KVListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.KV/List",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).List(ctx, req.(*KVListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_ReadMetadata_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KVMetadataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).ReadMetadata(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.KV/ReadMetadata",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).ReadMetadata(ctx, req.(*KVMetadataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_WriteMetadata_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KVWriteMetadataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).WriteMetadata(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.KV/WriteMetadata",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).WriteMetadata(ctx, req.(*KVWriteMetadataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_DeleteMetadata_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KVMetadataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).DeleteMetadata(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.KV/DeleteMetadata",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).DeleteMetadata(ctx, req.(*KVMetadataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// KV_ServiceDesc is the grpc.ServiceDesc for KV service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var KV_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.KV",
+	HandlerType: (*KVServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Put",
+			Handler:    _KV_Put_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _KV_Get_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _KV_Delete_Handler,
+		},
+		{
+			MethodName: "Undelete",
+			Handler:    _KV_Undelete_Handler,
+		},
+		{
+			MethodName: "Destroy",
+			Handler:    _KV_Destroy_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _KV_List_Handler,
+		},
+		{
+			MethodName: "ReadMetadata",
+			Handler:    _KV_ReadMetadata_Handler,
+		},
+		{
+			MethodName: "WriteMetadata",
+			Handler:    _KV_WriteMetadata_Handler,
+		},
+		{
+			MethodName: "DeleteMetadata",
+			Handler:    _KV_DeleteMetadata_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "vault.proto",
+}
+
+// PolicyClient is the client API for Policy service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PolicyClient interface {
+	ReadPolicy(ctx context.Context, in *PolicyRequest, opts ...grpc.CallOption) (*PolicyResponse, error)
+	WritePolicy(ctx context.Context, in *WritePolicyRequest, opts ...grpc.CallOption) (*PolicyResponse, error)
+	DeletePolicy(ctx context.Context, in *PolicyRequest, opts ...grpc.CallOption) (*PolicyResponse, error)
+	ListPolicies(ctx context.Context, in *ListPoliciesRequest, opts ...grpc.CallOption) (*ListPoliciesResponse, error)
+	ReadSubject(ctx context.Context, in *SubjectRequest, opts ...grpc.CallOption) (*SubjectResponse, error)
+	WriteSubject(ctx context.Context, in *WriteSubjectRequest, opts ...grpc.CallOption) (*SubjectResponse, error)
+}
+
+type policyClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPolicyClient(cc grpc.ClientConnInterface) PolicyClient {
+	return &policyClient{cc}
+}
+
+func (c *policyClient) ReadPolicy(ctx context.Context, in *PolicyRequest, opts ...grpc.CallOption) (*PolicyResponse, error) {
+	out := new(PolicyResponse)
+	err := c.cc.Invoke(ctx, "/pb.Policy/ReadPolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *policyClient) WritePolicy(ctx context.Context, in *WritePolicyRequest, opts ...grpc.CallOption) (*PolicyResponse, error) {
+	out := new(PolicyResponse)
+	err := c.cc.Invoke(ctx, "/pb.Policy/WritePolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *policyClient) DeletePolicy(ctx context.Context, in *PolicyRequest, opts ...grpc.CallOption) (*PolicyResponse, error) {
+	out := new(PolicyResponse)
+	err := c.cc.Invoke(ctx, "/pb.Policy/DeletePolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *policyClient) ListPolicies(ctx context.Context, in *ListPoliciesRequest, opts ...grpc.CallOption) (*ListPoliciesResponse, error) {
+	out := new(ListPoliciesResponse)
+	err := c.cc.Invoke(ctx, "/pb.Policy/ListPolicies", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *policyClient) ReadSubject(ctx context.Context, in *SubjectRequest, opts ...grpc.CallOption) (*SubjectResponse, error) {
+	out := new(SubjectResponse)
+	err := c.cc.Invoke(ctx, "/pb.Policy/ReadSubject", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *policyClient) WriteSubject(ctx context.Context, in *WriteSubjectRequest, opts ...grpc.CallOption) (*SubjectResponse, error) {
+	out := new(SubjectResponse)
+	err := c.cc.Invoke(ctx, "/pb.Policy/WriteSubject", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PolicyServer is the server API for Policy service.
+// All implementations should embed UnimplementedPolicyServer
+// for forward compatibility
+type PolicyServer interface {
+	ReadPolicy(context.Context, *PolicyRequest) (*PolicyResponse, error)
+	WritePolicy(context.Context, *WritePolicyRequest) (*PolicyResponse, error)
+	DeletePolicy(context.Context, *PolicyRequest) (*PolicyResponse, error)
+	ListPolicies(context.Context, *ListPoliciesRequest) (*ListPoliciesResponse, error)
+	ReadSubject(context.Context, *SubjectRequest) (*SubjectResponse, error)
+	WriteSubject(context.Context, *WriteSubjectRequest) (*SubjectResponse, error)
+}
+
+// UnimplementedPolicyServer should be embedded to have forward compatible implementations.
+type UnimplementedPolicyServer struct {
+}
+
+func (UnimplementedPolicyServer) ReadPolicy(context.Context, *PolicyRequest) (*PolicyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadPolicy not implemented")
+}
+func (UnimplementedPolicyServer) WritePolicy(context.Context, *WritePolicyRequest) (*PolicyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WritePolicy not implemented")
+}
+func (UnimplementedPolicyServer) DeletePolicy(context.Context, *PolicyRequest) (*PolicyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeletePolicy not implemented")
+}
+func (UnimplementedPolicyServer) ListPolicies(context.Context, *ListPoliciesRequest) (*ListPoliciesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPolicies not implemented")
+}
+func (UnimplementedPolicyServer) ReadSubject(context.Context, *SubjectRequest) (*SubjectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadSubject not implemented")
+}
+func (UnimplementedPolicyServer) WriteSubject(context.Context, *WriteSubjectRequest) (*SubjectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WriteSubject not implemented")
+}
+
+// UnsafePolicyServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PolicyServer will
+// result in compilation errors.
+type UnsafePolicyServer interface {
+	mustEmbedUnimplementedPolicyServer()
+}
+
+func RegisterPolicyServer(s grpc.ServiceRegistrar, srv PolicyServer) {
+	s.RegisterService(&Policy_ServiceDesc, srv)
+}
+
+func _Policy_ReadPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PolicyServer).ReadPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Policy/ReadPolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PolicyServer).ReadPolicy(ctx, req.(*PolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Policy_WritePolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WritePolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PolicyServer).WritePolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Policy/WritePolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PolicyServer).WritePolicy(ctx, req.(*WritePolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Policy_DeletePolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PolicyServer).DeletePolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Policy/DeletePolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PolicyServer).DeletePolicy(ctx, req.(*PolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Policy_ListPolicies_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPoliciesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PolicyServer).ListPolicies(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Policy/ListPolicies",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PolicyServer).ListPolicies(ctx, req.(*ListPoliciesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Policy_ReadSubject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PolicyServer).ReadSubject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Policy/ReadSubject",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PolicyServer).ReadSubject(ctx, req.(*SubjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Policy_WriteSubject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WriteSubjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PolicyServer).WriteSubject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Policy/WriteSubject",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PolicyServer).WriteSubject(ctx, req.(*WriteSubjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Policy_ServiceDesc is the grpc.ServiceDesc for Policy service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Policy_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.Policy",
+	HandlerType: (*PolicyServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReadPolicy",
+			Handler:    _Policy_ReadPolicy_Handler,
+		},
+		{
+			MethodName: "WritePolicy",
+			Handler:    _Policy_WritePolicy_Handler,
+		},
+		{
+			MethodName: "DeletePolicy",
+			Handler:    _Policy_DeletePolicy_Handler,
+		},
+		{
+			MethodName: "ListPolicies",
+			Handler:    _Policy_ListPolicies_Handler,
+		},
+		{
+			MethodName: "ReadSubject",
+			Handler:    _Policy_ReadSubject_Handler,
+		},
+		{
+			MethodName: "WriteSubject",
+			Handler:    _Policy_WriteSubject_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "vault.proto",
+}
+
+// LeaseClient is the client API for Lease service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type LeaseClient interface {
+	Lookup(ctx context.Context, in *LeaseRequest, opts ...grpc.CallOption) (*LeaseResponse, error)
+	Renew(ctx context.Context, in *RenewLeaseRequest, opts ...grpc.CallOption) (*LeaseResponse, error)
+	Revoke(ctx context.Context, in *LeaseRequest, opts ...grpc.CallOption) (*LeaseResponse, error)
+	RevokePrefix(ctx context.Context, in *LeasePrefixRequest, opts ...grpc.CallOption) (*LeaseResponse, error)
+	List(ctx context.Context, in *LeasePrefixRequest, opts ...grpc.CallOption) (*ListLeasesResponse, error)
+}
+
+type leaseClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLeaseClient(cc grpc.ClientConnInterface) LeaseClient {
+	return &leaseClient{cc}
+}
+
+func (c *leaseClient) Lookup(ctx context.Context, in *LeaseRequest, opts ...grpc.CallOption) (*LeaseResponse, error) {
+	out := new(LeaseResponse)
+	err := c.cc.Invoke(ctx, "/pb.Lease/Lookup", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *leaseClient) Renew(ctx context.Context, in *RenewLeaseRequest, opts ...grpc.CallOption) (*LeaseResponse, error) {
+	out := new(LeaseResponse)
+	err := c.cc.Invoke(ctx, "/pb.Lease/Renew", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *leaseClient) Revoke(ctx context.Context, in *LeaseRequest, opts ...grpc.CallOption) (*LeaseResponse, error) {
+	out := new(LeaseResponse)
+	err := c.cc.Invoke(ctx, "/pb.Lease/Revoke", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *leaseClient) RevokePrefix(ctx context.Context, in *LeasePrefixRequest, opts ...grpc.CallOption) (*LeaseResponse, error) {
+	out := new(LeaseResponse)
+	err := c.cc.Invoke(ctx, "/pb.Lease/RevokePrefix", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *leaseClient) List(ctx context.Context, in *LeasePrefixRequest, opts ...grpc.CallOption) (*ListLeasesResponse, error) {
+	out := new(ListLeasesResponse)
+	err := c.cc.Invoke(ctx, "/pb.Lease/List", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LeaseServer is the server API for Lease service.
+// All implementations should embed UnimplementedLeaseServer
+// for forward compatibility
+type LeaseServer interface {
+	Lookup(context.Context, *LeaseRequest) (*LeaseResponse, error)
+	Renew(context.Context, *RenewLeaseRequest) (*LeaseResponse, error)
+	Revoke(context.Context, *LeaseRequest) (*LeaseResponse, error)
+	RevokePrefix(context.Context, *LeasePrefixRequest) (*LeaseResponse, error)
+	List(context.Context, *LeasePrefixRequest) (*ListLeasesResponse, error)
+}
+
+// UnimplementedLeaseServer should be embedded to have forward compatible implementations.
+type UnimplementedLeaseServer struct {
+}
+
+func (UnimplementedLeaseServer) Lookup(context.Context, *LeaseRequest) (*LeaseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Lookup not implemented")
+}
+func (UnimplementedLeaseServer) Renew(context.Context, *RenewLeaseRequest) (*LeaseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Renew not implemented")
+}
+func (UnimplementedLeaseServer) Revoke(context.Context, *LeaseRequest) (*LeaseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Revoke not implemented")
+}
+func (UnimplementedLeaseServer) RevokePrefix(context.Context, *LeasePrefixRequest) (*LeaseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokePrefix not implemented")
+}
+func (UnimplementedLeaseServer) List(context.Context, *LeasePrefixRequest) (*ListLeasesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+
+// UnsafeLeaseServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LeaseServer will
+// result in compilation errors.
+type UnsafeLeaseServer interface {
+	mustEmbedUnimplementedLeaseServer()
+}
+
+func RegisterLeaseServer(s grpc.ServiceRegistrar, srv LeaseServer) {
+	s.RegisterService(&Lease_ServiceDesc, srv)
+}
+
+func _Lease_Lookup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LeaseServer).Lookup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Lease/Lookup",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LeaseServer).Lookup(ctx, req.(*LeaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lease_Renew_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenewLeaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LeaseServer).Renew(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Lease/Renew",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LeaseServer).Renew(ctx, req.(*RenewLeaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lease_Revoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LeaseServer).Revoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Lease/Revoke",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LeaseServer).Revoke(ctx, req.(*LeaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lease_RevokePrefix_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeasePrefixRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LeaseServer).RevokePrefix(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Lease/RevokePrefix",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LeaseServer).RevokePrefix(ctx, req.(*LeasePrefixRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lease_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeasePrefixRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LeaseServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Lease/List",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LeaseServer).List(ctx, req.(*LeasePrefixRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Lease_ServiceDesc is the grpc.ServiceDesc for Lease service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Lease_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.Lease",
+	HandlerType: (*LeaseServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Lookup",
+			Handler:    _Lease_Lookup_Handler,
+		},
+		{
+			MethodName: "Renew",
+			Handler:    _Lease_Renew_Handler,
+		},
+		{
+			MethodName: "Revoke",
+			Handler:    _Lease_Revoke_Handler,
+		},
+		{
+			MethodName: "RevokePrefix",
+			Handler:    _Lease_RevokePrefix_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _Lease_List_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "vault.proto",
+}
+
+// TokenClient is the client API for Token service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TokenClient interface {
+	Create(ctx context.Context, in *CreateTokenRequest, opts ...grpc.CallOption) (*TokenResponse, error)
+	Lookup(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error)
+	LookupSelf(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error)
+	Renew(ctx context.Context, in *RenewTokenRequest, opts ...grpc.CallOption) (*TokenResponse, error)
+	RenewSelf(ctx context.Context, in *RenewTokenRequest, opts ...grpc.CallOption) (*TokenResponse, error)
+	Revoke(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error)
+	RevokeSelf(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error)
+	RevokeOrphan(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error)
+}
+
+type tokenClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTokenClient(cc grpc.ClientConnInterface) TokenClient {
+	return &tokenClient{cc}
+}
+
+func (c *tokenClient) Create(ctx context.Context, in *CreateTokenRequest, opts ...grpc.CallOption) (*TokenResponse, error) {
+	out := new(TokenResponse)
+	err := c.cc.Invoke(ctx, "/pb.Token/Create", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokenClient) Lookup(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error) {
+	out := new(TokenResponse)
+	err := c.cc.Invoke(ctx, "/pb.Token/Lookup", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokenClient) LookupSelf(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error) {
+	out := new(TokenResponse)
+	err := c.cc.Invoke(ctx, "/pb.Token/LookupSelf", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokenClient) Renew(ctx context.Context, in *RenewTokenRequest, opts ...grpc.CallOption) (*TokenResponse, error) {
+	out := new(TokenResponse)
+	err := c.cc.Invoke(ctx, "/pb.Token/Renew", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokenClient) RenewSelf(ctx context.Context, in *RenewTokenRequest, opts ...grpc.CallOption) (*TokenResponse, error) {
+	out := new(TokenResponse)
+	err := c.cc.Invoke(ctx, "/pb.Token/RenewSelf", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokenClient) Revoke(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error) {
+	out := new(TokenResponse)
+	err := c.cc.Invoke(ctx, "/pb.Token/Revoke", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokenClient) RevokeSelf(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error) {
+	out := new(TokenResponse)
+	err := c.cc.Invoke(ctx, "/pb.Token/RevokeSelf", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokenClient) RevokeOrphan(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error) {
+	out := new(TokenResponse)
+	err := c.cc.Invoke(ctx, "/pb.Token/RevokeOrphan", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TokenServer is the server API for Token service.
+// All implementations should embed UnimplementedTokenServer
+// for forward compatibility
+type TokenServer interface {
+	Create(context.Context, *CreateTokenRequest) (*TokenResponse, error)
+	Lookup(context.Context, *TokenRequest) (*TokenResponse, error)
+	LookupSelf(context.Context, *TokenRequest) (*TokenResponse, error)
+	Renew(context.Context, *RenewTokenRequest) (*TokenResponse, error)
+	RenewSelf(context.Context, *RenewTokenRequest) (*TokenResponse, error)
+	Revoke(context.Context, *TokenRequest) (*TokenResponse, error)
+	RevokeSelf(context.Context, *TokenRequest) (*TokenResponse, error)
+	RevokeOrphan(context.Context, *TokenRequest) (*TokenResponse, error)
+}
+
+// UnimplementedTokenServer should be embedded to have forward compatible implementations.
+type UnimplementedTokenServer struct {
+}
+
+func (UnimplementedTokenServer) Create(context.Context, *CreateTokenRequest) (*TokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedTokenServer) Lookup(context.Context, *TokenRequest) (*TokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Lookup not implemented")
+}
+func (UnimplementedTokenServer) LookupSelf(context.Context, *TokenRequest) (*TokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupSelf not implemented")
+}
+func (UnimplementedTokenServer) Renew(context.Context, *RenewTokenRequest) (*TokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Renew not implemented")
+}
+func (UnimplementedTokenServer) RenewSelf(context.Context, *RenewTokenRequest) (*TokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenewSelf not implemented")
+}
+func (UnimplementedTokenServer) Revoke(context.Context, *TokenRequest) (*TokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Revoke not implemented")
+}
+func (UnimplementedTokenServer) RevokeSelf(context.Context, *TokenRequest) (*TokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeSelf not implemented")
+}
+func (UnimplementedTokenServer) RevokeOrphan(context.Context, *TokenRequest) (*TokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeOrphan not implemented")
+}
+
+// UnsafeTokenServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TokenServer will
+// result in compilation errors.
+type UnsafeTokenServer interface {
+	mustEmbedUnimplementedTokenServer()
+}
+
+func RegisterTokenServer(s grpc.ServiceRegistrar, srv TokenServer) {
+	s.RegisterService(&Token_ServiceDesc, srv)
+}
+
+func _Token_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Token/Create",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenServer).Create(ctx, req.(*CreateTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Token_Lookup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenServer).Lookup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Token/Lookup",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenServer).Lookup(ctx, req.(*TokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Token_LookupSelf_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenServer).LookupSelf(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Token/LookupSelf",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenServer).LookupSelf(ctx, req.(*TokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Token_Renew_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenewTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenServer).Renew(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Token/Renew",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenServer).Renew(ctx, req.(*RenewTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Token_RenewSelf_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenewTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenServer).RenewSelf(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Token/RenewSelf",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenServer).RenewSelf(ctx, req.(*RenewTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Token_Revoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenServer).Revoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Token/Revoke",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenServer).Revoke(ctx, req.(*TokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Token_RevokeSelf_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenServer).RevokeSelf(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Token/RevokeSelf",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenServer).RevokeSelf(ctx, req.(*TokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Token_RevokeOrphan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenServer).RevokeOrphan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Token/RevokeOrphan",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenServer).RevokeOrphan(ctx, req.(*TokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Token_ServiceDesc is the grpc.ServiceDesc for Token service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Token_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.Token",
+	HandlerType: (*TokenServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Create",
+			Handler:    _Token_Create_Handler,
+		},
+		{
+			MethodName: "Lookup",
+			Handler:    _Token_Lookup_Handler,
+		},
+		{
+			MethodName: "LookupSelf",
+			Handler:    _Token_LookupSelf_Handler,
+		},
+		{
+			MethodName: "Renew",
+			Handler:    _Token_Renew_Handler,
+		},
+		{
+			MethodName: "RenewSelf",
+			Handler:    _Token_RenewSelf_Handler,
+		},
+		{
+			MethodName: "Revoke",
+			Handler:    _Token_Revoke_Handler,
+		},
+		{
+			MethodName: "RevokeSelf",
+			Handler:    _Token_RevokeSelf_Handler,
+		},
+		{
+			MethodName: "RevokeOrphan",
+			Handler:    _Token_RevokeOrphan_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "vault.proto",
+}
+
+// AppRoleClient is the client API for AppRole service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AppRoleClient interface {
+	ReadRole(ctx context.Context, in *RoleRequest, opts ...grpc.CallOption) (*RoleResponse, error)
+	WriteRole(ctx context.Context, in *WriteRoleRequest, opts ...grpc.CallOption) (*RoleResponse, error)
+	DeleteRole(ctx context.Context, in *RoleRequest, opts ...grpc.CallOption) (*RoleResponse, error)
+	ListRoles(ctx context.Context, in *ListRolesRequest, opts ...grpc.CallOption) (*ListRolesResponse, error)
+	GenerateSecretID(ctx context.Context, in *RoleRequest, opts ...grpc.CallOption) (*SecretIDResponse, error)
+	DestroySecretID(ctx context.Context, in *DestroySecretIDRequest, opts ...grpc.CallOption) (*SecretIDResponse, error)
+	Login(ctx context.Context, in *AppRoleLoginRequest, opts ...grpc.CallOption) (*TokenResponse, error)
+}
+
+type appRoleClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAppRoleClient(cc grpc.ClientConnInterface) AppRoleClient {
+	return &appRoleClient{cc}
+}
+
+func (c *appRoleClient) ReadRole(ctx context.Context, in *RoleRequest, opts ...grpc.CallOption) (*RoleResponse, error) {
+	out := new(RoleResponse)
+	err := c.cc.Invoke(ctx, "/pb.AppRole/ReadRole", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appRoleClient) WriteRole(ctx context.Context, in *WriteRoleRequest, opts ...grpc.CallOption) (*RoleResponse, error) {
+	out := new(RoleResponse)
+	err := c.cc.Invoke(ctx, "/pb.AppRole/WriteRole", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appRoleClient) DeleteRole(ctx context.Context, in *RoleRequest, opts ...grpc.CallOption) (*RoleResponse, error) {
+	out := new(RoleResponse)
+	err := c.cc.Invoke(ctx, "/pb.AppRole/DeleteRole", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appRoleClient) ListRoles(ctx context.Context, in *ListRolesRequest, opts ...grpc.CallOption) (*ListRolesResponse, error) {
+	out := new(ListRolesResponse)
+	err := c.cc.Invoke(ctx, "/pb.AppRole/ListRoles", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appRoleClient) GenerateSecretID(ctx context.Context, in *RoleRequest, opts ...grpc.CallOption) (*SecretIDResponse, error) {
+	out := new(SecretIDResponse)
+	err := c.cc.Invoke(ctx, "/pb.AppRole/GenerateSecretID", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appRoleClient) DestroySecretID(ctx context.Context, in *DestroySecretIDRequest, opts ...grpc.CallOption) (*SecretIDResponse, error) {
+	out := new(SecretIDResponse)
+	err := c.cc.Invoke(ctx, "/pb.AppRole/DestroySecretID", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appRoleClient) Login(ctx context.Context, in *AppRoleLoginRequest, opts ...grpc.CallOption) (*TokenResponse, error) {
+	out := new(TokenResponse)
+	err := c.cc.Invoke(ctx, "/pb.AppRole/Login", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AppRoleServer is the server API for AppRole service.
+// All implementations should embed UnimplementedAppRoleServer
+// for forward compatibility
+type AppRoleServer interface {
+	ReadRole(context.Context, *RoleRequest) (*RoleResponse, error)
+	WriteRole(context.Context, *WriteRoleRequest) (*RoleResponse, error)
+	DeleteRole(context.Context, *RoleRequest) (*RoleResponse, error)
+	ListRoles(context.Context, *ListRolesRequest) (*ListRolesResponse, error)
+	GenerateSecretID(context.Context, *RoleRequest) (*SecretIDResponse, error)
+	DestroySecretID(context.Context, *DestroySecretIDRequest) (*SecretIDResponse, error)
+	Login(context.Context, *AppRoleLoginRequest) (*TokenResponse, error)
+}
+
+// UnimplementedAppRoleServer should be embedded to have forward compatible implementations.
+type UnimplementedAppRoleServer struct {
+}
+
+func (UnimplementedAppRoleServer) ReadRole(context.Context, *RoleRequest) (*RoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadRole not implemented")
+}
+func (UnimplementedAppRoleServer) WriteRole(context.Context, *WriteRoleRequest) (*RoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WriteRole not implemented")
+}
+func (UnimplementedAppRoleServer) DeleteRole(context.Context, *RoleRequest) (*RoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRole not implemented")
+}
+func (UnimplementedAppRoleServer) ListRoles(context.Context, *ListRolesRequest) (*ListRolesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRoles not implemented")
+}
+func (UnimplementedAppRoleServer) GenerateSecretID(context.Context, *RoleRequest) (*SecretIDResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GenerateSecretID not implemented")
+}
+func (UnimplementedAppRoleServer) DestroySecretID(context.Context, *DestroySecretIDRequest) (*SecretIDResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DestroySecretID not implemented")
+}
+func (UnimplementedAppRoleServer) Login(context.Context, *AppRoleLoginRequest) (*TokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+
+// UnsafeAppRoleServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AppRoleServer will
+// result in compilation errors.
+type UnsafeAppRoleServer interface {
+	mustEmbedUnimplementedAppRoleServer()
+}
+
+func RegisterAppRoleServer(s grpc.ServiceRegistrar, srv AppRoleServer) {
+	s.RegisterService(&AppRole_ServiceDesc, srv)
+}
+
+func _AppRole_ReadRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppRoleServer).ReadRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AppRole/ReadRole",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppRoleServer).ReadRole(ctx, req.(*RoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppRole_WriteRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WriteRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppRoleServer).WriteRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AppRole/WriteRole",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppRoleServer).WriteRole(ctx, req.(*WriteRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppRole_DeleteRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppRoleServer).DeleteRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AppRole/DeleteRole",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppRoleServer).DeleteRole(ctx, req.(*RoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppRole_ListRoles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRolesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppRoleServer).ListRoles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AppRole/ListRoles",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppRoleServer).ListRoles(ctx, req.(*ListRolesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppRole_GenerateSecretID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppRoleServer).GenerateSecretID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AppRole/GenerateSecretID",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppRoleServer).GenerateSecretID(ctx, req.(*RoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppRole_DestroySecretID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DestroySecretIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppRoleServer).DestroySecretID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AppRole/DestroySecretID",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppRoleServer).DestroySecretID(ctx, req.(*DestroySecretIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppRole_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppRoleLoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppRoleServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AppRole/Login",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppRoleServer).Login(ctx, req.(*AppRoleLoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AppRole_ServiceDesc is the grpc.ServiceDesc for AppRole service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AppRole_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.AppRole",
+	HandlerType: (*AppRoleServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReadRole",
+			Handler:    _AppRole_ReadRole_Handler,
+		},
+		{
+			MethodName: "WriteRole",
+			Handler:    _AppRole_WriteRole_Handler,
+		},
+		{
+			MethodName: "DeleteRole",
+			Handler:    _AppRole_DeleteRole_Handler,
+		},
+		{
+			MethodName: "ListRoles",
+			Handler:    _AppRole_ListRoles_Handler,
+		},
+		{
+			MethodName: "GenerateSecretID",
+			Handler:    _AppRole_GenerateSecretID_Handler,
+		},
+		{
+			MethodName: "DestroySecretID",
+			Handler:    _AppRole_DestroySecretID_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _AppRole_Login_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "vault.proto",
+}
+
+// UserpassClient is the client API for Userpass service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type UserpassClient interface {
+	ReadUser(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*UserResponse, error)
+	WriteUser(ctx context.Context, in *WriteUserRequest, opts ...grpc.CallOption) (*UserResponse, error)
+	DeleteUser(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*UserResponse, error)
+	ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*ListUsersResponse, error)
+	Login(ctx context.Context, in *UserpassLoginRequest, opts ...grpc.CallOption) (*TokenResponse, error)
+}
+
+type userpassClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUserpassClient(cc grpc.ClientConnInterface) UserpassClient {
+	return &userpassClient{cc}
+}
+
+func (c *userpassClient) ReadUser(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*UserResponse, error) {
+	out := new(UserResponse)
+	err := c.cc.Invoke(ctx, "/pb.Userpass/ReadUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userpassClient) WriteUser(ctx context.Context, in *WriteUserRequest, opts ...grpc.CallOption) (*UserResponse, error) {
+	out := new(UserResponse)
+	err := c.cc.Invoke(ctx, "/pb.Userpass/WriteUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userpassClient) DeleteUser(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*UserResponse, error) {
+	out := new(UserResponse)
+	err := c.cc.Invoke(ctx, "/pb.Userpass/DeleteUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userpassClient) ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*ListUsersResponse, error) {
+	out := new(ListUsersResponse)
+	err := c.cc.Invoke(ctx, "/pb.Userpass/ListUsers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userpassClient) Login(ctx context.Context, in *UserpassLoginRequest, opts ...grpc.CallOption) (*TokenResponse, error) {
+	out := new(TokenResponse)
+	err := c.cc.Invoke(ctx, "/pb.Userpass/Login", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UserpassServer is the server API for Userpass service.
+// All implementations should embed UnimplementedUserpassServer
+// for forward compatibility
+type UserpassServer interface {
+	ReadUser(context.Context, *UserRequest) (*UserResponse, error)
+	WriteUser(context.Context, *WriteUserRequest) (*UserResponse, error)
+	DeleteUser(context.Context, *UserRequest) (*UserResponse, error)
+	ListUsers(context.Context, *ListUsersRequest) (*ListUsersResponse, error)
+	Login(context.Context, *UserpassLoginRequest) (*TokenResponse, error)
+}
+
+// UnimplementedUserpassServer should be embedded to have forward compatible implementations.
+type UnimplementedUserpassServer struct {
+}
+
+func (UnimplementedUserpassServer) ReadUser(context.Context, *UserRequest) (*UserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadUser not implemented")
+}
+func (UnimplementedUserpassServer) WriteUser(context.Context, *WriteUserRequest) (*UserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WriteUser not implemented")
+}
+func (UnimplementedUserpassServer) DeleteUser(context.Context, *UserRequest) (*UserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteUser not implemented")
+}
+func (UnimplementedUserpassServer) ListUsers(context.Context, *ListUsersRequest) (*ListUsersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListUsers not implemented")
+}
+func (UnimplementedUserpassServer) Login(context.Context, *UserpassLoginRequest) (*TokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+
+// UnsafeUserpassServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to UserpassServer will
+// result in compilation errors.
+type UnsafeUserpassServer interface {
+	mustEmbedUnimplementedUserpassServer()
+}
+
+func RegisterUserpassServer(s grpc.ServiceRegistrar, srv UserpassServer) {
+	s.RegisterService(&Userpass_ServiceDesc, srv)
+}
+
+func _Userpass_ReadUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserpassServer).ReadUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Userpass/ReadUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserpassServer).ReadUser(ctx, req.(*UserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Userpass_WriteUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WriteUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserpassServer).WriteUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Userpass/WriteUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserpassServer).WriteUser(ctx, req.(*WriteUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Userpass_DeleteUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserpassServer).DeleteUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Userpass/DeleteUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserpassServer).DeleteUser(ctx, req.(*UserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Userpass_ListUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserpassServer).ListUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Userpass/ListUsers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserpassServer).ListUsers(ctx, req.(*ListUsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Userpass_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserpassLoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserpassServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Userpass/Login",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserpassServer).Login(ctx, req.(*UserpassLoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Userpass_ServiceDesc is the grpc.ServiceDesc for Userpass service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Userpass_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.Userpass",
+	HandlerType: (*UserpassServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReadUser",
+			Handler:    _Userpass_ReadUser_Handler,
+		},
+		{
+			MethodName: "WriteUser",
+			Handler:    _Userpass_WriteUser_Handler,
+		},
+		{
+			MethodName: "DeleteUser",
+			Handler:    _Userpass_DeleteUser_Handler,
+		},
+		{
+			MethodName: "ListUsers",
+			Handler:    _Userpass_ListUsers_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _Userpass_Login_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "vault.proto",
+}
+
+// OAuthClient is the client API for OAuth service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type OAuthClient interface {
+	ReadClient(ctx context.Context, in *ClientRequest, opts ...grpc.CallOption) (*ClientResponse, error)
+	WriteClient(ctx context.Context, in *WriteClientRequest, opts ...grpc.CallOption) (*ClientResponse, error)
+	DeleteClient(ctx context.Context, in *ClientRequest, opts ...grpc.CallOption) (*ClientResponse, error)
+	ListClients(ctx context.Context, in *ListClientsRequest, opts ...grpc.CallOption) (*ListClientsResponse, error)
+	Token(ctx context.Context, in *AccessTokenRequest, opts ...grpc.CallOption) (*AccessTokenResponse, error)
+	Introspect(ctx context.Context, in *IntrospectRequest, opts ...grpc.CallOption) (*IntrospectResponse, error)
+	Revoke(ctx context.Context, in *OAuthRevokeRequest, opts ...grpc.CallOption) (*OAuthRevokeResponse, error)
+}
+
+type oAuthClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewOAuthClient(cc grpc.ClientConnInterface) OAuthClient {
+	return &oAuthClient{cc}
+}
+
+func (c *oAuthClient) ReadClient(ctx context.Context, in *ClientRequest, opts ...grpc.CallOption) (*ClientResponse, error) {
+	out := new(ClientResponse)
+	err := c.cc.Invoke(ctx, "/pb.OAuth/ReadClient", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oAuthClient) WriteClient(ctx context.Context, in *WriteClientRequest, opts ...grpc.CallOption) (*ClientResponse, error) {
+	out := new(ClientResponse)
+	err := c.cc.Invoke(ctx, "/pb.OAuth/WriteClient", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oAuthClient) DeleteClient(ctx context.Context, in *ClientRequest, opts ...grpc.CallOption) (*ClientResponse, error) {
+	out := new(ClientResponse)
+	err := c.cc.Invoke(ctx, "/pb.OAuth/DeleteClient", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oAuthClient) ListClients(ctx context.Context, in *ListClientsRequest, opts ...grpc.CallOption) (*ListClientsResponse, error) {
+	out := new(ListClientsResponse)
+	err := c.cc.Invoke(ctx, "/pb.OAuth/ListClients", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oAuthClient) Token(ctx context.Context, in *AccessTokenRequest, opts ...grpc.CallOption) (*AccessTokenResponse, error) {
+	out := new(AccessTokenResponse)
+	err := c.cc.Invoke(ctx, "/pb.OAuth/Token", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oAuthClient) Introspect(ctx context.Context, in *IntrospectRequest, opts ...grpc.CallOption) (*IntrospectResponse, error) {
+	out := new(IntrospectResponse)
+	err := c.cc.Invoke(ctx, "/pb.OAuth/Introspect", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oAuthClient) Revoke(ctx context.Context, in *OAuthRevokeRequest, opts ...grpc.CallOption) (*OAuthRevokeResponse, error) {
+	out := new(OAuthRevokeResponse)
+	err := c.cc.Invoke(ctx, "/pb.OAuth/Revoke", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// OAuthServer is the server API for OAuth service.
+// All implementations should embed UnimplementedOAuthServer
+// for forward compatibility
+type OAuthServer interface {
+	ReadClient(context.Context, *ClientRequest) (*ClientResponse, error)
+	WriteClient(context.Context, *WriteClientRequest) (*ClientResponse, error)
+	DeleteClient(context.Context, *ClientRequest) (*ClientResponse, error)
+	ListClients(context.Context, *ListClientsRequest) (*ListClientsResponse, error)
+	Token(context.Context, *AccessTokenRequest) (*AccessTokenResponse, error)
+	Introspect(context.Context, *IntrospectRequest) (*IntrospectResponse, error)
+	Revoke(context.Context, *OAuthRevokeRequest) (*OAuthRevokeResponse, error)
+}
+
+// UnimplementedOAuthServer should be embedded to have forward compatible implementations.
+type UnimplementedOAuthServer struct {
+}
+
+func (UnimplementedOAuthServer) ReadClient(context.Context, *ClientRequest) (*ClientResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadClient not implemented")
+}
+func (UnimplementedOAuthServer) WriteClient(context.Context, *WriteClientRequest) (*ClientResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WriteClient not implemented")
+}
+func (UnimplementedOAuthServer) DeleteClient(context.Context, *ClientRequest) (*ClientResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteClient not implemented")
+}
+func (UnimplementedOAuthServer) ListClients(context.Context, *ListClientsRequest) (*ListClientsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListClients not implemented")
+}
+func (UnimplementedOAuthServer) Token(context.Context, *AccessTokenRequest) (*AccessTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Token not implemented")
+}
+func (UnimplementedOAuthServer) Introspect(context.Context, *IntrospectRequest) (*IntrospectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Introspect not implemented")
+}
+func (UnimplementedOAuthServer) Revoke(context.Context, *OAuthRevokeRequest) (*OAuthRevokeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Revoke not implemented")
+}
+
+// UnsafeOAuthServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to OAuthServer will
+// result in compilation errors.
+type UnsafeOAuthServer interface {
+	mustEmbedUnimplementedOAuthServer()
+}
+
+func RegisterOAuthServer(s grpc.ServiceRegistrar, srv OAuthServer) {
+	s.RegisterService(&OAuth_ServiceDesc, srv)
+}
+
+func _OAuth_ReadClient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClientRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OAuthServer).ReadClient(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.OAuth/ReadClient",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OAuthServer).ReadClient(ctx, req.(*ClientRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OAuth_WriteClient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WriteClientRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OAuthServer).WriteClient(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.OAuth/WriteClient",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OAuthServer).WriteClient(ctx, req.(*WriteClientRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OAuth_DeleteClient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClientRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OAuthServer).DeleteClient(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.OAuth/DeleteClient",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OAuthServer).DeleteClient(ctx, req.(*ClientRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OAuth_ListClients_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListClientsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OAuthServer).ListClients(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.OAuth/ListClients",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OAuthServer).ListClients(ctx, req.(*ListClientsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OAuth_Token_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AccessTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OAuthServer).Token(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.OAuth/Token",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OAuthServer).Token(ctx, req.(*AccessTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OAuth_Introspect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IntrospectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OAuthServer).Introspect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.OAuth/Introspect",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OAuthServer).Introspect(ctx, req.(*IntrospectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OAuth_Revoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OAuthRevokeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OAuthServer).Revoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.OAuth/Revoke",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OAuthServer).Revoke(ctx, req.(*OAuthRevokeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// OAuth_ServiceDesc is the grpc.ServiceDesc for OAuth service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var OAuth_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.OAuth",
+	HandlerType: (*OAuthServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReadClient",
+			Handler:    _OAuth_ReadClient_Handler,
+		},
+		{
+			MethodName: "WriteClient",
+			Handler:    _OAuth_WriteClient_Handler,
+		},
+		{
+			MethodName: "DeleteClient",
+			Handler:    _OAuth_DeleteClient_Handler,
+		},
+		{
+			MethodName: "ListClients",
+			Handler:    _OAuth_ListClients_Handler,
+		},
+		{
+			MethodName: "Token",
+			Handler:    _OAuth_Token_Handler,
+		},
+		{
+			MethodName: "Introspect",
+			Handler:    _OAuth_Introspect_Handler,
+		},
+		{
+			MethodName: "Revoke",
+			Handler:    _OAuth_Revoke_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "vault.proto",
+}
+
+// DenylistClient is the client API for Denylist service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DenylistClient interface {
+	Deny(ctx context.Context, in *DenyRequest, opts ...grpc.CallOption) (*DenylistEntryResponse, error)
+	Allow(ctx context.Context, in *DenylistEntryRequest, opts ...grpc.CallOption) (*DenylistEntryResponse, error)
+	ReadDenied(ctx context.Context, in *DenylistEntryRequest, opts ...grpc.CallOption) (*DenylistEntryResponse, error)
+	ListDenied(ctx context.Context, in *ListDeniedRequest, opts ...grpc.CallOption) (*ListDeniedResponse, error)
+}
+
+type denylistClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDenylistClient(cc grpc.ClientConnInterface) DenylistClient {
+	return &denylistClient{cc}
+}
+
+func (c *denylistClient) Deny(ctx context.Context, in *DenyRequest, opts ...grpc.CallOption) (*DenylistEntryResponse, error) {
+	out := new(DenylistEntryResponse)
+	err := c.cc.Invoke(ctx, "/pb.Denylist/Deny", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *denylistClient) Allow(ctx context.Context, in *DenylistEntryRequest, opts ...grpc.CallOption) (*DenylistEntryResponse, error) {
+	out := new(DenylistEntryResponse)
+	err := c.cc.Invoke(ctx, "/pb.Denylist/Allow", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *denylistClient) ReadDenied(ctx context.Context, in *DenylistEntryRequest, opts ...grpc.CallOption) (*DenylistEntryResponse, error) {
+	out := new(DenylistEntryResponse)
+	err := c.cc.Invoke(ctx, "/pb.Denylist/ReadDenied", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *denylistClient) ListDenied(ctx context.Context, in *ListDeniedRequest, opts ...grpc.CallOption) (*ListDeniedResponse, error) {
+	out := new(ListDeniedResponse)
+	err := c.cc.Invoke(ctx, "/pb.Denylist/ListDenied", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DenylistServer is the server API for Denylist service.
+// All implementations should embed UnimplementedDenylistServer
+// for forward compatibility
+type DenylistServer interface {
+	Deny(context.Context, *DenyRequest) (*DenylistEntryResponse, error)
+	Allow(context.Context, *DenylistEntryRequest) (*DenylistEntryResponse, error)
+	ReadDenied(context.Context, *DenylistEntryRequest) (*DenylistEntryResponse, error)
+	ListDenied(context.Context, *ListDeniedRequest) (*ListDeniedResponse, error)
+}
+
+// UnimplementedDenylistServer should be embedded to have forward compatible implementations.
+type UnimplementedDenylistServer struct {
+}
+
+func (UnimplementedDenylistServer) Deny(context.Context, *DenyRequest) (*DenylistEntryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Deny not implemented")
+}
+func (UnimplementedDenylistServer) Allow(context.Context, *DenylistEntryRequest) (*DenylistEntryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Allow not implemented")
+}
+func (UnimplementedDenylistServer) ReadDenied(context.Context, *DenylistEntryRequest) (*DenylistEntryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadDenied not implemented")
+}
+func (UnimplementedDenylistServer) ListDenied(context.Context, *ListDeniedRequest) (*ListDeniedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDenied not implemented")
+}
+
+// UnsafeDenylistServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DenylistServer will
+// result in compilation errors.
+type UnsafeDenylistServer interface {
+	mustEmbedUnimplementedDenylistServer()
+}
+
+func RegisterDenylistServer(s grpc.ServiceRegistrar, srv DenylistServer) {
+	s.RegisterService(&Denylist_ServiceDesc, srv)
+}
+
+func _Denylist_Deny_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DenyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DenylistServer).Deny(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Denylist/Deny",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DenylistServer).Deny(ctx, req.(*DenyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Denylist_Allow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DenylistEntryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DenylistServer).Allow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Denylist/Allow",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DenylistServer).Allow(ctx, req.(*DenylistEntryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Denylist_ReadDenied_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DenylistEntryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DenylistServer).ReadDenied(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Denylist/ReadDenied",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DenylistServer).ReadDenied(ctx, req.(*DenylistEntryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Denylist_ListDenied_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeniedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DenylistServer).ListDenied(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Denylist/ListDenied",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DenylistServer).ListDenied(ctx, req.(*ListDeniedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Denylist_ServiceDesc is the grpc.ServiceDesc for Denylist service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Denylist_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.Denylist",
+	HandlerType: (*DenylistServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Deny",
+			Handler:    _Denylist_Deny_Handler,
+		},
+		{
+			MethodName: "Allow",
+			Handler:    _Denylist_Allow_Handler,
+		},
+		{
+			MethodName: "ReadDenied",
+			Handler:    _Denylist_ReadDenied_Handler,
+		},
+		{
+			MethodName: "ListDenied",
+			Handler:    _Denylist_ListDenied_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "vault.proto",
+}
+
+// PKIClient is the client API for PKI service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PKIClient interface {
+	GenerateCA(ctx context.Context, in *PKIGenerateCARequest, opts ...grpc.CallOption) (*PKICAResponse, error)
+	ImportCA(ctx context.Context, in *PKIImportCARequest, opts ...grpc.CallOption) (*PKICAResponse, error)
+	ReadCA(ctx context.Context, in *PKIReadCARequest, opts ...grpc.CallOption) (*PKICAResponse, error)
+	WriteRole(ctx context.Context, in *PKIWriteRoleRequest, opts ...grpc.CallOption) (*PKIRoleResponse, error)
+	ReadRole(ctx context.Context, in *PKIRoleRequest, opts ...grpc.CallOption) (*PKIRoleResponse, error)
+	DeleteRole(ctx context.Context, in *PKIRoleRequest, opts ...grpc.CallOption) (*PKIRoleResponse, error)
+	ListRoles(ctx context.Context, in *PKIListRolesRequest, opts ...grpc.CallOption) (*PKIListRolesResponse, error)
+	Issue(ctx context.Context, in *PKIIssueRequest, opts ...grpc.CallOption) (*PKICertificateResponse, error)
+	Sign(ctx context.Context, in *PKISignRequest, opts ...grpc.CallOption) (*PKICertificateResponse, error)
+	Revoke(ctx context.Context, in *PKICertificateRequest, opts ...grpc.CallOption) (*PKICertificateResponse, error)
+	ReadCertificate(ctx context.Context, in *PKICertificateRequest, opts ...grpc.CallOption) (*PKICertificateResponse, error)
+	ListCertificates(ctx context.Context, in *PKIListCertificatesRequest, opts ...grpc.CallOption) (*PKIListCertificatesResponse, error)
+	ReadCRL(ctx context.Context, in *PKIReadCRLRequest, opts ...grpc.CallOption) (*PKICRLResponse, error)
+}
+
+type pKIClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPKIClient(cc grpc.ClientConnInterface) PKIClient {
+	return &pKIClient{cc}
+}
+
+func (c *pKIClient) GenerateCA(ctx context.Context, in *PKIGenerateCARequest, opts ...grpc.CallOption) (*PKICAResponse, error) {
+	out := new(PKICAResponse)
+	err := c.cc.Invoke(ctx, "/pb.PKI/GenerateCA", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pKIClient) ImportCA(ctx context.Context, in *PKIImportCARequest, opts ...grpc.CallOption) (*PKICAResponse, error) {
+	out := new(PKICAResponse)
+	err := c.cc.Invoke(ctx, "/pb.PKI/ImportCA", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pKIClient) ReadCA(ctx context.Context, in *PKIReadCARequest, opts ...grpc.CallOption) (*PKICAResponse, error) {
+	out := new(PKICAResponse)
+	err := c.cc.Invoke(ctx, "/pb.PKI/ReadCA", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pKIClient) WriteRole(ctx context.Context, in *PKIWriteRoleRequest, opts ...grpc.CallOption) (*PKIRoleResponse, error) {
+	out := new(PKIRoleResponse)
+	err := c.cc.Invoke(ctx, "/pb.PKI/WriteRole", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pKIClient) ReadRole(ctx context.Context, in *PKIRoleRequest, opts ...grpc.CallOption) (*PKIRoleResponse, error) {
+	out := new(PKIRoleResponse)
+	err := c.cc.Invoke(ctx, "/pb.PKI/ReadRole", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pKIClient) DeleteRole(ctx context.Context, in *PKIRoleRequest, opts ...grpc.CallOption) (*PKIRoleResponse, error) {
+	out := new(PKIRoleResponse)
+	err := c.cc.Invoke(ctx, "/pb.PKI/DeleteRole", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pKIClient) ListRoles(ctx context.Context, in *PKIListRolesRequest, opts ...grpc.CallOption) (*PKIListRolesResponse, error) {
+	out := new(PKIListRolesResponse)
+	err := c.cc.Invoke(ctx, "/pb.PKI/ListRoles", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pKIClient) Issue(ctx context.Context, in *PKIIssueRequest, opts ...grpc.CallOption) (*PKICertificateResponse, error) {
+	out := new(PKICertificateResponse)
+	err := c.cc.Invoke(ctx, "/pb.PKI/Issue", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pKIClient) Sign(ctx context.Context, in *PKISignRequest, opts ...grpc.CallOption) (*PKICertificateResponse, error) {
+	out := new(PKICertificateResponse)
+	err := c.cc.Invoke(ctx, "/pb.PKI/Sign", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pKIClient) Revoke(ctx context.Context, in *PKICertificateRequest, opts ...grpc.CallOption) (*PKICertificateResponse, error) {
+	out := new(PKICertificateResponse)
+	err := c.cc.Invoke(ctx, "/pb.PKI/Revoke", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pKIClient) ReadCertificate(ctx context.Context, in *PKICertificateRequest, opts ...grpc.CallOption) (*PKICertificateResponse, error) {
+	out := new(PKICertificateResponse)
+	err := c.cc.Invoke(ctx, "/pb.PKI/ReadCertificate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pKIClient) ListCertificates(ctx context.Context, in *PKIListCertificatesRequest, opts ...grpc.CallOption) (*PKIListCertificatesResponse, error) {
+	out := new(PKIListCertificatesResponse)
+	err := c.cc.Invoke(ctx, "/pb.PKI/ListCertificates", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pKIClient) ReadCRL(ctx context.Context, in *PKIReadCRLRequest, opts ...grpc.CallOption) (*PKICRLResponse, error) {
+	out := new(PKICRLResponse)
+	err := c.cc.Invoke(ctx, "/pb.PKI/ReadCRL", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PKIServer is the server API for PKI service.
+// All implementations should embed UnimplementedPKIServer
+// for forward compatibility
+type PKIServer interface {
+	GenerateCA(context.Context, *PKIGenerateCARequest) (*PKICAResponse, error)
+	ImportCA(context.Context, *PKIImportCARequest) (*PKICAResponse, error)
+	ReadCA(context.Context, *PKIReadCARequest) (*PKICAResponse, error)
+	WriteRole(context.Context, *PKIWriteRoleRequest) (*PKIRoleResponse, error)
+	ReadRole(context.Context, *PKIRoleRequest) (*PKIRoleResponse, error)
+	DeleteRole(context.Context, *PKIRoleRequest) (*PKIRoleResponse, error)
+	ListRoles(context.Context, *PKIListRolesRequest) (*PKIListRolesResponse, error)
+	Issue(context.Context, *PKIIssueRequest) (*PKICertificateResponse, error)
+	Sign(context.Context, *PKISignRequest) (*PKICertificateResponse, error)
+	Revoke(context.Context, *PKICertificateRequest) (*PKICertificateResponse, error)
+	ReadCertificate(context.Context, *PKICertificateRequest) (*PKICertificateResponse, error)
+	ListCertificates(context.Context, *PKIListCertificatesRequest) (*PKIListCertificatesResponse, error)
+	ReadCRL(context.Context, *PKIReadCRLRequest) (*PKICRLResponse, error)
+}
+
+// UnimplementedPKIServer should be embedded to have forward compatible implementations.
+type UnimplementedPKIServer struct {
+}
+
+func (UnimplementedPKIServer) GenerateCA(context.Context, *PKIGenerateCARequest) (*PKICAResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GenerateCA not implemented")
+}
+func (UnimplementedPKIServer) ImportCA(context.Context, *PKIImportCARequest) (*PKICAResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImportCA not implemented")
+}
+func (UnimplementedPKIServer) ReadCA(context.Context, *PKIReadCARequest) (*PKICAResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadCA not implemented")
+}
+func (UnimplementedPKIServer) WriteRole(context.Context, *PKIWriteRoleRequest) (*PKIRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WriteRole not implemented")
+}
+func (UnimplementedPKIServer) ReadRole(context.Context, *PKIRoleRequest) (*PKIRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadRole not implemented")
+}
+func (UnimplementedPKIServer) DeleteRole(context.Context, *PKIRoleRequest) (*PKIRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRole not implemented")
+}
+func (UnimplementedPKIServer) ListRoles(context.Context, *PKIListRolesRequest) (*PKIListRolesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRoles not implemented")
+}
+func (UnimplementedPKIServer) Issue(context.Context, *PKIIssueRequest) (*PKICertificateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Issue not implemented")
+}
+func (UnimplementedPKIServer) Sign(context.Context, *PKISignRequest) (*PKICertificateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sign not implemented")
+}
+func (UnimplementedPKIServer) Revoke(context.Context, *PKICertificateRequest) (*PKICertificateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Revoke not implemented")
+}
+func (UnimplementedPKIServer) ReadCertificate(context.Context, *PKICertificateRequest) (*PKICertificateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadCertificate not implemented")
+}
+func (UnimplementedPKIServer) ListCertificates(context.Context, *PKIListCertificatesRequest) (*PKIListCertificatesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCertificates not implemented")
+}
+func (UnimplementedPKIServer) ReadCRL(context.Context, *PKIReadCRLRequest) (*PKICRLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadCRL not implemented")
+}
+
+// UnsafePKIServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PKIServer will
+// result in compilation errors.
+type UnsafePKIServer interface {
+	mustEmbedUnimplementedPKIServer()
+}
+
+func RegisterPKIServer(s grpc.ServiceRegistrar, srv PKIServer) {
+	s.RegisterService(&PKI_ServiceDesc, srv)
+}
+
+func _PKI_GenerateCA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PKIGenerateCARequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PKIServer).GenerateCA(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PKI/GenerateCA",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PKIServer).GenerateCA(ctx, req.(*PKIGenerateCARequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PKI_ImportCA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PKIImportCARequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PKIServer).ImportCA(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PKI/ImportCA",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PKIServer).ImportCA(ctx, req.(*PKIImportCARequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PKI_ReadCA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PKIReadCARequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PKIServer).ReadCA(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PKI/ReadCA",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PKIServer).ReadCA(ctx, req.(*PKIReadCARequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PKI_WriteRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PKIWriteRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PKIServer).WriteRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PKI/WriteRole",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PKIServer).WriteRole(ctx, req.(*PKIWriteRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PKI_ReadRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PKIRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PKIServer).ReadRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PKI/ReadRole",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PKIServer).ReadRole(ctx, req.(*PKIRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PKI_DeleteRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PKIRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PKIServer).DeleteRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PKI/DeleteRole",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PKIServer).DeleteRole(ctx, req.(*PKIRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PKI_ListRoles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PKIListRolesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PKIServer).ListRoles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PKI/ListRoles",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PKIServer).ListRoles(ctx, req.(*PKIListRolesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PKI_Issue_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PKIIssueRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PKIServer).Issue(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PKI/Issue",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PKIServer).Issue(ctx, req.(*PKIIssueRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PKI_Sign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PKISignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PKIServer).Sign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PKI/Sign",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PKIServer).Sign(ctx, req.(*PKISignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PKI_Revoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PKICertificateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PKIServer).Revoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PKI/Revoke",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PKIServer).Revoke(ctx, req.(*PKICertificateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PKI_ReadCertificate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PKICertificateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PKIServer).ReadCertificate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PKI/ReadCertificate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PKIServer).ReadCertificate(ctx, req.(*PKICertificateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PKI_ListCertificates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PKIListCertificatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PKIServer).ListCertificates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PKI/ListCertificates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PKIServer).ListCertificates(ctx, req.(*PKIListCertificatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PKI_ReadCRL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PKIReadCRLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PKIServer).ReadCRL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PKI/ReadCRL",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PKIServer).ReadCRL(ctx, req.(*PKIReadCRLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PKI_ServiceDesc is the grpc.ServiceDesc for PKI service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PKI_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.PKI",
+	HandlerType: (*PKIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GenerateCA",
+			Handler:    _PKI_GenerateCA_Handler,
+		},
+		{
+			MethodName: "ImportCA",
+			Handler:    _PKI_ImportCA_Handler,
+		},
+		{
+			MethodName: "ReadCA",
+			Handler:    _PKI_ReadCA_Handler,
+		},
+		{
+			MethodName: "WriteRole",
+			Handler:    _PKI_WriteRole_Handler,
+		},
+		{
+			MethodName: "ReadRole",
+			Handler:    _PKI_ReadRole_Handler,
+		},
+		{
+			MethodName: "DeleteRole",
+			Handler:    _PKI_DeleteRole_Handler,
+		},
+		{
+			MethodName: "ListRoles",
+			Handler:    _PKI_ListRoles_Handler,
+		},
+		{
+			MethodName: "Issue",
+			Handler:    _PKI_Issue_Handler,
+		},
+		{
+			MethodName: "Sign",
+			Handler:    _PKI_Sign_Handler,
+		},
+		{
+			MethodName: "Revoke",
+			Handler:    _PKI_Revoke_Handler,
+		},
+		{
+			MethodName: "ReadCertificate",
+			Handler:    _PKI_ReadCertificate_Handler,
+		},
+		{
+			MethodName: "ListCertificates",
+			Handler:    _PKI_ListCertificates_Handler,
+		},
+		{
+			MethodName: "ReadCRL",
+			Handler:    _PKI_ReadCRL_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "vault.proto",
+}
+
+// SSHClient is the client API for SSH service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SSHClient interface {
+	ConfigureCA(ctx context.Context, in *SSHConfigureCARequest, opts ...grpc.CallOption) (*SSHCAResponse, error)
+	ReadCA(ctx context.Context, in *SSHReadCARequest, opts ...grpc.CallOption) (*SSHCAResponse, error)
+	WriteRole(ctx context.Context, in *SSHWriteRoleRequest, opts ...grpc.CallOption) (*SSHRoleResponse, error)
+	ReadRole(ctx context.Context, in *SSHRoleRequest, opts ...grpc.CallOption) (*SSHRoleResponse, error)
+	DeleteRole(ctx context.Context, in *SSHRoleRequest, opts ...grpc.CallOption) (*SSHRoleResponse, error)
+	ListRoles(ctx context.Context, in *SSHListRolesRequest, opts ...grpc.CallOption) (*SSHListRolesResponse, error)
+	Sign(ctx context.Context, in *SSHSignRequest, opts ...grpc.CallOption) (*SSHCertificateResponse, error)
+}
+
+type sSHClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSSHClient(cc grpc.ClientConnInterface) SSHClient {
+	return &sSHClient{cc}
+}
+
+func (c *sSHClient) ConfigureCA(ctx context.Context, in *SSHConfigureCARequest, opts ...grpc.CallOption) (*SSHCAResponse, error) {
+	out := new(SSHCAResponse)
+	err := c.cc.Invoke(ctx, "/pb.SSH/ConfigureCA", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sSHClient) ReadCA(ctx context.Context, in *SSHReadCARequest, opts ...grpc.CallOption) (*SSHCAResponse, error) {
+	out := new(SSHCAResponse)
+	err := c.cc.Invoke(ctx, "/pb.SSH/ReadCA", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sSHClient) WriteRole(ctx context.Context, in *SSHWriteRoleRequest, opts ...grpc.CallOption) (*SSHRoleResponse, error) {
+	out := new(SSHRoleResponse)
+	err := c.cc.Invoke(ctx, "/pb.SSH/WriteRole", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sSHClient) ReadRole(ctx context.Context, in *SSHRoleRequest, opts ...grpc.CallOption) (*SSHRoleResponse, error) {
+	out := new(SSHRoleResponse)
+	err := c.cc.Invoke(ctx, "/pb.SSH/ReadRole", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sSHClient) DeleteRole(ctx context.Context, in *SSHRoleRequest, opts ...grpc.CallOption) (*SSHRoleResponse, error) {
+	out := new(SSHRoleResponse)
+	err := c.cc.Invoke(ctx, "/pb.SSH/DeleteRole", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sSHClient) ListRoles(ctx context.Context, in *SSHListRolesRequest, opts ...grpc.CallOption) (*SSHListRolesResponse, error) {
+	out := new(SSHListRolesResponse)
+	err := c.cc.Invoke(ctx, "/pb.SSH/ListRoles", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sSHClient) Sign(ctx context.Context, in *SSHSignRequest, opts ...grpc.CallOption) (*SSHCertificateResponse, error) {
+	out := new(SSHCertificateResponse)
+	err := c.cc.Invoke(ctx, "/pb.SSH/Sign", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SSHServer is the server API for SSH service.
+// All implementations should embed UnimplementedSSHServer
+// for forward compatibility
+type SSHServer interface {
+	ConfigureCA(context.Context, *SSHConfigureCARequest) (*SSHCAResponse, error)
+	ReadCA(context.Context, *SSHReadCARequest) (*SSHCAResponse, error)
+	WriteRole(context.Context, *SSHWriteRoleRequest) (*SSHRoleResponse, error)
+	ReadRole(context.Context, *SSHRoleRequest) (*SSHRoleResponse, error)
+	DeleteRole(context.Context, *SSHRoleRequest) (*SSHRoleResponse, error)
+	ListRoles(context.Context, *SSHListRolesRequest) (*SSHListRolesResponse, error)
+	Sign(context.Context, *SSHSignRequest) (*SSHCertificateResponse, error)
+}
+
+// UnimplementedSSHServer should be embedded to have forward compatible implementations.
+type UnimplementedSSHServer struct {
+}
+
+func (UnimplementedSSHServer) ConfigureCA(context.Context, *SSHConfigureCARequest) (*SSHCAResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfigureCA not implemented")
+}
+func (UnimplementedSSHServer) ReadCA(context.Context, *SSHReadCARequest) (*SSHCAResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadCA not implemented")
+}
+func (UnimplementedSSHServer) WriteRole(context.Context, *SSHWriteRoleRequest) (*SSHRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WriteRole not implemented")
+}
+func (UnimplementedSSHServer) ReadRole(context.Context, *SSHRoleRequest) (*SSHRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadRole not implemented")
+}
+func (UnimplementedSSHServer) DeleteRole(context.Context, *SSHRoleRequest) (*SSHRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRole not implemented")
+}
+func (UnimplementedSSHServer) ListRoles(context.Context, *SSHListRolesRequest) (*SSHListRolesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRoles not implemented")
+}
+func (UnimplementedSSHServer) Sign(context.Context, *SSHSignRequest) (*SSHCertificateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sign not implemented")
+}
+
+// UnsafeSSHServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SSHServer will
+// result in compilation errors.
+type UnsafeSSHServer interface {
+	mustEmbedUnimplementedSSHServer()
+}
+
+func RegisterSSHServer(s grpc.ServiceRegistrar, srv SSHServer) {
+	s.RegisterService(&SSH_ServiceDesc, srv)
+}
+
+func _SSH_ConfigureCA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SSHConfigureCARequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SSHServer).ConfigureCA(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.SSH/ConfigureCA",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SSHServer).ConfigureCA(ctx, req.(*SSHConfigureCARequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SSH_ReadCA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SSHReadCARequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SSHServer).ReadCA(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.SSH/ReadCA",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SSHServer).ReadCA(ctx, req.(*SSHReadCARequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SSH_WriteRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SSHWriteRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SSHServer).WriteRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.SSH/WriteRole",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SSHServer).WriteRole(ctx, req.(*SSHWriteRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SSH_ReadRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SSHRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SSHServer).ReadRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.SSH/ReadRole",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SSHServer).ReadRole(ctx, req.(*SSHRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SSH_DeleteRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SSHRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SSHServer).DeleteRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.SSH/DeleteRole",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SSHServer).DeleteRole(ctx, req.(*SSHRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SSH_ListRoles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SSHListRolesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SSHServer).ListRoles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.SSH/ListRoles",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SSHServer).ListRoles(ctx, req.(*SSHListRolesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SSH_Sign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SSHSignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SSHServer).Sign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.SSH/Sign",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SSHServer).Sign(ctx, req.(*SSHSignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SSH_ServiceDesc is the grpc.ServiceDesc for SSH service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SSH_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.SSH",
+	HandlerType: (*SSHServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ConfigureCA",
+			Handler:    _SSH_ConfigureCA_Handler,
+		},
+		{
+			MethodName: "ReadCA",
+			Handler:    _SSH_ReadCA_Handler,
+		},
+		{
+			MethodName: "WriteRole",
+			Handler:    _SSH_WriteRole_Handler,
+		},
+		{
+			MethodName: "ReadRole",
+			Handler:    _SSH_ReadRole_Handler,
+		},
+		{
+			MethodName: "DeleteRole",
+			Handler:    _SSH_DeleteRole_Handler,
+		},
+		{
+			MethodName: "ListRoles",
+			Handler:    _SSH_ListRoles_Handler,
+		},
+		{
+			MethodName: "Sign",
+			Handler:    _SSH_Sign_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "vault.proto",
+}
+
+// WrappingClient is the client API for Wrapping service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WrappingClient interface {
+	Wrap(ctx context.Context, in *WrapRequest, opts ...grpc.CallOption) (*WrapInfoResponse, error)
+	Unwrap(ctx context.Context, in *UnwrapRequest, opts ...grpc.CallOption) (*UnwrapResponse, error)
+	Lookup(ctx context.Context, in *UnwrapRequest, opts ...grpc.CallOption) (*WrapInfoResponse, error)
+}
+
+type wrappingClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWrappingClient(cc grpc.ClientConnInterface) WrappingClient {
+	return &wrappingClient{cc}
+}
+
+func (c *wrappingClient) Wrap(ctx context.Context, in *WrapRequest, opts ...grpc.CallOption) (*WrapInfoResponse, error) {
+	out := new(WrapInfoResponse)
+	err := c.cc.Invoke(ctx, "/pb.Wrapping/Wrap", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wrappingClient) Unwrap(ctx context.Context, in *UnwrapRequest, opts ...grpc.CallOption) (*UnwrapResponse, error) {
+	out := new(UnwrapResponse)
+	err := c.cc.Invoke(ctx, "/pb.Wrapping/Unwrap", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wrappingClient) Lookup(ctx context.Context, in *UnwrapRequest, opts ...grpc.CallOption) (*WrapInfoResponse, error) {
+	out := new(WrapInfoResponse)
+	err := c.cc.Invoke(ctx, "/pb.Wrapping/Lookup", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// WrappingServer is the server API for Wrapping service.
+// All implementations should embed UnimplementedWrappingServer
+// for forward compatibility
+type WrappingServer interface {
+	Wrap(context.Context, *WrapRequest) (*WrapInfoResponse, error)
+	Unwrap(context.Context, *UnwrapRequest) (*UnwrapResponse, error)
+	Lookup(context.Context, *UnwrapRequest) (*WrapInfoResponse, error)
+}
+
+// UnimplementedWrappingServer should be embedded to have forward compatible implementations.
+type UnimplementedWrappingServer struct {
+}
+
+func (UnimplementedWrappingServer) Wrap(context.Context, *WrapRequest) (*WrapInfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Wrap not implemented")
+}
+func (UnimplementedWrappingServer) Unwrap(context.Context, *UnwrapRequest) (*UnwrapResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unwrap not implemented")
+}
+func (UnimplementedWrappingServer) Lookup(context.Context, *UnwrapRequest) (*WrapInfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Lookup not implemented")
+}
+
+// UnsafeWrappingServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WrappingServer will
+// result in compilation errors.
+type UnsafeWrappingServer interface {
+	mustEmbedUnimplementedWrappingServer()
+}
+
+func RegisterWrappingServer(s grpc.ServiceRegistrar, srv WrappingServer) {
+	s.RegisterService(&Wrapping_ServiceDesc, srv)
+}
+
+func _Wrapping_Wrap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WrapRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WrappingServer).Wrap(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Wrapping/Wrap",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WrappingServer).Wrap(ctx, req.(*WrapRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Wrapping_Unwrap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnwrapRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WrappingServer).Unwrap(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Wrapping/Unwrap",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WrappingServer).Unwrap(ctx, req.(*UnwrapRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Wrapping_Lookup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnwrapRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WrappingServer).Lookup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Wrapping/Lookup",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WrappingServer).Lookup(ctx, req.(*UnwrapRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Wrapping_ServiceDesc is the grpc.ServiceDesc for Wrapping service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Wrapping_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.Wrapping",
+	HandlerType: (*WrappingServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Wrap",
+			Handler:    _Wrapping_Wrap_Handler,
+		},
+		{
+			MethodName: "Unwrap",
+			Handler:    _Wrapping_Unwrap_Handler,
+		},
+		{
+			MethodName: "Lookup",
+			Handler:    _Wrapping_Lookup_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "vault.proto",
+}